@@ -0,0 +1,117 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package k3s
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+	log "github.com/rs/zerolog/log"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// k3sUninstallScript is installed by the k3s installer on every server node
+	k3sUninstallScript = "/usr/local/bin/k3s-uninstall.sh"
+	// k3sAgentUninstallScript is installed by the k3s installer on every agent node
+	k3sAgentUninstallScript = "/usr/local/bin/k3s-agent-uninstall.sh"
+)
+
+// nodeAddresses returns the addresses used to reach a set of nodes over SSH.
+// Public addresses are optional, the private ones are used when none are set.
+func nodeAddresses(public, private []string) []string {
+	addresses := nonEmpty(public)
+	if len(addresses) == 0 {
+		addresses = nonEmpty(private)
+	}
+	return addresses
+}
+
+func nonEmpty(hosts []string) []string {
+	addresses := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		if host = strings.TrimSpace(host); host != "" {
+			addresses = append(addresses, host)
+		}
+	}
+	return addresses
+}
+
+// uninstallCommand runs script when it exists, with sudo for non-root users
+func uninstallCommand(user, script string) string {
+	if user != "root" {
+		return fmt.Sprintf("if [ -x %[1]s ]; then sudo -n %[1]s; fi", script)
+	}
+	return fmt.Sprintf("if [ -x %[1]s ]; then %[1]s; fi", script)
+}
+
+// UninstallK3sNodes connects to every server and agent node of the cluster
+// over SSH and removes k3s with the uninstall script left by the installer.
+// Nodes where k3s is already gone are skipped.
+func UninstallK3sNodes(cl *pkgtypes.Cluster) error {
+	servers := nodeAddresses(cl.K3sAuth.K3sServersPublicIps, cl.K3sAuth.K3sServersPrivateIps)
+	agents := nodeAddresses(cl.K3sAuth.K3sAgentsPublicIps, cl.K3sAuth.K3sAgentsPrivateIps)
+	if len(servers) == 0 {
+		return fmt.Errorf("no k3s server addresses known for cluster %s", cl.ClusterName)
+	}
+
+	signer, err := ssh.ParsePrivateKey([]byte(cl.K3sAuth.K3sSSHPrivateKey))
+	if err != nil {
+		return fmt.Errorf("error parsing k3s ssh private key: %w", err)
+	}
+
+	sshConfig := &ssh.ClientConfig{
+		User: cl.K3sAuth.K3sSSHUser,
+		Auth: []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		// The nodes are provided by the user and have no known_hosts entry here
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(), //nolint:gosec // nodes are trusted through the provided ssh key
+		Timeout:         30 * time.Second,
+	}
+
+	// Agents go first so they do not keep trying to rejoin removed servers
+	agentCommand := uninstallCommand(cl.K3sAuth.K3sSSHUser, k3sAgentUninstallScript)
+	for _, host := range agents {
+		log.Info().Msgf("uninstalling k3s from agent node %s", host)
+		if err := runSSHCommand(host, sshConfig, agentCommand); err != nil {
+			return fmt.Errorf("error uninstalling k3s from agent node %s: %w", host, err)
+		}
+	}
+
+	serverCommand := uninstallCommand(cl.K3sAuth.K3sSSHUser, k3sUninstallScript)
+	for _, host := range servers {
+		log.Info().Msgf("uninstalling k3s from server node %s", host)
+		if err := runSSHCommand(host, sshConfig, serverCommand); err != nil {
+			return fmt.Errorf("error uninstalling k3s from server node %s: %w", host, err)
+		}
+	}
+
+	return nil
+}
+
+func runSSHCommand(host string, sshConfig *ssh.ClientConfig, command string) error {
+	client, err := ssh.Dial("tcp", net.JoinHostPort(host, "22"), sshConfig)
+	if err != nil {
+		return fmt.Errorf("error connecting to %s: %w", host, err)
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("error opening ssh session: %w", err)
+	}
+	defer session.Close()
+
+	output, err := session.CombinedOutput(command)
+	if err != nil {
+		return fmt.Errorf("error running %q: %w: %s", command, err, output)
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package k3s
+
+import (
+	"reflect"
+	"testing"
+
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+)
+
+func TestNodeAddresses(t *testing.T) {
+	tests := []struct {
+		name    string
+		public  []string
+		private []string
+		want    []string
+	}{
+		{name: "public addresses", public: []string{"192.0.2.1"}, private: []string{"10.0.0.1"}, want: []string{"192.0.2.1"}},
+		{name: "private fallback", private: []string{"10.0.0.1", "10.0.0.2"}, want: []string{"10.0.0.1", "10.0.0.2"}},
+		{name: "blank public addresses", public: []string{""}, private: []string{"10.0.0.1", " "}, want: []string{"10.0.0.1"}},
+		{name: "none", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nodeAddresses(tt.public, tt.private)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("nodeAddresses() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUninstallK3sNodesWithoutAddresses(t *testing.T) {
+	cl := &pkgtypes.Cluster{ClusterName: "test"}
+	if err := UninstallK3sNodes(cl); err == nil {
+		t.Error("expected an error when no server address is known")
+	}
+}
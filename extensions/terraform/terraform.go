@@ -7,31 +7,43 @@ See the LICENSE file for more details.
 package terraform
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
 
 	"github.com/konstructio/kubefirst-api/internal"
-	log "github.com/rs/zerolog/log"
+	"github.com/konstructio/kubefirst-api/internal/clusterlogs"
+	"github.com/konstructio/kubefirst-api/internal/tracing"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-func initActionAutoApprove(terraformClientPath string, tfAction, tfEntrypoint string, tfEnvs map[string]string) error {
-	log.Printf("initActionAutoApprove - action: %s entrypoint: %s", tfAction, tfEntrypoint)
+// entrypointKey records the terraform directory a span ran in
+const entrypointKey = attribute.Key("terraform.entrypoint")
 
-	err := os.Chdir(tfEntrypoint)
-	if err != nil {
-		log.Error().Msgf("error: could not change to directory %s", tfEntrypoint)
-		return fmt.Errorf("error: could not change to directory %s: %w", tfEntrypoint, err)
-	}
+func initActionAutoApprove(ctx context.Context, terraformClientPath string, tfAction, tfEntrypoint string, tfEnvs map[string]string) (err error) {
+	logger := clusterlogs.Logger(ctx)
+
+	logger.Printf("initActionAutoApprove - action: %s entrypoint: %s", tfAction, tfEntrypoint)
+
+	ctx, endSpan := tracing.Start(ctx, "terraform "+tfAction, entrypointKey.String(tfEntrypoint))
+	defer func() { endSpan(err) }()
 
-	err = internal.ExecShellWithVars(tfEnvs, terraformClientPath, "init", "-force-copy")
+	// terraform runs in tfEntrypoint rather than in the working directory of
+	// the process, which concurrent jobs share
+	chdir := fmt.Sprintf("-chdir=%s", tfEntrypoint)
+
+	err = internal.ExecShellWithVarsContext(ctx, tfEnvs, terraformClientPath, chdir, "init", "-force-copy")
 	if err != nil {
-		log.Error().Msgf("error: terraform init for %s failed: %s", tfEntrypoint, err)
+		logger.Error().Msgf("error: terraform init for %s failed: %s", tfEntrypoint, err)
 		return fmt.Errorf("error: terraform init for %s failed: %w", tfEntrypoint, err)
 	}
 
-	err = internal.ExecShellWithVars(tfEnvs, terraformClientPath, tfAction, "-auto-approve")
+	err = internal.ExecShellWithVarsContext(ctx, tfEnvs, terraformClientPath, chdir, tfAction, "-auto-approve")
 	if err != nil {
-		log.Error().Msgf("error: terraform %s -auto-approve for %s failed %s", tfAction, tfEntrypoint, err)
+		logger.Error().Msgf("error: terraform %s -auto-approve for %s failed %s", tfAction, tfEntrypoint, err)
 		return fmt.Errorf("error: terraform %s -auto-approve for %s failed: %w", tfAction, tfEntrypoint, err)
 	}
 
@@ -41,8 +53,14 @@ func initActionAutoApprove(terraformClientPath string, tfAction, tfEntrypoint st
 }
 
 func InitApplyAutoApprove(terraformClientPath string, tfEntrypoint string, tfEnvs map[string]string) error {
+	return InitApplyAutoApproveContext(context.Background(), terraformClientPath, tfEntrypoint, tfEnvs)
+}
+
+// InitApplyAutoApproveContext runs terraform init and apply, interrupting
+// terraform when ctx is cancelled
+func InitApplyAutoApproveContext(ctx context.Context, terraformClientPath string, tfEntrypoint string, tfEnvs map[string]string) error {
 	tfAction := "apply"
-	err := initActionAutoApprove(terraformClientPath, tfAction, tfEntrypoint, tfEnvs)
+	err := initActionAutoApprove(ctx, terraformClientPath, tfAction, tfEntrypoint, tfEnvs)
 	if err != nil {
 		return err
 	}
@@ -50,10 +68,82 @@ func InitApplyAutoApprove(terraformClientPath string, tfEntrypoint string, tfEnv
 }
 
 func InitDestroyAutoApprove(terraformClientPath string, tfEntrypoint string, tfEnvs map[string]string) error {
+	return InitDestroyAutoApproveContext(context.Background(), terraformClientPath, tfEntrypoint, tfEnvs)
+}
+
+// InitDestroyAutoApproveContext runs terraform init and destroy, interrupting
+// terraform when ctx is cancelled
+func InitDestroyAutoApproveContext(ctx context.Context, terraformClientPath string, tfEntrypoint string, tfEnvs map[string]string) error {
 	tfAction := "destroy"
-	err := initActionAutoApprove(terraformClientPath, tfAction, tfEntrypoint, tfEnvs)
+	err := initActionAutoApprove(ctx, terraformClientPath, tfAction, tfEntrypoint, tfEnvs)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// InitPlanContext runs terraform init and plan and returns a summary of the
+// planned changes. It does not lock the state, so it is safe to run next to a
+// create.
+func InitPlanContext(ctx context.Context, terraformClientPath string, tfEntrypoint string, tfEnvs map[string]string) (_ *pkgtypes.TerraformPlanSummary, err error) {
+	logger := clusterlogs.Logger(ctx)
+
+	logger.Info().Msgf("InitPlanContext - entrypoint: %s", tfEntrypoint)
+
+	ctx, endSpan := tracing.Start(ctx, "terraform plan", entrypointKey.String(tfEntrypoint))
+	defer func() { endSpan(err) }()
+
+	chdir := fmt.Sprintf("-chdir=%s", tfEntrypoint)
+	planFile := "kubefirst-dry-run.tfplan"
+
+	err = internal.ExecShellWithVarsContext(ctx, tfEnvs, terraformClientPath, chdir, "init", "-input=false")
+	if err != nil {
+		return nil, fmt.Errorf("error: terraform init for %s failed: %w", tfEntrypoint, err)
+	}
+
+	err = internal.ExecShellWithVarsContext(ctx, tfEnvs, terraformClientPath, chdir, "plan", "-input=false", "-lock=false", "-out="+planFile)
+	if err != nil {
+		return nil, fmt.Errorf("error: terraform plan for %s failed: %w", tfEntrypoint, err)
+	}
+
+	out, err := internal.ExecShellWithVarsReturnStdoutContext(ctx, tfEnvs, terraformClientPath, chdir, "show", "-json", planFile)
+	if err != nil {
+		return nil, fmt.Errorf("error: terraform show for %s failed: %w", tfEntrypoint, err)
+	}
+
+	return summarizePlan([]byte(out))
+}
+
+// summarizePlan counts the resource changes in the json output of terraform show
+func summarizePlan(planJSON []byte) (*pkgtypes.TerraformPlanSummary, error) {
+	var plan struct {
+		ResourceChanges []struct {
+			Type   string `json:"type"`
+			Change struct {
+				Actions []string `json:"actions"`
+			} `json:"change"`
+		} `json:"resource_changes"`
+	}
+	if err := json.Unmarshal(planJSON, &plan); err != nil {
+		return nil, fmt.Errorf("error parsing terraform plan: %w", err)
+	}
+
+	summary := &pkgtypes.TerraformPlanSummary{Resources: map[string]int{}}
+	for _, rc := range plan.ResourceChanges {
+		actions := rc.Change.Actions
+		if slices.Contains(actions, "create") {
+			summary.Add++
+		}
+		if slices.Contains(actions, "update") {
+			summary.Change++
+		}
+		if slices.Contains(actions, "delete") {
+			summary.Destroy++
+		}
+		if !slices.Equal(actions, []string{"no-op"}) && !slices.Equal(actions, []string{"read"}) {
+			summary.Resources[rc.Type]++
+		}
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,41 @@
+package terraform
+
+import (
+	"testing"
+)
+
+func TestSummarizePlan(t *testing.T) {
+	planJSON := []byte(`{
+		"resource_changes": [
+			{"type": "aws_vpc", "change": {"actions": ["create"]}},
+			{"type": "aws_subnet", "change": {"actions": ["create"]}},
+			{"type": "aws_subnet", "change": {"actions": ["create"]}},
+			{"type": "aws_iam_role", "change": {"actions": ["update"]}},
+			{"type": "aws_instance", "change": {"actions": ["delete", "create"]}},
+			{"type": "aws_s3_bucket", "change": {"actions": ["no-op"]}}
+		]
+	}`)
+
+	summary, err := summarizePlan(planJSON)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if summary.Add != 4 || summary.Change != 1 || summary.Destroy != 1 {
+		t.Errorf("expected 4 to add, 1 to change, 1 to destroy, got %+v", summary)
+	}
+
+	expected := map[string]int{"aws_vpc": 1, "aws_subnet": 2, "aws_iam_role": 1, "aws_instance": 1}
+	if len(summary.Resources) != len(expected) {
+		t.Fatalf("expected resources %v, got %v", expected, summary.Resources)
+	}
+	for resourceType, count := range expected {
+		if summary.Resources[resourceType] != count {
+			t.Errorf("expected %d %s, got %d", count, resourceType, summary.Resources[resourceType])
+		}
+	}
+
+	if _, err := summarizePlan([]byte("not json")); err == nil {
+		t.Error("expected an error for invalid plan output")
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+)
+
+// keyPrefix marks a token as a named API key, as opposed to K1_ACCESS_TOKEN
+const keyPrefix = "k1"
+
+var (
+	// Scopes lists every scope that can be granted to an API key
+	Scopes = []string{
+		constants.ScopeClustersRead,
+		constants.ScopeClustersWrite,
+		constants.ScopeServicesRead,
+		constants.ScopeServicesWrite,
+		constants.ScopeSecretsAdmin,
+		constants.ScopeAPIKeysAdmin,
+		constants.ScopeRoleBindingsAdmin,
+		constants.ScopeAuditRead,
+		constants.ScopeNotificationsAdmin,
+	}
+
+	// validName keeps API key names usable in a Kubernetes Secret name and
+	// free of the separator used in keys
+	validName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,38}[a-z0-9])?$`)
+
+	ErrExpired = errors.New("api key has expired")
+	ErrInvalid = errors.New("not a valid api key")
+)
+
+// ValidateRequest checks the name, scopes and expiry of a new API key
+func ValidateRequest(req *pkgtypes.APIKeyRequest, now time.Time) error {
+	if !validName.MatchString(req.Name) {
+		return fmt.Errorf("api key name %q must be at most 40 lowercase alphanumeric characters or '-'", req.Name)
+	}
+
+	if len(req.Scopes) == 0 {
+		return errors.New("at least one scope is required")
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(Scopes, scope) {
+			return fmt.Errorf("unknown scope %q, valid scopes are: %s", scope, strings.Join(Scopes, ", "))
+		}
+	}
+
+	if req.ExpiresAt != "" {
+		expiresAt, err := time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil {
+			return fmt.Errorf("expires_at must be an RFC 3339 timestamp: %w", err)
+		}
+		if !expiresAt.After(now) {
+			return errors.New("expires_at must be in the future")
+		}
+	}
+
+	return nil
+}
+
+// MissingScopes returns the requested scopes that are not in held, so a
+// caller cannot grant an API key more than it holds itself
+func MissingScopes(requested, held []string) []string {
+	var missing []string
+	for _, scope := range requested {
+		if !slices.Contains(held, scope) {
+			missing = append(missing, scope)
+		}
+	}
+
+	return missing
+}
+
+// Generate returns a new key for the named API key and the hash to store for it
+func Generate(name string) (string, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", fmt.Errorf("error generating api key: %w", err)
+	}
+
+	encoded := hex.EncodeToString(secret)
+	return fmt.Sprintf("%s.%s.%s", keyPrefix, name, encoded), Hash(encoded), nil
+}
+
+// Hash returns the hash stored for the secret part of a key
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIKey reports whether a bearer token has the format of a named API key
+func IsAPIKey(key string) bool {
+	return strings.HasPrefix(key, keyPrefix+".")
+}
+
+// Parse splits a key into the name of its API key and its secret part
+func Parse(key string) (string, string, error) {
+	parts := strings.SplitN(key, ".", 3)
+	if len(parts) != 3 || parts[0] != keyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", "", ErrInvalid
+	}
+
+	return parts[1], parts[2], nil
+}
+
+// Verify checks the secret part of a key against a stored API key
+func Verify(apiKey *pkgtypes.APIKey, secret string, now time.Time) error {
+	if subtle.ConstantTimeCompare([]byte(Hash(secret)), []byte(apiKey.HashedKey)) != 1 {
+		return ErrInvalid
+	}
+
+	if apiKey.ExpiresAt != "" {
+		expiresAt, err := time.Parse(time.RFC3339, apiKey.ExpiresAt)
+		if err != nil || !now.Before(expiresAt) {
+			return ErrExpired
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package apikeys
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+)
+
+func TestGenerateAndVerify(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	key, hash, err := Generate("ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name, secret, err := Parse(key)
+	if err != nil {
+		t.Fatalf("unexpected error parsing %q: %s", key, err)
+	}
+	if name != "ci" {
+		t.Errorf("expected name ci, got %q", name)
+	}
+
+	tests := []struct {
+		name     string
+		apiKey   pkgtypes.APIKey
+		secret   string
+		expected error
+	}{
+		{name: "valid", apiKey: pkgtypes.APIKey{HashedKey: hash}, secret: secret},
+		{name: "wrong secret", apiKey: pkgtypes.APIKey{HashedKey: hash}, secret: "wrong", expected: ErrInvalid},
+		{name: "not yet expired", apiKey: pkgtypes.APIKey{HashedKey: hash, ExpiresAt: "2026-02-01T00:00:00Z"}, secret: secret},
+		{name: "expired", apiKey: pkgtypes.APIKey{HashedKey: hash, ExpiresAt: "2025-12-01T00:00:00Z"}, secret: secret, expected: ErrExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(&tt.apiKey, tt.secret, now)
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, key := range []string{"", "k1_access_token", "k1..abc", "k1.ci.", "k2.ci.abc"} {
+		if _, _, err := Parse(key); err == nil {
+			t.Errorf("expected %q to be rejected", key)
+		}
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		req       pkgtypes.APIKeyRequest
+		expectErr bool
+	}{
+		{name: "valid", req: pkgtypes.APIKeyRequest{Name: "ci", Scopes: []string{constants.ScopeClustersRead}}},
+		{name: "with expiry", req: pkgtypes.APIKeyRequest{Name: "ci", Scopes: []string{constants.ScopeClustersRead}, ExpiresAt: "2026-06-01T00:00:00Z"}},
+		{name: "invalid name", req: pkgtypes.APIKeyRequest{Name: "CI.key", Scopes: []string{constants.ScopeClustersRead}}, expectErr: true},
+		{name: "no scopes", req: pkgtypes.APIKeyRequest{Name: "ci"}, expectErr: true},
+		{name: "unknown scope", req: pkgtypes.APIKeyRequest{Name: "ci", Scopes: []string{"clusters:admin"}}, expectErr: true},
+		{name: "expiry in the past", req: pkgtypes.APIKeyRequest{Name: "ci", Scopes: []string{constants.ScopeClustersRead}, ExpiresAt: "2025-06-01T00:00:00Z"}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRequest(&tt.req, now)
+			if tt.expectErr && err == nil {
+				t.Fatal("expected an error, got none")
+			}
+			if !tt.expectErr && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestMissingScopes(t *testing.T) {
+	held := []string{constants.ScopeAPIKeysAdmin, constants.ScopeClustersRead}
+
+	tests := []struct {
+		name      string
+		requested []string
+		expected  []string
+	}{
+		{name: "subset", requested: []string{constants.ScopeClustersRead}},
+		{name: "same scopes", requested: held},
+		{name: "escalation", requested: []string{constants.ScopeClustersRead, constants.ScopeSecretsAdmin}, expected: []string{constants.ScopeSecretsAdmin}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MissingScopes(tt.requested, held); !slices.Equal(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -17,7 +17,6 @@ import (
 
 	v1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
 	health "github.com/argoproj/gitops-engine/pkg/health"
-	"github.com/konstructio/kubefirst-api/internal/httpCommon"
 	"github.com/rs/zerolog/log"
 	"k8s.io/client-go/kubernetes"
 )
@@ -124,7 +123,7 @@ func RefreshRegistryApplication(host, token string) error {
 	request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 
 	// Submit request to ArgoCD API
-	client := httpCommon.CustomHTTPClient(false, 10*time.Second)
+	client := newHTTPClient(false, 10*time.Second)
 	response, err := client.Do(request)
 	if err != nil {
 		return fmt.Errorf("error sending request to refresh registry application: %w", err)
@@ -147,7 +146,7 @@ func RefreshApplication(host, token, appName string) error {
 	request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 
 	// Submit request to ArgoCD API
-	response, err := httpCommon.CustomHTTPClient(false).Do(request)
+	response, err := newHTTPClient(false).Do(request)
 	if err != nil {
 		return fmt.Errorf("error sending request to refresh application %s: %w", appName, err)
 	}
@@ -222,3 +221,30 @@ func waitForApplicationDeletion(clientset kubernetes.Interface, applicationName
 		}
 	}
 }
+
+// ListOutOfSyncApplications returns the names of the ArgoCD applications
+// whose live state does not match the gitops repository
+func ListOutOfSyncApplications(clientset kubernetes.Interface) ([]string, error) {
+	data, err := clientset.CoreV1().RESTClient().Get().
+		AbsPath(fmt.Sprintf("/apis/%s", ArgoCDAPIVersion)).
+		Namespace("argocd").
+		Resource("applications").
+		DoRaw(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error listing argocd applications: %w", err)
+	}
+
+	var resp v1alpha1.ApplicationList
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("error converting argocd application data to v1alpha1.ApplicationList: %w", err)
+	}
+
+	outOfSync := []string{}
+	for _, app := range resp.Items {
+		if app.Status.Sync.Status == v1alpha1.SyncStatusCodeOutOfSync {
+			outOfSync = append(outOfSync, app.Name)
+		}
+	}
+
+	return outOfSync, nil
+}
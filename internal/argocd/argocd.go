@@ -12,11 +12,13 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	v1alpha1ArgocdApplication "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
 	pkg "github.com/konstructio/kubefirst-api/internal"
 	"github.com/konstructio/kubefirst-api/internal/argocdModel"
 	"github.com/konstructio/kubefirst-api/internal/httpCommon"
+	"github.com/konstructio/kubefirst-api/internal/tracing"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -68,6 +70,14 @@ type TLSConfig struct {
 	SecretName string   `yaml:"secretName,omitempty"`
 }
 
+// newHTTPClient returns an HTTP client tracing its requests to Argo CD
+func newHTTPClient(allowInsecure bool, timeout ...time.Duration) *http.Client {
+	client := httpCommon.CustomHTTPClient(allowInsecure, timeout...)
+	client.Transport = tracing.Transport(tracing.ServiceArgoCD, client.Transport)
+
+	return client
+}
+
 // Sync request ArgoCD to manual sync an application.
 func DeleteApplication(httpClient pkg.HTTPDoer, applicationName, argoCDToken, cascade string) (int, string, error) {
 	params := url.Values{}
@@ -84,7 +94,9 @@ func DeleteApplication(httpClient pkg.HTTPDoer, applicationName, argoCDToken, ca
 	}
 	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", argoCDToken))
 
+	req, done := tracing.ClientRequest(tracing.ServiceArgoCD, req)
 	res, err := httpClient.Do(req)
+	done(res, err)
 	if err != nil {
 		log.Error().Msgf("error sending DELETE request to ArgoCD for application %q: %s", applicationName, err.Error())
 		return res.StatusCode, "", fmt.Errorf("error sending DELETE request to ArgoCD for application %q: %w", applicationName, err)
@@ -109,7 +121,7 @@ func DeleteApplication(httpClient pkg.HTTPDoer, applicationName, argoCDToken, ca
 // application data Application struct. This can be used when a resource needs to be updated, we firstly collect all
 // Application data, update what is necessary and then request the PUT function to update the resource.
 func GetArgoCDApplication(token string, applicationName string) (*argocdModel.V1alpha1Application, error) {
-	httpClient := httpCommon.CustomHTTPClient(true)
+	httpClient := newHTTPClient(true)
 
 	url := pkg.ArgoCDLocalBaseURL + "/applications/" + applicationName
 	req, err := http.NewRequest(http.MethodGet, url, nil)
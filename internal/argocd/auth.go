@@ -14,11 +14,10 @@ import (
 
 	pkg "github.com/konstructio/kubefirst-api/internal"
 	"github.com/konstructio/kubefirst-api/internal/argocdModel"
-	"github.com/konstructio/kubefirst-api/internal/httpCommon"
 )
 
 func getToken(endpoint, username, password string) (string, error) {
-	httpClient := httpCommon.CustomHTTPClient(true)
+	httpClient := newHTTPClient(true)
 	argoCDConfig := argocdModel.SessionSessionCreateRequest{
 		Username: username,
 		Password: password,
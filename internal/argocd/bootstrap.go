@@ -10,9 +10,9 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/konstructio/kubefirst-api/internal/clusterlogs"
 	"github.com/konstructio/kubefirst-api/internal/k8s"
 	kube "github.com/konstructio/kubefirst-api/internal/kubernetes"
-	"github.com/rs/zerolog/log"
 	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
@@ -21,17 +21,18 @@ import (
 )
 
 // ApplyArgoCDKustomize
-func ApplyArgoCDKustomize(clientset kubernetes.Interface, argoCDInstallPath string) error {
+func ApplyArgoCDKustomize(ctx context.Context, clientset kubernetes.Interface, argoCDInstallPath string) error {
+	logger := clusterlogs.Logger(ctx)
+
 	var (
 		enabled   = true
 		name      = "argocd-bootstrap"
 		namespace = "argocd"
-		ctx       = context.Background()
 	)
 
 	// Create Namespace
 	if err := kube.CreateNamespacesIfNotExistSimple(ctx, clientset, []string{namespace}); err != nil {
-		log.Error().Msgf("error creating namespace: %s", err)
+		logger.Error().Msgf("error creating namespace: %s", err)
 		return fmt.Errorf("error creating namespace: %w", err)
 	}
 
@@ -43,7 +44,7 @@ func ApplyArgoCDKustomize(clientset kubernetes.Interface, argoCDInstallPath stri
 	}}
 
 	if err := kube.CreateServiceAccountsIfNotExist(ctx, clientset, serviceAccounts); err != nil {
-		log.Error().Msgf("error creating service account: %s", err)
+		logger.Error().Msgf("error creating service account: %s", err)
 		return fmt.Errorf("error creating service account: %w", err)
 	}
 
@@ -57,7 +58,7 @@ func ApplyArgoCDKustomize(clientset kubernetes.Interface, argoCDInstallPath stri
 		}},
 	}
 	if err := kube.CreateClusterRolesIfNotExist(ctx, clientset, []kube.ClusterRole{clusterRole}); err != nil {
-		log.Error().Msgf("error creating cluster role: %s", err)
+		logger.Error().Msgf("error creating cluster role: %s", err)
 		return fmt.Errorf("error creating cluster role: %w", err)
 	}
 
@@ -75,7 +76,7 @@ func ApplyArgoCDKustomize(clientset kubernetes.Interface, argoCDInstallPath stri
 		},
 	}
 	if err := kube.CreateClusterRoleBindingsIfNotExist(ctx, clientset, []kube.ClusterRoleBinding{crb}); err != nil {
-		log.Error().Msgf("error creating cluster role binding: %s", err)
+		logger.Error().Msgf("error creating cluster role binding: %s", err)
 		return fmt.Errorf("error creating cluster role binding: %w", err)
 	}
 
@@ -105,32 +106,32 @@ func ApplyArgoCDKustomize(clientset kubernetes.Interface, argoCDInstallPath stri
 		},
 	}
 	if err := kube.RecreateJobs(ctx, clientset, []kube.Job{job}); err != nil {
-		log.Error().Msgf("error creating job: %s", err)
+		logger.Error().Msgf("error creating job: %s", err)
 		return fmt.Errorf("error creating job: %w", err)
 	}
 
-	log.Info().Msg("created argocd bootstrap job")
+	logger.Info().Msg("created argocd bootstrap job")
 
 	// Wait for the Job to finish
-	_, err := k8s.WaitForJobComplete(clientset, job.Name, job.Namespace, 240)
+	_, err := k8s.WaitForJobCompleteContext(ctx, clientset, job.Name, job.Namespace, 240)
 	if err != nil {
-		log.Error().Msgf("could not run argocd bootstrap job: %s", err)
+		logger.Error().Msgf("could not run argocd bootstrap job: %s", err)
 		return fmt.Errorf("could not run argocd bootstrap job: %w", err)
 	}
 
 	// Cleanup
 	if err := kube.DeleteServiceAccount(ctx, clientset, kube.ServiceAccount{Name: name, Namespace: namespace}); err != nil {
-		log.Error().Msgf("could not clean up argocd bootstrap service account %s - manual removal is required", name)
+		logger.Error().Msgf("could not clean up argocd bootstrap service account %s - manual removal is required", name)
 		return fmt.Errorf("could not clean up argocd bootstrap service account %s - manual removal is required: %w", name, err)
 	}
 
 	if err := kube.DeleteClusterRole(ctx, clientset, clusterRole.Name); err != nil {
-		log.Error().Msgf("could not clean up argocd bootstrap cluster role %s - manual removal is required", name)
+		logger.Error().Msgf("could not clean up argocd bootstrap cluster role %s - manual removal is required", name)
 		return fmt.Errorf("could not clean up argocd bootstrap cluster role %s - manual removal is required: %w", name, err)
 	}
 
 	if err := kube.DeleteClusterRoleBinding(ctx, clientset, crb.Name); err != nil {
-		log.Error().Msgf("could not clean up argocd bootstrap cluster role binding %s - manual removal is required", name)
+		logger.Error().Msgf("could not clean up argocd bootstrap cluster role binding %s - manual removal is required", name)
 		return fmt.Errorf("could not clean up argocd bootstrap cluster role binding %s - manual removal is required: %w", name, err)
 	}
 
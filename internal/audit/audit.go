@@ -0,0 +1,362 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/env"
+	"github.com/konstructio/kubefirst-api/internal/metrics"
+	"github.com/konstructio/kubefirst-api/internal/secrets"
+	"github.com/konstructio/kubefirst-api/internal/utils"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+	log "github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// segmentBytes is the encoded size of the entries written to an audit
+	// segment before a new one is started. Secret data is base64 encoded when
+	// stored, so this keeps segments below the 1 MiB size limit of a Secret.
+	segmentBytes = 512 * 1024
+
+	// queueSize is the number of entries waiting to be persisted, or to be
+	// forwarded, before new entries are dropped
+	queueSize = 1024
+
+	// segmentTimeLayout is the start time of a segment, as used in its name
+	segmentTimeLayout = "20060102-150405"
+
+	// maxSegmentName is the longest name of a Secret
+	maxSegmentName = 253
+
+	// DefaultLimit is the number of entries returned when a query sets no limit
+	DefaultLimit = 100
+	// MaxLimit is the largest number of entries a query returns
+	MaxLimit = 1000
+)
+
+var (
+	startOnce sync.Once
+	entries   chan pkgtypes.AuditEntry
+	forwards  chan pkgtypes.AuditEntry
+
+	webhookClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+// Filter selects audit entries. Empty fields match every entry.
+type Filter struct {
+	Actor   string
+	Method  string
+	Cluster string
+	Outcome string
+	Since   time.Time
+	Until   time.Time
+	Limit   int
+}
+
+// Matches reports whether entry is selected by the filter
+func (f Filter) Matches(entry pkgtypes.AuditEntry) bool {
+	if f.Actor != "" && entry.Actor != f.Actor {
+		return false
+	}
+	if f.Method != "" && !strings.EqualFold(entry.Method, f.Method) {
+		return false
+	}
+	if f.Cluster != "" && entry.Target["cluster_name"] != f.Cluster {
+		return false
+	}
+	if f.Outcome != "" && entry.Outcome != f.Outcome {
+		return false
+	}
+
+	if !f.Since.IsZero() || !f.Until.IsZero() {
+		timestamp, err := time.Parse(time.RFC3339Nano, entry.Timestamp)
+		if err != nil {
+			return false
+		}
+		if !f.Since.IsZero() && timestamp.Before(f.Since) {
+			return false
+		}
+		if !f.Until.IsZero() && timestamp.After(f.Until) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewEntry returns an audit entry for a call made now. Entry ids sort in the
+// order the entries were created.
+func NewEntry(now time.Time) pkgtypes.AuditEntry {
+	return pkgtypes.AuditEntry{
+		ID:        primitive.NewObjectID().Hex(),
+		Timestamp: now.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// Outcome returns the outcome of a call that responded with status
+func Outcome(status int) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return constants.AuditOutcomeDenied
+	case status >= http.StatusBadRequest:
+		return constants.AuditOutcomeFailure
+	default:
+		return constants.AuditOutcomeSuccess
+	}
+}
+
+// Record queues an entry to be persisted and, when K1_AUDIT_WEBHOOK_URL is
+// set, forwarded. Entries are written in order by a single writer so
+// concurrent calls never overwrite each other's entries. Record never blocks
+// the request it is called for: when the writer falls behind, the entry is
+// dropped and counted.
+func Record(entry pkgtypes.AuditEntry) {
+	startOnce.Do(func() {
+		entries = make(chan pkgtypes.AuditEntry, queueSize)
+		go write(entries)
+	})
+
+	select {
+	case entries <- entry:
+	default:
+		metrics.AuditEntryDropped("queue_full")
+		log.Error().Msgf("audit queue is full, dropped entry %s for %s by %s", entry.ID, entry.Action, entry.Actor)
+	}
+}
+
+// write persists entries to rotating segments, pruning segments older than
+// K1_AUDIT_RETENTION_DAYS whenever a new one is started
+func write(queue <-chan pkgtypes.AuditEntry) {
+	env, _ := env.GetEnv(constants.SilenceGetEnv)
+	kcfg := utils.GetKubernetesClient("")
+
+	if env.AuditWebhookURL != "" {
+		forwards = make(chan pkgtypes.AuditEntry, queueSize)
+		go forwardAll(env.AuditWebhookURL, forwards)
+	}
+
+	host := segmentHost()
+
+	var segment string
+	var segmentStart time.Time
+	size := 0
+
+	for entry := range queue {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			metrics.AuditEntryDropped("write_failed")
+			log.Error().Msgf("error encoding audit entry %s for %s by %s: %s", entry.ID, entry.Action, entry.Actor, err)
+			continue
+		}
+
+		now := time.Now().UTC()
+		if rollover(segment, segmentStart, now, size, len(data)) {
+			segmentStart = now
+			segment = segmentName(now, host)
+			size = 0
+
+			if err := prune(kcfg.Clientset, now.AddDate(0, 0, -env.AuditRetentionDays)); err != nil {
+				log.Warn().Msgf("error pruning audit segments: %s", err)
+			}
+		}
+
+		if err := secrets.InsertAuditEntry(kcfg.Clientset, segment, entry); err != nil {
+			metrics.AuditEntryDropped("write_failed")
+			log.Error().Msgf("error recording audit entry %s for %s by %s: %s", entry.ID, entry.Action, entry.Actor, err)
+
+			// the segment may have reached the size limit of a Secret, so
+			// the next entry starts a new one rather than failing as well
+			segment = ""
+		} else {
+			size += len(data)
+		}
+
+		if forwards != nil {
+			select {
+			case forwards <- entry:
+			default:
+				metrics.AuditEntryDropped("forward_queue_full")
+				log.Warn().Msgf("audit webhook queue is full, entry %s was not forwarded", entry.ID)
+			}
+		}
+	}
+}
+
+// rollover reports whether an entry of size bytes written at now starts a
+// new segment. Segments never span more than a day, and hold at most
+// segmentBytes of entries unless a single entry is larger.
+func rollover(segment string, segmentStart, now time.Time, segmentSize, size int) bool {
+	if segment == "" || now.Format("20060102") != segmentStart.Format("20060102") {
+		return true
+	}
+
+	return segmentSize > 0 && segmentSize+size > segmentBytes
+}
+
+// forwardAll posts the entries of queue to the audit webhook, apart from the
+// writer so a slow webhook never holds back persisting entries
+func forwardAll(url string, queue <-chan pkgtypes.AuditEntry) {
+	for entry := range queue {
+		if err := forward(url, entry); err != nil {
+			metrics.AuditEntryDropped("forward_failed")
+			log.Warn().Msgf("error forwarding audit entry %s: %s", entry.ID, err)
+		}
+	}
+}
+
+// prune deletes the segments started before cutoff. A segment never spans
+// more than a day, so it only holds entries older than cutoff once the day
+// after it started is before cutoff as well.
+func prune(clientSet kubernetes.Interface, cutoff time.Time) error {
+	segments, err := secrets.GetAuditSegments(clientSet)
+	if err != nil {
+		return err
+	}
+
+	for _, segment := range segments {
+		start, ok := segmentTime(segment)
+		if !ok || !start.AddDate(0, 0, 1).Before(cutoff) {
+			continue
+		}
+
+		if err := secrets.DeleteAuditSegment(clientSet, segment); err != nil {
+			return err
+		}
+		log.Info().Msgf("pruned audit segment %s", segment)
+	}
+
+	return nil
+}
+
+// forward posts an entry to the audit webhook
+func forward(url string, entry pkgtypes.AuditEntry) error {
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error marshalling json: %w", err)
+	}
+
+	res, err := webhookClient.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("error posting to audit webhook: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("audit webhook responded with %s", res.Status)
+	}
+
+	return nil
+}
+
+// Query returns the entries selected by filter, newest first
+func Query(clientSet kubernetes.Interface, filter Filter) ([]pkgtypes.AuditEntry, error) {
+	segments, err := secrets.GetAuditSegments(clientSet)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := []pkgtypes.AuditEntry{}
+	for _, segment := range segments {
+		start, ok := segmentTime(segment)
+		if ok && !filter.Until.IsZero() && start.After(filter.Until) {
+			continue
+		}
+		if ok && !filter.Since.IsZero() && start.AddDate(0, 0, 1).Before(filter.Since) {
+			continue
+		}
+
+		segmentList, err := secrets.GetAuditSegment(clientSet, segment)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range segmentList {
+			if filter.Matches(entry) {
+				selected = append(selected, entry)
+			}
+		}
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		return selected[i].ID > selected[j].ID
+	})
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if len(selected) > limit {
+		selected = selected[:limit]
+	}
+
+	return selected, nil
+}
+
+// segmentTime returns the start time of a segment from its name
+func segmentTime(segment string) (time.Time, bool) {
+	name := strings.TrimPrefix(segment, secrets.AuditSegmentPrefix+"-")
+	if len(name) > len(segmentTimeLayout) {
+		name = name[:len(segmentTimeLayout)]
+	}
+
+	start, err := time.Parse(segmentTimeLayout, name)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return start, true
+}
+
+// segmentName returns the name of a segment started at now by the replica
+// running on host. Each replica writes its own segments, so replicas never
+// write to the same segment.
+func segmentName(now time.Time, host string) string {
+	name := fmt.Sprintf("%s-%s-%s", secrets.AuditSegmentPrefix, now.Format(segmentTimeLayout), host)
+	if len(name) > maxSegmentName {
+		name = name[:maxSegmentName]
+	}
+
+	return strings.TrimRight(name, "-.")
+}
+
+// segmentHost returns the hostname of the replica as it is used in the names
+// of its segments, keeping only the characters allowed in a Secret name
+func segmentHost() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Warn().Msgf("error getting hostname for audit segments: %s", err)
+	}
+
+	host := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		default:
+			return '-'
+		}
+	}, hostname)
+	host = strings.Trim(host, "-.")
+	if host == "" {
+		return "unknown"
+	}
+
+	return host
+}
@@ -0,0 +1,161 @@
+package audit
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+)
+
+func TestRedactRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		redactAll bool
+		expected  string
+	}{
+		{name: "empty", body: "", expected: "null"},
+		{name: "not json", body: "plain text", expected: `"\u003c10 bytes\u003e"`},
+		{
+			name:     "nested credentials",
+			body:     `{"cluster_name":"dev","git_auth":{"token":"abc"},"aws_auth":{"secret_access_key":"abc"},"node_count":3}`,
+			expected: `{"aws_auth":"[REDACTED]","cluster_name":"dev","git_auth":"[REDACTED]","node_count":3}`,
+		},
+		{
+			name:     "service secret keys",
+			body:     `{"user":"kbot","secret_keys":[{"name":"password","value":"abc"}]}`,
+			expected: `{"secret_keys":"[REDACTED]","user":"kbot"}`,
+		},
+		{
+			name:      "redact all",
+			body:      `{"username":"admin","values":["a","b"]}`,
+			redactAll: true,
+			expected:  `{"username":"[REDACTED]","values":["[REDACTED]","[REDACTED]"]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(RedactRequest([]byte(tt.body), tt.redactAll))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFilterMatches(t *testing.T) {
+	entry := pkgtypes.AuditEntry{
+		Timestamp: "2026-10-17T10:00:00.5Z",
+		Actor:     "ci",
+		Method:    "DELETE",
+		Target:    map[string]string{"cluster_name": "dev"},
+		Outcome:   constants.AuditOutcomeSuccess,
+	}
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected bool
+	}{
+		{name: "no filter", filter: Filter{}, expected: true},
+		{name: "actor", filter: Filter{Actor: "ci"}, expected: true},
+		{name: "other actor", filter: Filter{Actor: "jane"}, expected: false},
+		{name: "method is case insensitive", filter: Filter{Method: "delete"}, expected: true},
+		{name: "other cluster", filter: Filter{Cluster: "prod"}, expected: false},
+		{name: "other outcome", filter: Filter{Outcome: constants.AuditOutcomeDenied}, expected: false},
+		{name: "within range", filter: Filter{Since: time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC), Until: time.Date(2026, 10, 17, 11, 0, 0, 0, time.UTC)}, expected: true},
+		{name: "before since", filter: Filter{Since: time.Date(2026, 10, 17, 11, 0, 0, 0, time.UTC)}, expected: false},
+		{name: "after until", filter: Filter{Until: time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Matches(entry); got != tt.expected {
+				t.Errorf("expected %t, got %t", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRollover(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		segment     string
+		now         time.Time
+		segmentSize int
+		size        int
+		expected    bool
+	}{
+		{name: "no segment", now: start, size: 100, expected: true},
+		{name: "room left", segment: "kubefirst-audit-1", now: start, segmentSize: 1024, size: 100, expected: false},
+		{name: "full", segment: "kubefirst-audit-1", now: start, segmentSize: segmentBytes - 50, size: 100, expected: true},
+		{name: "entry larger than a segment", segment: "kubefirst-audit-1", now: start, size: segmentBytes + 1, expected: false},
+		{name: "next day", segment: "kubefirst-audit-1", now: start.Add(14 * time.Hour), segmentSize: 1024, size: 100, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rollover(tt.segment, start, tt.now, tt.segmentSize, tt.size); got != tt.expected {
+				t.Errorf("expected rollover %t, got %t", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSegmentName(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		host     string
+		expected string
+	}{
+		{name: "host", host: "kubefirst-api-7c9d5-x2x4q", expected: "kubefirst-audit-20240501-100000-kubefirst-api-7c9d5-x2x4q"},
+		{name: "long host", host: strings.Repeat("a", 300), expected: "kubefirst-audit-20240501-100000-" + strings.Repeat("a", 253-len("kubefirst-audit-20240501-100000-"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := segmentName(start, tt.host)
+			if got != tt.expected {
+				t.Errorf("expected segment %q, got %q", tt.expected, got)
+			}
+
+			segmentStart, ok := segmentTime(got)
+			if !ok || !segmentStart.Equal(start) {
+				t.Errorf("expected segment %q to start at %s, got %s", got, start, segmentStart)
+			}
+		})
+	}
+}
+
+func TestSegmentTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		segment  string
+		expected time.Time
+		ok       bool
+	}{
+		{name: "segment of a replica", segment: "kubefirst-audit-20240501-100000-kubefirst-api-0", expected: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), ok: true},
+		{name: "segment without a host", segment: "kubefirst-audit-20240501-100000", expected: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), ok: true},
+		{name: "not a segment", segment: "kubefirst-audit-export"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := segmentTime(tt.segment)
+			if ok != tt.ok || !got.Equal(tt.expected) {
+				t.Errorf("expected %s, %t, got %s, %t", tt.expected, tt.ok, got, ok)
+			}
+		})
+	}
+}
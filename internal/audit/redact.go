@@ -0,0 +1,91 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// Redacted replaces secret values in recorded requests
+	Redacted = "[REDACTED]"
+
+	// MaxRequestSize is the largest request body recorded in an entry
+	MaxRequestSize = 64 * 1024
+)
+
+// sensitiveKeys are the parts of JSON keys whose values are never recorded
+var sensitiveKeys = []string{
+	"auth",
+	"certificate",
+	"credential",
+	"kubeconfig",
+	"passwd",
+	"password",
+	"private",
+	"secret",
+	"token",
+	"access_key",
+	"api_key",
+	"apikey",
+}
+
+// RedactRequest returns a summary of a request body that is safe to record.
+// JSON values under sensitive keys are redacted, or every value when
+// redactAll is set, and other bodies are only recorded by size.
+func RedactRequest(body []byte, redactAll bool) interface{} {
+	if len(body) == 0 {
+		return nil
+	}
+	if len(body) > MaxRequestSize {
+		return fmt.Sprintf("<%d bytes>", len(body))
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Sprintf("<%d bytes>", len(body))
+	}
+
+	return redact(value, redactAll)
+}
+
+func redact(value interface{}, redactAll bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, nested := range v {
+			if isSensitive(key) {
+				v[key] = Redacted
+				continue
+			}
+			v[key] = redact(nested, redactAll)
+		}
+		return v
+	case []interface{}:
+		for i, nested := range v {
+			v[i] = redact(nested, redactAll)
+		}
+		return v
+	default:
+		if redactAll {
+			return Redacted
+		}
+		return v
+	}
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -16,6 +16,7 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
@@ -23,6 +24,7 @@ import (
 	route53Types "github.com/aws/aws-sdk-go-v2/service/route53/types"
 	"github.com/konstructio/kubefirst-api/internal/constants"
 	"github.com/konstructio/kubefirst-api/internal/env"
+	"github.com/konstructio/kubefirst-api/internal/metrics"
 	"github.com/konstructio/kubefirst-api/internal/utils"
 	log "github.com/rs/zerolog/log"
 )
@@ -59,6 +61,7 @@ func New() (*Configuration, error) {
 		context.Background(),
 		config.WithRegion(env.AWSRegion),
 		config.WithSharedConfigProfile(env.AWSProfile),
+		WithRequestMetrics(),
 	)
 	if err != nil {
 		log.Error().Msgf("Could not create AWS config: %s", err.Error())
@@ -68,6 +71,12 @@ func New() (*Configuration, error) {
 	return &Configuration{Config: awsClient}, nil
 }
 
+// WithRequestMetrics records the latency of the calls made with an AWS
+// configuration
+func WithRequestMetrics() config.LoadOptionsFunc {
+	return config.WithHTTPClient(metrics.Client(metrics.ServiceAWS, awshttp.NewBuildableClient()))
+}
+
 // Route53AlterResourceRecord simplifies manipulation of Route53 records
 func (conf *Configuration) Route53AlterResourceRecord(r *Route53AlterResourceRecord) (*route53.ChangeResourceRecordSetsOutput, error) {
 	route53Client := route53.NewFromConfig(conf.Config)
@@ -282,6 +291,7 @@ func NewAwsV2(region string) (aws.Config, error) {
 		context.Background(),
 		config.WithRegion(region),
 		config.WithSharedConfigProfile(profile),
+		WithRequestMetrics(),
 	)
 	if err != nil {
 		return aws.Config{}, fmt.Errorf("unable to create aws client for region %q: %w", region, err)
@@ -299,6 +309,7 @@ func NewAwsV3(region, accessKeyID, secretAccessKey, sessionToken string) (aws.Co
 			secretAccessKey,
 			sessionToken,
 		)),
+		WithRequestMetrics(),
 	)
 	if err != nil {
 		return aws.Config{}, fmt.Errorf("unable to create aws client for region %q with provided credentials: %w", region, err)
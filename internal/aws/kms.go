@@ -8,6 +8,7 @@ package aws
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/rs/zerolog/log"
@@ -20,6 +21,7 @@ func (conf *Configuration) GetKmsKeyID(keyAlias string) (string, error) {
 	kmsKeys, err := kmsClient.ListAliases(context.Background(), &kms.ListAliasesInput{})
 	if err != nil {
 		log.Info().Msgf("error: could not list kms key aliases %s", err)
+		return "", fmt.Errorf("could not list kms key aliases: %w", err)
 	}
 
 	for _, k := range kmsKeys.Aliases {
@@ -18,6 +18,7 @@ import (
 	"github.com/civo/civogo"
 	"github.com/konstructio/kubefirst-api/internal/dns"
 	"github.com/konstructio/kubefirst-api/internal/httpCommon"
+	"github.com/konstructio/kubefirst-api/internal/metrics"
 	"github.com/rs/zerolog/log"
 )
 
@@ -39,7 +40,9 @@ func (c *Configuration) TestDomainLiveness(domainName, domainID string) bool {
 	log.Info().Msgf("domainName %s", domainName)
 
 	// check for existing records
+	start := time.Now()
 	records, err := c.Client.ListDNSRecords(domainID)
+	metrics.ObserveExternalCall(c.Context, metrics.ServiceCivo, start, err)
 	if err != nil {
 		log.Warn().Msgf("%s", err)
 		return false
@@ -50,7 +53,9 @@ func (c *Configuration) TestDomainLiveness(domainName, domainID string) bool {
 	}
 
 	// create record if it does not exist
+	start = time.Now()
 	_, err = c.Client.CreateDNSRecord(domainID, civoRecordConfig)
+	metrics.ObserveExternalCall(c.Context, metrics.ServiceCivo, start, err)
 	if err != nil {
 		log.Warn().Msgf("%s", err)
 		return false
@@ -114,7 +119,9 @@ func GetDomainApexContent(domainName string) bool {
 func (c *Configuration) GetDNSInfo(domainName string) (string, error) {
 	log.Info().Msg("GetDNSInfo (working...)")
 
+	start := time.Now()
 	civoDNSDomain, err := c.Client.FindDNSDomain(domainName)
+	metrics.ObserveExternalCall(c.Context, metrics.ServiceCivo, start, err)
 	if err != nil {
 		log.Error().Msg(err.Error())
 		return "", fmt.Errorf("error getting Civo DNS domain %q: %w", domainName, err)
@@ -125,7 +132,9 @@ func (c *Configuration) GetDNSInfo(domainName string) (string, error) {
 
 // GetDNSDomains lists all available DNS domains
 func (c *Configuration) GetDNSDomains() ([]string, error) {
+	start := time.Now()
 	domains, err := c.Client.ListDNSDomains()
+	metrics.ObserveExternalCall(c.Context, metrics.ServiceCivo, start, err)
 	if err != nil {
 		return nil, fmt.Errorf("error listing DNS domains: %w", err)
 	}
@@ -140,7 +149,9 @@ func (c *Configuration) GetDNSDomains() ([]string, error) {
 
 // GetRegions lists all available regions
 func (c *Configuration) GetRegions() ([]string, error) {
+	start := time.Now()
 	regions, err := c.Client.ListRegions()
+	metrics.ObserveExternalCall(c.Context, metrics.ServiceCivo, start, err)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching regions: %w", err)
 	}
@@ -154,7 +165,9 @@ func (c *Configuration) GetRegions() ([]string, error) {
 }
 
 func (c *Configuration) ListInstanceSizes() ([]string, error) {
+	start := time.Now()
 	resp, err := c.Client.SendGetRequest("/v2/sizes")
+	metrics.ObserveExternalCall(c.Context, metrics.ServiceCivo, start, err)
 	if err != nil {
 		return nil, fmt.Errorf("error sending request to list instance sizes: %w", err)
 	}
@@ -175,7 +188,9 @@ func (c *Configuration) ListInstanceSizes() ([]string, error) {
 }
 
 func (c *Configuration) GetKubeconfig(clusterName string) (string, error) {
+	start := time.Now()
 	cluster, err := c.Client.FindKubernetesCluster(clusterName)
+	metrics.ObserveExternalCall(c.Context, metrics.ServiceCivo, start, err)
 	if err != nil {
 		return "", fmt.Errorf("error finding Kubernetes cluster %q: %w", clusterName, err)
 	}
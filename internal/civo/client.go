@@ -10,6 +10,8 @@ import (
 	"github.com/civo/civogo"
 )
 
+// NewCivo returns a Civo API client. civogo does not accept an HTTP client,
+// so the latency of Civo API calls is recorded where they are made.
 func NewCivo(civoToken string, region string) *civogo.Client {
 	civoClient, _ := civogo.NewClient(civoToken, region)
 
@@ -12,17 +12,20 @@ import (
 	"time"
 
 	"github.com/civo/civogo"
+	"github.com/konstructio/kubefirst-api/internal/metrics"
 	"github.com/rs/zerolog/log"
 )
 
 // CreateStorageBucket creates an object storage bucket
 func (c *Configuration) CreateStorageBucket(accessKeyID string, bucketName string, region string) (*civogo.ObjectStore, error) {
+	start := time.Now()
 	bucket, err := c.Client.NewObjectStore(&civogo.CreateObjectStoreRequest{
 		Name:        bucketName,
 		Region:      region,
 		AccessKeyID: accessKeyID,
 		MaxSizeGB:   500,
 	})
+	metrics.ObserveExternalCall(c.Context, metrics.ServiceCivo, start, err)
 	if err != nil {
 		return nil, fmt.Errorf("error creating object store %s: %w", bucketName, err)
 	}
@@ -32,7 +35,9 @@ func (c *Configuration) CreateStorageBucket(accessKeyID string, bucketName strin
 
 // DeleteStorageBucket deletes an object storage bucket
 func (c *Configuration) DeleteStorageBucket(bucketName string) error {
+	start := time.Now()
 	objsts, err := c.Client.ListObjectStores()
+	metrics.ObserveExternalCall(c.Context, metrics.ServiceCivo, start, err)
 	if err != nil {
 		return fmt.Errorf("error fetching object stores: %w", err)
 	}
@@ -49,7 +54,9 @@ func (c *Configuration) DeleteStorageBucket(bucketName string) error {
 		return fmt.Errorf("bucket %s not found", bucketName)
 	}
 
+	start = time.Now()
 	_, err = c.Client.DeleteObjectStore(bucketID)
+	metrics.ObserveExternalCall(c.Context, metrics.ServiceCivo, start, err)
 	if err != nil {
 		return fmt.Errorf("error deleting object store %s: %w", bucketName, err)
 	}
@@ -112,7 +119,9 @@ func (c *Configuration) DeleteAccessCredentials(credentialName string) error {
 		return nil
 	}
 
+	start := time.Now()
 	_, err = c.Client.DeleteObjectStoreCredential(creds.ID)
+	metrics.ObserveExternalCall(c.Context, metrics.ServiceCivo, start, err)
 	if err != nil {
 		return fmt.Errorf("error deleting object store credentials: %w", err)
 	}
@@ -125,7 +134,9 @@ var errNoCredsFound = errors.New("no object store credentials found")
 // checkKubefirstCredentials determines whether or not object store credentials exist
 func (c *Configuration) checkKubefirstCredentials(credentialName string) (*civogo.ObjectStoreCredential, error) {
 	log.Info().Msgf("looking for credential: %s", credentialName)
+	start := time.Now()
 	remoteCredentials, err := c.Client.ListObjectStoreCredentials()
+	metrics.ObserveExternalCall(c.Context, metrics.ServiceCivo, start, err)
 	if err != nil {
 		log.Error().Msg(err.Error())
 		return nil, fmt.Errorf("error fetching object store credentials: %w", err)
@@ -143,10 +154,12 @@ func (c *Configuration) checkKubefirstCredentials(credentialName string) (*civog
 
 // createAccessCredentials creates access credentials for an object store
 func (c *Configuration) createAccessCredentials(credentialName string, region string) (*civogo.ObjectStoreCredential, error) {
+	start := time.Now()
 	creds, err := c.Client.NewObjectStoreCredential(&civogo.CreateObjectStoreCredentialRequest{
 		Name:   credentialName,
 		Region: region,
 	})
+	metrics.ObserveExternalCall(c.Context, metrics.ServiceCivo, start, err)
 	if err != nil {
 		log.Error().Msgf("error creating object store credentials: %s", err.Error())
 		return nil, fmt.Errorf("error creating object store credentials: %w", err)
@@ -156,7 +169,9 @@ func (c *Configuration) createAccessCredentials(credentialName string, region st
 
 // getAccessCredentials retrieves an object store's access credentials
 func (c *Configuration) getAccessCredentials(id string) (*civogo.ObjectStoreCredential, error) {
+	start := time.Now()
 	creds, err := c.Client.GetObjectStoreCredential(id)
+	metrics.ObserveExternalCall(c.Context, metrics.ServiceCivo, start, err)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching object store credentials: %w", err)
 	}
@@ -0,0 +1,234 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package clusterlogs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/env"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+	"github.com/rs/zerolog"
+	log "github.com/rs/zerolog/log"
+)
+
+const (
+	// extension is the extension of the log file of a run
+	extension = ".log"
+
+	// DefaultLimit is the number of entries returned when a search sets no limit
+	DefaultLimit = 500
+	// MaxLimit is the largest number of entries a search returns
+	MaxLimit = 5000
+)
+
+var (
+	// validName keeps cluster names and run IDs to a single path element
+	validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,127}$`)
+
+	// ErrInvalidName is returned for cluster names or run IDs that cannot
+	// name a log file
+	ErrInvalidName = errors.New("invalid name")
+
+	// ErrRunNotFound is returned when a cluster has no logs for a run
+	ErrRunNotFound = errors.New("run not found")
+)
+
+type runKey struct{}
+
+// Run returns the ID of the run carried by ctx, if any
+func Run(ctx context.Context) (string, bool) {
+	runID, ok := ctx.Value(runKey{}).(string)
+	return runID, ok && runID != ""
+}
+
+// Logger returns the logger of the run carried by ctx, or the logger of the
+// API when ctx carries no run
+func Logger(ctx context.Context) *zerolog.Logger {
+	if ctx != nil {
+		if _, ok := Run(ctx); ok {
+			return zerolog.Ctx(ctx)
+		}
+	}
+
+	return &log.Logger
+}
+
+// ValidName reports whether name can be used as a cluster name or run ID
+// without leaving the log directory
+func ValidName(name string) bool {
+	return validName.MatchString(name) && !strings.Contains(name, "..")
+}
+
+// Dir returns the directory holding the log files of every cluster
+func Dir() (string, error) {
+	homePath, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error getting current user's home directory: %w", err)
+	}
+
+	return filepath.Join(homePath, ".k1", "logs"), nil
+}
+
+// Path returns the log file of a run of a cluster
+func Path(clusterName, runID string) (string, error) {
+	if !ValidName(clusterName) {
+		return "", fmt.Errorf("%w: cluster %q", ErrInvalidName, clusterName)
+	}
+	if !ValidName(runID) {
+		return "", fmt.Errorf("%w: run %q", ErrInvalidName, runID)
+	}
+
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, clusterName, runID+extension), nil
+}
+
+// Start opens the log file of run runID of a cluster, removing the runs past
+// retention, and returns ctx carrying the run and a logger writing to both
+// the file and standard error. Runs log independently of each other, so
+// concurrent runs never write to each other's files. The returned function
+// closes the file once the run is over.
+func Start(ctx context.Context, clusterName, runID string) (context.Context, func(), error) {
+	file, err := open(clusterName, runID)
+	if err != nil {
+		return ctx, func() {}, err
+	}
+
+	e, _ := env.GetEnv(constants.SilenceGetEnv)
+	if err := prune(clusterName, runID, e.LogRetentionRuns, time.Duration(e.LogRetentionDays)*24*time.Hour); err != nil {
+		log.Warn().Msgf("error removing old logs of cluster %s: %s", clusterName, err)
+	}
+
+	logger := zerolog.New(zerolog.MultiLevelWriter(file, os.Stderr)).With().
+		Timestamp().
+		Str("cluster", clusterName).
+		Str("run", runID).
+		Logger()
+
+	ctx = logger.WithContext(context.WithValue(ctx, runKey{}, runID))
+
+	return ctx, func() { file.Close() }, nil
+}
+
+// open creates the log file of a run of a cluster
+func open(clusterName, runID string) (*os.File, error) {
+	path, err := Path(clusterName, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("error creating log directory for cluster %q: %w", clusterName, err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening log file for run %q of cluster %q: %w", runID, clusterName, err)
+	}
+
+	return file, nil
+}
+
+// Runs returns the runs of a cluster, newest first
+func Runs(clusterName string) ([]pkgtypes.LogRun, error) {
+	if !ValidName(clusterName) {
+		return nil, fmt.Errorf("%w: cluster %q", ErrInvalidName, clusterName)
+	}
+
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, clusterName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []pkgtypes.LogRun{}, nil
+		}
+		return nil, fmt.Errorf("error listing logs of cluster %q: %w", clusterName, err)
+	}
+
+	runs := make([]pkgtypes.LogRun, 0, len(entries))
+	for _, entry := range entries {
+		runID, ok := strings.CutSuffix(entry.Name(), extension)
+		if !ok || entry.IsDir() || !ValidName(runID) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		runs = append(runs, pkgtypes.LogRun{ID: runID, UpdatedAt: info.ModTime().UTC(), Size: info.Size()})
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].UpdatedAt.After(runs[j].UpdatedAt)
+	})
+
+	return runs, nil
+}
+
+// Latest returns the most recently written run of a cluster
+func Latest(clusterName string) (string, error) {
+	runs, err := Runs(clusterName)
+	if err != nil {
+		return "", err
+	}
+
+	if len(runs) == 0 {
+		return "", fmt.Errorf("%w: cluster %q has no logs", ErrRunNotFound, clusterName)
+	}
+
+	return runs[0].ID, nil
+}
+
+// prune removes the runs of a cluster beyond the newest keep runs and those
+// last written before maxAge, except for the active run
+func prune(clusterName, active string, keep int, maxAge time.Duration) error {
+	runs, err := Runs(clusterName)
+	if err != nil {
+		return err
+	}
+
+	kept := 0
+	for _, run := range runs {
+		if run.ID == active {
+			kept++
+			continue
+		}
+
+		expired := maxAge > 0 && time.Since(run.UpdatedAt) > maxAge
+		if !expired && (keep <= 0 || kept < keep) {
+			kept++
+			continue
+		}
+
+		path, err := Path(clusterName, run.ID)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error removing logs of run %q: %w", run.ID, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,210 @@
+package clusterlogs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{name: "my-cluster", valid: true},
+		{name: "6540f1a2b3c4d5e6f7a8b9c0", valid: true},
+		{name: "cluster.v2", valid: true},
+		{name: "", valid: false},
+		{name: ".", valid: false},
+		{name: "..", valid: false},
+		{name: "a..b", valid: false},
+		{name: "../secrets", valid: false},
+		{name: "cluster/run", valid: false},
+		{name: `cluster\run`, valid: false},
+		{name: ".hidden", valid: false},
+		{name: strings.Repeat("a", 129), valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidName(tt.name); got != tt.valid {
+				t.Errorf("ValidName(%q) = %t, want %t", tt.name, got, tt.valid)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	writeRun(t, "search", "run", []string{
+		`{"level":"debug","time":"2024-01-01T10:00:00Z","message":"cloning gitops template"}`,
+		`{"level":"info","time":"2024-01-01T10:05:00Z","message":"applying terraform"}`,
+		`{"level":"error","time":"2024-01-01T10:10:00Z","message":"terraform apply failed"}`,
+		`plain output from a command`,
+	})
+
+	tests := []struct {
+		name      string
+		filter    Filter
+		messages  []string
+		truncated bool
+	}{
+		{
+			name:     "no filter",
+			messages: []string{"cloning gitops template", "applying terraform", "terraform apply failed", "plain output from a command"},
+		},
+		{
+			name:     "level",
+			filter:   Filter{Level: "info"},
+			messages: []string{"applying terraform", "terraform apply failed"},
+		},
+		{
+			name:     "since",
+			filter:   Filter{Since: time.Date(2024, 1, 1, 10, 5, 0, 0, time.UTC)},
+			messages: []string{"applying terraform", "terraform apply failed"},
+		},
+		{
+			name:     "query ignores case",
+			filter:   Filter{Query: "TERRAFORM"},
+			messages: []string{"applying terraform", "terraform apply failed"},
+		},
+		{
+			name:      "limit keeps the last entries",
+			filter:    Filter{Limit: 1},
+			messages:  []string{"plain output from a command"},
+			truncated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, truncated, err := Search("search", "run", tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			messages := make([]string, 0, len(entries))
+			for _, entry := range entries {
+				messages = append(messages, entry.Message)
+			}
+			if strings.Join(messages, "|") != strings.Join(tt.messages, "|") {
+				t.Errorf("expected messages %q, got %q", tt.messages, messages)
+			}
+			if truncated != tt.truncated {
+				t.Errorf("expected truncated %t, got %t", tt.truncated, truncated)
+			}
+		})
+	}
+
+	if _, _, err := Search("search", "missing", Filter{}); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("expected ErrRunNotFound for a missing run, got %v", err)
+	}
+	if _, _, err := Search("search", "../run", Filter{}); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("expected ErrInvalidName for a path, got %v", err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	now := time.Now()
+	for i, runID := range []string{"active", "new", "old", "expired"} {
+		path := writeRun(t, "prune", runID, nil)
+		modTime := now.Add(-time.Duration(i) * time.Hour)
+		if runID == "expired" {
+			modTime = now.Add(-48 * time.Hour)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := prune("prune", "active", 2, 24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := Runs("prune")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make([]string, 0, len(runs))
+	for _, run := range runs {
+		got = append(got, run.ID)
+	}
+	if strings.Join(got, ",") != "active,new" {
+		t.Errorf("expected runs active,new to be kept, got %v", got)
+	}
+}
+
+func TestStart(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	if _, ok := Run(context.Background()); ok {
+		t.Fatal("expected no run without Start")
+	}
+
+	// concurrent runs of different clusters each log to their own file
+	first, closeFirst, err := Start(context.Background(), "first", "run-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, closeSecond, err := Start(context.Background(), "second", "run-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Logger(first).Info().Msg("from the first run")
+	Logger(second).Info().Msg("from the second run")
+	closeFirst()
+	closeSecond()
+
+	for _, tt := range []struct {
+		cluster  string
+		run      string
+		expected string
+		other    string
+	}{
+		{cluster: "first", run: "run-1", expected: "from the first run", other: "from the second run"},
+		{cluster: "second", run: "run-2", expected: "from the second run", other: "from the first run"},
+	} {
+		path, err := Path(tt.cluster, tt.run)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), tt.expected) || strings.Contains(string(data), tt.other) {
+			t.Errorf("expected the logs of %s to hold only %q, got %s", tt.cluster, tt.expected, data)
+		}
+	}
+}
+
+func writeRun(t *testing.T, clusterName, runID string, lines []string) string {
+	t.Helper()
+
+	path, err := Path(clusterName, runID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	content := strings.Join(lines, "\n")
+	if len(lines) > 0 {
+		content += "\n"
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
@@ -0,0 +1,202 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package clusterlogs
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+	"github.com/rs/zerolog"
+)
+
+// maxLineSize is the longest log line that can be searched
+const maxLineSize = 1024 * 1024
+
+// Filter selects the entries returned by Search. Fields left empty match
+// every entry.
+type Filter struct {
+	// Since drops entries written before it, along with lines without a time
+	Since time.Time
+	// Level drops entries below it, along with lines without a level
+	Level string
+	// Query keeps lines containing it, ignoring case
+	Query string
+	// Limit keeps the last Limit matching entries, DefaultLimit when unset
+	Limit int
+}
+
+// Search returns the entries of a run of a cluster matching filter, and
+// whether entries were left out because of its limit
+func Search(clusterName, runID string, filter Filter) ([]pkgtypes.LogEntry, bool, error) {
+	file, err := openRun(clusterName, runID)
+	if err != nil {
+		return nil, false, err
+	}
+	defer file.Close()
+
+	var minLevel zerolog.Level
+	if filter.Level != "" {
+		minLevel, err = zerolog.ParseLevel(filter.Level)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid level %q: %w", filter.Level, err)
+		}
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	query := strings.ToLower(filter.Query)
+	entries := []pkgtypes.LogEntry{}
+	truncated := false
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if query != "" && !strings.Contains(strings.ToLower(line), query) {
+			continue
+		}
+
+		entry := parseLine(line)
+		if !filter.Since.IsZero() {
+			written, err := time.Parse(time.RFC3339, entry.Time)
+			if err != nil || written.Before(filter.Since) {
+				continue
+			}
+		}
+		if filter.Level != "" {
+			level, err := zerolog.ParseLevel(entry.Level)
+			if err != nil || entry.Level == "" || level < minLevel {
+				continue
+			}
+		}
+
+		entries = append(entries, entry)
+		if len(entries) > limit {
+			entries = entries[1:]
+			truncated = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, false, fmt.Errorf("error reading logs of run %q: %w", runID, err)
+	}
+
+	return entries, truncated, nil
+}
+
+// parseLine reads the time, level and message of a line written by zerolog.
+// Other lines are returned as their message.
+func parseLine(line string) pkgtypes.LogEntry {
+	var fields struct {
+		Time    string `json:"time"`
+		Level   string `json:"level"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return pkgtypes.LogEntry{Message: line, Line: line}
+	}
+
+	return pkgtypes.LogEntry{Time: fields.Time, Level: fields.Level, Message: fields.Message, Line: line}
+}
+
+// WriteBundle writes a gzipped tar archive of the given runs of a cluster to
+// w, or of every run when runIDs is empty
+func WriteBundle(w io.Writer, clusterName string, runIDs []string) error {
+	if len(runIDs) == 0 {
+		runs, err := Runs(clusterName)
+		if err != nil {
+			return err
+		}
+		if len(runs) == 0 {
+			return fmt.Errorf("%w: cluster %q has no logs", ErrRunNotFound, clusterName)
+		}
+
+		for _, run := range runs {
+			runIDs = append(runIDs, run.ID)
+		}
+	}
+
+	// Open every run first so that a missing run is reported before the
+	// archive is started
+	files := make([]*os.File, 0, len(runIDs))
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	for _, runID := range runIDs {
+		file, err := openRun(clusterName, runID)
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	for i, file := range files {
+		info, err := file.Stat()
+		if err != nil {
+			return fmt.Errorf("error reading logs of run %q: %w", runIDs[i], err)
+		}
+
+		header := &tar.Header{
+			Name:    path.Join(clusterName, runIDs[i]+extension),
+			Mode:    0o600,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("error writing logs of run %q: %w", runIDs[i], err)
+		}
+
+		// The run may still be written to, so no more than the size in the
+		// header is copied
+		if _, err := io.CopyN(tw, file, info.Size()); err != nil {
+			return fmt.Errorf("error writing logs of run %q: %w", runIDs[i], err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("error closing log bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("error closing log bundle: %w", err)
+	}
+
+	return nil
+}
+
+func openRun(clusterName, runID string) (*os.File, error) {
+	name, err := Path(clusterName, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: cluster %q has no logs for run %q", ErrRunNotFound, clusterName, runID)
+		}
+		return nil, fmt.Errorf("error opening logs of run %q: %w", runID, err)
+	}
+
+	return file, nil
+}
@@ -14,11 +14,79 @@ const (
 	KubefirstAuthSecretName = "kubefirst-secret"
 
 	// Cluster statuses
+	ClusterStatusCancelled    = "cancelled"
 	ClusterStatusDeleted      = "deleted"
 	ClusterStatusDeleting     = "deleting"
 	ClusterStatusError        = "error"
 	ClusterStatusProvisioned  = "provisioned"
 	ClusterStatusProvisioning = "provisioning"
+	ClusterStatusUpdating     = "updating"
+
+	// Cluster create step statuses
+	StepStatusPending   = "pending"
+	StepStatusRunning   = "running"
+	StepStatusSucceeded = "succeeded"
+	StepStatusFailed    = "failed"
+	StepStatusCancelled = "cancelled"
+
+	// Job types
+	JobTypeClusterCreate  = "cluster_create"
+	JobTypeClusterDelete  = "cluster_delete"
+	JobTypeClusterNodes   = "cluster_nodes"
+	JobTypeClusterUpgrade = "cluster_upgrade"
+	JobTypeServiceCreate  = "service_create"
+	JobTypeServiceDelete  = "service_delete"
+
+	// Job statuses
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+
+	// Dry run and validation check statuses
+	CheckStatusPass = "pass"
+	CheckStatusWarn = "warn"
+	CheckStatusFail = "fail"
+	CheckStatusSkip = "skip"
+
+	// Node update apply modes
+	NodesApplyModeTerraform = "terraform"
+	NodesApplyModeAtlantis  = "atlantis"
+
+	// Drift detection statuses
+	DriftStatusInSync  = "in_sync"
+	DriftStatusDrifted = "drifted"
+	DriftStatusError   = "error"
+	DriftStatusSkipped = "skipped"
+
+	// Audit entry outcomes
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+	AuditOutcomeDenied  = "denied"
+
+	// Notification delivery outcomes
+	NotificationOutcomeSuccess = "success"
+	NotificationOutcomeFailure = "failure"
+
+	// API key scopes
+	ScopeClustersRead  = "clusters:read"
+	ScopeClustersWrite = "clusters:write"
+	ScopeServicesRead  = "services:read"
+	ScopeServicesWrite = "services:write"
+	ScopeSecretsAdmin  = "secrets:admin"
+	ScopeAPIKeysAdmin  = "apikeys:admin"
+	// Managing role bindings lets a caller grant itself any role, so
+	// callers with this scope are not restricted by role bindings
+	ScopeRoleBindingsAdmin  = "rolebindings:admin"
+	ScopeAuditRead          = "audit:read"
+	ScopeNotificationsAdmin = "notifications:admin"
+
+	// Roles granted to OIDC users through their groups and to subjects of
+	// role bindings
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
 
 	SilenceGetEnv = true
 )
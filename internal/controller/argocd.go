@@ -17,14 +17,18 @@ import (
 	"github.com/konstructio/kubefirst-api/internal/argocd"
 	"github.com/konstructio/kubefirst-api/internal/k8s"
 	"github.com/konstructio/kubefirst-api/internal/secrets"
+	"github.com/konstructio/kubefirst-api/pkg/types"
 	"github.com/kubefirst/metrics-client/pkg/telemetry"
-	log "github.com/rs/zerolog/log"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
 // InstallArgoCD
 func (clctrl *ClusterController) InstallArgoCD() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
 	cl, err := secrets.GetCluster(clctrl.KubernetesClient, clctrl.ClusterName)
 	if err != nil {
 		return fmt.Errorf("failed to get cluster: %w", err)
@@ -50,10 +54,10 @@ func (clctrl *ClusterController) InstallArgoCD() error {
 
 		argoCDInstallPath := fmt.Sprintf("github.com:konstructio/manifests/argocd/cloud?ref=%s", pkg.KubefirstManifestRepoRef)
 
-		log.Info().Msg("installing argocd")
+		clctrl.logger().Info().Msg("installing argocd")
 
 		telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.ArgoCDInstallStarted, "")
-		err = argocd.ApplyArgoCDKustomize(kcfg.Clientset, argoCDInstallPath)
+		err = argocd.ApplyArgoCDKustomize(clctrl.Context, kcfg.Clientset, argoCDInstallPath)
 		if err != nil {
 			telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.ArgoCDInstallFailed, err.Error())
 			return fmt.Errorf("failed to apply ArgoCD kustomize: %w", err)
@@ -62,14 +66,15 @@ func (clctrl *ClusterController) InstallArgoCD() error {
 		telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.ArgoCDInstallCompleted, "")
 
 		// Wait for ArgoCD to be ready
-		_, err = k8s.VerifyArgoCDReadiness(kcfg.Clientset, true, 300)
+		_, err = k8s.VerifyArgoCDReadinessContext(clctrl.Context, kcfg.Clientset, true, 300)
 		if err != nil {
-			log.Error().Msgf("error waiting for ArgoCD to become ready: %s", err)
+			clctrl.logger().Error().Msgf("error waiting for ArgoCD to become ready: %s", err)
 			return fmt.Errorf("failed to verify ArgoCD readiness: %w", err)
 		}
 
-		clctrl.Cluster.ArgoCDInstallCheck = true
-		err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+		err = clctrl.updateCluster(func(cluster *types.Cluster) {
+			cluster.ArgoCDInstallCheck = true
+		})
 		if err != nil {
 			return fmt.Errorf("failed to update cluster: %w", err)
 		}
@@ -80,6 +85,10 @@ func (clctrl *ClusterController) InstallArgoCD() error {
 
 // InitializeArgoCD
 func (clctrl *ClusterController) InitializeArgoCD() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
 	cl, err := secrets.GetCluster(clctrl.KubernetesClient, clctrl.ClusterName)
 	if err != nil {
 		return fmt.Errorf("failed to get cluster: %w", err)
@@ -104,7 +113,7 @@ func (clctrl *ClusterController) InitializeArgoCD() error {
 			}
 		}
 
-		log.Info().Msg("Setting argocd username and password credentials")
+		clctrl.logger().Info().Msg("Setting argocd username and password credentials")
 
 		argocd.ArgocdSecretClient = kcfg.Clientset.CoreV1().Secrets("argocd")
 
@@ -113,8 +122,8 @@ func (clctrl *ClusterController) InitializeArgoCD() error {
 			return fmt.Errorf("argocd password not found in secret")
 		}
 
-		log.Info().Msg("argocd username and password credentials set successfully")
-		log.Info().Msg("getting an argocd auth token")
+		clctrl.logger().Info().Msg("argocd username and password credentials set successfully")
+		clctrl.logger().Info().Msg("getting an argocd auth token")
 
 		var argoCDToken string
 
@@ -142,13 +151,13 @@ func (clctrl *ClusterController) InitializeArgoCD() error {
 			}
 		}
 
-		log.Info().Msg("argocd admin auth token set")
-
-		clctrl.Cluster.ArgoCDPassword = argocdPassword
-		clctrl.Cluster.ArgoCDAuthToken = argoCDToken
-		clctrl.Cluster.ArgoCDInitializeCheck = true
+		clctrl.logger().Info().Msg("argocd admin auth token set")
 
-		err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+		err = clctrl.updateCluster(func(cluster *types.Cluster) {
+			cluster.ArgoCDPassword = argocdPassword
+			cluster.ArgoCDAuthToken = argoCDToken
+			cluster.ArgoCDInitializeCheck = true
+		})
 		if err != nil {
 			return fmt.Errorf("failed to update cluster: %w", err)
 		}
@@ -179,6 +188,10 @@ func RestartDeployment(ctx context.Context, clientset kubernetes.Interface, name
 
 // DeployRegistryApplication
 func (clctrl *ClusterController) DeployRegistryApplication() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
 	cl, err := secrets.GetCluster(clctrl.KubernetesClient, clctrl.ClusterName)
 	if err != nil {
 		return fmt.Errorf("failed to get cluster: %w", err)
@@ -209,7 +222,7 @@ func (clctrl *ClusterController) DeployRegistryApplication() error {
 			return fmt.Errorf("failed to create ArgoCD client: %w", err)
 		}
 
-		log.Info().Msg("applying the registry application to argocd")
+		clctrl.logger().Info().Msg("applying the registry application to argocd")
 
 		registryURL, err := clctrl.GetRepoURL()
 		if err != nil {
@@ -228,40 +241,39 @@ func (clctrl *ClusterController) DeployRegistryApplication() error {
 			registryPath,
 		)
 
-		if clctrl.Kcfg == nil {
-			clctrl.Kcfg, err = k8s.CreateKubeConfig(false, clctrl.ProviderConfig.Kubeconfig)
-			if err != nil {
-				return fmt.Errorf("failed to create Kubernetes config: %w", err)
-			}
+		clusterClient, err := clctrl.ClusterKubernetesClient()
+		if err != nil {
+			return err
 		}
 
-		err = RestartDeployment(context.Background(), clctrl.Kcfg.Clientset, "argocd", "argocd-applicationset-controller")
+		err = RestartDeployment(clctrl.Context, clusterClient.Clientset, "argocd", "argocd-applicationset-controller")
 		if err != nil {
 			return fmt.Errorf("failed to restart deployment: %w", err)
 		}
 
 		retryAttempts := 2
 		for attempt := 1; attempt <= retryAttempts; attempt++ {
-			log.Info().Msgf("Attempt #%d to create Argo CD application...", attempt)
+			clctrl.logger().Info().Msgf("Attempt #%d to create Argo CD application...", attempt)
 
-			app, err := argocdClient.ArgoprojV1alpha1().Applications("argocd").Create(context.Background(), registryApplicationObject, metav1.CreateOptions{})
+			app, err := argocdClient.ArgoprojV1alpha1().Applications("argocd").Create(clctrl.Context, registryApplicationObject, metav1.CreateOptions{})
 			if err != nil {
 				if attempt == retryAttempts {
 					return fmt.Errorf("failed to create Argo CD application on attempt #%d: %w", attempt, err)
 				}
-				log.Info().Msgf("Error creating Argo CD application on attempt number #%d: %v", attempt, err)
+				clctrl.logger().Info().Msgf("Error creating Argo CD application on attempt number #%d: %v", attempt, err)
 				time.Sleep(5 * time.Second)
 				continue
 			}
 
-			log.Info().Msgf("Argo CD application created successfully on attempt #%d: %s", attempt, app.Name)
+			clctrl.logger().Info().Msgf("Argo CD application created successfully on attempt #%d: %s", attempt, app.Name)
 			break
 		}
 
 		telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.CreateRegistryCompleted, "")
 
-		clctrl.Cluster.ArgoCDCreateRegistryCheck = true
-		err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+		err = clctrl.updateCluster(func(cluster *types.Cluster) {
+			cluster.ArgoCDCreateRegistryCheck = true
+		})
 		if err != nil {
 			return fmt.Errorf("failed to update cluster: %w", err)
 		}
@@ -26,85 +26,93 @@ import (
 	"github.com/konstructio/kubefirst-api/internal/k8s"
 	"github.com/konstructio/kubefirst-api/internal/secrets"
 	"github.com/konstructio/kubefirst-api/pkg/providerConfigs"
+	"github.com/konstructio/kubefirst-api/pkg/types"
 	"github.com/kubefirst/metrics-client/pkg/telemetry"
-	log "github.com/rs/zerolog/log"
 	"github.com/thanhpk/randstr"
 	v1 "k8s.io/api/apps/v1"
 )
 
 // CreateCluster
 func (clctrl *ClusterController) CreateCluster() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
 	cl, err := secrets.GetCluster(clctrl.KubernetesClient, clctrl.ClusterName)
 	if err != nil {
 		return fmt.Errorf("failed to get cluster: %w", err)
 	}
 
-	if !cl.CloudTerraformApplyCheck || cl.CloudTerraformApplyFailedCheck {
-		log.Info().Msg("creating aws cloud resources with terraform")
+	if !cl.CloudTerraformApplyCheck || cl.CloudTerraformApplyFailedCheck || cl.CloudTerraformApplyCancelledCheck {
+		clctrl.logger().Info().Msg("creating aws cloud resources with terraform")
 		tfEntrypoint := clctrl.ProviderConfig.GitopsDir + fmt.Sprintf("/terraform/%s", clctrl.CloudProvider)
-		tfEnvs := map[string]string{}
 
 		telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.CloudTerraformApplyStarted, "")
 
-		log.Info().Msgf("creating %s cluster", clctrl.CloudProvider)
+		clctrl.logger().Info().Msgf("creating %s cluster", clctrl.CloudProvider)
 
-		switch clctrl.CloudProvider {
-		case "akamai":
-			tfEnvs = akamaiext.GetAkamaiTerraformEnvs(tfEnvs, cl)
-		case "aws":
-			tfEnvs = awsext.GetAwsTerraformEnvs(tfEnvs, cl)
-			iamCaller, err := clctrl.AwsClient.GetCallerIdentity()
-			if err != nil {
-				return fmt.Errorf("error getting AWS caller identity: %w", err)
-			}
-			tfEnvs["TF_VAR_aws_account_id"] = *iamCaller.Account
-			tfEnvs["TF_VAR_use_ecr"] = strconv.FormatBool(clctrl.ECR) // Flag out the ecr terraform
+		tfEnvs, err := clctrl.cloudTerraformEnvs(cl)
+		if err != nil {
+			return err
+		}
 
-			clctrl.Cluster.AWSAccountID = *iamCaller.Account
-			err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+		if clctrl.CloudProvider == "aws" {
+			accountID := clctrl.Cluster.AWSAccountID
+			err = clctrl.updateCluster(func(cluster *types.Cluster) {
+				cluster.AWSAccountID = accountID
+			})
 			if err != nil {
 				return fmt.Errorf("failed to update cluster after getting AWS account ID: %w", err)
 			}
-		case "civo":
-			tfEnvs = civoext.GetCivoTerraformEnvs(tfEnvs, cl)
-		case "digitalocean":
-			tfEnvs = digitaloceanext.GetDigitaloceanTerraformEnvs(tfEnvs, cl)
-		case "google":
-			tfEnvs = googleext.GetGoogleTerraformEnvs(tfEnvs, cl)
-		case "vultr":
-			tfEnvs = vultrext.GetVultrTerraformEnvs(tfEnvs, cl)
-		case "k3s":
-			tfEnvs = k3sext.GetK3sTerraformEnvs(tfEnvs, cl)
 		}
 
-		err := terraformext.InitApplyAutoApprove(clctrl.ProviderConfig.TerraformClient, tfEntrypoint, tfEnvs)
-		if err != nil {
-			log.Error().Msgf("error applying cloud terraform: %s", err)
+		err = terraformext.InitApplyAutoApproveContext(clctrl.Context, clctrl.ProviderConfig.TerraformClient, tfEntrypoint, tfEnvs)
+		if err != nil && !clctrl.Cancelled() {
+			clctrl.logger().Error().Msgf("error applying cloud terraform: %s", err)
+			clctrl.logger().Info().Msg("sleeping 10 seconds before retrying terraform execution once more")
+			select {
+			case <-clctrl.Context.Done():
+			case <-time.After(10 * time.Second):
+			}
 
-			log.Info().Msg("sleeping 10 seconds before retrying terraform execution once more")
-			time.Sleep(10 * time.Second)
+			err = terraformext.InitApplyAutoApproveContext(clctrl.Context, clctrl.ProviderConfig.TerraformClient, tfEntrypoint, tfEnvs)
+		}
 
-			err = terraformext.InitApplyAutoApprove(clctrl.ProviderConfig.TerraformClient, tfEntrypoint, tfEnvs)
-			if err != nil {
-				telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.CloudTerraformApplyFailed, err.Error())
-				clctrl.Cluster.CloudTerraformApplyFailedCheck = true
+		// a cancelled run is not a failure, but resources terraform created
+		// before it was interrupted still need to be tracked for the next run
+		// or for cleanup
+		if err != nil && clctrl.Cancelled() {
+			clctrl.logger().Info().Msgf("cloud terraform for cluster %s was cancelled", clctrl.ClusterName)
+			if err := clctrl.updateCluster(func(cluster *types.Cluster) {
+				cluster.CloudTerraformApplyCancelledCheck = true
+			}); err != nil {
+				return fmt.Errorf("failed to update cluster after terraform apply was cancelled: %w", err)
+			}
 
-				if err := secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster); err != nil {
-					telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.CloudTerraformApplyFailed, err.Error())
-					return fmt.Errorf("failed to update cluster after terraform apply failed: %w", err)
-				}
+			return clctrl.checkCancelled()
+		}
 
-				log.Error().Msgf("error creating %s resources with terraform %s: %s", clctrl.CloudProvider, tfEntrypoint, err)
-				return fmt.Errorf("error creating %s resources with terraform %s: %w", clctrl.CloudProvider, tfEntrypoint, err)
+		if err != nil {
+			telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.CloudTerraformApplyFailed, err.Error())
+			if err := clctrl.updateCluster(func(cluster *types.Cluster) {
+				cluster.CloudTerraformApplyFailedCheck = true
+			}); err != nil {
+				telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.CloudTerraformApplyFailed, err.Error())
+				return fmt.Errorf("failed to update cluster after terraform apply failed: %w", err)
 			}
+
+			clctrl.logger().Error().Msgf("error creating %s resources with terraform %s: %s", clctrl.CloudProvider, tfEntrypoint, err)
+			return fmt.Errorf("error creating %s resources with terraform %s: %w", clctrl.CloudProvider, tfEntrypoint, err)
 		}
 
-		log.Info().Msgf("created %s cloud resources", clctrl.CloudProvider)
+		clctrl.logger().Info().Msgf("created %s cloud resources", clctrl.CloudProvider)
 		telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.CloudTerraformApplyCompleted, "")
 
-		clctrl.Cluster.CloudTerraformApplyCheck = true
-		clctrl.Cluster.CloudTerraformApplyFailedCheck = false
-		err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+		err = clctrl.updateCluster(func(cluster *types.Cluster) {
+			cluster.CloudTerraformApplyCheck = true
+			cluster.CloudTerraformApplyFailedCheck = false
+			cluster.CloudTerraformApplyCancelledCheck = false
+		})
 		if err != nil {
 			return fmt.Errorf("failed to update cluster state after creating cloud resources: %w", err)
 		}
@@ -113,13 +121,41 @@ func (clctrl *ClusterController) CreateCluster() error {
 	return nil
 }
 
-// CreateTokens
-func (clctrl *ClusterController) CreateTokens(kind string) interface{} {
-	cl, err := secrets.GetCluster(clctrl.KubernetesClient, clctrl.ClusterName)
-	if err != nil {
-		return fmt.Errorf("failed to get cluster while creating tokens: %w", err)
+// cloudTerraformEnvs returns the environment the cloud terraform runs with.
+// For AWS the account ID is also stored on the in-memory cluster record.
+func (clctrl *ClusterController) cloudTerraformEnvs(cl *types.Cluster) (map[string]string, error) {
+	tfEnvs := map[string]string{}
+
+	switch clctrl.CloudProvider {
+	case "akamai":
+		tfEnvs = akamaiext.GetAkamaiTerraformEnvs(tfEnvs, cl)
+	case "aws":
+		tfEnvs = awsext.GetAwsTerraformEnvs(tfEnvs, cl)
+		iamCaller, err := clctrl.AwsClient.GetCallerIdentity()
+		if err != nil {
+			return nil, fmt.Errorf("error getting AWS caller identity: %w", err)
+		}
+		tfEnvs["TF_VAR_aws_account_id"] = *iamCaller.Account
+		tfEnvs["TF_VAR_use_ecr"] = strconv.FormatBool(clctrl.ECR) // Flag out the ecr terraform
+
+		clctrl.Cluster.AWSAccountID = *iamCaller.Account
+	case "civo":
+		tfEnvs = civoext.GetCivoTerraformEnvs(tfEnvs, cl)
+	case "digitalocean":
+		tfEnvs = digitaloceanext.GetDigitaloceanTerraformEnvs(tfEnvs, cl)
+	case "google":
+		tfEnvs = googleext.GetGoogleTerraformEnvs(tfEnvs, cl)
+	case "vultr":
+		tfEnvs = vultrext.GetVultrTerraformEnvs(tfEnvs, cl)
+	case "k3s":
+		tfEnvs = k3sext.GetK3sTerraformEnvs(tfEnvs, cl)
 	}
 
+	return tfEnvs, nil
+}
+
+// CreateTokens
+func (clctrl *ClusterController) CreateTokens(kind string) interface{} {
 	var fullDomainName string
 
 	if clctrl.SubdomainName != "" {
@@ -229,7 +265,7 @@ func (clctrl *ClusterController) CreateTokens(kind string) interface{} {
 		// Handle provider specific tokens
 		switch clctrl.CloudProvider {
 		case "vultr":
-			gitopsTemplateTokens.StateStoreBucketHostname = cl.StateStoreDetails.Hostname
+			gitopsTemplateTokens.StateStoreBucketHostname = clctrl.Cluster.StateStoreDetails.Hostname
 		case "google":
 			gitopsTemplateTokens.GoogleAuth = clctrl.GoogleAuth.KeyFile
 			gitopsTemplateTokens.GoogleProject = clctrl.GoogleAuth.ProjectID
@@ -253,11 +289,11 @@ func (clctrl *ClusterController) CreateTokens(kind string) interface{} {
 
 			if clctrl.ECR {
 				gitopsTemplateTokens.ContainerRegistryURL = fmt.Sprintf("%s.dkr.ecr.%s.amazonaws.com", *iamCaller.Account, clctrl.CloudRegion)
-				log.Info().Msgf("Using ECR URL %s", gitopsTemplateTokens.ContainerRegistryURL)
+				clctrl.logger().Info().Msgf("Using ECR URL %s", gitopsTemplateTokens.ContainerRegistryURL)
 			} else {
 				// moving commented line below to default behavior
 				// gitopsTemplateTokens.ContainerRegistryURL = fmt.Sprintf("%s/%s", clctrl.ContainerRegistryHost, clctrl.GitAuth.Owner)
-				log.Info().Msgf("NOT using ECR but instead %s URL %s", clctrl.GitProvider, gitopsTemplateTokens.ContainerRegistryURL)
+				clctrl.logger().Info().Msgf("NOT using ECR but instead %s URL %s", clctrl.GitProvider, gitopsTemplateTokens.ContainerRegistryURL)
 			}
 		case "k3s":
 			gitopsTemplateTokens.K3sServersPrivateIps = clctrl.K3sAuth.K3sServersPrivateIps
@@ -285,6 +321,10 @@ func (clctrl *ClusterController) CreateTokens(kind string) interface{} {
 
 // ClusterSecretsBootstrap
 func (clctrl *ClusterController) ClusterSecretsBootstrap() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
 	cl, err := secrets.GetCluster(clctrl.KubernetesClient, clctrl.ClusterName)
 	if err != nil {
 		return fmt.Errorf("failed to get cluster during secrets bootstrap: %w", err)
@@ -326,7 +366,7 @@ func (clctrl *ClusterController) ClusterSecretsBootstrap() error {
 		case "akamai":
 			err := akamaiext.BootstrapAkamaiMgmtCluster(clientSet, cl, destinationGitopsRepoGitURL)
 			if err != nil {
-				log.Error().Msgf("error adding Kubernetes secrets for bootstrap: %s", err)
+				clctrl.logger().Error().Msgf("error adding Kubernetes secrets for bootstrap: %s", err)
 				return fmt.Errorf("error adding Kubernetes secrets for bootstrap on akamai: %w", err)
 			}
 		case "aws":
@@ -337,37 +377,37 @@ func (clctrl *ClusterController) ClusterSecretsBootstrap() error {
 				clctrl.AwsClient,
 			)
 			if err != nil {
-				log.Error().Msgf("error adding Kubernetes secrets for bootstrap: %s", err)
+				clctrl.logger().Error().Msgf("error adding Kubernetes secrets for bootstrap: %s", err)
 				return fmt.Errorf("error adding Kubernetes secrets for bootstrap on aws: %w", err)
 			}
 		case "civo":
 			err := civoext.BootstrapCivoMgmtCluster(clientSet, cl, destinationGitopsRepoGitURL)
 			if err != nil {
-				log.Error().Msgf("error adding Kubernetes secrets for bootstrap: %s", err)
+				clctrl.logger().Error().Msgf("error adding Kubernetes secrets for bootstrap: %s", err)
 				return fmt.Errorf("error adding Kubernetes secrets for bootstrap on civo: %w", err)
 			}
 		case "google":
 			err := googleext.BootstrapGoogleMgmtCluster(clientSet, cl, destinationGitopsRepoGitURL)
 			if err != nil {
-				log.Error().Msgf("error adding Kubernetes secrets for bootstrap: %s", err)
+				clctrl.logger().Error().Msgf("error adding Kubernetes secrets for bootstrap: %s", err)
 				return fmt.Errorf("error adding Kubernetes secrets for bootstrap on google: %w", err)
 			}
 		case "digitalocean":
 			err := digitaloceanext.BootstrapDigitaloceanMgmtCluster(clientSet, cl, destinationGitopsRepoGitURL)
 			if err != nil {
-				log.Error().Msgf("error adding Kubernetes secrets for bootstrap: %s", err)
+				clctrl.logger().Error().Msgf("error adding Kubernetes secrets for bootstrap: %s", err)
 				return fmt.Errorf("error adding Kubernetes secrets for bootstrap on digitalocean: %w", err)
 			}
 		case "vultr":
 			err := vultrext.BootstrapVultrMgmtCluster(clientSet, cl, destinationGitopsRepoGitURL)
 			if err != nil {
-				log.Error().Msgf("error adding Kubernetes secrets for bootstrap: %s", err)
+				clctrl.logger().Error().Msgf("error adding Kubernetes secrets for bootstrap: %s", err)
 				return fmt.Errorf("error adding Kubernetes secrets for bootstrap on vultr: %w", err)
 			}
 		case "k3s":
 			err := k3sext.BootstrapK3sMgmtCluster(clientSet, cl, destinationGitopsRepoGitURL)
 			if err != nil {
-				log.Error().Msgf("error adding Kubernetes secrets for bootstrap: %s", err)
+				clctrl.logger().Error().Msgf("error adding Kubernetes secrets for bootstrap: %s", err)
 				return fmt.Errorf("error adding Kubernetes secrets for bootstrap on k3s: %w", err)
 			}
 		}
@@ -377,8 +417,9 @@ func (clctrl *ClusterController) ClusterSecretsBootstrap() error {
 			return fmt.Errorf("failed to create service accounts during secrets bootstrap: %w", err)
 		}
 
-		clctrl.Cluster.ClusterSecretsCreatedCheck = true
-		err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+		err = clctrl.updateCluster(func(cluster *types.Cluster) {
+			cluster.ClusterSecretsCreatedCheck = true
+		})
 		if err != nil {
 			return fmt.Errorf("failed to update cluster state after creating secrets bootstrap: %w", err)
 		}
@@ -407,7 +448,7 @@ func (clctrl *ClusterController) ContainerRegistryAuth() (string, error) {
 		}
 		containerRegistryAuthToken, err := gitShim.CreateContainerRegistrySecret(&containerRegistryAuth)
 		if err != nil {
-			log.Error().Msgf("error generating container registry authentication: %s", err)
+			clctrl.logger().Error().Msgf("error generating container registry authentication: %s", err)
 			return "", fmt.Errorf("error generating container registry authentication for AWS: %w", err)
 		}
 
@@ -438,7 +479,7 @@ func (clctrl *ClusterController) ContainerRegistryAuth() (string, error) {
 	}
 	containerRegistryAuthToken, err := gitShim.CreateContainerRegistrySecret(&containerRegistryAuth)
 	if err != nil {
-		log.Error().Msgf("error generating container registry authentication: %s", err)
+		clctrl.logger().Error().Msgf("error generating container registry authentication: %s", err)
 		return "", fmt.Errorf("error generating container registry authentication for cloud provider %s: %w", clctrl.CloudProvider, err)
 	}
 
@@ -447,6 +488,10 @@ func (clctrl *ClusterController) ContainerRegistryAuth() (string, error) {
 
 // WaitForClusterReady
 func (clctrl *ClusterController) WaitForClusterReady() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
 	var kcfg *k8s.KubernetesClient
 
 	switch clctrl.CloudProvider {
@@ -470,7 +515,8 @@ func (clctrl *ClusterController) WaitForClusterReady() error {
 	var err error
 	switch clctrl.CloudProvider {
 	case "aws", "civo", "digitalocean", "vultr", "k3s":
-		dnsDeployment, err = k8s.ReturnDeploymentObject(
+		dnsDeployment, err = k8s.ReturnDeploymentObjectContext(
+			clctrl.Context,
 			kcfg.Clientset,
 			"kubernetes.io/name",
 			"CoreDNS",
@@ -478,11 +524,12 @@ func (clctrl *ClusterController) WaitForClusterReady() error {
 			300,
 		)
 		if err != nil {
-			log.Error().Msgf("error finding CoreDNS deployment: %s", err)
+			clctrl.logger().Error().Msgf("error finding CoreDNS deployment: %s", err)
 			return fmt.Errorf("error finding CoreDNS deployment while waiting for cluster to be ready: %w", err)
 		}
 	case "google":
-		dnsDeployment, err = k8s.ReturnDeploymentObject(
+		dnsDeployment, err = k8s.ReturnDeploymentObjectContext(
+			clctrl.Context,
 			kcfg.Clientset,
 			"k8s-app",
 			"kube-dns",
@@ -490,14 +537,14 @@ func (clctrl *ClusterController) WaitForClusterReady() error {
 			300,
 		)
 		if err != nil {
-			log.Error().Msgf("error finding CoreDNS deployment: %s", err)
+			clctrl.logger().Error().Msgf("error finding CoreDNS deployment: %s", err)
 			return fmt.Errorf("error finding CoreDNS deployment while waiting for cluster to be ready: %w", err)
 		}
 	}
 
-	_, err = k8s.WaitForDeploymentReady(kcfg.Clientset, dnsDeployment, 120)
+	_, err = k8s.WaitForDeploymentReadyContext(clctrl.Context, kcfg.Clientset, dnsDeployment, 120)
 	if err != nil {
-		log.Error().Msgf("error waiting for CoreDNS deployment ready state: %s", err)
+		clctrl.logger().Error().Msgf("error waiting for CoreDNS deployment ready state: %s", err)
 		return fmt.Errorf("error waiting for CoreDNS deployment ready state while waiting for cluster to be ready: %w", err)
 	}
 
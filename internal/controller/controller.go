@@ -13,8 +13,10 @@ import (
 	"net/http"
 	"time"
 
+	awsext "github.com/konstructio/kubefirst-api/extensions/aws"
 	runtime "github.com/konstructio/kubefirst-api/internal"
 	awsinternal "github.com/konstructio/kubefirst-api/internal/aws"
+	"github.com/konstructio/kubefirst-api/internal/clusterlogs"
 	"github.com/konstructio/kubefirst-api/internal/constants"
 	"github.com/konstructio/kubefirst-api/internal/env"
 	"github.com/konstructio/kubefirst-api/internal/github"
@@ -28,12 +30,18 @@ import (
 	"github.com/konstructio/kubefirst-api/pkg/providerConfigs"
 	"github.com/konstructio/kubefirst-api/pkg/types"
 	"github.com/kubefirst/metrics-client/pkg/telemetry"
-	log "github.com/rs/zerolog/log"
+	"github.com/rs/zerolog"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"k8s.io/client-go/kubernetes"
 )
 
 type ClusterController struct {
+	// Context is cancelled when the provisioning run should stop
+	Context context.Context
+
+	// DryRun initializes the controller without writing a cluster record
+	DryRun bool
+
 	CloudProvider             string
 	CloudRegion               string
 	ClusterName               string
@@ -62,6 +70,7 @@ type ClusterController struct {
 	NodeCount              int
 	PostInstallCatalogApps []types.GitopsCatalogApp
 	InstallKubefirstPro    bool
+	ForceDestroy           bool
 
 	// configs
 	ProviderConfig providerConfigs.ProviderConfig
@@ -111,10 +120,14 @@ type ClusterController struct {
 	GoogleClient google.Configuration
 	Kcfg         *k8s.KubernetesClient
 	Cluster      types.Cluster
+
+	vaultStopChannel chan struct{}
 }
 
 // InitController
-func (clctrl *ClusterController) InitController(def *types.ClusterDefinition) error {
+func (clctrl *ClusterController) InitController(ctx context.Context, def *types.ClusterDefinition) error {
+	clctrl.Context = ctx
+
 	// Create k1 dir if it doesn't exist
 	utils.CreateK1Directory(def.ClusterName)
 
@@ -143,19 +156,12 @@ func (clctrl *ClusterController) InitController(def *types.ClusterDefinition) er
 
 	if rec == nil {
 		recordExists = false
-		log.Info().Msg("cluster record doesn't exist, continuing")
+		clctrl.logger().Info().Msg("cluster record doesn't exist, continuing")
 	}
 
-	logFileName := def.LogFileName
-	if recordExists {
-		logFileName = rec.LogFileName
-	}
-
-	utils.InitializeLogs(logFileName)
-
 	// If record exists but status is deleted, entry should be deleted
 	// and process should start fresh
-	if recordExists && rec.Status == constants.ClusterStatusDeleted {
+	if recordExists && rec.Status == constants.ClusterStatusDeleted && !clctrl.DryRun {
 		err = secrets.DeleteCluster(clctrl.KubernetesClient, def.ClusterName)
 		if err != nil {
 			return fmt.Errorf("error deleting existing cluster %q: %w", def.ClusterName, err)
@@ -203,6 +209,7 @@ func (clctrl *ClusterController) InitController(def *types.ClusterDefinition) er
 	clctrl.NodeCount = def.NodeCount
 	clctrl.PostInstallCatalogApps = def.PostInstallCatalogApps
 	clctrl.InstallKubefirstPro = def.InstallKubefirstPro
+	clctrl.ForceDestroy = def.ForceDestroy
 
 	clctrl.AkamaiAuth = def.AkamaiAuth
 	clctrl.AWSAuth = def.AWSAuth
@@ -345,7 +352,7 @@ func (clctrl *ClusterController) InitController(def *types.ClusterDefinition) er
 		clctrl.AwsClient = &awsinternal.Configuration{Config: conf}
 	case "google":
 		clctrl.GoogleClient = google.Configuration{
-			Context: context.Background(),
+			Context: ctx,
 			Project: def.GoogleAuth.ProjectID,
 			Region:  clctrl.CloudRegion,
 		}
@@ -388,10 +395,14 @@ func (clctrl *ClusterController) InitController(def *types.ClusterDefinition) er
 		NodeCount:              clctrl.NodeCount,
 		LogFileName:            def.LogFileName,
 		PostInstallCatalogApps: clctrl.PostInstallCatalogApps,
+		InstallKubefirstPro:    clctrl.InstallKubefirstPro,
+		ForceDestroy:           clctrl.ForceDestroy,
 	}
 
-	if !recordExists {
-		log.Info().Msg("cluster record doesn't exist after initialization, inserting")
+	if !recordExists && clctrl.DryRun {
+		clctrl.logger().Info().Msg("cluster record doesn't exist, skipping insert for dry run")
+	} else if !recordExists {
+		clctrl.logger().Info().Msg("cluster record doesn't exist after initialization, inserting")
 		err = secrets.InsertCluster(clctrl.KubernetesClient, clctrl.Cluster)
 		if err != nil {
 			return fmt.Errorf("error inserting cluster record: %w", err)
@@ -450,6 +461,63 @@ func (clctrl *ClusterController) SetGitTokens(def types.ClusterDefinition) error
 	return nil
 }
 
+// ClusterKubernetesClient returns a client for the cluster being provisioned,
+// creating it on first use
+func (clctrl *ClusterController) ClusterKubernetesClient() (*k8s.KubernetesClient, error) {
+	if clctrl.Kcfg != nil {
+		return clctrl.Kcfg, nil
+	}
+
+	switch clctrl.CloudProvider {
+	case "aws":
+		clctrl.Kcfg = awsext.CreateEKSKubeconfig(&clctrl.AwsClient.Config, clctrl.ClusterName)
+	case "google":
+		kcfg, err := clctrl.GoogleClient.GetContainerClusterAuth(clctrl.ClusterName, []byte(clctrl.GoogleAuth.KeyFile))
+		if err != nil {
+			return nil, fmt.Errorf("unable to get Google cluster auth: %w", err)
+		}
+		clctrl.Kcfg = kcfg
+	default:
+		kcfg, err := k8s.CreateKubeConfig(false, clctrl.ProviderConfig.Kubeconfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Kubernetes config: %w", err)
+		}
+		clctrl.Kcfg = kcfg
+	}
+
+	return clctrl.Kcfg, nil
+}
+
+// clusterController returns a controller carrying the cloud credentials of an
+// existing cluster, enough for read-only calls to its cloud provider
+func clusterController(ctx context.Context, cl *types.Cluster) (*ClusterController, error) {
+	clctrl := &ClusterController{
+		Context:          ctx,
+		CloudProvider:    cl.CloudProvider,
+		CloudRegion:      cl.CloudRegion,
+		ClusterName:      cl.ClusterName,
+		NodeType:         cl.NodeType,
+		NodeCount:        cl.NodeCount,
+		AkamaiAuth:       cl.AkamaiAuth,
+		AWSAuth:          cl.AWSAuth,
+		CivoAuth:         cl.CivoAuth,
+		DigitaloceanAuth: cl.DigitaloceanAuth,
+		VultrAuth:        cl.VultrAuth,
+		GoogleAuth:       cl.GoogleAuth,
+		Cluster:          *cl,
+	}
+
+	if cl.CloudProvider == "aws" {
+		conf, err := awsinternal.NewAwsV3(cl.CloudRegion, cl.AWSAuth.AccessKeyID, cl.AWSAuth.SecretAccessKey, cl.AWSAuth.SessionToken)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create aws client: %w", err)
+		}
+		clctrl.AwsClient = &awsinternal.Configuration{Config: conf}
+	}
+
+	return clctrl, nil
+}
+
 // GetCurrentClusterRecord will return an active cluster's record if it exists
 func (clctrl *ClusterController) GetCurrentClusterRecord() (*types.Cluster, error) {
 	cl, err := secrets.GetCluster(clctrl.KubernetesClient, clctrl.ClusterName)
@@ -460,16 +528,49 @@ func (clctrl *ClusterController) GetCurrentClusterRecord() (*types.Cluster, erro
 	return cl, nil
 }
 
+// updateCluster applies mutate to the cluster of the controller and writes
+// only the fields it sets to the latest record of the cluster
+func (clctrl *ClusterController) updateCluster(mutate func(cluster *types.Cluster)) error {
+	return secrets.UpdateClusterFields(clctrl.KubernetesClient, &clctrl.Cluster, mutate)
+}
+
 // UpdateClusterOnError implements an error handler for cluster controller objects
 func (clctrl *ClusterController) UpdateClusterOnError(condition string) error {
-	clctrl.Cluster.InProgress = false
-	clctrl.Cluster.Status = constants.ClusterStatusError
-	clctrl.Cluster.LastCondition = condition
+	status := constants.ClusterStatusError
+	if clctrl.Cancelled() {
+		status = constants.ClusterStatusCancelled
+	}
 
-	log.Error().Msgf("unexpected error: %s", condition)
-	if err := secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster); err != nil {
+	clctrl.logger().Error().Msgf("unexpected error: %s", condition)
+	err := clctrl.updateCluster(func(cluster *types.Cluster) {
+		cluster.InProgress = false
+		cluster.Status = status
+		cluster.LastCondition = condition
+	})
+	if err != nil {
 		return fmt.Errorf("error updating cluster after condition failure: %w", err)
 	}
 
 	return nil
 }
+
+// Cancelled reports whether the provisioning run has been cancelled
+func (clctrl *ClusterController) Cancelled() bool {
+	return clctrl.Context != nil && clctrl.Context.Err() != nil
+}
+
+// logger returns the logger of the run the controller works for, whose
+// entries are kept in the logs of the cluster
+func (clctrl *ClusterController) logger() *zerolog.Logger {
+	return clusterlogs.Logger(clctrl.Context)
+}
+
+// checkCancelled returns an error when the provisioning run has been cancelled
+// so that a step stops before starting any new work
+func (clctrl *ClusterController) checkCancelled() error {
+	if clctrl.Cancelled() {
+		return fmt.Errorf("cluster %q provisioning cancelled: %w", clctrl.ClusterName, clctrl.Context.Err())
+	}
+
+	return nil
+}
@@ -7,9 +7,9 @@ See the LICENSE file for more details.
 package controller
 
 import (
-	"context"
 	"errors"
 	"fmt"
+	"slices"
 
 	cloudflare_api "github.com/cloudflare/cloudflare-go"
 	"github.com/konstructio/kubefirst-api/internal/civo"
@@ -18,12 +18,16 @@ import (
 	"github.com/konstructio/kubefirst-api/internal/dns"
 	"github.com/konstructio/kubefirst-api/internal/secrets"
 	"github.com/konstructio/kubefirst-api/internal/vultr"
+	"github.com/konstructio/kubefirst-api/pkg/types"
 	"github.com/kubefirst/metrics-client/pkg/telemetry"
-	log "github.com/rs/zerolog/log"
 )
 
 // DomainLivenessTest
 func (clctrl *ClusterController) DomainLivenessTest() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
 	cl, err := secrets.GetCluster(clctrl.KubernetesClient, clctrl.ClusterName)
 	if err != nil {
 		return fmt.Errorf("failed to get cluster for domain liveness test: %w", err)
@@ -43,17 +47,17 @@ func (clctrl *ClusterController) DomainLivenessTest() error {
 		case "civo":
 			civoConf := civo.Configuration{
 				Client:  civo.NewCivo(cl.CivoAuth.Token, cl.CloudRegion),
-				Context: context.Background(),
+				Context: clctrl.Context,
 			}
 
 			// domain id
 			domainID, err := civoConf.GetDNSInfo(clctrl.DomainName)
 			if err != nil {
 				telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.DomainLivenessFailed, err.Error())
-				log.Info().Msg(err.Error())
+				clctrl.logger().Info().Msg(err.Error())
 			}
 
-			log.Info().Msgf("domainId: %s", domainID)
+			clctrl.logger().Info().Msgf("domainId: %s", domainID)
 			domainLiveness := civoConf.TestDomainLiveness(clctrl.DomainName, domainID)
 
 			err = clctrl.HandleDomainLiveness(domainLiveness)
@@ -69,7 +73,7 @@ func (clctrl *ClusterController) DomainLivenessTest() error {
 
 			cloudflareConf := cloudflare.Configuration{
 				Client:  client,
-				Context: context.Background(),
+				Context: clctrl.Context,
 			}
 
 			domainLiveness := cloudflareConf.TestDomainLiveness(clctrl.DomainName)
@@ -81,16 +85,16 @@ func (clctrl *ClusterController) DomainLivenessTest() error {
 		case "digitalocean":
 			digitaloceanConf := digitalocean.Configuration{
 				Client:  digitalocean.NewDigitalocean(cl.DigitaloceanAuth.Token),
-				Context: context.Background(),
+				Context: clctrl.Context,
 			}
 
 			// domain id
 			domainID, err := digitaloceanConf.GetDNSInfo(clctrl.DomainName)
 			if err != nil {
-				log.Info().Msg(err.Error())
+				clctrl.logger().Info().Msg(err.Error())
 			}
 
-			log.Info().Msgf("domainId: %s", domainID)
+			clctrl.logger().Info().Msgf("domainId: %s", domainID)
 			domainLiveness := digitaloceanConf.TestDomainLiveness(clctrl.DomainName)
 
 			err = clctrl.HandleDomainLiveness(domainLiveness)
@@ -100,17 +104,17 @@ func (clctrl *ClusterController) DomainLivenessTest() error {
 		case "vultr":
 			vultrConf := vultr.Configuration{
 				Client:  vultr.NewVultr(cl.VultrAuth.Token),
-				Context: context.Background(),
+				Context: clctrl.Context,
 			}
 
 			// domain id
 			domainID, err := vultrConf.GetDNSInfo(clctrl.DomainName)
 			if err != nil {
-				log.Info().Msg(err.Error())
+				clctrl.logger().Info().Msg(err.Error())
 			}
 
 			// viper values set in above function
-			log.Info().Msgf("domainId: %s", domainID)
+			clctrl.logger().Info().Msgf("domainId: %s", domainID)
 			domainLiveness := vultrConf.TestDomainLiveness(clctrl.DomainName)
 
 			err = clctrl.HandleDomainLiveness(domainLiveness)
@@ -119,15 +123,71 @@ func (clctrl *ClusterController) DomainLivenessTest() error {
 			}
 		}
 
-		clctrl.Cluster.DomainLivenessCheck = true
-		err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+		err = clctrl.updateCluster(func(cluster *types.Cluster) {
+			cluster.DomainLivenessCheck = true
+		})
 		if err != nil {
 			return fmt.Errorf("failed to update cluster after domain liveness test: %w", err)
 		}
 
 		telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.DomainLivenessCompleted, "")
 
-		log.Info().Msgf("domain %s verified", clctrl.DomainName)
+		clctrl.logger().Info().Msgf("domain %s verified", clctrl.DomainName)
+	}
+
+	return nil
+}
+
+// CheckDomain is the read-only counterpart of DomainLivenessTest. It confirms
+// that the DNS provider hosts the domain and that the domain has name servers,
+// without writing the liveness record.
+func (clctrl *ClusterController) CheckDomain() error {
+	var err error
+
+	switch clctrl.DNSProvider {
+	case "aws":
+		_, err = clctrl.AwsClient.GetHostedZoneID(clctrl.DomainName)
+	case "civo":
+		_, err = clctrl.civoConfiguration().GetDNSInfo(clctrl.DomainName)
+	case "cloudflare":
+		client, clientErr := cloudflare_api.NewWithAPIToken(clctrl.CloudflareAuth.APIToken)
+		if clientErr != nil {
+			return fmt.Errorf("failed to create Cloudflare client: %w", clientErr)
+		}
+
+		cloudflareConf := cloudflare.Configuration{
+			Client:  client,
+			Context: clctrl.Context,
+		}
+
+		var domains []string
+		domains, err = cloudflareConf.GetDNSDomains()
+		if err == nil && !slices.Contains(domains, clctrl.DomainName) {
+			err = fmt.Errorf("zone %s not found", clctrl.DomainName)
+		}
+	case "digitalocean":
+		_, err = clctrl.digitaloceanConfiguration().GetDNSInfo(clctrl.DomainName)
+	case "google":
+		var domains []string
+		domains, err = clctrl.googleConfiguration().GetDNSDomains()
+		if err == nil && !slices.Contains(domains, clctrl.DomainName) {
+			err = fmt.Errorf("managed zone %s not found", clctrl.DomainName)
+		}
+	case "vultr":
+		_, err = clctrl.vultrConfiguration().GetDNSInfo(clctrl.DomainName)
+	default:
+		return fmt.Errorf("dns provider %q is not supported", clctrl.DNSProvider)
+	}
+	if err != nil {
+		return fmt.Errorf("domain %s is not hosted by dns provider %s: %w", clctrl.DomainName, clctrl.DNSProvider, err)
+	}
+
+	records, err := dns.GetDomainNSRecords(clctrl.DomainName)
+	if err != nil {
+		return fmt.Errorf("error looking up name servers for domain %s: %w", clctrl.DomainName, err)
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("no NS records found for domain %s", clctrl.DomainName)
 	}
 
 	return nil
@@ -138,7 +198,7 @@ func (clctrl *ClusterController) HandleDomainLiveness(domainLiveness bool) error
 	if !domainLiveness {
 		foundRecords, err := dns.GetDomainNSRecords(clctrl.DomainName)
 		if err != nil {
-			log.Warn().Msgf("error attempting to get NS records for domain %s: %s", clctrl.DomainName, err)
+			clctrl.logger().Warn().Msgf("error attempting to get NS records for domain %s: %s", clctrl.DomainName, err)
 		}
 		msg := fmt.Sprintf("failed to verify domain liveness for domain %s", clctrl.DomainName)
 		if len(foundRecords) != 0 {
@@ -0,0 +1,179 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	terraformext "github.com/konstructio/kubefirst-api/extensions/terraform"
+	"github.com/konstructio/kubefirst-api/internal/argocd"
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/pkg/types"
+)
+
+// Drift detection stack names
+const (
+	DriftStackCloud = "cloud"
+	DriftStackGit   = "git"
+	DriftStackVault = "vault"
+	DriftStackUsers = "users"
+)
+
+// DetectDrift plans the cloud, git, vault and users terraform of the cluster
+// against a fresh clone of its gitops repository and lists the ArgoCD
+// applications that are out of sync. Nothing is applied. The terraform state
+// is read from the state store with the credentials on the cluster record.
+func (clctrl *ClusterController) DetectDrift() *types.ClusterDrift {
+	drift := &types.ClusterDrift{
+		CheckedAt:             time.Now().UTC().Format(time.RFC3339),
+		Stacks:                []types.TerraformDrift{},
+		OutOfSyncApplications: []string{},
+	}
+
+	clctrl.detectTerraformDrift(drift)
+
+	kcfg, err := clctrl.ClusterKubernetesClient()
+	if err == nil {
+		drift.OutOfSyncApplications, err = argocd.ListOutOfSyncApplications(kcfg.Clientset)
+	}
+	if err != nil {
+		drift.ArgoCDError = err.Error()
+	}
+	if len(drift.OutOfSyncApplications) > 0 {
+		drift.Drifted = true
+	}
+
+	return drift
+}
+
+func (clctrl *ClusterController) detectTerraformDrift(drift *types.ClusterDrift) {
+	stacks := []string{DriftStackCloud, DriftStackGit, DriftStackVault, DriftStackUsers}
+
+	failAll := func(err error) {
+		for _, stack := range stacks {
+			drift.Stacks = append(drift.Stacks, types.TerraformDrift{
+				Stack:   stack,
+				Status:  constants.DriftStatusError,
+				Message: err.Error(),
+			})
+		}
+	}
+
+	workDir, err := os.MkdirTemp("", fmt.Sprintf("kubefirst-drift-%s-", clctrl.ClusterName))
+	if err != nil {
+		failAll(fmt.Errorf("error creating working directory: %w", err))
+		return
+	}
+	defer os.RemoveAll(workDir)
+
+	_, cloudEntrypoint, err := clctrl.cloneGitops(workDir)
+	if err != nil {
+		failAll(err)
+		return
+	}
+	terraformDir := filepath.Dir(cloudEntrypoint)
+
+	terraformClient, err := clctrl.terraformClient(workDir)
+	if err != nil {
+		failAll(err)
+		return
+	}
+	defer clctrl.closeVaultPortForward()
+
+	for _, stack := range stacks {
+		result := types.TerraformDrift{Stack: stack}
+
+		entrypoint, tfEnvs, err := clctrl.driftStack(stack, workDir, terraformDir)
+		var skipped skippedError
+		switch {
+		case errors.As(err, &skipped):
+			result.Status = constants.DriftStatusSkipped
+			result.Message = err.Error()
+		case err != nil:
+			result.Status = constants.DriftStatusError
+			result.Message = err.Error()
+		default:
+			clctrl.logger().Info().Msgf("checking %s terraform of cluster %s for drift", stack, clctrl.ClusterName)
+			plan, err := terraformext.InitPlanContext(clctrl.Context, terraformClient, entrypoint, tfEnvs)
+			if err != nil {
+				result.Status = constants.DriftStatusError
+				result.Message = err.Error()
+				break
+			}
+
+			result.Plan = plan
+			result.Status = constants.DriftStatusInSync
+			if plan.Add+plan.Change+plan.Destroy > 0 {
+				result.Status = constants.DriftStatusDrifted
+				result.Message = fmt.Sprintf("%d to add, %d to change, %d to destroy", plan.Add, plan.Change, plan.Destroy)
+				drift.Drifted = true
+			}
+		}
+
+		drift.Stacks = append(drift.Stacks, result)
+	}
+}
+
+// driftStack returns the entrypoint and environment used to plan a stack. A
+// stack that was never applied to the cluster is skipped.
+func (clctrl *ClusterController) driftStack(stack, workDir, terraformDir string) (string, map[string]string, error) {
+	cl := &clctrl.Cluster
+
+	switch stack {
+	case DriftStackCloud:
+		if !cl.CloudTerraformApplyCheck {
+			return "", nil, skippedError("cloud terraform has not been applied")
+		}
+		tfEnvs, err := clctrl.workDirTerraformEnvs(workDir)
+		return filepath.Join(terraformDir, clctrl.CloudProvider), tfEnvs, err
+	case DriftStackGit:
+		if !cl.GitTerraformApplyCheck {
+			return "", nil, skippedError("git terraform has not been applied")
+		}
+		return filepath.Join(terraformDir, clctrl.GitProvider), clctrl.gitTerraformEnvs(cl), nil
+	}
+
+	// vault and users are applied through a port forward to vault
+	switch {
+	case stack == DriftStackVault && !cl.VaultTerraformApplyCheck:
+		return "", nil, skippedError("vault terraform has not been applied")
+	case stack == DriftStackUsers && !cl.UsersTerraformApplyCheck:
+		return "", nil, skippedError("users terraform has not been applied")
+	case stack == DriftStackVault && clctrl.GitProvider == "gitlab":
+		// the gitlab registry deploy token is written to vault by the create
+		// and not kept on the cluster record, so it cannot be compared
+		return "", nil, skippedError("the vault stack of a gitlab cluster cannot be compared without its registry deploy token")
+	}
+
+	kcfg, err := clctrl.ClusterKubernetesClient()
+	if err != nil {
+		return "", nil, err
+	}
+	if err := clctrl.OpenVaultPortForward(); err != nil {
+		return "", nil, err
+	}
+
+	var tfEnvs map[string]string
+	if stack == DriftStackVault {
+		tfEnvs = clctrl.vaultTerraformEnvs(kcfg.Clientset, cl, "")
+	} else {
+		tfEnvs = clctrl.usersTerraformEnvs(kcfg.Clientset, cl)
+	}
+	if clctrl.CloudProvider == "google" {
+		cloudEnvs, err := clctrl.workDirTerraformEnvs(workDir)
+		if err != nil {
+			return "", nil, err
+		}
+		tfEnvs["GOOGLE_APPLICATION_CREDENTIALS"] = cloudEnvs["GOOGLE_APPLICATION_CREDENTIALS"]
+	}
+
+	return filepath.Join(terraformDir, stack), tfEnvs, nil
+}
@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/konstructio/kubefirst-api/pkg/types"
+)
+
+func TestDriftStackSkipped(t *testing.T) {
+	tests := []struct {
+		name    string
+		stack   string
+		cluster types.Cluster
+	}{
+		{name: "cloud not applied", stack: DriftStackCloud},
+		{name: "git not applied", stack: DriftStackGit},
+		{name: "vault not applied", stack: DriftStackVault},
+		{name: "users not applied", stack: DriftStackUsers},
+		{
+			name:    "gitlab vault",
+			stack:   DriftStackVault,
+			cluster: types.Cluster{GitProvider: "gitlab", VaultTerraformApplyCheck: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clctrl := &ClusterController{Cluster: tt.cluster, GitProvider: tt.cluster.GitProvider}
+
+			_, _, err := clctrl.driftStack(tt.stack, t.TempDir(), t.TempDir())
+			var skipped skippedError
+			if !errors.As(err, &skipped) {
+				t.Errorf("expected %s to be skipped, got %v", tt.stack, err)
+			}
+		})
+	}
+}
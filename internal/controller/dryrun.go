@@ -0,0 +1,330 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	terraformext "github.com/konstructio/kubefirst-api/extensions/terraform"
+	"github.com/konstructio/kubefirst-api/internal/civo"
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/digitalocean"
+	"github.com/konstructio/kubefirst-api/internal/utils"
+	"github.com/konstructio/kubefirst-api/internal/vultr"
+	google "github.com/konstructio/kubefirst-api/pkg/google"
+	"github.com/konstructio/kubefirst-api/pkg/providerConfigs"
+	"github.com/konstructio/kubefirst-api/pkg/types"
+)
+
+// Dry run check names
+const (
+	DryRunCheckInitialize     = "initialize"
+	DryRunCheckCredentials    = "cloud_credentials"
+	DryRunCheckGitCredentials = "git_credentials"
+	DryRunCheckDomain         = "domain"
+	DryRunCheckQuotas         = "quotas"
+	DryRunCheckRenderGitops   = "render_gitops"
+	DryRunCheckTerraformPlan  = "terraform_plan"
+)
+
+// awsDryRunQuotas lists the service quotas reported by a dry run against AWS,
+// keyed by service code
+var awsDryRunQuotas = map[string][]string{
+	"ec2": {"Running On-Demand Standard (A, C, D, H, I, M, R, T, Z) instances", "EC2-VPC Elastic IPs"},
+	"eks": {"Clusters"},
+	"vpc": {"VPCs per Region", "Internet gateways per Region", "NAT gateways per Availability Zone"},
+}
+
+// dryRunBackendOverride points terraform at local state, since the state
+// store bucket does not exist before the cluster is created
+const dryRunBackendOverride = `terraform {
+  backend "local" {}
+}
+`
+
+// skippedError marks a dry run check that does not apply to the cluster
+type skippedError string
+
+func (e skippedError) Error() string {
+	return string(e)
+}
+
+// RunDryRun runs the read-only checks of a cluster create and plans the cloud
+// terraform against a copy of the gitops repository rendered into a temporary
+// directory. Nothing is created with the cloud provider, the git provider or
+// in the cluster record.
+func (clctrl *ClusterController) RunDryRun() *types.DryRunReport {
+	report := NewDryRunReport(clctrl.ClusterName, clctrl.CloudProvider)
+	AddDryRunCheck(report, DryRunCheckInitialize, "cluster definition accepted", nil)
+
+	message, err := clctrl.checkCloudCredentials()
+	AddDryRunCheck(report, DryRunCheckCredentials, message, err)
+
+	AddDryRunCheck(report, DryRunCheckGitCredentials, fmt.Sprintf("authenticated to %s as %s", clctrl.GitProvider, clctrl.GitAuth.User), nil)
+
+	err = clctrl.CheckDomain()
+	AddDryRunCheck(report, DryRunCheckDomain, fmt.Sprintf("domain %s is hosted by %s", clctrl.DomainName, clctrl.DNSProvider), err)
+
+	message, err = clctrl.checkQuotas()
+	AddDryRunCheck(report, DryRunCheckQuotas, message, err)
+
+	workDir, err := os.MkdirTemp("", fmt.Sprintf("kubefirst-dry-run-%s-", clctrl.ClusterName))
+	if err != nil {
+		AddDryRunCheck(report, DryRunCheckRenderGitops, "", fmt.Errorf("error creating temporary directory: %w", err))
+		AddDryRunCheck(report, DryRunCheckTerraformPlan, "", skippedError("the gitops repository was not rendered"))
+		return report
+	}
+	defer os.RemoveAll(workDir)
+
+	err = clctrl.renderGitopsRepository(workDir)
+	AddDryRunCheck(report, DryRunCheckRenderGitops, "gitops and metaphor repositories rendered", err)
+	if err != nil {
+		AddDryRunCheck(report, DryRunCheckTerraformPlan, "", skippedError("the gitops repository was not rendered"))
+		return report
+	}
+
+	plan, err := clctrl.planCloudTerraform(workDir)
+	if err != nil && errors.Is(clctrl.Context.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("terraform plan did not finish before the dry run timed out: %w", clctrl.Context.Err())
+	}
+	if err == nil {
+		report.Plan = plan
+		message = fmt.Sprintf("%d to add, %d to change, %d to destroy", plan.Add, plan.Change, plan.Destroy)
+	}
+	AddDryRunCheck(report, DryRunCheckTerraformPlan, message, err)
+
+	return report
+}
+
+// NewDryRunReport returns an empty dry run report that passes until a failed
+// check is added
+func NewDryRunReport(clusterName, cloudProvider string) *types.DryRunReport {
+	return &types.DryRunReport{
+		ClusterName:   clusterName,
+		CloudProvider: cloudProvider,
+		Passed:        true,
+		Checks:        []types.DryRunCheck{},
+	}
+}
+
+// AddDryRunCheck records the outcome of a check on the report. A nil error
+// passes the check with message, a skippedError skips it and any other error
+// fails it.
+func AddDryRunCheck(report *types.DryRunReport, name, message string, err error) {
+	check := types.DryRunCheck{
+		Name:    name,
+		Status:  constants.CheckStatusPass,
+		Message: message,
+	}
+
+	var skipped skippedError
+	switch {
+	case errors.As(err, &skipped):
+		check.Status = constants.CheckStatusSkip
+		check.Message = err.Error()
+	case err != nil:
+		check.Status = constants.CheckStatusFail
+		check.Message = err.Error()
+		report.Passed = false
+	}
+
+	report.Checks = append(report.Checks, check)
+}
+
+// checkCloudCredentials makes a read-only call to the cloud provider to
+// confirm the credentials on the definition are accepted
+func (clctrl *ClusterController) checkCloudCredentials() (string, error) {
+	switch clctrl.CloudProvider {
+	case "akamai":
+		if _, err := clctrl.linodeClient().GetProfile(clctrl.Context); err != nil {
+			return "", fmt.Errorf("error validating akamai token: %w", err)
+		}
+	case "aws":
+		iamCaller, err := clctrl.AwsClient.GetCallerIdentity()
+		if err != nil {
+			return "", fmt.Errorf("error validating aws credentials: %w", err)
+		}
+		return fmt.Sprintf("authenticated as %s", *iamCaller.Arn), nil
+	case "civo":
+		if _, err := clctrl.civoConfiguration().GetRegions(); err != nil {
+			return "", fmt.Errorf("error validating civo token: %w", err)
+		}
+	case "digitalocean":
+		if _, err := clctrl.digitaloceanConfiguration().GetRegions(); err != nil {
+			return "", fmt.Errorf("error validating digitalocean token: %w", err)
+		}
+	case "google":
+		if _, err := clctrl.googleConfiguration().GetRegions(); err != nil {
+			return "", fmt.Errorf("error validating google credentials: %w", err)
+		}
+	case "vultr":
+		if _, err := clctrl.vultrConfiguration().GetRegions(); err != nil {
+			return "", fmt.Errorf("error validating vultr token: %w", err)
+		}
+	default:
+		return "", skippedError(fmt.Sprintf("%s has no cloud credentials to validate", clctrl.CloudProvider))
+	}
+
+	return fmt.Sprintf("%s credentials accepted", clctrl.CloudProvider), nil
+}
+
+// checkQuotas reports the service quotas the cluster depends on. Only AWS
+// exposes quotas through its api.
+func (clctrl *ClusterController) checkQuotas() (string, error) {
+	if clctrl.CloudProvider != "aws" {
+		return "", skippedError(fmt.Sprintf("quotas cannot be checked for %s", clctrl.CloudProvider))
+	}
+
+	quotas, err := clctrl.AwsClient.GetServiceQuotas(slices.Sorted(maps.Keys(awsDryRunQuotas)))
+	if err != nil {
+		return "", fmt.Errorf("error getting aws service quotas: %w", err)
+	}
+
+	found := []string{}
+	for service, names := range awsDryRunQuotas {
+		for _, quota := range quotas[service] {
+			if !slices.Contains(names, quota.QuotaName) {
+				continue
+			}
+
+			if quota.QuotaValue < 1 {
+				return "", fmt.Errorf("aws %s quota %q is %.0f", service, quota.QuotaName, quota.QuotaValue)
+			}
+			found = append(found, fmt.Sprintf("%s %s: %.0f", service, quota.QuotaName, quota.QuotaValue))
+		}
+	}
+	slices.Sort(found)
+
+	return strings.Join(found, ", "), nil
+}
+
+// renderGitopsRepository clones and detokenizes the gitops and metaphor
+// templates into workDir. The repositories are not pushed.
+func (clctrl *ClusterController) renderGitopsRepository(workDir string) error {
+	clctrl.ProviderConfig.K1Dir = workDir
+	clctrl.ProviderConfig.GitopsDir = filepath.Join(workDir, "gitops")
+	clctrl.ProviderConfig.MetaphorDir = filepath.Join(workDir, "metaphor")
+
+	var apexContentExists bool
+	switch clctrl.CloudProvider {
+	case "google":
+		apexContentExists = google.GetDomainApexContent(clctrl.DomainName)
+	case "digitalocean":
+		apexContentExists = digitalocean.GetDomainApexContent(clctrl.DomainName)
+	case "vultr", "k3s":
+		apexContentExists = vultr.GetDomainApexContent(clctrl.DomainName)
+	default:
+		apexContentExists = civo.GetDomainApexContent(clctrl.DomainName)
+	}
+
+	gitopsTokens, ok := clctrl.CreateTokens("gitops").(*providerConfigs.GitopsDirectoryValues)
+	if !ok {
+		return fmt.Errorf("error creating gitops tokens for %s", clctrl.ClusterName)
+	}
+
+	err := providerConfigs.PrepareGitRepositories(
+		clctrl.CloudProvider,
+		clctrl.GitProvider,
+		clctrl.ClusterName,
+		clctrl.ClusterType,
+		clctrl.ProviderConfig.DestinationGitopsRepoURL,
+		clctrl.ProviderConfig.GitopsDir,
+		clctrl.GitopsTemplateBranch,
+		clctrl.GitopsTemplateURL,
+		clctrl.ProviderConfig.DestinationMetaphorRepoURL,
+		clctrl.ProviderConfig.K1Dir,
+		gitopsTokens,
+		clctrl.ProviderConfig.MetaphorDir,
+		clctrl.CreateTokens("metaphor").(*providerConfigs.MetaphorTokenValues),
+		apexContentExists,
+		clctrl.GitProtocol,
+		clctrl.CloudflareAuth.OriginCaIssuerKey != "",
+	)
+	if err != nil {
+		return fmt.Errorf("error rendering git repositories for %s: %w", clctrl.CloudProvider, err)
+	}
+
+	return nil
+}
+
+// planCloudTerraform runs terraform plan for the cloud terraform of the
+// rendered gitops repository
+func (clctrl *ClusterController) planCloudTerraform(workDir string) (*types.TerraformPlanSummary, error) {
+	terraformClient, err := clctrl.terraformClient(workDir)
+	if err != nil {
+		return nil, err
+	}
+
+	tfEntrypoint := filepath.Join(clctrl.ProviderConfig.GitopsDir, "terraform", clctrl.CloudProvider)
+	if err := os.WriteFile(filepath.Join(tfEntrypoint, "kubefirst_dry_run_override.tf"), []byte(dryRunBackendOverride), 0o644); err != nil {
+		return nil, fmt.Errorf("error writing terraform backend override: %w", err)
+	}
+
+	tfEnvs, err := clctrl.workDirTerraformEnvs(workDir)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := terraformext.InitPlanContext(clctrl.Context, terraformClient, tfEntrypoint, tfEnvs)
+	if err != nil {
+		return nil, fmt.Errorf("error planning %s resources: %w", clctrl.CloudProvider, err)
+	}
+
+	return plan, nil
+}
+
+// terraformClient returns the terraform binary downloaded during create, or
+// downloads one into workDir when it is not there
+func (clctrl *ClusterController) terraformClient(workDir string) (string, error) {
+	terraformClient := clctrl.ProviderConfig.TerraformClient
+	if _, err := os.Stat(terraformClient); err == nil {
+		return terraformClient, nil
+	}
+
+	clctrl.logger().Info().Msgf("terraform not found at %s, downloading", terraformClient)
+
+	toolsDir := filepath.Join(workDir, "tools")
+	err := utils.DownloadTools(
+		filepath.Join(toolsDir, "kubectl"),
+		providerConfigs.KubectlClientVersion,
+		providerConfigs.LocalhostOS,
+		providerConfigs.LocalhostArch,
+		providerConfigs.TerraformClientVersion,
+		toolsDir,
+	)
+	if err != nil {
+		return "", fmt.Errorf("error downloading terraform: %w", err)
+	}
+
+	return filepath.Join(toolsDir, "terraform"), nil
+}
+
+// workDirTerraformEnvs returns the cloud terraform environment for a run
+// outside the k1 directory. Google credentials are written into workDir.
+func (clctrl *ClusterController) workDirTerraformEnvs(workDir string) (map[string]string, error) {
+	tfEnvs, err := clctrl.cloudTerraformEnvs(&clctrl.Cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	if clctrl.CloudProvider == "google" {
+		credentialsFile := filepath.Join(workDir, "application-default-credentials.json")
+		if err := os.WriteFile(credentialsFile, []byte(clctrl.GoogleAuth.KeyFile), 0o600); err != nil {
+			return nil, fmt.Errorf("error writing google application credentials: %w", err)
+		}
+		tfEnvs["GOOGLE_APPLICATION_CREDENTIALS"] = credentialsFile
+	}
+
+	return tfEnvs, nil
+}
@@ -21,12 +21,16 @@ import (
 	gitShim "github.com/konstructio/kubefirst-api/internal/gitShim"
 	"github.com/konstructio/kubefirst-api/internal/gitlab"
 	"github.com/konstructio/kubefirst-api/internal/secrets"
+	"github.com/konstructio/kubefirst-api/pkg/types"
 	"github.com/kubefirst/metrics-client/pkg/telemetry"
-	log "github.com/rs/zerolog/log"
 )
 
 // GitInit
 func (clctrl *ClusterController) GitInit() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
 	cl, err := secrets.GetCluster(clctrl.KubernetesClient, clctrl.ClusterName)
 	if err != nil {
 		return fmt.Errorf("failed to get cluster: %w", err)
@@ -49,8 +53,9 @@ func (clctrl *ClusterController) GitInit() error {
 			return fmt.Errorf("failed to initialize git provider: %w", err)
 		}
 
-		clctrl.Cluster.GitInitCheck = true
-		err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+		err = clctrl.updateCluster(func(cluster *types.Cluster) {
+			cluster.GitInitCheck = true
+		})
 		if err != nil {
 			return fmt.Errorf("failed to update cluster after git initialization: %w", err)
 		}
@@ -61,6 +66,10 @@ func (clctrl *ClusterController) GitInit() error {
 
 // RunGitTerraform
 func (clctrl *ClusterController) RunGitTerraform() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
 	cl, err := secrets.GetCluster(clctrl.KubernetesClient, clctrl.ClusterName)
 	if err != nil {
 		return fmt.Errorf("failed to get cluster for terraform execution: %w", err)
@@ -70,68 +79,35 @@ func (clctrl *ClusterController) RunGitTerraform() error {
 
 	telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.GitTerraformApplyStarted, "")
 
-	log.Info().Msgf("Creating %s resources with terraform", clctrl.GitProvider)
+	clctrl.logger().Info().Msgf("Creating %s resources with terraform", clctrl.GitProvider)
 
 	tfEntrypoint := clctrl.ProviderConfig.GitopsDir + fmt.Sprintf("/terraform/%s", clctrl.GitProvider)
-	tfEnvs := map[string]string{}
-
 	if !cl.GitTerraformApplyCheck {
-		switch clctrl.GitProvider {
-		case "github":
-			switch clctrl.CloudProvider {
-			case "akamai":
-				tfEnvs = akamaiext.GetGithubTerraformEnvs(tfEnvs, cl)
-			case "aws":
-				tfEnvs = awsext.GetGithubTerraformEnvs(tfEnvs, cl)
-			case "civo":
-				tfEnvs = civoext.GetGithubTerraformEnvs(tfEnvs, cl)
-			case "google":
-				tfEnvs = googleext.GetGithubTerraformEnvs(tfEnvs, cl)
-			case "digitalocean":
-				tfEnvs = digitaloceanext.GetGithubTerraformEnvs(tfEnvs, cl)
-			case "vultr":
-				tfEnvs = vultrext.GetGithubTerraformEnvs(tfEnvs, cl)
-			case "k3s":
-				tfEnvs = k3sext.GetGithubTerraformEnvs(tfEnvs, cl)
-			}
-		case "gitlab":
-			switch clctrl.CloudProvider {
-			case "akamai":
-				tfEnvs = akamaiext.GetGitlabTerraformEnvs(tfEnvs, clctrl.GitlabOwnerGroupID, cl)
-			case "aws":
-				tfEnvs = awsext.GetGitlabTerraformEnvs(tfEnvs, clctrl.GitlabOwnerGroupID, cl)
-			case "civo":
-				tfEnvs = civoext.GetGitlabTerraformEnvs(tfEnvs, clctrl.GitlabOwnerGroupID, cl)
-			case "google":
-				tfEnvs = googleext.GetGitlabTerraformEnvs(tfEnvs, clctrl.GitlabOwnerGroupID, cl)
-			case "digitalocean":
-				tfEnvs = digitaloceanext.GetGitlabTerraformEnvs(tfEnvs, clctrl.GitlabOwnerGroupID, cl)
-			case "vultr":
-				tfEnvs = vultrext.GetGitlabTerraformEnvs(tfEnvs, clctrl.GitlabOwnerGroupID, cl)
-			case "k3s":
-				tfEnvs = k3sext.GetGitlabTerraformEnvs(tfEnvs, clctrl.GitlabOwnerGroupID, cl)
-			}
-		}
+		tfEnvs := clctrl.gitTerraformEnvs(cl)
 
-		err := terraformext.InitApplyAutoApprove(clctrl.ProviderConfig.TerraformClient, tfEntrypoint, tfEnvs)
+		err := terraformext.InitApplyAutoApproveContext(clctrl.Context, clctrl.ProviderConfig.TerraformClient, tfEntrypoint, tfEnvs)
 		if err != nil {
-			log.Error().Msgf("error applying git terraform: %s", err)
-			log.Info().Msg("sleeping 10 seconds before retrying terraform execution once more")
+			clctrl.logger().Error().Msgf("error applying git terraform: %s", err)
+			if err := clctrl.checkCancelled(); err != nil {
+				return err
+			}
+			clctrl.logger().Info().Msg("sleeping 10 seconds before retrying terraform execution once more")
 			time.Sleep(10 * time.Second)
-			err = terraformext.InitApplyAutoApprove(clctrl.ProviderConfig.TerraformClient, tfEntrypoint, tfEnvs)
+			err = terraformext.InitApplyAutoApproveContext(clctrl.Context, clctrl.ProviderConfig.TerraformClient, tfEntrypoint, tfEnvs)
 			if err != nil {
 				msg := fmt.Sprintf("error creating %s resources with terraform %s: %s", clctrl.GitProvider, tfEntrypoint, err)
-				log.Error().Msg(msg)
+				clctrl.logger().Error().Msg(msg)
 				telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.GitTerraformApplyFailed, err.Error())
 				return fmt.Errorf("failed to apply terraform for git resources: %q", msg)
 			}
 		}
 
-		log.Info().Msgf("created git projects and groups for %s.com/%s", clctrl.GitProvider, clctrl.GitAuth.Owner)
+		clctrl.logger().Info().Msgf("created git projects and groups for %s.com/%s", clctrl.GitProvider, clctrl.GitAuth.Owner)
 		telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.GitTerraformApplyCompleted, "")
 
-		clctrl.Cluster.GitTerraformApplyCheck = true
-		err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+		err = clctrl.updateCluster(func(cluster *types.Cluster) {
+			cluster.GitTerraformApplyCheck = true
+		})
 		if err != nil {
 			return fmt.Errorf("failed to update cluster after terraform application: %w", err)
 		}
@@ -140,6 +116,51 @@ func (clctrl *ClusterController) RunGitTerraform() error {
 	return nil
 }
 
+// gitTerraformEnvs returns the environment for the terraform that manages the
+// git provider teams and repositories
+func (clctrl *ClusterController) gitTerraformEnvs(cl *types.Cluster) map[string]string {
+	tfEnvs := map[string]string{}
+
+	switch clctrl.GitProvider {
+	case "github":
+		switch clctrl.CloudProvider {
+		case "akamai":
+			tfEnvs = akamaiext.GetGithubTerraformEnvs(tfEnvs, cl)
+		case "aws":
+			tfEnvs = awsext.GetGithubTerraformEnvs(tfEnvs, cl)
+		case "civo":
+			tfEnvs = civoext.GetGithubTerraformEnvs(tfEnvs, cl)
+		case "google":
+			tfEnvs = googleext.GetGithubTerraformEnvs(tfEnvs, cl)
+		case "digitalocean":
+			tfEnvs = digitaloceanext.GetGithubTerraformEnvs(tfEnvs, cl)
+		case "vultr":
+			tfEnvs = vultrext.GetGithubTerraformEnvs(tfEnvs, cl)
+		case "k3s":
+			tfEnvs = k3sext.GetGithubTerraformEnvs(tfEnvs, cl)
+		}
+	case "gitlab":
+		switch clctrl.CloudProvider {
+		case "akamai":
+			tfEnvs = akamaiext.GetGitlabTerraformEnvs(tfEnvs, clctrl.GitlabOwnerGroupID, cl)
+		case "aws":
+			tfEnvs = awsext.GetGitlabTerraformEnvs(tfEnvs, clctrl.GitlabOwnerGroupID, cl)
+		case "civo":
+			tfEnvs = civoext.GetGitlabTerraformEnvs(tfEnvs, clctrl.GitlabOwnerGroupID, cl)
+		case "google":
+			tfEnvs = googleext.GetGitlabTerraformEnvs(tfEnvs, clctrl.GitlabOwnerGroupID, cl)
+		case "digitalocean":
+			tfEnvs = digitaloceanext.GetGitlabTerraformEnvs(tfEnvs, clctrl.GitlabOwnerGroupID, cl)
+		case "vultr":
+			tfEnvs = vultrext.GetGitlabTerraformEnvs(tfEnvs, clctrl.GitlabOwnerGroupID, cl)
+		case "k3s":
+			tfEnvs = k3sext.GetGitlabTerraformEnvs(tfEnvs, clctrl.GitlabOwnerGroupID, cl)
+		}
+	}
+
+	return tfEnvs
+}
+
 func (clctrl *ClusterController) GetRepoURL() (string, error) {
 	// default case is https
 	destinationGitopsRepoURL := clctrl.ProviderConfig.DestinationGitopsRepoURL
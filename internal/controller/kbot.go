@@ -10,13 +10,17 @@ import (
 	"fmt"
 
 	"github.com/konstructio/kubefirst-api/internal/secrets"
+	"github.com/konstructio/kubefirst-api/pkg/types"
 	pkg "github.com/konstructio/kubefirst-api/pkg/utils"
 	"github.com/kubefirst/metrics-client/pkg/telemetry"
-	log "github.com/rs/zerolog/log"
 )
 
 // InitializeBot
 func (clctrl *ClusterController) InitializeBot() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
 	cl, err := secrets.GetCluster(clctrl.KubernetesClient, clctrl.ClusterName)
 	if err != nil {
 		return fmt.Errorf("failed to get cluster: %w", err)
@@ -25,16 +29,16 @@ func (clctrl *ClusterController) InitializeBot() error {
 	if !cl.KbotSetupCheck {
 		clctrl.GitAuth.PrivateKey, clctrl.GitAuth.PublicKey, err = pkg.CreateSSHKeyPair()
 		if err != nil {
-			log.Error().Msgf("error generating ssh keys: %s", err)
+			clctrl.logger().Error().Msgf("error generating ssh keys: %s", err)
 			telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.KbotSetupFailed, err.Error())
 			return fmt.Errorf("failed to generate SSH key pair: %w", err)
 		}
 
-		clctrl.Cluster.GitAuth.PublicKey = clctrl.GitAuth.PublicKey
-		clctrl.Cluster.GitAuth.PrivateKey = clctrl.GitAuth.PrivateKey
-		clctrl.Cluster.KbotSetupCheck = true
-
-		err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+		err = clctrl.updateCluster(func(cluster *types.Cluster) {
+			cluster.GitAuth.PublicKey = clctrl.GitAuth.PublicKey
+			cluster.GitAuth.PrivateKey = clctrl.GitAuth.PrivateKey
+			cluster.KbotSetupCheck = true
+		})
 		if err != nil {
 			return fmt.Errorf("failed to update cluster: %w", err)
 		}
@@ -13,11 +13,15 @@ import (
 	githttps "github.com/go-git/go-git/v5/plumbing/transport/http"
 	pkg "github.com/konstructio/kubefirst-api/internal"
 	"github.com/konstructio/kubefirst-api/internal/gitClient"
-	"github.com/konstructio/kubefirst-api/internal/secrets"
+	"github.com/konstructio/kubefirst-api/pkg/types"
 )
 
 // DetokenizeKMSKeyID
 func (clctrl *ClusterController) DetokenizeKMSKeyID() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
 	cl, err := clctrl.GetCurrentClusterRecord()
 	if err != nil {
 		return fmt.Errorf("failed to get current cluster record: %w", err)
@@ -38,8 +42,9 @@ func (clctrl *ClusterController) DetokenizeKMSKeyID() error {
 			return fmt.Errorf("failed to get KMS key ID: %w", err)
 		}
 
-		clctrl.Cluster.AWSKMSKeyID = awsKmsKeyID
-		err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+		err = clctrl.updateCluster(func(cluster *types.Cluster) {
+			cluster.AWSKMSKeyID = awsKmsKeyID
+		})
 		if err != nil {
 			return fmt.Errorf("failed to update cluster with KMS key ID: %w", err)
 		}
@@ -85,8 +90,9 @@ func (clctrl *ClusterController) DetokenizeKMSKeyID() error {
 			return fmt.Errorf("failed to push changes to repository: %w", err)
 		}
 
-		clctrl.Cluster.AWSKMSKeyDetokenizedCheck = true
-		err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+		err = clctrl.updateCluster(func(cluster *types.Cluster) {
+			cluster.AWSKMSKeyDetokenizedCheck = true
+		})
 		if err != nil {
 			return fmt.Errorf("failed to update cluster after detokenizing KMS key: %w", err)
 		}
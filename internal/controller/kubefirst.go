@@ -22,6 +22,7 @@ import (
 	"github.com/konstructio/kubefirst-api/internal/httpCommon"
 	"github.com/konstructio/kubefirst-api/internal/k8s"
 	"github.com/konstructio/kubefirst-api/internal/secrets"
+	"github.com/konstructio/kubefirst-api/internal/services"
 	"github.com/konstructio/kubefirst-api/pkg/types"
 	log "github.com/rs/zerolog/log"
 	v1secret "k8s.io/api/core/v1"
@@ -52,9 +53,13 @@ func ReadKubefirstAPITokenFromSecret(clientset kubernetes.Interface) (string, er
 // ExportClusterRecord will export cluster record to mgmt cluster
 // To be intiated by cluster 0
 func (clctrl *ClusterController) ExportClusterRecord() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
 	cluster, err := secrets.GetCluster(clctrl.KubernetesClient, clctrl.ClusterName)
 	if err != nil {
-		log.Error().Msgf("Error exporting cluster record: %s", err)
+		clctrl.logger().Error().Msgf("Error exporting cluster record: %s", err)
 		clctrl.UpdateClusterOnError(err.Error())
 		return fmt.Errorf("error exporting cluster record: %w", err)
 	}
@@ -103,6 +108,24 @@ func (clctrl *ClusterController) ExportClusterRecord() error {
 	return nil
 }
 
+// AddDefaultServices creates the default service entries for the cluster
+func (clctrl *ClusterController) AddDefaultServices() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
+	cl, err := secrets.GetCluster(clctrl.KubernetesClient, clctrl.ClusterName)
+	if err != nil {
+		return fmt.Errorf("error getting cluster %s: %w", clctrl.ClusterName, err)
+	}
+
+	if err := services.AddDefaultServices(cl); err != nil {
+		return fmt.Errorf("error adding default service entries for cluster %s: %w", cl.ClusterName, err)
+	}
+
+	return nil
+}
+
 // ExportClusterRecord will export cluster record to mgmt cluster
 func (clctrl *ClusterController) CreateVirtualClusters() error {
 	time.Sleep(time.Minute * 2)
@@ -122,7 +145,7 @@ func (clctrl *ClusterController) CreateVirtualClusters() error {
 
 	err := pkg.IsAppAvailable(fmt.Sprintf("%s/api/proxyHealth", consoleCloudURL), "kubefirst api")
 	if err != nil {
-		log.Error().Msgf("unable to wait for kubefirst console: %s", err)
+		clctrl.logger().Error().Msgf("unable to wait for kubefirst console: %s", err)
 		clctrl.UpdateClusterOnError(err.Error())
 		return fmt.Errorf("unable to wait for kubefirst console: %w", err)
 	}
@@ -138,7 +161,7 @@ func (clctrl *ClusterController) CreateVirtualClusters() error {
 
 	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/api/proxy", consoleCloudURL), bytes.NewReader(payload))
 	if err != nil {
-		log.Error().Msgf("unable to create default clusters: %s", err)
+		clctrl.logger().Error().Msgf("unable to create default clusters: %s", err)
 		clctrl.UpdateClusterOnError(err.Error())
 		return fmt.Errorf("unable to create default clusters: %w", err)
 	}
@@ -148,7 +171,7 @@ func (clctrl *ClusterController) CreateVirtualClusters() error {
 
 	res, err := httpCommon.CustomHTTPClient(true).Do(req)
 	if err != nil {
-		log.Error().Msgf("unable to create default clusters: %s", err)
+		clctrl.logger().Error().Msgf("unable to create default clusters: %s", err)
 		clctrl.UpdateClusterOnError(err.Error())
 		return fmt.Errorf("unable to create default clusters: %w", err)
 	}
@@ -161,11 +184,11 @@ func (clctrl *ClusterController) CreateVirtualClusters() error {
 
 	if res.StatusCode != http.StatusOK {
 		e := fmt.Errorf("unable to create default clusters, API responded non-200 status: %s: %s", res.Status, string(body))
-		log.Error().Msg(e.Error())
+		clctrl.logger().Error().Msg(e.Error())
 		clctrl.UpdateClusterOnError(e.Error())
 		return e
 	}
 
-	log.Info().Msg("cluster creation complete")
+	clctrl.logger().Info().Msg("cluster creation complete")
 	return nil
 }
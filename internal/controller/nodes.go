@@ -0,0 +1,344 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	gitConfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	githttps "github.com/go-git/go-git/v5/plumbing/transport/http"
+	terraformext "github.com/konstructio/kubefirst-api/extensions/terraform"
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/gitClient"
+	"github.com/konstructio/kubefirst-api/internal/gitShim"
+	"github.com/konstructio/kubefirst-api/internal/github"
+	"github.com/konstructio/kubefirst-api/internal/gitlab"
+	"github.com/konstructio/kubefirst-api/internal/secrets"
+	"github.com/konstructio/kubefirst-api/pkg/types"
+)
+
+var (
+	// nodeTypeVar matches the detokenized node type in the cloud terraform,
+	// which is named instance_type for aws and google
+	nodeTypeVar = regexp.MustCompile(`^(\s*(?:node_type|instance_type)\s*=\s*)"[^"<>]*"`)
+	// nodeCountVar matches the detokenized node count in the cloud terraform
+	nodeCountVar = regexp.MustCompile(`^(\s*node_count\s*=\s*)\d+`)
+)
+
+// ValidateNodesRequest checks a node change against an existing cluster. A
+// new node type must be one of the instance sizes offered in the cluster region.
+func ValidateNodesRequest(ctx context.Context, cl *types.Cluster, req *types.ClusterNodesRequest) error {
+	switch req.ApplyMode {
+	case "", constants.NodesApplyModeTerraform, constants.NodesApplyModeAtlantis:
+	default:
+		return fmt.Errorf("apply_mode must be %q or %q", constants.NodesApplyModeTerraform, constants.NodesApplyModeAtlantis)
+	}
+
+	switch {
+	case cl.CloudProvider == "k3s":
+		return errors.New("k3s nodes are provided by the user and cannot be changed through kubefirst")
+	case req.NodeCount < 0:
+		return errors.New("node_count must be positive")
+	case req.NodeType == "" && req.NodeCount == 0:
+		return errors.New("one of node_type or node_count must be provided")
+	case (req.NodeType == "" || req.NodeType == cl.NodeType) && (req.NodeCount == 0 || req.NodeCount == cl.NodeCount):
+		return fmt.Errorf("cluster %q already runs %d %s nodes", cl.ClusterName, cl.NodeCount, cl.NodeType)
+	}
+
+	if req.NodeType == "" {
+		return nil
+	}
+
+	clctrl, err := clusterController(ctx, cl)
+	if err != nil {
+		return err
+	}
+	clctrl.NodeType = req.NodeType
+
+	if finding := clctrl.validateNodeType(); finding.Status != constants.CheckStatusPass {
+		return errors.New(finding.Message)
+	}
+
+	return nil
+}
+
+// UpdateNodes changes the node count and node type of a provisioned cluster.
+// The detokenized terraform variables in the gitops repository are updated and
+// either applied right away or proposed in a pull request for Atlantis. An
+// applied change is only pushed to the gitops repository once terraform succeeds.
+func (clctrl *ClusterController) UpdateNodes(req *types.ClusterNodesRequest) error {
+	nodeType := clctrl.Cluster.NodeType
+	if req.NodeType != "" {
+		nodeType = req.NodeType
+	}
+	nodeCount := clctrl.Cluster.NodeCount
+	if req.NodeCount != 0 {
+		nodeCount = req.NodeCount
+	}
+
+	workDir, err := os.MkdirTemp("", fmt.Sprintf("kubefirst-nodes-%s-", clctrl.ClusterName))
+	if err != nil {
+		return fmt.Errorf("error creating working directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	gitopsRepo, tfEntrypoint, err := clctrl.cloneGitops(workDir)
+	if err != nil {
+		return err
+	}
+
+	if err := UpdateNodeTerraformVars(tfEntrypoint, nodeType, nodeCount); err != nil {
+		return err
+	}
+
+	commitMsg := fmt.Sprintf("updating cluster %s to %d %s nodes", clctrl.ClusterName, nodeCount, nodeType)
+
+	if req.ApplyMode == constants.NodesApplyModeAtlantis {
+		return clctrl.proposeNodeChange(gitopsRepo, commitMsg)
+	}
+
+	if err := clctrl.applyAndPushCloudTerraform(gitopsRepo, workDir, tfEntrypoint, commitMsg); err != nil {
+		return err
+	}
+
+	// The record may have changed during the apply, so only the node
+	// settings are written to its latest version
+	updated, err := secrets.UpdateClusterWith(clctrl.KubernetesClient, clctrl.ClusterName, func(cl *types.Cluster) error {
+		cl.NodeType = nodeType
+		cl.NodeCount = nodeCount
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error updating cluster nodes: %w", err)
+	}
+	clctrl.Cluster = *updated
+
+	clctrl.logger().Info().Msgf("cluster %s now runs %d %s nodes", clctrl.ClusterName, nodeCount, nodeType)
+	return nil
+}
+
+// cloneGitops clones the gitops repository of the cluster into workDir and
+// returns it along with the path of its cloud terraform
+func (clctrl *ClusterController) cloneGitops(workDir string) (*git.Repository, string, error) {
+	gitopsDir := filepath.Join(workDir, "gitops")
+	if err := gitShim.PrepareGitEnvironment(&clctrl.Cluster, gitopsDir); err != nil {
+		return nil, "", fmt.Errorf("error cloning gitops repository: %w", err)
+	}
+
+	gitopsRepo, err := git.PlainOpen(gitopsDir)
+	if err != nil {
+		return nil, "", fmt.Errorf("error opening gitops repository: %w", err)
+	}
+
+	return gitopsRepo, filepath.Join(gitopsDir, "terraform", clctrl.CloudProvider), nil
+}
+
+func (clctrl *ClusterController) gitopsAuth() *githttps.BasicAuth {
+	return &githttps.BasicAuth{
+		Username: clctrl.Cluster.GitAuth.User,
+		Password: clctrl.Cluster.GitAuth.Token,
+	}
+}
+
+// gitopsBranch returns the branch checked out in a fresh gitops clone, which
+// is the default branch of the repository
+func gitopsBranch(gitopsRepo *git.Repository) (string, error) {
+	head, err := gitopsRepo.Head()
+	if err != nil {
+		return "", fmt.Errorf("error reading gitops repository head: %w", err)
+	}
+	if !head.Name().IsBranch() {
+		return "", fmt.Errorf("gitops repository head %s is not a branch", head.Name())
+	}
+	return head.Name().Short(), nil
+}
+
+// applyAndPushCloudTerraform commits the changes made to a gitops clone,
+// applies the cloud terraform from the clone and pushes the commit to the
+// default branch once the apply succeeded, so a failed change never lands there
+func (clctrl *ClusterController) applyAndPushCloudTerraform(gitopsRepo *git.Repository, workDir, tfEntrypoint, commitMsg string) error {
+	branch, err := gitopsBranch(gitopsRepo)
+	if err != nil {
+		return err
+	}
+
+	if err := gitClient.Commit(gitopsRepo, commitMsg); err != nil {
+		return fmt.Errorf("error committing terraform change: %w", err)
+	}
+
+	terraformClient, err := clctrl.terraformClient(workDir)
+	if err != nil {
+		return err
+	}
+
+	tfEnvs, err := clctrl.workDirTerraformEnvs(workDir)
+	if err != nil {
+		return err
+	}
+
+	clctrl.logger().Info().Msgf("applying %s terraform for cluster %s", clctrl.CloudProvider, clctrl.ClusterName)
+	if err := terraformext.InitApplyAutoApproveContext(clctrl.Context, terraformClient, tfEntrypoint, tfEnvs); err != nil {
+		return fmt.Errorf("error applying %s terraform: %w", clctrl.CloudProvider, err)
+	}
+
+	refSpec := gitConfig.RefSpec(fmt.Sprintf("refs/heads/%[1]s:refs/heads/%[1]s", branch))
+	err = gitopsRepo.Push(&git.PushOptions{
+		RemoteName: "origin",
+		RefSpecs:   []gitConfig.RefSpec{refSpec},
+		Auth:       clctrl.gitopsAuth(),
+	})
+	if err != nil {
+		return fmt.Errorf("terraform change was applied but could not be pushed to %s: %w", branch, err)
+	}
+
+	return nil
+}
+
+// proposeNodeChange pushes the node change to a new branch and opens a pull
+// request for it, leaving the plan and apply to Atlantis. The cluster record
+// keeps its node settings until the change is applied.
+func (clctrl *ClusterController) proposeNodeChange(gitopsRepo *git.Repository, commitMsg string) error {
+	branchName := fmt.Sprintf("kubefirst-nodes-%d", time.Now().Unix())
+
+	baseBranch, err := gitopsBranch(gitopsRepo)
+	if err != nil {
+		return err
+	}
+
+	w, err := gitopsRepo.Worktree()
+	if err != nil {
+		return fmt.Errorf("error getting worktree: %w", err)
+	}
+	err = w.Checkout(&git.CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName(branchName),
+		Create: true,
+		Keep:   true,
+	})
+	if err != nil {
+		return fmt.Errorf("error creating branch %q: %w", branchName, err)
+	}
+
+	if err := gitClient.Commit(gitopsRepo, commitMsg); err != nil {
+		return fmt.Errorf("error committing node change: %w", err)
+	}
+
+	refSpec := gitConfig.RefSpec(fmt.Sprintf("refs/heads/%[1]s:refs/heads/%[1]s", branchName))
+	err = gitopsRepo.Push(&git.PushOptions{
+		RemoteName: "origin",
+		RefSpecs:   []gitConfig.RefSpec{refSpec},
+		Auth:       clctrl.gitopsAuth(),
+	})
+	if err != nil {
+		return fmt.Errorf("error pushing branch %q: %w", branchName, err)
+	}
+
+	body := "Opened by the kubefirst api. Atlantis will plan this change, comment `atlantis apply` to roll it out."
+
+	var url string
+	switch clctrl.Cluster.GitProvider {
+	case "github":
+		pr, err := github.New(clctrl.Cluster.GitAuth.Token).CreatePR(branchName, "gitops", clctrl.Cluster.GitAuth.Owner, baseBranch, commitMsg, body)
+		if err != nil {
+			return fmt.Errorf("error opening pull request: %w", err)
+		}
+		url = pr.GetHTMLURL()
+	case "gitlab":
+		gitlabClient, err := gitlab.NewGitLabClient(clctrl.Cluster.GitAuth.Token, clctrl.Cluster.GitAuth.Owner)
+		if err != nil {
+			return fmt.Errorf("error creating gitlab client: %w", err)
+		}
+		url, err = gitlabClient.CreateMergeRequest("gitops", branchName, baseBranch, commitMsg, body)
+		if err != nil {
+			return fmt.Errorf("error opening merge request: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported git provider %q", clctrl.Cluster.GitProvider)
+	}
+
+	clctrl.logger().Info().Msgf("node change for cluster %s is waiting for atlantis at %s", clctrl.ClusterName, url)
+	return nil
+}
+
+// UpdateNodeTerraformVars sets the node type and node count of the node pool
+// in the terraform files at the top of tfEntrypoint. The node pool is the
+// first block assigning both a node type and a node count, so the same names
+// in other blocks, such as autoscaler settings or additional pools, are left
+// alone. Modules and templates below tfEntrypoint only reference variables.
+func UpdateNodeTerraformVars(tfEntrypoint, nodeType string, nodeCount int) error {
+	files, err := filepath.Glob(filepath.Join(tfEntrypoint, "*.tf"))
+	if err != nil {
+		return fmt.Errorf("error listing terraform files: %w", err)
+	}
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", file, err)
+		}
+
+		lines := strings.Split(string(content), "\n")
+		typeLine, countLine, found := nodePoolLines(lines)
+		if !found {
+			continue
+		}
+
+		lines[typeLine] = nodeTypeVar.ReplaceAllString(lines[typeLine], fmt.Sprintf(`${1}%q`, nodeType))
+		lines[countLine] = nodeCountVar.ReplaceAllString(lines[countLine], "${1}"+strconv.Itoa(nodeCount))
+
+		if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+			return fmt.Errorf("error writing %s: %w", file, err)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("no node pool with node type and node count variables found in %s", tfEntrypoint)
+}
+
+// nodePoolLines returns the lines of the node type and node count of the
+// first block that assigns both
+func nodePoolLines(lines []string) (int, int, bool) {
+	type block struct{ typeLine, countLine int }
+	stack := []block{{-1, -1}}
+
+	for i, line := range lines {
+		top := &stack[len(stack)-1]
+		switch {
+		case nodeTypeVar.MatchString(line) && top.typeLine < 0:
+			top.typeLine = i
+		case nodeCountVar.MatchString(line) && top.countLine < 0:
+			top.countLine = i
+		}
+		if top.typeLine >= 0 && top.countLine >= 0 {
+			return top.typeLine, top.countLine, true
+		}
+
+		inString := false
+		for j, r := range line {
+			switch {
+			case r == '"' && (j == 0 || line[j-1] != '\\'):
+				inString = !inString
+			case inString:
+			case r == '{':
+				stack = append(stack, block{-1, -1})
+			case r == '}' && len(stack) > 1:
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	return 0, 0, false
+}
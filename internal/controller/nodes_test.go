@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/konstructio/kubefirst-api/pkg/types"
+)
+
+func TestUpdateNodeTerraformVars(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		expected  string
+		expectErr bool
+	}{
+		{
+			name: "node type and count",
+			content: `module "mgmt" {
+  node_count = 4
+  node_type  = "g4s.kube.large"
+}
+`,
+			expected: `module "mgmt" {
+  node_count = 6
+  node_type  = "g4s.kube.xlarge"
+}
+`,
+		},
+		{
+			name: "instance type",
+			content: `module "eks" {
+  instance_type = "g4s.kube.large"
+  node_count    = 4
+}
+`,
+			expected: `module "eks" {
+  instance_type = "g4s.kube.xlarge"
+  node_count    = 6
+}
+`,
+		},
+		{
+			name: "only the node pool is changed",
+			content: `locals {
+  node_count = 10
+}
+
+module "mgmt" {
+  node_count = 4
+  node_type  = "g4s.kube.large"
+  labels     = { "pool" = "${local.name}" }
+}
+
+resource "civo_kubernetes_node_pool" "workload" {
+  node_count = 2
+  node_type  = "g4s.kube.small"
+}
+`,
+			expected: `locals {
+  node_count = 10
+}
+
+module "mgmt" {
+  node_count = 6
+  node_type  = "g4s.kube.xlarge"
+  labels     = { "pool" = "${local.name}" }
+}
+
+resource "civo_kubernetes_node_pool" "workload" {
+  node_count = 2
+  node_type  = "g4s.kube.small"
+}
+`,
+		},
+		{
+			name: "variable references are left alone",
+			content: `module "mgmt" {
+  node_count = var.node_count
+  node_type  = "<WORKLOAD_NODE_TYPE>"
+}
+`,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			file := filepath.Join(dir, "main.tf")
+			if err := os.WriteFile(file, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := UpdateNodeTerraformVars(dir, "g4s.kube.xlarge", 6)
+			if tt.expectErr {
+				if err == nil {
+					t.Fatal("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			content, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, content)
+			}
+		})
+	}
+}
+
+func TestValidateNodesRequest(t *testing.T) {
+	cluster := &types.Cluster{
+		ClusterName:   "kubefirst",
+		CloudProvider: "civo",
+		NodeType:      "g4s.kube.large",
+		NodeCount:     4,
+	}
+
+	tests := []struct {
+		name    string
+		cluster *types.Cluster
+		request types.ClusterNodesRequest
+	}{
+		{
+			name:    "empty request",
+			cluster: cluster,
+		},
+		{
+			name:    "negative count",
+			cluster: cluster,
+			request: types.ClusterNodesRequest{NodeCount: -1},
+		},
+		{
+			name:    "unchanged",
+			cluster: cluster,
+			request: types.ClusterNodesRequest{NodeType: "g4s.kube.large", NodeCount: 4},
+		},
+		{
+			name:    "unknown apply mode",
+			cluster: cluster,
+			request: types.ClusterNodesRequest{NodeCount: 5, ApplyMode: "manual"},
+		},
+		{
+			name:    "k3s",
+			cluster: &types.Cluster{ClusterName: "kubefirst", CloudProvider: "k3s"},
+			request: types.ClusterNodesRequest{NodeCount: 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateNodesRequest(context.Background(), tt.cluster, &tt.request); err == nil {
+				t.Error("expected an error, got none")
+			}
+		})
+	}
+
+	if err := ValidateNodesRequest(context.Background(), cluster, &types.ClusterNodesRequest{NodeCount: 5}); err != nil {
+		t.Errorf("unexpected error scaling nodes: %s", err)
+	}
+}
@@ -0,0 +1,366 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package controller
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/events"
+	"github.com/konstructio/kubefirst-api/internal/metrics"
+	"github.com/konstructio/kubefirst-api/internal/tracing"
+	"github.com/konstructio/kubefirst-api/pkg/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Cluster create step names
+const (
+	StepCheckAvailabilityZones  = "check_availability_zones"
+	StepWriteGoogleCredentials  = "write_google_credentials"
+	StepDownloadTools           = "download_tools"
+	StepDomainLivenessTest      = "domain_liveness_test"
+	StepStateStoreCredentials   = "state_store_credentials"
+	StepStateStoreCreate        = "state_store_create"
+	StepGitInit                 = "git_init"
+	StepInitializeBot           = "initialize_bot"
+	StepRepositoryPrep          = "repository_prep"
+	StepRunGitTerraform         = "run_git_terraform"
+	StepRepositoryPush          = "repository_push"
+	StepCreateCluster           = "create_cluster"
+	StepDetokenizeKMSKeyID      = "detokenize_kms_key_id"
+	StepWaitForClusterReady     = "wait_for_cluster_ready"
+	StepClusterSecretsBootstrap = "cluster_secrets_bootstrap"
+	StepRestoreSSL              = "restore_ssl"
+	StepInstallArgoCD           = "install_argocd"
+	StepInitializeArgoCD        = "initialize_argocd"
+	StepDeployRegistry          = "deploy_registry_application"
+	StepWaitForVault            = "wait_for_vault"
+	StepInitializeVault         = "initialize_vault"
+	StepOpenVaultPortForward    = "open_vault_port_forward"
+	StepRunVaultTerraform       = "run_vault_terraform"
+	StepWriteVaultSecrets       = "write_vault_secrets"
+	StepRunUsersTerraform       = "run_users_terraform"
+	StepWaitForCrossplane       = "wait_for_crossplane"
+	StepExportClusterRecord     = "export_cluster_record"
+	StepAddDefaultServices      = "add_default_services"
+	StepWaitForKubefirstPro     = "wait_for_kubefirst_pro"
+	StepWaitForArgoCD           = "wait_for_argocd"
+)
+
+// Step is a named unit of work in the cluster create pipeline
+type Step struct {
+	Name string
+	// DependsOn lists the steps that must have completed before this one runs
+	DependsOn []string
+	Run       func(clctrl *ClusterController) error
+}
+
+// StepOverride changes the default pipeline for a single cloud provider
+type StepOverride struct {
+	// Name of the step to change, remove or add
+	Name string
+	// Run replaces the step's function, required when adding a step
+	Run func(clctrl *ClusterController) error
+	// DependsOn replaces the step's dependencies when set
+	DependsOn []string
+	// Before and After move the step, or place a new step, relative to another
+	Before string
+	After  string
+	// Remove drops the step. Steps that depended on it inherit its dependencies.
+	Remove bool
+}
+
+// DefaultSteps returns the cluster create pipeline shared by all cloud providers
+func DefaultSteps() []Step {
+	return []Step{
+		{
+			Name: StepDownloadTools,
+			Run: func(clctrl *ClusterController) error {
+				return clctrl.DownloadTools(clctrl.ProviderConfig.ToolsDir)
+			},
+		},
+		{Name: StepDomainLivenessTest, DependsOn: []string{StepDownloadTools}, Run: (*ClusterController).DomainLivenessTest},
+		{Name: StepStateStoreCredentials, DependsOn: []string{StepDomainLivenessTest}, Run: (*ClusterController).StateStoreCredentials},
+		{Name: StepGitInit, DependsOn: []string{StepStateStoreCredentials}, Run: (*ClusterController).GitInit},
+		{Name: StepInitializeBot, DependsOn: []string{StepGitInit}, Run: (*ClusterController).InitializeBot},
+		{Name: StepRepositoryPrep, DependsOn: []string{StepInitializeBot}, Run: (*ClusterController).RepositoryPrep},
+		{Name: StepRunGitTerraform, DependsOn: []string{StepRepositoryPrep}, Run: (*ClusterController).RunGitTerraform},
+		{Name: StepRepositoryPush, DependsOn: []string{StepRunGitTerraform}, Run: (*ClusterController).RepositoryPush},
+		{Name: StepCreateCluster, DependsOn: []string{StepRepositoryPush}, Run: (*ClusterController).CreateCluster},
+		{Name: StepWaitForClusterReady, DependsOn: []string{StepCreateCluster}, Run: (*ClusterController).WaitForClusterReady},
+		{Name: StepClusterSecretsBootstrap, DependsOn: []string{StepWaitForClusterReady}, Run: (*ClusterController).ClusterSecretsBootstrap},
+		{Name: StepRestoreSSL, DependsOn: []string{StepClusterSecretsBootstrap}, Run: (*ClusterController).RestoreSSL},
+		{Name: StepInstallArgoCD, DependsOn: []string{StepRestoreSSL}, Run: (*ClusterController).InstallArgoCD},
+		{Name: StepInitializeArgoCD, DependsOn: []string{StepInstallArgoCD}, Run: (*ClusterController).InitializeArgoCD},
+		{Name: StepDeployRegistry, DependsOn: []string{StepInitializeArgoCD}, Run: (*ClusterController).DeployRegistryApplication},
+		{Name: StepWaitForVault, DependsOn: []string{StepDeployRegistry}, Run: (*ClusterController).WaitForVault},
+		{Name: StepInitializeVault, DependsOn: []string{StepWaitForVault}, Run: (*ClusterController).InitializeVault},
+		{Name: StepOpenVaultPortForward, DependsOn: []string{StepInitializeVault}, Run: (*ClusterController).OpenVaultPortForward},
+		{Name: StepRunVaultTerraform, DependsOn: []string{StepOpenVaultPortForward}, Run: (*ClusterController).RunVaultTerraform},
+		{Name: StepWriteVaultSecrets, DependsOn: []string{StepRunVaultTerraform}, Run: (*ClusterController).WriteVaultSecrets},
+		{Name: StepRunUsersTerraform, DependsOn: []string{StepWriteVaultSecrets}, Run: (*ClusterController).RunUsersTerraform},
+		{Name: StepWaitForCrossplane, DependsOn: []string{StepRunUsersTerraform}, Run: (*ClusterController).WaitForCrossplane},
+		{Name: StepExportClusterRecord, DependsOn: []string{StepWaitForCrossplane}, Run: (*ClusterController).ExportClusterRecord},
+		{Name: StepAddDefaultServices, DependsOn: []string{StepExportClusterRecord}, Run: (*ClusterController).AddDefaultServices},
+		{Name: StepWaitForKubefirstPro, DependsOn: []string{StepAddDefaultServices}, Run: (*ClusterController).WaitForKubefirstPro},
+		{Name: StepWaitForArgoCD, DependsOn: []string{StepWaitForKubefirstPro}, Run: (*ClusterController).WaitForArgoCD},
+	}
+}
+
+// BuildPipeline applies provider overrides to the default steps and validates
+// that every dependency refers to a step that runs earlier
+func BuildPipeline(overrides []StepOverride) ([]Step, error) {
+	steps := DefaultSteps()
+
+	for _, override := range overrides {
+		idx := stepIndex(steps, override.Name)
+
+		if override.Remove {
+			if idx < 0 {
+				return nil, fmt.Errorf("cannot remove unknown step %q", override.Name)
+			}
+
+			removed := steps[idx]
+			steps = slices.Delete(steps, idx, idx+1)
+			for i := range steps {
+				if slices.Contains(steps[i].DependsOn, removed.Name) {
+					deps := slices.DeleteFunc(slices.Clone(steps[i].DependsOn), func(dep string) bool { return dep == removed.Name })
+					steps[i].DependsOn = append(deps, removed.DependsOn...)
+				}
+			}
+			continue
+		}
+
+		var step Step
+		if idx < 0 {
+			if override.Run == nil {
+				return nil, fmt.Errorf("new step %q has nothing to run", override.Name)
+			}
+			step = Step{Name: override.Name}
+		} else {
+			step = steps[idx]
+		}
+
+		if override.Run != nil {
+			step.Run = override.Run
+		}
+		if override.DependsOn != nil {
+			step.DependsOn = override.DependsOn
+		}
+
+		if override.Before == "" && override.After == "" {
+			if idx < 0 {
+				steps = append(steps, step)
+			} else {
+				steps[idx] = step
+			}
+			continue
+		}
+
+		if idx >= 0 {
+			steps = slices.Delete(steps, idx, idx+1)
+		}
+
+		anchor := override.Before
+		if anchor == "" {
+			anchor = override.After
+		}
+		pos := stepIndex(steps, anchor)
+		if pos < 0 {
+			return nil, fmt.Errorf("cannot place step %q relative to unknown step %q", override.Name, anchor)
+		}
+		if override.After != "" {
+			pos++
+		}
+		steps = slices.Insert(steps, pos, step)
+	}
+
+	seen := map[string]bool{}
+	for _, step := range steps {
+		if seen[step.Name] {
+			return nil, fmt.Errorf("step %q is declared more than once", step.Name)
+		}
+
+		for _, dep := range step.DependsOn {
+			if !seen[dep] {
+				return nil, fmt.Errorf("step %q depends on %q, which does not run before it", step.Name, dep)
+			}
+		}
+
+		seen[step.Name] = true
+	}
+
+	return steps, nil
+}
+
+// RunPipeline runs each step in order, recording its progress on the cluster
+// record, and marks the cluster as provisioned once every step has completed
+func (clctrl *ClusterController) RunPipeline(steps []Step) error {
+	return clctrl.RunSelectedSteps(steps, nil)
+}
+
+// RunSelectedSteps runs the named steps of the pipeline in pipeline order, or
+// every step when selected is nil. The cluster is marked as provisioned only
+// once every step of the pipeline has succeeded.
+func (clctrl *ClusterController) RunSelectedSteps(steps []Step, selected []string) error {
+	defer clctrl.closeVaultPortForward()
+
+	err := clctrl.updateCluster(func(cluster *types.Cluster) {
+		cluster.Steps = stepRecords(steps, cluster)
+	})
+	if err != nil {
+		return fmt.Errorf("error recording pipeline steps: %w", err)
+	}
+
+	for _, step := range steps {
+		if selected != nil && !slices.Contains(selected, step.Name) {
+			continue
+		}
+
+		if err := clctrl.runStep(step); err != nil {
+			clctrl.UpdateClusterOnError(err.Error())
+			return fmt.Errorf("error running step %q: %w", step.Name, err)
+		}
+	}
+
+	err = clctrl.updateCluster(func(cluster *types.Cluster) {
+		cluster.InProgress = false
+		cluster.Status = pipelineStatus(cluster.Steps)
+	})
+	if err != nil {
+		return fmt.Errorf("error updating cluster status: %w", err)
+	}
+
+	return nil
+}
+
+// runStep runs a single step, recording its start, end, attempts and error
+func (clctrl *ClusterController) runStep(step Step) error {
+	if err := clctrl.checkCancelled(); err != nil {
+		clctrl.recordStep(step.Name, func(record *types.ClusterStep) {
+			record.Status = constants.StepStatusCancelled
+		})
+		events.Publish(events.StepFinished(clctrl.ClusterName, step.Name, err))
+		return err
+	}
+
+	clctrl.logger().Info().Msgf("running step %s", step.Name)
+	clctrl.recordStep(step.Name, func(record *types.ClusterStep) {
+		record.Status = constants.StepStatusRunning
+		record.Attempts++
+		record.LastError = ""
+		record.StartedAt = stepTimestamp()
+		record.FinishedAt = ""
+	})
+	events.Publish(events.Event{Type: events.StepStarted, Cluster: clctrl.ClusterName, Step: step.Name})
+
+	// Calls made by the step with clctrl.Context are recorded under its span
+	parent := clctrl.Context
+	ctx, endSpan := tracing.Start(parent, "step "+step.Name,
+		tracing.ClusterKey.String(clctrl.ClusterName),
+		tracing.StepKey.String(step.Name),
+		tracing.ProviderKey.String(clctrl.CloudProvider),
+	)
+	clctrl.Context = ctx
+
+	started := time.Now()
+	err := step.Run(clctrl)
+	duration := time.Since(started)
+
+	clctrl.Context = parent
+	endSpan(err)
+
+	status := constants.StepStatusSucceeded
+	switch {
+	case err != nil && clctrl.Cancelled():
+		status = constants.StepStatusCancelled
+	case err != nil:
+		status = constants.StepStatusFailed
+	}
+	metrics.ObserveStep(clctrl.CloudProvider, step.Name, status, duration, status == constants.StepStatusFailed)
+
+	clctrl.recordStep(step.Name, func(record *types.ClusterStep) {
+		record.FinishedAt = stepTimestamp()
+		record.Status = status
+		if err != nil {
+			record.LastError = err.Error()
+		}
+	})
+	events.Publish(events.StepFinished(clctrl.ClusterName, step.Name, err))
+
+	return err
+}
+
+// recordStep applies update to the named step record and persists the cluster
+func (clctrl *ClusterController) recordStep(name string, update func(record *types.ClusterStep)) {
+	err := clctrl.updateCluster(func(cluster *types.Cluster) {
+		for i := range cluster.Steps {
+			if cluster.Steps[i].Name == name {
+				update(&cluster.Steps[i])
+				break
+			}
+		}
+	})
+	if err != nil {
+		clctrl.logger().Warn().Msgf("error recording progress of step %s: %s", name, err)
+	}
+}
+
+// stepRecords returns a record for every step in the pipeline, keeping the
+// history of steps that have run before. Steps without a record are pending
+// unless their check flag shows they completed before progress was recorded.
+func stepRecords(steps []Step, cl *types.Cluster) []types.ClusterStep {
+	records := make([]types.ClusterStep, 0, len(steps))
+	for _, step := range steps {
+		record := types.ClusterStep{Name: step.Name, Status: constants.StepStatusPending}
+		if check, ok := stepChecks[step.Name]; ok && *check(cl) {
+			record.Status = constants.StepStatusSucceeded
+		}
+
+		for _, prev := range cl.Steps {
+			if prev.Name == step.Name {
+				record = prev
+				break
+			}
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
+
+// pipelineStatus returns the cluster status matching the step records of the
+// create pipeline. A pipeline with steps left to run and none failed or
+// cancelled is still provisioning, waiting for those steps to be retried.
+func pipelineStatus(records []types.ClusterStep) string {
+	status := constants.ClusterStatusProvisioned
+	for _, record := range records {
+		switch record.Status {
+		case constants.StepStatusFailed:
+			return constants.ClusterStatusError
+		case constants.StepStatusCancelled:
+			status = constants.ClusterStatusCancelled
+		case constants.StepStatusSucceeded:
+		default:
+			if status == constants.ClusterStatusProvisioned {
+				status = constants.ClusterStatusProvisioning
+			}
+		}
+	}
+
+	return status
+}
+
+func stepIndex(steps []Step, name string) int {
+	return slices.IndexFunc(steps, func(step Step) bool { return step.Name == name })
+}
+
+func stepTimestamp() string {
+	return fmt.Sprintf("%v", primitive.NewDateTimeFromTime(time.Now().UTC()))
+}
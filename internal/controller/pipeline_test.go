@@ -0,0 +1,206 @@
+package controller
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/secrets"
+	"github.com/konstructio/kubefirst-api/pkg/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func noop(_ *ClusterController) error { return nil }
+
+func TestBuildPipeline(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides []StepOverride
+		wantErr   bool
+		check     func(t *testing.T, steps []Step)
+	}{
+		{
+			name: "default steps are valid",
+			check: func(t *testing.T, steps []Step) {
+				if len(steps) != len(DefaultSteps()) {
+					t.Errorf("expected %d steps, got %d", len(DefaultSteps()), len(steps))
+				}
+			},
+		},
+		{
+			name:      "removed step passes its dependencies on",
+			overrides: []StepOverride{{Name: StepRestoreSSL, Remove: true}},
+			check: func(t *testing.T, steps []Step) {
+				if stepIndex(steps, StepRestoreSSL) >= 0 {
+					t.Fatalf("expected %s to be removed", StepRestoreSSL)
+				}
+
+				install := steps[stepIndex(steps, StepInstallArgoCD)]
+				if !slices.Equal(install.DependsOn, []string{StepClusterSecretsBootstrap}) {
+					t.Errorf("expected %s to depend on %s, got %v", StepInstallArgoCD, StepClusterSecretsBootstrap, install.DependsOn)
+				}
+			},
+		},
+		{
+			name: "new step is inserted after its anchor",
+			overrides: []StepOverride{
+				{Name: "custom", Run: noop, DependsOn: []string{StepCreateCluster}, After: StepCreateCluster},
+			},
+			check: func(t *testing.T, steps []Step) {
+				if stepIndex(steps, "custom") != stepIndex(steps, StepCreateCluster)+1 {
+					t.Errorf("expected custom step directly after %s", StepCreateCluster)
+				}
+			},
+		},
+		{
+			name:      "new step without a function",
+			overrides: []StepOverride{{Name: "custom", After: StepCreateCluster}},
+			wantErr:   true,
+		},
+		{
+			name:      "step moved ahead of its dependency",
+			overrides: []StepOverride{{Name: StepWaitForArgoCD, Before: StepDownloadTools}},
+			wantErr:   true,
+		},
+		{
+			name:      "unknown anchor",
+			overrides: []StepOverride{{Name: "custom", Run: noop, After: "does_not_exist"}},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps, err := BuildPipeline(tt.overrides)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			tt.check(t, steps)
+		})
+	}
+}
+
+func TestRunPipelineRecordsSteps(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+
+	clctrl := &ClusterController{
+		ClusterName:      "kubefirst",
+		KubernetesClient: clientset,
+		Cluster: types.Cluster{
+			ID:          primitive.NewObjectID(),
+			ClusterName: "kubefirst",
+			Status:      constants.ClusterStatusProvisioning,
+		},
+	}
+	if err := secrets.InsertCluster(clientset, clctrl.Cluster); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	stepErr := errors.New("terraform apply failed")
+	steps := []Step{
+		{Name: "first", Run: noop},
+		{Name: "second", DependsOn: []string{"first"}, Run: func(_ *ClusterController) error { return stepErr }},
+		{Name: "third", DependsOn: []string{"second"}, Run: noop},
+	}
+
+	if err := clctrl.RunPipeline(steps); !errors.Is(err, stepErr) {
+		t.Fatalf("expected error %v, got %v", stepErr, err)
+	}
+
+	stored, err := secrets.GetCluster(clientset, "kubefirst")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if stored.Status != constants.ClusterStatusError {
+		t.Errorf("expected cluster status %q, got %q", constants.ClusterStatusError, stored.Status)
+	}
+
+	expected := map[string]string{
+		"first":  constants.StepStatusSucceeded,
+		"second": constants.StepStatusFailed,
+		"third":  constants.StepStatusPending,
+	}
+	for _, record := range stored.Steps {
+		if record.Status != expected[record.Name] {
+			t.Errorf("expected step %s to be %q, got %q", record.Name, expected[record.Name], record.Status)
+		}
+	}
+
+	if len(stored.Steps) != 3 || stored.Steps[1].Attempts != 1 || stored.Steps[1].LastError != stepErr.Error() {
+		t.Errorf("unexpected step records: %+v", stored.Steps)
+	}
+}
+
+func TestPipelineStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []string
+		expected string
+	}{
+		{name: "all succeeded", statuses: []string{constants.StepStatusSucceeded, constants.StepStatusSucceeded}, expected: constants.ClusterStatusProvisioned},
+		{name: "failed step", statuses: []string{constants.StepStatusSucceeded, constants.StepStatusFailed, constants.StepStatusPending}, expected: constants.ClusterStatusError},
+		{name: "cancelled step", statuses: []string{constants.StepStatusSucceeded, constants.StepStatusCancelled, constants.StepStatusPending}, expected: constants.ClusterStatusCancelled},
+		{name: "steps left to run", statuses: []string{constants.StepStatusSucceeded, constants.StepStatusPending}, expected: constants.ClusterStatusProvisioning},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := make([]types.ClusterStep, 0, len(tt.statuses))
+			for _, status := range tt.statuses {
+				records = append(records, types.ClusterStep{Status: status})
+			}
+
+			if status := pipelineStatus(records); status != tt.expected {
+				t.Errorf("expected status %q, got %q", tt.expected, status)
+			}
+		})
+	}
+}
+
+func TestRunSelectedStepsRecomputesStatus(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+
+	clctrl := &ClusterController{
+		ClusterName:      "kubefirst",
+		KubernetesClient: clientset,
+		Cluster: types.Cluster{
+			ID:          primitive.NewObjectID(),
+			ClusterName: "kubefirst",
+			Status:      constants.ClusterStatusError,
+			Steps: []types.ClusterStep{
+				{Name: "first", Status: constants.StepStatusSucceeded},
+				{Name: "second", Status: constants.StepStatusFailed},
+			},
+		},
+	}
+	if err := secrets.InsertCluster(clientset, clctrl.Cluster); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	steps := []Step{
+		{Name: "first", Run: noop},
+		{Name: "second", DependsOn: []string{"first"}, Run: noop},
+	}
+	if err := clctrl.RunSelectedSteps(steps, []string{"second"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	stored, err := secrets.GetCluster(clientset, "kubefirst")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if stored.Status != constants.ClusterStatusProvisioned {
+		t.Errorf("expected cluster status %q, got %q", constants.ClusterStatusProvisioned, stored.Status)
+	}
+}
@@ -20,12 +20,16 @@ import (
 	"github.com/konstructio/kubefirst-api/internal/vultr"
 	google "github.com/konstructio/kubefirst-api/pkg/google"
 	"github.com/konstructio/kubefirst-api/pkg/providerConfigs"
+	"github.com/konstructio/kubefirst-api/pkg/types"
 	"github.com/kubefirst/metrics-client/pkg/telemetry"
-	log "github.com/rs/zerolog/log"
 )
 
 // RepositoryPrep
 func (clctrl *ClusterController) RepositoryPrep() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
 	cl, err := secrets.GetCluster(clctrl.KubernetesClient, clctrl.ClusterName)
 	if err != nil {
 		return fmt.Errorf("error getting cluster for %q: %w", clctrl.ClusterName, err)
@@ -39,7 +43,7 @@ func (clctrl *ClusterController) RepositoryPrep() error {
 	// TODO Implement an interface so we can call GetDomainApexContent on the clustercotroller
 
 	if !cl.GitopsReadyCheck {
-		log.Info().Msg("initializing the gitops repository - this may take several minutes")
+		clctrl.logger().Info().Msg("initializing the gitops repository - this may take several minutes")
 
 		switch clctrl.CloudProvider {
 		case "akamai":
@@ -207,13 +211,14 @@ func (clctrl *ClusterController) RepositoryPrep() error {
 			os.Remove(kubefirstRegistryLocation)
 		}
 
-		clctrl.Cluster.GitopsReadyCheck = true
-		err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+		err = clctrl.updateCluster(func(cluster *types.Cluster) {
+			cluster.GitopsReadyCheck = true
+		})
 		if err != nil {
 			return fmt.Errorf("error updating cluster %q: %w", clctrl.ClusterName, err)
 		}
 
-		log.Info().Msg("gitops repository initialized")
+		clctrl.logger().Info().Msg("gitops repository initialized")
 	}
 
 	return nil
@@ -221,6 +226,10 @@ func (clctrl *ClusterController) RepositoryPrep() error {
 
 // RepositoryPush
 func (clctrl *ClusterController) RepositoryPush() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
 	cl, err := secrets.GetCluster(clctrl.KubernetesClient, clctrl.ClusterName)
 	if err != nil {
 		return fmt.Errorf("error getting cluster %q: %w", clctrl.ClusterName, err)
@@ -250,7 +259,7 @@ func (clctrl *ClusterController) RepositoryPush() error {
 
 			keys, err := gitlabClient.GetUserSSHKeys()
 			if err != nil {
-				log.Error().Msgf("unable to check for ssh keys in gitlab: %s", err.Error())
+				clctrl.logger().Error().Msgf("unable to check for ssh keys in gitlab: %s", err.Error())
 			}
 
 			keyName := "kbot-ssh-key"
@@ -258,18 +267,18 @@ func (clctrl *ClusterController) RepositoryPush() error {
 			for _, key := range keys {
 				if key.Title == keyName {
 					if strings.Contains(key.Key, strings.TrimSuffix(clctrl.GitAuth.PublicKey, "\n")) {
-						log.Info().Msgf("ssh key %s already exists and key is up to date, continuing", keyName)
+						clctrl.logger().Info().Msgf("ssh key %s already exists and key is up to date, continuing", keyName)
 						keyFound = true
 					} else {
-						log.Error().Msgf("ssh key %s already exists and key data has drifted - please remove before continuing", keyName)
+						clctrl.logger().Error().Msgf("ssh key %s already exists and key data has drifted - please remove before continuing", keyName)
 					}
 				}
 			}
 			if !keyFound {
-				log.Info().Msgf("creating ssh key %s...", keyName)
+				clctrl.logger().Info().Msgf("creating ssh key %s...", keyName)
 				err := gitlabClient.AddUserSSHKey(keyName, clctrl.GitAuth.PublicKey)
 				if err != nil {
-					log.Error().Msgf("error adding ssh key %q: %s", keyName, err.Error())
+					clctrl.logger().Error().Msgf("error adding ssh key %q: %s", keyName, err.Error())
 				}
 			}
 		}
@@ -304,13 +313,14 @@ func (clctrl *ClusterController) RepositoryPush() error {
 			return fmt.Errorf("error pushing detokenized metaphor repository to remote %s: %w", clctrl.ProviderConfig.DestinationMetaphorRepoURL, err)
 		}
 
-		log.Info().Msgf("successfully pushed gitops and metaphor repositories to git@%s/%s", clctrl.GitHost, clctrl.GitAuth.Owner)
+		clctrl.logger().Info().Msgf("successfully pushed gitops and metaphor repositories to git@%s/%s", clctrl.GitHost, clctrl.GitAuth.Owner)
 		// todo delete the local gitops repo and re-clone it
 		// todo that way we can stop worrying about which origin we're going to push to
 		telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.GitopsRepoPushCompleted, "")
 
-		clctrl.Cluster.GitopsPushedCheck = true
-		err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+		err = clctrl.updateCluster(func(cluster *types.Cluster) {
+			cluster.GitopsPushedCheck = true
+		})
 		if err != nil {
 			return fmt.Errorf("error updating cluster %q: %w", clctrl.ClusterName, err)
 		}
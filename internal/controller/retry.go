@@ -0,0 +1,140 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/pkg/types"
+)
+
+// stepChecks maps each step to the check flag it sets on the cluster record
+// once it has completed. Steps without a flag run every time.
+var stepChecks = map[string]func(cl *types.Cluster) *bool{
+	StepDownloadTools:           func(cl *types.Cluster) *bool { return &cl.InstallToolsCheck },
+	StepDomainLivenessTest:      func(cl *types.Cluster) *bool { return &cl.DomainLivenessCheck },
+	StepStateStoreCredentials:   func(cl *types.Cluster) *bool { return &cl.StateStoreCredsCheck },
+	StepStateStoreCreate:        func(cl *types.Cluster) *bool { return &cl.StateStoreCreateCheck },
+	StepGitInit:                 func(cl *types.Cluster) *bool { return &cl.GitInitCheck },
+	StepInitializeBot:           func(cl *types.Cluster) *bool { return &cl.KbotSetupCheck },
+	StepRepositoryPrep:          func(cl *types.Cluster) *bool { return &cl.GitopsReadyCheck },
+	StepRunGitTerraform:         func(cl *types.Cluster) *bool { return &cl.GitTerraformApplyCheck },
+	StepRepositoryPush:          func(cl *types.Cluster) *bool { return &cl.GitopsPushedCheck },
+	StepCreateCluster:           func(cl *types.Cluster) *bool { return &cl.CloudTerraformApplyCheck },
+	StepDetokenizeKMSKeyID:      func(cl *types.Cluster) *bool { return &cl.AWSKMSKeyDetokenizedCheck },
+	StepClusterSecretsBootstrap: func(cl *types.Cluster) *bool { return &cl.ClusterSecretsCreatedCheck },
+	StepInstallArgoCD:           func(cl *types.Cluster) *bool { return &cl.ArgoCDInstallCheck },
+	StepInitializeArgoCD:        func(cl *types.Cluster) *bool { return &cl.ArgoCDInitializeCheck },
+	StepDeployRegistry:          func(cl *types.Cluster) *bool { return &cl.ArgoCDCreateRegistryCheck },
+	StepInitializeVault:         func(cl *types.Cluster) *bool { return &cl.VaultInitializedCheck },
+	StepRunVaultTerraform:       func(cl *types.Cluster) *bool { return &cl.VaultTerraformApplyCheck },
+	StepRunUsersTerraform:       func(cl *types.Cluster) *bool { return &cl.UsersTerraformApplyCheck },
+}
+
+// processLocalSteps set up state that only lives in the running api process,
+// such as port-forwards and credential files, so a retry always runs them
+// again ahead of the steps that need them
+var processLocalSteps = []string{
+	StepWriteGoogleCredentials,
+	StepOpenVaultPortForward,
+}
+
+// SelectRetrySteps returns the names of the steps a retry should run, in
+// pipeline order. Either fromStep, to run that step and every step after it,
+// or onlySteps, to run just those steps, must be set. An error is returned
+// when a selected step depends on a step that has not completed and is not
+// part of the retry.
+func SelectRetrySteps(steps []Step, cl *types.Cluster, fromStep string, onlySteps []string) ([]string, error) {
+	if (fromStep == "") == (len(onlySteps) == 0) {
+		return nil, errors.New("exactly one of from_step or only_steps must be set")
+	}
+
+	chosen := map[string]bool{}
+	if fromStep != "" {
+		idx := stepIndex(steps, fromStep)
+		if idx < 0 {
+			return nil, fmt.Errorf("unknown step %q", fromStep)
+		}
+
+		for _, step := range steps[idx:] {
+			chosen[step.Name] = true
+		}
+	}
+
+	for _, name := range onlySteps {
+		if stepIndex(steps, name) < 0 {
+			return nil, fmt.Errorf("unknown step %q", name)
+		}
+		chosen[name] = true
+	}
+
+	last := 0
+	for i, step := range steps {
+		if chosen[step.Name] {
+			last = i
+		}
+	}
+	for _, step := range steps[:last] {
+		if slices.Contains(processLocalSteps, step.Name) {
+			chosen[step.Name] = true
+		}
+	}
+
+	selected := []string{}
+	for _, step := range steps {
+		if !chosen[step.Name] {
+			continue
+		}
+
+		for _, dep := range step.DependsOn {
+			if !chosen[dep] && !stepComplete(cl, dep) {
+				return nil, fmt.Errorf("step %q requires %q to have completed first", step.Name, dep)
+			}
+		}
+
+		selected = append(selected, step.Name)
+	}
+
+	return selected, nil
+}
+
+// ResetSteps clears the check flags and progress records of the selected
+// steps so that they run again
+func ResetSteps(cl *types.Cluster, selected []string) {
+	for _, name := range selected {
+		if check, ok := stepChecks[name]; ok {
+			*check(cl) = false
+		}
+	}
+
+	for i := range cl.Steps {
+		if slices.Contains(selected, cl.Steps[i].Name) {
+			cl.Steps[i].Status = constants.StepStatusPending
+			cl.Steps[i].LastError = ""
+			cl.Steps[i].FinishedAt = ""
+		}
+	}
+}
+
+// stepComplete reports whether a step has completed. Clusters created before
+// step progress was recorded fall back to the step's check flag.
+func stepComplete(cl *types.Cluster, name string) bool {
+	for _, record := range cl.Steps {
+		if record.Name == name {
+			return record.Status == constants.StepStatusSucceeded
+		}
+	}
+
+	if check, ok := stepChecks[name]; ok {
+		return *check(cl)
+	}
+
+	return len(cl.Steps) == 0
+}
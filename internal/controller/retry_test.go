@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/pkg/types"
+)
+
+func TestSelectRetrySteps(t *testing.T) {
+	steps := []Step{
+		{Name: StepDownloadTools, Run: noop},
+		{Name: StepOpenVaultPortForward, DependsOn: []string{StepDownloadTools}, Run: noop},
+		{Name: StepInitializeVault, DependsOn: []string{StepOpenVaultPortForward}, Run: noop},
+		{Name: StepRunVaultTerraform, DependsOn: []string{StepInitializeVault}, Run: noop},
+		{Name: StepRunUsersTerraform, DependsOn: []string{StepRunVaultTerraform}, Run: noop},
+	}
+
+	tests := []struct {
+		name      string
+		cluster   types.Cluster
+		fromStep  string
+		onlySteps []string
+		expected  []string
+		wantErr   bool
+	}{
+		{
+			name:     "from step runs every later step",
+			cluster:  types.Cluster{InstallToolsCheck: true, VaultInitializedCheck: true},
+			fromStep: StepRunVaultTerraform,
+			expected: []string{StepOpenVaultPortForward, StepRunVaultTerraform, StepRunUsersTerraform},
+		},
+		{
+			name:      "only steps with completed prerequisites",
+			cluster:   types.Cluster{InstallToolsCheck: true, VaultInitializedCheck: true, VaultTerraformApplyCheck: true},
+			onlySteps: []string{StepRunUsersTerraform},
+			expected:  []string{StepOpenVaultPortForward, StepRunUsersTerraform},
+		},
+		{
+			name:      "only steps with a missing prerequisite",
+			cluster:   types.Cluster{InstallToolsCheck: true, VaultInitializedCheck: true},
+			onlySteps: []string{StepRunUsersTerraform},
+			wantErr:   true,
+		},
+		{
+			name: "step record takes precedence over check flag",
+			cluster: types.Cluster{
+				InstallToolsCheck: true,
+				Steps:             []types.ClusterStep{{Name: StepDownloadTools, Status: constants.StepStatusFailed}},
+			},
+			fromStep: StepOpenVaultPortForward,
+			wantErr:  true,
+		},
+		{
+			name:     "unknown step",
+			fromStep: "does_not_exist",
+			wantErr:  true,
+		},
+		{
+			name:      "both options set",
+			fromStep:  StepRunVaultTerraform,
+			onlySteps: []string{StepRunUsersTerraform},
+			wantErr:   true,
+		},
+		{
+			name:    "no option set",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, err := SelectRetrySteps(steps, &tt.cluster, tt.fromStep, tt.onlySteps)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", selected)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !slices.Equal(selected, tt.expected) {
+				t.Errorf("expected steps %v, got %v", tt.expected, selected)
+			}
+		})
+	}
+}
+
+func TestResetSteps(t *testing.T) {
+	cl := &types.Cluster{
+		VaultInitializedCheck:    true,
+		VaultTerraformApplyCheck: true,
+		Steps: []types.ClusterStep{
+			{Name: StepInitializeVault, Status: constants.StepStatusSucceeded},
+			{Name: StepRunVaultTerraform, Status: constants.StepStatusFailed, LastError: "terraform apply failed"},
+		},
+	}
+
+	ResetSteps(cl, []string{StepRunVaultTerraform})
+
+	if !cl.VaultInitializedCheck || cl.VaultTerraformApplyCheck {
+		t.Errorf("expected only the vault terraform check to be reset, got %+v", cl)
+	}
+
+	if cl.Steps[0].Status != constants.StepStatusSucceeded {
+		t.Errorf("expected %s to stay %q, got %q", StepInitializeVault, constants.StepStatusSucceeded, cl.Steps[0].Status)
+	}
+
+	if cl.Steps[1].Status != constants.StepStatusPending || cl.Steps[1].LastError != "" {
+		t.Errorf("expected %s to be reset, got %+v", StepRunVaultTerraform, cl.Steps[1])
+	}
+}
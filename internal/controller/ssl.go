@@ -0,0 +1,47 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package controller
+
+import (
+	"os"
+
+	"github.com/konstructio/kubefirst-api/internal/ssl"
+)
+
+// RestoreSSL restores tls secrets backed up from a previous install of the cluster
+func (clctrl *ClusterController) RestoreSSL() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
+	clctrl.logger().Info().Msg("checking for tls secrets to restore")
+	secretsFilesToRestore, err := os.ReadDir(clctrl.ProviderConfig.SSLBackupDir + "/secrets")
+	if err != nil {
+		if os.IsNotExist(err) {
+			clctrl.logger().Info().Msg("no files found in secrets directory, continuing")
+		} else {
+			clctrl.logger().Info().Msgf("unable to check for TLS secrets to restore: %s", err.Error())
+		}
+	}
+
+	if len(secretsFilesToRestore) == 0 {
+		clctrl.logger().Info().Msg("no files found in secrets directory, continuing")
+		return nil
+	}
+
+	// todo would like these but requires CRD's and is not currently supported
+	// add crds ( use execShellReturnErrors? )
+	// https://raw.githubusercontent.com/cert-manager/cert-manager/v1.11.0/deploy/crds/crd-clusterissuers.yaml
+	// https://raw.githubusercontent.com/cert-manager/cert-manager/v1.11.0/deploy/crds/crd-certificates.yaml
+	// add certificates, and clusterissuers
+	clctrl.logger().Info().Msgf("found %d tls secrets to restore", len(secretsFilesToRestore))
+	if err := ssl.Restore(clctrl.ProviderConfig.SSLBackupDir, clctrl.ProviderConfig.Kubeconfig); err != nil {
+		clctrl.logger().Warn().Msgf("error restoring tls secrets: %s", err)
+	}
+
+	return nil
+}
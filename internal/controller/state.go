@@ -7,25 +7,28 @@ See the LICENSE file for more details.
 package controller
 
 import (
-	"context"
 	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/konstructio/kubefirst-api/internal/civo"
 	"github.com/konstructio/kubefirst-api/internal/digitalocean"
+	"github.com/konstructio/kubefirst-api/internal/metrics"
 	"github.com/konstructio/kubefirst-api/internal/secrets"
 	"github.com/konstructio/kubefirst-api/internal/vultr"
 	"github.com/konstructio/kubefirst-api/pkg/akamai"
 	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
 	"github.com/kubefirst/metrics-client/pkg/telemetry"
 	"github.com/linode/linodego"
-	log "github.com/rs/zerolog/log"
 	"golang.org/x/oauth2"
 )
 
 // StateStoreCredentials
 func (clctrl *ClusterController) StateStoreCredentials() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
 	cl, err := secrets.GetCluster(clctrl.KubernetesClient, clctrl.ClusterName)
 	if err != nil {
 		return fmt.Errorf("failed to get cluster: %w", err)
@@ -38,7 +41,7 @@ func (clctrl *ClusterController) StateStoreCredentials() error {
 	if !cl.StateStoreCredsCheck {
 		switch clctrl.CloudProvider {
 		case "akamai":
-			log.Info().Msg("object storage credentials created during bucket create")
+			clctrl.logger().Info().Msg("object storage credentials created during bucket create")
 		case "aws":
 			kubefirstStateStoreBucket, err := clctrl.AwsClient.CreateBucket(clctrl.KubefirstStateStoreBucketName)
 			if err != nil {
@@ -57,13 +60,14 @@ func (clctrl *ClusterController) StateStoreCredentials() error {
 				Name:            clctrl.KubefirstStateStoreBucketName,
 			}
 
-			clctrl.Cluster.StateStoreDetails = pkgtypes.StateStoreDetails{
-				AWSStateStoreBucket: strings.ReplaceAll(*kubefirstStateStoreBucket.Location, "/", ""),
-				AWSArtifactsBucket:  strings.ReplaceAll(*kubefirstArtifactsBucket.Location, "/", ""),
-				Hostname:            "s3.amazonaws.com",
-				Name:                clctrl.KubefirstStateStoreBucketName,
-			}
-			err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+			err = clctrl.updateCluster(func(cluster *pkgtypes.Cluster) {
+				cluster.StateStoreDetails = pkgtypes.StateStoreDetails{
+					AWSStateStoreBucket: strings.ReplaceAll(*kubefirstStateStoreBucket.Location, "/", ""),
+					AWSArtifactsBucket:  strings.ReplaceAll(*kubefirstArtifactsBucket.Location, "/", ""),
+					Hostname:            "s3.amazonaws.com",
+					Name:                clctrl.KubefirstStateStoreBucketName,
+				}
+			})
 			if err != nil {
 				telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.StateStoreCredentialsCreateFailed, err.Error())
 				return fmt.Errorf("failed to update cluster after creating AWS state store: %w", err)
@@ -71,13 +75,13 @@ func (clctrl *ClusterController) StateStoreCredentials() error {
 		case "civo":
 			civoConf := civo.Configuration{
 				Client:  civo.NewCivo(cl.CivoAuth.Token, cl.CloudRegion),
-				Context: context.Background(),
+				Context: clctrl.Context,
 			}
 
 			creds, err := civoConf.GetAccessCredentials(clctrl.KubefirstStateStoreBucketName, clctrl.CloudRegion)
 			if err != nil {
 				telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.StateStoreCredentialsCreateFailed, err.Error())
-				log.Error().Msg(err.Error())
+				clctrl.logger().Error().Msg(err.Error())
 				return fmt.Errorf("failed to get access credentials from Civo: %w", err)
 			}
 
@@ -90,7 +94,7 @@ func (clctrl *ClusterController) StateStoreCredentials() error {
 		case "digitalocean":
 			digitaloceanConf := digitalocean.Configuration{
 				Client:  digitalocean.NewDigitalocean(cl.DigitaloceanAuth.Token),
-				Context: context.Background(),
+				Context: clctrl.Context,
 			}
 
 			creds := digitalocean.SpacesCredentials{
@@ -101,7 +105,7 @@ func (clctrl *ClusterController) StateStoreCredentials() error {
 			err = digitaloceanConf.CreateSpaceBucket(creds, clctrl.KubefirstStateStoreBucketName)
 			if err != nil {
 				msg := fmt.Sprintf("error creating spaces bucket %s: %s", clctrl.KubefirstStateStoreBucketName, err)
-				log.Error().Msg(msg)
+				clctrl.logger().Error().Msg(msg)
 				telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.StateStoreCredentialsCreateFailed, err.Error())
 				return fmt.Errorf("failed to create DigitalOcean spaces bucket: %w", err)
 			}
@@ -112,11 +116,12 @@ func (clctrl *ClusterController) StateStoreCredentials() error {
 				Name:            clctrl.KubefirstStateStoreBucketName,
 			}
 
-			clctrl.Cluster.StateStoreDetails = pkgtypes.StateStoreDetails{
-				Name:     clctrl.KubefirstStateStoreBucketName,
-				Hostname: creds.Endpoint,
-			}
-			err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+			err = clctrl.updateCluster(func(cluster *pkgtypes.Cluster) {
+				cluster.StateStoreDetails = pkgtypes.StateStoreDetails{
+					Name:     clctrl.KubefirstStateStoreBucketName,
+					Hostname: creds.Endpoint,
+				}
+			})
 			if err != nil {
 				return fmt.Errorf("failed to update cluster after creating DigitalOcean spaces bucket: %w", err)
 			}
@@ -135,7 +140,7 @@ func (clctrl *ClusterController) StateStoreCredentials() error {
 		case "vultr":
 			vultrConf := vultr.Configuration{
 				Client:  vultr.NewVultr(cl.VultrAuth.Token),
-				Context: context.Background(),
+				Context: clctrl.Context,
 				Region:  cl.CloudRegion,
 				// https://www.vultr.com/docs/vultr-object-storage/
 				ObjectStorageRegion: "ewr",
@@ -144,7 +149,7 @@ func (clctrl *ClusterController) StateStoreCredentials() error {
 			objst, err := vultrConf.CreateObjectStorage(clctrl.KubefirstStateStoreBucketName)
 			if err != nil {
 				telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.StateStoreCreateFailed, err.Error())
-				log.Error().Msg(err.Error())
+				clctrl.logger().Error().Msg(err.Error())
 				return fmt.Errorf("failed to create Vultr object storage: %w", err)
 			}
 			err = vultrConf.CreateObjectStorageBucket(vultr.BucketCredentials{
@@ -164,27 +169,28 @@ func (clctrl *ClusterController) StateStoreCredentials() error {
 				ID:              objst.ID,
 			}
 
-			clctrl.Cluster.StateStoreDetails = pkgtypes.StateStoreDetails{
-				Name:     objst.Label,
-				ID:       objst.ID,
-				Hostname: objst.S3Hostname,
-			}
-			err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+			err = clctrl.updateCluster(func(cluster *pkgtypes.Cluster) {
+				cluster.StateStoreDetails = pkgtypes.StateStoreDetails{
+					Name:     objst.Label,
+					ID:       objst.ID,
+					Hostname: objst.S3Hostname,
+				}
+			})
 			if err != nil {
 				return fmt.Errorf("failed to update cluster after creating Vultr state storage bucket: %w", err)
 			}
 		}
 
-		clctrl.Cluster.StateStoreCredentials = stateStoreData
-		clctrl.Cluster.StateStoreCredsCheck = true
-
-		err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+		err = clctrl.updateCluster(func(cluster *pkgtypes.Cluster) {
+			cluster.StateStoreCredentials = stateStoreData
+			cluster.StateStoreCredsCheck = true
+		})
 		if err != nil {
 			return fmt.Errorf("failed to update cluster state store credentials: %w", err)
 		}
 
 		telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.CloudCredentialsCheckCompleted, "")
-		log.Info().Msgf("%s object storage credentials created and set", clctrl.CloudProvider)
+		clctrl.logger().Info().Msgf("%s object storage credentials created and set", clctrl.CloudProvider)
 	}
 
 	return nil
@@ -192,6 +198,10 @@ func (clctrl *ClusterController) StateStoreCredentials() error {
 
 // StateStoreCreate
 func (clctrl *ClusterController) StateStoreCreate() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
 	cl, err := secrets.GetCluster(clctrl.KubernetesClient, clctrl.ClusterName)
 	if err != nil {
 		return fmt.Errorf("failed to get cluster for state store creation: %w", err)
@@ -206,6 +216,7 @@ func (clctrl *ClusterController) StateStoreCreate() error {
 			oauth2Client := &http.Client{
 				Transport: &oauth2.Transport{
 					Source: tokenSource,
+					Base:   metrics.Transport(metrics.ServiceAkamai, nil),
 				},
 			}
 
@@ -213,7 +224,7 @@ func (clctrl *ClusterController) StateStoreCreate() error {
 
 			akamaiConf := akamai.Configuration{
 				Client:  linodego.NewClient(oauth2Client),
-				Context: context.Background(),
+				Context: clctrl.Context,
 			}
 
 			telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.StateStoreCreateStarted, "")
@@ -221,41 +232,41 @@ func (clctrl *ClusterController) StateStoreCreate() error {
 			bucketAndCreds, err := akamaiConf.CreateObjectStorageBucketAndKeys(cl.ClusterName)
 			if err != nil {
 				telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.StateStoreCreateFailed, err.Error())
-				log.Error().Msg(err.Error())
+				clctrl.logger().Error().Msg(err.Error())
 				return fmt.Errorf("failed to create Akamai object storage bucket and keys: %w", err)
 			}
 
-			clctrl.Cluster.StateStoreDetails = pkgtypes.StateStoreDetails{
-				Name:     bucketAndCreds.StateStoreDetails.Name,
-				Hostname: bucketAndCreds.StateStoreDetails.Hostname,
-			}
-			clctrl.Cluster.StateStoreCreateCheck = true
-			clctrl.Cluster.StateStoreCredentials = bucketAndCreds.StateStoreCredentials
-			clctrl.Cluster.StateStoreCredsCheck = true
-
-			err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+			err = clctrl.updateCluster(func(cluster *pkgtypes.Cluster) {
+				cluster.StateStoreDetails = pkgtypes.StateStoreDetails{
+					Name:     bucketAndCreds.StateStoreDetails.Name,
+					Hostname: bucketAndCreds.StateStoreDetails.Hostname,
+				}
+				cluster.StateStoreCreateCheck = true
+				cluster.StateStoreCredentials = bucketAndCreds.StateStoreCredentials
+				cluster.StateStoreCredsCheck = true
+			})
 			if err != nil {
 				return fmt.Errorf("failed to update cluster after creating Akamai state store: %w", err)
 			}
 
 			telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.StateStoreCreateCompleted, "")
-			log.Info().Msgf("%s state store bucket created", clctrl.CloudProvider)
+			clctrl.logger().Info().Msgf("%s state store bucket created", clctrl.CloudProvider)
 		case "civo":
 
 			civoConf := civo.Configuration{
 				Client:  civo.NewCivo(cl.CivoAuth.Token, cl.CloudRegion),
-				Context: context.Background(),
+				Context: clctrl.Context,
 			}
 
 			telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.StateStoreCreateStarted, "")
 
 			accessKeyID := cl.StateStoreCredentials.AccessKeyID
-			log.Info().Msgf("access key id %s", accessKeyID)
+			clctrl.logger().Info().Msgf("access key id %s", accessKeyID)
 
 			bucket, err := civoConf.CreateStorageBucket(accessKeyID, clctrl.KubefirstStateStoreBucketName, clctrl.CloudRegion)
 			if err != nil {
 				telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.StateStoreCreateFailed, err.Error())
-				log.Error().Msg(err.Error())
+				clctrl.logger().Error().Msg(err.Error())
 				return fmt.Errorf("failed to create Civo storage bucket: %w", err)
 			}
 
@@ -265,16 +276,16 @@ func (clctrl *ClusterController) StateStoreCreate() error {
 				Hostname: bucket.BucketURL,
 			}
 
-			clctrl.Cluster.StateStoreDetails = stateStoreData
-			clctrl.Cluster.StateStoreCreateCheck = true
-
-			err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+			err = clctrl.updateCluster(func(cluster *pkgtypes.Cluster) {
+				cluster.StateStoreDetails = stateStoreData
+				cluster.StateStoreCreateCheck = true
+			})
 			if err != nil {
 				return fmt.Errorf("failed to update cluster after creating Civo state store: %w", err)
 			}
 
 			telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.StateStoreCreateCompleted, "")
-			log.Info().Msgf("%s state store bucket created", clctrl.CloudProvider)
+			clctrl.logger().Info().Msgf("%s state store bucket created", clctrl.CloudProvider)
 		}
 	}
 
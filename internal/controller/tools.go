@@ -13,20 +13,24 @@ import (
 	"github.com/konstructio/kubefirst-api/internal/utils"
 	awsinternal "github.com/konstructio/kubefirst-api/pkg/aws"
 	"github.com/konstructio/kubefirst-api/pkg/providerConfigs"
-	log "github.com/rs/zerolog/log"
+	"github.com/konstructio/kubefirst-api/pkg/types"
 )
 
 // DownloadTools
 // This obviously doesn't work in an api-based environment.
 // It's included for testing and development.
 func (clctrl *ClusterController) DownloadTools(toolsDir string) error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
 	cl, err := secrets.GetCluster(clctrl.KubernetesClient, clctrl.ClusterName)
 	if err != nil {
 		return fmt.Errorf("failed to get cluster: %w", err)
 	}
 
 	if !cl.InstallToolsCheck {
-		log.Info().Msg("installing kubefirst dependencies")
+		clctrl.logger().Info().Msg("installing kubefirst dependencies")
 
 		switch cl.CloudProvider {
 		case "akamai":
@@ -39,7 +43,7 @@ func (clctrl *ClusterController) DownloadTools(toolsDir string) error {
 				toolsDir,
 			)
 			if err != nil {
-				log.Error().Msgf("error downloading dependencies: %s", err)
+				clctrl.logger().Error().Msgf("error downloading dependencies: %s", err)
 				return fmt.Errorf("failed to download tools for akamai: %w", err)
 			}
 		case "aws":
@@ -49,7 +53,7 @@ func (clctrl *ClusterController) DownloadTools(toolsDir string) error {
 				providerConfigs.TerraformClientVersion,
 			)
 			if err != nil {
-				log.Error().Msgf("error downloading dependencies: %s", err)
+				clctrl.logger().Error().Msgf("error downloading dependencies: %s", err)
 				return fmt.Errorf("failed to download tools for aws: %w", err)
 			}
 		case "civo":
@@ -62,7 +66,7 @@ func (clctrl *ClusterController) DownloadTools(toolsDir string) error {
 				toolsDir,
 			)
 			if err != nil {
-				log.Error().Msgf("error downloading dependencies: %s", err)
+				clctrl.logger().Error().Msgf("error downloading dependencies: %s", err)
 				return fmt.Errorf("failed to download tools for civo: %w", err)
 			}
 		case "google":
@@ -75,7 +79,7 @@ func (clctrl *ClusterController) DownloadTools(toolsDir string) error {
 				toolsDir,
 			)
 			if err != nil {
-				log.Error().Msgf("error downloading dependencies: %s", err)
+				clctrl.logger().Error().Msgf("error downloading dependencies: %s", err)
 				return fmt.Errorf("failed to download tools for google: %w", err)
 			}
 		case "digitalocean":
@@ -88,7 +92,7 @@ func (clctrl *ClusterController) DownloadTools(toolsDir string) error {
 				toolsDir,
 			)
 			if err != nil {
-				log.Error().Msgf("error downloading dependencies: %s", err)
+				clctrl.logger().Error().Msgf("error downloading dependencies: %s", err)
 				return fmt.Errorf("failed to download tools for digitalocean: %w", err)
 			}
 		case "vultr":
@@ -101,7 +105,7 @@ func (clctrl *ClusterController) DownloadTools(toolsDir string) error {
 				toolsDir,
 			)
 			if err != nil {
-				log.Error().Msgf("error downloading dependencies: %s", err)
+				clctrl.logger().Error().Msgf("error downloading dependencies: %s", err)
 				return fmt.Errorf("failed to download tools for vultr: %w", err)
 			}
 
@@ -117,14 +121,15 @@ func (clctrl *ClusterController) DownloadTools(toolsDir string) error {
 				toolsDir,
 			)
 			if err != nil {
-				log.Error().Msgf("error downloading dependencies: %s", err)
+				clctrl.logger().Error().Msgf("error downloading dependencies: %s", err)
 				return fmt.Errorf("failed to download tools for k3s: %w", err)
 			}
 		}
-		log.Info().Msg("dependency downloads complete")
+		clctrl.logger().Info().Msg("dependency downloads complete")
 
-		clctrl.Cluster.InstallToolsCheck = true
-		err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+		err = clctrl.updateCluster(func(cluster *types.Cluster) {
+			cluster.InstallToolsCheck = true
+		})
 		if err != nil {
 			return fmt.Errorf("failed to update cluster after downloading tools: %w", err)
 		}
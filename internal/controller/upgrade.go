@@ -0,0 +1,398 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/digitalocean/godo"
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/k8s"
+	"github.com/konstructio/kubefirst-api/pkg/types"
+	"github.com/linode/linodego"
+	"github.com/vultr/govultr/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// upgradeNodesTimeoutSeconds bounds the wait for every node to roll onto
+	// the new version once the control plane has been upgraded
+	upgradeNodesTimeoutSeconds = 3600
+	// upgradeDeploymentTimeoutSeconds bounds the wait for each kube-system
+	// deployment to become ready after the nodes rolled
+	upgradeDeploymentTimeoutSeconds = 300
+)
+
+var (
+	// kubernetesVersionVar matches the detokenized control plane version in
+	// the cloud terraform
+	kubernetesVersionVar = regexp.MustCompile(`(?m)^(\s*(?:kubernetes_version|cluster_version|k8s_version)\s*=\s*)"[^"<>]*"`)
+	// semanticVersion matches the major, minor and optional patch version in
+	// the version formats used by the cloud providers, such as 1.29,
+	// v1.29.2+1, 1.29.1-do.0 or 1.29.1-gke.1589018
+	semanticVersion = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+)
+
+// ListUpgradeVersions returns the control plane version of a cluster and the
+// versions offered in its region that it can be upgraded to
+func ListUpgradeVersions(ctx context.Context, cl *types.Cluster) (*types.ClusterUpgradeVersions, error) {
+	if cl.CloudProvider == "k3s" {
+		return nil, errors.New("k3s is installed on user provided hosts and cannot be upgraded through kubefirst")
+	}
+
+	clctrl, err := clusterController(ctx, cl)
+	if err != nil {
+		return nil, err
+	}
+
+	current, available, err := clctrl.KubernetesVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.ClusterUpgradeVersions{
+		CurrentVersion:    current,
+		AvailableVersions: UpgradableVersions(current, available),
+	}, nil
+}
+
+// KubernetesVersions returns the control plane version of the cluster and
+// every version the cloud provider offers in the cluster region
+func (clctrl *ClusterController) KubernetesVersions() (string, []string, error) {
+	var current string
+	var available []string
+
+	switch clctrl.CloudProvider {
+	case "akamai":
+		client := clctrl.linodeClient()
+		clusters, err := client.ListLKEClusters(clctrl.Context, &linodego.ListOptions{})
+		if err != nil {
+			return "", nil, fmt.Errorf("error listing lke clusters: %w", err)
+		}
+		for _, cluster := range clusters {
+			if cluster.Label == clctrl.ClusterName {
+				current = cluster.K8sVersion
+			}
+		}
+
+		versions, err := client.ListLKEVersions(clctrl.Context, &linodego.ListOptions{})
+		if err != nil {
+			return "", nil, fmt.Errorf("error listing lke versions: %w", err)
+		}
+		for _, version := range versions {
+			available = append(available, version.ID)
+		}
+	case "aws":
+		client := eks.NewFromConfig(clctrl.AwsClient.Config)
+		cluster, err := client.DescribeCluster(clctrl.Context, &eks.DescribeClusterInput{Name: aws.String(clctrl.ClusterName)})
+		if err != nil {
+			return "", nil, fmt.Errorf("error describing eks cluster: %w", err)
+		}
+		current = aws.ToString(cluster.Cluster.Version)
+
+		// EKS has no version listing in this sdk, the cluster versions the
+		// kube-proxy addon is built for are the ones EKS supports
+		addons, err := client.DescribeAddonVersions(clctrl.Context, &eks.DescribeAddonVersionsInput{AddonName: aws.String("kube-proxy")})
+		if err != nil {
+			return "", nil, fmt.Errorf("error listing eks versions: %w", err)
+		}
+		for _, addon := range addons.Addons {
+			for _, addonVersion := range addon.AddonVersions {
+				for _, compatibility := range addonVersion.Compatibilities {
+					version := aws.ToString(compatibility.ClusterVersion)
+					if !slices.Contains(available, version) {
+						available = append(available, version)
+					}
+				}
+			}
+		}
+	case "civo":
+		client := clctrl.civoConfiguration().Client
+		cluster, err := client.FindKubernetesCluster(clctrl.ClusterName)
+		if err != nil {
+			return "", nil, fmt.Errorf("error finding civo cluster: %w", err)
+		}
+		current = cluster.KubernetesVersion
+
+		versions, err := client.ListAvailableKubernetesVersions()
+		if err != nil {
+			return "", nil, fmt.Errorf("error listing civo kubernetes versions: %w", err)
+		}
+		for _, version := range versions {
+			if cluster.ClusterType == "" || version.ClusterType == cluster.ClusterType {
+				available = append(available, version.Version)
+			}
+		}
+	case "digitalocean":
+		doConf := clctrl.digitaloceanConfiguration()
+		clusters, _, err := doConf.Client.Kubernetes.List(doConf.Context, &godo.ListOptions{})
+		if err != nil {
+			return "", nil, fmt.Errorf("error listing doks clusters: %w", err)
+		}
+		for _, cluster := range clusters {
+			if cluster.Name == clctrl.ClusterName {
+				current = cluster.VersionSlug
+			}
+		}
+
+		options, _, err := doConf.Client.Kubernetes.GetOptions(doConf.Context)
+		if err != nil {
+			return "", nil, fmt.Errorf("error listing doks versions: %w", err)
+		}
+		for _, version := range options.Versions {
+			available = append(available, version.Slug)
+		}
+	case "google":
+		googleConf := clctrl.googleConfiguration()
+		cluster, err := googleConf.GetContainerCluster(clctrl.ClusterName)
+		if err != nil {
+			return "", nil, fmt.Errorf("error getting gke cluster: %w", err)
+		}
+		current = cluster.GetCurrentMasterVersion()
+
+		available, err = googleConf.ListMasterVersions()
+		if err != nil {
+			return "", nil, fmt.Errorf("error listing gke versions: %w", err)
+		}
+	case "vultr":
+		vultrConf := clctrl.vultrConfiguration()
+		clusters, _, _, err := vultrConf.Client.Kubernetes.ListClusters(vultrConf.Context, &govultr.ListOptions{})
+		if err != nil {
+			return "", nil, fmt.Errorf("error listing vke clusters: %w", err)
+		}
+		for _, cluster := range clusters {
+			if cluster.Label == clctrl.ClusterName {
+				current = cluster.Version
+			}
+		}
+
+		versions, _, err := vultrConf.Client.Kubernetes.GetVersions(vultrConf.Context)
+		if err != nil {
+			return "", nil, fmt.Errorf("error listing vke versions: %w", err)
+		}
+		available = versions.Versions
+	default:
+		return "", nil, fmt.Errorf("cloud provider %q does not support kubernetes upgrades", clctrl.CloudProvider)
+	}
+
+	if current == "" {
+		return "", nil, fmt.Errorf("%s cluster %s not found", clctrl.CloudProvider, clctrl.ClusterName)
+	}
+
+	return current, available, nil
+}
+
+// UpgradableVersions returns the versions in available that current can be
+// upgraded to without breaking the version skew policy
+func UpgradableVersions(current string, available []string) []string {
+	upgradable := []string{}
+	for _, version := range available {
+		if ValidateVersionSkew(current, version) == nil {
+			upgradable = append(upgradable, version)
+		}
+	}
+	return upgradable
+}
+
+// ValidateVersionSkew checks that a control plane on current can be upgraded
+// to target. Kubernetes only supports upgrading one minor version at a time
+// and never downgrading.
+func ValidateVersionSkew(current, target string) error {
+	if current == target {
+		return fmt.Errorf("cluster already runs kubernetes %s", current)
+	}
+
+	currentVersion, err := parseVersion(current)
+	if err != nil {
+		return err
+	}
+	targetVersion, err := parseVersion(target)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case targetVersion[0] != currentVersion[0]:
+		return fmt.Errorf("upgrading from major version %d to %d is not supported", currentVersion[0], targetVersion[0])
+	case targetVersion[1] < currentVersion[1],
+		targetVersion[1] == currentVersion[1] && targetVersion[2] < currentVersion[2]:
+		return fmt.Errorf("kubernetes %s is older than the current version %s, downgrades are not supported", target, current)
+	case targetVersion[1] > currentVersion[1]+1:
+		return fmt.Errorf("kubernetes can only be upgraded one minor version at a time, upgrade %s to %d.%d first", current, currentVersion[0], currentVersion[1]+1)
+	}
+
+	return nil
+}
+
+// parseVersion returns the major, minor and patch version of a provider
+// version string. A missing patch version is returned as 0.
+func parseVersion(version string) ([3]int, error) {
+	var parsed [3]int
+
+	match := semanticVersion.FindStringSubmatch(version)
+	if match == nil {
+		return parsed, fmt.Errorf("unable to parse kubernetes version %q", version)
+	}
+
+	for i, part := range match[1:] {
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return parsed, fmt.Errorf("unable to parse kubernetes version %q: %w", version, err)
+		}
+		parsed[i] = n
+	}
+
+	return parsed, nil
+}
+
+// UpgradeKubernetes upgrades the control plane of the cluster to target by
+// updating the version in the gitops repository and applying the cloud
+// terraform, then waits for the nodes to roll onto the new version. Every
+// upgrade is recorded in the upgrade history of the cluster.
+func (clctrl *ClusterController) UpgradeKubernetes(target string) error {
+	current, available, err := clctrl.KubernetesVersions()
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(available, target) {
+		return fmt.Errorf("kubernetes %s is not offered by %s in region %s", target, clctrl.CloudProvider, clctrl.CloudRegion)
+	}
+	if err := ValidateVersionSkew(current, target); err != nil {
+		return err
+	}
+
+	upgrade := types.ClusterUpgrade{
+		FromVersion: current,
+		ToVersion:   target,
+		Status:      constants.JobStatusRunning,
+		StartedAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+	err = clctrl.updateCluster(func(cluster *types.Cluster) {
+		cluster.UpgradeHistory = append(cluster.UpgradeHistory, upgrade)
+	})
+	if err != nil {
+		return fmt.Errorf("error recording upgrade: %w", err)
+	}
+
+	upgradeErr := clctrl.runUpgrade(current, target)
+
+	upgrade.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+	upgrade.Status = constants.JobStatusSucceeded
+	if upgradeErr != nil {
+		upgrade.Status = constants.JobStatusFailed
+		upgrade.Error = upgradeErr.Error()
+	}
+	err = clctrl.updateCluster(func(cluster *types.Cluster) {
+		for i := range cluster.UpgradeHistory {
+			if cluster.UpgradeHistory[i].StartedAt == upgrade.StartedAt && cluster.UpgradeHistory[i].ToVersion == target {
+				cluster.UpgradeHistory[i] = upgrade
+			}
+		}
+		if upgradeErr == nil {
+			cluster.KubernetesVersion = target
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("error recording upgrade result: %w", err)
+	}
+
+	return upgradeErr
+}
+
+func (clctrl *ClusterController) runUpgrade(current, target string) error {
+	workDir, err := os.MkdirTemp("", fmt.Sprintf("kubefirst-upgrade-%s-", clctrl.ClusterName))
+	if err != nil {
+		return fmt.Errorf("error creating working directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	gitopsRepo, tfEntrypoint, err := clctrl.cloneGitops(workDir)
+	if err != nil {
+		return err
+	}
+
+	if err := UpdateKubernetesVersionVar(tfEntrypoint, target); err != nil {
+		return err
+	}
+
+	commitMsg := fmt.Sprintf("upgrading cluster %s from kubernetes %s to %s", clctrl.ClusterName, current, target)
+	if err := clctrl.applyAndPushCloudTerraform(gitopsRepo, workDir, tfEntrypoint, commitMsg); err != nil {
+		return err
+	}
+
+	kcfg, err := clctrl.ClusterKubernetesClient()
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes client: %w", err)
+	}
+
+	targetVersion, err := parseVersion(target)
+	if err != nil {
+		return err
+	}
+	if _, err := k8s.WaitForNodesVersion(clctrl.Context, kcfg.Clientset, fmt.Sprintf("v%d.%d.", targetVersion[0], targetVersion[1]), upgradeNodesTimeoutSeconds); err != nil {
+		return fmt.Errorf("error waiting for nodes to upgrade: %w", err)
+	}
+
+	deployments, err := kcfg.Clientset.AppsV1().Deployments("kube-system").List(clctrl.Context, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("error listing kube-system deployments: %w", err)
+	}
+	for i := range deployments.Items {
+		if _, err := k8s.WaitForDeploymentReadyContext(clctrl.Context, kcfg.Clientset, &deployments.Items[i], upgradeDeploymentTimeoutSeconds); err != nil {
+			return fmt.Errorf("error waiting for kube-system deployments after upgrade: %w", err)
+		}
+	}
+
+	clctrl.logger().Info().Msgf("cluster %s upgraded to kubernetes %s", clctrl.ClusterName, target)
+	return nil
+}
+
+// UpdateKubernetesVersionVar sets the control plane version in the terraform
+// files at the top of tfEntrypoint
+func UpdateKubernetesVersionVar(tfEntrypoint, version string) error {
+	files, err := filepath.Glob(filepath.Join(tfEntrypoint, "*.tf"))
+	if err != nil {
+		return fmt.Errorf("error listing terraform files: %w", err)
+	}
+
+	found := false
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", file, err)
+		}
+
+		if !kubernetesVersionVar.Match(content) {
+			continue
+		}
+		found = true
+
+		updated := kubernetesVersionVar.ReplaceAllString(string(content), fmt.Sprintf(`${1}%q`, version))
+		if err := os.WriteFile(file, []byte(updated), 0o644); err != nil {
+			return fmt.Errorf("error writing %s: %w", file, err)
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("kubernetes version variable not found in %s", tfEntrypoint)
+	}
+
+	return nil
+}
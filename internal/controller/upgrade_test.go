@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestValidateVersionSkew(t *testing.T) {
+	tests := []struct {
+		name      string
+		current   string
+		target    string
+		expectErr bool
+	}{
+		{name: "next minor", current: "1.29.2", target: "1.30.1"},
+		{name: "patch release", current: "1.29.2", target: "1.29.4"},
+		{name: "provider suffixes", current: "1.29.1-do.0", target: "1.30.2-do.0"},
+		{name: "same version", current: "1.29.2", target: "1.29.2", expectErr: true},
+		{name: "skipping a minor", current: "1.28.2", target: "1.30.1", expectErr: true},
+		{name: "downgrade", current: "1.30.1", target: "1.29.2", expectErr: true},
+		{name: "patch downgrade", current: "1.30.2", target: "1.30.1", expectErr: true},
+		{name: "major upgrade", current: "1.30.1", target: "2.0.0", expectErr: true},
+		{name: "invalid version", current: "1.30.1", target: "latest", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateVersionSkew(tt.current, tt.target)
+			if tt.expectErr && err == nil {
+				t.Fatal("expected an error, got none")
+			}
+			if !tt.expectErr && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestUpgradableVersions(t *testing.T) {
+	available := []string{"1.28.9", "1.29.2", "1.29.4", "1.30.1", "1.31.0"}
+
+	got := UpgradableVersions("1.29.2", available)
+	expected := []string{"1.29.4", "1.30.1"}
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestUpdateKubernetesVersionVar(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		expected  string
+		expectErr bool
+	}{
+		{
+			name: "kubernetes version",
+			content: `module "mgmt" {
+  kubernetes_version = "1.29.2"
+}
+`,
+			expected: `module "mgmt" {
+  kubernetes_version = "1.30.1"
+}
+`,
+		},
+		{
+			name: "cluster version",
+			content: `module "eks" {
+  cluster_version = "1.29.2"
+}
+`,
+			expected: `module "eks" {
+  cluster_version = "1.30.1"
+}
+`,
+		},
+		{
+			name: "tokens are left alone",
+			content: `module "mgmt" {
+  kubernetes_version = "<KUBERNETES_VERSION>"
+}
+`,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			file := filepath.Join(dir, "main.tf")
+			if err := os.WriteFile(file, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := UpdateKubernetesVersionVar(dir, "1.30.1")
+			if tt.expectErr {
+				if err == nil {
+					t.Fatal("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			content, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, content)
+			}
+		})
+	}
+}
@@ -20,12 +20,17 @@ import (
 	vultrext "github.com/konstructio/kubefirst-api/extensions/vultr"
 	"github.com/konstructio/kubefirst-api/internal/k8s"
 	"github.com/konstructio/kubefirst-api/internal/secrets"
+	"github.com/konstructio/kubefirst-api/pkg/types"
 	"github.com/kubefirst/metrics-client/pkg/telemetry"
-	log "github.com/rs/zerolog/log"
+	"k8s.io/client-go/kubernetes"
 )
 
 // RunUsersTerraform
 func (clctrl *ClusterController) RunUsersTerraform() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
 	cl, err := secrets.GetCluster(clctrl.KubernetesClient, clctrl.ClusterName)
 	if err != nil {
 		return fmt.Errorf("failed to get cluster: %w", err)
@@ -51,55 +56,36 @@ func (clctrl *ClusterController) RunUsersTerraform() error {
 		}
 
 		telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.UsersTerraformApplyStarted, "")
-		log.Info().Msg("applying users terraform")
+		clctrl.logger().Info().Msg("applying users terraform")
 
-		tfEnvs := map[string]string{}
+		tfEnvs := clctrl.usersTerraformEnvs(kcfg.Clientset, cl)
 		var tfEntrypoint, terraformClient string
 
-		switch clctrl.CloudProvider {
-		case "akamai":
-			tfEnvs = akamaiext.GetAkamaiTerraformEnvs(tfEnvs, cl)
-			tfEnvs = akamaiext.GetUsersTerraformEnvs(kcfg.Clientset, cl, tfEnvs)
-		case "aws":
-			tfEnvs = awsext.GetAwsTerraformEnvs(tfEnvs, cl)
-			tfEnvs = awsext.GetUsersTerraformEnvs(kcfg.Clientset, cl, tfEnvs)
-		case "civo":
-			tfEnvs = civoext.GetCivoTerraformEnvs(tfEnvs, cl)
-			tfEnvs = civoext.GetUsersTerraformEnvs(kcfg.Clientset, cl, tfEnvs)
-		case "google":
-			tfEnvs = googleext.GetGoogleTerraformEnvs(tfEnvs, cl)
-			tfEnvs = googleext.GetUsersTerraformEnvs(kcfg.Clientset, cl, tfEnvs)
-		case "digitalocean":
-			tfEnvs = digitaloceanext.GetDigitaloceanTerraformEnvs(tfEnvs, cl)
-			tfEnvs = digitaloceanext.GetUsersTerraformEnvs(kcfg.Clientset, cl, tfEnvs)
-		case "vultr":
-			tfEnvs = vultrext.GetVultrTerraformEnvs(tfEnvs, cl)
-			tfEnvs = vultrext.GetUsersTerraformEnvs(kcfg.Clientset, cl, tfEnvs)
-		case "k3s":
-			tfEnvs = k3sext.GetK3sTerraformEnvs(tfEnvs, cl)
-			tfEnvs = k3sext.GetUsersTerraformEnvs(kcfg.Clientset, cl, tfEnvs)
-		}
 		tfEntrypoint = clctrl.ProviderConfig.GitopsDir + "/terraform/users"
 		terraformClient = clctrl.ProviderConfig.TerraformClient
-		err = terraformext.InitApplyAutoApprove(terraformClient, tfEntrypoint, tfEnvs)
+		err = terraformext.InitApplyAutoApproveContext(clctrl.Context, terraformClient, tfEntrypoint, tfEnvs)
 		if err != nil {
-			log.Error().Msgf("error applying users terraform: %s", err)
-			log.Info().Msg("sleeping 10 seconds before retrying terraform execution once more")
+			clctrl.logger().Error().Msgf("error applying users terraform: %s", err)
+			if err := clctrl.checkCancelled(); err != nil {
+				return err
+			}
+			clctrl.logger().Info().Msg("sleeping 10 seconds before retrying terraform execution once more")
 			time.Sleep(10 * time.Second)
-			err = terraformext.InitApplyAutoApprove(terraformClient, tfEntrypoint, tfEnvs)
+			err = terraformext.InitApplyAutoApproveContext(clctrl.Context, terraformClient, tfEntrypoint, tfEnvs)
 			if err != nil {
-				log.Error().Msgf("error applying users terraform: %s", err)
+				clctrl.logger().Error().Msgf("error applying users terraform: %s", err)
 				telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.UsersTerraformApplyFailed, err.Error())
 				return fmt.Errorf("failed to apply users terraform on retry: %w", err)
 			}
 		}
-		log.Info().Msg("executed users terraform successfully")
+		clctrl.logger().Info().Msg("executed users terraform successfully")
 		telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.UsersTerraformApplyCompleted, "")
 
 		clctrl.VaultAuth.RootToken = tfEnvs["VAULT_TOKEN"]
 
-		clctrl.Cluster.VaultAuth.RootToken = clctrl.VaultAuth.RootToken
-		err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+		err = clctrl.updateCluster(func(cluster *types.Cluster) {
+			cluster.VaultAuth.RootToken = clctrl.VaultAuth.RootToken
+		})
 		if err != nil {
 			return fmt.Errorf("failed to update cluster after applying terraform: %w", err)
 		}
@@ -107,11 +93,12 @@ func (clctrl *ClusterController) RunUsersTerraform() error {
 		// Set kbot password in object
 		err = clctrl.GetUserPassword("kbot")
 		if err != nil {
-			log.Info().Msgf("error fetching kbot password: %s", err)
+			clctrl.logger().Info().Msgf("error fetching kbot password: %s", err)
 		}
 
-		clctrl.Cluster.UsersTerraformApplyCheck = true
-		err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+		err = clctrl.updateCluster(func(cluster *types.Cluster) {
+			cluster.UsersTerraformApplyCheck = true
+		})
 		if err != nil {
 			return fmt.Errorf("failed to update cluster with new status details: %w", err)
 		}
@@ -119,3 +106,34 @@ func (clctrl *ClusterController) RunUsersTerraform() error {
 
 	return nil
 }
+
+// usersTerraformEnvs returns the environment for the users terraform
+func (clctrl *ClusterController) usersTerraformEnvs(clientset kubernetes.Interface, cl *types.Cluster) map[string]string {
+	tfEnvs := map[string]string{}
+
+	switch clctrl.CloudProvider {
+	case "akamai":
+		tfEnvs = akamaiext.GetAkamaiTerraformEnvs(tfEnvs, cl)
+		tfEnvs = akamaiext.GetUsersTerraformEnvs(clientset, cl, tfEnvs)
+	case "aws":
+		tfEnvs = awsext.GetAwsTerraformEnvs(tfEnvs, cl)
+		tfEnvs = awsext.GetUsersTerraformEnvs(clientset, cl, tfEnvs)
+	case "civo":
+		tfEnvs = civoext.GetCivoTerraformEnvs(tfEnvs, cl)
+		tfEnvs = civoext.GetUsersTerraformEnvs(clientset, cl, tfEnvs)
+	case "google":
+		tfEnvs = googleext.GetGoogleTerraformEnvs(tfEnvs, cl)
+		tfEnvs = googleext.GetUsersTerraformEnvs(clientset, cl, tfEnvs)
+	case "digitalocean":
+		tfEnvs = digitaloceanext.GetDigitaloceanTerraformEnvs(tfEnvs, cl)
+		tfEnvs = digitaloceanext.GetUsersTerraformEnvs(clientset, cl, tfEnvs)
+	case "vultr":
+		tfEnvs = vultrext.GetVultrTerraformEnvs(tfEnvs, cl)
+		tfEnvs = vultrext.GetUsersTerraformEnvs(clientset, cl, tfEnvs)
+	case "k3s":
+		tfEnvs = k3sext.GetK3sTerraformEnvs(tfEnvs, cl)
+		tfEnvs = k3sext.GetUsersTerraformEnvs(clientset, cl, tfEnvs)
+	}
+
+	return tfEnvs
+}
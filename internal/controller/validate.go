@@ -0,0 +1,394 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	githubapi "github.com/google/go-github/v45/github"
+	awsinternal "github.com/konstructio/kubefirst-api/internal/aws"
+	"github.com/konstructio/kubefirst-api/internal/civo"
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/digitalocean"
+	"github.com/konstructio/kubefirst-api/internal/github"
+	"github.com/konstructio/kubefirst-api/internal/gitlab"
+	"github.com/konstructio/kubefirst-api/internal/metrics"
+	"github.com/konstructio/kubefirst-api/internal/vultr"
+	google "github.com/konstructio/kubefirst-api/pkg/google"
+	"github.com/konstructio/kubefirst-api/pkg/types"
+	"github.com/linode/linodego"
+	"golang.org/x/oauth2"
+)
+
+// Validation check names
+const (
+	ValidationCheckGitToken         = "git_token"
+	ValidationCheckGitRepositories  = "git_repositories"
+	ValidationCheckCloudCredentials = "cloud_credentials"
+	ValidationCheckRegion           = "region"
+	ValidationCheckNodeType         = "node_type"
+	ValidationCheckDomain           = "domain"
+	ValidationCheckK3sHosts         = "k3s_hosts"
+)
+
+// ValidateDefinition runs the pre-flight checks for a cluster definition in
+// parallel. Every check is read-only, so a definition can be validated as
+// often as needed before it is used to create a cluster.
+func ValidateDefinition(ctx context.Context, def *types.ClusterDefinition) *types.ClusterValidationResponse {
+	clctrl := &ClusterController{
+		Context:          ctx,
+		CloudProvider:    def.CloudProvider,
+		CloudRegion:      def.CloudRegion,
+		ClusterName:      def.ClusterName,
+		DomainName:       def.DomainName,
+		DNSProvider:      def.DNSProvider,
+		NodeType:         def.NodeType,
+		NodeCount:        def.NodeCount,
+		GitProvider:      def.GitProvider,
+		GitAuth:          def.GitAuth,
+		AkamaiAuth:       def.AkamaiAuth,
+		AWSAuth:          def.AWSAuth,
+		CivoAuth:         def.CivoAuth,
+		DigitaloceanAuth: def.DigitaloceanAuth,
+		VultrAuth:        def.VultrAuth,
+		GoogleAuth:       def.GoogleAuth,
+		K3sAuth:          def.K3sAuth,
+		CloudflareAuth:   def.CloudflareAuth,
+		Repositories:     []string{"gitops", "metaphor"},
+	}
+
+	if def.CloudProvider == "aws" || def.DNSProvider == "aws" {
+		conf, err := awsinternal.NewAwsV3(def.CloudRegion, def.AWSAuth.AccessKeyID, def.AWSAuth.SecretAccessKey, def.AWSAuth.SessionToken)
+		if err != nil {
+			return &types.ClusterValidationResponse{
+				Findings: []types.ValidationFinding{failFinding(
+					ValidationCheckCloudCredentials,
+					fmt.Sprintf("unable to create aws client: %s", err),
+					"Provide an access key id, secret access key and session token for the aws account",
+				)},
+			}
+		}
+		clctrl.AwsClient = &awsinternal.Configuration{Config: conf}
+	}
+
+	checks := []func() types.ValidationFinding{
+		clctrl.validateGitToken,
+		clctrl.validateGitRepositories,
+		clctrl.validateCloudCredentials,
+		clctrl.validateRegion,
+		clctrl.validateNodeType,
+		clctrl.validateDomain,
+	}
+	if def.CloudProvider == "k3s" {
+		checks = append(checks, clctrl.validateK3sHosts)
+	}
+
+	findings := make([]types.ValidationFinding, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			findings[i] = check()
+		}()
+	}
+	wg.Wait()
+
+	response := &types.ClusterValidationResponse{
+		Valid:    true,
+		Findings: findings,
+	}
+	for _, finding := range findings {
+		if finding.Status == constants.CheckStatusFail {
+			response.Valid = false
+		}
+	}
+
+	return response
+}
+
+func passFinding(check, message string) types.ValidationFinding {
+	return types.ValidationFinding{Check: check, Status: constants.CheckStatusPass, Message: message}
+}
+
+func warnFinding(check, message, remediation string) types.ValidationFinding {
+	return types.ValidationFinding{Check: check, Status: constants.CheckStatusWarn, Message: message, Remediation: remediation}
+}
+
+func failFinding(check, message, remediation string) types.ValidationFinding {
+	return types.ValidationFinding{Check: check, Status: constants.CheckStatusFail, Message: message, Remediation: remediation}
+}
+
+// validateGitToken checks that the git token carries every scope kubefirst needs
+func (clctrl *ClusterController) validateGitToken() types.ValidationFinding {
+	var err error
+	switch clctrl.GitProvider {
+	case "github":
+		err = github.VerifyTokenPermissions(clctrl.GitAuth.Token)
+	case "gitlab":
+		err = gitlab.VerifyTokenPermissions(clctrl.GitAuth.Token)
+	default:
+		return failFinding(ValidationCheckGitToken, fmt.Sprintf("unsupported git provider %q", clctrl.GitProvider), "Use github or gitlab as the git provider")
+	}
+	if err != nil {
+		return failFinding(
+			ValidationCheckGitToken,
+			err.Error(),
+			fmt.Sprintf("Create a %s personal access token with the missing scopes and use it as git_auth.git_token", clctrl.GitProvider),
+		)
+	}
+
+	return passFinding(ValidationCheckGitToken, fmt.Sprintf("%s token has the required scopes", clctrl.GitProvider))
+}
+
+// validateGitRepositories checks that the gitops and metaphor repositories
+// kubefirst creates do not exist yet
+func (clctrl *ClusterController) validateGitRepositories() types.ValidationFinding {
+	existing := []string{}
+
+	switch clctrl.GitProvider {
+	case "github":
+		session := github.New(clctrl.GitAuth.Token)
+		for _, name := range clctrl.Repositories {
+			_, err := session.GetRepo(clctrl.GitAuth.Owner, name)
+			if err == nil {
+				existing = append(existing, fmt.Sprintf("%s/%s", clctrl.GitAuth.Owner, name))
+				continue
+			}
+
+			var errResponse *githubapi.ErrorResponse
+			if !errors.As(err, &errResponse) || errResponse.Response.StatusCode != http.StatusNotFound {
+				return warnFinding(ValidationCheckGitRepositories, fmt.Sprintf("unable to check repository %s: %s", name, err), "Make sure the git owner exists and the token can read its repositories")
+			}
+		}
+	case "gitlab":
+		gitlabClient, err := gitlab.NewGitLabClient(clctrl.GitAuth.Token, clctrl.GitAuth.Owner)
+		if err != nil {
+			return failFinding(ValidationCheckGitRepositories, fmt.Sprintf("unable to find gitlab group %s: %s", clctrl.GitAuth.Owner, err), "Set git_auth.git_owner to a gitlab group the token can access")
+		}
+
+		for _, name := range clctrl.Repositories {
+			exists, err := gitlabClient.CheckProjectExists(name)
+			if err != nil {
+				return warnFinding(ValidationCheckGitRepositories, fmt.Sprintf("unable to check project %s: %s", name, err), "Make sure the token can list the projects of the gitlab group")
+			}
+			if exists {
+				existing = append(existing, fmt.Sprintf("%s/%s", clctrl.GitAuth.Owner, name))
+			}
+		}
+	default:
+		return failFinding(ValidationCheckGitRepositories, fmt.Sprintf("unsupported git provider %q", clctrl.GitProvider), "Use github or gitlab as the git provider")
+	}
+
+	if len(existing) > 0 {
+		return failFinding(
+			ValidationCheckGitRepositories,
+			fmt.Sprintf("repositories %s already exist", strings.Join(existing, ", ")),
+			"Delete or rename the existing repositories, or use a different git owner",
+		)
+	}
+
+	return passFinding(ValidationCheckGitRepositories, "gitops and metaphor repositories do not exist yet")
+}
+
+// validateCloudCredentials checks that the cloud provider accepts the credentials
+func (clctrl *ClusterController) validateCloudCredentials() types.ValidationFinding {
+	message, err := clctrl.checkCloudCredentials()
+
+	var skipped skippedError
+	switch {
+	case errors.As(err, &skipped):
+		return passFinding(ValidationCheckCloudCredentials, err.Error())
+	case err != nil:
+		return failFinding(ValidationCheckCloudCredentials, err.Error(), fmt.Sprintf("Check the %s credentials on the definition and their permissions", clctrl.CloudProvider))
+	}
+
+	return passFinding(ValidationCheckCloudCredentials, message)
+}
+
+// validateRegion checks that the region exists and can host the cluster
+func (clctrl *ClusterController) validateRegion() types.ValidationFinding {
+	remediation := fmt.Sprintf("Choose one of the regions returned by POST /api/v1/region/%s", clctrl.CloudProvider)
+
+	var regions []string
+	var err error
+
+	switch clctrl.CloudProvider {
+	case "aws":
+		if _, err := clctrl.AwsClient.CheckAvailabilityZones(clctrl.CloudRegion); err != nil {
+			return failFinding(ValidationCheckRegion, err.Error(), "Choose a region with enough availability zones")
+		}
+		return passFinding(ValidationCheckRegion, fmt.Sprintf("aws region %s has enough availability zones", clctrl.CloudRegion))
+	case "akamai":
+		var linodeRegions []linodego.Region
+		linodeRegions, err = clctrl.linodeClient().ListRegions(clctrl.Context, &linodego.ListOptions{})
+		for _, region := range linodeRegions {
+			regions = append(regions, region.ID)
+		}
+	case "civo":
+		regions, err = clctrl.civoConfiguration().GetRegions()
+	case "digitalocean":
+		if err := clctrl.digitaloceanConfiguration().ValidateRegion(clctrl.CloudRegion); err != nil {
+			return failFinding(ValidationCheckRegion, err.Error(), remediation)
+		}
+		return passFinding(ValidationCheckRegion, fmt.Sprintf("digitalocean region %s is available", clctrl.CloudRegion))
+	case "google":
+		regions, err = clctrl.googleConfiguration().GetRegions()
+	case "vultr":
+		regions, err = clctrl.vultrConfiguration().GetRegions()
+	case "k3s":
+		return passFinding(ValidationCheckRegion, "k3s runs on the provided hosts")
+	default:
+		return failFinding(ValidationCheckRegion, fmt.Sprintf("unsupported cloud provider %q", clctrl.CloudProvider), "Use one of akamai, aws, civo, digitalocean, google, k3s or vultr")
+	}
+	if err != nil {
+		return warnFinding(ValidationCheckRegion, fmt.Sprintf("unable to list %s regions: %s", clctrl.CloudProvider, err), "Check the cloud credentials and try again")
+	}
+
+	if !slices.Contains(regions, clctrl.CloudRegion) {
+		return failFinding(ValidationCheckRegion, fmt.Sprintf("%s region %q does not exist", clctrl.CloudProvider, clctrl.CloudRegion), remediation)
+	}
+
+	return passFinding(ValidationCheckRegion, fmt.Sprintf("%s region %s is available", clctrl.CloudProvider, clctrl.CloudRegion))
+}
+
+// validateNodeType checks that the node type is offered in the region
+func (clctrl *ClusterController) validateNodeType() types.ValidationFinding {
+	if clctrl.NodeType == "" {
+		return failFinding(ValidationCheckNodeType, "node type is not set", fmt.Sprintf("Use GET /api/v1/%s/defaults for the recommended node type", clctrl.CloudProvider))
+	}
+
+	var nodeTypes []string
+	var err error
+
+	switch clctrl.CloudProvider {
+	case "aws":
+		nodeTypes, err = clctrl.AwsClient.ListInstanceSizesForRegion()
+	case "akamai":
+		var linodeTypes []linodego.LinodeType
+		linodeTypes, err = clctrl.linodeClient().ListTypes(clctrl.Context, &linodego.ListOptions{})
+		for _, linodeType := range linodeTypes {
+			nodeTypes = append(nodeTypes, linodeType.ID)
+		}
+	case "civo":
+		nodeTypes, err = clctrl.civoConfiguration().ListInstanceSizes()
+	case "digitalocean":
+		nodeTypes, err = clctrl.digitaloceanConfiguration().ListInstances()
+	case "google":
+		nodeTypes, err = clctrl.googleNodeTypes()
+	case "vultr":
+		nodeTypes, err = clctrl.vultrConfiguration().ListInstances()
+	case "k3s":
+		return passFinding(ValidationCheckNodeType, "k3s runs on the provided hosts")
+	default:
+		return failFinding(ValidationCheckNodeType, fmt.Sprintf("unsupported cloud provider %q", clctrl.CloudProvider), "Use one of akamai, aws, civo, digitalocean, google, k3s or vultr")
+	}
+	if err != nil {
+		return warnFinding(ValidationCheckNodeType, fmt.Sprintf("unable to list %s node types: %s", clctrl.CloudProvider, err), "Check the cloud credentials and try again")
+	}
+
+	if !slices.Contains(nodeTypes, clctrl.NodeType) {
+		return failFinding(
+			ValidationCheckNodeType,
+			fmt.Sprintf("node type %q is not available in %s region %s", clctrl.NodeType, clctrl.CloudProvider, clctrl.CloudRegion),
+			fmt.Sprintf("Choose one of the node types returned by POST /api/v1/instance-sizes/%s", clctrl.CloudProvider),
+		)
+	}
+
+	return passFinding(ValidationCheckNodeType, fmt.Sprintf("node type %s is available in region %s", clctrl.NodeType, clctrl.CloudRegion))
+}
+
+// googleNodeTypes lists the machine types of the first zone in the region
+func (clctrl *ClusterController) googleNodeTypes() ([]string, error) {
+	googleConf := clctrl.googleConfiguration()
+
+	zones, err := googleConf.GetZones()
+	if err != nil {
+		return nil, fmt.Errorf("error listing zones: %w", err)
+	}
+
+	for _, zone := range zones {
+		if strings.HasPrefix(zone, clctrl.CloudRegion+"-") {
+			return googleConf.ListInstances(zone)
+		}
+	}
+
+	return nil, fmt.Errorf("no zones found in region %s", clctrl.CloudRegion)
+}
+
+// validateDomain checks that the domain is hosted by the dns provider
+func (clctrl *ClusterController) validateDomain() types.ValidationFinding {
+	if err := clctrl.CheckDomain(); err != nil {
+		return failFinding(
+			ValidationCheckDomain,
+			err.Error(),
+			fmt.Sprintf("Host %s in the %s account used for this cluster and delegate its NS records to it", clctrl.DomainName, clctrl.DNSProvider),
+		)
+	}
+
+	return passFinding(ValidationCheckDomain, fmt.Sprintf("domain %s is hosted by %s", clctrl.DomainName, clctrl.DNSProvider))
+}
+
+// validateK3sHosts checks that the hosts k3s is installed on are described
+func (clctrl *ClusterController) validateK3sHosts() types.ValidationFinding {
+	switch {
+	case len(clctrl.K3sAuth.K3sServersPrivateIps) == 0:
+		return failFinding(ValidationCheckK3sHosts, "no server private ips provided", "Set k3s_auth.servers_private_ips to the hosts k3s is installed on")
+	case clctrl.K3sAuth.K3sSSHUser == "" || clctrl.K3sAuth.K3sSSHPrivateKey == "":
+		return failFinding(ValidationCheckK3sHosts, "ssh user or private key missing", "Set k3s_auth.ssh_user and k3s_auth.ssh_privatekey for the hosts")
+	case len(clctrl.K3sAuth.K3sServersPublicIps) == 0:
+		return warnFinding(ValidationCheckK3sHosts, "no server public ips provided", "Set k3s_auth.servers_public_ips if the hosts are not reachable on their private ips")
+	}
+
+	return passFinding(ValidationCheckK3sHosts, fmt.Sprintf("%d k3s servers provided", len(clctrl.K3sAuth.K3sServersPrivateIps)))
+}
+
+func (clctrl *ClusterController) linodeClient() *linodego.Client {
+	client := linodego.NewClient(&http.Client{
+		Transport: &oauth2.Transport{
+			Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: clctrl.AkamaiAuth.Token}),
+			Base:   metrics.Transport(metrics.ServiceAkamai, nil),
+		},
+	})
+	return &client
+}
+
+func (clctrl *ClusterController) civoConfiguration() *civo.Configuration {
+	return &civo.Configuration{
+		Client:  civo.NewCivo(clctrl.CivoAuth.Token, clctrl.CloudRegion),
+		Context: clctrl.Context,
+	}
+}
+
+func (clctrl *ClusterController) digitaloceanConfiguration() *digitalocean.Configuration {
+	return &digitalocean.Configuration{
+		Client:  digitalocean.NewDigitalocean(clctrl.DigitaloceanAuth.Token),
+		Context: clctrl.Context,
+	}
+}
+
+func (clctrl *ClusterController) googleConfiguration() *google.Configuration {
+	return &google.Configuration{
+		Context: clctrl.Context,
+		Project: clctrl.GoogleAuth.ProjectID,
+		Region:  clctrl.CloudRegion,
+		KeyFile: clctrl.GoogleAuth.KeyFile,
+	}
+}
+
+func (clctrl *ClusterController) vultrConfiguration() *vultr.Configuration {
+	return &vultr.Configuration{
+		Client:  vultr.NewVultr(clctrl.VultrAuth.Token),
+		Context: clctrl.Context,
+	}
+}
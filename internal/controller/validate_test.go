@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/pkg/types"
+)
+
+func TestValidateK3sHosts(t *testing.T) {
+	tests := []struct {
+		name     string
+		auth     types.K3sAuth
+		expected string
+	}{
+		{
+			name: "complete",
+			auth: types.K3sAuth{
+				K3sServersPrivateIps: []string{"10.0.0.1"},
+				K3sServersPublicIps:  []string{"192.0.2.1"},
+				K3sSSHUser:           "root",
+				K3sSSHPrivateKey:     "key",
+			},
+			expected: constants.CheckStatusPass,
+		},
+		{
+			name: "no public ips",
+			auth: types.K3sAuth{
+				K3sServersPrivateIps: []string{"10.0.0.1"},
+				K3sSSHUser:           "root",
+				K3sSSHPrivateKey:     "key",
+			},
+			expected: constants.CheckStatusWarn,
+		},
+		{
+			name: "no ssh key",
+			auth: types.K3sAuth{
+				K3sServersPrivateIps: []string{"10.0.0.1"},
+				K3sSSHUser:           "root",
+			},
+			expected: constants.CheckStatusFail,
+		},
+		{
+			name:     "no servers",
+			expected: constants.CheckStatusFail,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clctrl := &ClusterController{K3sAuth: tt.auth}
+
+			finding := clctrl.validateK3sHosts()
+			if finding.Status != tt.expected {
+				t.Errorf("expected status %q, got %q: %s", tt.expected, finding.Status, finding.Message)
+			}
+
+			if finding.Status != constants.CheckStatusPass && finding.Remediation == "" {
+				t.Errorf("expected remediation for %s finding", finding.Status)
+			}
+		})
+	}
+}
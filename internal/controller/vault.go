@@ -28,10 +28,12 @@ import (
 	"github.com/konstructio/kubefirst-api/internal/k8s"
 	"github.com/konstructio/kubefirst-api/internal/secrets"
 	"github.com/konstructio/kubefirst-api/internal/vault"
+	"github.com/konstructio/kubefirst-api/pkg/types"
 	"github.com/kubefirst/metrics-client/pkg/telemetry"
 	log "github.com/rs/zerolog/log"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 )
 
 // InitializeVault
@@ -49,8 +51,9 @@ func (clctrl *ClusterController) GetUserPassword(user string) error {
 		return fmt.Errorf("failed to get user password from vault: %w", err)
 	}
 
-	clctrl.Cluster.VaultAuth.KbotPassword = clctrl.VaultAuth.KbotPassword
-	err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+	err = clctrl.updateCluster(func(cluster *types.Cluster) {
+		cluster.VaultAuth.KbotPassword = clctrl.VaultAuth.KbotPassword
+	})
 	if err != nil {
 		return fmt.Errorf("failed to update the cluster with new kbot password: %w", err)
 	}
@@ -60,6 +63,10 @@ func (clctrl *ClusterController) GetUserPassword(user string) error {
 
 // InitializeVault
 func (clctrl *ClusterController) InitializeVault() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
 	cl, err := secrets.GetCluster(clctrl.KubernetesClient, clctrl.ClusterName)
 	if err != nil {
 		return fmt.Errorf("failed to get cluster for vault initialization: %w", err)
@@ -127,27 +134,28 @@ func (clctrl *ClusterController) InitializeVault() error {
 			if err != nil {
 				return fmt.Errorf("failed to split kustomize yaml output for vault: %w", err)
 			}
-			err = kcfg.ApplyObjects(output)
+			err = kcfg.ApplyObjectsContext(clctrl.Context, output)
 			if err != nil {
 				return fmt.Errorf("failed to apply kustomize objects for vault: %w", err)
 			}
 
 			// Wait for the Job to finish
-			job, err := k8s.ReturnJobObject(kcfg.Clientset, "vault", "vault-handler")
+			job, err := k8s.ReturnJobObjectContext(clctrl.Context, kcfg.Clientset, "vault", "vault-handler")
 			if err != nil {
 				return fmt.Errorf("failed to return vault handler job object: %w", err)
 			}
-			_, err = k8s.WaitForJobComplete(kcfg.Clientset, job.Name, job.Namespace, 240)
+			_, err = k8s.WaitForJobCompleteContext(clctrl.Context, kcfg.Clientset, job.Name, job.Namespace, 240)
 			if err != nil {
 				msg := fmt.Sprintf("could not run vault unseal job: %s", err)
 				telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.VaultInitializationFailed, err.Error())
-				log.Error().Msg(msg)
+				clctrl.logger().Error().Msg(msg)
 			}
 		}
 		telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.VaultInitializationCompleted, "")
 
-		clctrl.Cluster.VaultInitializedCheck = true
-		err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+		err = clctrl.updateCluster(func(cluster *types.Cluster) {
+			cluster.VaultInitializedCheck = true
+		})
 		if err != nil {
 			return fmt.Errorf("failed to update cluster to indicate vault is initialized: %w", err)
 		}
@@ -158,6 +166,10 @@ func (clctrl *ClusterController) InitializeVault() error {
 
 // RunVaultTerraform
 func (clctrl *ClusterController) RunVaultTerraform() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
 	cl, err := secrets.GetCluster(clctrl.KubernetesClient, clctrl.ClusterName)
 	if err != nil {
 		return fmt.Errorf("failed to get cluster for vault terraform execution: %w", err)
@@ -184,78 +196,42 @@ func (clctrl *ClusterController) RunVaultTerraform() error {
 
 		telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.VaultTerraformApplyStarted, "")
 
-		tfEnvs := map[string]string{}
-
-		// Common TfEnvs
-		var usernamePasswordString, base64DockerAuth, registryAuth string
-
+		var registryAuth string
 		if clctrl.GitProvider == "gitlab" {
 			registryAuth, err = clctrl.ContainerRegistryAuth()
 			if err != nil {
 				return fmt.Errorf("failed to get container registry auth for gitlab: %w", err)
 			}
-
-			usernamePasswordString = fmt.Sprintf("%s:%s", "container-registry-auth", registryAuth)
-			base64DockerAuth = base64.StdEncoding.EncodeToString([]byte(usernamePasswordString))
-		} else {
-			usernamePasswordString = fmt.Sprintf("%s:%s", clctrl.GitAuth.User, clctrl.GitAuth.Token)
-			base64DockerAuth = base64.StdEncoding.EncodeToString([]byte(usernamePasswordString))
 		}
 
-		tfEnvs["TF_VAR_b64_docker_auth"] = base64DockerAuth
-
-		if clctrl.GitProvider == "gitlab" {
-			tfEnvs["TF_VAR_container_registry_auth"] = registryAuth
-			tfEnvs["TF_VAR_owner_group_id"] = strconv.Itoa(clctrl.GitlabOwnerGroupID)
-		}
-
-		// Specific TfEnvs
-		switch clctrl.CloudProvider {
-		case "akamai":
-			tfEnvs = akamaiext.GetVaultTerraformEnvs(kcfg.Clientset, cl, tfEnvs)
-			tfEnvs = akamaiext.GetAkamaiTerraformEnvs(tfEnvs, cl)
-		case "aws":
-			tfEnvs = awsext.GetVaultTerraformEnvs(kcfg.Clientset, cl, tfEnvs)
-			tfEnvs = awsext.GetAwsTerraformEnvs(tfEnvs, cl)
-		case "civo":
-			tfEnvs = civoext.GetVaultTerraformEnvs(kcfg.Clientset, cl, tfEnvs)
-			tfEnvs = civoext.GetCivoTerraformEnvs(tfEnvs, cl)
-		case "google":
-			tfEnvs = googleext.GetVaultTerraformEnvs(kcfg.Clientset, cl, tfEnvs)
-			tfEnvs = googleext.GetGoogleTerraformEnvs(tfEnvs, cl)
-		case "digitalocean":
-			tfEnvs = digitaloceanext.GetVaultTerraformEnvs(kcfg.Clientset, cl, tfEnvs)
-			tfEnvs = digitaloceanext.GetDigitaloceanTerraformEnvs(tfEnvs, cl)
-		case "vultr":
-			tfEnvs = vultrext.GetVaultTerraformEnvs(kcfg.Clientset, cl, tfEnvs)
-			tfEnvs = vultrext.GetVultrTerraformEnvs(tfEnvs, cl)
-		case "k3s":
-			tfEnvs = k3sext.GetVaultTerraformEnvs(kcfg.Clientset, cl, tfEnvs)
-			tfEnvs = k3sext.GetK3sTerraformEnvs(tfEnvs, cl)
-		}
+		tfEnvs := clctrl.vaultTerraformEnvs(kcfg.Clientset, cl, registryAuth)
 
 		tfEntrypoint := clctrl.ProviderConfig.GitopsDir + "/terraform/vault"
 		tfClient := clctrl.ProviderConfig.TerraformClient
 
-		log.Info().Msg("configuring vault with terraform")
-		err = terraformext.InitApplyAutoApprove(tfClient, tfEntrypoint, tfEnvs)
+		clctrl.logger().Info().Msg("configuring vault with terraform")
+		err = terraformext.InitApplyAutoApproveContext(clctrl.Context, tfClient, tfEntrypoint, tfEnvs)
 		if err != nil {
-			log.Error().Msgf("error applying vault terraform: %s", err)
-			log.Info().Msg("sleeping 10 seconds before retrying terraform execution once more")
+			clctrl.logger().Error().Msgf("error applying vault terraform: %s", err)
+			if err := clctrl.checkCancelled(); err != nil {
+				return err
+			}
+			clctrl.logger().Info().Msg("sleeping 10 seconds before retrying terraform execution once more")
 			time.Sleep(10 * time.Second)
-			err = terraformext.InitApplyAutoApprove(tfClient, tfEntrypoint, tfEnvs)
+			err = terraformext.InitApplyAutoApproveContext(clctrl.Context, tfClient, tfEntrypoint, tfEnvs)
 			if err != nil {
-				log.Error().Msgf("error applying vault terraform on retry: %s", err)
+				clctrl.logger().Error().Msgf("error applying vault terraform on retry: %s", err)
 				telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.VaultTerraformApplyFailed, err.Error())
 				return fmt.Errorf("failed to apply vault terraform configuration after retry: %w", err)
 			}
 		}
 
-		log.Info().Msg("vault terraform executed successfully")
+		clctrl.logger().Info().Msg("vault terraform executed successfully")
 		telemetry.SendEvent(clctrl.TelemetryEvent, telemetry.VaultTerraformApplyCompleted, "")
 
-		clctrl.Cluster.VaultTerraformApplyCheck = true
-		err = secrets.UpdateCluster(clctrl.KubernetesClient, clctrl.Cluster)
+		err = clctrl.updateCluster(func(cluster *types.Cluster) {
+			cluster.VaultTerraformApplyCheck = true
+		})
 		if err != nil {
 			return fmt.Errorf("failed to update cluster after vault terraform execution: %w", err)
 		}
@@ -264,7 +240,58 @@ func (clctrl *ClusterController) RunVaultTerraform() error {
 	return nil
 }
 
+// vaultTerraformEnvs returns the environment for the vault terraform. For
+// gitlab, registryAuth is the deploy token for the container registry.
+func (clctrl *ClusterController) vaultTerraformEnvs(clientset kubernetes.Interface, cl *types.Cluster, registryAuth string) map[string]string {
+	tfEnvs := map[string]string{}
+
+	// Common TfEnvs
+	var usernamePasswordString string
+	if clctrl.GitProvider == "gitlab" {
+		usernamePasswordString = fmt.Sprintf("%s:%s", "container-registry-auth", registryAuth)
+	} else {
+		usernamePasswordString = fmt.Sprintf("%s:%s", clctrl.GitAuth.User, clctrl.GitAuth.Token)
+	}
+	tfEnvs["TF_VAR_b64_docker_auth"] = base64.StdEncoding.EncodeToString([]byte(usernamePasswordString))
+
+	if clctrl.GitProvider == "gitlab" {
+		tfEnvs["TF_VAR_container_registry_auth"] = registryAuth
+		tfEnvs["TF_VAR_owner_group_id"] = strconv.Itoa(clctrl.GitlabOwnerGroupID)
+	}
+
+	// Specific TfEnvs
+	switch clctrl.CloudProvider {
+	case "akamai":
+		tfEnvs = akamaiext.GetVaultTerraformEnvs(clientset, cl, tfEnvs)
+		tfEnvs = akamaiext.GetAkamaiTerraformEnvs(tfEnvs, cl)
+	case "aws":
+		tfEnvs = awsext.GetVaultTerraformEnvs(clientset, cl, tfEnvs)
+		tfEnvs = awsext.GetAwsTerraformEnvs(tfEnvs, cl)
+	case "civo":
+		tfEnvs = civoext.GetVaultTerraformEnvs(clientset, cl, tfEnvs)
+		tfEnvs = civoext.GetCivoTerraformEnvs(tfEnvs, cl)
+	case "google":
+		tfEnvs = googleext.GetVaultTerraformEnvs(clientset, cl, tfEnvs)
+		tfEnvs = googleext.GetGoogleTerraformEnvs(tfEnvs, cl)
+	case "digitalocean":
+		tfEnvs = digitaloceanext.GetVaultTerraformEnvs(clientset, cl, tfEnvs)
+		tfEnvs = digitaloceanext.GetDigitaloceanTerraformEnvs(tfEnvs, cl)
+	case "vultr":
+		tfEnvs = vultrext.GetVaultTerraformEnvs(clientset, cl, tfEnvs)
+		tfEnvs = vultrext.GetVultrTerraformEnvs(tfEnvs, cl)
+	case "k3s":
+		tfEnvs = k3sext.GetVaultTerraformEnvs(clientset, cl, tfEnvs)
+		tfEnvs = k3sext.GetK3sTerraformEnvs(tfEnvs, cl)
+	}
+
+	return tfEnvs
+}
+
 func (clctrl *ClusterController) WriteVaultSecrets() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
 	cl, err := secrets.GetCluster(clctrl.KubernetesClient, clctrl.ClusterName)
 	if err != nil {
 		return fmt.Errorf("failed to get cluster when writing vault secrets: %w", err)
@@ -272,11 +299,11 @@ func (clctrl *ClusterController) WriteVaultSecrets() error {
 
 	vaultAddr := "http://localhost:8200"
 
-	vaultClient, err := vaultapi.NewClient(&vaultapi.Config{
+	vaultClient, err := vault.NewClient(&vaultapi.Config{
 		Address: vaultAddr,
 	})
 	if err != nil {
-		log.Error().Msgf("error creating vault client: %s", err)
+		clctrl.logger().Error().Msgf("error creating vault client: %s", err)
 		return fmt.Errorf("failed to create vault client: %w", err)
 	}
 
@@ -320,7 +347,7 @@ func (clctrl *ClusterController) WriteVaultSecrets() error {
 	var vaultRootToken string
 	vaultUnsealSecretData, err := k8s.ReadSecretV2(clientset, "vault", "vault-unseal-secret")
 	if err != nil {
-		log.Error().Msgf("error reading vault-unseal-secret: %s", err)
+		clctrl.logger().Error().Msgf("error reading vault-unseal-secret: %s", err)
 	}
 	if len(vaultUnsealSecretData) != 0 {
 		vaultRootToken = vaultUnsealSecretData["root-token"]
@@ -340,19 +367,19 @@ func (clctrl *ClusterController) WriteVaultSecrets() error {
 		k8s.CreateSecretV2(kcfg.Clientset, secretToCreate)
 	}
 
-	_, err = vaultClient.KVv2("secret").Put(context.Background(), "external-dns", map[string]interface{}{
+	_, err = vaultClient.KVv2("secret").Put(clctrl.Context, "external-dns", map[string]interface{}{
 		"token": externalDNSToken,
 	})
 	if err != nil {
-		log.Error().Msgf("error writing secret to vault: %s", err)
+		clctrl.logger().Error().Msgf("error writing secret to vault: %s", err)
 		return fmt.Errorf("failed to write external-dns secret to vault: %w", err)
 	}
 
-	_, err = vaultClient.KVv2("secret").Put(context.Background(), "cloudflare", map[string]interface{}{
+	_, err = vaultClient.KVv2("secret").Put(clctrl.Context, "cloudflare", map[string]interface{}{
 		"origin-ca-api-key": cl.CloudflareAuth.OriginCaIssuerKey,
 	})
 	if err != nil {
-		log.Error().Msgf("error writing secret to vault: %s", err)
+		clctrl.logger().Error().Msgf("error writing secret to vault: %s", err)
 		return fmt.Errorf("failed to write cloudflare secret to vault: %w", err)
 	}
 
@@ -362,25 +389,68 @@ func (clctrl *ClusterController) WriteVaultSecrets() error {
 	// })
 
 	if cl.CloudProvider == "google" {
-		log.Info().Msg("writing google specific secrets to vault secret store")
+		clctrl.logger().Info().Msg("writing google specific secrets to vault secret store")
 		homeDir, err := os.UserHomeDir()
 		if err != nil {
-			log.Error().Msgf("error getting home path: %s", err)
+			clctrl.logger().Error().Msgf("error getting home path: %s", err)
 			return fmt.Errorf("failed to get home path: %w", err)
 		}
 		if err := writeGoogleSecrets(homeDir, vaultClient); err != nil {
-			log.Error().Msgf("error writing Google secrets to vault: %s", err)
+			clctrl.logger().Error().Msgf("error writing Google secrets to vault: %s", err)
 			return fmt.Errorf("failed to write google-specific secrets to vault: %w", err)
 		}
-		log.Info().Msg("successfully wrote google specific secrets to vault")
+		clctrl.logger().Info().Msg("successfully wrote google specific secrets to vault")
+	}
+
+	clctrl.logger().Info().Msg("successfully wrote platform secrets to vault secret store")
+	return nil
+}
+
+// OpenVaultPortForward forwards the local vault port to vault-0 for the rest
+// of the pipeline run. The forward is closed when the pipeline finishes.
+func (clctrl *ClusterController) OpenVaultPortForward() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
+	if clctrl.vaultStopChannel != nil {
+		return nil
+	}
+
+	kcfg, err := clctrl.ClusterKubernetesClient()
+	if err != nil {
+		return err
+	}
+
+	clctrl.vaultStopChannel = make(chan struct{}, 1)
+	if err := k8s.OpenPortForwardPodWrapper(
+		kcfg.Clientset,
+		kcfg.RestConfig,
+		"vault-0",
+		"vault",
+		8200,
+		8200,
+		clctrl.vaultStopChannel,
+	); err != nil {
+		clctrl.logger().Warn().Msgf("unable to open vault port-forward, continuing: %s", err)
 	}
 
-	log.Info().Msg("successfully wrote platform secrets to vault secret store")
 	return nil
 }
 
+func (clctrl *ClusterController) closeVaultPortForward() {
+	if clctrl.vaultStopChannel != nil {
+		close(clctrl.vaultStopChannel)
+		clctrl.vaultStopChannel = nil
+	}
+}
+
 // WaitForVault
 func (clctrl *ClusterController) WaitForVault() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
 	var kcfg *k8s.KubernetesClient
 
 	switch clctrl.CloudProvider {
@@ -400,7 +470,8 @@ func (clctrl *ClusterController) WaitForVault() error {
 		}
 	}
 
-	vaultStatefulSet, err := k8s.ReturnStatefulSetObject(
+	vaultStatefulSet, err := k8s.ReturnStatefulSetObjectContext(
+		clctrl.Context,
 		kcfg.Clientset,
 		"app.kubernetes.io/instance",
 		"vault",
@@ -408,12 +479,12 @@ func (clctrl *ClusterController) WaitForVault() error {
 		1200,
 	)
 	if err != nil {
-		log.Error().Msgf("error finding Vault StatefulSet: %s", err)
+		clctrl.logger().Error().Msgf("error finding Vault StatefulSet: %s", err)
 		return fmt.Errorf("failed to find vault stateful set in Kubernetes: %w", err)
 	}
-	_, err = k8s.WaitForStatefulSetReady(kcfg.Clientset, vaultStatefulSet, 300, true)
+	_, err = k8s.WaitForStatefulSetReadyContext(clctrl.Context, kcfg.Clientset, vaultStatefulSet, 300, true)
 	if err != nil {
-		log.Error().Msgf("error waiting for Vault StatefulSet ready state: %s", err)
+		clctrl.logger().Error().Msgf("error waiting for Vault StatefulSet ready state: %s", err)
 		return fmt.Errorf("failed to wait for vault stateful set to be ready: %w", err)
 	}
 
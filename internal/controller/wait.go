@@ -0,0 +1,112 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package controller
+
+import (
+	"fmt"
+
+	"github.com/konstructio/kubefirst-api/internal/k8s"
+)
+
+// WaitForCrossplane waits for the last sync wave app to transition to Running
+func (clctrl *ClusterController) WaitForCrossplane() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
+	kcfg, err := clctrl.ClusterKubernetesClient()
+	if err != nil {
+		return err
+	}
+
+	clctrl.logger().Info().Msg("waiting for final sync wave Deployment to transition to Running")
+	crossplaneDeployment, err := k8s.ReturnDeploymentObjectContext(
+		clctrl.Context,
+		kcfg.Clientset,
+		"app.kubernetes.io/instance",
+		"crossplane",
+		"crossplane-system",
+		3600,
+	)
+	if err != nil {
+		return fmt.Errorf("error finding crossplane Deployment: %w", err)
+	}
+
+	clctrl.logger().Info().Msg("waiting on dns, tls certificates from letsencrypt and remaining sync waves.\n this may take up to 60 minutes but regularly completes in under 20 minutes")
+	if _, err := k8s.WaitForDeploymentReadyContext(clctrl.Context, kcfg.Clientset, crossplaneDeployment, 3600); err != nil {
+		return fmt.Errorf("error waiting for all Apps to sync ready state: %w", err)
+	}
+
+	return nil
+}
+
+// WaitForKubefirstPro waits for the kubefirst-pro-api Deployment when kubefirst
+// pro was requested
+func (clctrl *ClusterController) WaitForKubefirstPro() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
+	if !clctrl.InstallKubefirstPro {
+		return nil
+	}
+
+	kcfg, err := clctrl.ClusterKubernetesClient()
+	if err != nil {
+		return err
+	}
+
+	clctrl.logger().Info().Msg("waiting for kubefirst-pro-api Deployment to transition to Running")
+	kubefirstProAPI, err := k8s.ReturnDeploymentObjectContext(
+		clctrl.Context,
+		kcfg.Clientset,
+		"app.kubernetes.io/name",
+		"kubefirst-pro-api",
+		"kubefirst",
+		1200,
+	)
+	if err != nil {
+		return fmt.Errorf("error finding kubefirst-pro-api Deployment: %w", err)
+	}
+
+	if _, err := k8s.WaitForDeploymentReadyContext(clctrl.Context, kcfg.Clientset, kubefirstProAPI, 300); err != nil {
+		return fmt.Errorf("error waiting for kubefirst-pro-api to transition to Running: %w", err)
+	}
+
+	return nil
+}
+
+// WaitForArgoCD waits for the argocd-server Deployment to enter the Ready state
+func (clctrl *ClusterController) WaitForArgoCD() error {
+	if err := clctrl.checkCancelled(); err != nil {
+		return err
+	}
+
+	kcfg, err := clctrl.ClusterKubernetesClient()
+	if err != nil {
+		return err
+	}
+
+	clctrl.logger().Info().Msg("waiting for final sync wave Deployment to transition to Running")
+	argocdDeployment, err := k8s.ReturnDeploymentObjectContext(
+		clctrl.Context,
+		kcfg.Clientset,
+		"app.kubernetes.io/name",
+		"argocd-server",
+		"argocd",
+		3600,
+	)
+	if err != nil {
+		return fmt.Errorf("error finding argocd Deployment: %w", err)
+	}
+
+	if _, err := k8s.WaitForDeploymentReadyContext(clctrl.Context, kcfg.Clientset, argocdDeployment, 3600); err != nil {
+		return fmt.Errorf("error waiting for argocd deployment to enter Ready state: %w", err)
+	}
+
+	return nil
+}
@@ -7,14 +7,21 @@ See the LICENSE file for more details.
 package digitalocean
 
 import (
+	"context"
 	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/digitalocean/godo"
+	"github.com/konstructio/kubefirst-api/internal/metrics"
+	"golang.org/x/oauth2"
 )
 
 func NewDigitalocean(digitalOceanToken string) *godo.Client {
-	digitaloceanClient := godo.NewFromToken(digitalOceanToken)
+	cleanToken := strings.Trim(strings.TrimSpace(digitalOceanToken), "'")
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, metrics.HTTPClient(metrics.ServiceDigitalOcean))
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: cleanToken})
+	digitaloceanClient := godo.NewClient(oauth2.NewClient(ctx, ts))
 
 	return digitaloceanClient
 }
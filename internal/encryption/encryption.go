@@ -0,0 +1,120 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package encryption
+
+import (
+	"fmt"
+
+	"github.com/konstructio/kubefirst-api/internal/env"
+	"github.com/konstructio/kubefirst-api/internal/envelope"
+	"github.com/konstructio/kubefirst-api/internal/envelope/awskms"
+	"github.com/konstructio/kubefirst-api/internal/secrets"
+	log "github.com/rs/zerolog/log"
+	"k8s.io/client-go/kubernetes"
+)
+
+// codec is the codec configured for cluster records, if any
+var codec *envelope.Codec
+
+// Configure sets up encryption of cluster records at rest with the provider
+// selected by K1_ENCRYPTION_PROVIDER. When no provider is set, cluster
+// records are stored in plain text.
+func Configure(env env.Env) error {
+	var current envelope.KeyWrapper
+	previous := []envelope.KeyWrapper{}
+
+	switch env.EncryptionProvider {
+	case "":
+		log.Warn().Msg("K1_ENCRYPTION_PROVIDER is not set, cluster credentials are stored unencrypted")
+		return nil
+	case envelope.ProviderLocal:
+		if env.EncryptionKeyFile == "" {
+			return fmt.Errorf("K1_ENCRYPTION_KEY_FILE is required for encryption provider %s", env.EncryptionProvider)
+		}
+
+		wrapper, err := envelope.LoadLocalKeyWrapper(env.EncryptionKeyFile)
+		if err != nil {
+			return err
+		}
+		current = wrapper
+
+		for _, path := range env.EncryptionPrevKeys {
+			wrapper, err := envelope.LoadLocalKeyWrapper(path)
+			if err != nil {
+				return err
+			}
+			previous = append(previous, wrapper)
+		}
+	case awskms.Provider:
+		if env.EncryptionKMSKeyAlias == "" {
+			return fmt.Errorf("K1_ENCRYPTION_KMS_KEY_ALIAS is required for encryption provider %s", env.EncryptionProvider)
+		}
+
+		wrapper, err := awskms.New(env.EncryptionKMSKeyAlias)
+		if err != nil {
+			return err
+		}
+		current = wrapper
+	case envelope.ProviderVault:
+		if env.EncryptionVaultAddr == "" || env.EncryptionVaultToken == "" {
+			return fmt.Errorf("K1_ENCRYPTION_VAULT_ADDR and K1_ENCRYPTION_VAULT_TOKEN are required for encryption provider %s", env.EncryptionProvider)
+		}
+
+		wrapper, err := envelope.NewVaultTransitWrapper(env.EncryptionVaultAddr, env.EncryptionVaultToken, env.EncryptionVaultMount, env.EncryptionVaultKey)
+		if err != nil {
+			return err
+		}
+		current = wrapper
+	default:
+		return fmt.Errorf("unknown encryption provider %q, must be one of %s, %s or %s", env.EncryptionProvider, envelope.ProviderLocal, awskms.Provider, envelope.ProviderVault)
+	}
+
+	codec = envelope.NewCodec(current, previous...)
+	secrets.SetClusterCodec(codec)
+
+	log.Info().Msgf("encrypting cluster credentials with %s key %s", current.Provider(), current.KeyID())
+
+	return nil
+}
+
+// Migrate encrypts the cluster records that are stored in plain text or under
+// a previous key with the current key
+func Migrate(clientSet kubernetes.Interface) error {
+	if codec == nil {
+		return nil
+	}
+
+	rewritten, err := secrets.ReencryptClusters(clientSet, false)
+	if err != nil {
+		return fmt.Errorf("error migrating cluster records: %w", err)
+	}
+
+	if rewritten > 0 {
+		log.Info().Msgf("encrypted %d cluster records with the current key", rewritten)
+	}
+
+	return nil
+}
+
+// Rotate encrypts every cluster record with a new data key wrapped by the
+// current key
+func Rotate(clientSet kubernetes.Interface) error {
+	if codec == nil {
+		return fmt.Errorf("K1_ENCRYPTION_PROVIDER is not set, there is no key to rotate")
+	}
+
+	codec.Rotate()
+
+	rewritten, err := secrets.ReencryptClusters(clientSet, true)
+	if err != nil {
+		return fmt.Errorf("error rotating data key: %w", err)
+	}
+
+	log.Info().Msgf("re-encrypted %d cluster records with a new data key", rewritten)
+
+	return nil
+}
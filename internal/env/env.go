@@ -2,6 +2,7 @@ package env
 
 import (
 	"fmt"
+	"time"
 
 	env "github.com/caarlos0/env/v10"
 	"github.com/joho/godotenv"
@@ -9,25 +10,64 @@ import (
 )
 
 type Env struct {
-	ServerPort            int    `env:"SERVER_PORT" envDefault:"8081"`
-	K1AccessToken         string `env:"K1_ACCESS_TOKEN"`
-	KubefirstVersion      string `env:"KUBEFIRST_VERSION" envDefault:"main"`
-	CloudProvider         string `env:"CLOUD_PROVIDER"`
-	ClusterID             string `env:"CLUSTER_ID"`
-	ClusterType           string `env:"CLUSTER_TYPE"`
-	DomainName            string `env:"DOMAIN_NAME"`
-	GitProvider           string `env:"GIT_PROVIDER"`
-	InstallMethod         string `env:"INSTALL_METHOD"`
-	KubefirstTeam         string `env:"KUBEFIRST_TEAM"`
-	KubefirstTeamInfo     string `env:"KUBEFIRST_TEAM_INFO"`
-	AWSRegion             string `env:"AWS_REGION"`
-	AWSProfile            string `env:"AWS_PROFILE"`
-	IsClusterZero         bool   `env:"IS_CLUSTER_ZERO" envDefault:"true"`
-	ParentClusterID       string `env:"PARENT_CLUSTER_ID"`
-	InCluster             bool   `env:"IN_CLUSTER" envDefault:"false"`
-	EnterpriseAPIURL      string `env:"ENTERPRISE_API_URL"`
-	K1LocalDebug          bool   `env:"K1_LOCAL_DEBUG"`
-	K1LocalKubeconfigPath string `env:"K1_LOCAL_KUBECONFIG_PATH"`
+	ServerPort            int               `env:"SERVER_PORT" envDefault:"8081"`
+	K1AccessToken         string            `env:"K1_ACCESS_TOKEN"`
+	KubefirstVersion      string            `env:"KUBEFIRST_VERSION" envDefault:"main"`
+	CloudProvider         string            `env:"CLOUD_PROVIDER"`
+	ClusterID             string            `env:"CLUSTER_ID"`
+	ClusterType           string            `env:"CLUSTER_TYPE"`
+	DomainName            string            `env:"DOMAIN_NAME"`
+	GitProvider           string            `env:"GIT_PROVIDER"`
+	InstallMethod         string            `env:"INSTALL_METHOD"`
+	KubefirstTeam         string            `env:"KUBEFIRST_TEAM"`
+	KubefirstTeamInfo     string            `env:"KUBEFIRST_TEAM_INFO"`
+	AWSRegion             string            `env:"AWS_REGION"`
+	AWSProfile            string            `env:"AWS_PROFILE"`
+	IsClusterZero         bool              `env:"IS_CLUSTER_ZERO" envDefault:"true"`
+	ParentClusterID       string            `env:"PARENT_CLUSTER_ID"`
+	InCluster             bool              `env:"IN_CLUSTER" envDefault:"false"`
+	EnterpriseAPIURL      string            `env:"ENTERPRISE_API_URL"`
+	K1LocalDebug          bool              `env:"K1_LOCAL_DEBUG"`
+	K1LocalKubeconfigPath string            `env:"K1_LOCAL_KUBECONFIG_PATH"`
+	SecretAllowlist       []string          `env:"K1_SECRET_ALLOWLIST" envDefault:"kubefirst-state" envSeparator:","`
+	StreamTokenKey        string            `env:"K1_STREAM_TOKEN_KEY"`
+	OIDCIssuer            string            `env:"K1_OIDC_ISSUER"`
+	OIDCAudience          string            `env:"K1_OIDC_AUDIENCE"`
+	OIDCJWKSURL           string            `env:"K1_OIDC_JWKS_URL"`
+	OIDCGroupsClaim       string            `env:"K1_OIDC_GROUPS_CLAIM" envDefault:"groups"`
+	OIDCGroupRoles        map[string]string `env:"K1_OIDC_GROUP_ROLES"`
+	OIDCDefaultRole       string            `env:"K1_OIDC_DEFAULT_ROLE"`
+	AuditRetentionDays    int               `env:"K1_AUDIT_RETENTION_DAYS" envDefault:"30"`
+	AuditWebhookURL       string            `env:"K1_AUDIT_WEBHOOK_URL"`
+	EncryptionProvider    string            `env:"K1_ENCRYPTION_PROVIDER"`
+	EncryptionKeyFile     string            `env:"K1_ENCRYPTION_KEY_FILE"`
+	EncryptionPrevKeys    []string          `env:"K1_ENCRYPTION_PREVIOUS_KEY_FILES" envSeparator:","`
+	EncryptionKMSKeyAlias string            `env:"K1_ENCRYPTION_KMS_KEY_ALIAS"`
+	EncryptionVaultAddr   string            `env:"K1_ENCRYPTION_VAULT_ADDR"`
+	EncryptionVaultToken  string            `env:"K1_ENCRYPTION_VAULT_TOKEN"`
+	EncryptionVaultMount  string            `env:"K1_ENCRYPTION_VAULT_TRANSIT_MOUNT" envDefault:"transit"`
+	EncryptionVaultKey    string            `env:"K1_ENCRYPTION_VAULT_TRANSIT_KEY" envDefault:"kubefirst-api"`
+	RateLimitRPS          float64           `env:"K1_RATE_LIMIT_RPS" envDefault:"10"`
+	RateLimitBurst        int               `env:"K1_RATE_LIMIT_BURST" envDefault:"20"`
+	RateLimitStrictRPM    float64           `env:"K1_RATE_LIMIT_STRICT_RPM" envDefault:"6"`
+	RateLimitStrictBurst  int               `env:"K1_RATE_LIMIT_STRICT_BURST" envDefault:"3"`
+	TrustedProxies        []string          `env:"K1_TRUSTED_PROXIES" envSeparator:","`
+	MaxRequestBodyBytes   int64             `env:"K1_MAX_REQUEST_BODY_BYTES" envDefault:"1048576"`
+	CORSAllowedOrigins    []string          `env:"K1_CORS_ALLOWED_ORIGINS" envSeparator:","`
+	CORSAllowedHeaders    []string          `env:"K1_CORS_ALLOWED_HEADERS" envDefault:"Origin,Content-Type,Authorization,If-Match,Last-Event-ID" envSeparator:","`
+	CORSAllowCredentials  bool              `env:"K1_CORS_ALLOW_CREDENTIALS" envDefault:"false"`
+	CORSMaxAge            time.Duration     `env:"K1_CORS_MAX_AGE" envDefault:"12h"`
+	EventsBufferSize      int               `env:"K1_EVENTS_BUFFER_SIZE" envDefault:"1000"`
+	OTLPEndpoint          string            `env:"K1_OTEL_EXPORTER_OTLP_ENDPOINT"`
+	OTLPHeaders           map[string]string `env:"K1_OTEL_EXPORTER_OTLP_HEADERS"`
+	OTLPSampleRatio       float64           `env:"K1_OTEL_SAMPLE_RATIO" envDefault:"1"`
+	LogRetentionRuns      int               `env:"K1_LOG_RETENTION_RUNS" envDefault:"10"`
+	LogRetentionDays      int               `env:"K1_LOG_RETENTION_DAYS" envDefault:"30"`
+	NotificationAttempts  int               `env:"K1_NOTIFICATION_ATTEMPTS" envDefault:"5"`
+	NotificationLogSize   int               `env:"K1_NOTIFICATION_LOG_SIZE" envDefault:"500"`
+	JobHistorySize        int               `env:"K1_JOB_HISTORY_SIZE" envDefault:"200"`
+	DryRunTimeout         time.Duration     `env:"K1_DRY_RUN_TIMEOUT" envDefault:"10m"`
+	APIURL                string            `env:"K1_API_URL"`
 }
 
 func GetEnv(silent bool) (Env, error) {
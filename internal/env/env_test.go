@@ -24,6 +24,7 @@ func TestEnv(t *testing.T) {
 	os.Setenv("IS_CLUSTER_ZERO", "true")
 	os.Setenv("IN_CLUSTER", "false")
 	os.Setenv("ENTERPRISE_API_URL", "enterprise_api_url")
+	os.Setenv("K1_SECRET_ALLOWLIST", "kubefirst-state,kubefirst-console")
 
 	defer func() {
 		os.Unsetenv("SERVER_PORT")
@@ -42,6 +43,7 @@ func TestEnv(t *testing.T) {
 		os.Unsetenv("IS_CLUSTER_ZERO")
 		os.Unsetenv("IN_CLUSTER")
 		os.Unsetenv("ENTERPRISE_API_URL")
+		os.Unsetenv("K1_SECRET_ALLOWLIST")
 	}()
 
 	env := Env{}
@@ -113,4 +115,8 @@ func TestEnv(t *testing.T) {
 	if env.EnterpriseAPIURL != "enterprise_api_url" {
 		t.Errorf("expected EnterpriseApiUrl to be 'enterprise_api_url', but got '%s'", env.EnterpriseAPIURL)
 	}
+
+	if len(env.SecretAllowlist) != 2 || env.SecretAllowlist[0] != "kubefirst-state" || env.SecretAllowlist[1] != "kubefirst-console" {
+		t.Errorf("expected SecretAllowlist to be [kubefirst-state kubefirst-console], but got %v", env.SecretAllowlist)
+	}
 }
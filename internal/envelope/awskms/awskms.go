@@ -0,0 +1,93 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package awskms
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	awsinternal "github.com/konstructio/kubefirst-api/internal/aws"
+	"github.com/konstructio/kubefirst-api/internal/envelope"
+)
+
+// Provider wraps data keys with an AWS KMS key
+const Provider = "awskms"
+
+// encryptionContext binds wrapped data keys to their use, so KMS refuses to
+// decrypt them for anything else
+var encryptionContext = map[string]string{"kubefirst": "cluster-records"}
+
+// KeyWrapper wraps data keys with an AWS KMS key
+type KeyWrapper struct {
+	client *kms.Client
+	keyID  string
+}
+
+var _ envelope.KeyWrapper = (*KeyWrapper)(nil)
+
+// New returns a wrapper for the KMS key with keyAlias, using the AWS region
+// and profile of the environment
+func New(keyAlias string) (*KeyWrapper, error) {
+	conf, err := awsinternal.New()
+	if err != nil {
+		return nil, fmt.Errorf("error creating aws configuration: %w", err)
+	}
+
+	keyID, err := conf.GetKmsKeyID(keyAlias)
+	if err != nil {
+		return nil, fmt.Errorf("error looking up kms key %s: %w", keyAlias, err)
+	}
+	if keyID == "" {
+		return nil, fmt.Errorf("kms key with alias %s not found", keyAlias)
+	}
+
+	return &KeyWrapper{
+		client: kms.NewFromConfig(conf.Config),
+		keyID:  keyID,
+	}, nil
+}
+
+// Provider returns Provider
+func (w *KeyWrapper) Provider() string {
+	return Provider
+}
+
+// KeyID returns the id of the KMS key
+func (w *KeyWrapper) KeyID() string {
+	return w.keyID
+}
+
+// Wrap encrypts dataKey with the KMS key
+func (w *KeyWrapper) Wrap(ctx context.Context, dataKey []byte) ([]byte, error) {
+	output, err := w.client.Encrypt(ctx, &kms.EncryptInput{
+		KeyId:             aws.String(w.keyID),
+		Plaintext:         dataKey,
+		EncryptionContext: encryptionContext,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting with kms key %s: %w", w.keyID, err)
+	}
+
+	return output.CiphertextBlob, nil
+}
+
+// Unwrap decrypts a data key wrapped with the KMS key keyID. Data keys of
+// other KMS keys are decrypted as well, as long as the caller may use them.
+func (w *KeyWrapper) Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	output, err := w.client.Decrypt(ctx, &kms.DecryptInput{
+		KeyId:             aws.String(keyID),
+		CiphertextBlob:    wrapped,
+		EncryptionContext: encryptionContext,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting with kms key %s: %w", keyID, err)
+	}
+
+	return output.Plaintext, nil
+}
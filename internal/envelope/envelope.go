@@ -0,0 +1,273 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package envelope
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	// MetadataKey holds the wrapped data key of a sealed record
+	MetadataKey = "encryption"
+
+	// valuePrefix marks a sealed value. Plain values are JSON encoded and
+	// never start with it.
+	valuePrefix = "enc:v1:"
+
+	dataKeySize = 32
+)
+
+// ErrKeyMismatch is returned by a KeyWrapper asked to unwrap a data key
+// wrapped by another key
+var ErrKeyMismatch = errors.New("data key was wrapped by another key")
+
+// KeyWrapper wraps data keys with a key encryption key held by a KMS
+type KeyWrapper interface {
+	// Provider names the KMS, such as local, awskms or vault
+	Provider() string
+	// KeyID identifies the key encryption key new data keys are wrapped with
+	KeyID() string
+	Wrap(ctx context.Context, dataKey []byte) ([]byte, error)
+	// Unwrap returns ErrKeyMismatch when it cannot unwrap data keys of keyID
+	Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Metadata records how the data key of a sealed record was wrapped
+type Metadata struct {
+	Provider   string `json:"provider"`
+	KeyID      string `json:"key_id"`
+	WrappedKey string `json:"wrapped_key"`
+}
+
+// Codec seals record fields with a data key wrapped by a KeyWrapper. One data
+// key is generated per process and rotation, and unwrapped data keys are
+// cached so reads do not call the KMS every time.
+type Codec struct {
+	current  KeyWrapper
+	previous []KeyWrapper
+
+	mu       sync.Mutex
+	dataKey  []byte
+	metadata *Metadata
+	unsealed map[string][]byte
+}
+
+// NewCodec returns a codec sealing with current. Records sealed under
+// previous keys can still be opened, and are sealed with current when they
+// are written again.
+func NewCodec(current KeyWrapper, previous ...KeyWrapper) *Codec {
+	return &Codec{
+		current:  current,
+		previous: previous,
+		unsealed: map[string][]byte{},
+	}
+}
+
+// Rotate discards the data key, so the next record sealed gets a new one
+func (c *Codec) Rotate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.dataKey = nil
+	c.metadata = nil
+}
+
+// IsCurrent reports whether a record sealed with metadata is sealed under the
+// current key encryption key
+func (c *Codec) IsCurrent(metadata *Metadata) bool {
+	return metadata != nil && metadata.Provider == c.current.Provider() && metadata.KeyID == c.current.KeyID()
+}
+
+// Seal encrypts the values of fields in data, which is bound to record, and
+// stores the wrapped data key under MetadataKey
+func (c *Codec) Seal(ctx context.Context, record string, data map[string][]byte, fields []string) error {
+	dataKey, metadata, err := c.currentDataKey(ctx)
+	if err != nil {
+		return err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		value, ok := data[field]
+		if !ok {
+			continue
+		}
+
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return fmt.Errorf("error generating nonce: %w", err)
+		}
+
+		sealed := aead.Seal(nonce, nonce, value, additionalData(record, field))
+		data[field] = []byte(valuePrefix + base64.StdEncoding.EncodeToString(sealed))
+	}
+
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("error marshalling encryption metadata: %w", err)
+	}
+	data[MetadataKey] = encoded
+
+	return nil
+}
+
+// Open decrypts the sealed values of data, which is bound to record, and
+// removes MetadataKey. Records that are not sealed are left untouched.
+func (c *Codec) Open(ctx context.Context, record string, data map[string]interface{}) error {
+	metadata, err := ReadMetadata(data)
+	if err != nil || metadata == nil {
+		return err
+	}
+
+	dataKey, err := c.unwrap(ctx, metadata)
+	if err != nil {
+		return err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return err
+	}
+
+	for field, value := range data {
+		sealed, ok := value.(string)
+		if !ok || !strings.HasPrefix(sealed, valuePrefix) {
+			continue
+		}
+
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, valuePrefix))
+		if err != nil || len(raw) < aead.NonceSize() {
+			return fmt.Errorf("invalid sealed value for %s of %s", field, record)
+		}
+
+		plaintext, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], additionalData(record, field))
+		if err != nil {
+			return fmt.Errorf("unable to decrypt %s of %s: %w", field, record, err)
+		}
+		data[field] = string(plaintext)
+	}
+
+	delete(data, MetadataKey)
+
+	return nil
+}
+
+// ReadMetadata returns the encryption metadata of a record, or nil when the
+// record is not sealed
+func ReadMetadata(data map[string]interface{}) (*Metadata, error) {
+	value, ok := data[MetadataKey]
+	if !ok {
+		return nil, nil
+	}
+
+	encoded, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid encryption metadata of type %T", value)
+	}
+
+	var metadata Metadata
+	if err := json.Unmarshal([]byte(encoded), &metadata); err != nil {
+		return nil, fmt.Errorf("invalid encryption metadata: %w", err)
+	}
+
+	return &metadata, nil
+}
+
+func (c *Codec) currentDataKey(ctx context.Context) ([]byte, *Metadata, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.dataKey != nil {
+		return c.dataKey, c.metadata, nil
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, fmt.Errorf("error generating data key: %w", err)
+	}
+
+	wrapped, err := c.current.Wrap(ctx, dataKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error wrapping data key with %s key %s: %w", c.current.Provider(), c.current.KeyID(), err)
+	}
+
+	c.dataKey = dataKey
+	c.metadata = &Metadata{
+		Provider:   c.current.Provider(),
+		KeyID:      c.current.KeyID(),
+		WrappedKey: base64.StdEncoding.EncodeToString(wrapped),
+	}
+	c.unsealed[c.metadata.WrappedKey] = dataKey
+
+	return c.dataKey, c.metadata, nil
+}
+
+func (c *Codec) unwrap(ctx context.Context, metadata *Metadata) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if dataKey, ok := c.unsealed[metadata.WrappedKey]; ok {
+		return dataKey, nil
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(metadata.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wrapped data key: %w", err)
+	}
+
+	for _, wrapper := range append([]KeyWrapper{c.current}, c.previous...) {
+		if wrapper.Provider() != metadata.Provider {
+			continue
+		}
+
+		dataKey, err := wrapper.Unwrap(ctx, metadata.KeyID, wrapped)
+		if errors.Is(err, ErrKeyMismatch) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error unwrapping data key with %s key %s: %w", metadata.Provider, metadata.KeyID, err)
+		}
+
+		c.unsealed[metadata.WrappedKey] = dataKey
+		return dataKey, nil
+	}
+
+	return nil, fmt.Errorf("no %s key configured for key id %s", metadata.Provider, metadata.KeyID)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("error creating gcm: %w", err)
+	}
+
+	return aead, nil
+}
+
+// additionalData binds a sealed value to its record and field so it cannot be
+// moved to another one
+func additionalData(record, field string) []byte {
+	return []byte(record + "/" + field)
+}
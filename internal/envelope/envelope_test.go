@@ -0,0 +1,128 @@
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, dataKeySize)
+}
+
+func seal(t *testing.T, codec *Codec, record string, plain map[string]string, fields []string) map[string]interface{} {
+	t.Helper()
+
+	data := map[string][]byte{}
+	for k, v := range plain {
+		data[k] = []byte(v)
+	}
+
+	if err := codec.Seal(context.Background(), record, data, fields); err != nil {
+		t.Fatalf("unexpected error sealing: %v", err)
+	}
+
+	stored := map[string]interface{}{}
+	for k, v := range data {
+		stored[k] = string(v)
+	}
+
+	return stored
+}
+
+func TestCodec(t *testing.T) {
+	oldKey, _ := NewLocalKeyWrapper(testKey(1))
+	newKey, _ := NewLocalKeyWrapper(testKey(2))
+
+	plain := map[string]string{
+		"cluster_name": `"mgmt"`,
+		"civo_auth":    `{"token":"civo-token"}`,
+	}
+	fields := []string{"civo_auth", "git_auth"}
+
+	tests := []struct {
+		name    string
+		sealer  *Codec
+		opener  *Codec
+		record  string
+		tamper  func(data map[string]interface{})
+		wantErr bool
+	}{
+		{name: "round trip", sealer: NewCodec(oldKey), opener: NewCodec(oldKey), record: "kubefirst-cluster-mgmt"},
+		{name: "previous key", sealer: NewCodec(oldKey), opener: NewCodec(newKey, oldKey), record: "kubefirst-cluster-mgmt"},
+		{name: "unknown key", sealer: NewCodec(oldKey), opener: NewCodec(newKey), record: "kubefirst-cluster-mgmt", wantErr: true},
+		{
+			name: "value moved to another record", sealer: NewCodec(oldKey), opener: NewCodec(oldKey), record: "kubefirst-cluster-mgmt",
+			tamper: func(data map[string]interface{}) {
+				other := seal(t, NewCodec(oldKey), "kubefirst-cluster-other", plain, fields)
+				data["civo_auth"] = other["civo_auth"]
+				data[MetadataKey] = other[MetadataKey]
+			},
+			wantErr: true,
+		},
+		{
+			name: "tampered value", sealer: NewCodec(oldKey), opener: NewCodec(oldKey), record: "kubefirst-cluster-mgmt",
+			tamper: func(data map[string]interface{}) {
+				value := data["civo_auth"].(string)
+				data["civo_auth"] = value[:len(value)-4] + "AAA="
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := seal(t, tt.sealer, tt.record, plain, fields)
+
+			if strings.Contains(data["civo_auth"].(string), "civo-token") {
+				t.Fatalf("expected civo_auth to be encrypted, got %s", data["civo_auth"])
+			}
+			if data["cluster_name"] != plain["cluster_name"] {
+				t.Fatalf("expected cluster_name to be left in plain text, got %s", data["cluster_name"])
+			}
+
+			if tt.tamper != nil {
+				tt.tamper(data)
+			}
+
+			err := tt.opener.Open(context.Background(), tt.record, data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %t, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			for k, v := range plain {
+				if data[k] != v {
+					t.Errorf("expected %s to be %s, got %v", k, v, data[k])
+				}
+			}
+			if _, ok := data[MetadataKey]; ok {
+				t.Errorf("expected %s to be removed", MetadataKey)
+			}
+		})
+	}
+}
+
+func TestCodecIsCurrent(t *testing.T) {
+	oldKey, _ := NewLocalKeyWrapper(testKey(1))
+	newKey, _ := NewLocalKeyWrapper(testKey(2))
+
+	data := seal(t, NewCodec(oldKey), "kubefirst-cluster-mgmt", map[string]string{"civo_auth": "{}"}, []string{"civo_auth"})
+	metadata, err := ReadMetadata(data)
+	if err != nil {
+		t.Fatalf("unexpected error reading metadata: %v", err)
+	}
+
+	if !NewCodec(oldKey).IsCurrent(metadata) {
+		t.Errorf("expected record sealed with the current key to be current")
+	}
+	if NewCodec(newKey, oldKey).IsCurrent(metadata) {
+		t.Errorf("expected record sealed with a previous key not to be current")
+	}
+	if NewCodec(oldKey).IsCurrent(nil) {
+		t.Errorf("expected plain record not to be current")
+	}
+}
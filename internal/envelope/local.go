@@ -0,0 +1,110 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+)
+
+// ProviderLocal wraps data keys with a key read from a file
+const ProviderLocal = "local"
+
+// LocalKeyWrapper wraps data keys with a 256-bit key read from a file
+type LocalKeyWrapper struct {
+	key   []byte
+	keyID string
+}
+
+// NewLocalKeyWrapper returns a wrapper for key, which must be 32 bytes
+func NewLocalKeyWrapper(key []byte) (*LocalKeyWrapper, error) {
+	if len(key) != dataKeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", dataKeySize, len(key))
+	}
+
+	sum := sha256.Sum256(key)
+
+	return &LocalKeyWrapper{
+		key:   key,
+		keyID: hex.EncodeToString(sum[:])[:16],
+	}, nil
+}
+
+// LoadLocalKeyWrapper returns a wrapper for the key in path. The file holds
+// the key base64 encoded, or as 32 raw bytes.
+func LoadLocalKeyWrapper(path string) (*LocalKeyWrapper, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading encryption key file %s: %w", path, err)
+	}
+
+	key := content
+	if decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(content))); err == nil {
+		key = decoded
+	}
+
+	wrapper, err := NewLocalKeyWrapper(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key file %s: %w", path, err)
+	}
+
+	return wrapper, nil
+}
+
+// Provider returns ProviderLocal
+func (w *LocalKeyWrapper) Provider() string {
+	return ProviderLocal
+}
+
+// KeyID returns a fingerprint of the key
+func (w *LocalKeyWrapper) KeyID() string {
+	return w.keyID
+}
+
+// Wrap encrypts dataKey with the key
+func (w *LocalKeyWrapper) Wrap(_ context.Context, dataKey []byte) ([]byte, error) {
+	aead, err := newAEAD(w.key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("error generating nonce: %w", err)
+	}
+
+	return aead.Seal(nonce, nonce, dataKey, []byte(w.keyID)), nil
+}
+
+// Unwrap decrypts a data key wrapped with the key
+func (w *LocalKeyWrapper) Unwrap(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	if keyID != w.keyID {
+		return nil, ErrKeyMismatch
+	}
+
+	aead, err := newAEAD(w.key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(wrapped) < aead.NonceSize() {
+		return nil, fmt.Errorf("wrapped data key is too short")
+	}
+
+	dataKey, err := aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], []byte(w.keyID))
+	if err != nil {
+		return nil, fmt.Errorf("unable to unwrap data key: %w", err)
+	}
+
+	return dataKey, nil
+}
@@ -0,0 +1,109 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package envelope
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/konstructio/kubefirst-api/internal/vault"
+)
+
+// ProviderVault wraps data keys with a Vault transit key
+const ProviderVault = "vault"
+
+// VaultTransitWrapper wraps data keys with a key of the Vault transit secrets
+// engine. Versions of the transit key are tracked by Vault, so rotating the
+// transit key in Vault does not require a new KeyID.
+type VaultTransitWrapper struct {
+	client *vaultapi.Client
+	mount  string
+	key    string
+}
+
+// NewVaultTransitWrapper returns a wrapper for the transit key mounted at
+// mount in the Vault at address
+func NewVaultTransitWrapper(address, token, mount, key string) (*VaultTransitWrapper, error) {
+	config := vaultapi.DefaultConfig()
+	config.Address = address
+
+	client, err := vault.NewClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating vault client: %w", err)
+	}
+	client.SetToken(token)
+
+	return &VaultTransitWrapper{
+		client: client,
+		mount:  mount,
+		key:    key,
+	}, nil
+}
+
+// Provider returns ProviderVault
+func (w *VaultTransitWrapper) Provider() string {
+	return ProviderVault
+}
+
+// KeyID returns the path of the transit key
+func (w *VaultTransitWrapper) KeyID() string {
+	return fmt.Sprintf("%s/%s", w.mount, w.key)
+}
+
+// Wrap encrypts dataKey with the transit key
+func (w *VaultTransitWrapper) Wrap(ctx context.Context, dataKey []byte) ([]byte, error) {
+	secret, err := w.client.Logical().WriteWithContext(ctx, fmt.Sprintf("%s/encrypt/%s", w.mount, w.key), map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString(dataKey),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting with vault transit key %s: %w", w.KeyID(), err)
+	}
+
+	ciphertext, ok := secretString(secret, "ciphertext")
+	if !ok {
+		return nil, fmt.Errorf("vault transit key %s returned no ciphertext", w.KeyID())
+	}
+
+	return []byte(ciphertext), nil
+}
+
+// Unwrap decrypts a data key wrapped with the transit key
+func (w *VaultTransitWrapper) Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	if keyID != w.KeyID() {
+		return nil, ErrKeyMismatch
+	}
+
+	secret, err := w.client.Logical().WriteWithContext(ctx, fmt.Sprintf("%s/decrypt/%s", w.mount, w.key), map[string]interface{}{
+		"ciphertext": string(wrapped),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting with vault transit key %s: %w", w.KeyID(), err)
+	}
+
+	plaintext, ok := secretString(secret, "plaintext")
+	if !ok {
+		return nil, fmt.Errorf("vault transit key %s returned no plaintext", w.KeyID())
+	}
+
+	dataKey, err := base64.StdEncoding.DecodeString(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plaintext from vault transit key %s: %w", w.KeyID(), err)
+	}
+
+	return dataKey, nil
+}
+
+func secretString(secret *vaultapi.Secret, key string) (string, bool) {
+	if secret == nil || secret.Data == nil {
+		return "", false
+	}
+
+	value, ok := secret.Data[key].(string)
+	return value, ok && value != ""
+}
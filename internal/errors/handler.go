@@ -19,11 +19,13 @@ import (
 func HandleClusterError(cl *pkgtypes.Cluster, condition string) error {
 	kcfg := utils.GetKubernetesClient(cl.ClusterName)
 
-	cl.InProgress = false
-	cl.Status = constants.ClusterStatusError
-	cl.LastCondition = condition
-
-	err := secrets.UpdateCluster(kcfg.Clientset, *cl)
+	// only the status fields are written, so the progress other callers
+	// recorded since cl was read is kept
+	err := secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+		cluster.InProgress = false
+		cluster.Status = constants.ClusterStatusError
+		cluster.LastCondition = condition
+	})
 	if err != nil {
 		return fmt.Errorf("failed to update cluster %q: %w", cl.ClusterName, err)
 	}
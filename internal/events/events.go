@@ -0,0 +1,209 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/env"
+)
+
+// Type is the kind of an event
+type Type string
+
+const (
+	// StepStarted is published when a step of a cluster starts running
+	StepStarted Type = "step_started"
+	// StepCompleted is published when a step of a cluster has succeeded
+	StepCompleted Type = "step_completed"
+	// StepFailed is published when a step of a cluster has failed or was
+	// cancelled
+	StepFailed Type = "step_failed"
+	// StatusChanged is published when the status of a cluster record changes
+	StatusChanged Type = "status_changed"
+	// ServiceSynced is published when Argo CD has synchronized a service added
+	// to a cluster
+	ServiceSynced Type = "service_synced"
+	// ServiceSyncFailed is published when Argo CD could not synchronize a
+	// service added to a cluster
+	ServiceSyncFailed Type = "service_sync_failed"
+)
+
+// subscriberBuffer is the number of events a subscriber can fall behind by
+// before it is dropped
+const subscriberBuffer = 64
+
+// Event is a change to a cluster
+type Event struct {
+	ID             uint64    `json:"id"`
+	Type           Type      `json:"type"`
+	Cluster        string    `json:"cluster"`
+	Step           string    `json:"step,omitempty"`
+	Service        string    `json:"service,omitempty"`
+	Status         string    `json:"status,omitempty"`
+	PreviousStatus string    `json:"previous_status,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	Time           time.Time `json:"time"`
+}
+
+// StepFinished returns the event published when a step of cluster ends with
+// err. The error itself is not published, since it may name cloud resources
+// or credentials, and is left to the logs of the cluster.
+func StepFinished(cluster, step string, err error) Event {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return Event{Type: StepFailed, Cluster: cluster, Step: step, Error: fmt.Sprintf("step %s was cancelled", step)}
+	case err != nil:
+		return Event{Type: StepFailed, Cluster: cluster, Step: step, Error: fmt.Sprintf("step %s failed, see the logs of the cluster", step)}
+	}
+
+	return Event{Type: StepCompleted, Cluster: cluster, Step: step}
+}
+
+// Bus delivers events to its subscribers and keeps the latest ones in a ring
+// buffer, so a subscriber reconnecting can catch up on the events it missed
+type Bus struct {
+	mu          sync.Mutex
+	ring        []Event
+	lastID      uint64
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription receives the events of a cluster published on a bus
+type Subscription struct {
+	// C is closed when the subscription is closed, or when the subscriber
+	// fell too far behind, in which case it should subscribe again from the
+	// last event it received
+	C <-chan Event
+
+	bus     *Bus
+	cluster string
+	events  chan Event
+}
+
+// NewBus returns a bus keeping the latest size events
+func NewBus(size int) *Bus {
+	if size < 1 {
+		size = 1
+	}
+
+	return &Bus{
+		ring:        make([]Event, size),
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+// Publish numbers event and delivers it to the subscribers of its cluster
+func (b *Bus) Publish(event Event) Event {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	event.ID = b.lastID
+	b.ring[b.index(event.ID)] = event
+
+	for sub := range b.subscribers {
+		if sub.cluster != "" && sub.cluster != event.Cluster {
+			continue
+		}
+
+		select {
+		case sub.events <- event:
+		default:
+			b.drop(sub)
+		}
+	}
+
+	return event
+}
+
+// Subscribe returns a subscription to the events of cluster, or of every
+// cluster when cluster is empty, along with the buffered events published
+// after lastEventID. Nothing is replayed when lastEventID is 0. An ID the bus
+// has not reached yet was issued before the API restarted, and replays every
+// buffered event.
+func (b *Bus) Subscribe(cluster string, lastEventID uint64) (*Subscription, []Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	replay := []Event{}
+	if lastEventID > 0 {
+		oldest := uint64(1)
+		if b.lastID > uint64(len(b.ring)) {
+			oldest = b.lastID - uint64(len(b.ring)) + 1
+		}
+
+		from := lastEventID + 1
+		if from < oldest || lastEventID > b.lastID {
+			from = oldest
+		}
+
+		for id := from; id <= b.lastID; id++ {
+			event := b.ring[b.index(id)]
+			if cluster == "" || event.Cluster == cluster {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	events := make(chan Event, subscriberBuffer)
+	sub := &Subscription{C: events, bus: b, cluster: cluster, events: events}
+	b.subscribers[sub] = struct{}{}
+
+	return sub, replay
+}
+
+// Close stops the delivery of events to the subscription
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	s.bus.drop(s)
+}
+
+func (b *Bus) drop(sub *Subscription) {
+	if _, ok := b.subscribers[sub]; !ok {
+		return
+	}
+
+	delete(b.subscribers, sub)
+	close(sub.events)
+}
+
+func (b *Bus) index(id uint64) int {
+	return int((id - 1) % uint64(len(b.ring)))
+}
+
+var (
+	defaultBusOnce sync.Once
+	defaultBus     *Bus
+)
+
+// Default returns the bus of the API, keeping the latest
+// K1_EVENTS_BUFFER_SIZE events
+func Default() *Bus {
+	defaultBusOnce.Do(func() {
+		env, _ := env.GetEnv(constants.SilenceGetEnv)
+		defaultBus = NewBus(env.EventsBufferSize)
+	})
+
+	return defaultBus
+}
+
+// Publish publishes event on the bus of the API
+func Publish(event Event) Event {
+	return Default().Publish(event)
+}
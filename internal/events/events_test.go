@@ -0,0 +1,101 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestBusSubscribeReplay(t *testing.T) {
+	// the bus keeps the latest four of the six events published
+	bus := NewBus(4)
+	for _, cluster := range []string{"a", "b", "a", "a", "b", "a"} {
+		bus.Publish(Event{Type: StatusChanged, Cluster: cluster})
+	}
+
+	tests := []struct {
+		name        string
+		cluster     string
+		lastEventID uint64
+		expected    []uint64
+	}{
+		{name: "new client", cluster: "a", expected: []uint64{}},
+		{name: "events after last event", cluster: "a", lastEventID: 4, expected: []uint64{6}},
+		{name: "every cluster", lastEventID: 4, expected: []uint64{5, 6}},
+		{name: "last event no longer buffered", cluster: "a", lastEventID: 1, expected: []uint64{3, 4, 6}},
+		{name: "up to date", cluster: "a", lastEventID: 6, expected: []uint64{}},
+		{name: "last event from before a restart", cluster: "b", lastEventID: 42, expected: []uint64{5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, replay := bus.Subscribe(tt.cluster, tt.lastEventID)
+			defer sub.Close()
+
+			ids := []uint64{}
+			for _, event := range replay {
+				ids = append(ids, event.ID)
+			}
+			if !slices.Equal(ids, tt.expected) {
+				t.Errorf("expected events %v, got %v", tt.expected, ids)
+			}
+		})
+	}
+}
+
+func TestBusDropsSlowSubscriber(t *testing.T) {
+	bus := NewBus(4)
+	sub, _ := bus.Subscribe("a", 0)
+	other, _ := bus.Subscribe("b", 0)
+	defer other.Close()
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		bus.Publish(Event{Type: StepStarted, Cluster: "a"})
+	}
+
+	received := 0
+	for range sub.C {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("expected %d events before the subscription closed, got %d", subscriberBuffer, received)
+	}
+
+	bus.Publish(Event{Type: StepStarted, Cluster: "b"})
+	if event := <-other.C; event.ID != subscriberBuffer+2 {
+		t.Errorf("expected other subscriber to keep receiving events, got event %d", event.ID)
+	}
+
+	// closing a dropped subscription is a no-op
+	sub.Close()
+}
+
+func TestStepFinished(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected Event
+	}{
+		{name: "succeeded", expected: Event{Type: StepCompleted, Cluster: "a", Step: "git_init"}},
+		{
+			name:     "failed",
+			err:      errors.New("error creating repository with token ghp_secret"),
+			expected: Event{Type: StepFailed, Cluster: "a", Step: "git_init", Error: "step git_init failed, see the logs of the cluster"},
+		},
+		{
+			name:     "cancelled",
+			err:      fmt.Errorf("cluster %q provisioning cancelled: %w", "a", context.Canceled),
+			expected: Event{Type: StepFailed, Cluster: "a", Step: "git_init", Error: "step git_init was cancelled"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if event := StepFinished("a", "git_init", tt.err); event != tt.expected {
+				t.Errorf("expected event %+v, got %+v", tt.expected, event)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package export
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// PassphraseHeader carries the passphrase a bundle is sealed or opened with
+	PassphraseHeader = "X-Kubefirst-Export-Passphrase"
+
+	// MinPassphraseLength is the length below which a passphrase is rejected
+	MinPassphraseLength = 12
+
+	bundleVersion = 1
+	kdfScrypt     = "scrypt"
+	saltSize      = 16
+	keySize       = 32
+)
+
+var ErrDecrypt = errors.New("unable to decrypt bundle, check the passphrase")
+
+// Seal encrypts a cluster record into a bundle with AES-256-GCM, keyed with
+// scrypt from passphrase
+func Seal(cl pkgtypes.Cluster, passphrase string, now time.Time) (*pkgtypes.ClusterExportBundle, error) {
+	if len(passphrase) < MinPassphraseLength {
+		return nil, fmt.Errorf("passphrase must be at least %d characters", MinPassphraseLength)
+	}
+
+	plaintext, err := json.Marshal(cl)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling cluster %s: %w", cl.ClusterName, err)
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("error generating salt: %w", err)
+	}
+
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("error generating nonce: %w", err)
+	}
+
+	bundle := &pkgtypes.ClusterExportBundle{
+		Version:           bundleVersion,
+		ClusterName:       cl.ClusterName,
+		CreationTimestamp: now.UTC().Format(time.RFC3339),
+		KDF:               kdfScrypt,
+		Salt:              base64.StdEncoding.EncodeToString(salt),
+		Nonce:             base64.StdEncoding.EncodeToString(nonce),
+	}
+	ciphertext := aead.Seal(nil, nonce, plaintext, additionalData(bundle))
+	bundle.Ciphertext = base64.StdEncoding.EncodeToString(ciphertext)
+
+	return bundle, nil
+}
+
+// Open decrypts the cluster record of a bundle
+func Open(bundle *pkgtypes.ClusterExportBundle, passphrase string) (*pkgtypes.Cluster, error) {
+	if bundle.Version != bundleVersion || bundle.KDF != kdfScrypt {
+		return nil, fmt.Errorf("unsupported bundle version %d with kdf %q", bundle.Version, bundle.KDF)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(bundle.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle salt: %w", err)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(bundle.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle nonce: %w", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(bundle.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle ciphertext: %w", err)
+	}
+
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid bundle nonce length %d", len(nonce))
+	}
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData(bundle))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	var cl pkgtypes.Cluster
+	if err := json.Unmarshal(plaintext, &cl); err != nil {
+		return nil, fmt.Errorf("unable to cast bundle cluster: %w", err)
+	}
+
+	return &cl, nil
+}
+
+func newAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("error deriving key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("error creating gcm: %w", err)
+	}
+
+	return aead, nil
+}
+
+// additionalData binds the ciphertext to the cluster name so it cannot be
+// imported under another name
+func additionalData(bundle *pkgtypes.ClusterExportBundle) []byte {
+	return []byte(fmt.Sprintf("kubefirst-export/v%d/%s", bundle.Version, bundle.ClusterName))
+}
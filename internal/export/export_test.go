@@ -0,0 +1,44 @@
+package export
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+)
+
+func TestSealOpen(t *testing.T) {
+	cl := pkgtypes.Cluster{
+		ClusterName: "dev",
+		GitAuth:     pkgtypes.GitAuth{Token: "ghp_token"},
+	}
+	passphrase := "correct horse battery staple"
+
+	bundle, err := Seal(cl, passphrase, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opened, err := Open(bundle, passphrase)
+	if err != nil {
+		t.Fatalf("unexpected error opening bundle: %s", err)
+	}
+	if opened.ClusterName != "dev" || opened.GitAuth.Token != "ghp_token" {
+		t.Errorf("expected the sealed cluster, got %+v", opened)
+	}
+
+	if _, err := Open(bundle, "wrong passphrase!"); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("expected %v for a wrong passphrase, got %v", ErrDecrypt, err)
+	}
+
+	renamed := *bundle
+	renamed.ClusterName = "prod"
+	if _, err := Open(&renamed, passphrase); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("expected %v for a renamed bundle, got %v", ErrDecrypt, err)
+	}
+
+	if _, err := Seal(cl, "short", time.Now()); err == nil {
+		t.Error("expected an error for a short passphrase")
+	}
+}
@@ -8,6 +8,7 @@ package gitShim //nolint:revive,stylecheck // allowing name during code cleanup
 
 import (
 	"github.com/google/go-github/v52/github"
+	"github.com/konstructio/kubefirst-api/internal/metrics"
 )
 
 // GitHubClient acts as a receiver for interacting with GitHub's API
@@ -17,5 +18,5 @@ type GitHubClient struct {
 
 // NewGitHub instantiates an unauthenticated GitHub client
 func NewGitHub() *github.Client {
-	return github.NewClient(nil)
+	return github.NewClient(metrics.HTTPClient(metrics.ServiceGitHub))
 }
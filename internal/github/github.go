@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/google/go-github/v45/github"
+	"github.com/konstructio/kubefirst-api/internal/metrics"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/oauth2"
 )
@@ -29,7 +30,7 @@ type Session struct {
 // New - Create a new client for github wrapper
 func New(token string) Session {
 	var gSession Session
-	gSession.context = context.Background()
+	gSession.context = context.WithValue(context.Background(), oauth2.HTTPClient, metrics.HTTPClient(metrics.ServiceGitHub))
 	gSession.staticToken = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
 	gSession.oauthClient = oauth2.NewClient(gSession.context, gSession.staticToken)
 	gSession.gitClient = github.NewClient(gSession.oauthClient)
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/konstructio/kubefirst-api/internal/metrics"
 	"github.com/rs/zerolog/log"
 	"github.com/xanzy/go-gitlab"
 )
@@ -17,7 +18,7 @@ import (
 // NewGitLabClient instantiates a wrapper to communicate with GitLab
 // It sets the path and ID of the group under which resources will be managed
 func NewGitLabClient(token string, parentGroupName string) (*Wrapper, error) {
-	git, err := gitlab.NewClient(token)
+	git, err := gitlab.NewClient(token, gitlab.WithHTTPClient(metrics.HTTPClient(metrics.ServiceGitLab)))
 	if err != nil {
 		return nil, fmt.Errorf("error instantiating gitlab client: %w", err)
 	}
@@ -407,6 +408,27 @@ func (gl *Wrapper) ListProjectDeployTokens(projectName string) ([]gitlab.DeployT
 	return container, nil
 }
 
+// CreateMergeRequest opens a merge request in a project and returns its web url
+func (gl *Wrapper) CreateMergeRequest(projectName, sourceBranch, targetBranch, title, description string) (string, error) {
+	projectID, err := gl.GetProjectID(projectName)
+	if err != nil {
+		return "", fmt.Errorf("could not get project ID for project %s: %w", projectName, err)
+	}
+
+	mr, _, err := gl.Client.MergeRequests.CreateMergeRequest(projectID, &gitlab.CreateMergeRequestOptions{
+		Title:        &title,
+		Description:  &description,
+		SourceBranch: &sourceBranch,
+		TargetBranch: &targetBranch,
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not create merge request for project %s: %w", projectName, err)
+	}
+
+	log.Info().Msgf("created merge request %s", mr.WebURL)
+	return mr.WebURL, nil
+}
+
 // DeleteProjectWebhook
 func (gl *Wrapper) DeleteProjectWebhook(projectName string, url string) error {
 	projectID, err := gl.GetProjectID(projectName)
@@ -0,0 +1,270 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/konstructio/kubefirst-api/internal/clusterlogs"
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/env"
+	"github.com/konstructio/kubefirst-api/internal/metrics"
+	"github.com/konstructio/kubefirst-api/internal/secrets"
+	"github.com/konstructio/kubefirst-api/internal/tracing"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+	log "github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Handler performs the work for a job. Handlers should stop as soon as
+// possible once ctx is cancelled.
+type Handler func(ctx context.Context, job *pkgtypes.Job) error
+
+// running holds the cancel functions for jobs executing in this process
+var (
+	running   = map[string]context.CancelFunc{}
+	runningMu sync.Mutex
+)
+
+// NewJob returns a queued job record for the given cluster, continuing the
+// trace in ctx when the job runs
+func NewJob(ctx context.Context, jobType, clusterName, serviceName string) pkgtypes.Job {
+	return pkgtypes.Job{
+		ID:                primitive.NewObjectID().Hex(),
+		Type:              jobType,
+		ClusterName:       clusterName,
+		ServiceName:       serviceName,
+		Status:            constants.JobStatusQueued,
+		CreationTimestamp: timestamp(),
+		TraceContext:      tracing.Inject(ctx),
+	}
+}
+
+// insert persists a new job and prunes the oldest finished jobs beyond the
+// configured history size
+func insert(clientSet kubernetes.Interface, job pkgtypes.Job) error {
+	if err := secrets.InsertJob(clientSet, job); err != nil {
+		return fmt.Errorf("error inserting job for cluster %q: %w", job.ClusterName, err)
+	}
+
+	env, _ := env.GetEnv(constants.SilenceGetEnv)
+	if err := secrets.PruneJobs(clientSet, env.JobHistorySize); err != nil {
+		log.Warn().Msgf("error pruning finished jobs: %s", err)
+	}
+
+	return nil
+}
+
+// Enqueue persists a new job and runs its handler in the background
+func Enqueue(clientSet kubernetes.Interface, job pkgtypes.Job, handler Handler) (*pkgtypes.Job, error) {
+	if err := insert(clientSet, job); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		if err := run(clientSet, &job, handler); err != nil {
+			log.Error().Msgf("job %s (%s) for cluster %s failed: %s", job.ID, job.Type, job.ClusterName, err)
+		}
+	}()
+
+	return &job, nil
+}
+
+// Run persists a new job and runs its handler in the foreground, returning
+// the handler's error
+func Run(clientSet kubernetes.Interface, job pkgtypes.Job, handler Handler) error {
+	if err := insert(clientSet, job); err != nil {
+		return err
+	}
+
+	return run(clientSet, &job, handler)
+}
+
+// ResumeJobs picks up jobs that were queued or running when the API last
+// stopped. Jobs still owned by another running replica are left alone and
+// each remaining job is claimed first, so only one replica picks it up. Jobs
+// with a handler in resumers are run again, all others are marked as failed
+// since there is not enough information to restart them.
+func ResumeJobs(clientSet kubernetes.Interface, resumers map[string]Handler) error {
+	allJobs, err := secrets.GetJobs(clientSet)
+	if err != nil {
+		return fmt.Errorf("error retrieving jobs: %w", err)
+	}
+
+	self := hostname()
+	for _, job := range allJobs {
+		if job.Status != constants.JobStatusQueued && job.Status != constants.JobStatusRunning {
+			continue
+		}
+
+		if job.Owner != "" && job.Owner != self && ownerRunning(clientSet, job.Owner) {
+			continue
+		}
+
+		claimed, err := secrets.ClaimJob(clientSet, job, self)
+		if err != nil {
+			log.Warn().Msgf("error claiming job %s: %s", job.ID, err)
+			continue
+		}
+		if !claimed {
+			log.Info().Msgf("job %s (%s) for cluster %s was picked up by another replica", job.ID, job.Type, job.ClusterName)
+			continue
+		}
+		job.Owner = self
+
+		handler, ok := resumers[job.Type]
+		if !ok {
+			log.Warn().Msgf("job %s (%s) for cluster %s cannot be resumed, marking as failed", job.ID, job.Type, job.ClusterName)
+			job.Status = constants.JobStatusFailed
+			job.LastError = "interrupted by api restart"
+			job.FinishedAt = timestamp()
+			if err := secrets.UpdateJob(clientSet, job); err != nil {
+				log.Warn().Msgf("error updating job %s: %s", job.ID, err)
+			}
+			continue
+		}
+
+		log.Info().Msgf("resuming job %s (%s) for cluster %s", job.ID, job.Type, job.ClusterName)
+		go func(job pkgtypes.Job) {
+			if err := run(clientSet, &job, handler); err != nil {
+				log.Error().Msgf("resumed job %s (%s) for cluster %s failed: %s", job.ID, job.Type, job.ClusterName, err)
+			}
+		}(job)
+	}
+
+	return nil
+}
+
+// ownerRunning reports whether the api pod named owner is still running. Job
+// owners are the hostnames of the replicas, which are their pod names.
+func ownerRunning(clientSet kubernetes.Interface, owner string) bool {
+	pod, err := clientSet.CoreV1().Pods("kubefirst").Get(context.Background(), owner, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Warn().Msgf("error looking up job owner %s, assuming it is running: %s", owner, err)
+			return true
+		}
+		return false
+	}
+
+	return pod.DeletionTimestamp == nil && pod.Status.Phase == corev1.PodRunning
+}
+
+func hostname() string {
+	owner, err := os.Hostname()
+	if err != nil {
+		log.Warn().Msgf("unable to determine hostname for job owner: %s", err)
+	}
+	return owner
+}
+
+// Active returns the queued or running job of the given type for a cluster,
+// or nil when there is none
+func Active(clientSet kubernetes.Interface, clusterName, jobType string) (*pkgtypes.Job, error) {
+	allJobs, err := secrets.GetJobs(clientSet)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving jobs: %w", err)
+	}
+
+	for _, job := range allJobs {
+		if job.ClusterName != clusterName || job.Type != jobType {
+			continue
+		}
+
+		if job.Status == constants.JobStatusQueued || job.Status == constants.JobStatusRunning {
+			return &job, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Cancel requests that a job running in this process stops. It returns false
+// when the job is not running here, for example when it is owned by another
+// api replica or has already finished.
+func Cancel(jobID string) bool {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+
+	cancel, ok := running[jobID]
+	if !ok {
+		return false
+	}
+
+	cancel()
+	return true
+}
+
+// run executes a handler and records the outcome on the job
+func run(clientSet kubernetes.Interface, job *pkgtypes.Job, handler Handler) error {
+	ctx, endSpan := tracing.Start(tracing.Extract(context.Background(), job.TraceContext), "job "+job.Type,
+		tracing.JobKey.String(job.ID),
+		tracing.ClusterKey.String(job.ClusterName),
+	)
+	ctx, closeLogs, err := clusterlogs.Start(ctx, job.ClusterName, job.ID)
+	if err != nil {
+		log.Warn().Msgf("error opening logs of job %s for cluster %s: %s", job.ID, job.ClusterName, err)
+	}
+	defer closeLogs()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	runningMu.Lock()
+	running[job.ID] = cancel
+	runningMu.Unlock()
+
+	job.Status = constants.JobStatusRunning
+	job.Attempts++
+	job.Owner = hostname()
+	job.LastError = ""
+	job.StartedAt = timestamp()
+	job.FinishedAt = ""
+	if err := secrets.UpdateJob(clientSet, *job); err != nil {
+		log.Warn().Msgf("error updating job %s: %s", job.ID, err)
+	}
+
+	metrics.JobStarted(job.Type)
+	defer metrics.JobFinished(job.Type)
+
+	handlerErr := handler(ctx, job)
+	endSpan(handlerErr)
+
+	runningMu.Lock()
+	delete(running, job.ID)
+	runningMu.Unlock()
+
+	job.FinishedAt = timestamp()
+	switch {
+	case handlerErr != nil && ctx.Err() != nil:
+		job.Status = constants.JobStatusCancelled
+		job.LastError = handlerErr.Error()
+	case handlerErr != nil:
+		job.Status = constants.JobStatusFailed
+		job.LastError = handlerErr.Error()
+	default:
+		job.Status = constants.JobStatusSucceeded
+	}
+
+	if err := secrets.UpdateJob(clientSet, *job); err != nil {
+		log.Warn().Msgf("error updating job %s: %s", job.ID, err)
+	}
+
+	return handlerErr
+}
+
+func timestamp() string {
+	return fmt.Sprintf("%v", primitive.NewDateTimeFromTime(time.Now().UTC()))
+}
@@ -0,0 +1,270 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/secrets"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name           string
+		handlerErr     error
+		expectedStatus string
+	}{
+		{
+			name:           "handler succeeds",
+			handlerErr:     nil,
+			expectedStatus: constants.JobStatusSucceeded,
+		},
+		{
+			name:           "handler fails",
+			handlerErr:     errors.New("terraform apply failed"),
+			expectedStatus: constants.JobStatusFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset()
+
+			job := NewJob(context.Background(), constants.JobTypeClusterCreate, "kubefirst", "")
+			err := Run(clientset, job, func(_ context.Context, _ *pkgtypes.Job) error {
+				return tt.handlerErr
+			})
+			if !errors.Is(err, tt.handlerErr) {
+				t.Fatalf("expected error %v, got %v", tt.handlerErr, err)
+			}
+
+			stored, err := secrets.GetJob(clientset, job.ID)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if stored.Status != tt.expectedStatus {
+				t.Errorf("expected status %q, got %q", tt.expectedStatus, stored.Status)
+			}
+
+			if stored.Attempts != 1 {
+				t.Errorf("expected 1 attempt, got %d", stored.Attempts)
+			}
+		})
+	}
+}
+
+func TestResumeJobsMarksUnknownTypesFailed(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+
+	job := NewJob(context.Background(), constants.JobTypeServiceCreate, "kubefirst", "metaphor")
+	job.Status = constants.JobStatusRunning
+	if err := secrets.InsertJob(clientset, job); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := ResumeJobs(clientset, map[string]Handler{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	stored, err := secrets.GetJob(clientset, job.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if stored.Status != constants.JobStatusFailed {
+		t.Errorf("expected status %q, got %q", constants.JobStatusFailed, stored.Status)
+	}
+}
+
+func TestResumeJobsSkipsRunningOwners(t *testing.T) {
+	tests := []struct {
+		name           string
+		ownerPod       *corev1.Pod
+		expectedStatus string
+	}{
+		{
+			name: "owner still running",
+			ownerPod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "kubefirst-api-1", Namespace: "kubefirst"},
+				Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+			},
+			expectedStatus: constants.JobStatusRunning,
+		},
+		{
+			name:           "owner gone",
+			expectedStatus: constants.JobStatusFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset()
+			if tt.ownerPod != nil {
+				clientset = fake.NewSimpleClientset(tt.ownerPod)
+			}
+
+			job := NewJob(context.Background(), constants.JobTypeServiceCreate, "kubefirst", "metaphor")
+			job.Status = constants.JobStatusRunning
+			job.Owner = "kubefirst-api-1"
+			if err := secrets.InsertJob(clientset, job); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if err := ResumeJobs(clientset, map[string]Handler{}); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			stored, err := secrets.GetJob(clientset, job.ID)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if stored.Status != tt.expectedStatus {
+				t.Errorf("expected status %q, got %q", tt.expectedStatus, stored.Status)
+			}
+		})
+	}
+}
+
+func TestClaimJob(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+
+	job := NewJob(context.Background(), constants.JobTypeClusterCreate, "kubefirst", "")
+	job.Status = constants.JobStatusRunning
+	job.Owner = "kubefirst-api-1"
+	if err := secrets.InsertJob(clientset, job); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	claimed, err := secrets.ClaimJob(clientset, job, "kubefirst-api-2")
+	if err != nil || !claimed {
+		t.Fatalf("expected the first claim to succeed, got %v, %v", claimed, err)
+	}
+
+	// a replica still holding the listing from before the first claim
+	claimed, err = secrets.ClaimJob(clientset, job, "kubefirst-api-3")
+	if err != nil || claimed {
+		t.Fatalf("expected the second claim to fail, got %v, %v", claimed, err)
+	}
+
+	stored, err := secrets.GetJob(clientset, job.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if stored.Owner != "kubefirst-api-2" {
+		t.Errorf("expected owner %q, got %q", "kubefirst-api-2", stored.Owner)
+	}
+}
+
+func TestPruneJobs(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+
+	statuses := []string{
+		constants.JobStatusSucceeded,
+		constants.JobStatusRunning,
+		constants.JobStatusFailed,
+		constants.JobStatusSucceeded,
+		constants.JobStatusCancelled,
+	}
+	ids := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		job := NewJob(context.Background(), constants.JobTypeClusterCreate, "kubefirst", "")
+		job.Status = status
+		if err := secrets.InsertJob(clientset, job); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		ids = append(ids, job.ID)
+	}
+
+	if err := secrets.PruneJobs(clientset, 2); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	remaining, err := secrets.GetJobs(clientset)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got := make([]string, 0, len(remaining))
+	for _, job := range remaining {
+		got = append(got, job.ID)
+	}
+	// the running job is kept along with the two newest finished ones
+	want := []string{ids[1], ids[3], ids[4]}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected jobs %v, got %v", want, got)
+	}
+}
+
+func TestGetJobsSkipsMissingRecords(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+
+	first := NewJob(context.Background(), constants.JobTypeClusterCreate, "kubefirst", "")
+	second := NewJob(context.Background(), constants.JobTypeClusterDelete, "kubefirst", "")
+	for _, job := range []pkgtypes.Job{first, second} {
+		if err := secrets.InsertJob(clientset, job); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	err := clientset.CoreV1().Secrets("kubefirst").Delete(context.Background(), "kubefirst-job-"+first.ID, metav1.DeleteOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	jobs, err := secrets.GetJobs(clientset)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(jobs) != 1 || jobs[0].ID != second.ID {
+		t.Errorf("expected only job %s, got %v", second.ID, jobs)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+
+	started := make(chan struct{})
+	job, err := Enqueue(clientset, NewJob(context.Background(), constants.JobTypeClusterCreate, "kubefirst", ""), func(ctx context.Context, _ *pkgtypes.Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	<-started
+	if !Cancel(job.ID) {
+		t.Fatalf("expected job %s to be cancellable", job.ID)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stored, err := secrets.GetJob(clientset, job.ID)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if stored.Status == constants.JobStatusCancelled {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected status %q, got %q", constants.JobStatusCancelled, stored.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if Cancel(job.ID) {
+		t.Errorf("expected finished job %s to no longer be cancellable", job.ID)
+	}
+}
@@ -16,7 +16,7 @@ import (
 	"os"
 
 	goyaml "github.com/go-yaml/yaml"
-	"github.com/rs/zerolog/log"
+	"github.com/konstructio/kubefirst-api/internal/clusterlogs"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -33,7 +33,14 @@ import (
 // ApplyObjects parses a structured Kubernetes-compatible yaml file and applies
 // its objects to a target Kubernetes cluster
 func (kcl KubernetesClient) ApplyObjects(yamlData [][]byte) error {
-	log.Info().Msgf("applying objects against kubernetes cluster")
+	return kcl.ApplyObjectsContext(context.Background(), yamlData)
+}
+
+// ApplyObjectsContext is ApplyObjects, stopping when ctx is cancelled
+func (kcl KubernetesClient) ApplyObjectsContext(ctx context.Context, yamlData [][]byte) error {
+	logger := clusterlogs.Logger(ctx)
+
+	logger.Info().Msgf("applying objects against kubernetes cluster")
 
 	// RESTMapper to find GVR
 	dc, err := discovery.NewDiscoveryClientForConfig(kcl.RestConfig)
@@ -83,14 +90,14 @@ func (kcl KubernetesClient) ApplyObjects(yamlData [][]byte) error {
 		//
 		//	types.ApplyPatchType indicates server-side apply
 		//	FieldManager specifies the field owner ID
-		_, err = dr.Patch(context.Background(), obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
+		_, err = dr.Patch(ctx, obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
 			FieldManager: "kubefirst",
 		})
 		if err != nil {
 			return fmt.Errorf("error applying %s/%s: %w", gvk.Kind, obj.GetName(), err)
 		}
 
-		log.Info().Msgf("applied %s %s", gvk.Kind, obj.GetName())
+		logger.Info().Msgf("applied %s %s", gvk.Kind, obj.GetName())
 	}
 
 	return nil
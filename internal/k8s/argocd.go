@@ -7,9 +7,10 @@ See the LICENSE file for more details.
 package k8s
 
 import (
+	"context"
 	"fmt"
 
-	"github.com/rs/zerolog/log"
+	"github.com/konstructio/kubefirst-api/internal/clusterlogs"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -18,8 +19,17 @@ import (
 //
 // This helps prevent race conditions and timeouts
 func VerifyArgoCDReadiness(clientset kubernetes.Interface, highAvailabilityEnabled bool, timeoutSeconds int) (bool, error) {
+	return VerifyArgoCDReadinessContext(context.Background(), clientset, highAvailabilityEnabled, timeoutSeconds)
+}
+
+// VerifyArgoCDReadinessContext is VerifyArgoCDReadiness, stopping when ctx is
+// cancelled
+func VerifyArgoCDReadinessContext(ctx context.Context, clientset kubernetes.Interface, highAvailabilityEnabled bool, timeoutSeconds int) (bool, error) {
+	logger := clusterlogs.Logger(ctx)
+
 	// Wait for ArgoCD StatefulSet Pods to transition to Running
-	argoCDStatefulSet, err := ReturnStatefulSetObject(
+	argoCDStatefulSet, err := ReturnStatefulSetObjectContext(
+		ctx,
 		clientset,
 		"app.kubernetes.io/part-of",
 		"argocd",
@@ -29,13 +39,14 @@ func VerifyArgoCDReadiness(clientset kubernetes.Interface, highAvailabilityEnabl
 	if err != nil {
 		return false, fmt.Errorf("error finding ArgoCD Application Controller StatefulSet: %w", err)
 	}
-	_, err = WaitForStatefulSetReady(clientset, argoCDStatefulSet, timeoutSeconds, false)
+	_, err = WaitForStatefulSetReadyContext(ctx, clientset, argoCDStatefulSet, timeoutSeconds, false)
 	if err != nil {
 		return false, fmt.Errorf("error waiting for ArgoCD Application Controller StatefulSet ready state: %w", err)
 	}
 
 	// argocd-server Deployment
-	argoCDServerDeployment, err := ReturnDeploymentObject(
+	argoCDServerDeployment, err := ReturnDeploymentObjectContext(
+		ctx,
 		clientset,
 		"app.kubernetes.io/name",
 		"argocd-server",
@@ -43,12 +54,12 @@ func VerifyArgoCDReadiness(clientset kubernetes.Interface, highAvailabilityEnabl
 		timeoutSeconds,
 	)
 	if err != nil {
-		log.Info().Msgf("Error finding ArgoCD server deployment: %s", err)
+		logger.Info().Msgf("Error finding ArgoCD server deployment: %s", err)
 		return false, fmt.Errorf("error finding ArgoCD server deployment: %w", err)
 	}
-	_, err = WaitForDeploymentReady(clientset, argoCDServerDeployment, timeoutSeconds)
+	_, err = WaitForDeploymentReadyContext(ctx, clientset, argoCDServerDeployment, timeoutSeconds)
 	if err != nil {
-		log.Info().Msgf("Error waiting for ArgoCD server deployment ready state: %s", err)
+		logger.Info().Msgf("Error waiting for ArgoCD server deployment ready state: %s", err)
 		return false, fmt.Errorf("error waiting for ArgoCD server deployment ready state: %w", err)
 	}
 
@@ -60,7 +71,8 @@ func VerifyArgoCDReadiness(clientset kubernetes.Interface, highAvailabilityEnabl
 	// may never apply
 
 	// argocd-repo-server
-	argoCDRepoDeployment, err := ReturnDeploymentObject(
+	argoCDRepoDeployment, err := ReturnDeploymentObjectContext(
+		ctx,
 		clientset,
 		"app.kubernetes.io/name",
 		"argocd-repo-server",
@@ -70,7 +82,7 @@ func VerifyArgoCDReadiness(clientset kubernetes.Interface, highAvailabilityEnabl
 	if err != nil {
 		return false, fmt.Errorf("error finding ArgoCD repo deployment: %s", err.Error())
 	}
-	_, err = WaitForDeploymentReady(clientset, argoCDRepoDeployment, timeoutSeconds)
+	_, err = WaitForDeploymentReadyContext(ctx, clientset, argoCDRepoDeployment, timeoutSeconds)
 	if err != nil {
 		return false, fmt.Errorf("error waiting for ArgoCD repo deployment ready state: %s", err.Error())
 	}
@@ -78,7 +90,8 @@ func VerifyArgoCDReadiness(clientset kubernetes.Interface, highAvailabilityEnabl
 	// high availability components
 	if highAvailabilityEnabled {
 		// argocd-redis-ha-haproxy Deployment
-		argoCDRedisHAhaproxyDeployment, err := ReturnDeploymentObject(
+		argoCDRedisHAhaproxyDeployment, err := ReturnDeploymentObjectContext(
+			ctx,
 			clientset,
 			"app.kubernetes.io/name",
 			"argocd-redis-ha-haproxy",
@@ -88,13 +101,14 @@ func VerifyArgoCDReadiness(clientset kubernetes.Interface, highAvailabilityEnabl
 		if err != nil {
 			return false, fmt.Errorf("error finding ArgoCD argocd-redis-ha-haproxy Deployment: %w", err)
 		}
-		_, err = WaitForDeploymentReady(clientset, argoCDRedisHAhaproxyDeployment, timeoutSeconds)
+		_, err = WaitForDeploymentReadyContext(ctx, clientset, argoCDRedisHAhaproxyDeployment, timeoutSeconds)
 		if err != nil {
 			return false, fmt.Errorf("error waiting for ArgoCD argocd-redis-ha-haproxy deployment ready state: %s", err.Error())
 		}
 
 		// argocd-redis-ha StatefulSet
-		argoCDRedisHAServerStatefulSet, err := ReturnStatefulSetObject(
+		argoCDRedisHAServerStatefulSet, err := ReturnStatefulSetObjectContext(
+			ctx,
 			clientset,
 			"app.kubernetes.io/name",
 			"argocd-redis-ha",
@@ -104,14 +118,15 @@ func VerifyArgoCDReadiness(clientset kubernetes.Interface, highAvailabilityEnabl
 		if err != nil {
 			return false, fmt.Errorf("error finding ArgoCD argocd-redis-ha StatefulSet: %s", err.Error())
 		}
-		_, err = WaitForStatefulSetReady(clientset, argoCDRedisHAServerStatefulSet, timeoutSeconds, false)
+		_, err = WaitForStatefulSetReadyContext(ctx, clientset, argoCDRedisHAServerStatefulSet, timeoutSeconds, false)
 		if err != nil {
 			return false, fmt.Errorf("error waiting for ArgoCD argocd-redis-ha StatefulSet ready state: %s", err.Error())
 		}
 	} else {
 		// non-high availability components
 		// argocd-redis Deployment
-		argoCDRedisDeployment, err := ReturnDeploymentObject(
+		argoCDRedisDeployment, err := ReturnDeploymentObjectContext(
+			ctx,
 			clientset,
 			"app.kubernetes.io/name",
 			"argocd-redis",
@@ -121,7 +136,7 @@ func VerifyArgoCDReadiness(clientset kubernetes.Interface, highAvailabilityEnabl
 		if err != nil {
 			return false, fmt.Errorf("error finding ArgoCD argocd-redis Deployment: %w", err)
 		}
-		_, err = WaitForDeploymentReady(clientset, argoCDRedisDeployment, timeoutSeconds)
+		_, err = WaitForDeploymentReadyContext(ctx, clientset, argoCDRedisDeployment, timeoutSeconds)
 		if err != nil {
 			return false, fmt.Errorf("error waiting for ArgoCD argocd-redis Deployment ready state: %w", err)
 		}
@@ -13,9 +13,11 @@ import (
 	"io"
 	"net"
 	"os"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/konstructio/kubefirst-api/internal/clusterlogs"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/term"
 	appsv1 "k8s.io/api/apps/v1"
@@ -165,11 +167,16 @@ func podExec(kubeConfigPath string, ps *PodSessionOptions, pe v1.PodExecOptions,
 }
 
 func ReturnDeploymentObject(client kubernetes.Interface, matchLabel string, matchLabelValue string, namespace string, timeoutSeconds int) (*appsv1.Deployment, error) {
+	return ReturnDeploymentObjectContext(context.Background(), client, matchLabel, matchLabelValue, namespace, timeoutSeconds)
+}
+
+// ReturnDeploymentObjectContext is ReturnDeploymentObject, stopping when ctx is cancelled
+func ReturnDeploymentObjectContext(ctx context.Context, client kubernetes.Interface, matchLabel string, matchLabelValue string, namespace string, timeoutSeconds int) (*appsv1.Deployment, error) {
 	timeout := time.Duration(timeoutSeconds) * time.Second
 	var deployment *appsv1.Deployment
 
-	err := wait.PollImmediate(15*time.Second, timeout, func() (bool, error) {
-		deployments, err := client.AppsV1().Deployments(namespace).List(context.Background(), metav1.ListOptions{
+	err := wait.PollImmediateWithContext(ctx, 15*time.Second, timeout, func(ctx context.Context) (bool, error) {
+		deployments, err := client.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{
 			LabelSelector: fmt.Sprintf("%s=%s", matchLabel, matchLabelValue),
 		})
 		if err != nil {
@@ -207,29 +214,36 @@ func ReturnDeploymentObject(client kubernetes.Interface, matchLabel string, matc
 
 // ReturnPodObject returns a matching v1.Pod object based on the filters
 func ReturnPodObject(kubeConfigPath, matchLabel, matchLabelValue, namespace string, timeoutSeconds int) (*v1.Pod, error) {
+	return ReturnPodObjectContext(context.Background(), kubeConfigPath, matchLabel, matchLabelValue, namespace, timeoutSeconds)
+}
+
+// ReturnPodObjectContext is ReturnPodObject, stopping when ctx is cancelled
+func ReturnPodObjectContext(ctx context.Context, kubeConfigPath, matchLabel, matchLabelValue, namespace string, timeoutSeconds int) (*v1.Pod, error) {
+	logger := clusterlogs.Logger(ctx)
+
 	clientset, err := GetClientSet(kubeConfigPath)
 	if err != nil {
 		return nil, fmt.Errorf("error getting client set from kubeConfigPath %q: %w", kubeConfigPath, err)
 	}
 
 	labelSelector := fmt.Sprintf("%s=%s", matchLabel, matchLabelValue)
-	log.Info().Msgf("waiting for pod with label %s=%s to be created in namespace %q", matchLabel, matchLabelValue, namespace)
+	logger.Info().Msgf("waiting for pod with label %s=%s to be created in namespace %q", matchLabel, matchLabelValue, namespace)
 
 	var pod *v1.Pod
 
-	err = wait.PollImmediate(5*time.Second, time.Duration(timeoutSeconds)*time.Second, func() (bool, error) {
-		podList, err := clientset.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{
+	err = wait.PollImmediateWithContext(ctx, 5*time.Second, time.Duration(timeoutSeconds)*time.Second, func(ctx context.Context) (bool, error) {
+		podList, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
 			LabelSelector: labelSelector,
 		})
 		if err != nil {
 			// If we couldn't connect, retry
 			if isNetworkingError(err) {
-				log.Warn().Msgf("connection error, retrying: %s", err.Error())
+				logger.Warn().Msgf("connection error, retrying: %s", err.Error())
 				return false, nil
 			}
 
 			// For other errors, log and return the error to stop polling
-			log.Error().Msgf("error listing Pods: %v", err)
+			logger.Error().Msgf("error listing Pods: %v", err)
 			return false, fmt.Errorf("error listing pods: %w", err)
 		}
 
@@ -248,7 +262,7 @@ func ReturnPodObject(kubeConfigPath, matchLabel, matchLabelValue, namespace stri
 		return false, nil
 	})
 	if err != nil {
-		log.Error().Msg("the pod was not created within the timeout period")
+		logger.Error().Msg("the pod was not created within the timeout period")
 		return nil, fmt.Errorf("the Pod %q in Namespace %q was not created within the timeout period: %w", matchLabelValue, namespace, err)
 	}
 
@@ -257,24 +271,31 @@ func ReturnPodObject(kubeConfigPath, matchLabel, matchLabelValue, namespace stri
 
 // ReturnStatefulSetObject returns a matching appsv1.StatefulSet object based on the filters
 func ReturnStatefulSetObject(clientset kubernetes.Interface, matchLabel, matchLabelValue, namespace string, timeoutSeconds int) (*appsv1.StatefulSet, error) {
+	return ReturnStatefulSetObjectContext(context.Background(), clientset, matchLabel, matchLabelValue, namespace, timeoutSeconds)
+}
+
+// ReturnStatefulSetObjectContext is ReturnStatefulSetObject, stopping when ctx is cancelled
+func ReturnStatefulSetObjectContext(ctx context.Context, clientset kubernetes.Interface, matchLabel, matchLabelValue, namespace string, timeoutSeconds int) (*appsv1.StatefulSet, error) {
+	logger := clusterlogs.Logger(ctx)
+
 	labelSelector := fmt.Sprintf("%s=%s", matchLabel, matchLabelValue)
-	log.Info().Msgf("waiting for StatefulSet with label %s=%s to be created in namespace %q", matchLabel, matchLabelValue, namespace)
+	logger.Info().Msgf("waiting for StatefulSet with label %s=%s to be created in namespace %q", matchLabel, matchLabelValue, namespace)
 
 	var statefulSet *appsv1.StatefulSet
 
-	err := wait.PollImmediate(5*time.Second, time.Duration(timeoutSeconds)*time.Second, func() (bool, error) {
-		statefulSets, err := clientset.AppsV1().StatefulSets(namespace).List(context.Background(), metav1.ListOptions{
+	err := wait.PollImmediateWithContext(ctx, 5*time.Second, time.Duration(timeoutSeconds)*time.Second, func(ctx context.Context) (bool, error) {
+		statefulSets, err := clientset.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{
 			LabelSelector: labelSelector,
 		})
 		if err != nil {
 			// If we couldn't connect, retry
 			if isNetworkingError(err) {
-				log.Warn().Msgf("connection error, retrying: %s", err.Error())
+				logger.Warn().Msgf("connection error, retrying: %s", err.Error())
 				return false, nil
 			}
 
 			// For other errors, log and return the error to stop polling
-			log.Error().Msgf("error listing StatefulSets: %v", err)
+			logger.Error().Msgf("error listing StatefulSets: %v", err)
 			return false, fmt.Errorf("error listing statefulsets: %w", err)
 		}
 
@@ -293,7 +314,7 @@ func ReturnStatefulSetObject(clientset kubernetes.Interface, matchLabel, matchLa
 		return false, nil
 	})
 	if err != nil {
-		log.Error().Msg("the StatefulSet was not created within the timeout period")
+		logger.Error().Msg("the StatefulSet was not created within the timeout period")
 		return nil, fmt.Errorf("the StatefulSet %q in Namespace %q was not created within the timeout period: %w", matchLabelValue, namespace, err)
 	}
 
@@ -302,35 +323,42 @@ func ReturnStatefulSetObject(clientset kubernetes.Interface, matchLabel, matchLa
 
 // WaitForDeploymentReady waits for a target Deployment to become ready
 func WaitForDeploymentReady(clientset kubernetes.Interface, deployment *appsv1.Deployment, timeoutSeconds int) (bool, error) {
+	return WaitForDeploymentReadyContext(context.Background(), clientset, deployment, timeoutSeconds)
+}
+
+// WaitForDeploymentReadyContext is WaitForDeploymentReady, stopping when ctx is cancelled
+func WaitForDeploymentReadyContext(ctx context.Context, clientset kubernetes.Interface, deployment *appsv1.Deployment, timeoutSeconds int) (bool, error) {
+	logger := clusterlogs.Logger(ctx)
+
 	deploymentName := deployment.Name
 	namespace := deployment.Namespace
 
 	// Get the desired number of replicas from the deployment spec
 	if deployment.Spec.Replicas == nil {
-		log.Error().Msgf("deployment %q in namespace %q has nil spec.replicas field", deploymentName, namespace)
+		logger.Error().Msgf("deployment %q in namespace %q has nil spec.replicas field", deploymentName, namespace)
 		return false, fmt.Errorf("deployment %q in Namespace %q has nil Spec.Replicas", deploymentName, namespace)
 	}
 	desiredReplicas := *deployment.Spec.Replicas
 
-	log.Info().Msgf("waiting for deployment %q in namespace %q to be ready - this could take up to %v seconds", deploymentName, namespace, timeoutSeconds)
+	logger.Info().Msgf("waiting for deployment %q in namespace %q to be ready - this could take up to %v seconds", deploymentName, namespace, timeoutSeconds)
 
-	err := wait.PollImmediate(5*time.Second, time.Duration(timeoutSeconds)*time.Second, func() (bool, error) {
+	err := wait.PollImmediateWithContext(ctx, 5*time.Second, time.Duration(timeoutSeconds)*time.Second, func(ctx context.Context) (bool, error) {
 		// Get the latest Deployment object
-		currentDeployment, err := clientset.AppsV1().Deployments(namespace).Get(context.Background(), deploymentName, metav1.GetOptions{})
+		currentDeployment, err := clientset.AppsV1().Deployments(namespace).Get(ctx, deploymentName, metav1.GetOptions{})
 		if err != nil {
 			// If we couldn't connect, retry
 			if isNetworkingError(err) {
-				log.Warn().Msgf("connection error, retrying: %s", err.Error())
+				logger.Warn().Msgf("connection error, retrying: %s", err.Error())
 				return false, nil
 			}
 
 			// For other errors, log and return the error to stop polling
-			log.Error().Msgf("error when getting deployment %q in namespace %q: %v", deploymentName, namespace, err)
+			logger.Error().Msgf("error when getting deployment %q in namespace %q: %v", deploymentName, namespace, err)
 			return false, fmt.Errorf("error listing statefulsets: %w", err)
 		}
 
 		if currentDeployment.Status.ReadyReplicas == desiredReplicas {
-			log.Info().Msgf("all pods in deployment %q are ready", deploymentName)
+			logger.Info().Msgf("all pods in deployment %q are ready", deploymentName)
 			return true, nil
 		}
 
@@ -338,7 +366,7 @@ func WaitForDeploymentReady(clientset kubernetes.Interface, deployment *appsv1.D
 		return false, nil
 	})
 	if err != nil {
-		log.Error().Msgf("the deployment %q in namespace %q was not ready within the timeout period", deploymentName, namespace)
+		logger.Error().Msgf("the deployment %q in namespace %q was not ready within the timeout period", deploymentName, namespace)
 		return false, fmt.Errorf("the Deployment %q in Namespace %q was not ready within the timeout period: %w", deploymentName, namespace, err)
 	}
 
@@ -347,28 +375,35 @@ func WaitForDeploymentReady(clientset kubernetes.Interface, deployment *appsv1.D
 
 // WaitForPodReady waits for a target Pod to become ready
 func WaitForPodReady(clientset kubernetes.Interface, pod *v1.Pod, timeoutSeconds int) (bool, error) {
+	return WaitForPodReadyContext(context.Background(), clientset, pod, timeoutSeconds)
+}
+
+// WaitForPodReadyContext is WaitForPodReady, stopping when ctx is cancelled
+func WaitForPodReadyContext(ctx context.Context, clientset kubernetes.Interface, pod *v1.Pod, timeoutSeconds int) (bool, error) {
+	logger := clusterlogs.Logger(ctx)
+
 	podName := pod.Name
 	namespace := pod.Namespace
 
-	log.Info().Msgf("waiting for pod %q in namespace %q to be ready - this could take up to %v seconds", podName, namespace, timeoutSeconds)
+	logger.Info().Msgf("waiting for pod %q in namespace %q to be ready - this could take up to %v seconds", podName, namespace, timeoutSeconds)
 
-	err := wait.PollImmediate(5*time.Second, time.Duration(timeoutSeconds)*time.Second, func() (bool, error) {
+	err := wait.PollImmediateWithContext(ctx, 5*time.Second, time.Duration(timeoutSeconds)*time.Second, func(ctx context.Context) (bool, error) {
 		// Get the latest Pod object
-		currentPod, err := clientset.CoreV1().Pods(namespace).Get(context.Background(), podName, metav1.GetOptions{})
+		currentPod, err := clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
 		if err != nil {
 			// If we couldn't connect, retry
 			if isNetworkingError(err) {
-				log.Warn().Msgf("connection error, retrying: %s", err.Error())
+				logger.Warn().Msgf("connection error, retrying: %s", err.Error())
 				return false, nil
 			}
 
 			// For other errors, log and return the error to stop polling
-			log.Error().Msgf("error getting pod %q in namespace %q: %v", podName, namespace, err)
+			logger.Error().Msgf("error getting pod %q in namespace %q: %v", podName, namespace, err)
 			return false, fmt.Errorf("error listing pods: %w", err)
 		}
 
 		if currentPod.Status.Phase == v1.PodRunning {
-			log.Info().Msgf("pod %q has status %q", podName, currentPod.Status.Phase)
+			logger.Info().Msgf("pod %q has status %q", podName, currentPod.Status.Phase)
 			return true, nil
 		}
 
@@ -376,39 +411,101 @@ func WaitForPodReady(clientset kubernetes.Interface, pod *v1.Pod, timeoutSeconds
 		return false, nil
 	})
 	if err != nil {
-		log.Error().Msgf("the operation timed out while waiting for pod %q in namespace %q to become ready", podName, namespace)
+		logger.Error().Msgf("the operation timed out while waiting for pod %q in namespace %q to become ready", podName, namespace)
 		return false, fmt.Errorf("the operation timed out while waiting for Pod %q in Namespace %q: %w", podName, namespace, err)
 	}
 
 	return true, nil
 }
 
+// WaitForNodesVersion waits for every node to be ready and to run a kubelet
+// whose version starts with version, such as v1.29
+func WaitForNodesVersion(ctx context.Context, clientset kubernetes.Interface, version string, timeoutSeconds int) (bool, error) {
+	logger := clusterlogs.Logger(ctx)
+
+	logger.Info().Msgf("waiting for all nodes to run kubelet %s - this could take up to %v seconds", version, timeoutSeconds)
+
+	err := wait.PollImmediateWithContext(ctx, 15*time.Second, time.Duration(timeoutSeconds)*time.Second, func(ctx context.Context) (bool, error) {
+		nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			// If we couldn't connect, retry, the control plane may be restarting
+			if isNetworkingError(err) {
+				logger.Warn().Msgf("connection error, retrying: %s", err.Error())
+				return false, nil
+			}
+
+			logger.Error().Msgf("error listing nodes: %v", err)
+			return false, fmt.Errorf("error listing nodes: %w", err)
+		}
+
+		if len(nodes.Items) == 0 {
+			return false, nil
+		}
+
+		pending := 0
+		for _, node := range nodes.Items {
+			if !strings.HasPrefix(node.Status.NodeInfo.KubeletVersion, version) || !nodeReady(node) {
+				pending++
+			}
+		}
+		if pending > 0 {
+			logger.Info().Msgf("%d of %d nodes are not ready on kubelet %s yet", pending, len(nodes.Items), version)
+			return false, nil
+		}
+
+		logger.Info().Msgf("all %d nodes are ready on kubelet %s", len(nodes.Items), version)
+		return true, nil
+	})
+	if err != nil {
+		logger.Error().Msgf("nodes were not ready on kubelet %s within the timeout period", version)
+		return false, fmt.Errorf("nodes were not ready on kubelet %s within the timeout period: %w", version, err)
+	}
+
+	return true, nil
+}
+
+func nodeReady(node v1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
 // WaitForStatefulSetReady waits for a target StatefulSet to become ready
 func WaitForStatefulSetReady(clientset kubernetes.Interface, statefulset *appsv1.StatefulSet, timeoutSeconds int, ignoreReady bool) (bool, error) {
+	return WaitForStatefulSetReadyContext(context.Background(), clientset, statefulset, timeoutSeconds, ignoreReady)
+}
+
+// WaitForStatefulSetReadyContext is WaitForStatefulSetReady, stopping when ctx is cancelled
+func WaitForStatefulSetReadyContext(ctx context.Context, clientset kubernetes.Interface, statefulset *appsv1.StatefulSet, timeoutSeconds int, ignoreReady bool) (bool, error) {
+	logger := clusterlogs.Logger(ctx)
+
 	statefulSetName := statefulset.Name
 	namespace := statefulset.Namespace
 
 	// Get the desired number of replicas from the StatefulSet spec
 	if statefulset.Spec.Replicas == nil {
-		log.Error().Msgf("statefulSet %q in namespace %s has nil spec.replicas", statefulSetName, namespace)
+		logger.Error().Msgf("statefulSet %q in namespace %s has nil spec.replicas", statefulSetName, namespace)
 		return false, fmt.Errorf("StatefulSet %q in Namespace %q has nil Spec.Replicas", statefulSetName, namespace)
 	}
 	desiredReplicas := *statefulset.Spec.Replicas
 
-	log.Info().Msgf("waiting for statefulset %q in namespace %q to be ready - this could take up to %v seconds", statefulSetName, namespace, timeoutSeconds)
+	logger.Info().Msgf("waiting for statefulset %q in namespace %q to be ready - this could take up to %v seconds", statefulSetName, namespace, timeoutSeconds)
 
-	err := wait.PollImmediate(5*time.Second, time.Duration(timeoutSeconds)*time.Second, func() (bool, error) {
+	err := wait.PollImmediateWithContext(ctx, 5*time.Second, time.Duration(timeoutSeconds)*time.Second, func(ctx context.Context) (bool, error) {
 		// Get the latest StatefulSet object
-		currentStatefulSet, err := clientset.AppsV1().StatefulSets(namespace).Get(context.Background(), statefulSetName, metav1.GetOptions{})
+		currentStatefulSet, err := clientset.AppsV1().StatefulSets(namespace).Get(ctx, statefulSetName, metav1.GetOptions{})
 		if err != nil {
 			// If we couldn't connect, retry
 			if isNetworkingError(err) {
-				log.Warn().Msgf("connection error, retrying: %s", err.Error())
+				logger.Warn().Msgf("connection error, retrying: %s", err.Error())
 				return false, nil
 			}
 
 			// For other errors, log and return the error to stop polling
-			log.Error().Msgf("error when getting statefulset %q in namespace %s: %v", statefulSetName, namespace, err)
+			logger.Error().Msgf("error when getting statefulset %q in namespace %s: %v", statefulSetName, namespace, err)
 			return false, fmt.Errorf("error listing statefulsets: %w", err)
 		}
 
@@ -418,17 +515,17 @@ func WaitForStatefulSetReady(clientset kubernetes.Interface, statefulset *appsv1
 				currentRevision := currentStatefulSet.Status.CurrentRevision
 
 				// Get Pods owned by the StatefulSet
-				pods, err := clientset.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{
+				pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
 					LabelSelector: fmt.Sprintf("controller-revision-hash=%s", currentRevision),
 				})
 				if err != nil {
 					// If we couldn't connect, retry
 					if isNetworkingError(err) {
-						log.Warn().Msg("connection refused while listing pods, retrying...")
+						logger.Warn().Msg("connection refused while listing pods, retrying...")
 						return false, nil
 					}
 
-					log.Error().Msgf("could not find pods owned by statefulset %q in namespace %q: %v", statefulSetName, namespace, err)
+					logger.Error().Msgf("could not find pods owned by statefulset %q in namespace %q: %v", statefulSetName, namespace, err)
 					return false, fmt.Errorf("error listing statefulsets: %w", err)
 				}
 
@@ -442,14 +539,14 @@ func WaitForStatefulSetReady(clientset kubernetes.Interface, statefulset *appsv1
 				}
 
 				if allRunning {
-					log.Info().Msgf("all pods in statefulset %q are running", statefulSetName)
+					logger.Info().Msgf("all pods in statefulset %q are running", statefulSetName)
 					return true, nil
 				}
 			}
 		} else {
 			// Check if ReadyReplicas match desired replicas
 			if currentStatefulSet.Status.ReadyReplicas == desiredReplicas {
-				log.Info().Msgf("all pods in statefulset %q are ready", statefulSetName)
+				logger.Info().Msgf("all pods in statefulset %q are ready", statefulSetName)
 				return true, nil
 			}
 		}
@@ -458,7 +555,7 @@ func WaitForStatefulSetReady(clientset kubernetes.Interface, statefulset *appsv1
 		return false, nil
 	})
 	if err != nil {
-		log.Error().Msgf("the statefulset %q in namespace %q was not ready within the timeout period", statefulSetName, namespace)
+		logger.Error().Msgf("the statefulset %q in namespace %q was not ready within the timeout period", statefulSetName, namespace)
 		return false, fmt.Errorf("the StatefulSet %q in Namespace %q was not ready within the timeout period: %w", statefulSetName, namespace, err)
 	}
 
@@ -62,3 +62,54 @@ func UpdateSecretV2(clientset kubernetes.Interface, namespace string, secretName
 	log.Info().Msgf("updated secret %q in namespace %q", currentSecret.Name, currentSecret.Namespace)
 	return nil
 }
+
+// ReadSecretV2WithResourceVersion reads the content of a Kubernetes Secret
+// along with its resourceVersion
+func ReadSecretV2WithResourceVersion(clientset kubernetes.Interface, namespace string, secretName string) (map[string]interface{}, string, error) {
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.Background(), secretName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			log.Warn().Msgf("no secret found: %s", err)
+			return nil, "", fmt.Errorf("no secret found: %w", err)
+		}
+
+		log.Warn().Msgf("unable to pull secret: %s", err)
+		return nil, "", fmt.Errorf("unable to pull secret: %w", err)
+	}
+
+	parsedSecretData := make(map[string]interface{})
+	for key, value := range secret.Data {
+		parsedSecretData[key] = string(value)
+	}
+
+	return parsedSecretData, secret.ResourceVersion, nil
+}
+
+// UpdateSecretV2WithResourceVersion updates the key value pairs of a
+// Kubernetes Secret only if it is still at resourceVersion, and returns its
+// new resourceVersion. The update fails with a conflict error when the Secret
+// changed in the meantime. An empty resourceVersion updates the Secret
+// whatever its version.
+func UpdateSecretV2WithResourceVersion(clientset kubernetes.Interface, namespace string, secretName string, resourceVersion string, secretValues map[string][]byte) (string, error) {
+	currentSecret, err := clientset.CoreV1().Secrets(namespace).Get(context.Background(), secretName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("error getting secret: %w", err)
+	}
+
+	if resourceVersion != "" {
+		currentSecret.ResourceVersion = resourceVersion
+	}
+	currentSecret.Data = secretValues
+
+	updatedSecret, err := clientset.CoreV1().Secrets(currentSecret.Namespace).Update(
+		context.Background(),
+		currentSecret,
+		metav1.UpdateOptions{},
+	)
+	if err != nil {
+		return "", fmt.Errorf("error updating secret: %w", err)
+	}
+
+	log.Info().Msgf("updated secret %q in namespace %q", currentSecret.Name, currentSecret.Namespace)
+	return updatedSecret.ResourceVersion, nil
+}
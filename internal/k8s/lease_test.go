@@ -0,0 +1,68 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAcquireLease(t *testing.T) {
+	lease := func(holder string, renewed time.Time) *coordinationv1.Lease {
+		renewTime := metav1.NewMicroTime(renewed)
+		seconds := int32(3600)
+		return &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: "drift", Namespace: "kubefirst"},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &holder,
+				LeaseDurationSeconds: &seconds,
+				RenewTime:            &renewTime,
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		existing *coordinationv1.Lease
+		expected bool
+	}{
+		{name: "no lease", expected: true},
+		{name: "held by us", existing: lease("api-1", time.Now()), expected: true},
+		{name: "held by another replica", existing: lease("api-2", time.Now()), expected: false},
+		{name: "expired", existing: lease("api-2", time.Now().Add(-2*time.Hour)), expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset()
+			if tt.existing != nil {
+				clientset = fake.NewSimpleClientset(tt.existing)
+			}
+
+			acquired, err := AcquireLease(context.Background(), clientset, "kubefirst", "drift", "api-1", time.Hour)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if acquired != tt.expected {
+				t.Fatalf("expected acquired %v, got %v", tt.expected, acquired)
+			}
+
+			stored, err := clientset.CoordinationV1().Leases("kubefirst").Get(context.Background(), "drift", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if holder := *stored.Spec.HolderIdentity; acquired && holder != "api-1" {
+				t.Errorf("expected holder %q, got %q", "api-1", holder)
+			}
+		})
+	}
+}
@@ -11,7 +11,7 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/rs/zerolog/log"
+	"github.com/konstructio/kubefirst-api/internal/clusterlogs"
 	batchv1 "k8s.io/api/batch/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -19,7 +19,12 @@ import (
 
 // ReturnJobObject returns a matching appsv1.StatefulSet object based on the filters
 func ReturnJobObject(clientset kubernetes.Interface, namespace, jobName string) (*batchv1.Job, error) {
-	job, err := clientset.BatchV1().Jobs(namespace).Get(context.Background(), jobName, metav1.GetOptions{})
+	return ReturnJobObjectContext(context.Background(), clientset, namespace, jobName)
+}
+
+// ReturnJobObjectContext is ReturnJobObject, stopping when ctx is cancelled
+func ReturnJobObjectContext(ctx context.Context, clientset kubernetes.Interface, namespace, jobName string) (*batchv1.Job, error) {
+	job, err := clientset.BatchV1().Jobs(namespace).Get(ctx, jobName, metav1.GetOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve Job %q in namespace %q: %w", jobName, namespace, err)
 	}
@@ -29,6 +34,13 @@ func ReturnJobObject(clientset kubernetes.Interface, namespace, jobName string)
 
 // WaitForJobComplete waits for a target Job to reach completion
 func WaitForJobComplete(clientset kubernetes.Interface, jobName, jobNamespace string, timeoutSeconds int64) (bool, error) {
+	return WaitForJobCompleteContext(context.Background(), clientset, jobName, jobNamespace, timeoutSeconds)
+}
+
+// WaitForJobCompleteContext is WaitForJobComplete, stopping when ctx is cancelled
+func WaitForJobCompleteContext(ctx context.Context, clientset kubernetes.Interface, jobName, jobNamespace string, timeoutSeconds int64) (bool, error) {
+	logger := clusterlogs.Logger(ctx)
+
 	// Format list for metav1.ListOptions for watch
 	watchOptions := metav1.ListOptions{
 		FieldSelector: fmt.Sprintf(
@@ -39,34 +51,38 @@ func WaitForJobComplete(clientset kubernetes.Interface, jobName, jobNamespace st
 	objWatch, err := clientset.
 		BatchV1().
 		Jobs(jobNamespace).
-		Watch(context.Background(), watchOptions)
+		Watch(ctx, watchOptions)
 	if err != nil {
-		log.Error().Msgf("error when attempting to wait for Job: %s", err)
+		logger.Error().Msgf("error when attempting to wait for Job: %s", err)
 		return false, fmt.Errorf("unable to create watch for Job %q in namespace %q: %w", jobName, jobNamespace, err)
 	}
-	log.Info().Msgf("waiting for %s Job completion. This could take up to %v seconds.", jobName, timeoutSeconds)
+	defer objWatch.Stop()
+	logger.Info().Msgf("waiting for %s Job completion. This could take up to %v seconds.", jobName, timeoutSeconds)
 
 	// Feed events using provided channel
 	objChan := objWatch.ResultChan()
 
 	// Listen until the Job is complete
 	// Timeout if it isn't complete within timeoutSeconds
+	timeout := time.After(time.Duration(timeoutSeconds) * time.Second)
 	for {
 		select {
+		case <-ctx.Done():
+			return false, fmt.Errorf("stopped waiting for Job %q in namespace %s: %w", jobName, jobNamespace, ctx.Err())
 		case event, ok := <-objChan:
 			if !ok {
 				// Error if the channel closes
-				log.Error().Msgf("failed to wait for job %s to complete", jobName)
+				logger.Error().Msgf("failed to wait for job %s to complete", jobName)
 				return false, fmt.Errorf("job %q channel closed unexpectedly while waiting for completion", jobName)
 			}
 			if event.
 				Object.(*batchv1.Job).
 				Status.Succeeded > 0 {
-				log.Info().Msgf("job %s completed at %s.", jobName, event.Object.(*batchv1.Job).Status.CompletionTime)
+				logger.Info().Msgf("job %s completed at %s.", jobName, event.Object.(*batchv1.Job).Status.CompletionTime)
 				return true, nil
 			}
-		case <-time.After(time.Duration(timeoutSeconds) * time.Second):
-			log.Error().Msg("the operation timed out while waiting for the Job to complete")
+		case <-timeout:
+			logger.Error().Msg("the operation timed out while waiting for the Job to complete")
 			return false, fmt.Errorf("the operation timed out while waiting for Job %q in namespace %s to complete", jobName, jobNamespace)
 		}
 	}
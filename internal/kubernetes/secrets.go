@@ -4,7 +4,7 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/rs/zerolog/log"
+	"github.com/konstructio/kubefirst-api/internal/clusterlogs"
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -20,10 +20,12 @@ type Secret struct {
 }
 
 func CreateSecretsIfNotExist(ctx context.Context, k8s kubernetes.Interface, secrets []Secret) error {
+	logger := clusterlogs.Logger(ctx)
+
 	for _, s := range secrets {
 		secret := createSecret(s)
 
-		log.Info().Msgf("creating secret %q", secret.Name)
+		logger.Info().Msgf("creating secret %q", secret.Name)
 		_, err := k8s.CoreV1().Secrets(secret.Namespace).Get(ctx, secret.Name, metav1.GetOptions{})
 		if err != nil {
 			if apierrors.IsNotFound(err) {
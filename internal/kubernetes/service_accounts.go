@@ -4,7 +4,7 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/rs/zerolog/log"
+	"github.com/konstructio/kubefirst-api/internal/clusterlogs"
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -20,10 +20,12 @@ type ServiceAccount struct {
 }
 
 func CreateServiceAccountsIfNotExist(ctx context.Context, k8s kubernetes.Interface, serviceAccounts []ServiceAccount) error {
+	logger := clusterlogs.Logger(ctx)
+
 	for _, sa := range serviceAccounts {
 		serviceAccount := createServiceAccount(sa)
 
-		log.Info().Msgf("creating service account %q", serviceAccount.Name)
+		logger.Info().Msgf("creating service account %q", serviceAccount.Name)
 		_, err := k8s.CoreV1().ServiceAccounts(serviceAccount.Namespace).Get(ctx, serviceAccount.Name, metav1.GetOptions{})
 		if err != nil {
 			if apierrors.IsNotFound(err) {
@@ -0,0 +1,130 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/konstructio/kubefirst-api/internal/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"google.golang.org/grpc"
+)
+
+// External services whose call latency is recorded
+const (
+	ServiceAkamai       = "akamai"
+	ServiceAWS          = "aws"
+	ServiceCivo         = "civo"
+	ServiceDigitalOcean = "digitalocean"
+	ServiceGoogle       = "google"
+	ServiceVultr        = "vultr"
+	ServiceGitHub       = "github"
+	ServiceGitLab       = "gitlab"
+)
+
+// Doer sends HTTP requests, like *http.Client and the HTTP clients of the AWS
+// SDK
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// ObserveExternalCall records a call to service started at start and traces
+// it as part of ctx, for SDKs that do not let their HTTP client be replaced
+func ObserveExternalCall(ctx context.Context, service string, start time.Time, err error) {
+	observeCall(service, start, err)
+	tracing.Record(ctx, service, start, err)
+}
+
+func observeCall(service string, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+
+	externalRequestDuration.WithLabelValues(service, outcome).Observe(time.Since(start).Seconds())
+}
+
+// observeResponse records a call to service, server errors counting as errors
+func observeResponse(service string, start time.Time, resp *http.Response, err error) {
+	if err == nil && resp.StatusCode >= http.StatusInternalServerError {
+		err = errServerError
+	}
+
+	observeCall(service, start, err)
+}
+
+var errServerError = errors.New("server error")
+
+// Transport records the latency of the requests sent through next, or
+// through http.DefaultTransport when next is nil, and traces them
+func Transport(service string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return roundTripper{service: service, next: next}
+}
+
+// HTTPClient returns an HTTP client recording the latency of its requests to
+// service and tracing them
+func HTTPClient(service string) *http.Client {
+	return &http.Client{Transport: Transport(service, nil)}
+}
+
+type roundTripper struct {
+	service string
+	next    http.RoundTripper
+}
+
+func (t roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req, done := tracing.ClientRequest(t.service, req)
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	observeResponse(t.service, start, resp, err)
+	done(resp, err)
+
+	return resp, err //nolint:wrapcheck // the error is returned as is to the HTTP client
+}
+
+// Client records the latency of the requests sent by next and traces them
+func Client(service string, next Doer) *TimedClient {
+	return &TimedClient{service: service, next: next}
+}
+
+// TimedClient records the latency of the requests it sends and traces them
+type TimedClient struct {
+	service string
+	next    Doer
+}
+
+// Do sends req and records its latency
+func (d *TimedClient) Do(req *http.Request) (*http.Response, error) {
+	req, done := tracing.ClientRequest(d.service, req)
+	start := time.Now()
+	resp, err := d.next.Do(req)
+	observeResponse(d.service, start, resp, err)
+	done(resp, err)
+
+	return resp, err //nolint:wrapcheck // the error is returned as is to the SDK
+}
+
+// UnaryClientInterceptor records the latency of the gRPC calls to service
+// and traces them
+func UnaryClientInterceptor(service string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, endSpan := tracing.Start(ctx, method, semconv.RPCSystemGRPC, semconv.PeerService(service))
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		observeCall(service, start, err)
+		endSpan(err)
+
+		return err
+	}
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestTransportOutcome(t *testing.T) {
+	tests := []struct {
+		name    string
+		service string
+		status  int
+		outcome string
+	}{
+		{name: "success", service: "test-ok", status: http.StatusOK, outcome: "success"},
+		{name: "client error is a response", service: "test-not-found", status: http.StatusNotFound, outcome: "success"},
+		{name: "server error", service: "test-unavailable", status: http.StatusServiceUnavailable, outcome: "error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			resp, err := HTTPClient(tt.service).Get(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if got := externalObservations(t, tt.service, tt.outcome); got != 1 {
+				t.Errorf("expected 1 %s call recorded, got %d", tt.outcome, got)
+			}
+		})
+	}
+}
+
+func externalObservations(t *testing.T, service, outcome string) uint64 {
+	t.Helper()
+
+	metric := &dto.Metric{}
+	if err := externalRequestDuration.WithLabelValues(service, outcome).(prometheus.Metric).Write(metric); err != nil {
+		t.Fatal(err)
+	}
+
+	return metric.GetHistogram().GetSampleCount()
+}
@@ -0,0 +1,122 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package metrics
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "kubefirst_api"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled by the API, by method, route and status code.",
+	}, []string{"method", "route", "code"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to handle HTTP requests, by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	stepDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "provisioning_step_duration_seconds",
+		Help:      "Time taken by cluster provisioning steps, by cloud provider, step and outcome.",
+		Buckets:   []float64{1, 5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600, 7200},
+	}, []string{"provider", "step", "outcome"})
+
+	stepFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provisioning_step_failures_total",
+		Help:      "Cluster provisioning steps that failed, by cloud provider and step.",
+	}, []string{"provider", "step"})
+
+	activeJobs = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "jobs_active",
+		Help:      "Jobs running in this process, by type.",
+	}, []string{"type"})
+
+	externalRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "external_request_duration_seconds",
+		Help:      "Time taken by calls to cloud and git provider APIs, by service and outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service", "outcome"})
+
+	auditEntriesDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "audit_entries_dropped_total",
+		Help:      "Audit entries that were not persisted or not forwarded, by reason.",
+	}, []string{"reason"})
+
+	gitopsCatalogOnce    sync.Once
+	gitopsCatalogMu      sync.Mutex
+	gitopsCatalogUpdated time.Time
+)
+
+// ObserveRequest records an HTTP request handled by the API. route is the
+// route pattern rather than the path, to keep the number of series bounded.
+func ObserveRequest(method, route string, code int, duration time.Duration) {
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(code)).Inc()
+	httpRequestDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// AuditEntryDropped records an audit entry that was not persisted or not
+// forwarded for reason
+func AuditEntryDropped(reason string) {
+	auditEntriesDropped.WithLabelValues(reason).Inc()
+}
+
+// ObserveStep records a provisioning step of a cluster on provider that ended
+// with outcome, one of the step statuses
+func ObserveStep(provider, step, outcome string, duration time.Duration, failed bool) {
+	stepDuration.WithLabelValues(provider, step, outcome).Observe(duration.Seconds())
+	if failed {
+		stepFailures.WithLabelValues(provider, step).Inc()
+	}
+}
+
+// JobStarted counts a job of jobType as active until JobFinished is called
+func JobStarted(jobType string) {
+	activeJobs.WithLabelValues(jobType).Inc()
+}
+
+// JobFinished stops counting a job of jobType as active
+func JobFinished(jobType string) {
+	activeJobs.WithLabelValues(jobType).Dec()
+}
+
+// GitopsCatalogUpdated records that the gitops catalog cache was refreshed at
+// updated. The age of the cache is only exported once it has been refreshed.
+func GitopsCatalogUpdated(updated time.Time) {
+	gitopsCatalogMu.Lock()
+	gitopsCatalogUpdated = updated
+	gitopsCatalogMu.Unlock()
+
+	gitopsCatalogOnce.Do(func() {
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "gitops_catalog_age_seconds",
+			Help:      "Time since the gitops catalog cache was last refreshed.",
+		}, func() float64 {
+			gitopsCatalogMu.Lock()
+			defer gitopsCatalogMu.Unlock()
+
+			return time.Since(gitopsCatalogUpdated).Seconds()
+		})
+	})
+}
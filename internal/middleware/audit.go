@@ -0,0 +1,82 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/konstructio/kubefirst-api/internal/audit"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+)
+
+// anonymousActor is recorded for calls that were not authenticated
+const anonymousActor = "anonymous"
+
+// Audit records every POST, PUT, PATCH and DELETE call with its caller,
+// target, redacted request body and outcome. The bodies of calls to the
+// secret routes are recorded with every value redacted.
+func Audit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			c.Next()
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			body, _ = io.ReadAll(c.Request.Body)
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		now := time.Now()
+		c.Next()
+
+		audit.Record(auditEntry(c, now, body))
+	}
+}
+
+// auditEntry returns the audit entry of a call that has been handled
+func auditEntry(c *gin.Context, now time.Time, body []byte) pkgtypes.AuditEntry {
+	route := c.FullPath()
+	if route == "" {
+		route = c.Request.URL.Path
+	}
+
+	entry := audit.NewEntry(now)
+	entry.Actor = anonymousActor
+	entry.Action = fmt.Sprintf("%s %s", c.Request.Method, route)
+	entry.Method = c.Request.Method
+	entry.Path = c.Request.URL.Path
+	// incoming webhook URLs of notification targets carry their token
+	redactAll := strings.HasPrefix(route, "/api/v1/secret/") || strings.HasPrefix(route, "/api/v1/notifications/")
+	entry.Request = audit.RedactRequest(body, redactAll)
+	entry.Status = c.Writer.Status()
+	entry.Outcome = audit.Outcome(entry.Status)
+	entry.ClientIP = c.ClientIP()
+
+	if user, err := GetAuthorizedUser(c); err == nil {
+		entry.Actor = user.Name
+		entry.ActorKind = user.Kind
+	}
+
+	if len(c.Params) > 0 {
+		entry.Target = map[string]string{}
+		for _, param := range c.Params {
+			entry.Target[param.Key] = param.Value
+		}
+	}
+
+	return entry
+}
@@ -7,17 +7,32 @@ See the LICENSE file for more details.
 package middleware
 
 import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/konstructio/kubefirst-api/internal/apikeys"
 	"github.com/konstructio/kubefirst-api/internal/constants"
 	"github.com/konstructio/kubefirst-api/internal/env"
+	"github.com/konstructio/kubefirst-api/internal/secrets"
+	"github.com/konstructio/kubefirst-api/internal/utils"
 	"github.com/rs/zerolog/log"
 )
 
-// ValidateAPIKey determines whether or not a request is authenticated with a valid API key
-func ValidateAPIKey() gin.HandlerFunc {
+// accessTokenUser is the name given to requests authenticated with K1_ACCESS_TOKEN
+const accessTokenUser = "k1-access-token"
+
+// ValidateAPIKey determines whether or not a request is authenticated with a
+// valid API key or OIDC token that has been granted scope. K1_ACCESS_TOKEN is
+// granted every scope. Named API keys are read on every request, so a rotated
+// or deleted key stops working right away.
+func ValidateAPIKey(scope string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		APIKey := strings.TrimPrefix(c.Request.Header.Get("Authorization"), "Bearer ")
 
@@ -29,14 +44,61 @@ func ValidateAPIKey() gin.HandlerFunc {
 			return
 		}
 
-		env, _ := env.GetEnv(constants.SilenceGetEnv)
+		user, err := authenticate(c.Request.Context(), APIKey)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"status": 401, "message": fmt.Sprintf("Authentication failed - %s", err)})
+			c.Abort()
+
+			log.Info().Msgf(" Request Status: 401;  Authentication failed - %s", err)
+			return
+		}
+
+		if !limitCredential(c, user.Kind+":"+user.Name) {
+			return
+		}
 
-		if APIKey != env.K1AccessToken {
-			c.JSON(http.StatusUnauthorized, gin.H{"status": 401, "message": "Authentication failed - not a valid API key"})
+		if !slices.Contains(user.Scopes, scope) {
+			c.JSON(http.StatusForbidden, gin.H{"status": 403, "message": fmt.Sprintf("API key %q is missing the %q scope", user.Name, scope)})
 			c.Abort()
 
-			log.Info().Msg(" Request Status: 401;  Authentication failed - no API key provided in request")
+			log.Info().Msgf(" Request Status: 403;  API key %q is missing the %q scope", user.Name, scope)
 			return
 		}
+
+		c.Set(AuthorizedUserKey, user)
+	}
+}
+
+// authenticate returns the user of an API key or, when an issuer is
+// configured, of an OIDC token
+func authenticate(ctx context.Context, key string) (*AuthorizedUser, error) {
+	env, _ := env.GetEnv(constants.SilenceGetEnv)
+
+	if env.K1AccessToken != "" && subtle.ConstantTimeCompare([]byte(key), []byte(env.K1AccessToken)) == 1 {
+		return &AuthorizedUser{Name: accessTokenUser, Kind: AuthKindAPIKey, Scopes: apikeys.Scopes}, nil
+	}
+
+	if v := oidcVerifier(env); v != nil && !apikeys.IsAPIKey(key) {
+		return authenticateOIDC(ctx, v, env, key)
+	}
+
+	name, secret, err := apikeys.Parse(key)
+	if err != nil {
+		return nil, err
+	}
+
+	kcfg := utils.GetKubernetesClient("")
+	apiKey, err := secrets.GetAPIKey(kcfg.Clientset, name)
+	if err != nil {
+		if errors.Is(err, &secrets.APIKeyNotFoundError{}) {
+			return nil, apikeys.ErrInvalid
+		}
+		return nil, fmt.Errorf("unable to look up api key: %w", err)
 	}
+
+	if err := apikeys.Verify(apiKey, secret, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return &AuthorizedUser{Name: apiKey.Name, Kind: AuthKindAPIKey, Scopes: apiKey.Scopes}, nil
 }
@@ -0,0 +1,146 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/konstructio/kubefirst-api/internal/apikeys"
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/env"
+	"github.com/konstructio/kubefirst-api/internal/oidc"
+	"github.com/rs/zerolog/log"
+)
+
+// roleScopes lists the scopes granted by each role
+var roleScopes = map[string][]string{
+	constants.RoleAdmin: apikeys.Scopes,
+	constants.RoleEditor: {
+		constants.ScopeClustersRead,
+		constants.ScopeClustersWrite,
+		constants.ScopeServicesRead,
+		constants.ScopeServicesWrite,
+	},
+	constants.RoleViewer: {
+		constants.ScopeClustersRead,
+		constants.ScopeServicesRead,
+	},
+}
+
+var (
+	verifierOnce sync.Once
+	verifier     *oidc.Verifier
+)
+
+// oidcVerifier returns the verifier for the configured issuer, or nil when
+// OIDC authentication is not enabled
+func oidcVerifier(env env.Env) *oidc.Verifier {
+	if env.OIDCIssuer == "" {
+		return nil
+	}
+
+	verifierOnce.Do(func() {
+		if env.OIDCAudience == "" {
+			log.Error().Msg("K1_OIDC_AUDIENCE is not set, every OIDC token is rejected")
+		}
+		verifier = oidc.NewVerifier(env.OIDCIssuer, env.OIDCAudience, env.OIDCJWKSURL, env.OIDCGroupsClaim)
+	})
+
+	return verifier
+}
+
+// authenticateOIDC returns the user of an OIDC token, with the roles mapped
+// from its groups
+func authenticateOIDC(ctx context.Context, v *oidc.Verifier, env env.Env, token string) (*AuthorizedUser, error) {
+	identity, err := v.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, scopes := rolesForGroups(identity.Groups, env.OIDCGroupRoles, env.OIDCDefaultRole)
+	if len(roles) == 0 {
+		return nil, fmt.Errorf("none of the groups of %s are mapped to a role", identity.Name)
+	}
+
+	return &AuthorizedUser{
+		Name:        identity.Subject,
+		DisplayName: identity.Name,
+		Kind:        AuthKindOIDC,
+		Groups:      identity.Groups,
+		Roles:       roles,
+		Scopes:      scopes,
+	}, nil
+}
+
+// rolesForGroups maps groups to roles through groupRoles and returns the
+// roles along with the scopes they grant. defaultRole is granted to every
+// user when it is set.
+func rolesForGroups(groups []string, groupRoles map[string]string, defaultRole string) ([]string, []string) {
+	roles := []string{}
+	if defaultRole != "" {
+		roles = append(roles, defaultRole)
+	}
+	for _, group := range groups {
+		if role, ok := groupRoles[group]; ok && !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+
+	scopes := []string{}
+	known := []string{}
+	for _, role := range roles {
+		granted, ok := roleScopes[role]
+		if !ok {
+			continue
+		}
+		known = append(known, role)
+		for _, scope := range granted {
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+
+	return known, scopes
+}
+
+// GetAuthorizedUser returns the caller of a request authenticated by ValidateAPIKey
+func GetAuthorizedUser(c *gin.Context) (*AuthorizedUser, error) {
+	value, ok := c.Get(AuthorizedUserKey)
+	if !ok {
+		return nil, errors.New("request is not authenticated")
+	}
+
+	user, ok := value.(*AuthorizedUser)
+	if !ok {
+		return nil, errors.New("request is not authenticated")
+	}
+
+	return user, nil
+}
+
+// RequestUser returns the user a change is made on behalf of. Requests
+// authenticated with an OIDC token are attributed to the username of the
+// token subject, and only API key callers such as the console can name the
+// user themselves.
+func RequestUser(c *gin.Context, requested string) string {
+	user, err := GetAuthorizedUser(c)
+	if err != nil || user.Kind != AuthKindOIDC {
+		return requested
+	}
+
+	if user.DisplayName != "" {
+		return user.DisplayName
+	}
+
+	return user.Name
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/konstructio/kubefirst-api/internal/constants"
+)
+
+func TestRolesForGroups(t *testing.T) {
+	groupRoles := map[string]string{
+		"admins":     constants.RoleAdmin,
+		"developers": constants.RoleEditor,
+		"auditors":   "auditor",
+	}
+
+	tests := []struct {
+		name           string
+		groups         []string
+		defaultRole    string
+		expectedRoles  []string
+		expectedScopes []string
+	}{
+		{
+			name:           "mapped group",
+			groups:         []string{"developers", "everyone"},
+			expectedRoles:  []string{constants.RoleEditor},
+			expectedScopes: roleScopes[constants.RoleEditor],
+		},
+		{
+			name:           "default role",
+			groups:         []string{"everyone"},
+			defaultRole:    constants.RoleViewer,
+			expectedRoles:  []string{constants.RoleViewer},
+			expectedScopes: roleScopes[constants.RoleViewer],
+		},
+		{
+			name:           "scopes are merged",
+			groups:         []string{"developers"},
+			defaultRole:    constants.RoleViewer,
+			expectedRoles:  []string{constants.RoleViewer, constants.RoleEditor},
+			expectedScopes: []string{constants.ScopeClustersRead, constants.ScopeServicesRead, constants.ScopeClustersWrite, constants.ScopeServicesWrite},
+		},
+		{
+			name:           "unknown roles are dropped",
+			groups:         []string{"auditors"},
+			expectedRoles:  []string{},
+			expectedScopes: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roles, scopes := rolesForGroups(tt.groups, groupRoles, tt.defaultRole)
+			if !slices.Equal(roles, tt.expectedRoles) {
+				t.Errorf("expected roles %v, got %v", tt.expectedRoles, roles)
+			}
+			if !slices.Equal(scopes, tt.expectedScopes) {
+				t.Errorf("expected scopes %v, got %v", tt.expectedScopes, scopes)
+			}
+		})
+	}
+}
+
+func TestRequestUser(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     *AuthorizedUser
+		expected string
+	}{
+		{name: "oidc token", user: &AuthorizedUser{Name: "4f1c2a", DisplayName: "jane", Kind: AuthKindOIDC}, expected: "jane"},
+		{name: "oidc token without username", user: &AuthorizedUser{Name: "4f1c2a", Kind: AuthKindOIDC}, expected: "4f1c2a"},
+		{name: "api key", user: &AuthorizedUser{Name: "k1-access-token", Kind: AuthKindAPIKey}, expected: "kbot"},
+		{name: "unauthenticated", expected: "kbot"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			if tt.user != nil {
+				c.Set(AuthorizedUserKey, tt.user)
+			}
+
+			if user := RequestUser(c, "kbot"); user != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, user)
+			}
+		})
+	}
+}
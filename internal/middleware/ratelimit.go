@@ -0,0 +1,253 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/env"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/time/rate"
+)
+
+// rateLimitIdle is how long a client is remembered after its last request
+const rateLimitIdle = 10 * time.Minute
+
+// rateLimitExempt are the routes that are never rate limited, so liveness
+// probes keep working under load
+var rateLimitExempt = []string{"/api/v1/health"}
+
+// rateLimiter holds a token bucket per client
+type rateLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu        sync.Mutex
+	clients   map[string]*rateLimitClient
+	lastSweep time.Time
+}
+
+type rateLimitClient struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newRateLimiter(limit rate.Limit, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimiter{
+		limit:   limit,
+		burst:   burst,
+		clients: map[string]*rateLimitClient{},
+	}
+}
+
+// delay takes a token from the bucket of client and returns zero, or returns
+// how long the client has to wait for a token when its bucket is empty
+func (l *rateLimiter) delay(client string, now time.Time) time.Duration {
+	delay, _ := l.reserve(client, now)
+	return delay
+}
+
+// reserve is delay, also returning the reservation of the token taken so it
+// can be given back when the request is rejected later on
+func (l *rateLimiter) reserve(client string, now time.Time) (time.Duration, *rate.Reservation) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > rateLimitIdle {
+		for key, cl := range l.clients {
+			if now.Sub(cl.lastSeen) > rateLimitIdle {
+				delete(l.clients, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	cl, ok := l.clients[client]
+	if !ok {
+		cl = &rateLimitClient{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[client] = cl
+	}
+	cl.lastSeen = now
+
+	reservation := cl.limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return rateLimitIdle, nil
+	}
+
+	delay := reservation.DelayFrom(now)
+	if delay > 0 {
+		reservation.CancelAt(now)
+		return delay, nil
+	}
+
+	return 0, reservation
+}
+
+// rateLimitsKey holds the rate limits a request went through, whose
+// credential buckets are charged once the request is authenticated
+const rateLimitsKey = "rateLimits"
+
+// pendingRateLimit is a rate limit whose client IP bucket has been charged
+// for a request
+type pendingRateLimit struct {
+	limiter *rateLimiter
+	ip      *rate.Reservation
+	at      time.Time
+}
+
+// RateLimit limits the requests of each client IP and of each authenticated
+// API key or user to K1_RATE_LIMIT_RPS per second, allowing bursts of
+// K1_RATE_LIMIT_BURST. The client IP is only read from X-Forwarded-For when
+// the request comes from one of K1_TRUSTED_PROXIES. Rate limiting is disabled
+// when K1_RATE_LIMIT_RPS is 0.
+func RateLimit() gin.HandlerFunc {
+	env, _ := env.GetEnv(constants.SilenceGetEnv)
+
+	return rateLimit(env.RateLimitRPS, env.RateLimitBurst)
+}
+
+// StrictRateLimit limits the requests of each client IP and of each
+// authenticated API key or user to K1_RATE_LIMIT_STRICT_RPM per minute, allowing bursts of
+// K1_RATE_LIMIT_STRICT_BURST. It is used on top of RateLimit by the routes
+// creating and deleting resources, and shares its buckets between every
+// route it is used on.
+func StrictRateLimit() gin.HandlerFunc {
+	env, _ := env.GetEnv(constants.SilenceGetEnv)
+
+	return rateLimit(env.RateLimitStrictRPM/60, env.RateLimitStrictBurst)
+}
+
+func rateLimit(perSecond float64, burst int) gin.HandlerFunc {
+	if perSecond <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	limiter := newRateLimiter(rate.Limit(perSecond), burst)
+
+	return func(c *gin.Context) {
+		for _, route := range rateLimitExempt {
+			if c.FullPath() == route {
+				c.Next()
+				return
+			}
+		}
+
+		now := time.Now()
+		wait, reservation := limiter.reserve("ip:"+c.ClientIP(), now)
+		if wait > 0 {
+			tooManyRequests(c, wait)
+			return
+		}
+
+		pending, _ := c.Get(rateLimitsKey)
+		limits, _ := pending.([]pendingRateLimit)
+		c.Set(rateLimitsKey, append(limits, pendingRateLimit{limiter: limiter, ip: reservation, at: now}))
+
+		c.Next()
+	}
+}
+
+// limitCredential charges the credential buckets of the rate limits a request
+// went through once the request is authenticated as identity, so unverified
+// credentials never get a bucket of their own. A request rejected by a
+// credential bucket gets its client IP tokens back, along with the tokens
+// already taken from its other credential buckets. It reports whether the
+// request may go on.
+func limitCredential(c *gin.Context, identity string) bool {
+	pending, _ := c.Get(rateLimitsKey)
+	limits, _ := pending.([]pendingRateLimit)
+
+	now := time.Now()
+	taken := []*rate.Reservation{}
+	for _, limit := range limits {
+		wait, reservation := limit.limiter.reserve("key:"+identity, now)
+		if wait == 0 {
+			taken = append(taken, reservation)
+			continue
+		}
+
+		// a reservation only gives its token back when cancelled as of
+		// the time it was made
+		for _, reservation := range taken {
+			reservation.CancelAt(now)
+		}
+		for _, limit := range limits {
+			if limit.ip != nil {
+				limit.ip.CancelAt(limit.at)
+			}
+		}
+		tooManyRequests(c, wait)
+		return false
+	}
+
+	return true
+}
+
+// tooManyRequests rejects a request that has to wait before it is served
+func tooManyRequests(c *gin.Context, wait time.Duration) {
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"status": http.StatusTooManyRequests, "message": fmt.Sprintf("rate limit exceeded, retry in %d seconds", retryAfter)})
+
+	log.Info().Msgf(" Request Status: 429;  rate limit exceeded by %s on %s %s", c.ClientIP(), c.Request.Method, c.Request.URL.Path)
+}
+
+// MaxBodySize rejects requests with a body larger than
+// K1_MAX_REQUEST_BODY_BYTES with 413. The limit is disabled when set to 0.
+func MaxBodySize() gin.HandlerFunc {
+	env, _ := env.GetEnv(constants.SilenceGetEnv)
+
+	return maxBodySize(env.MaxRequestBodyBytes)
+}
+
+func maxBodySize(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limit <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		tooLarge := func() {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"status": http.StatusRequestEntityTooLarge, "message": fmt.Sprintf("request body is larger than %d bytes", limit)})
+
+			log.Info().Msgf(" Request Status: 413;  request body larger than %d bytes on %s %s", limit, c.Request.Method, c.Request.URL.Path)
+		}
+
+		if c.Request.ContentLength > limit {
+			tooLarge()
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, limit+1))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("error reading request body: %s", err)})
+			return
+		}
+		if int64(len(body)) > limit {
+			tooLarge()
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Next()
+	}
+}
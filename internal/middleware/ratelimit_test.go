@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimiterDelay(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// one request per second, in bursts of two
+	limiter := newRateLimiter(rate.Limit(1), 2)
+
+	tests := []struct {
+		name     string
+		client   string
+		at       time.Duration
+		expected time.Duration
+	}{
+		{name: "first request of burst", client: "ip:10.0.0.1", expected: 0},
+		{name: "second request of burst", client: "ip:10.0.0.1", expected: 0},
+		{name: "bucket empty", client: "ip:10.0.0.1", expected: time.Second},
+		{name: "rejected request takes no token", client: "ip:10.0.0.1", at: 500 * time.Millisecond, expected: 500 * time.Millisecond},
+		{name: "other client has its own bucket", client: "ip:10.0.0.2", at: 500 * time.Millisecond, expected: 0},
+		{name: "bucket refilled", client: "ip:10.0.0.1", at: time.Second, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay := limiter.delay(tt.client, now.Add(tt.at))
+			if delay != tt.expected {
+				t.Errorf("expected delay %s, got %s", tt.expected, delay)
+			}
+		})
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		body     string
+		chunked  bool
+		expected int
+	}{
+		{name: "within limit", body: `{"a":1}`, expected: http.StatusOK},
+		{name: "at limit", body: strings.Repeat("a", 16), expected: http.StatusOK},
+		{name: "content length over limit", body: strings.Repeat("a", 17), expected: http.StatusRequestEntityTooLarge},
+		{name: "chunked body over limit", body: strings.Repeat("a", 17), chunked: true, expected: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.POST("/", maxBodySize(16), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("expected status %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestRateLimitCredential(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	type request struct {
+		method   string
+		ip       string
+		user     string
+		expected int
+	}
+
+	tests := []struct {
+		name     string
+		requests []request
+	}{
+		{
+			name: "unauthenticated requests use the client IP bucket",
+			requests: []request{
+				{ip: "10.0.0.1", expected: http.StatusOK},
+				{ip: "10.0.0.1", expected: http.StatusOK},
+				{ip: "10.0.0.1", expected: http.StatusTooManyRequests},
+			},
+		},
+		{
+			name: "credential bucket is shared across client IPs",
+			requests: []request{
+				{ip: "10.0.0.1", user: "alice", expected: http.StatusOK},
+				{ip: "10.0.0.2", user: "alice", expected: http.StatusOK},
+				{ip: "10.0.0.3", user: "alice", expected: http.StatusTooManyRequests},
+				{ip: "10.0.0.3", user: "bob", expected: http.StatusOK},
+			},
+		},
+		{
+			name: "rejected credential gives the client IP token back",
+			requests: []request{
+				{ip: "10.0.0.1", user: "alice", expected: http.StatusOK},
+				{ip: "10.0.0.1", user: "alice", expected: http.StatusOK},
+				{ip: "10.0.0.2", user: "alice", expected: http.StatusTooManyRequests},
+				{ip: "10.0.0.2", expected: http.StatusOK},
+				{ip: "10.0.0.2", expected: http.StatusOK},
+			},
+		},
+		{
+			name: "rejected strict credential gives the general credential token back",
+			requests: []request{
+				{method: http.MethodPost, ip: "10.0.0.1", user: "alice", expected: http.StatusOK},
+				{method: http.MethodPost, ip: "10.0.0.2", user: "alice", expected: http.StatusTooManyRequests},
+				{ip: "10.0.0.3", user: "alice", expected: http.StatusOK},
+				{ip: "10.0.0.4", user: "alice", expected: http.StatusTooManyRequests},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// buckets of two requests, and of one for the strict limit on
+			// POST, refilled far slower than the test runs
+			handler := func(c *gin.Context) {
+				if user := c.GetHeader("X-User"); user != "" && !limitCredential(c, user) {
+					return
+				}
+				c.Status(http.StatusOK)
+			}
+			general := rateLimit(0.001, 2)
+			r := gin.New()
+			r.GET("/", general, handler)
+			r.POST("/", general, rateLimit(0.001, 1), handler)
+
+			for i, request := range tt.requests {
+				method := request.method
+				if method == "" {
+					method = http.MethodGet
+				}
+				req := httptest.NewRequest(method, "/", nil)
+				req.RemoteAddr = request.ip + ":1234"
+				req.Header.Set("X-User", request.user)
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+
+				if w.Code != request.expected {
+					t.Errorf("request %d: expected status %d, got %d", i, request.expected, w.Code)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package middleware
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/rbac"
+	"github.com/konstructio/kubefirst-api/internal/secrets"
+	"github.com/konstructio/kubefirst-api/internal/utils"
+)
+
+// Authorize returns rbac.ErrForbidden unless a role binding grants the caller
+// of a request action on target. Callers that can manage role bindings are
+// not restricted, and every other caller is denied until a binding grants
+// the action, so removing a binding never widens access.
+func Authorize(c *gin.Context, action string, target rbac.Target) error {
+	user, err := GetAuthorizedUser(c)
+	if err != nil {
+		return fmt.Errorf("%w: %s", rbac.ErrForbidden, err)
+	}
+
+	if slices.Contains(user.Scopes, constants.ScopeRoleBindingsAdmin) {
+		return nil
+	}
+
+	kcfg := utils.GetKubernetesClient("")
+	bindings, err := secrets.GetRoleBindings(kcfg.Clientset)
+	if err != nil {
+		return fmt.Errorf("unable to look up role bindings: %w", err)
+	}
+
+	return rbac.Authorize(bindings, user.Subject(), action, target)
+}
+
+// Subject returns the role binding subject of the user
+func (u *AuthorizedUser) Subject() rbac.Subject {
+	if u.Kind == AuthKindOIDC {
+		return rbac.Subject{Kind: rbac.SubjectUser, Name: u.Name, Groups: u.Groups}
+	}
+
+	return rbac.Subject{Kind: rbac.SubjectAPIKey, Name: u.Name}
+}
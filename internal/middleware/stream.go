@@ -0,0 +1,193 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/env"
+	"github.com/rs/zerolog/log"
+)
+
+// StreamTokenTTL is how long a stream token can be used to open a stream
+const StreamTokenTTL = 5 * time.Minute
+
+var (
+	streamKeyOnce sync.Once
+	streamKey     []byte
+
+	errInvalidStreamToken = errors.New("not a valid stream token")
+	errExpiredStreamToken = errors.New("stream token has expired")
+)
+
+// streamClaims is the signed payload of a stream token. User and Kind name the
+// caller the token was issued to, so a stream is attributed to them.
+type streamClaims struct {
+	FileName  string `json:"file_name"`
+	User      string `json:"sub"`
+	Kind      string `json:"kind"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// ValidateStreamToken authenticates a log stream with the token query
+// parameter, since browser EventSource clients cannot set an Authorization
+// header. Requests without a token fall back to ValidateAPIKey with scope.
+func ValidateStreamToken(scope string) gin.HandlerFunc {
+	return validateStreamToken(scope, func(c *gin.Context) string {
+		return c.Param("file_name")
+	})
+}
+
+// ValidateEventsToken authenticates an event stream with the token query
+// parameter, since browser EventSource and WebSocket clients cannot set an
+// Authorization header. Requests without a token fall back to ValidateAPIKey
+// with scope.
+func ValidateEventsToken(scope string) gin.HandlerFunc {
+	return validateStreamToken(scope, func(c *gin.Context) string {
+		return eventsSubject(c.Query("cluster"))
+	})
+}
+
+// NewEventsToken returns a token issued to user that opens the event stream
+// of cluster, or of every cluster when cluster is empty, until it expires
+func NewEventsToken(cluster string, user *AuthorizedUser) (string, time.Time, error) {
+	return NewStreamToken(eventsSubject(cluster), user)
+}
+
+// eventsSubject is what an events token is signed for. Log file names cannot
+// contain a slash, so a log stream token never opens an event stream.
+func eventsSubject(cluster string) string {
+	return "events/" + cluster
+}
+
+func validateStreamToken(scope string, subject func(c *gin.Context) string) gin.HandlerFunc {
+	validateAPIKey := ValidateAPIKey(scope)
+
+	return func(c *gin.Context) {
+		token := c.Query("token")
+		if token == "" {
+			validateAPIKey(c)
+			return
+		}
+
+		claims, err := verifyStreamToken(streamTokenKey(), token, subject(c), time.Now())
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"status": 401, "message": fmt.Sprintf("Authentication failed - %s", err)})
+			c.Abort()
+
+			log.Info().Msgf(" Request Status: 401;  Authentication failed - %s", err)
+			return
+		}
+
+		if !limitCredential(c, "stream:"+subject(c)) {
+			return
+		}
+
+		// the token only opens the stream it was signed for, so it grants no
+		// scope beyond the one guarding that stream
+		c.Set(AuthorizedUserKey, &AuthorizedUser{Name: claims.User, Kind: claims.Kind, Scopes: []string{scope}})
+	}
+}
+
+// NewStreamToken returns a token issued to user that opens the log stream of
+// fileName until it expires
+func NewStreamToken(fileName string, user *AuthorizedUser) (string, time.Time, error) {
+	expiresAt := time.Now().Add(StreamTokenTTL)
+
+	token, err := signStreamToken(streamTokenKey(), streamClaims{
+		FileName:  fileName,
+		User:      user.Name,
+		Kind:      user.Kind,
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// streamTokenKey returns the key stream tokens are signed with. Without
+// K1_STREAM_TOKEN_KEY a random key is generated, so tokens do not outlive the
+// process that issued them and are rejected by every other replica.
+func streamTokenKey() []byte {
+	streamKeyOnce.Do(func() {
+		env, _ := env.GetEnv(constants.SilenceGetEnv)
+		if env.StreamTokenKey != "" {
+			streamKey = []byte(env.StreamTokenKey)
+			return
+		}
+
+		log.Warn().Msg("K1_STREAM_TOKEN_KEY is not set, signing stream tokens with a random key: tokens will fail on other replicas and after a restart, set K1_STREAM_TOKEN_KEY when running more than one replica")
+
+		streamKey = make([]byte, 32)
+		if _, err := rand.Read(streamKey); err != nil {
+			log.Fatal().Msgf("error generating stream token key: %s", err)
+		}
+	})
+
+	return streamKey
+}
+
+func signStreamToken(key []byte, claims streamClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling stream token: %w", err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(streamSignature(key, encoded)), nil
+}
+
+func verifyStreamToken(key []byte, token, fileName string, now time.Time) (*streamClaims, error) {
+	encoded, signature, found := strings.Cut(token, ".")
+	if !found {
+		return nil, errInvalidStreamToken
+	}
+
+	decodedSignature, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(decodedSignature, streamSignature(key, encoded)) {
+		return nil, errInvalidStreamToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errInvalidStreamToken
+	}
+
+	var claims streamClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errInvalidStreamToken
+	}
+
+	if claims.FileName != fileName {
+		return nil, errInvalidStreamToken
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, errExpiredStreamToken
+	}
+
+	return &claims, nil
+}
+
+func streamSignature(key []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerifyStreamToken(t *testing.T) {
+	key := []byte("stream-token-key")
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	issued := streamClaims{FileName: "log_1700000000.log", User: "ci", Kind: AuthKindAPIKey, ExpiresAt: now.Add(StreamTokenTTL).Unix()}
+	token, err := signStreamToken(key, issued)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		key      []byte
+		token    string
+		fileName string
+		now      time.Time
+		expected error
+	}{
+		{name: "valid", key: key, token: token, fileName: "log_1700000000.log", now: now},
+		{name: "other file", key: key, token: token, fileName: "log_1800000000.log", now: now, expected: errInvalidStreamToken},
+		{name: "other key", key: []byte("other-key"), token: token, fileName: "log_1700000000.log", now: now, expected: errInvalidStreamToken},
+		{name: "tampered", key: key, token: "e30" + token[3:], fileName: "log_1700000000.log", now: now, expected: errInvalidStreamToken},
+		{name: "malformed", key: key, token: "not-a-token", fileName: "log_1700000000.log", now: now, expected: errInvalidStreamToken},
+		{name: "expired", key: key, token: token, fileName: "log_1700000000.log", now: now.Add(StreamTokenTTL), expected: errExpiredStreamToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := verifyStreamToken(tt.key, tt.token, tt.fileName, tt.now)
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, err)
+			}
+			if err == nil && *claims != issued {
+				t.Errorf("expected claims %+v, got %+v", issued, *claims)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/konstructio/kubefirst-api/internal/tracing"
+)
+
+// Tracing records a span for each request, continuing the trace of the caller
+// when the request carries a traceparent header. Handlers that start
+// background work pass the request context on so that the work joins the
+// trace.
+func Tracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		req, done := tracing.ServerRequest(c.Request, route)
+		c.Request = req
+		c.Next()
+
+		done(c.Writer.Status())
+	}
+}
@@ -6,7 +6,24 @@ See the LICENSE file for more details.
 */
 package middleware
 
+// AuthorizedUserKey is the gin context key holding the AuthorizedUser of a request
+const AuthorizedUserKey = "authorized_user"
+
+// Kinds of credentials a request can be authenticated with
+const (
+	AuthKindAPIKey = "apikey"
+	AuthKindOIDC   = "oidc"
+)
+
+// AuthorizedUser is the caller a request was authenticated as, either an API
+// key or the subject of an OIDC token. The Name of an OIDC user is the sub
+// claim of its token, which the issuer never reassigns, and DisplayName its
+// username.
 type AuthorizedUser struct {
-	Name   string `bson:"name" json:"name"`
-	APIKey string `bson:"api_key" json:"api_key"`
+	Name        string   `bson:"name" json:"name"`
+	DisplayName string   `bson:"display_name,omitempty" json:"display_name,omitempty"`
+	Kind        string   `bson:"kind" json:"kind"`
+	Groups      []string `bson:"groups,omitempty" json:"groups,omitempty"`
+	Roles       []string `bson:"roles,omitempty" json:"roles,omitempty"`
+	Scopes      []string `bson:"scopes" json:"scopes"`
 }
@@ -0,0 +1,308 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package notifications
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/metrics"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+	log "github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// PagerDutyEventsURL receives the events of pagerduty targets without a
+	// url
+	PagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+	// Headers sent with every notification
+	EventHeader     = "X-Kubefirst-Event"
+	DeliveryHeader  = "X-Kubefirst-Delivery"
+	TimestampHeader = "X-Kubefirst-Timestamp"
+	// SignatureHeader carries the signature of payloads sent to targets with
+	// a signing secret
+	SignatureHeader = "X-Kubefirst-Signature"
+)
+
+var (
+	// client only connects to public addresses over https, so targets cannot
+	// be used to reach the API's own network, such as cloud metadata or
+	// in-cluster services
+	client = &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: metrics.Transport("notifications", &http.Transport{
+			DialContext:         (&net.Dialer{Timeout: 10 * time.Second, Control: dialControl}).DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		}),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Scheme != "https" {
+				return fmt.Errorf("refusing redirect to %s", req.URL.Redacted())
+			}
+			if len(via) >= 5 {
+				return errors.New("stopped after 5 redirects")
+			}
+			return nil
+		},
+	}
+
+	// internalNetworks are the addresses targets may not connect to, next to
+	// loopback, link-local, private and unspecified addresses
+	internalNetworks = []netip.Prefix{
+		netip.MustParsePrefix("100.64.0.0/10"),
+		netip.MustParsePrefix("192.0.0.0/24"),
+		netip.MustParsePrefix("198.18.0.0/15"),
+	}
+
+	// retryDelay is the wait before the second attempt of a delivery, doubled
+	// after every further attempt up to maxRetryDelay
+	retryDelay    = 2 * time.Second
+	maxRetryDelay = time.Minute
+)
+
+// Sign returns the signature of a payload sent at timestamp, the hex encoded
+// HMAC-SHA256 of the timestamp, a dot and the payload, keyed with the signing
+// secret of the target
+func Sign(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliver sends a notification to a target, making up to attempts attempts
+// while the target cannot be reached, responds 429 or fails with a 5xx
+func Deliver(target pkgtypes.NotificationTarget, notification pkgtypes.Notification, attempts int) pkgtypes.NotificationDelivery {
+	delivery := pkgtypes.NotificationDelivery{
+		ID:             primitive.NewObjectID().Hex(),
+		Target:         target.Name,
+		NotificationID: notification.ID,
+		Event:          notification.Event,
+		Cluster:        notification.Cluster,
+		Outcome:        constants.NotificationOutcomeFailure,
+	}
+
+	payload, err := Payload(target, notification)
+	if err != nil {
+		delivery.Error = err.Error()
+		delivery.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
+		return delivery
+	}
+
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	delay := retryDelay
+	for delivery.Attempts < attempts {
+		if delivery.Attempts > 0 {
+			time.Sleep(delay)
+			delay = min(delay*2, maxRetryDelay)
+		}
+		delivery.Attempts++
+
+		status, err := send(target, notification, payload)
+		delivery.StatusCode = status
+		if err == nil {
+			delivery.Outcome = constants.NotificationOutcomeSuccess
+			delivery.Error = ""
+			break
+		}
+		delivery.Error = err.Error()
+
+		if status != 0 && status != http.StatusTooManyRequests && status < http.StatusInternalServerError {
+			break
+		}
+		log.Warn().Msgf("attempt %d of %d to deliver %s to notification target %s failed: %s", delivery.Attempts, attempts, notification.Event, target.Name, err)
+	}
+
+	delivery.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
+	if delivery.Outcome == constants.NotificationOutcomeSuccess {
+		log.Info().Msgf("delivered %s of cluster %s to notification target %s", notification.Event, notification.Cluster, target.Name)
+	} else {
+		log.Error().Msgf("error delivering %s of cluster %s to notification target %s: %s", notification.Event, notification.Cluster, target.Name, delivery.Error)
+	}
+
+	return delivery
+}
+
+// send posts a payload to a target once, returning the response status
+func send(target pkgtypes.NotificationTarget, notification pkgtypes.Notification, payload []byte) (int, error) {
+	address := target.URL
+	if address == "" && target.Type == TypePagerDuty {
+		address = PagerDutyEventsURL
+	}
+	if !strings.HasPrefix(address, "https://") {
+		return 0, errors.New("notification targets must use https")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, address, bytes.NewReader(payload))
+	if err != nil {
+		return 0, fmt.Errorf("error creating request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, notification.Event)
+	req.Header.Set(DeliveryHeader, notification.ID)
+	req.Header.Set(TimestampHeader, timestamp)
+	if target.SigningSecret != "" {
+		req.Header.Set(SignatureHeader, Sign(target.SigningSecret, timestamp, payload))
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error posting notification: %w", err)
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64*1024))
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return res.StatusCode, fmt.Errorf("notification target responded with %s", res.Status)
+	}
+
+	return res.StatusCode, nil
+}
+
+// dialControl refuses connections to internal addresses, checked once the
+// target host is resolved so a name pointing to one is refused as well
+func dialControl(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("error parsing address %s: %w", address, err)
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil || internalAddress(ip) {
+		return fmt.Errorf("notification targets may not connect to %s", host)
+	}
+
+	return nil
+}
+
+// internalAddress reports whether ip is local to the API or its network
+func internalAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() {
+		return true
+	}
+
+	for _, network := range internalNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// internalHost reports whether a target host is an internal address or a
+// name only resolved inside the cluster
+func internalHost(host string) bool {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return internalAddress(ip)
+	}
+
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || !strings.Contains(host, ".") {
+		return true
+	}
+	for _, suffix := range []string{".localhost", ".local", ".internal", ".svc", ".cluster.local"} {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Payload returns the body posted to a target: a message for Slack and
+// Teams, an event for PagerDuty and the notification itself for webhooks
+func Payload(target pkgtypes.NotificationTarget, notification pkgtypes.Notification) ([]byte, error) {
+	var body interface{}
+
+	switch target.Type {
+	case TypeSlack:
+		body = map[string]string{"text": notification.Message}
+	case TypeTeams:
+		body = map[string]string{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    notification.Message,
+			"themeColor": color(notification.Event),
+			"title":      "Kubefirst",
+			"text":       notification.Message,
+		}
+	case TypePagerDuty:
+		severity := "info"
+		if failure(notification.Event) {
+			severity = "error"
+		}
+		source := notification.Cluster
+		if source == "" {
+			source = "kubefirst-api"
+		}
+
+		body = map[string]interface{}{
+			"routing_key":  target.RoutingKey,
+			"event_action": "trigger",
+			"dedup_key":    notification.ID,
+			"payload": map[string]interface{}{
+				"summary":        notification.Message,
+				"source":         source,
+				"severity":       severity,
+				"timestamp":      notification.Time.Format(time.RFC3339),
+				"component":      notification.Service,
+				"class":          notification.Event,
+				"custom_details": notification,
+			},
+		}
+	default:
+		body = notification
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling json: %w", err)
+	}
+
+	return payload, nil
+}
+
+// failure reports whether event reports a failure
+func failure(event string) bool {
+	return event == EventClusterFailed || event == EventServiceSyncFailed
+}
+
+// color returns the theme color of a Teams message for event
+func color(event string) string {
+	switch {
+	case failure(event):
+		return "D92D20"
+	case event == EventClusterProvisioned:
+		return "12B76A"
+	default:
+		return "2E90FA"
+	}
+}
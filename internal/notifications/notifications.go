@@ -0,0 +1,263 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package notifications
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/env"
+	"github.com/konstructio/kubefirst-api/internal/events"
+	"github.com/konstructio/kubefirst-api/internal/secrets"
+	"github.com/konstructio/kubefirst-api/internal/utils"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+	log "github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Target types
+const (
+	TypeSlack     = "slack"
+	TypeTeams     = "teams"
+	TypePagerDuty = "pagerduty"
+	TypeWebhook   = "webhook"
+)
+
+// Notification events
+const (
+	// EventClusterProvisioned is sent when a cluster finishes provisioning
+	EventClusterProvisioned = "cluster_provisioned"
+	// EventClusterFailed is sent when provisioning or deleting a cluster fails
+	EventClusterFailed = "cluster_failed"
+	// EventClusterDeleting is sent when a cluster starts deleting
+	EventClusterDeleting = "cluster_deleting"
+	// EventServiceSyncFailed is sent when Argo CD could not synchronize a
+	// catalog service added to a cluster
+	EventServiceSyncFailed = "service_sync_failed"
+	// EventTest is sent to a target on request, whatever its events
+	EventTest = "test"
+)
+
+const (
+	// DefaultLimit is the number of deliveries returned when a query sets no
+	// limit
+	DefaultLimit = 100
+	// MaxLimit is the largest number of deliveries a query returns
+	MaxLimit = 1000
+)
+
+var (
+	// Types lists every target type
+	Types = []string{TypeSlack, TypeTeams, TypePagerDuty, TypeWebhook}
+
+	// Events lists every event a target can be notified of
+	Events = []string{EventClusterProvisioned, EventClusterFailed, EventClusterDeleting, EventServiceSyncFailed}
+
+	// validName keeps target names usable in a Kubernetes Secret name
+	validName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,38}[a-z0-9])?$`)
+)
+
+// ValidateTarget checks the name, type, URL and events of a target
+func ValidateTarget(target *pkgtypes.NotificationTarget) error {
+	if !validName.MatchString(target.Name) {
+		return fmt.Errorf("notification target name %q must be at most 40 lowercase alphanumeric characters or '-'", target.Name)
+	}
+
+	if !slices.Contains(Types, target.Type) {
+		return fmt.Errorf("unknown notification target type %q, valid types are: %s", target.Type, strings.Join(Types, ", "))
+	}
+
+	if target.Type == TypePagerDuty && target.RoutingKey == "" {
+		return errors.New("pagerduty notification targets require a routing_key")
+	}
+
+	if target.URL == "" && target.Type != TypePagerDuty {
+		return fmt.Errorf("%s notification targets require a url", target.Type)
+	}
+	if target.URL != "" {
+		parsed, err := url.Parse(target.URL)
+		if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+			return fmt.Errorf("notification target url %q must be an https URL", target.URL)
+		}
+		if internalHost(parsed.Hostname()) {
+			return fmt.Errorf("notification target url %q must not point to a local or cluster-internal address", target.URL)
+		}
+	}
+
+	for _, event := range target.Events {
+		if !slices.Contains(Events, event) {
+			return fmt.Errorf("unknown notification event %q, valid events are: %s", event, strings.Join(Events, ", "))
+		}
+	}
+
+	return nil
+}
+
+// FromEvent returns the notification sent for a cluster event, if any. The
+// error of a failure is not sent, since it may name cloud resources or
+// credentials; failures link to the logs of the cluster instead.
+func FromEvent(event events.Event) (pkgtypes.Notification, bool) {
+	notification := pkgtypes.Notification{
+		ID:             primitive.NewObjectID().Hex(),
+		Cluster:        event.Cluster,
+		Service:        event.Service,
+		Status:         event.Status,
+		PreviousStatus: event.PreviousStatus,
+		Time:           event.Time,
+	}
+
+	switch event.Type {
+	case events.StatusChanged:
+		// A status first seen since the API started may have been set
+		// before, so only changes from a known status are notified
+		if event.PreviousStatus == "" {
+			return pkgtypes.Notification{}, false
+		}
+
+		switch event.Status {
+		case constants.ClusterStatusProvisioned:
+			if event.PreviousStatus != constants.ClusterStatusProvisioning {
+				return pkgtypes.Notification{}, false
+			}
+			notification.Event = EventClusterProvisioned
+			notification.Message = fmt.Sprintf("Cluster %s finished provisioning", event.Cluster)
+		case constants.ClusterStatusError:
+			notification.Event = EventClusterFailed
+			notification.Logs = logsURL(event.Cluster)
+			notification.Message = fmt.Sprintf("Cluster %s failed, see its logs at %s", event.Cluster, notification.Logs)
+		case constants.ClusterStatusDeleting:
+			notification.Event = EventClusterDeleting
+			notification.Message = fmt.Sprintf("Cluster %s is being deleted", event.Cluster)
+		default:
+			return pkgtypes.Notification{}, false
+		}
+	case events.ServiceSyncFailed:
+		notification.Event = EventServiceSyncFailed
+		notification.Logs = logsURL(event.Cluster)
+		notification.Message = fmt.Sprintf("Service %s failed to sync on cluster %s, see its logs at %s", event.Service, event.Cluster, notification.Logs)
+	default:
+		return pkgtypes.Notification{}, false
+	}
+
+	return notification, true
+}
+
+// logsURL returns the address of the logs of a cluster, relative to the API
+// unless K1_API_URL is set
+func logsURL(clusterName string) string {
+	env, _ := env.GetEnv(constants.SilenceGetEnv)
+
+	return fmt.Sprintf("%s/api/v1/cluster/%s/logs", strings.TrimSuffix(env.APIURL, "/"), url.PathEscape(clusterName))
+}
+
+// Test returns a notification to check the delivery to a target
+func Test() pkgtypes.Notification {
+	return pkgtypes.Notification{
+		ID:      primitive.NewObjectID().Hex(),
+		Event:   EventTest,
+		Message: "Test notification from the Kubefirst API",
+		Time:    time.Now().UTC(),
+	}
+}
+
+// subscribed reports whether target is notified of event
+func subscribed(target pkgtypes.NotificationTarget, event string) bool {
+	return event == EventTest || len(target.Events) == 0 || slices.Contains(target.Events, event)
+}
+
+var (
+	startOnce sync.Once
+
+	recordOnce sync.Once
+	deliveries chan pkgtypes.NotificationDelivery
+)
+
+// Start notifies the configured targets of the events published on the bus
+// of the API, in the background
+func Start(clientSet kubernetes.Interface) {
+	startOnce.Do(func() {
+		go watch(clientSet, events.Default())
+	})
+}
+
+// watch sends the notifications of the events published on bus. A
+// subscription dropped for falling behind is renewed from the last event
+// handled, so no event is missed while it is still buffered.
+func watch(clientSet kubernetes.Interface, bus *events.Bus) {
+	var lastID uint64
+	for {
+		sub, missed := bus.Subscribe("", lastID)
+		for _, event := range missed {
+			lastID = event.ID
+			dispatch(clientSet, event)
+		}
+		for event := range sub.C {
+			lastID = event.ID
+			dispatch(clientSet, event)
+		}
+
+		log.Warn().Msgf("notifications fell behind the cluster events, resuming after event %d", lastID)
+	}
+}
+
+// dispatch delivers the notification of event to the targets subscribed to
+// it, each in its own goroutine so a failing target does not hold back the
+// others
+func dispatch(clientSet kubernetes.Interface, event events.Event) {
+	notification, ok := FromEvent(event)
+	if !ok {
+		return
+	}
+
+	targets, err := secrets.GetNotificationTargets(clientSet)
+	if err != nil {
+		log.Error().Msgf("error getting notification targets for %s of cluster %s: %s", notification.Event, notification.Cluster, err)
+		return
+	}
+
+	env, _ := env.GetEnv(constants.SilenceGetEnv)
+	for _, target := range targets {
+		if !subscribed(target, notification.Event) {
+			continue
+		}
+
+		go func() {
+			Record(Deliver(target, notification, env.NotificationAttempts))
+		}()
+	}
+}
+
+// Record queues a delivery to be written to the delivery log, which keeps
+// the latest K1_NOTIFICATION_LOG_SIZE deliveries. Deliveries are written by
+// a single writer so concurrent deliveries never overwrite each other.
+func Record(delivery pkgtypes.NotificationDelivery) {
+	recordOnce.Do(func() {
+		deliveries = make(chan pkgtypes.NotificationDelivery, 256)
+		go write(deliveries)
+	})
+
+	deliveries <- delivery
+}
+
+func write(queue <-chan pkgtypes.NotificationDelivery) {
+	env, _ := env.GetEnv(constants.SilenceGetEnv)
+	kcfg := utils.GetKubernetesClient("")
+
+	for delivery := range queue {
+		if err := secrets.InsertNotificationDelivery(kcfg.Clientset, delivery, env.NotificationLogSize); err != nil {
+			log.Error().Msgf("error recording delivery %s of %s to %s: %s", delivery.ID, delivery.Event, delivery.Target, err)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package notifications
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/events"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+)
+
+func TestFromEvent(t *testing.T) {
+	tests := []struct {
+		name  string
+		event events.Event
+		want  string
+	}{
+		{
+			name:  "provisioned",
+			event: events.Event{Type: events.StatusChanged, Status: constants.ClusterStatusProvisioned, PreviousStatus: constants.ClusterStatusProvisioning},
+			want:  EventClusterProvisioned,
+		},
+		{
+			name:  "upgraded",
+			event: events.Event{Type: events.StatusChanged, Status: constants.ClusterStatusProvisioned, PreviousStatus: constants.ClusterStatusUpdating},
+		},
+		{
+			name:  "failed",
+			event: events.Event{Type: events.StatusChanged, Status: constants.ClusterStatusError, PreviousStatus: constants.ClusterStatusProvisioning, Error: "error with token dop_v1_abc"},
+			want:  EventClusterFailed,
+		},
+		{
+			name:  "deleting",
+			event: events.Event{Type: events.StatusChanged, Status: constants.ClusterStatusDeleting, PreviousStatus: constants.ClusterStatusProvisioned},
+			want:  EventClusterDeleting,
+		},
+		{
+			name:  "status first seen",
+			event: events.Event{Type: events.StatusChanged, Status: constants.ClusterStatusError},
+		},
+		{
+			name:  "service sync failed",
+			event: events.Event{Type: events.ServiceSyncFailed, Service: "metaphor", Error: "timed out"},
+			want:  EventServiceSyncFailed,
+		},
+		{
+			name:  "step failed",
+			event: events.Event{Type: events.StepFailed, Step: "terraform_apply"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.event.Cluster = "dev"
+
+			notification, ok := FromEvent(tt.event)
+			if ok != (tt.want != "") {
+				t.Fatalf("expected a notification %t, got %t", tt.want != "", ok)
+			}
+			if notification.Event != tt.want {
+				t.Errorf("expected event %q, got %q", tt.want, notification.Event)
+			}
+			if tt.event.Error != "" && strings.Contains(notification.Message, tt.event.Error) {
+				t.Errorf("expected the error not to be sent, got %q", notification.Message)
+			}
+		})
+	}
+}
+
+func TestValidateTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{name: "https", url: "https://hooks.slack.com/services/T000/B000/XXXX"},
+		{name: "http", url: "http://hooks.slack.com/services/T000/B000/XXXX", wantErr: true},
+		{name: "metadata address", url: "https://169.254.169.254/latest/meta-data", wantErr: true},
+		{name: "private address", url: "https://10.0.0.12/hook", wantErr: true},
+		{name: "loopback", url: "https://[::1]:8443/hook", wantErr: true},
+		{name: "cluster service", url: "https://kubefirst-api.kubefirst.svc.cluster.local/hook", wantErr: true},
+		{name: "short service name", url: "https://vault/hook", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := pkgtypes.NotificationTarget{Name: "receiver", Type: TypeWebhook, URL: tt.url}
+			if err := ValidateTarget(&target); (err != nil) != tt.wantErr {
+				t.Errorf("expected error %t, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestDialControl(t *testing.T) {
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{address: "203.0.113.10:443", allowed: true},
+		{address: "[2001:db8::1]:443", allowed: true},
+		{address: "127.0.0.1:443"},
+		{address: "169.254.169.254:80"},
+		{address: "[fd00:ec2::254]:80"},
+		{address: "[::ffff:10.0.0.1]:443"},
+		{address: "100.64.0.10:443"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			if err := dialControl("tcp", tt.address, nil); (err == nil) != tt.allowed {
+				t.Errorf("expected allowed %t, got %v", tt.allowed, err)
+			}
+		})
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	retryDelay = time.Millisecond
+
+	// the test server listens on loopback, which targets may not reach
+	defaultClient := client
+	t.Cleanup(func() { client = defaultClient })
+
+	tests := []struct {
+		name     string
+		statuses []int
+		outcome  string
+		attempts int
+	}{
+		{name: "success", statuses: []int{http.StatusOK}, outcome: constants.NotificationOutcomeSuccess, attempts: 1},
+		{name: "retried after server error", statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusNoContent}, outcome: constants.NotificationOutcomeSuccess, attempts: 3},
+		{name: "client error is not retried", statuses: []int{http.StatusNotFound}, outcome: constants.NotificationOutcomeFailure, attempts: 1},
+		{name: "gives up", statuses: []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}, outcome: constants.NotificationOutcomeFailure, attempts: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			var signed atomic.Bool
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				payload, _ := io.ReadAll(r.Body)
+				signed.Store(r.Header.Get(SignatureHeader) == Sign("secret", r.Header.Get(TimestampHeader), payload))
+
+				w.WriteHeader(tt.statuses[calls.Add(1)-1])
+			}))
+			defer server.Close()
+			client = server.Client()
+
+			target := pkgtypes.NotificationTarget{Name: "receiver", Type: TypeWebhook, URL: server.URL, SigningSecret: "secret"}
+			delivery := Deliver(target, Test(), 3)
+
+			if delivery.Outcome != tt.outcome {
+				t.Errorf("expected outcome %q, got %q: %s", tt.outcome, delivery.Outcome, delivery.Error)
+			}
+			if delivery.Attempts != tt.attempts || int(calls.Load()) != tt.attempts {
+				t.Errorf("expected %d attempts, got %d recorded and %d received", tt.attempts, delivery.Attempts, calls.Load())
+			}
+			if !signed.Load() {
+				t.Error("expected the payload to be signed with the signing secret")
+			}
+		})
+	}
+}
@@ -0,0 +1,343 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	// jwksRefreshInterval limits how often an unknown key id triggers a fetch
+	// of the issuer keys, and is the shortest time fetched keys are used
+	jwksRefreshInterval = time.Minute
+
+	// jwksTTL is how long fetched keys are used when the issuer does not set
+	// a max-age on them, and jwksMaxTTL the longest they are used whatever
+	// the max-age, so a key the issuer revoked stops being accepted
+	jwksTTL    = time.Hour
+	jwksMaxTTL = 24 * time.Hour
+)
+
+var (
+	errUnknownKey  = errors.New("token is signed with an unknown key")
+	errKeysExpired = errors.New("oidc keys have expired and could not be refreshed")
+)
+
+// Identity is the caller described by a verified token
+type Identity struct {
+	// Subject is the sub claim, the identifier of the caller that the issuer
+	// never reassigns
+	Subject string
+	// Name is the preferred username of the caller, falling back to the
+	// email and then the subject. It can change and be reused, so it is only
+	// fit for display.
+	Name   string
+	Groups []string
+}
+
+// Verifier checks tokens issued by an OIDC provider against the keys it
+// publishes
+type Verifier struct {
+	Issuer      string
+	Audience    string
+	JWKSURL     string
+	GroupsClaim string
+	HTTPClient  *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+	expiresAt time.Time
+}
+
+// NewVerifier returns a verifier for tokens issued by issuer. The keys are
+// read from jwksURL, or from the jwks_uri in the issuer discovery document
+// when jwksURL is empty. Tokens are only accepted for audience, so every
+// token is rejected when audience is empty.
+func NewVerifier(issuer, audience, jwksURL, groupsClaim string) *Verifier {
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+
+	return &Verifier{
+		Issuer:      strings.TrimSuffix(issuer, "/"),
+		Audience:    audience,
+		JWKSURL:     jwksURL,
+		GroupsClaim: groupsClaim,
+		HTTPClient:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Verify checks the signature, issuer, audience and lifetime of a token and
+// returns the identity it carries. Tokens must expire, and are rejected
+// before the time set by their nbf claim.
+func (v *Verifier) Verify(ctx context.Context, rawToken string) (*Identity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return v.key(ctx, kid)
+	}, jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}))
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+
+	now := time.Now().Unix()
+	if !claims.VerifyExpiresAt(now, true) {
+		return nil, errors.New("token has no expiry or has expired")
+	}
+	if !claims.VerifyNotBefore(now, false) {
+		return nil, errors.New("token is not valid yet")
+	}
+
+	if !claims.VerifyIssuer(v.Issuer, true) {
+		return nil, fmt.Errorf("token was not issued by %s", v.Issuer)
+	}
+	if v.Audience == "" {
+		return nil, errors.New("no audience is configured to accept tokens for")
+	}
+	if !claims.VerifyAudience(v.Audience, true) {
+		return nil, fmt.Errorf("token was not issued for %s", v.Audience)
+	}
+
+	identity := &Identity{Groups: stringSlice(claims[v.GroupsClaim])}
+	identity.Subject, _ = claims["sub"].(string)
+	if identity.Subject == "" {
+		return nil, errors.New("token does not identify a subject")
+	}
+	for _, claim := range []string{"preferred_username", "email", "sub"} {
+		if name, ok := claims[claim].(string); ok && name != "" {
+			identity.Name = name
+			break
+		}
+	}
+
+	return identity, nil
+}
+
+// key returns the public key with id kid, fetching the issuer keys when the
+// id is not known yet or the keys fetched last have expired
+func (v *Verifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := time.Now()
+	fresh := now.Before(v.expiresAt)
+	if fresh {
+		if key, ok := v.lookup(kid); ok {
+			return key, nil
+		}
+	}
+
+	if now.Sub(v.fetchedAt) < jwksRefreshInterval {
+		if fresh {
+			return nil, errUnknownKey
+		}
+		return nil, errKeysExpired
+	}
+
+	keys, ttl, err := v.fetchKeys(ctx)
+	v.fetchedAt = now
+	if err != nil {
+		return nil, err
+	}
+	v.keys = keys
+	v.expiresAt = now.Add(ttl)
+
+	if key, ok := v.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, errUnknownKey
+}
+
+// lookup finds a key by id. A token without a key id is accepted when the
+// issuer publishes a single key.
+func (v *Verifier) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+
+	key, ok := v.keys[kid]
+	return key, ok
+}
+
+// fetchKeys returns the signing keys of the issuer and how long they may be
+// used for
+func (v *Verifier) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, time.Duration, error) {
+	jwksURL := v.JWKSURL
+	if jwksURL == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if _, err := v.getJSON(ctx, v.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+			return nil, 0, fmt.Errorf("error reading oidc discovery document: %w", err)
+		}
+		if discovery.JWKSURI == "" {
+			return nil, 0, fmt.Errorf("oidc discovery document of %s has no jwks_uri", v.Issuer)
+		}
+		jwksURL = discovery.JWKSURI
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	header, err := v.getJSON(ctx, jwksURL, &jwks)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading oidc keys: %w", err)
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	return keys, keysTTL(header.Get("Cache-Control")), nil
+}
+
+// keysTTL returns how long keys served with a Cache-Control header may be
+// used, between jwksRefreshInterval and jwksMaxTTL
+func keysTTL(cacheControl string) time.Duration {
+	ttl := jwksTTL
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(strings.ToLower(directive)), "=")
+		switch name {
+		case "no-cache", "no-store":
+			return jwksRefreshInterval
+		case "max-age":
+			if seconds, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil {
+				ttl = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+
+	return min(max(ttl, jwksRefreshInterval), jwksMaxTTL)
+}
+
+// getJSON decodes the response to a request for url into out and returns its
+// header
+func (v *Verifier) getJSON(ctx context.Context, url string, out interface{}) (http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	res, err := v.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error requesting %s: %w", url, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d from %s", res.StatusCode, url)
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", url, err)
+	}
+
+	return res.Header, nil
+}
+
+// jsonWebKey is a public key in a JSON web key set
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding key: %w", err)
+	}
+	return new(big.Int).SetBytes(decoded), nil
+}
+
+// stringSlice reads a claim that is either a list of strings or a single string
+func stringSlice(claim interface{}) []string {
+	switch value := claim.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := []string{}
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+
+	return []string{}
+}
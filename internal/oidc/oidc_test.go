@@ -0,0 +1,173 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// newIssuer starts a local stand-in for an OIDC provider that publishes the
+// public half of key under kid
+func newIssuer(t *testing.T, key *rsa.PrivateKey, kid string) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": server.URL, "jwks_uri": server.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": kid,
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+			}},
+		})
+	})
+
+	return server
+}
+
+func signToken(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestVerify(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := newIssuer(t, key, "vault")
+	verifier := NewVerifier(issuer.URL, "kubefirst-console", "", "")
+
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":                issuer.URL,
+			"aud":                "kubefirst-console",
+			"sub":                "4f1c2a",
+			"preferred_username": "jane",
+			"groups":             []string{"developers"},
+			"exp":                time.Now().Add(time.Hour).Unix(),
+		}
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		identity, err := verifier.Verify(context.Background(), signToken(t, key, "vault", validClaims()))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if identity.Name != "jane" || identity.Subject != "4f1c2a" || !slices.Equal(identity.Groups, []string{"developers"}) {
+			t.Errorf("unexpected identity %+v", identity)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		key    *rsa.PrivateKey
+		kid    string
+		modify func(jwt.MapClaims)
+	}{
+		{name: "wrong issuer", key: key, kid: "vault", modify: func(c jwt.MapClaims) { c["iss"] = "https://attacker.example.com" }},
+		{name: "wrong audience", key: key, kid: "vault", modify: func(c jwt.MapClaims) { c["aud"] = "another-app" }},
+		{name: "expired", key: key, kid: "vault", modify: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }},
+		{name: "no expiry", key: key, kid: "vault", modify: func(c jwt.MapClaims) { delete(c, "exp") }},
+		{name: "not valid yet", key: key, kid: "vault", modify: func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Hour).Unix() }},
+		{name: "signed by another key", key: otherKey, kid: "vault", modify: func(jwt.MapClaims) {}},
+		{name: "unknown key id", key: otherKey, kid: "other", modify: func(jwt.MapClaims) {}},
+		{name: "no subject", key: key, kid: "vault", modify: func(c jwt.MapClaims) { delete(c, "sub") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			tt.modify(claims)
+
+			if _, err := verifier.Verify(context.Background(), signToken(t, tt.key, tt.kid, claims)); err == nil {
+				t.Error("expected an error, got none")
+			}
+		})
+	}
+
+	t.Run("no audience configured", func(t *testing.T) {
+		verifier := NewVerifier(issuer.URL, "", "", "")
+		if _, err := verifier.Verify(context.Background(), signToken(t, key, "vault", validClaims())); err == nil {
+			t.Error("expected an error, got none")
+		}
+	})
+}
+
+func TestKeysTTL(t *testing.T) {
+	tests := []struct {
+		cacheControl string
+		expected     time.Duration
+	}{
+		{cacheControl: "", expected: jwksTTL},
+		{cacheControl: "public, max-age=600", expected: 10 * time.Minute},
+		{cacheControl: "max-age=5", expected: jwksRefreshInterval},
+		{cacheControl: "max-age=604800", expected: jwksMaxTTL},
+		{cacheControl: "no-cache", expected: jwksRefreshInterval},
+		{cacheControl: "max-age=invalid", expected: jwksTTL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cacheControl, func(t *testing.T) {
+			if ttl := keysTTL(tt.cacheControl); ttl != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, ttl)
+			}
+		})
+	}
+}
+
+func TestKeyExpiry(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := newIssuer(t, key, "vault")
+	verifier := NewVerifier(issuer.URL, "kubefirst-console", "", "")
+	claims := jwt.MapClaims{"iss": issuer.URL, "aud": "kubefirst-console", "sub": "4f1c2a", "exp": time.Now().Add(time.Hour).Unix()}
+
+	if _, err := verifier.Verify(context.Background(), signToken(t, key, "vault", claims)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// the issuer stops publishing the key once the cached keys have expired
+	issuer.Close()
+	verifier.mu.Lock()
+	verifier.expiresAt = time.Now().Add(-time.Second)
+	verifier.fetchedAt = time.Now().Add(-jwksRefreshInterval)
+	verifier.mu.Unlock()
+
+	if _, err := verifier.Verify(context.Background(), signToken(t, key, "vault", claims)); err == nil {
+		t.Error("expected expired keys not to be used")
+	}
+}
@@ -0,0 +1,175 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package rbac
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+)
+
+// Actions restricted by role bindings. Reads are governed by scopes alone.
+const (
+	ActionWrite  = "write"
+	ActionDelete = "delete"
+)
+
+// Kinds of subjects a role can be bound to
+const (
+	SubjectAPIKey = "apikey"
+	SubjectUser   = "user"
+	SubjectGroup  = "group"
+)
+
+// Kinds of resources a role can be bound on
+const (
+	ResourceCluster         = "cluster"
+	ResourceWorkloadCluster = "workload_cluster"
+	ResourceEnvironment     = "environment"
+	ResourceService         = "service"
+)
+
+// Wildcard matches every resource of a kind
+const Wildcard = "*"
+
+var (
+	// roleActions lists the actions granted by each role
+	roleActions = map[string][]string{
+		constants.RoleAdmin:  {ActionWrite, ActionDelete},
+		constants.RoleEditor: {ActionWrite},
+	}
+
+	subjectKinds  = []string{SubjectAPIKey, SubjectUser, SubjectGroup}
+	resourceKinds = []string{ResourceCluster, ResourceWorkloadCluster, ResourceEnvironment, ResourceService}
+
+	// validName keeps role binding names usable in a Kubernetes Secret name
+	validName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,38}[a-z0-9])?$`)
+
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Subject is the caller a request is authorized for
+type Subject struct {
+	// Kind is SubjectAPIKey or SubjectUser
+	Kind   string
+	Name   string
+	Groups []string
+}
+
+// Target is the resource a request acts on, along with the resources that
+// contain it. Fields that do not apply are left empty.
+type Target struct {
+	Cluster         string
+	WorkloadCluster string
+	Environment     string
+	Service         string
+}
+
+func (t Target) String() string {
+	parts := []string{}
+	for _, part := range []struct{ kind, name string }{
+		{ResourceCluster, t.Cluster},
+		{ResourceWorkloadCluster, t.WorkloadCluster},
+		{ResourceEnvironment, t.Environment},
+		{ResourceService, t.Service},
+	} {
+		if part.name != "" {
+			parts = append(parts, fmt.Sprintf("%s %q", part.kind, part.name))
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// ValidateBinding checks the name, role, subjects and resources of a role binding
+func ValidateBinding(binding *pkgtypes.RoleBinding) error {
+	if !validName.MatchString(binding.Name) {
+		return fmt.Errorf("role binding name %q must be at most 40 lowercase alphanumeric characters or '-'", binding.Name)
+	}
+
+	if _, ok := roleActions[binding.Role]; !ok {
+		return fmt.Errorf("unknown role %q, valid roles are: %s, %s", binding.Role, constants.RoleAdmin, constants.RoleEditor)
+	}
+
+	if len(binding.Subjects) == 0 {
+		return errors.New("at least one subject is required")
+	}
+	for _, subject := range binding.Subjects {
+		if !slices.Contains(subjectKinds, subject.Kind) {
+			return fmt.Errorf("unknown subject kind %q, valid kinds are: %s", subject.Kind, strings.Join(subjectKinds, ", "))
+		}
+		if subject.Name == "" {
+			return fmt.Errorf("%s subject name cannot be empty", subject.Kind)
+		}
+	}
+
+	if len(binding.Resources) == 0 {
+		return errors.New("at least one resource is required")
+	}
+	for _, resource := range binding.Resources {
+		if !slices.Contains(resourceKinds, resource.Kind) {
+			return fmt.Errorf("unknown resource kind %q, valid kinds are: %s", resource.Kind, strings.Join(resourceKinds, ", "))
+		}
+		if resource.Name == "" {
+			return fmt.Errorf("%s resource name cannot be empty", resource.Kind)
+		}
+	}
+
+	return nil
+}
+
+// Authorize returns ErrForbidden unless one of bindings grants subject the
+// action on target or on a resource containing it
+func Authorize(bindings []pkgtypes.RoleBinding, subject Subject, action string, target Target) error {
+	for _, binding := range bindings {
+		if !slices.Contains(roleActions[binding.Role], action) {
+			continue
+		}
+		if !slices.ContainsFunc(binding.Subjects, subject.matches) {
+			continue
+		}
+		if slices.ContainsFunc(binding.Resources, target.matches) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s %q may not %s %s", ErrForbidden, subject.Kind, subject.Name, action, target)
+}
+
+func (s Subject) matches(bound pkgtypes.RoleBindingSubject) bool {
+	switch bound.Kind {
+	case SubjectGroup:
+		return s.Kind == SubjectUser && slices.Contains(s.Groups, bound.Name)
+	default:
+		return s.Kind == bound.Kind && s.Name == bound.Name
+	}
+}
+
+func (t Target) matches(bound pkgtypes.RoleBindingResource) bool {
+	var name string
+	switch bound.Kind {
+	case ResourceCluster:
+		name = t.Cluster
+	case ResourceWorkloadCluster:
+		name = t.WorkloadCluster
+	case ResourceEnvironment:
+		name = t.Environment
+	case ResourceService:
+		name = t.Service
+	}
+
+	if name == "" {
+		return false
+	}
+
+	return bound.Name == Wildcard || bound.Name == name
+}
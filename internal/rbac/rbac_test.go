@@ -0,0 +1,92 @@
+package rbac
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+)
+
+func TestAuthorize(t *testing.T) {
+	bindings := []pkgtypes.RoleBinding{
+		{
+			Name:      "developers-development",
+			Role:      constants.RoleEditor,
+			Subjects:  []pkgtypes.RoleBindingSubject{{Kind: SubjectGroup, Name: "developers"}},
+			Resources: []pkgtypes.RoleBindingResource{{Kind: ResourceEnvironment, Name: "development"}},
+		},
+		{
+			Name:      "ci-clusters",
+			Role:      constants.RoleAdmin,
+			Subjects:  []pkgtypes.RoleBindingSubject{{Kind: SubjectAPIKey, Name: "ci"}},
+			Resources: []pkgtypes.RoleBindingResource{{Kind: ResourceCluster, Name: Wildcard}},
+		},
+	}
+
+	developer := Subject{Kind: SubjectUser, Name: "jane", Groups: []string{"developers"}}
+	ci := Subject{Kind: SubjectAPIKey, Name: "ci"}
+	developmentService := Target{Cluster: "mgmt", WorkloadCluster: "dev", Environment: "development", Service: "metaphor"}
+
+	tests := []struct {
+		name     string
+		subject  Subject
+		action   string
+		target   Target
+		expected error
+	}{
+		{name: "editor writes in bound environment", subject: developer, action: ActionWrite, target: developmentService},
+		{name: "editor cannot delete in bound environment", subject: developer, action: ActionDelete, target: developmentService, expected: ErrForbidden},
+		{name: "editor cannot write in other environment", subject: developer, action: ActionWrite, target: Target{Cluster: "mgmt", Environment: "production", Service: "metaphor"}, expected: ErrForbidden},
+		{name: "editor cannot delete cluster", subject: developer, action: ActionDelete, target: Target{Cluster: "mgmt"}, expected: ErrForbidden},
+		{name: "group binding does not match api key of the same name", subject: Subject{Kind: SubjectAPIKey, Name: "developers"}, action: ActionWrite, target: developmentService, expected: ErrForbidden},
+		{name: "wildcard admin deletes cluster", subject: ci, action: ActionDelete, target: Target{Cluster: "mgmt"}},
+		{name: "wildcard does not match environment", subject: ci, action: ActionWrite, target: Target{Environment: "production"}, expected: ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Authorize(bindings, tt.subject, tt.action, tt.target)
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestValidateBinding(t *testing.T) {
+	valid := pkgtypes.RoleBinding{
+		Name:      "developers",
+		Role:      constants.RoleEditor,
+		Subjects:  []pkgtypes.RoleBindingSubject{{Kind: SubjectGroup, Name: "developers"}},
+		Resources: []pkgtypes.RoleBindingResource{{Kind: ResourceEnvironment, Name: "development"}},
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(b *pkgtypes.RoleBinding)
+		wantErr bool
+	}{
+		{name: "valid", modify: func(*pkgtypes.RoleBinding) {}},
+		{name: "invalid name", modify: func(b *pkgtypes.RoleBinding) { b.Name = "Developers" }, wantErr: true},
+		{name: "viewer role", modify: func(b *pkgtypes.RoleBinding) { b.Role = constants.RoleViewer }, wantErr: true},
+		{name: "no subjects", modify: func(b *pkgtypes.RoleBinding) { b.Subjects = nil }, wantErr: true},
+		{name: "unknown subject kind", modify: func(b *pkgtypes.RoleBinding) { b.Subjects[0].Kind = "team" }, wantErr: true},
+		{name: "unknown resource kind", modify: func(b *pkgtypes.RoleBinding) { b.Resources[0].Kind = "namespace" }, wantErr: true},
+		{name: "empty resource name", modify: func(b *pkgtypes.RoleBinding) { b.Resources[0].Name = "" }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			binding := valid
+			binding.Subjects = append([]pkgtypes.RoleBindingSubject{}, valid.Subjects...)
+			binding.Resources = append([]pkgtypes.RoleBindingResource{}, valid.Resources...)
+			tt.modify(&binding)
+
+			err := ValidateBinding(&binding)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error: %t, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package redact
+
+import (
+	"fmt"
+	"net/url"
+
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+)
+
+// Mask replaces secret values in API responses. Empty values are left empty
+// so callers can still tell whether a credential is set.
+const Mask = "********"
+
+// Cluster returns a copy of cl with every credential masked
+func Cluster(cl pkgtypes.Cluster) pkgtypes.Cluster {
+	mask(&cl.AkamaiAuth.Token)
+	mask(&cl.AWSAuth.SecretAccessKey)
+	mask(&cl.AWSAuth.SessionToken)
+	mask(&cl.CivoAuth.Token)
+	mask(&cl.DigitaloceanAuth.Token)
+	mask(&cl.DigitaloceanAuth.SpacesSecret)
+	mask(&cl.VultrAuth.Token)
+	mask(&cl.CloudflareAuth.Token)
+	mask(&cl.CloudflareAuth.APIToken)
+	mask(&cl.CloudflareAuth.OriginCaIssuerKey)
+	mask(&cl.GoogleAuth.KeyFile)
+	mask(&cl.K3sAuth.K3sSSHPrivateKey)
+	mask(&cl.VaultAuth.RootToken)
+	mask(&cl.VaultAuth.KbotPassword)
+	mask(&cl.StateStoreCredentials.SecretAccessKey)
+	mask(&cl.StateStoreCredentials.SessionToken)
+	mask(&cl.AtlantisWebhookSecret)
+	mask(&cl.ArgoCDPassword)
+	mask(&cl.ArgoCDAuthToken)
+	gitAuth(&cl.GitAuth)
+
+	if cl.WorkloadClusters != nil {
+		workloadClusters := make([]pkgtypes.WorkloadCluster, len(cl.WorkloadClusters))
+		copy(workloadClusters, cl.WorkloadClusters)
+		for i := range workloadClusters {
+			gitAuth(&workloadClusters[i].GitAuth)
+		}
+		cl.WorkloadClusters = workloadClusters
+	}
+
+	return cl
+}
+
+// Clusters returns copies of clusters with every credential masked
+func Clusters(clusters []pkgtypes.Cluster) []pkgtypes.Cluster {
+	redacted := make([]pkgtypes.Cluster, 0, len(clusters))
+	for _, cl := range clusters {
+		redacted = append(redacted, Cluster(cl))
+	}
+
+	return redacted
+}
+
+// NotificationTarget returns a copy of target with its credentials masked.
+// Incoming webhook URLs carry their token in the path, so only their scheme
+// and host are kept.
+func NotificationTarget(target pkgtypes.NotificationTarget) pkgtypes.NotificationTarget {
+	mask(&target.RoutingKey)
+	mask(&target.SigningSecret)
+
+	if target.URL != "" {
+		parsed, err := url.Parse(target.URL)
+		if err != nil || parsed.Host == "" {
+			target.URL = Mask
+		} else if parsed.Path != "" || parsed.RawQuery != "" {
+			target.URL = fmt.Sprintf("%s://%s/%s", parsed.Scheme, parsed.Host, Mask)
+		}
+	}
+
+	return target
+}
+
+// NotificationTargets returns copies of targets with their credentials
+// masked
+func NotificationTargets(targets []pkgtypes.NotificationTarget) []pkgtypes.NotificationTarget {
+	redacted := make([]pkgtypes.NotificationTarget, 0, len(targets))
+	for _, target := range targets {
+		redacted = append(redacted, NotificationTarget(target))
+	}
+
+	return redacted
+}
+
+func gitAuth(auth *pkgtypes.GitAuth) {
+	mask(&auth.Token)
+	mask(&auth.PrivateKey)
+}
+
+func mask(value *string) {
+	if *value != "" {
+		*value = Mask
+	}
+}
@@ -0,0 +1,69 @@
+package redact
+
+import (
+	"testing"
+
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+)
+
+func TestCluster(t *testing.T) {
+	cl := pkgtypes.Cluster{
+		ClusterName:    "dev",
+		AWSAuth:        pkgtypes.AWSAuth{AccessKeyID: "AKIA", SecretAccessKey: "secret"},
+		GitAuth:        pkgtypes.GitAuth{Owner: "kubefirst", Token: "ghp_token", PrivateKey: "-----BEGIN"},
+		VaultAuth:      pkgtypes.VaultAuth{RootToken: "hvs.root"},
+		ArgoCDPassword: "password",
+		WorkloadClusters: []pkgtypes.WorkloadCluster{
+			{ClusterName: "workload", GitAuth: pkgtypes.GitAuth{Token: "ghp_workload"}},
+		},
+	}
+
+	redacted := Cluster(cl)
+
+	for name, got := range map[string]string{
+		"aws secret access key": redacted.AWSAuth.SecretAccessKey,
+		"git token":             redacted.GitAuth.Token,
+		"git private key":       redacted.GitAuth.PrivateKey,
+		"vault root token":      redacted.VaultAuth.RootToken,
+		"argocd password":       redacted.ArgoCDPassword,
+		"workload git token":    redacted.WorkloadClusters[0].GitAuth.Token,
+	} {
+		if got != Mask {
+			t.Errorf("expected %s to be masked, got %q", name, got)
+		}
+	}
+
+	if redacted.AWSAuth.AccessKeyID != "AKIA" || redacted.GitAuth.Owner != "kubefirst" {
+		t.Errorf("expected identifiers to be kept, got %q and %q", redacted.AWSAuth.AccessKeyID, redacted.GitAuth.Owner)
+	}
+	if redacted.CivoAuth.Token != "" {
+		t.Errorf("expected empty credentials to stay empty, got %q", redacted.CivoAuth.Token)
+	}
+	if cl.GitAuth.Token != "ghp_token" || cl.WorkloadClusters[0].GitAuth.Token != "ghp_workload" {
+		t.Error("expected the original cluster to be left untouched")
+	}
+}
+
+func TestNotificationTarget(t *testing.T) {
+	target := pkgtypes.NotificationTarget{
+		Name:          "platform",
+		Type:          "slack",
+		URL:           "https://hooks.slack.com/services/T000/B000/XXXX",
+		SigningSecret: "secret",
+	}
+
+	redacted := NotificationTarget(target)
+
+	if redacted.URL != "https://hooks.slack.com/"+Mask {
+		t.Errorf("expected the webhook path to be masked, got %q", redacted.URL)
+	}
+	if redacted.SigningSecret != Mask {
+		t.Errorf("expected the signing secret to be masked, got %q", redacted.SigningSecret)
+	}
+	if redacted.RoutingKey != "" {
+		t.Errorf("expected the empty routing key to stay empty, got %q", redacted.RoutingKey)
+	}
+	if target.URL != "https://hooks.slack.com/services/T000/B000/XXXX" {
+		t.Error("expected the original target to be left untouched")
+	}
+}
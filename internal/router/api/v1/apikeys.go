@@ -0,0 +1,280 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/konstructio/kubefirst-api/internal/apikeys"
+	"github.com/konstructio/kubefirst-api/internal/middleware"
+	"github.com/konstructio/kubefirst-api/internal/secrets"
+	"github.com/konstructio/kubefirst-api/internal/types"
+	"github.com/konstructio/kubefirst-api/internal/utils"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+	log "github.com/rs/zerolog/log"
+)
+
+// GetAPIKeys godoc
+//
+//	@Summary		Return all API keys
+//	@Description	Return the name, scopes and expiry of all API keys. The keys themselves are never returned.
+//	@Tags			apikeys
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]pkgtypes.APIKey
+//	@Failure		400	{object}	types.JSONFailureResponse
+//	@Router			/apikeys [get]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//
+// GetAPIKeys returns all API keys
+func GetAPIKeys(c *gin.Context) {
+	kcfg := utils.GetKubernetesClient("")
+
+	allAPIKeys, err := secrets.GetAPIKeys(kcfg.Clientset)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	for i := range allAPIKeys {
+		allAPIKeys[i].HashedKey = ""
+	}
+
+	c.JSON(http.StatusOK, allAPIKeys)
+}
+
+// PostAPIKey godoc
+//
+//	@Summary		Create an API key
+//	@Description	Create a named API key with a set of scopes and an optional expiry. The key is only returned in this response.
+//	@Tags			apikeys
+//	@Accept			json
+//	@Produce		json
+//	@Param			definition	body		pkgtypes.APIKeyRequest	true	"API key definition"
+//	@Success		201			{object}	pkgtypes.APIKeyResponse
+//	@Failure		400			{object}	types.JSONFailureResponse
+//	@Failure		403			{object}	types.JSONFailureResponse
+//	@Failure		409			{object}	types.JSONFailureResponse
+//	@Router			/apikeys [post]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//
+// PostAPIKey creates an API key
+func PostAPIKey(c *gin.Context) {
+	var apiKeyRequest pkgtypes.APIKeyRequest
+	if err := c.Bind(&apiKeyRequest); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	now := time.Now().UTC()
+	if err := apikeys.ValidateRequest(&apiKeyRequest, now); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if !callerHoldsScopes(c, apiKeyRequest.Scopes) {
+		return
+	}
+
+	kcfg := utils.GetKubernetesClient("")
+
+	_, err := secrets.GetAPIKey(kcfg.Clientset, apiKeyRequest.Name)
+	if err == nil {
+		c.JSON(http.StatusConflict, types.JSONFailureResponse{
+			Message: fmt.Sprintf("api key %q already exists", apiKeyRequest.Name),
+		})
+		return
+	}
+	if !errors.Is(err, &secrets.APIKeyNotFoundError{}) {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	key, hashedKey, err := apikeys.Generate(apiKeyRequest.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	apiKey := pkgtypes.APIKey{
+		Name:              apiKeyRequest.Name,
+		HashedKey:         hashedKey,
+		Scopes:            apiKeyRequest.Scopes,
+		ExpiresAt:         apiKeyRequest.ExpiresAt,
+		CreationTimestamp: now.Format(time.RFC3339),
+	}
+	if err := secrets.InsertAPIKey(kcfg.Clientset, apiKey); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	log.Info().Msgf("created api key %q with scopes %v", apiKey.Name, apiKey.Scopes)
+
+	apiKey.HashedKey = ""
+	c.JSON(http.StatusCreated, pkgtypes.APIKeyResponse{APIKey: apiKey, Key: key})
+}
+
+// PostRotateAPIKey godoc
+//
+//	@Summary		Rotate an API key
+//	@Description	Replace the key of an API key, keeping its scopes and expiry. The previous key stops working immediately and the new key is only returned in this response.
+//	@Tags			apikeys
+//	@Accept			json
+//	@Produce		json
+//	@Param			apikey_name	path		string	true	"API key name"
+//	@Success		200			{object}	pkgtypes.APIKeyResponse
+//	@Failure		400			{object}	types.JSONFailureResponse
+//	@Failure		403			{object}	types.JSONFailureResponse
+//	@Failure		404			{object}	types.JSONFailureResponse
+//	@Router			/apikeys/:apikey_name/rotate [post]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//
+// PostRotateAPIKey replaces the key of an API key
+func PostRotateAPIKey(c *gin.Context) {
+	name, param := c.Params.Get("apikey_name")
+	if !param {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: ":apikey_name not provided",
+		})
+		return
+	}
+
+	kcfg := utils.GetKubernetesClient("")
+
+	apiKey, err := secrets.GetAPIKey(kcfg.Clientset, name)
+	if err != nil {
+		if errors.Is(err, &secrets.APIKeyNotFoundError{}) {
+			c.JSON(http.StatusNotFound, types.JSONFailureResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if !callerHoldsScopes(c, apiKey.Scopes) {
+		return
+	}
+
+	key, hashedKey, err := apikeys.Generate(apiKey.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	apiKey.HashedKey = hashedKey
+	apiKey.LastRotatedAt = time.Now().UTC().Format(time.RFC3339)
+	if err := secrets.UpdateAPIKey(kcfg.Clientset, *apiKey); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	log.Info().Msgf("rotated api key %q", apiKey.Name)
+
+	apiKey.HashedKey = ""
+	c.JSON(http.StatusOK, pkgtypes.APIKeyResponse{APIKey: *apiKey, Key: key})
+}
+
+// DeleteAPIKey godoc
+//
+//	@Summary		Delete an API key
+//	@Description	Delete an API key. Requests using it are rejected immediately.
+//	@Tags			apikeys
+//	@Accept			json
+//	@Produce		json
+//	@Param			apikey_name	path		string	true	"API key name"
+//	@Success		200			{object}	types.JSONSuccessResponse
+//	@Failure		400			{object}	types.JSONFailureResponse
+//	@Failure		404			{object}	types.JSONFailureResponse
+//	@Router			/apikeys/:apikey_name [delete]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//
+// DeleteAPIKey deletes an API key
+func DeleteAPIKey(c *gin.Context) {
+	name, param := c.Params.Get("apikey_name")
+	if !param {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: ":apikey_name not provided",
+		})
+		return
+	}
+
+	kcfg := utils.GetKubernetesClient("")
+
+	if _, err := secrets.GetAPIKey(kcfg.Clientset, name); err != nil {
+		if errors.Is(err, &secrets.APIKeyNotFoundError{}) {
+			c.JSON(http.StatusNotFound, types.JSONFailureResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := secrets.DeleteAPIKey(kcfg.Clientset, name); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	log.Info().Msgf("deleted api key %q", name)
+
+	c.JSON(http.StatusOK, types.JSONSuccessResponse{
+		Message: fmt.Sprintf("api key %q deleted", name),
+	})
+}
+
+// callerHoldsScopes responds with 403 and returns false when the caller does
+// not hold every scope of the API key it is creating or rotating
+func callerHoldsScopes(c *gin.Context, scopes []string) bool {
+	user, err := middleware.GetAuthorizedUser(c)
+	if err != nil {
+		c.JSON(http.StatusForbidden, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return false
+	}
+
+	if missing := apikeys.MissingScopes(scopes, user.Scopes); len(missing) > 0 {
+		c.JSON(http.StatusForbidden, types.JSONFailureResponse{
+			Message: fmt.Sprintf("%q cannot grant scopes it does not hold: %s", user.Name, strings.Join(missing, ", ")),
+		})
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,86 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/konstructio/kubefirst-api/internal/audit"
+	"github.com/konstructio/kubefirst-api/internal/types"
+	"github.com/konstructio/kubefirst-api/internal/utils"
+)
+
+// GetAudit godoc
+//
+//	@Summary		Return audit entries
+//	@Description	Return the audit entries of mutating API calls, newest first
+//	@Tags			audit
+//	@Accept			json
+//	@Produce		json
+//	@Param			actor	query		string	false	"API key or OIDC user that made the call"
+//	@Param			method	query		string	false	"HTTP method of the call"
+//	@Param			cluster	query		string	false	"Cluster the call targeted"
+//	@Param			outcome	query		string	false	"success, failure or denied"
+//	@Param			since	query		string	false	"RFC 3339 timestamp of the oldest entry"
+//	@Param			until	query		string	false	"RFC 3339 timestamp of the newest entry"
+//	@Param			limit	query		int		false	"Maximum number of entries, 100 by default"
+//	@Success		200		{object}	[]pkgtypes.AuditEntry
+//	@Failure		400		{object}	types.JSONFailureResponse
+//	@Router			/audit [get]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//
+// GetAudit returns audit entries matching the query filters
+func GetAudit(c *gin.Context) {
+	filter := audit.Filter{
+		Actor:   c.Query("actor"),
+		Method:  c.Query("method"),
+		Cluster: c.Query("cluster"),
+		Outcome: c.Query("outcome"),
+	}
+
+	for param, value := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if c.Query(param) == "" {
+			continue
+		}
+
+		parsed, err := time.Parse(time.RFC3339, c.Query(param))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+				Message: fmt.Sprintf("%s must be an RFC 3339 timestamp: %s", param, err),
+			})
+			return
+		}
+		*value = parsed
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 || parsed > audit.MaxLimit {
+			c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+				Message: fmt.Sprintf("limit must be a number between 1 and %d", audit.MaxLimit),
+			})
+			return
+		}
+		filter.Limit = parsed
+	}
+
+	kcfg := utils.GetKubernetesClient("")
+
+	entries, err := audit.Query(kcfg.Clientset, filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
@@ -7,7 +7,6 @@ See the LICENSE file for more details.
 package api
 
 import (
-	"context"
 	"net/http"
 
 	"github.com/civo/civogo"
@@ -52,7 +51,7 @@ func GetValidateCivoDomain(c *gin.Context) {
 	// Run validate func
 	civoConf := civo.Configuration{
 		Client:  &civogo.Client{},
-		Context: context.Background(),
+		Context: c.Request.Context(),
 	}
 
 	domainID, err := civoConf.GetDNSInfo(domainName)
@@ -11,30 +11,34 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/mail"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	civoruntime "github.com/konstructio/kubefirst-api/internal/civo"
 	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/controller"
 	digioceanruntime "github.com/konstructio/kubefirst-api/internal/digitalocean"
 	"github.com/konstructio/kubefirst-api/internal/env"
 	environments "github.com/konstructio/kubefirst-api/internal/environments"
+	"github.com/konstructio/kubefirst-api/internal/export"
 	"github.com/konstructio/kubefirst-api/internal/gitShim"
+	"github.com/konstructio/kubefirst-api/internal/jobs"
 	"github.com/konstructio/kubefirst-api/internal/k8s"
+	"github.com/konstructio/kubefirst-api/internal/middleware"
+	"github.com/konstructio/kubefirst-api/internal/rbac"
+	"github.com/konstructio/kubefirst-api/internal/redact"
 	"github.com/konstructio/kubefirst-api/internal/secrets"
 	"github.com/konstructio/kubefirst-api/internal/services"
 	"github.com/konstructio/kubefirst-api/internal/types"
 	"github.com/konstructio/kubefirst-api/internal/utils"
 	vultrruntime "github.com/konstructio/kubefirst-api/internal/vultr"
 	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
-	"github.com/konstructio/kubefirst-api/providers/akamai"
-	"github.com/konstructio/kubefirst-api/providers/aws"
-	"github.com/konstructio/kubefirst-api/providers/civo"
-	"github.com/konstructio/kubefirst-api/providers/digitalocean"
-	"github.com/konstructio/kubefirst-api/providers/google"
-	"github.com/konstructio/kubefirst-api/providers/k3s"
-	"github.com/konstructio/kubefirst-api/providers/vultr"
-	"github.com/kubefirst/metrics-client/pkg/telemetry"
+	"github.com/konstructio/kubefirst-api/providers"
 	log "github.com/rs/zerolog/log"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
@@ -47,13 +51,654 @@ import (
 //	@Accept			json
 //	@Produce		json
 //	@Param			cluster_name	path		string	true	"Cluster name"
-//	@Success		202				{object}	types.JSONSuccessResponse
+//	@Success		202				{object}	types.JobResponse
+//	@Failure		400				{object}	types.JSONFailureResponse
+//	@Failure		409				{object}	types.JSONFailureResponse
+//	@Failure		412				{object}	types.JSONFailureResponse
+//	@Router			/cluster/:cluster_name [delete]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//	@Param			If-Match		header	string	false	"ETag of the cluster the request was made against"
+//
+// DeleteCluster handles a request to delete a cluster
+func DeleteCluster(c *gin.Context) {
+	clusterName, param := c.Params.Get("cluster_name")
+	if !param {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: ":cluster_name not provided",
+		})
+		return
+	}
+
+	if !authorize(c, rbac.ActionDelete, rbac.Target{Cluster: clusterName}) {
+		return
+	}
+
+	kcfg := utils.GetKubernetesClient(clusterName)
+
+	// Delete cluster
+	rec, err := secrets.GetCluster(kcfg.Clientset, clusterName)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	switch rec.CloudProvider {
+	case "akamai", "aws", "civo", "digitalocean", "google", "k3s", "vultr":
+	default:
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: fmt.Sprintf("cloud provider %q does not support cluster delete", rec.CloudProvider),
+		})
+		return
+	}
+
+	// A delete cannot run next to another delete, or next to a create
+	// applying the same terraform state
+	for _, jobType := range []string{constants.JobTypeClusterDelete, constants.JobTypeClusterCreate} {
+		activeJob, err := jobs.Active(kcfg.Clientset, clusterName, jobType)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+		if activeJob != nil {
+			c.JSON(http.StatusConflict, types.JSONFailureResponse{
+				Message: fmt.Sprintf("%s already has %s job %s in progress", clusterName, strings.ReplaceAll(jobType, "_", " "), activeJob.ID),
+			})
+			return
+		}
+	}
+
+	telemetryEvent := providers.DeleteTelemetryEvent(rec)
+
+	updated, err := secrets.UpdateClusterWith(kcfg.Clientset, clusterName, func(cl *pkgtypes.Cluster) error {
+		if err := secrets.CheckResourceVersion(fmt.Sprintf("cluster %q", clusterName), cl.ResourceVersion, ifMatch(c)); err != nil {
+			return err
+		}
+
+		cl.LastCondition = ""
+		if cl.Status == constants.ClusterStatusError || cl.Status == constants.ClusterStatusCancelled {
+			cl.Status = constants.ClusterStatusDeleting
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, &secrets.PreconditionFailedError{}) {
+			c.JSON(http.StatusPreconditionFailed, types.JSONFailureResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		log.Warn().Msgf("error updating cluster status field: %s", err)
+	} else {
+		rec = updated
+	}
+
+	job, err := jobs.Enqueue(kcfg.Clientset, jobs.NewJob(c.Request.Context(), constants.JobTypeClusterDelete, clusterName, ""), func(ctx context.Context, _ *pkgtypes.Job) error {
+		return providers.DeleteCluster(ctx, rec, telemetryEvent)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, types.JobResponse{
+		Message: "cluster delete enqueued",
+		JobID:   job.ID,
+	})
+}
+
+// PostRetryCluster godoc
+//
+//	@Summary		Retry steps of a Kubefirst cluster create
+//	@Description	Re-run the create pipeline from a given step onward, or only the given steps. The check flags of the selected steps are reset before the run is enqueued.
+//	@Tags			cluster
+//	@Accept			json
+//	@Produce		json
+//	@Param			cluster_name	path		string							true	"Cluster name"
+//	@Param			definition		body		pkgtypes.ClusterRetryRequest	true	"Steps to retry"
+//	@Success		202				{object}	types.JobResponse
+//	@Failure		400				{object}	types.JSONFailureResponse
+//	@Failure		404				{object}	types.JSONFailureResponse
+//	@Failure		412				{object}	types.JSONFailureResponse
+//	@Router			/cluster/:cluster_name/retry [post]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//	@Param			If-Match		header	string	false	"ETag of the cluster the request was made against"
+//
+// PostRetryCluster handles a request to retry steps of a cluster create
+func PostRetryCluster(c *gin.Context) {
+	clusterName, param := c.Params.Get("cluster_name")
+	if !param {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: ":cluster_name not provided",
+		})
+		return
+	}
+
+	if !authorize(c, rbac.ActionWrite, rbac.Target{Cluster: clusterName}) {
+		return
+	}
+
+	var retryRequest pkgtypes.ClusterRetryRequest
+	if err := c.Bind(&retryRequest); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	kcfg := utils.GetKubernetesClient(clusterName)
+
+	cluster, err := secrets.GetCluster(kcfg.Clientset, clusterName)
+	if err != nil {
+		if errors.Is(err, &secrets.ClusterNotFoundError{}) {
+			c.JSON(http.StatusNotFound, types.JSONFailureResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if !checkIfMatch(c, fmt.Sprintf("cluster %q", clusterName), cluster.ResourceVersion) {
+		return
+	}
+
+	switch {
+	case cluster.InProgress:
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: fmt.Sprintf("%s has an active process running and cannot be retried", clusterName),
+		})
+		return
+	case cluster.Status == constants.ClusterStatusDeleted || cluster.Status == constants.ClusterStatusDeleting:
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: fmt.Sprintf("%s is in %q state and cannot be retried", clusterName, cluster.Status),
+		})
+		return
+	}
+
+	activeJob, err := jobs.Active(kcfg.Clientset, clusterName, constants.JobTypeClusterCreate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+	if activeJob != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: fmt.Sprintf("%s already has cluster create job %s in progress", clusterName, activeJob.ID),
+		})
+		return
+	}
+
+	steps, err := providers.Pipeline(cluster.CloudProvider)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	selected, err := controller.SelectRetrySteps(steps, cluster, retryRequest.FromStep, retryRequest.OnlySteps)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	cluster, err = secrets.UpdateClusterWith(kcfg.Clientset, clusterName, func(cl *pkgtypes.Cluster) error {
+		if err := secrets.CheckResourceVersion(fmt.Sprintf("cluster %q", clusterName), cl.ResourceVersion, ifMatch(c)); err != nil {
+			return err
+		}
+		controller.ResetSteps(cl, selected)
+		return nil
+	})
+	if err != nil {
+		c.JSON(preconditionStatus(err, http.StatusInternalServerError), types.JSONFailureResponse{
+			Message: fmt.Sprintf("error resetting steps for cluster %s: %s", clusterName, err),
+		})
+		return
+	}
+
+	job, err := jobs.Enqueue(kcfg.Clientset, jobs.NewJob(c.Request.Context(), constants.JobTypeClusterCreate, clusterName, ""), func(ctx context.Context, _ *pkgtypes.Job) error {
+		return providers.RetryCluster(ctx, cluster, selected)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, types.JobResponse{
+		Message: fmt.Sprintf("cluster retry enqueued for steps %s", strings.Join(selected, ", ")),
+		JobID:   job.ID,
+	})
+}
+
+// PatchClusterNodes godoc
+//
+//	@Summary		Change the node pool of a Kubefirst cluster
+//	@Description	Scale the node pool or change its node type on a provisioned cluster. The terraform variables in the gitops repository are updated and applied by the api, or proposed in a pull request for Atlantis.
+//	@Tags			cluster
+//	@Accept			json
+//	@Produce		json
+//	@Param			cluster_name	path		string							true	"Cluster name"
+//	@Param			definition		body		pkgtypes.ClusterNodesRequest	true	"Node changes"
+//	@Success		202				{object}	types.JobResponse
+//	@Failure		400				{object}	types.JSONFailureResponse
+//	@Failure		404				{object}	types.JSONFailureResponse
+//	@Failure		412				{object}	types.JSONFailureResponse
+//	@Router			/cluster/:cluster_name/nodes [patch]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//	@Param			If-Match		header	string	false	"ETag of the cluster the request was made against"
+//
+// PatchClusterNodes handles a request to change the node pool of a cluster
+func PatchClusterNodes(c *gin.Context) {
+	clusterName, param := c.Params.Get("cluster_name")
+	if !param {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: ":cluster_name not provided",
+		})
+		return
+	}
+
+	if !authorize(c, rbac.ActionWrite, rbac.Target{Cluster: clusterName}) {
+		return
+	}
+
+	var nodesRequest pkgtypes.ClusterNodesRequest
+	if err := c.Bind(&nodesRequest); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	kcfg := utils.GetKubernetesClient(clusterName)
+
+	cluster, err := secrets.GetCluster(kcfg.Clientset, clusterName)
+	if err != nil {
+		if errors.Is(err, &secrets.ClusterNotFoundError{}) {
+			c.JSON(http.StatusNotFound, types.JSONFailureResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if !checkIfMatch(c, fmt.Sprintf("cluster %q", clusterName), cluster.ResourceVersion) {
+		return
+	}
+
+	switch {
+	case cluster.InProgress:
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: fmt.Sprintf("%s has an active process running and its nodes cannot be changed", clusterName),
+		})
+		return
+	case cluster.Status != constants.ClusterStatusProvisioned:
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: fmt.Sprintf("%s is in %q state, nodes can only be changed on a provisioned cluster", clusterName, cluster.Status),
+		})
+		return
+	}
+
+	activeJob, err := jobs.Active(kcfg.Clientset, clusterName, constants.JobTypeClusterNodes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+	if activeJob != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: fmt.Sprintf("%s already has node change job %s in progress", clusterName, activeJob.ID),
+		})
+		return
+	}
+
+	if err := controller.ValidateNodesRequest(c.Request.Context(), cluster, &nodesRequest); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	job, err := jobs.Enqueue(kcfg.Clientset, jobs.NewJob(c.Request.Context(), constants.JobTypeClusterNodes, clusterName, ""), func(ctx context.Context, _ *pkgtypes.Job) error {
+		return providers.UpdateClusterNodes(ctx, cluster, &nodesRequest)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, types.JobResponse{
+		Message: "cluster node change enqueued",
+		JobID:   job.ID,
+	})
+}
+
+// GetClusterDrift godoc
+//
+//	@Summary		Return the drift of a Kubefirst cluster
+//	@Description	Return the result of the last drift detection run, which plans the cloud, git, vault and users terraform and lists the ArgoCD applications that are out of sync
+//	@Tags			cluster
+//	@Accept			json
+//	@Produce		json
+//	@Param			cluster_name	path		string	true	"Cluster name"
+//	@Success		200				{object}	pkgtypes.ClusterDrift
+//	@Failure		400				{object}	types.JSONFailureResponse
+//	@Failure		404				{object}	types.JSONFailureResponse
+//	@Router			/cluster/:cluster_name/drift [get]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//
+// GetClusterDrift returns the drift recorded on a cluster
+func GetClusterDrift(c *gin.Context) {
+	clusterName, param := c.Params.Get("cluster_name")
+	if !param {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: ":cluster_name not provided",
+		})
+		return
+	}
+
+	kcfg := utils.GetKubernetesClient(clusterName)
+
+	cluster, err := secrets.GetCluster(kcfg.Clientset, clusterName)
+	if err != nil {
+		if errors.Is(err, &secrets.ClusterNotFoundError{}) {
+			c.JSON(http.StatusNotFound, types.JSONFailureResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if cluster.Drift == nil {
+		c.JSON(http.StatusNotFound, types.JSONFailureResponse{
+			Message: fmt.Sprintf("%s has not been checked for drift yet", clusterName),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, cluster.Drift)
+}
+
+// GetClusterUpgrade godoc
+//
+//	@Summary		List the Kubernetes versions a cluster can be upgraded to
+//	@Description	Return the control plane version of a cluster and the versions offered by its cloud provider in its region that it can be upgraded to
+//	@Tags			cluster
+//	@Accept			json
+//	@Produce		json
+//	@Param			cluster_name	path		string	true	"Cluster name"
+//	@Success		200				{object}	pkgtypes.ClusterUpgradeVersions
+//	@Failure		400				{object}	types.JSONFailureResponse
+//	@Failure		404				{object}	types.JSONFailureResponse
+//	@Router			/cluster/:cluster_name/upgrade [get]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//
+// GetClusterUpgrade returns the Kubernetes versions a cluster can be upgraded to
+func GetClusterUpgrade(c *gin.Context) {
+	clusterName, param := c.Params.Get("cluster_name")
+	if !param {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: ":cluster_name not provided",
+		})
+		return
+	}
+
+	kcfg := utils.GetKubernetesClient(clusterName)
+
+	cluster, err := secrets.GetCluster(kcfg.Clientset, clusterName)
+	if err != nil {
+		if errors.Is(err, &secrets.ClusterNotFoundError{}) {
+			c.JSON(http.StatusNotFound, types.JSONFailureResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	versions, err := controller.ListUpgradeVersions(c.Request.Context(), cluster)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, versions)
+}
+
+// PostUpgradeCluster godoc
+//
+//	@Summary		Upgrade the Kubernetes version of a Kubefirst cluster
+//	@Description	Upgrade the control plane of a provisioned cluster by one minor version at most. The version in the gitops repository is updated and applied, and the upgrade completes once every node runs the new version.
+//	@Tags			cluster
+//	@Accept			json
+//	@Produce		json
+//	@Param			cluster_name	path		string							true	"Cluster name"
+//	@Param			definition		body		pkgtypes.ClusterUpgradeRequest	true	"Target Kubernetes version"
+//	@Success		202				{object}	types.JobResponse
+//	@Failure		400				{object}	types.JSONFailureResponse
+//	@Failure		404				{object}	types.JSONFailureResponse
+//	@Failure		412				{object}	types.JSONFailureResponse
+//	@Router			/cluster/:cluster_name/upgrade [post]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//	@Param			If-Match		header	string	false	"ETag of the cluster the request was made against"
+//
+// PostUpgradeCluster handles a request to upgrade the Kubernetes version of a cluster
+func PostUpgradeCluster(c *gin.Context) {
+	clusterName, param := c.Params.Get("cluster_name")
+	if !param {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: ":cluster_name not provided",
+		})
+		return
+	}
+
+	if !authorize(c, rbac.ActionWrite, rbac.Target{Cluster: clusterName}) {
+		return
+	}
+
+	var upgradeRequest pkgtypes.ClusterUpgradeRequest
+	if err := c.Bind(&upgradeRequest); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	kcfg := utils.GetKubernetesClient(clusterName)
+
+	cluster, err := secrets.GetCluster(kcfg.Clientset, clusterName)
+	if err != nil {
+		if errors.Is(err, &secrets.ClusterNotFoundError{}) {
+			c.JSON(http.StatusNotFound, types.JSONFailureResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if !checkIfMatch(c, fmt.Sprintf("cluster %q", clusterName), cluster.ResourceVersion) {
+		return
+	}
+
+	switch {
+	case cluster.InProgress:
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: fmt.Sprintf("%s has an active process running and cannot be upgraded", clusterName),
+		})
+		return
+	case cluster.Status != constants.ClusterStatusProvisioned:
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: fmt.Sprintf("%s is in %q state, only a provisioned cluster can be upgraded", clusterName, cluster.Status),
+		})
+		return
+	}
+
+	activeJob, err := jobs.Active(kcfg.Clientset, clusterName, constants.JobTypeClusterUpgrade)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+	if activeJob != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: fmt.Sprintf("%s already has upgrade job %s in progress", clusterName, activeJob.ID),
+		})
+		return
+	}
+
+	versions, err := controller.ListUpgradeVersions(c.Request.Context(), cluster)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+	if err := controller.ValidateVersionSkew(versions.CurrentVersion, upgradeRequest.KubernetesVersion); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+	if !slices.Contains(versions.AvailableVersions, upgradeRequest.KubernetesVersion) {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: fmt.Sprintf("kubernetes %s is not available for %s, choose one of: %s", upgradeRequest.KubernetesVersion, clusterName, strings.Join(versions.AvailableVersions, ", ")),
+		})
+		return
+	}
+
+	job, err := jobs.Enqueue(kcfg.Clientset, jobs.NewJob(c.Request.Context(), constants.JobTypeClusterUpgrade, clusterName, ""), func(ctx context.Context, _ *pkgtypes.Job) error {
+		return providers.UpgradeCluster(ctx, cluster, upgradeRequest.KubernetesVersion)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, types.JobResponse{
+		Message: fmt.Sprintf("cluster upgrade to kubernetes %s enqueued", upgradeRequest.KubernetesVersion),
+		JobID:   job.ID,
+	})
+}
+
+// PostCancelCluster godoc
+//
+//	@Summary		Cancel an in-flight Kubefirst cluster create
+//	@Description	Stop a running cluster create. Any running terraform process is interrupted and the cluster is left in the cancelled state so it can be retried or deleted.
+//	@Tags			cluster
+//	@Accept			json
+//	@Produce		json
+//	@Param			cluster_name	path		string	true	"Cluster name"
+//	@Success		202				{object}	types.JobResponse
+//	@Failure		400				{object}	types.JSONFailureResponse
+//	@Failure		409				{object}	types.JSONFailureResponse
+//	@Router			/cluster/:cluster_name/cancel [post]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//
+// PostCancelCluster handles a request to cancel a cluster create
+func PostCancelCluster(c *gin.Context) {
+	clusterName, param := c.Params.Get("cluster_name")
+	if !param {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: ":cluster_name not provided",
+		})
+		return
+	}
+
+	if !authorize(c, rbac.ActionWrite, rbac.Target{Cluster: clusterName}) {
+		return
+	}
+
+	kcfg := utils.GetKubernetesClient(clusterName)
+
+	job, err := jobs.Active(kcfg.Clientset, clusterName, constants.JobTypeClusterCreate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if job == nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: fmt.Sprintf("%s has no cluster create in progress", clusterName),
+		})
+		return
+	}
+
+	if !jobs.Cancel(job.ID) {
+		c.JSON(http.StatusConflict, types.JSONFailureResponse{
+			Message: fmt.Sprintf("cluster create job %s for %s is not running on this instance (owner: %s)", job.ID, clusterName, job.Owner),
+		})
+		return
+	}
+
+	log.Info().Msgf("cancellation requested for cluster create job %s on %s", job.ID, clusterName)
+	c.JSON(http.StatusAccepted, types.JobResponse{
+		Message: "cluster create cancellation requested",
+		JobID:   job.ID,
+	})
+}
+
+// GetCluster godoc
+//
+//	@Summary		Return a configured Kubefirst cluster
+//	@Description	Return a configured Kubefirst cluster
+//	@Tags			cluster
+//	@Accept			json
+//	@Produce		json
+//	@Param			cluster_name	path		string	true	"Cluster name"
+//	@Param			include_secrets	query		bool	false	"Return credentials unmasked, requires the secrets:admin scope"
+//	@Success		200				{object}	pkgtypes.Cluster
+//	@Header			200				{string}	ETag	"Version of the cluster, to send in If-Match"
 //	@Failure		400				{object}	types.JSONFailureResponse
-//	@Router			/cluster/:cluster_name [delete]
+//	@Failure		403				{object}	types.JSONFailureResponse
+//	@Router			/cluster/:cluster_name [get]
 //	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
 //
-// DeleteCluster handles a request to delete a cluster
-func DeleteCluster(c *gin.Context) {
+// GetCluster returns a specific configured cluster
+func GetCluster(c *gin.Context) {
 	clusterName, param := c.Params.Get("cluster_name")
 	if !param {
 		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
@@ -62,125 +707,132 @@ func DeleteCluster(c *gin.Context) {
 		return
 	}
 
+	withSecrets, ok := includeSecrets(c)
+	if !ok {
+		return
+	}
+
 	kcfg := utils.GetKubernetesClient(clusterName)
 
-	// Delete cluster
-	rec, err := secrets.GetCluster(kcfg.Clientset, clusterName)
+	// Retrieve cluster info
+	cluster, err := secrets.GetCluster(kcfg.Clientset, clusterName)
 	if err != nil {
+		if errors.Is(err, &secrets.ClusterNotFoundError{}) {
+			c.JSON(http.StatusNotFound, types.JSONFailureResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
 		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
-			Message: err.Error(),
+			Message: "unable to find cluster: " + err.Error(),
 		})
 		return
 	}
 
-	env, _ := env.GetEnv(constants.SilenceGetEnv)
-
-	telemetryEvent := telemetry.TelemetryEvent{
-		CliVersion:        env.KubefirstVersion,
-		CloudProvider:     rec.CloudProvider,
-		ClusterID:         rec.ClusterID,
-		ClusterType:       rec.ClusterType,
-		DomainName:        rec.DomainName,
-		GitProvider:       rec.GitProvider,
-		InstallMethod:     "",
-		KubefirstClient:   "api",
-		KubefirstTeam:     env.KubefirstTeam,
-		KubefirstTeamInfo: env.KubefirstTeamInfo,
-		MachineID:         rec.DomainName,
-		ErrorMessage:      "",
-		UserId:            rec.DomainName,
-		MetricName:        telemetry.ClusterDeleteStarted,
-	}
+	setETag(c, cluster.ResourceVersion)
 
-	if rec.LastCondition != "" {
-		rec.LastCondition = ""
-		err = secrets.UpdateCluster(kcfg.Clientset, *rec)
-		if err != nil {
-			log.Warn().Msgf("error updating cluster last_condition field: %s", err)
-		}
-	}
-	if rec.Status == constants.ClusterStatusError {
-		rec.Status = constants.ClusterStatusDeleting
-		err = secrets.UpdateCluster(kcfg.Clientset, *rec)
-		if err != nil {
-			log.Warn().Msgf("error updating cluster status field: %s", err)
-		}
+	if withSecrets {
+		c.JSON(http.StatusOK, cluster)
+		return
 	}
 
-	switch rec.CloudProvider {
-	case "aws":
-		go func() {
-			err := aws.DeleteAWSCluster(rec, telemetryEvent)
-			if err != nil {
-				log.Error().Msg(err.Error())
-			}
-		}()
+	c.JSON(http.StatusOK, redact.Cluster(*cluster))
+}
 
-		c.JSON(http.StatusAccepted, types.JSONSuccessResponse{
-			Message: "cluster delete enqueued",
+// PutCluster godoc
+//
+//	@Summary		Update a Kubefirst cluster
+//	@Description	Update the fields of a cluster that can be changed once it has been created
+//	@Tags			cluster
+//	@Accept			json
+//	@Produce		json
+//	@Param			cluster_name	path		string						true	"Cluster name"
+//	@Param			definition		body		types.ClusterUpdateRequest	true	"Cluster update in JSON format"
+//	@Success		200				{object}	types.JSONSuccessResponse
+//	@Header			200				{string}	ETag	"Version of the cluster, to send in If-Match"
+//	@Failure		400				{object}	types.JSONFailureResponse
+//	@Failure		404				{object}	types.JSONFailureResponse
+//	@Failure		412				{object}	types.JSONFailureResponse
+//	@Router			/cluster/:cluster_name [put]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//	@Param			If-Match		header	string	false	"ETag of the cluster the request was made against"
+//
+// PutCluster updates the fields of a cluster that can be changed once it has
+// been created
+func PutCluster(c *gin.Context) {
+	clusterName, param := c.Params.Get("cluster_name")
+	if !param {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: ":cluster_name not provided",
 		})
-	case "civo":
-		go func() {
-			err := civo.DeleteCivoCluster(rec, telemetryEvent)
-			if err != nil {
-				log.Error().Msg(err.Error())
-			}
-		}()
+		return
+	}
 
-		c.JSON(http.StatusAccepted, types.JSONSuccessResponse{
-			Message: "cluster delete enqueued",
+	var clusterUpdate types.ClusterUpdateRequest
+	if err := c.Bind(&clusterUpdate); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
 		})
-	case "digitalocean":
-		go func() {
-			err := digitalocean.DeleteDigitaloceanCluster(rec, telemetryEvent)
-			if err != nil {
-				log.Error().Msg(err.Error())
-			}
-		}()
+		return
+	}
 
-		c.JSON(http.StatusAccepted, types.JSONSuccessResponse{
-			Message: "cluster delete enqueued",
+	if _, err := mail.ParseAddress(clusterUpdate.AlertsEmail); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: fmt.Sprintf("invalid alerts_email %q: %s", clusterUpdate.AlertsEmail, err),
 		})
-	case "vultr":
-		go func() {
-			err := vultr.DeleteVultrCluster(rec, telemetryEvent)
-			if err != nil {
-				log.Error().Msg(err.Error())
-			}
-		}()
+		return
+	}
 
-		c.JSON(http.StatusAccepted, types.JSONSuccessResponse{
-			Message: "cluster delete enqueued",
-		})
-	case "google":
-		go func() {
-			err := google.DeleteGoogleCluster(rec, telemetryEvent)
-			if err != nil {
-				log.Error().Msg(err.Error())
-			}
-		}()
+	if !authorize(c, rbac.ActionWrite, rbac.Target{Cluster: clusterName}) {
+		return
+	}
+
+	kcfg := utils.GetKubernetesClient(clusterName)
+
+	updated, err := secrets.UpdateClusterWith(kcfg.Clientset, clusterName, func(cl *pkgtypes.Cluster) error {
+		if err := secrets.CheckResourceVersion(fmt.Sprintf("cluster %q", clusterName), cl.ResourceVersion, ifMatch(c)); err != nil {
+			return err
+		}
+
+		cl.AlertsEmail = clusterUpdate.AlertsEmail
+		return nil
+	})
+	if err != nil {
+		status := preconditionStatus(err, http.StatusBadRequest)
+		if errors.Is(err, &secrets.ClusterNotFoundError{}) {
+			status = http.StatusNotFound
+		}
 
-		c.JSON(http.StatusAccepted, types.JSONSuccessResponse{
-			Message: "cluster delete enqueued",
+		c.JSON(status, types.JSONFailureResponse{
+			Message: fmt.Sprintf("error updating cluster %s: %s", clusterName, err),
 		})
+		return
 	}
+
+	setETag(c, updated.ResourceVersion)
+
+	c.JSON(http.StatusOK, types.JSONSuccessResponse{
+		Message: "cluster updated",
+	})
 }
 
-// GetCluster godoc
+// GetClusterSteps godoc
 //
-//	@Summary		Return a configured Kubefirst cluster
-//	@Description	Return a configured Kubefirst cluster
+//	@Summary		Return the create pipeline progress of a Kubefirst cluster
+//	@Description	Return each step of the cluster create pipeline with its status, timings, attempt count and last error
 //	@Tags			cluster
 //	@Accept			json
 //	@Produce		json
 //	@Param			cluster_name	path		string	true	"Cluster name"
-//	@Success		200				{object}	pkgtypes.Cluster
+//	@Success		200				{object}	[]pkgtypes.ClusterStep
 //	@Failure		400				{object}	types.JSONFailureResponse
-//	@Router			/cluster/:cluster_name [get]
+//	@Failure		404				{object}	types.JSONFailureResponse
+//	@Router			/cluster/:cluster_name/steps [get]
 //	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
 //
-// GetCluster returns a specific configured cluster
-func GetCluster(c *gin.Context) {
+// GetClusterSteps returns the create pipeline progress of a cluster
+func GetClusterSteps(c *gin.Context) {
 	clusterName, param := c.Params.Get("cluster_name")
 	if !param {
 		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
@@ -191,7 +843,6 @@ func GetCluster(c *gin.Context) {
 
 	kcfg := utils.GetKubernetesClient(clusterName)
 
-	// Retrieve cluster info
 	cluster, err := secrets.GetCluster(kcfg.Clientset, clusterName)
 	if err != nil {
 		if errors.Is(err, &secrets.ClusterNotFoundError{}) {
@@ -207,7 +858,12 @@ func GetCluster(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, cluster)
+	steps := cluster.Steps
+	if steps == nil {
+		steps = []pkgtypes.ClusterStep{}
+	}
+
+	c.JSON(http.StatusOK, steps)
 }
 
 // GetClusters godoc
@@ -217,13 +873,20 @@ func GetCluster(c *gin.Context) {
 //	@Tags			cluster
 //	@Accept			json
 //	@Produce		json
-//	@Success		200	{object}	[]pkgtypes.Cluster
-//	@Failure		400	{object}	types.JSONFailureResponse
+//	@Param			include_secrets	query		bool	false	"Return credentials unmasked, requires the secrets:admin scope"
+//	@Success		200				{object}	[]pkgtypes.Cluster
+//	@Failure		400				{object}	types.JSONFailureResponse
+//	@Failure		403				{object}	types.JSONFailureResponse
 //	@Router			/cluster [get]
 //	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
 //
 // GetClusters returns all known configured clusters
 func GetClusters(c *gin.Context) {
+	withSecrets, ok := includeSecrets(c)
+	if !ok {
+		return
+	}
+
 	kcfg := utils.GetKubernetesClient("TODO: SECRETS")
 
 	// Retrieve all clusters info
@@ -235,7 +898,32 @@ func GetClusters(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, allClusters)
+	if withSecrets {
+		c.JSON(http.StatusOK, allClusters)
+		return
+	}
+
+	c.JSON(http.StatusOK, redact.Clusters(allClusters))
+}
+
+// includeSecrets reports whether credentials were requested unmasked with
+// ?include_secrets=true. It responds with 403 and returns false for ok when
+// the caller lacks the secrets:admin scope.
+func includeSecrets(c *gin.Context) (bool, bool) {
+	requested, _ := strconv.ParseBool(c.Query("include_secrets"))
+	if !requested {
+		return false, true
+	}
+
+	user, err := middleware.GetAuthorizedUser(c)
+	if err != nil || !slices.Contains(user.Scopes, constants.ScopeSecretsAdmin) {
+		c.JSON(http.StatusForbidden, types.JSONFailureResponse{
+			Message: fmt.Sprintf("include_secrets requires the %q scope", constants.ScopeSecretsAdmin),
+		})
+		return false, false
+	}
+
+	return true, true
 }
 
 // PostCreateCluster godoc
@@ -247,7 +935,9 @@ func GetClusters(c *gin.Context) {
 //	@Produce		json
 //	@Param			cluster_name	path		string					true	"Cluster name"
 //	@Param			definition		body		types.ClusterDefinition	true	"Cluster create request in JSON format"
-//	@Success		202				{object}	types.JSONSuccessResponse
+//	@Param			dry_run			query		bool					false	"Validate and plan the create without creating anything"
+//	@Success		200				{object}	pkgtypes.DryRunReport
+//	@Success		202				{object}	types.JobResponse
 //	@Failure		400				{object}	types.JSONFailureResponse
 //	@Router			/cluster/:cluster_name [post]
 //	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
@@ -262,6 +952,10 @@ func PostCreateCluster(c *gin.Context) {
 		return
 	}
 
+	if !authorize(c, rbac.ActionWrite, rbac.Target{Cluster: clusterName}) {
+		return
+	}
+
 	// Bind to variable as application/json, handle error
 	var clusterDefinition pkgtypes.ClusterDefinition
 	err := c.Bind(&clusterDefinition)
@@ -273,6 +967,17 @@ func PostCreateCluster(c *gin.Context) {
 	}
 	clusterDefinition.ClusterName = clusterName
 
+	dryRun := false
+	if value := c.Query("dry_run"); value != "" {
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+				Message: fmt.Sprintf("invalid dry_run value %q", value),
+			})
+			return
+		}
+	}
+
 	kcfg := utils.GetKubernetesClient(clusterName)
 
 	// Create
@@ -298,19 +1003,18 @@ func PostCreateCluster(c *gin.Context) {
 			return
 		}
 
-		if cluster.LastCondition != "" {
-			cluster.LastCondition = ""
-			err = secrets.UpdateCluster(kcfg.Clientset, *cluster)
-			if err != nil {
-				log.Warn().Msgf("error updating cluster last_condition field: %s", err)
-			}
-		}
-
-		if cluster.Status == constants.ClusterStatusError {
-			cluster.Status = constants.ClusterStatusProvisioning
-			err = secrets.UpdateCluster(kcfg.Clientset, *cluster)
+		if !dryRun {
+			updated, err := secrets.UpdateClusterWith(kcfg.Clientset, clusterName, func(cl *pkgtypes.Cluster) error {
+				cl.LastCondition = ""
+				if cl.Status == constants.ClusterStatusError || cl.Status == constants.ClusterStatusCancelled {
+					cl.Status = constants.ClusterStatusProvisioning
+				}
+				return nil
+			})
 			if err != nil {
 				log.Warn().Msgf("error updating cluster status field: %s", err)
+			} else {
+				cluster = updated
 			}
 		}
 
@@ -380,17 +1084,6 @@ func PostCreateCluster(c *gin.Context) {
 			})
 			return
 		}
-
-		go func() {
-			err = akamai.CreateAkamaiCluster(&clusterDefinition)
-			if err != nil {
-				log.Error().Msg(err.Error())
-			}
-		}()
-
-		c.JSON(http.StatusAccepted, types.JSONSuccessResponse{
-			Message: "cluster create enqueued",
-		})
 	case "aws":
 		if useSecretForAuth {
 			err := utils.ValidateAuthenticationFields(k1AuthSecret)
@@ -413,16 +1106,6 @@ func PostCreateCluster(c *gin.Context) {
 			})
 			return
 		}
-		go func() {
-			err = aws.CreateAWSCluster(&clusterDefinition)
-			if err != nil {
-				log.Error().Msg(err.Error())
-			}
-		}()
-
-		c.JSON(http.StatusAccepted, types.JSONSuccessResponse{
-			Message: "cluster create enqueued",
-		})
 	case "civo":
 		if useSecretForAuth {
 			err := utils.ValidateAuthenticationFields(k1AuthSecret)
@@ -441,17 +1124,6 @@ func PostCreateCluster(c *gin.Context) {
 			})
 			return
 		}
-
-		go func() {
-			err = civo.CreateCivoCluster(&clusterDefinition)
-			if err != nil {
-				log.Error().Msg(err.Error())
-			}
-		}()
-
-		c.JSON(http.StatusAccepted, types.JSONSuccessResponse{
-			Message: "cluster create enqueued",
-		})
 	case "digitalocean":
 		if useSecretForAuth {
 			err := utils.ValidateAuthenticationFields(k1AuthSecret)
@@ -474,17 +1146,6 @@ func PostCreateCluster(c *gin.Context) {
 			})
 			return
 		}
-
-		go func() {
-			err = digitalocean.CreateDigitaloceanCluster(&clusterDefinition)
-			if err != nil {
-				log.Error().Msg(err.Error())
-			}
-		}()
-
-		c.JSON(http.StatusAccepted, types.JSONSuccessResponse{
-			Message: "cluster create enqueued",
-		})
 	case "vultr":
 		if useSecretForAuth {
 			err := utils.ValidateAuthenticationFields(k1AuthSecret)
@@ -503,17 +1164,6 @@ func PostCreateCluster(c *gin.Context) {
 			})
 			return
 		}
-
-		go func() {
-			err = vultr.CreateVultrCluster(&clusterDefinition)
-			if err != nil {
-				log.Error().Msg(err.Error())
-			}
-		}()
-
-		c.JSON(http.StatusAccepted, types.JSONSuccessResponse{
-			Message: "cluster create enqueued",
-		})
 	case "google":
 		if useSecretForAuth {
 			err := utils.ValidateAuthenticationFields(k1AuthSecret)
@@ -533,18 +1183,6 @@ func PostCreateCluster(c *gin.Context) {
 			})
 			return
 		}
-
-		go func() {
-			err = google.CreateGoogleCluster(&clusterDefinition)
-			if err != nil {
-				log.Error().Msg(err.Error())
-			}
-		}()
-
-		c.JSON(http.StatusAccepted, types.JSONSuccessResponse{
-			Message: "cluster create enqueued",
-		})
-		// }
 	case "k3s":
 		if useSecretForAuth {
 			err := utils.ValidateAuthenticationFields(k1AuthSecret)
@@ -576,31 +1214,68 @@ func PostCreateCluster(c *gin.Context) {
 			})
 			return
 		}
+	}
 
-		go func() {
-			err = k3s.CreateK3sCluster(&clusterDefinition)
-			if err != nil {
-				log.Error().Msg(err.Error())
-			}
-		}()
+	if dryRun {
+		c.JSON(http.StatusOK, providers.DryRunCluster(c.Request.Context(), &clusterDefinition))
+		return
+	}
+
+	job, err := jobs.Enqueue(kcfg.Clientset, jobs.NewJob(c.Request.Context(), constants.JobTypeClusterCreate, clusterName, ""), func(ctx context.Context, _ *pkgtypes.Job) error {
+		return providers.CreateCluster(ctx, &clusterDefinition)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
 
-		c.JSON(http.StatusAccepted, types.JSONSuccessResponse{
-			Message: "cluster create enqueued",
+	c.JSON(http.StatusAccepted, types.JobResponse{
+		Message: "cluster create enqueued",
+		JobID:   job.ID,
+	})
+}
+
+// PostValidateCluster godoc
+//
+//	@Summary		Validate a Kubefirst cluster definition
+//	@Description	Run the pre-flight checks for a cluster definition and return pass, warn or fail findings with remediation
+//	@Tags			cluster
+//	@Accept			json
+//	@Produce		json
+//	@Param			definition	body		pkgtypes.ClusterDefinition	true	"Cluster create request in JSON format"
+//	@Success		200			{object}	pkgtypes.ClusterValidationResponse
+//	@Failure		400			{object}	types.JSONFailureResponse
+//	@Router			/cluster/validate [post]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//
+// PostValidateCluster handles a request to validate a cluster definition
+func PostValidateCluster(c *gin.Context) {
+	var clusterDefinition pkgtypes.ClusterDefinition
+	if err := c.Bind(&clusterDefinition); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
 		})
+		return
 	}
+
+	c.JSON(http.StatusOK, controller.ValidateDefinition(c.Request.Context(), &clusterDefinition))
 }
 
 // PostExportCluster godoc
 //
 //	@Summary		Export a Kubefirst cluster database entry
-//	@Description	Export a Kubefirst cluster database entry
+//	@Description	Export a Kubefirst cluster database entry as a bundle encrypted with the passphrase in the X-Kubefirst-Export-Passphrase header
 //	@Tags			cluster
 //	@Accept			json
 //	@Produce		json
-//	@Param			cluster_name	path		string	true	"Cluster name"
-//	@Success		202				{object}	types.JSONSuccessResponse
-//	@Failure		400				{object}	types.JSONFailureResponse
-//	@Router			/cluster/:cluster_name/export [post]
+//	@Param			cluster_name					path		string	true	"Cluster name"
+//	@Param			X-Kubefirst-Export-Passphrase	header		string	true	"Passphrase of at least 12 characters the bundle is encrypted with"
+//	@Success		200								{object}	pkgtypes.ClusterExportBundle
+//	@Failure		400								{object}	types.JSONFailureResponse
+//	@Failure		403								{object}	types.JSONFailureResponse
+//	@Router			/cluster/:cluster_name/export [get]
 //	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
 //
 // PostExportCluster handles a request to export a cluster
@@ -613,6 +1288,10 @@ func GetExportCluster(c *gin.Context) {
 		return
 	}
 
+	if !authorizeSecretRead(c, rbac.Target{Cluster: clusterName}) {
+		return
+	}
+
 	kcfg := utils.GetKubernetesClient(clusterName)
 
 	// get cluster object
@@ -624,8 +1303,15 @@ func GetExportCluster(c *gin.Context) {
 		return
 	}
 
-	// return json of cluster
-	c.IndentedJSON(http.StatusOK, cluster)
+	bundle, err := export.Seal(*cluster, c.GetHeader(export.PassphraseHeader), time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, bundle)
 }
 
 func GetClusterKubeConfig(c *gin.Context) {
@@ -660,6 +1346,10 @@ func GetClusterKubeConfig(c *gin.Context) {
 			return
 		}
 
+		if !authorizeSecretRead(c, rbac.Target{Cluster: kubeConfigRequest.ManagClusterName, WorkloadCluster: kubeConfigRequest.ClusterName}) {
+			return
+		}
+
 		kcfg := utils.GetKubernetesClient(kubeConfigRequest.ClusterName)
 		internalSecret, err := k8s.ReadSecretV2(kcfg.Clientset, kubeConfigRequest.ClusterName, fmt.Sprintf("vc-%v", kubeConfigRequest.ClusterName))
 		if err != nil {
@@ -684,6 +1374,10 @@ func GetClusterKubeConfig(c *gin.Context) {
 		return
 	}
 
+	if !authorizeSecretRead(c, rbac.Target{Cluster: kubeConfigRequest.ClusterName}) {
+		return
+	}
+
 	// handle management cluster kubeconfig
 	switch cloudProvider {
 	case "civo":
@@ -704,7 +1398,7 @@ func GetClusterKubeConfig(c *gin.Context) {
 
 		civoConfig := civoruntime.Configuration{
 			Client:  civoruntime.NewCivo(kubeConfigRequest.CivoAuth.Token, kubeConfigRequest.CloudRegion),
-			Context: context.Background(),
+			Context: c.Request.Context(),
 		}
 
 		config, err := civoConfig.GetKubeconfig(kubeConfigRequest.ClusterName)
@@ -767,21 +1461,22 @@ func GetClusterKubeConfig(c *gin.Context) {
 // PostImportCluster godoc
 //
 //	@Summary		Import a Kubefirst cluster database entry
-//	@Description	Import a Kubefirst cluster database entry
+//	@Description	Import a Kubefirst cluster database entry, either as an export bundle opened with the passphrase in the X-Kubefirst-Export-Passphrase header or as a plain cluster record
 //	@Tags			cluster
 //	@Accept			json
 //	@Produce		json
-//	@Param			request_body	body		types.Cluster	true	"Cluster import request in JSON format"
-//	@Success		202				{object}	types.JSONSuccessResponse
-//	@Failure		400				{object}	types.JSONFailureResponse
+//	@Param			request_body					body		pkgtypes.ClusterExportBundle	true	"Cluster export bundle or cluster record in JSON format"
+//	@Param			X-Kubefirst-Export-Passphrase	header		string							false	"Passphrase the export bundle was encrypted with"
+//	@Success		202								{object}	types.JSONSuccessResponse
+//	@Failure		400								{object}	types.JSONFailureResponse
 //	@Router			/cluster/import [post]
 //	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
 //
 // PostImportCluster handles a request to import a cluster
 func PostImportCluster(c *gin.Context) {
 	// Bind to variable as application/json, handle error
-	var cluster pkgtypes.Cluster
-	err := c.Bind(&cluster)
+	var bundle pkgtypes.ClusterExportBundle
+	err := c.ShouldBindBodyWith(&bundle, binding.JSON)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
 			Message: err.Error(),
@@ -789,6 +1484,27 @@ func PostImportCluster(c *gin.Context) {
 		return
 	}
 
+	var cluster pkgtypes.Cluster
+	if bundle.Ciphertext != "" {
+		opened, err := export.Open(&bundle, c.GetHeader(export.PassphraseHeader))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+		cluster = *opened
+	} else if err := c.ShouldBindBodyWith(&cluster, binding.JSON); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if !authorize(c, rbac.ActionWrite, rbac.Target{Cluster: cluster.ClusterName}) {
+		return
+	}
+
 	kcfg := utils.GetKubernetesClient(cluster.ClusterName)
 
 	// Insert the cluster into the target database
@@ -817,8 +1533,10 @@ func PostImportCluster(c *gin.Context) {
 	}
 
 	// Update cluster status in database
-	cluster.InProgress = false
-	err = secrets.UpdateCluster(kcfg.Clientset, cluster)
+	_, err = secrets.UpdateClusterWith(kcfg.Clientset, cluster.ClusterName, func(cl *pkgtypes.Cluster) error {
+		cl.InProgress = false
+		return nil
+	})
 	if err != nil {
 		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
 			Message: err.Error(),
@@ -841,8 +1559,10 @@ func PostImportCluster(c *gin.Context) {
 //	@Param			cluster_name	path		string	true	"Cluster name"
 //	@Success		202				{object}	types.JSONSuccessResponse
 //	@Failure		400				{object}	types.JSONFailureResponse
+//	@Failure		412				{object}	types.JSONFailureResponse
 //	@Router			/cluster/:cluster_name/reset_progress [post]
 //	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//	@Param			If-Match		header	string	false	"ETag of the cluster the request was made against"
 //
 // PostResetClusterProgress removes a cluster progress marker from a cluster entry
 func PostResetClusterProgress(c *gin.Context) {
@@ -854,20 +1574,36 @@ func PostResetClusterProgress(c *gin.Context) {
 		return
 	}
 
+	if !authorize(c, rbac.ActionWrite, rbac.Target{Cluster: clusterName}) {
+		return
+	}
+
 	kcfg := utils.GetKubernetesClient(clusterName)
 	// Get Cluster
 
-	cluster, _ := secrets.GetCluster(kcfg.Clientset, clusterName)
-	// Reset
-	cluster.InProgress = false
-	err := secrets.UpdateCluster(kcfg.Clientset, *cluster)
+	updated, err := secrets.UpdateClusterWith(kcfg.Clientset, clusterName, func(cl *pkgtypes.Cluster) error {
+		if err := secrets.CheckResourceVersion(fmt.Sprintf("cluster %q", clusterName), cl.ResourceVersion, ifMatch(c)); err != nil {
+			return err
+		}
+
+		// Reset
+		cl.InProgress = false
+		return nil
+	})
 	if err != nil {
-		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+		status := preconditionStatus(err, http.StatusBadRequest)
+		if errors.Is(err, &secrets.ClusterNotFoundError{}) {
+			status = http.StatusNotFound
+		}
+
+		c.JSON(status, types.JSONFailureResponse{
 			Message: fmt.Sprintf("error updating cluster %s: %s", clusterName, err),
 		})
 		return
 	}
 
+	setETag(c, updated.ResourceVersion)
+
 	c.JSON(http.StatusOK, types.JSONSuccessResponse{
 		Message: "cluster updated",
 	})
@@ -896,6 +1632,10 @@ func PostCreateVcluster(c *gin.Context) {
 		return
 	}
 
+	if !authorize(c, rbac.ActionWrite, rbac.Target{Cluster: clusterName}) {
+		return
+	}
+
 	kcfg := utils.GetKubernetesClient(clusterName)
 
 	cluster, err := secrets.GetCluster(kcfg.Clientset, clusterName)
@@ -17,6 +17,7 @@ import (
 	"github.com/konstructio/kubefirst-api/internal/civo"
 	cloudflare "github.com/konstructio/kubefirst-api/internal/cloudflare"
 	"github.com/konstructio/kubefirst-api/internal/digitalocean"
+	"github.com/konstructio/kubefirst-api/internal/metrics"
 	"github.com/konstructio/kubefirst-api/internal/types"
 	"github.com/konstructio/kubefirst-api/internal/vultr"
 	"github.com/konstructio/kubefirst-api/pkg/google"
@@ -66,6 +67,7 @@ func PostDomains(c *gin.Context) {
 		oauth2Client := &http.Client{
 			Transport: &oauth2.Transport{
 				Source: tokenSource,
+				Base:   metrics.Transport(metrics.ServiceAkamai, nil),
 			},
 		}
 
@@ -160,7 +162,7 @@ func PostDomains(c *gin.Context) {
 		}
 		civoConf := civo.Configuration{
 			Client:  civo.NewCivo(domainListRequest.CivoAuth.Token, domainListRequest.CloudRegion),
-			Context: context.Background(),
+			Context: c.Request.Context(),
 		}
 
 		domains, err := civoConf.GetDNSDomains()
@@ -1,15 +1,18 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	environments "github.com/konstructio/kubefirst-api/internal/environments"
+	"github.com/konstructio/kubefirst-api/internal/rbac"
 	"github.com/konstructio/kubefirst-api/internal/secrets"
 	"github.com/konstructio/kubefirst-api/internal/types"
 	"github.com/konstructio/kubefirst-api/internal/utils"
 	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+	"k8s.io/client-go/kubernetes"
 )
 
 func GetEnvironments(c *gin.Context) {
@@ -25,6 +28,34 @@ func GetEnvironments(c *gin.Context) {
 	c.JSON(http.StatusOK, environments)
 }
 
+func GetEnvironment(c *gin.Context) {
+	envID, param := c.Params.Get("environment_id")
+
+	if !param {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: ":environment_id not provided",
+		})
+		return
+	}
+
+	kcfg := utils.GetKubernetesClient("TODO: SECRETS")
+	environment, err := secrets.GetEnvironmentByID(kcfg.Clientset, envID)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, &secrets.EnvironmentNotFoundError{}) {
+			status = http.StatusNotFound
+		}
+
+		c.JSON(status, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	setETag(c, environment.ResourceVersion)
+	c.JSON(http.StatusOK, environment)
+}
+
 func CreateEnvironment(c *gin.Context) {
 	// Bind to variable as application/json, handle error
 	var environmentDefinition pkgtypes.Environment
@@ -36,6 +67,10 @@ func CreateEnvironment(c *gin.Context) {
 		return
 	}
 
+	if !authorize(c, rbac.ActionWrite, rbac.Target{Environment: environmentDefinition.Name}) {
+		return
+	}
+
 	newEnv, err := environments.NewEnvironment(environmentDefinition)
 	if err != nil {
 		c.JSON(http.StatusConflict, types.JSONFailureResponse{
@@ -58,6 +93,10 @@ func DeleteEnvironment(c *gin.Context) {
 	}
 
 	kcfg := utils.GetKubernetesClient("TODO: SECRETS")
+	if !authorize(c, rbac.ActionDelete, rbac.Target{Environment: environmentName(kcfg.Clientset, envID)}) {
+		return
+	}
+
 	err := secrets.DeleteEnvironment(kcfg.Clientset, envID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
@@ -98,16 +137,44 @@ func UpdateEnvironment(c *gin.Context) {
 	}
 
 	kcfg := utils.GetKubernetesClient("TODO: SECRETS")
-	updateErr := secrets.UpdateEnvironment(kcfg.Clientset, envID, environmentUpdate)
+	if !authorize(c, rbac.ActionWrite, rbac.Target{Environment: environmentName(kcfg.Clientset, envID)}) {
+		return
+	}
+
+	updated, updateErr := secrets.UpdateEnvironment(kcfg.Clientset, envID, environmentUpdate, ifMatch(c))
 
 	if updateErr != nil {
-		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+		status := preconditionStatus(updateErr, http.StatusBadRequest)
+		if errors.Is(updateErr, &secrets.EnvironmentNotFoundError{}) {
+			status = http.StatusNotFound
+		}
+
+		c.JSON(status, types.JSONFailureResponse{
 			Message: updateErr.Error(),
 		})
 		return
 	}
 
+	setETag(c, updated.ResourceVersion)
+
 	c.JSON(http.StatusOK, types.JSONSuccessResponse{
 		Message: fmt.Sprintf("successfully updated environment with id: %v", envID),
 	})
 }
+
+// environmentName returns the name of the environment with the given id, or
+// the id itself when there is no such environment
+func environmentName(clientSet kubernetes.Interface, envID string) string {
+	allEnvironments, err := secrets.GetEnvironments(clientSet)
+	if err != nil {
+		return envID
+	}
+
+	for _, environment := range allEnvironments {
+		if environment.ID.Hex() == envID {
+			return environment.Name
+		}
+	}
+
+	return envID
+}
@@ -0,0 +1,62 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/konstructio/kubefirst-api/internal/secrets"
+	"github.com/konstructio/kubefirst-api/internal/types"
+)
+
+// setETag sets the ETag of a response to the version of the record it serves
+func setETag(c *gin.Context, resourceVersion string) {
+	if resourceVersion != "" {
+		c.Header("ETag", fmt.Sprintf("%q", resourceVersion))
+	}
+}
+
+// ifMatch returns the record version required by the If-Match header of a
+// request, or an empty string when any version is accepted
+func ifMatch(c *gin.Context) string {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return ""
+	}
+
+	return strings.Trim(header, `"`)
+}
+
+// checkIfMatch responds with 412 and returns false when the If-Match header
+// of a request does not match resourceVersion
+func checkIfMatch(c *gin.Context, resource, resourceVersion string) bool {
+	err := secrets.CheckResourceVersion(resource, resourceVersion, ifMatch(c))
+	if err == nil {
+		return true
+	}
+
+	setETag(c, resourceVersion)
+	c.JSON(http.StatusPreconditionFailed, types.JSONFailureResponse{
+		Message: err.Error(),
+	})
+
+	return false
+}
+
+// preconditionStatus returns 412 for errors raised by a failed If-Match, and
+// status otherwise
+func preconditionStatus(err error, status int) int {
+	if errors.Is(err, &secrets.PreconditionFailedError{}) {
+		return http.StatusPreconditionFailed
+	}
+
+	return status
+}
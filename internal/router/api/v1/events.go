@@ -0,0 +1,271 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/konstructio/kubefirst-api/internal/events"
+	"github.com/konstructio/kubefirst-api/internal/middleware"
+	"github.com/konstructio/kubefirst-api/internal/types"
+	log "github.com/rs/zerolog/log"
+)
+
+const (
+	// eventsKeepAlive is how often an idle event stream is written to, so
+	// proxies do not close it
+	eventsKeepAlive = 30 * time.Second
+
+	// eventsWriteTimeout is how long a WebSocket client has to accept an event
+	eventsWriteTimeout = 10 * time.Second
+)
+
+var (
+	eventsUpgrader = websocket.Upgrader{CheckOrigin: checkEventsOrigin}
+
+	// eventsOriginAllowed reports whether a page from another origin may open
+	// an event stream WebSocket, set from the CORS policy by SetEventsOrigins
+	eventsOriginAllowed = func(string) bool { return false }
+)
+
+// SetEventsOrigins sets which cross-origin pages may open an event stream
+// WebSocket. Browsers do not apply CORS to WebSockets, so the upgrade checks
+// the Origin header itself.
+func SetEventsOrigins(allowed func(origin string) bool) {
+	eventsOriginAllowed = allowed
+}
+
+// checkEventsOrigin accepts WebSocket upgrades from clients that send no
+// Origin, from the API's own origin and from the origins allowed by CORS
+func checkEventsOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	return eventsOriginAllowed(origin)
+}
+
+// GetEvents godoc
+//
+//	@Summary		Stream cluster events
+//	@Description	Stream the step_started, step_completed, step_failed, status_changed and service_synced events of a cluster, or of every cluster, as server-sent events or as WebSocket JSON messages when the request is a WebSocket upgrade. Events published after the Last-Event-ID header or the last_event_id query parameter are replayed first, as long as they are still buffered. Browser clients that cannot set the Authorization header can pass a token from the events token endpoint instead.
+//	@Tags			events
+//	@Produce		text/event-stream
+//	@Param			cluster			query		string	false	"Cluster name"
+//	@Param			last_event_id	query		string	false	"ID of the last event received"
+//	@Param			Last-Event-ID	header		string	false	"ID of the last event received"
+//	@Param			token			query		string	false	"Events token"
+//	@Success		200				{object}	events.Event
+//	@Failure		400				{object}	types.JSONFailureResponse
+//	@Router			/events [get]
+//	@Param			Authorization	header	string	false	"API key"	default(Bearer <API key>)
+//
+// GetEvents streams the events of a cluster
+func GetEvents(c *gin.Context) {
+	lastEventID, err := lastEventID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	cluster := c.Query("cluster")
+
+	if websocket.IsWebSocketUpgrade(c.Request) {
+		streamEventsWebSocket(c, cluster, lastEventID)
+		return
+	}
+
+	streamEvents(c, cluster, lastEventID)
+}
+
+// PostEventsToken godoc
+//
+//	@Summary		Create a token to stream cluster events
+//	@Description	Create a short-lived token that opens the event stream of a cluster, or of every cluster, through the token query parameter, for browser EventSource and WebSocket clients
+//	@Tags			events
+//	@Accept			json
+//	@Produce		json
+//	@Param			cluster	query		string	false	"Cluster name"
+//	@Success		200		{object}	types.StreamTokenResponse
+//	@Failure		401		{object}	types.JSONFailureResponse
+//	@Failure		500		{object}	types.JSONFailureResponse
+//	@Router			/events/token [post]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//
+// PostEventsToken returns a token that opens the event stream of a cluster
+func PostEventsToken(c *gin.Context) {
+	user, err := middleware.GetAuthorizedUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	token, expiresAt, err := middleware.NewEventsToken(c.Query("cluster"), user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, types.StreamTokenResponse{
+		Token:     token,
+		ExpiresAt: expiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// lastEventID returns the ID of the last event a reconnecting client
+// received, or 0 for a new client
+func lastEventID(c *gin.Context) (uint64, error) {
+	value := c.GetHeader("Last-Event-ID")
+	if value == "" {
+		value = c.Query("last_event_id")
+	}
+	if value == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid last event ID %q", value)
+	}
+
+	return id, nil
+}
+
+// streamEvents writes the events of cluster as server-sent events until the
+// client goes away
+func streamEvents(c *gin.Context, cluster string, lastEventID uint64) {
+	setHeaders(c)
+
+	sub, replay := events.Default().Subscribe(cluster, lastEventID)
+	defer sub.Close()
+
+	for _, event := range replay {
+		renderEvent(c, event)
+	}
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+
+		case event, ok := <-sub.C:
+			if !ok {
+				// the client fell behind, it reconnects with the ID of the
+				// last event it received
+				return
+			}
+
+			renderEvent(c, event)
+			c.Writer.Flush()
+
+		case <-keepAlive.C:
+			fmt.Fprint(c.Writer, ": keep-alive\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
+
+func renderEvent(c *gin.Context, event events.Event) {
+	c.Render(-1, sse.Event{
+		Id:    strconv.FormatUint(event.ID, 10),
+		Event: string(event.Type),
+		Data:  event,
+	})
+}
+
+// streamEventsWebSocket writes the events of cluster as JSON messages on a
+// WebSocket until the client goes away
+func streamEventsWebSocket(c *gin.Context, cluster string, lastEventID uint64) {
+	conn, err := eventsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// the upgrader has already replied with the error
+		log.Warn().Msgf("error upgrading event stream to a websocket: %s", err)
+		return
+	}
+	defer conn.Close()
+
+	sub, replay := events.Default().Subscribe(cluster, lastEventID)
+	defer sub.Close()
+
+	// Clients are not expected to send anything, reading only handles the
+	// control messages and notices when the client goes away
+	closed := make(chan struct{})
+	conn.SetReadLimit(512)
+	conn.SetReadDeadline(time.Now().Add(2 * eventsKeepAlive))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * eventsKeepAlive))
+	})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	writeEvent := func(event events.Event) error {
+		conn.SetWriteDeadline(time.Now().Add(eventsWriteTimeout))
+		return conn.WriteJSON(event)
+	}
+
+	for _, event := range replay {
+		if err := writeEvent(event); err != nil {
+			return
+		}
+	}
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+
+		case event, ok := <-sub.C:
+			if !ok {
+				// the client fell behind, it reconnects with the ID of the
+				// last event it received
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "event stream fell behind"), time.Now().Add(eventsWriteTimeout))
+				return
+			}
+
+			if err := writeEvent(event); err != nil {
+				return
+			}
+
+		case <-keepAlive.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(eventsWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -78,7 +78,10 @@ func GetGitopsCatalogApps(c *gin.Context) {
 //	@Router			/gitops-catalog/apps/update [get]
 //	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
 //
-// UpdateGitopsCatalogApps updates the list of available Kubefirst gitops catalog applications
+// UpdateGitopsCatalogApps updates the list of available Kubefirst gitops catalog applications.
+// It only refreshes the public catalog and acts on no cluster, environment or
+// service a role binding could be granted on, so it is governed by the
+// services:write scope alone.
 func UpdateGitopsCatalogApps(c *gin.Context) {
 	kcfg := utils.GetKubernetesClient("TODO: Secrets")
 	err := secrets.UpdateGitopsCatalogApps(kcfg.Clientset)
@@ -10,6 +10,7 @@ import (
 	awsinternal "github.com/konstructio/kubefirst-api/internal/aws"
 	"github.com/konstructio/kubefirst-api/internal/civo"
 	"github.com/konstructio/kubefirst-api/internal/digitalocean"
+	"github.com/konstructio/kubefirst-api/internal/metrics"
 	"github.com/konstructio/kubefirst-api/internal/types"
 	"github.com/konstructio/kubefirst-api/internal/vultr"
 	"github.com/konstructio/kubefirst-api/pkg/aws"
@@ -50,7 +51,7 @@ func ListInstanceSizesForRegion(c *gin.Context) {
 
 		civoConfig := civo.Configuration{
 			Client:  civo.NewCivo(instanceSizesRequest.CivoAuth.Token, instanceSizesRequest.CloudRegion),
-			Context: context.Background(),
+			Context: c.Request.Context(),
 		}
 
 		instanceSizes, err := civoConfig.ListInstanceSizes()
@@ -199,6 +200,7 @@ func ListInstanceSizesForRegion(c *gin.Context) {
 		oauth2Client := &http.Client{
 			Transport: &oauth2.Transport{
 				Source: tokenSource,
+				Base:   metrics.Transport(metrics.ServiceAkamai, nil),
 			},
 		}
 
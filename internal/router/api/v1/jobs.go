@@ -0,0 +1,103 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/konstructio/kubefirst-api/internal/secrets"
+	"github.com/konstructio/kubefirst-api/internal/types"
+	"github.com/konstructio/kubefirst-api/internal/utils"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+)
+
+// GetJobs godoc
+//
+//	@Summary		Return all known background jobs
+//	@Description	Return all known background jobs, optionally filtered by cluster name
+//	@Tags			jobs
+//	@Accept			json
+//	@Produce		json
+//	@Param			cluster_name	query		string	false	"Cluster name"
+//	@Success		200				{object}	[]pkgtypes.Job
+//	@Failure		400				{object}	types.JSONFailureResponse
+//	@Router			/jobs [get]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//
+// GetJobs returns all known background jobs
+func GetJobs(c *gin.Context) {
+	kcfg := utils.GetKubernetesClient("")
+
+	allJobs, err := secrets.GetJobs(kcfg.Clientset)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	clusterName := c.Query("cluster_name")
+	if clusterName == "" {
+		c.JSON(http.StatusOK, allJobs)
+		return
+	}
+
+	clusterJobs := []pkgtypes.Job{}
+	for _, job := range allJobs {
+		if job.ClusterName == clusterName {
+			clusterJobs = append(clusterJobs, job)
+		}
+	}
+
+	c.JSON(http.StatusOK, clusterJobs)
+}
+
+// GetJob godoc
+//
+//	@Summary		Return a background job
+//	@Description	Return a background job
+//	@Tags			jobs
+//	@Accept			json
+//	@Produce		json
+//	@Param			job_id	path		string	true	"Job ID"
+//	@Success		200		{object}	pkgtypes.Job
+//	@Failure		400		{object}	types.JSONFailureResponse
+//	@Failure		404		{object}	types.JSONFailureResponse
+//	@Router			/jobs/:job_id [get]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//
+// GetJob returns a specific background job
+func GetJob(c *gin.Context) {
+	jobID, param := c.Params.Get("job_id")
+	if !param {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: ":job_id not provided",
+		})
+		return
+	}
+
+	kcfg := utils.GetKubernetesClient("")
+
+	job, err := secrets.GetJob(kcfg.Clientset, jobID)
+	if err != nil {
+		if errors.Is(err, &secrets.JobNotFoundError{}) {
+			c.JSON(http.StatusNotFound, types.JSONFailureResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
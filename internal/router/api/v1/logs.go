@@ -0,0 +1,212 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/konstructio/kubefirst-api/internal/clusterlogs"
+	"github.com/konstructio/kubefirst-api/internal/rbac"
+	"github.com/konstructio/kubefirst-api/internal/types"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+	"github.com/rs/zerolog"
+	log "github.com/rs/zerolog/log"
+)
+
+// GetClusterLogs godoc
+//
+//	@Summary		Search the logs of a Kubefirst cluster
+//	@Description	Return the log entries of a provisioning run of a cluster, the latest run by default, along with the runs that are kept
+//	@Tags			logs
+//	@Accept			json
+//	@Produce		json
+//	@Param			cluster_name	path		string	true	"Cluster name"
+//	@Param			run				query		string	false	"Run ID, the ID of the job that wrote the logs"
+//	@Param			since			query		string	false	"RFC 3339 timestamp of the oldest entry"
+//	@Param			level			query		string	false	"Lowest level of the entries, such as warn"
+//	@Param			q				query		string	false	"Text the entries contain, ignoring case"
+//	@Param			limit			query		int		false	"Maximum number of entries, 500 by default"
+//	@Success		200				{object}	pkgtypes.ClusterLogs
+//	@Failure		400				{object}	types.JSONFailureResponse
+//	@Failure		403				{object}	types.JSONFailureResponse
+//	@Failure		404				{object}	types.JSONFailureResponse
+//	@Router			/cluster/:cluster_name/logs [get]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//
+// GetClusterLogs returns the log entries of a run matching the query filters
+func GetClusterLogs(c *gin.Context) {
+	clusterName, param := c.Params.Get("cluster_name")
+	if !param {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: ":cluster_name not provided",
+		})
+		return
+	}
+
+	if !authorizeSecretRead(c, rbac.Target{Cluster: clusterName}) {
+		return
+	}
+
+	filter := clusterlogs.Filter{
+		Level: c.Query("level"),
+		Query: c.Query("q"),
+	}
+
+	if since := c.Query("since"); since != "" {
+		parsed, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+				Message: fmt.Sprintf("since must be an RFC 3339 timestamp: %s", err),
+			})
+			return
+		}
+		filter.Since = parsed
+	}
+
+	if filter.Level != "" {
+		if _, err := zerolog.ParseLevel(filter.Level); err != nil {
+			c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+				Message: fmt.Sprintf("level must be one of trace, debug, info, warn, error, fatal or panic: %s", err),
+			})
+			return
+		}
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 || parsed > clusterlogs.MaxLimit {
+			c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+				Message: fmt.Sprintf("limit must be a number between 1 and %d", clusterlogs.MaxLimit),
+			})
+			return
+		}
+		filter.Limit = parsed
+	}
+
+	runs, err := clusterlogs.Runs(clusterName)
+	if err != nil {
+		clusterLogsError(c, err)
+		return
+	}
+
+	runID := c.Query("run")
+	if runID == "" {
+		if len(runs) == 0 {
+			c.JSON(http.StatusNotFound, types.JSONFailureResponse{
+				Message: fmt.Sprintf("cluster %s has no logs", clusterName),
+			})
+			return
+		}
+		runID = runs[0].ID
+	}
+
+	entries, truncated, err := clusterlogs.Search(clusterName, runID, filter)
+	if err != nil {
+		clusterLogsError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, pkgtypes.ClusterLogs{
+		Run:       runID,
+		Runs:      runs,
+		Entries:   entries,
+		Truncated: truncated,
+	})
+}
+
+// GetClusterLogsDownload godoc
+//
+//	@Summary		Download the logs of a Kubefirst cluster
+//	@Description	Download a gzipped tar archive of the log files of a run of a cluster, or of every run that is kept when no run is given
+//	@Tags			logs
+//	@Produce		application/gzip
+//	@Param			cluster_name	path		string	true	"Cluster name"
+//	@Param			run				query		string	false	"Run ID, the ID of the job that wrote the logs"
+//	@Success		200				{file}		file
+//	@Failure		400				{object}	types.JSONFailureResponse
+//	@Failure		403				{object}	types.JSONFailureResponse
+//	@Failure		404				{object}	types.JSONFailureResponse
+//	@Router			/cluster/:cluster_name/logs/download [get]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//
+// GetClusterLogsDownload returns the log files of a cluster as a gzipped tar
+// archive
+func GetClusterLogsDownload(c *gin.Context) {
+	clusterName, param := c.Params.Get("cluster_name")
+	if !param {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: ":cluster_name not provided",
+		})
+		return
+	}
+
+	if !authorizeSecretRead(c, rbac.Target{Cluster: clusterName}) {
+		return
+	}
+
+	// Runs are checked before the archive is started so that errors can
+	// still be returned as JSON
+	runs, err := clusterlogs.Runs(clusterName)
+	if err != nil {
+		clusterLogsError(c, err)
+		return
+	}
+
+	runIDs := make([]string, 0, len(runs))
+	fileName := clusterName + "-logs.tar.gz"
+	if runID := c.Query("run"); runID != "" {
+		if !slices.ContainsFunc(runs, func(run pkgtypes.LogRun) bool { return run.ID == runID }) {
+			c.JSON(http.StatusNotFound, types.JSONFailureResponse{
+				Message: fmt.Sprintf("cluster %s has no logs for run %s", clusterName, runID),
+			})
+			return
+		}
+		runIDs = append(runIDs, runID)
+		fileName = fmt.Sprintf("%s-%s-logs.tar.gz", clusterName, runID)
+	} else {
+		for _, run := range runs {
+			runIDs = append(runIDs, run.ID)
+		}
+	}
+
+	if len(runIDs) == 0 {
+		c.JSON(http.StatusNotFound, types.JSONFailureResponse{
+			Message: fmt.Sprintf("cluster %s has no logs", clusterName),
+		})
+		return
+	}
+
+	c.Header("Content-Type", "application/gzip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	c.Status(http.StatusOK)
+
+	if err := clusterlogs.WriteBundle(c.Writer, clusterName, runIDs); err != nil {
+		// The response has started, so the error can only be logged
+		log.Error().Msgf("error writing logs of cluster %s: %s", clusterName, err)
+	}
+}
+
+// clusterLogsError responds to a failed log search or download
+func clusterLogsError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, clusterlogs.ErrInvalidName):
+		status = http.StatusBadRequest
+	case errors.Is(err, clusterlogs.ErrRunNotFound):
+		status = http.StatusNotFound
+	}
+
+	c.JSON(status, types.JSONFailureResponse{
+		Message: err.Error(),
+	})
+}
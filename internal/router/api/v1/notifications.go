@@ -0,0 +1,366 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/konstructio/kubefirst-api/internal/notifications"
+	"github.com/konstructio/kubefirst-api/internal/redact"
+	"github.com/konstructio/kubefirst-api/internal/secrets"
+	"github.com/konstructio/kubefirst-api/internal/types"
+	"github.com/konstructio/kubefirst-api/internal/utils"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+	log "github.com/rs/zerolog/log"
+)
+
+// GetNotificationTargets godoc
+//
+//	@Summary		Return all notification targets
+//	@Description	Return all notification targets, with their URLs, routing keys and signing secrets masked
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]pkgtypes.NotificationTarget
+//	@Failure		400	{object}	types.JSONFailureResponse
+//	@Router			/notifications/targets [get]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//
+// GetNotificationTargets returns all notification targets
+func GetNotificationTargets(c *gin.Context) {
+	kcfg := utils.GetKubernetesClient("")
+
+	allTargets, err := secrets.GetNotificationTargets(kcfg.Clientset)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, redact.NotificationTargets(allTargets))
+}
+
+// PostNotificationTarget godoc
+//
+//	@Summary		Create a notification target
+//	@Description	Notify a Slack, Microsoft Teams or PagerDuty integration, or any webhook, of cluster and service lifecycle events
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			definition	body		pkgtypes.NotificationTarget	true	"Notification target definition"
+//	@Success		201			{object}	pkgtypes.NotificationTarget
+//	@Failure		400			{object}	types.JSONFailureResponse
+//	@Failure		409			{object}	types.JSONFailureResponse
+//	@Router			/notifications/targets [post]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//
+// PostNotificationTarget creates a notification target
+func PostNotificationTarget(c *gin.Context) {
+	var target pkgtypes.NotificationTarget
+	if err := c.Bind(&target); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := notifications.ValidateTarget(&target); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	kcfg := utils.GetKubernetesClient("")
+
+	_, err := secrets.GetNotificationTarget(kcfg.Clientset, target.Name)
+	if err == nil {
+		c.JSON(http.StatusConflict, types.JSONFailureResponse{
+			Message: fmt.Sprintf("notification target %q already exists", target.Name),
+		})
+		return
+	}
+	if !errors.Is(err, &secrets.NotificationTargetNotFoundError{}) {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	target.CreationTimestamp = time.Now().UTC().Format(time.RFC3339)
+	if err := secrets.InsertNotificationTarget(kcfg.Clientset, target); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	log.Info().Msgf("created %s notification target %q", target.Type, target.Name)
+
+	c.JSON(http.StatusCreated, redact.NotificationTarget(target))
+}
+
+// PutNotificationTarget godoc
+//
+//	@Summary		Update a notification target
+//	@Description	Replace the type, URL, credentials and events of a notification target. Masked values returned by the API keep the current value.
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			target_name	path		string						true	"Notification target name"
+//	@Param			definition	body		pkgtypes.NotificationTarget	true	"Notification target definition"
+//	@Success		200			{object}	pkgtypes.NotificationTarget
+//	@Failure		400			{object}	types.JSONFailureResponse
+//	@Failure		404			{object}	types.JSONFailureResponse
+//	@Router			/notifications/targets/:target_name [put]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//
+// PutNotificationTarget updates a notification target
+func PutNotificationTarget(c *gin.Context) {
+	name, param := c.Params.Get("target_name")
+	if !param {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: ":target_name not provided",
+		})
+		return
+	}
+
+	var update pkgtypes.NotificationTarget
+	if err := c.Bind(&update); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if update.Name != name {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: fmt.Sprintf("notification target name %q does not match %q", update.Name, name),
+		})
+		return
+	}
+
+	kcfg := utils.GetKubernetesClient("")
+
+	target, err := secrets.GetNotificationTarget(kcfg.Clientset, name)
+	if err != nil {
+		if errors.Is(err, &secrets.NotificationTargetNotFoundError{}) {
+			c.JSON(http.StatusNotFound, types.JSONFailureResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// values masked by the API are sent back unchanged by clients editing a
+	// target they read, and keep the current value
+	masked := redact.NotificationTarget(*target)
+	if update.URL == masked.URL {
+		update.URL = target.URL
+	}
+	if update.RoutingKey == redact.Mask {
+		update.RoutingKey = target.RoutingKey
+	}
+	if update.SigningSecret == redact.Mask {
+		update.SigningSecret = target.SigningSecret
+	}
+
+	if err := notifications.ValidateTarget(&update); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	target.Type = update.Type
+	target.URL = update.URL
+	target.RoutingKey = update.RoutingKey
+	target.SigningSecret = update.SigningSecret
+	target.Events = update.Events
+	if err := secrets.UpdateNotificationTarget(kcfg.Clientset, *target); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	log.Info().Msgf("updated %s notification target %q", target.Type, target.Name)
+
+	c.JSON(http.StatusOK, redact.NotificationTarget(*target))
+}
+
+// DeleteNotificationTarget godoc
+//
+//	@Summary		Delete a notification target
+//	@Description	Delete a notification target. Its deliveries are kept in the delivery log.
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			target_name	path		string	true	"Notification target name"
+//	@Success		200			{object}	types.JSONSuccessResponse
+//	@Failure		400			{object}	types.JSONFailureResponse
+//	@Failure		404			{object}	types.JSONFailureResponse
+//	@Router			/notifications/targets/:target_name [delete]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//
+// DeleteNotificationTarget deletes a notification target
+func DeleteNotificationTarget(c *gin.Context) {
+	name, param := c.Params.Get("target_name")
+	if !param {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: ":target_name not provided",
+		})
+		return
+	}
+
+	kcfg := utils.GetKubernetesClient("")
+
+	if _, err := secrets.GetNotificationTarget(kcfg.Clientset, name); err != nil {
+		if errors.Is(err, &secrets.NotificationTargetNotFoundError{}) {
+			c.JSON(http.StatusNotFound, types.JSONFailureResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := secrets.DeleteNotificationTarget(kcfg.Clientset, name); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	log.Info().Msgf("deleted notification target %q", name)
+
+	c.JSON(http.StatusOK, types.JSONSuccessResponse{
+		Message: fmt.Sprintf("notification target %q deleted", name),
+	})
+}
+
+// PostNotificationTargetTest godoc
+//
+//	@Summary		Send a test notification
+//	@Description	Send a test notification to a notification target once, without retrying, and return its delivery
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			target_name	path		string	true	"Notification target name"
+//	@Success		200			{object}	pkgtypes.NotificationDelivery
+//	@Failure		400			{object}	types.JSONFailureResponse
+//	@Failure		404			{object}	types.JSONFailureResponse
+//	@Router			/notifications/targets/:target_name/test [post]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//
+// PostNotificationTargetTest sends a test notification to a notification
+// target
+func PostNotificationTargetTest(c *gin.Context) {
+	name, param := c.Params.Get("target_name")
+	if !param {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: ":target_name not provided",
+		})
+		return
+	}
+
+	kcfg := utils.GetKubernetesClient("")
+
+	target, err := secrets.GetNotificationTarget(kcfg.Clientset, name)
+	if err != nil {
+		if errors.Is(err, &secrets.NotificationTargetNotFoundError{}) {
+			c.JSON(http.StatusNotFound, types.JSONFailureResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	delivery := notifications.Deliver(*target, notifications.Test(), 1)
+	notifications.Record(delivery)
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+// GetNotificationDeliveries godoc
+//
+//	@Summary		Return notification deliveries
+//	@Description	Return the latest notification deliveries, newest first
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			target	query		string	false	"Notification target the notification was sent to"
+//	@Param			cluster	query		string	false	"Cluster the notification is about"
+//	@Param			outcome	query		string	false	"success or failure"
+//	@Param			limit	query		int		false	"Maximum number of deliveries, 100 by default"
+//	@Success		200		{object}	[]pkgtypes.NotificationDelivery
+//	@Failure		400		{object}	types.JSONFailureResponse
+//	@Router			/notifications/deliveries [get]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//
+// GetNotificationDeliveries returns notification deliveries matching the
+// query filters
+func GetNotificationDeliveries(c *gin.Context) {
+	limit := notifications.DefaultLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > notifications.MaxLimit {
+			c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+				Message: fmt.Sprintf("limit must be a number between 1 and %d", notifications.MaxLimit),
+			})
+			return
+		}
+		limit = parsed
+	}
+
+	kcfg := utils.GetKubernetesClient("")
+
+	allDeliveries, err := secrets.GetNotificationDeliveries(kcfg.Clientset)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	target, cluster, outcome := c.Query("target"), c.Query("cluster"), c.Query("outcome")
+	deliveries := []pkgtypes.NotificationDelivery{}
+	for _, delivery := range allDeliveries {
+		if (target != "" && delivery.Target != target) ||
+			(cluster != "" && delivery.Cluster != cluster) ||
+			(outcome != "" && delivery.Outcome != outcome) {
+			continue
+		}
+
+		deliveries = append(deliveries, delivery)
+		if len(deliveries) == limit {
+			break
+		}
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
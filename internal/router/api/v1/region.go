@@ -16,6 +16,7 @@ import (
 	awsinternal "github.com/konstructio/kubefirst-api/internal/aws"
 	"github.com/konstructio/kubefirst-api/internal/civo"
 	"github.com/konstructio/kubefirst-api/internal/digitalocean"
+	"github.com/konstructio/kubefirst-api/internal/metrics"
 	"github.com/konstructio/kubefirst-api/internal/types"
 	"github.com/konstructio/kubefirst-api/internal/vultr"
 	"github.com/konstructio/kubefirst-api/pkg/aws"
@@ -108,7 +109,7 @@ func PostRegions(c *gin.Context) {
 		}
 		civoConf := civo.Configuration{
 			Client:  civo.NewCivo(regionListRequest.CivoAuth.Token, regionListRequest.CloudRegion),
-			Context: context.Background(),
+			Context: c.Request.Context(),
 		}
 
 		regions, err := civoConf.GetRegions()
@@ -191,6 +192,7 @@ func PostRegions(c *gin.Context) {
 		oauth2Client := &http.Client{
 			Transport: &oauth2.Transport{
 				Source: tokenSource,
+				Base:   metrics.Transport(metrics.ServiceAkamai, nil),
 			},
 		}
 
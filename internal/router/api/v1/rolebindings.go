@@ -0,0 +1,286 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/konstructio/kubefirst-api/internal/middleware"
+	"github.com/konstructio/kubefirst-api/internal/rbac"
+	"github.com/konstructio/kubefirst-api/internal/secrets"
+	"github.com/konstructio/kubefirst-api/internal/types"
+	"github.com/konstructio/kubefirst-api/internal/utils"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+	log "github.com/rs/zerolog/log"
+)
+
+// GetRoleBindings godoc
+//
+//	@Summary		Return all role bindings
+//	@Description	Return all role bindings
+//	@Tags			rolebindings
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]pkgtypes.RoleBinding
+//	@Failure		400	{object}	types.JSONFailureResponse
+//	@Router			/rolebindings [get]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//
+// GetRoleBindings returns all role bindings
+func GetRoleBindings(c *gin.Context) {
+	kcfg := utils.GetKubernetesClient("")
+
+	allRoleBindings, err := secrets.GetRoleBindings(kcfg.Clientset)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, allRoleBindings)
+}
+
+// PostRoleBinding godoc
+//
+//	@Summary		Create a role binding
+//	@Description	Grant a role to API keys, OIDC users or OIDC groups on clusters, workload clusters, environments or services. Once a role binding exists, changes are only allowed where a role binding grants them.
+//	@Tags			rolebindings
+//	@Accept			json
+//	@Produce		json
+//	@Param			definition	body		pkgtypes.RoleBinding	true	"Role binding definition"
+//	@Success		201			{object}	pkgtypes.RoleBinding
+//	@Failure		400			{object}	types.JSONFailureResponse
+//	@Failure		409			{object}	types.JSONFailureResponse
+//	@Router			/rolebindings [post]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//
+// PostRoleBinding creates a role binding
+func PostRoleBinding(c *gin.Context) {
+	var roleBinding pkgtypes.RoleBinding
+	if err := c.Bind(&roleBinding); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := rbac.ValidateBinding(&roleBinding); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	kcfg := utils.GetKubernetesClient("")
+
+	_, err := secrets.GetRoleBinding(kcfg.Clientset, roleBinding.Name)
+	if err == nil {
+		c.JSON(http.StatusConflict, types.JSONFailureResponse{
+			Message: fmt.Sprintf("role binding %q already exists", roleBinding.Name),
+		})
+		return
+	}
+	if !errors.Is(err, &secrets.RoleBindingNotFoundError{}) {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	roleBinding.CreationTimestamp = time.Now().UTC().Format(time.RFC3339)
+	if err := secrets.InsertRoleBinding(kcfg.Clientset, roleBinding); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	log.Info().Msgf("created role binding %q granting %s", roleBinding.Name, roleBinding.Role)
+
+	c.JSON(http.StatusCreated, roleBinding)
+}
+
+// PutRoleBinding godoc
+//
+//	@Summary		Update a role binding
+//	@Description	Replace the role, subjects and resources of a role binding
+//	@Tags			rolebindings
+//	@Accept			json
+//	@Produce		json
+//	@Param			rolebinding_name	path		string					true	"Role binding name"
+//	@Param			definition			body		pkgtypes.RoleBinding	true	"Role binding definition"
+//	@Success		200					{object}	pkgtypes.RoleBinding
+//	@Failure		400					{object}	types.JSONFailureResponse
+//	@Failure		404					{object}	types.JSONFailureResponse
+//	@Router			/rolebindings/:rolebinding_name [put]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//
+// PutRoleBinding updates a role binding
+func PutRoleBinding(c *gin.Context) {
+	name, param := c.Params.Get("rolebinding_name")
+	if !param {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: ":rolebinding_name not provided",
+		})
+		return
+	}
+
+	var update pkgtypes.RoleBinding
+	if err := c.Bind(&update); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if update.Name != name {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: fmt.Sprintf("role binding name %q does not match %q", update.Name, name),
+		})
+		return
+	}
+
+	if err := rbac.ValidateBinding(&update); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	kcfg := utils.GetKubernetesClient("")
+
+	roleBinding, err := secrets.GetRoleBinding(kcfg.Clientset, name)
+	if err != nil {
+		if errors.Is(err, &secrets.RoleBindingNotFoundError{}) {
+			c.JSON(http.StatusNotFound, types.JSONFailureResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	roleBinding.Role = update.Role
+	roleBinding.Subjects = update.Subjects
+	roleBinding.Resources = update.Resources
+	if err := secrets.UpdateRoleBinding(kcfg.Clientset, *roleBinding); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	log.Info().Msgf("updated role binding %q granting %s", roleBinding.Name, roleBinding.Role)
+
+	c.JSON(http.StatusOK, roleBinding)
+}
+
+// DeleteRoleBinding godoc
+//
+//	@Summary		Delete a role binding
+//	@Description	Delete a role binding. When the last role binding is deleted, changes are no longer restricted by role bindings.
+//	@Tags			rolebindings
+//	@Accept			json
+//	@Produce		json
+//	@Param			rolebinding_name	path		string	true	"Role binding name"
+//	@Success		200					{object}	types.JSONSuccessResponse
+//	@Failure		400					{object}	types.JSONFailureResponse
+//	@Failure		404					{object}	types.JSONFailureResponse
+//	@Router			/rolebindings/:rolebinding_name [delete]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//
+// DeleteRoleBinding deletes a role binding
+func DeleteRoleBinding(c *gin.Context) {
+	name, param := c.Params.Get("rolebinding_name")
+	if !param {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: ":rolebinding_name not provided",
+		})
+		return
+	}
+
+	kcfg := utils.GetKubernetesClient("")
+
+	if _, err := secrets.GetRoleBinding(kcfg.Clientset, name); err != nil {
+		if errors.Is(err, &secrets.RoleBindingNotFoundError{}) {
+			c.JSON(http.StatusNotFound, types.JSONFailureResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := secrets.DeleteRoleBinding(kcfg.Clientset, name); err != nil {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	log.Info().Msgf("deleted role binding %q", name)
+
+	c.JSON(http.StatusOK, types.JSONSuccessResponse{
+		Message: fmt.Sprintf("role binding %q deleted", name),
+	})
+}
+
+// authorize responds with 403 and returns false unless the caller of a
+// request may perform action on target
+func authorize(c *gin.Context, action string, target rbac.Target) bool {
+	err := middleware.Authorize(c, action, target)
+	if err == nil {
+		return true
+	}
+
+	status := http.StatusInternalServerError
+	if errors.Is(err, rbac.ErrForbidden) {
+		status = http.StatusForbidden
+	}
+
+	log.Info().Msgf(" Request Status: %d;  %s", status, err)
+	c.JSON(status, types.JSONFailureResponse{
+		Message: err.Error(),
+	})
+
+	return false
+}
+
+// authorizeSecretRead responds with 403 and returns false unless the caller
+// may read the credentials or logs of target. Reads are otherwise governed by
+// scopes alone, but credentials and logs grant as much as changing the
+// cluster, so reading them needs the same grant.
+func authorizeSecretRead(c *gin.Context, target rbac.Target) bool {
+	return authorize(c, rbac.ActionWrite, target)
+}
+
+// workloadClusterEnvironment returns the environment of a workload cluster of
+// cl, or an empty string when the workload cluster is not recorded on cl or
+// has no environment. The environment is never taken from the request, so a
+// binding on an environment cannot be claimed for another workload cluster.
+func workloadClusterEnvironment(cl *pkgtypes.Cluster, workloadClusterName string) string {
+	for _, workloadCluster := range cl.WorkloadClusters {
+		if workloadCluster.ClusterName == workloadClusterName {
+			return workloadCluster.Environment.Name
+		}
+	}
+
+	return ""
+}
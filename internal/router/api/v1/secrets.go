@@ -8,10 +8,15 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"slices"
 
 	"github.com/gin-gonic/gin"
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/env"
 	"github.com/konstructio/kubefirst-api/internal/k8s"
+	"github.com/konstructio/kubefirst-api/internal/rbac"
 	"github.com/konstructio/kubefirst-api/internal/secrets"
 	"github.com/konstructio/kubefirst-api/internal/types"
 	"github.com/konstructio/kubefirst-api/internal/utils"
@@ -36,6 +41,17 @@ func GetClusterSecret(c *gin.Context) {
 		return
 	}
 
+	if !secretAllowed(secret) {
+		c.JSON(http.StatusForbidden, types.JSONFailureResponse{
+			Message: fmt.Sprintf("secret %q cannot be accessed through the api", secret),
+		})
+		return
+	}
+
+	if !authorizeSecretRead(c, rbac.Target{Cluster: clusterName}) {
+		return
+	}
+
 	kcfg := utils.GetKubernetesClient(clusterName)
 	kubefirstSecrets, _ := k8s.ReadSecretV2Old(kcfg.Clientset, "kubefirst", secret)
 
@@ -67,6 +83,17 @@ func CreateClusterSecret(c *gin.Context) {
 		return
 	}
 
+	if !secretAllowed(secretName) {
+		c.JSON(http.StatusForbidden, types.JSONFailureResponse{
+			Message: fmt.Sprintf("secret %q cannot be accessed through the api", secretName),
+		})
+		return
+	}
+
+	if !authorize(c, rbac.ActionWrite, rbac.Target{Cluster: clusterName}) {
+		return
+	}
+
 	var secretValues map[string]interface{}
 	err := c.Bind(&secretValues)
 	if err != nil {
@@ -125,6 +152,17 @@ func UpdateClusterSecret(c *gin.Context) {
 		return
 	}
 
+	if !secretAllowed(secret) {
+		c.JSON(http.StatusForbidden, types.JSONFailureResponse{
+			Message: fmt.Sprintf("secret %q cannot be accessed through the api", secret),
+		})
+		return
+	}
+
+	if !authorize(c, rbac.ActionWrite, rbac.Target{Cluster: clusterName}) {
+		return
+	}
+
 	var secretValues map[string]interface{}
 	err := c.Bind(&secretValues)
 	if err != nil {
@@ -157,3 +195,11 @@ func UpdateClusterSecret(c *gin.Context) {
 		Message: "cluster secret updated",
 	})
 }
+
+// secretAllowed reports whether the secret routes may touch a secret in the
+// kubefirst namespace. Cluster records, API keys and credentials are never
+// on the allowlist by default.
+func secretAllowed(secretName string) bool {
+	env, _ := env.GetEnv(constants.SilenceGetEnv)
+	return slices.Contains(env.SecretAllowlist, secretName)
+}
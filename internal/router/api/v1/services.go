@@ -7,10 +7,15 @@ See the LICENSE file for more details.
 package api
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/jobs"
+	"github.com/konstructio/kubefirst-api/internal/middleware"
+	"github.com/konstructio/kubefirst-api/internal/rbac"
 	"github.com/konstructio/kubefirst-api/internal/secrets"
 	"github.com/konstructio/kubefirst-api/internal/services"
 	"github.com/konstructio/kubefirst-api/internal/types"
@@ -92,7 +97,7 @@ func PostAddServiceToCluster(c *gin.Context) {
 	kcfg := utils.GetKubernetesClient(clusterName)
 
 	// Verify cluster exists
-	_, err := secrets.GetCluster(kcfg.Clientset, clusterName)
+	cl, err := secrets.GetCluster(kcfg.Clientset, clusterName)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
 			Message: "cluster not found",
@@ -135,6 +140,19 @@ func PostAddServiceToCluster(c *gin.Context) {
 		})
 		return
 	}
+	serviceDefinition.User = middleware.RequestUser(c, serviceDefinition.User)
+
+	target := rbac.Target{
+		Cluster:         clusterName,
+		WorkloadCluster: serviceDefinition.WorkloadClusterName,
+		Service:         serviceName,
+	}
+	if serviceDefinition.WorkloadClusterName != "" {
+		target.Environment = workloadClusterEnvironment(cl, serviceDefinition.WorkloadClusterName)
+	}
+	if !authorize(c, rbac.ActionWrite, target) {
+		return
+	}
 
 	// Verify any required secrets are present and not empty
 	if hasKeys {
@@ -172,15 +190,9 @@ func PostAddServiceToCluster(c *gin.Context) {
 	}
 
 	// Generate and apply
-	cl, err := secrets.GetCluster(kcfg.Clientset, clusterName)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
-			Message: err.Error(),
-		})
-		return
-	}
-
-	err = services.CreateService(cl, serviceName, &appDef, &serviceDefinition, false)
+	err = jobs.Run(kcfg.Clientset, jobs.NewJob(c.Request.Context(), constants.JobTypeServiceCreate, clusterName, serviceName), func(_ context.Context, _ *pkgtypes.Job) error {
+		return services.CreateService(cl, serviceName, &appDef, &serviceDefinition, false)
+	})
 	if err != nil {
 		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
 			Message: err.Error(),
@@ -278,6 +290,20 @@ func PostValidateService(c *gin.Context) {
 		return
 	}
 
+	// validating clones the gitops repository with the credentials of the
+	// cluster, so it needs the same grant as adding the service
+	target := rbac.Target{
+		Cluster:         clusterName,
+		WorkloadCluster: serviceDefinition.WorkloadClusterName,
+		Service:         serviceName,
+	}
+	if serviceDefinition.WorkloadClusterName != "" {
+		target.Environment = workloadClusterEnvironment(cl, serviceDefinition.WorkloadClusterName)
+	}
+	if !authorize(c, rbac.ActionWrite, target) {
+		return
+	}
+
 	canDeleteService, err := services.ValidateService(cl, serviceName, &serviceDefinition)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
@@ -364,8 +390,23 @@ func DeleteServiceFromCluster(c *gin.Context) {
 		})
 		return
 	}
+	serviceDefinition.User = middleware.RequestUser(c, serviceDefinition.User)
 
-	err = services.DeleteService(cl, serviceName, serviceDefinition)
+	target := rbac.Target{
+		Cluster:         clusterName,
+		WorkloadCluster: serviceDefinition.WorkloadClusterName,
+		Service:         serviceName,
+	}
+	if serviceDefinition.WorkloadClusterName != "" {
+		target.Environment = workloadClusterEnvironment(cl, serviceDefinition.WorkloadClusterName)
+	}
+	if !authorize(c, rbac.ActionDelete, target) {
+		return
+	}
+
+	err = jobs.Run(kcfg.Clientset, jobs.NewJob(c.Request.Context(), constants.JobTypeServiceDelete, clusterName, serviceName), func(_ context.Context, _ *pkgtypes.Job) error {
+		return services.DeleteService(cl, serviceName, serviceDefinition)
+	})
 	if err != nil {
 		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
 			Message: err.Error(),
@@ -9,12 +9,16 @@ package api
 import (
 	"fmt"
 	"net/http"
-	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/konstructio/kubefirst-api/internal/clusterlogs"
+	"github.com/konstructio/kubefirst-api/internal/middleware"
+	"github.com/konstructio/kubefirst-api/internal/rbac"
+	"github.com/konstructio/kubefirst-api/internal/secrets"
 	"github.com/konstructio/kubefirst-api/internal/types"
+	"github.com/konstructio/kubefirst-api/internal/utils"
 	"github.com/nxadm/tail"
 )
 
@@ -30,10 +34,11 @@ func setHeaders(c *gin.Context) {
 // GetLogs godoc
 //
 //	@Summary		Stream API server logs
-//	@Description	Stream API server logs
+//	@Description	Stream the logs of the latest run of the cluster whose log_file or name is file_name. Browser EventSource clients that cannot set the Authorization header can pass a token from the stream token endpoint instead.
 //	@Tags			logs
+//	@Param			token	query	string	false	"Stream token"
 //	@Router			/stream/file_name [get]
-//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//	@Param			Authorization	header	string	false	"API key"	default(Bearer <API key>)
 //
 // GetLogs
 func GetLogs(c *gin.Context) {
@@ -48,6 +53,28 @@ func GetLogs(c *gin.Context) {
 		return
 	}
 
+	if !validLogFileName(fileName) {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: fmt.Sprintf("invalid log file name %q", fileName),
+		})
+		return
+	}
+
+	// the role bindings were checked when a stream token was issued
+	if c.Query("token") == "" {
+		clusterName, err := streamLogCluster(fileName)
+		if err != nil {
+			c.JSON(http.StatusNotFound, types.JSONFailureResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		if !authorizeSecretRead(c, rbac.Target{Cluster: clusterName}) {
+			return
+		}
+	}
+
 	// Stream logs
 	if err := StreamLogs(c, fileName); err != nil {
 		c.SSEvent("error", err.Error())
@@ -55,15 +82,126 @@ func GetLogs(c *gin.Context) {
 	}
 }
 
+// PostStreamToken godoc
+//
+//	@Summary		Create a token to stream API server logs
+//	@Description	Create a short-lived token that opens the log stream of a file through the token query parameter, for browser EventSource clients
+//	@Tags			logs
+//	@Accept			json
+//	@Produce		json
+//	@Param			file_name	path		string	true	"Log file name"
+//	@Success		200			{object}	types.StreamTokenResponse
+//	@Failure		400			{object}	types.JSONFailureResponse
+//	@Failure		401			{object}	types.JSONFailureResponse
+//	@Failure		403			{object}	types.JSONFailureResponse
+//	@Failure		404			{object}	types.JSONFailureResponse
+//	@Router			/stream/:file_name/token [post]
+//	@Param			Authorization	header	string	true	"API key"	default(Bearer <API key>)
+//
+// PostStreamToken returns a token that opens the log stream of a file
+func PostStreamToken(c *gin.Context) {
+	fileName, param := c.Params.Get("file_name")
+	if !param {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: ":file_name not provided",
+		})
+		return
+	}
+
+	if !validLogFileName(fileName) {
+		c.JSON(http.StatusBadRequest, types.JSONFailureResponse{
+			Message: fmt.Sprintf("invalid log file name %q", fileName),
+		})
+		return
+	}
+
+	clusterName, err := streamLogCluster(fileName)
+	if err != nil {
+		c.JSON(http.StatusNotFound, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if !authorizeSecretRead(c, rbac.Target{Cluster: clusterName}) {
+		return
+	}
+
+	user, err := middleware.GetAuthorizedUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	token, expiresAt, err := middleware.NewStreamToken(fileName, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.JSONFailureResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, types.StreamTokenResponse{
+		Token:     token,
+		ExpiresAt: expiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// validLogFileName rejects file names that are paths
+func validLogFileName(fileName string) bool {
+	return fileName != "" && fileName != "." && fileName != ".." && filepath.Base(fileName) == fileName
+}
+
+// streamLogCluster returns the name of the cluster whose log file name or
+// cluster name is fileName
+func streamLogCluster(fileName string) (string, error) {
+	kcfg := utils.GetKubernetesClient("TODO: SECRETS")
+
+	allClusters, err := secrets.GetClusters(kcfg.Clientset)
+	if err != nil {
+		return "", fmt.Errorf("error listing clusters: %w", err)
+	}
+
+	for _, cluster := range allClusters {
+		if cluster.LogFileName == fileName || cluster.ClusterName == fileName {
+			return cluster.ClusterName, nil
+		}
+	}
+
+	return "", fmt.Errorf("no cluster logs to log file %q", fileName)
+}
+
+// streamLogPath returns the log file of the latest run of the cluster whose
+// log file name or cluster name is fileName. The path is built from the
+// cluster record rather than from fileName, which comes from the client.
+func streamLogPath(fileName string) (string, error) {
+	clusterName, err := streamLogCluster(fileName)
+	if err != nil {
+		return "", err
+	}
+
+	runID, err := clusterlogs.Latest(clusterName)
+	if err != nil {
+		return "", fmt.Errorf("error finding logs of cluster %q: %w", clusterName, err)
+	}
+
+	logfile, err := clusterlogs.Path(clusterName, runID)
+	if err != nil {
+		return "", fmt.Errorf("error finding logs of cluster %q: %w", clusterName, err)
+	}
+
+	return logfile, nil
+}
+
 // StreamLogs redirects stdout logs to the stream via SSE
 func StreamLogs(c *gin.Context, fileName string) error {
-	homePath, err := os.UserHomeDir()
+	logfile, err := streamLogPath(fileName)
 	if err != nil {
-		return fmt.Errorf("error getting current user's home directory: %w", err)
+		return err
 	}
 
-	logfile := filepath.Join(homePath, ".k1", "logs", fileName)
-
 	t, err := tail.TailFile(logfile, tail.Config{Follow: true, ReOpen: true})
 	if err != nil {
 		return fmt.Errorf("error opening log file %q: %w", logfile, err)
@@ -12,6 +12,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/konstructio/kubefirst-api/internal/constants"
 	"github.com/konstructio/kubefirst-api/internal/env"
+	"github.com/konstructio/kubefirst-api/internal/rbac"
 	"github.com/konstructio/kubefirst-api/internal/secrets"
 	"github.com/konstructio/kubefirst-api/internal/types"
 	"github.com/konstructio/kubefirst-api/internal/utils"
@@ -39,6 +40,10 @@ func PostTelemetry(c *gin.Context) {
 		})
 		return
 	}
+	if !authorize(c, rbac.ActionWrite, rbac.Target{Cluster: clusterName}) {
+		return
+	}
+
 	kcfg := utils.GetKubernetesClient(clusterName)
 
 	// Retrieve cluster info
@@ -0,0 +1,108 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-contrib/cors"
+	"github.com/konstructio/kubefirst-api/internal/env"
+	"github.com/konstructio/kubefirst-api/internal/k3d"
+	log "github.com/rs/zerolog/log"
+)
+
+// corsConfig returns the CORS policy set by the K1_CORS_* variables. Only the
+// console is allowed by default: https://kubefirst.<DOMAIN_NAME>, or the
+// console of a local k3d cluster when DOMAIN_NAME is not set. Preflight
+// requests are answered before authentication, since browsers never send
+// credentials with them.
+func corsConfig(env env.Env) cors.Config {
+	config := cors.Config{
+		AllowMethods: []string{
+			http.MethodDelete,
+			http.MethodGet,
+			http.MethodHead,
+			http.MethodPatch,
+			http.MethodPost,
+			http.MethodPut,
+			http.MethodOptions,
+		},
+		AllowHeaders:     env.CORSAllowedHeaders,
+		ExposeHeaders:    []string{"ETag", "Retry-After"},
+		AllowCredentials: env.CORSAllowCredentials,
+		AllowWildcard:    true,
+		MaxAge:           env.CORSMaxAge,
+	}
+
+	origins := []string{}
+	for _, origin := range env.CORSAllowedOrigins {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		switch {
+		case origin == "":
+		case origin == "*":
+			config.AllowAllOrigins = true
+		case !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://"):
+			log.Warn().Msgf("ignoring CORS origin %q, origins must start with http:// or https://", origin)
+		case strings.Count(origin, "*") > 1:
+			log.Warn().Msgf("ignoring CORS origin %q, origins may contain a single *", origin)
+		default:
+			origins = append(origins, origin)
+		}
+	}
+
+	switch {
+	case config.AllowAllOrigins:
+		if config.AllowCredentials {
+			log.Warn().Msg("K1_CORS_ALLOW_CREDENTIALS is ignored since K1_CORS_ALLOWED_ORIGINS allows every origin")
+			config.AllowCredentials = false
+		}
+	case len(origins) > 0:
+		config.AllowOrigins = origins
+	default:
+		config.AllowOrigins = []string{consoleOrigin(env.DomainName)}
+	}
+
+	if !slices.ContainsFunc(config.AllowHeaders, func(header string) bool {
+		return strings.EqualFold(header, "Authorization")
+	}) {
+		log.Warn().Msg("K1_CORS_ALLOWED_HEADERS does not list Authorization, browsers will not be able to call authenticated routes")
+	}
+
+	return config
+}
+
+// originAllowed returns whether an origin is allowed by config, matching
+// origins the way the CORS middleware does
+func originAllowed(config cors.Config) func(origin string) bool {
+	return func(origin string) bool {
+		if config.AllowAllOrigins {
+			return true
+		}
+
+		for _, allowed := range config.AllowOrigins {
+			prefix, suffix, wildcard := strings.Cut(allowed, "*")
+			if origin == allowed || (wildcard && len(origin) >= len(prefix)+len(suffix) &&
+				strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix)) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// consoleOrigin returns the origin of the console of a cluster with domainName
+func consoleOrigin(domainName string) string {
+	if domainName == "" || domainName == "unset" {
+		return k3d.KubefirstConsoleURL
+	}
+
+	return fmt.Sprintf("https://kubefirst.%s", domainName)
+}
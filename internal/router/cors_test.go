@@ -0,0 +1,107 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/konstructio/kubefirst-api/internal/env"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	headers := []string{"Origin", "Content-Type", "Authorization", "If-Match"}
+
+	tests := []struct {
+		name            string
+		env             env.Env
+		origin          string
+		wantAllowOrigin string
+		wantCredentials string
+	}{
+		{
+			name:            "console of the cluster domain",
+			env:             env.Env{DomainName: "example.com", CORSAllowedHeaders: headers},
+			origin:          "https://kubefirst.example.com",
+			wantAllowOrigin: "https://kubefirst.example.com",
+		},
+		{
+			name:   "other origin rejected by default",
+			env:    env.Env{DomainName: "example.com", CORSAllowedHeaders: headers},
+			origin: "https://evil.example.org",
+		},
+		{
+			name:            "configured origin with credentials",
+			env:             env.Env{CORSAllowedOrigins: []string{"https://console.example.com/"}, CORSAllowedHeaders: headers, CORSAllowCredentials: true},
+			origin:          "https://console.example.com",
+			wantAllowOrigin: "https://console.example.com",
+			wantCredentials: "true",
+		},
+		{
+			name:            "every origin without credentials",
+			env:             env.Env{CORSAllowedOrigins: []string{"*"}, CORSAllowedHeaders: headers, CORSAllowCredentials: true},
+			origin:          "https://evil.example.org",
+			wantAllowOrigin: "*",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(cors.New(corsConfig(tt.env)))
+			r.GET("/api/v1/cluster", func(c *gin.Context) {
+				c.Status(http.StatusUnauthorized)
+			})
+
+			req := httptest.NewRequest(http.MethodOptions, "/api/v1/cluster", nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+			req.Header.Set("Access-Control-Request-Headers", "authorization")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if tt.wantAllowOrigin == "" {
+				if w.Code != http.StatusForbidden {
+					t.Errorf("expected preflight to be rejected, got status %d", w.Code)
+				}
+				return
+			}
+
+			if w.Code != http.StatusNoContent {
+				t.Errorf("expected preflight to succeed without credentials, got status %d", w.Code)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllowOrigin {
+				t.Errorf("expected allowed origin %q, got %q", tt.wantAllowOrigin, got)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != tt.wantCredentials {
+				t.Errorf("expected allow credentials %q, got %q", tt.wantCredentials, got)
+			}
+		})
+	}
+}
+
+func TestOriginAllowed(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      env.Env
+		origin   string
+		expected bool
+	}{
+		{name: "console of the cluster domain", env: env.Env{DomainName: "example.com"}, origin: "https://kubefirst.example.com", expected: true},
+		{name: "other origin rejected by default", env: env.Env{DomainName: "example.com"}, origin: "https://evil.example.org"},
+		{name: "configured wildcard origin", env: env.Env{CORSAllowedOrigins: []string{"https://*.example.com"}}, origin: "https://console.example.com", expected: true},
+		{name: "outside a wildcard origin", env: env.Env{CORSAllowedOrigins: []string{"https://*.example.com"}}, origin: "https://console.example.org"},
+		{name: "every origin", env: env.Env{CORSAllowedOrigins: []string{"*"}}, origin: "https://evil.example.org", expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := originAllowed(corsConfig(tt.env))(tt.origin); got != tt.expected {
+				t.Errorf("expected %t, got %t", tt.expected, got)
+			}
+		})
+	}
+}
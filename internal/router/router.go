@@ -9,8 +9,11 @@ package api
 import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/env"
 	"github.com/konstructio/kubefirst-api/internal/middleware"
 	router "github.com/konstructio/kubefirst-api/internal/router/api/v1"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/rs/zerolog/log"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -24,89 +27,150 @@ func SetupRouter() *gin.Engine {
 	log.Info().Msg("Starting kubefirst API...")
 	r := gin.New()
 
-	// CORS
-	r.Use(cors.New(cors.Config{
-		AllowAllOrigins: true,
-		AllowMethods:    []string{"DELETE", "GET", "HEAD", "PATCH", "POST", "PUT", "OPTIONS"},
-		AllowHeaders:    []string{"origin", "content-Type"},
-	}))
+	env, _ := env.GetEnv(constants.SilenceGetEnv)
+
+	// The client IP used for rate limiting and logging is only read from
+	// X-Forwarded-For when the request comes from a trusted proxy
+	if err := r.SetTrustedProxies(env.TrustedProxies); err != nil {
+		log.Fatal().Msgf("error setting trusted proxies: %s", err)
+	}
+
+	// CORS, which WebSocket upgrades of the event stream are checked against
+	// as well since browsers do not apply it to them
+	corsCfg := corsConfig(env)
+	r.Use(cors.New(corsCfg))
+	router.SetEventsOrigins(originAllowed(corsCfg))
 
 	// Establish routes we don't want to log requests to
 	r.Use(gin.LoggerWithConfig(gin.LoggerConfig{
 		SkipPaths: []string{
 			"/api/v1/health",
+			"/metrics",
 		},
 	}))
 
+	// Prometheus metrics and traces, recorded outside of the recovery
+	// middleware so requests that panicked are counted with their 500
+	r.Use(middleware.Metrics(), middleware.Tracing())
+
 	// Recovery middleware
 	r.Use(gin.Recovery())
 
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
 	// Define api/v1 group
 	v1 := r.Group("api/v1")
+	v1.Use(middleware.RateLimit(), middleware.MaxBodySize(), middleware.Audit())
+
+	// Creating and deleting resources starts long running cloud operations,
+	// so those routes share a stricter rate limit
+	strict := middleware.StrictRateLimit()
 	{
 		// Cluster
-		v1.GET("/cluster", middleware.ValidateAPIKey(), router.GetClusters)
-		v1.POST("/cluster/import", middleware.ValidateAPIKey(), router.PostImportCluster)
-
-		v1.GET("/cluster/:cluster_name", middleware.ValidateAPIKey(), router.GetCluster)
-		v1.DELETE("/cluster/:cluster_name", middleware.ValidateAPIKey(), router.DeleteCluster)
-		v1.POST("/cluster/:cluster_name", middleware.ValidateAPIKey(), router.PostCreateCluster)
-		v1.GET("/cluster/:cluster_name/export", middleware.ValidateAPIKey(), router.GetExportCluster)
-		v1.POST("/cluster/:cluster_name/reset_progress", middleware.ValidateAPIKey(), router.PostResetClusterProgress)
-		v1.POST("/cluster/:cluster_name/vclusters", middleware.ValidateAPIKey(), router.PostCreateVcluster)
+		v1.GET("/cluster", middleware.ValidateAPIKey(constants.ScopeClustersRead), router.GetClusters)
+		v1.POST("/cluster/import", strict, middleware.ValidateAPIKey(constants.ScopeClustersWrite), router.PostImportCluster)
+		v1.POST("/cluster/validate", middleware.ValidateAPIKey(constants.ScopeClustersWrite), router.PostValidateCluster)
+
+		v1.GET("/cluster/:cluster_name", middleware.ValidateAPIKey(constants.ScopeClustersRead), router.GetCluster)
+		v1.PUT("/cluster/:cluster_name", middleware.ValidateAPIKey(constants.ScopeClustersWrite), router.PutCluster)
+		v1.DELETE("/cluster/:cluster_name", strict, middleware.ValidateAPIKey(constants.ScopeClustersWrite), router.DeleteCluster)
+		v1.POST("/cluster/:cluster_name", strict, middleware.ValidateAPIKey(constants.ScopeClustersWrite), router.PostCreateCluster)
+		v1.GET("/cluster/:cluster_name/steps", middleware.ValidateAPIKey(constants.ScopeClustersRead), router.GetClusterSteps)
+		v1.GET("/cluster/:cluster_name/export", middleware.ValidateAPIKey(constants.ScopeSecretsAdmin), router.GetExportCluster)
+		v1.GET("/cluster/:cluster_name/logs", middleware.ValidateAPIKey(constants.ScopeSecretsAdmin), router.GetClusterLogs)
+		v1.GET("/cluster/:cluster_name/logs/download", middleware.ValidateAPIKey(constants.ScopeSecretsAdmin), router.GetClusterLogsDownload)
+		v1.POST("/cluster/:cluster_name/reset_progress", middleware.ValidateAPIKey(constants.ScopeClustersWrite), router.PostResetClusterProgress)
+		v1.POST("/cluster/:cluster_name/retry", middleware.ValidateAPIKey(constants.ScopeClustersWrite), router.PostRetryCluster)
+		v1.POST("/cluster/:cluster_name/cancel", middleware.ValidateAPIKey(constants.ScopeClustersWrite), router.PostCancelCluster)
+		v1.PATCH("/cluster/:cluster_name/nodes", middleware.ValidateAPIKey(constants.ScopeClustersWrite), router.PatchClusterNodes)
+		v1.GET("/cluster/:cluster_name/drift", middleware.ValidateAPIKey(constants.ScopeClustersRead), router.GetClusterDrift)
+		v1.GET("/cluster/:cluster_name/upgrade", middleware.ValidateAPIKey(constants.ScopeClustersRead), router.GetClusterUpgrade)
+		v1.POST("/cluster/:cluster_name/upgrade", middleware.ValidateAPIKey(constants.ScopeClustersWrite), router.PostUpgradeCluster)
+		v1.POST("/cluster/:cluster_name/vclusters", strict, middleware.ValidateAPIKey(constants.ScopeClustersWrite), router.PostCreateVcluster)
+
+		// API keys
+		v1.GET("/apikeys", middleware.ValidateAPIKey(constants.ScopeAPIKeysAdmin), router.GetAPIKeys)
+		v1.POST("/apikeys", middleware.ValidateAPIKey(constants.ScopeAPIKeysAdmin), router.PostAPIKey)
+		v1.DELETE("/apikeys/:apikey_name", middleware.ValidateAPIKey(constants.ScopeAPIKeysAdmin), router.DeleteAPIKey)
+		v1.POST("/apikeys/:apikey_name/rotate", middleware.ValidateAPIKey(constants.ScopeAPIKeysAdmin), router.PostRotateAPIKey)
+
+		// Role bindings
+		v1.GET("/rolebindings", middleware.ValidateAPIKey(constants.ScopeRoleBindingsAdmin), router.GetRoleBindings)
+		v1.POST("/rolebindings", middleware.ValidateAPIKey(constants.ScopeRoleBindingsAdmin), router.PostRoleBinding)
+		v1.PUT("/rolebindings/:rolebinding_name", middleware.ValidateAPIKey(constants.ScopeRoleBindingsAdmin), router.PutRoleBinding)
+		v1.DELETE("/rolebindings/:rolebinding_name", middleware.ValidateAPIKey(constants.ScopeRoleBindingsAdmin), router.DeleteRoleBinding)
+
+		// Audit
+		v1.GET("/audit", middleware.ValidateAPIKey(constants.ScopeAuditRead), router.GetAudit)
+
+		// Notifications
+		v1.GET("/notifications/targets", middleware.ValidateAPIKey(constants.ScopeNotificationsAdmin), router.GetNotificationTargets)
+		v1.POST("/notifications/targets", middleware.ValidateAPIKey(constants.ScopeNotificationsAdmin), router.PostNotificationTarget)
+		v1.PUT("/notifications/targets/:target_name", middleware.ValidateAPIKey(constants.ScopeNotificationsAdmin), router.PutNotificationTarget)
+		v1.DELETE("/notifications/targets/:target_name", middleware.ValidateAPIKey(constants.ScopeNotificationsAdmin), router.DeleteNotificationTarget)
+		v1.POST("/notifications/targets/:target_name/test", middleware.ValidateAPIKey(constants.ScopeNotificationsAdmin), router.PostNotificationTargetTest)
+		v1.GET("/notifications/deliveries", middleware.ValidateAPIKey(constants.ScopeNotificationsAdmin), router.GetNotificationDeliveries)
+
+		// Jobs
+		v1.GET("/jobs", middleware.ValidateAPIKey(constants.ScopeClustersRead), router.GetJobs)
+		v1.GET("/jobs/:job_id", middleware.ValidateAPIKey(constants.ScopeClustersRead), router.GetJob)
 
 		// KubeConfig
-		v1.POST("/kubeconfig/:cloud_provider", middleware.ValidateAPIKey(), router.GetClusterKubeConfig)
+		v1.POST("/kubeconfig/:cloud_provider", middleware.ValidateAPIKey(constants.ScopeSecretsAdmin), router.GetClusterKubeConfig)
 
 		// Cluster Secret
-		v1.GET("/secret/:cluster_name/:secret", router.GetClusterSecret)
-		v1.POST("/secret/:cluster_name/:secret", router.CreateClusterSecret)
-		v1.PUT("/secret/:cluster_name/:secret", router.UpdateClusterSecret)
+		v1.GET("/secret/:cluster_name/:secret", middleware.ValidateAPIKey(constants.ScopeSecretsAdmin), router.GetClusterSecret)
+		v1.POST("/secret/:cluster_name/:secret", middleware.ValidateAPIKey(constants.ScopeSecretsAdmin), router.CreateClusterSecret)
+		v1.PUT("/secret/:cluster_name/:secret", middleware.ValidateAPIKey(constants.ScopeSecretsAdmin), router.UpdateClusterSecret)
 
 		// Gitops Catalog
-		v1.GET("/gitops-catalog/:cluster_name/:cloud_provider/apps", middleware.ValidateAPIKey(), router.GetGitopsCatalogApps)
-		v1.GET("/gitops-catalog/apps/update", middleware.ValidateAPIKey(), router.UpdateGitopsCatalogApps)
+		v1.GET("/gitops-catalog/:cluster_name/:cloud_provider/apps", middleware.ValidateAPIKey(constants.ScopeServicesRead), router.GetGitopsCatalogApps)
+		v1.GET("/gitops-catalog/apps/update", middleware.ValidateAPIKey(constants.ScopeServicesWrite), router.UpdateGitopsCatalogApps)
 
 		// Services
-		v1.GET("/services/:cluster_name", middleware.ValidateAPIKey(), router.GetServices)
-		v1.POST("/services/:cluster_name/:service_name", middleware.ValidateAPIKey(), router.PostAddServiceToCluster)
-		v1.POST("/services/:cluster_name/:service_name/validate", middleware.ValidateAPIKey(), router.PostValidateService)
-		v1.DELETE("/services/:cluster_name/:service_name", middleware.ValidateAPIKey(), router.DeleteServiceFromCluster)
+		v1.GET("/services/:cluster_name", middleware.ValidateAPIKey(constants.ScopeServicesRead), router.GetServices)
+		v1.POST("/services/:cluster_name/:service_name", strict, middleware.ValidateAPIKey(constants.ScopeServicesWrite), router.PostAddServiceToCluster)
+		v1.POST("/services/:cluster_name/:service_name/validate", middleware.ValidateAPIKey(constants.ScopeServicesWrite), router.PostValidateService)
+		v1.DELETE("/services/:cluster_name/:service_name", strict, middleware.ValidateAPIKey(constants.ScopeServicesWrite), router.DeleteServiceFromCluster)
 
 		// Domains
-		v1.POST("/domain/:dns_provider", middleware.ValidateAPIKey(), router.PostDomains)
-		v1.GET("/domain/validate/aws/:domain", middleware.ValidateAPIKey(), router.GetValidateAWSDomain)
-		v1.GET("/domain/validate/civo/:domain", middleware.ValidateAPIKey(), router.GetValidateCivoDomain)
-		v1.POST("/domain/validate/cloudflare/:domain", middleware.ValidateAPIKey(), router.PostValidateCloudflareDomain)
-		v1.POST("/domain/validate/digitalocean/:domain", middleware.ValidateAPIKey(), router.PostValidateDigitalOceanDomain)
-		// v1.GET("/domain/validate/vultr/:domain", middleware.ValidateAPIKey(), router.GetValidateVultrDomain)
-		// v1.GET("/domain/validate/google/:domain", middleware.ValidateAPIKey(), router.GetValidateGoogleDomain)
+		v1.POST("/domain/:dns_provider", middleware.ValidateAPIKey(constants.ScopeClustersRead), router.PostDomains)
+		v1.GET("/domain/validate/aws/:domain", middleware.ValidateAPIKey(constants.ScopeClustersRead), router.GetValidateAWSDomain)
+		v1.GET("/domain/validate/civo/:domain", middleware.ValidateAPIKey(constants.ScopeClustersRead), router.GetValidateCivoDomain)
+		v1.POST("/domain/validate/cloudflare/:domain", middleware.ValidateAPIKey(constants.ScopeClustersRead), router.PostValidateCloudflareDomain)
+		v1.POST("/domain/validate/digitalocean/:domain", middleware.ValidateAPIKey(constants.ScopeClustersRead), router.PostValidateDigitalOceanDomain)
+		// v1.GET("/domain/validate/vultr/:domain", middleware.ValidateAPIKey(constants.ScopeClustersRead), router.GetValidateVultrDomain)
+		// v1.GET("/domain/validate/google/:domain", middleware.ValidateAPIKey(constants.ScopeClustersRead), router.GetValidateGoogleDomain)
 		// Regions
-		v1.POST("/region/:cloud_provider", middleware.ValidateAPIKey(), router.PostRegions)
+		v1.POST("/region/:cloud_provider", middleware.ValidateAPIKey(constants.ScopeClustersRead), router.PostRegions)
 
 		// Zones *** Only supports google ***
-		v1.POST("/zones", middleware.ValidateAPIKey(), router.ListZonesForRegion)
+		v1.POST("/zones", middleware.ValidateAPIKey(constants.ScopeClustersRead), router.ListZonesForRegion)
 
 		// Instance Sizes
-		v1.POST("/instance-sizes/:cloud_provider", middleware.ValidateAPIKey(), router.ListInstanceSizesForRegion)
+		v1.POST("/instance-sizes/:cloud_provider", middleware.ValidateAPIKey(constants.ScopeClustersRead), router.ListInstanceSizesForRegion)
 
 		// Default instance size and node count for supported cloud providers
-		v1.GET("/cloud-defaults", middleware.ValidateAPIKey(), router.GetCloudProviderDefaults)
+		v1.GET("/cloud-defaults", middleware.ValidateAPIKey(constants.ScopeClustersRead), router.GetCloudProviderDefaults)
 
 		// Environments
-		v1.GET("/environment", middleware.ValidateAPIKey(), router.GetEnvironments)
-		v1.POST("/environment", middleware.ValidateAPIKey(), router.CreateEnvironment)
-		v1.DELETE("/environment/:environment_id", middleware.ValidateAPIKey(), router.DeleteEnvironment)
-		v1.PUT("/environment/:environment_id", middleware.ValidateAPIKey(), router.UpdateEnvironment)
+		v1.GET("/environment", middleware.ValidateAPIKey(constants.ScopeClustersRead), router.GetEnvironments)
+		v1.GET("/environment/:environment_id", middleware.ValidateAPIKey(constants.ScopeClustersRead), router.GetEnvironment)
+		v1.POST("/environment", strict, middleware.ValidateAPIKey(constants.ScopeClustersWrite), router.CreateEnvironment)
+		v1.DELETE("/environment/:environment_id", strict, middleware.ValidateAPIKey(constants.ScopeClustersWrite), router.DeleteEnvironment)
+		v1.PUT("/environment/:environment_id", middleware.ValidateAPIKey(constants.ScopeClustersWrite), router.UpdateEnvironment)
 
 		// Utilities
 		v1.GET("/health", router.GetHealth)
 
 		// Event streaming
-		v1.GET("/stream/:file_name", router.GetLogs)
+		v1.GET("/stream/:file_name", middleware.ValidateStreamToken(constants.ScopeSecretsAdmin), router.GetLogs)
+		v1.POST("/stream/:file_name/token", middleware.ValidateAPIKey(constants.ScopeSecretsAdmin), router.PostStreamToken)
+		v1.GET("/events", middleware.ValidateEventsToken(constants.ScopeClustersRead), router.GetEvents)
+		v1.POST("/events/token", middleware.ValidateAPIKey(constants.ScopeClustersRead), router.PostEventsToken)
 
 		// Telemetry
-		v1.POST("/telemetry/:cluster_name", middleware.ValidateAPIKey(), router.PostTelemetry)
+		v1.POST("/telemetry/:cluster_name", middleware.ValidateAPIKey(constants.ScopeClustersWrite), router.PostTelemetry)
 	}
 
 	// swagger-ui
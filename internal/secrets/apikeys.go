@@ -0,0 +1,180 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/konstructio/kubefirst-api/internal/k8s"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	apiKeySecretName = "kubefirst-apikeys"
+	apiKeyPrefix     = "kubefirst-apikey"
+)
+
+type APIKeyNotFoundError struct {
+	Name string
+}
+
+func (e *APIKeyNotFoundError) Error() string {
+	return fmt.Sprintf("api key %q not found", e.Name)
+}
+
+func (e *APIKeyNotFoundError) Is(target error) bool {
+	_, ok := target.(*APIKeyNotFoundError)
+	return ok
+}
+
+// GetAPIKey
+func GetAPIKey(clientSet kubernetes.Interface, name string) (*pkgtypes.APIKey, error) {
+	apiKey := pkgtypes.APIKey{}
+
+	apiKeySecret, err := k8s.ReadSecretV2Old(clientSet, "kubefirst", fmt.Sprintf("%s-%s", apiKeyPrefix, name))
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, &APIKeyNotFoundError{Name: name}
+		}
+
+		return nil, fmt.Errorf("secret not found: %w", err)
+	}
+
+	if isMapEmpty(apiKeySecret) {
+		return nil, &APIKeyNotFoundError{Name: name}
+	}
+
+	jsonString, err := MapToStructuredJSON(apiKeySecret)
+	if err != nil {
+		return nil, fmt.Errorf("error mapping to structured json: %w", err)
+	}
+
+	jsonData, err := json.Marshal(jsonString)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling json: %w", err)
+	}
+
+	err = json.Unmarshal(jsonData, &apiKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to cast api key: %w", err)
+	}
+
+	return &apiKey, nil
+}
+
+// GetAPIKeys
+func GetAPIKeys(clientSet kubernetes.Interface) ([]pkgtypes.APIKey, error) {
+	apiKeyList := []pkgtypes.APIKey{}
+	apiKeyReferenceList, err := GetSecretReference(clientSet, apiKeySecretName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return apiKeyList, nil
+		}
+
+		return nil, fmt.Errorf("unable to get secret api key reference: %w", err)
+	}
+
+	for _, name := range apiKeyReferenceList.List {
+		apiKey, err := GetAPIKey(clientSet, name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get api key %s: %w", name, err)
+		}
+
+		apiKeyList = append(apiKeyList, *apiKey)
+	}
+
+	return apiKeyList, nil
+}
+
+// InsertAPIKey
+func InsertAPIKey(clientSet kubernetes.Interface, apiKey pkgtypes.APIKey) error {
+	_, err := GetSecretReference(clientSet, apiKeySecretName)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("unable to get secret api key reference: %w", err)
+	}
+
+	if apierrors.IsNotFound(err) {
+		secretReference := pkgtypes.SecretListReference{
+			Name: "apikeys",
+			List: []string{apiKey.Name},
+		}
+		if err := UpsertSecretReference(clientSet, apiKeySecretName, secretReference); err != nil {
+			return fmt.Errorf("when inserting api key: error creating secret reference: %w", err)
+		}
+	} else if err := AddSecretReferenceItem(clientSet, apiKeySecretName, apiKey.Name); err != nil {
+		return fmt.Errorf("when inserting api key: error adding secret reference item: %w", err)
+	}
+
+	secretValuesMap, err := apiKeySecretData(apiKey)
+	if err != nil {
+		return err
+	}
+
+	secretToCreate := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%s", apiKeyPrefix, apiKey.Name),
+			Namespace: "kubefirst",
+		},
+		Data: secretValuesMap,
+	}
+
+	err = k8s.CreateSecretV2(clientSet, secretToCreate)
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes secret: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateAPIKey
+func UpdateAPIKey(clientSet kubernetes.Interface, apiKey pkgtypes.APIKey) error {
+	secretValuesMap, err := apiKeySecretData(apiKey)
+	if err != nil {
+		return err
+	}
+
+	err = k8s.UpdateSecretV2(clientSet, "kubefirst", fmt.Sprintf("%s-%s", apiKeyPrefix, apiKey.Name), secretValuesMap)
+	if err != nil {
+		return fmt.Errorf("error updating kubernetes secret: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteAPIKey
+func DeleteAPIKey(clientSet kubernetes.Interface, name string) error {
+	err := DeleteSecretReference(clientSet, apiKeySecretName, name)
+	if err != nil {
+		return fmt.Errorf("error deleting api key %s reference: %w", name, err)
+	}
+
+	err = k8s.DeleteSecretV2(clientSet, "kubefirst", fmt.Sprintf("%s-%s", apiKeyPrefix, name))
+	if err != nil {
+		return fmt.Errorf("error deleting api key %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func apiKeySecretData(apiKey pkgtypes.APIKey) (map[string][]byte, error) {
+	bytes, err := json.Marshal(apiKey)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling json: %w", err)
+	}
+
+	secretValuesMap, err := ParseJSONToMap(string(bytes))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing json to map: %w", err)
+	}
+
+	return secretValuesMap, nil
+}
@@ -0,0 +1,127 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/konstructio/kubefirst-api/internal/k8s"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// AuditSegmentPrefix prefixes the names of the Secrets holding audit
+	// entries. Each Secret is a segment holding a bounded number of entries.
+	AuditSegmentPrefix = "kubefirst-audit"
+	auditSegmentLabel  = "kubefirst.konstruct.io/audit"
+)
+
+// GetAuditSegments returns the names of all audit segments, oldest first
+func GetAuditSegments(clientSet kubernetes.Interface) ([]string, error) {
+	segmentList, err := clientSet.CoreV1().Secrets("kubefirst").List(context.Background(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=true", auditSegmentLabel),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing audit segments: %w", err)
+	}
+
+	segments := []string{}
+	for _, segment := range segmentList.Items {
+		if strings.HasPrefix(segment.Name, AuditSegmentPrefix) {
+			segments = append(segments, segment.Name)
+		}
+	}
+	sort.Strings(segments)
+
+	return segments, nil
+}
+
+// GetAuditSegment returns the entries of an audit segment
+func GetAuditSegment(clientSet kubernetes.Interface, segment string) ([]pkgtypes.AuditEntry, error) {
+	auditSecret, err := clientSet.CoreV1().Secrets("kubefirst").Get(context.Background(), segment, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error getting audit segment %s: %w", segment, err)
+	}
+
+	entries := make([]pkgtypes.AuditEntry, 0, len(auditSecret.Data))
+	for id, data := range auditSecret.Data {
+		var entry pkgtypes.AuditEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("unable to cast audit entry %s: %w", id, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// InsertAuditEntry adds an entry to an audit segment, creating the segment
+// when it does not exist yet. The segment is read again and the entry added
+// anew when another writer changed or created it in the meantime.
+func InsertAuditEntry(clientSet kubernetes.Interface, segment string, entry pkgtypes.AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error marshalling json: %w", err)
+	}
+
+	retriable := func(err error) bool {
+		return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err)
+	}
+
+	return retry.OnError(retry.DefaultRetry, retriable, func() error {
+		auditSecret, err := clientSet.CoreV1().Secrets("kubefirst").Get(context.Background(), segment, metav1.GetOptions{})
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				return fmt.Errorf("error getting audit segment %s: %w", segment, err)
+			}
+
+			secretToCreate := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      segment,
+					Namespace: "kubefirst",
+					Labels:    map[string]string{auditSegmentLabel: "true"},
+				},
+				Data: map[string][]byte{entry.ID: data},
+			}
+			if err := k8s.CreateSecretV2(clientSet, secretToCreate); err != nil {
+				return fmt.Errorf("error creating audit segment %s: %w", segment, err)
+			}
+
+			return nil
+		}
+
+		if auditSecret.Data == nil {
+			auditSecret.Data = map[string][]byte{}
+		}
+		auditSecret.Data[entry.ID] = data
+
+		if _, err := clientSet.CoreV1().Secrets("kubefirst").Update(context.Background(), auditSecret, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("error updating audit segment %s: %w", segment, err)
+		}
+
+		return nil
+	})
+}
+
+// DeleteAuditSegment deletes an audit segment and its entries
+func DeleteAuditSegment(clientSet kubernetes.Interface, segment string) error {
+	err := k8s.DeleteSecretV2(clientSet, "kubefirst", segment)
+	if err != nil {
+		return fmt.Errorf("error deleting audit segment %s: %w", segment, err)
+	}
+
+	return nil
+}
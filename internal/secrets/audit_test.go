@@ -0,0 +1,61 @@
+package secrets
+
+import (
+	"testing"
+
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestInsertAuditEntry(t *testing.T) {
+	const segment = "kubefirst-audit-20240501-100000-kubefirst-api-0"
+
+	tests := []struct {
+		name  string
+		fails map[string]error
+	}{
+		{name: "no conflict"},
+		{
+			name:  "segment created by another writer",
+			fails: map[string]error{"create": apierrors.NewAlreadyExists(schema.GroupResource{Resource: "secrets"}, segment)},
+		},
+		{
+			name:  "segment updated by another writer",
+			fails: map[string]error{"update": apierrors.NewConflict(schema.GroupResource{Resource: "secrets"}, segment, nil)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientSet := fake.NewSimpleClientset()
+			for verb, err := range tt.fails {
+				failed := false
+				clientSet.PrependReactor(verb, "secrets", func(k8stesting.Action) (bool, runtime.Object, error) {
+					if failed {
+						return false, nil, nil
+					}
+					failed = true
+					return true, nil, err
+				})
+			}
+
+			for _, id := range []string{"1", "2"} {
+				if err := InsertAuditEntry(clientSet, segment, pkgtypes.AuditEntry{ID: id}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			entries, err := GetAuditSegment(clientSet, segment)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 2 {
+				t.Errorf("expected 2 entries, got %d", len(entries))
+			}
+		})
+	}
+}
@@ -7,9 +7,12 @@ See the LICENSE file for more details.
 package secrets
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 
+	"github.com/konstructio/kubefirst-api/internal/envelope"
 	"github.com/konstructio/kubefirst-api/internal/k8s"
 	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
 	log "github.com/rs/zerolog/log"
@@ -17,6 +20,7 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
 )
 
 const (
@@ -24,6 +28,69 @@ const (
 	clusterPrefix = "kubefirst-cluster"
 )
 
+// encryptedClusterFields are the fields of a cluster record holding
+// credentials, which are encrypted at rest when a cluster codec is set
+var encryptedClusterFields = []string{
+	"akamai_auth",
+	"aws_auth",
+	"civo_auth",
+	"do_auth",
+	"vultr_auth",
+	"cloudflare_auth",
+	"git_auth",
+	"vault_auth",
+	"google_auth",
+	"k3s_auth",
+	"state_store_credentials",
+	"argocd_password",
+	"argocd_auth_token",
+	"atlantis_webhook_secret",
+	"workload_clusters",
+}
+
+// clusterCodec encrypts the credentials of cluster records. When it is nil,
+// cluster records are written in plain text.
+var clusterCodec *envelope.Codec
+
+// SetClusterCodec sets the codec cluster records are encrypted with
+func SetClusterCodec(codec *envelope.Codec) {
+	clusterCodec = codec
+}
+
+// sealCluster encrypts the credentials of a cluster record
+func sealCluster(clusterName string, data map[string][]byte) error {
+	if clusterCodec == nil {
+		return nil
+	}
+
+	if err := clusterCodec.Seal(context.Background(), clusterRecord(clusterName), data, encryptedClusterFields); err != nil {
+		return fmt.Errorf("error encrypting cluster %s: %w", clusterName, err)
+	}
+
+	return nil
+}
+
+// openCluster decrypts the credentials of a cluster record
+func openCluster(clusterName string, data map[string]interface{}) error {
+	if _, sealed := data[envelope.MetadataKey]; !sealed {
+		return nil
+	}
+
+	if clusterCodec == nil {
+		return fmt.Errorf("cluster %s is encrypted but no encryption provider is configured", clusterName)
+	}
+
+	if err := clusterCodec.Open(context.Background(), clusterRecord(clusterName), data); err != nil {
+		return fmt.Errorf("error decrypting cluster %s: %w", clusterName, err)
+	}
+
+	return nil
+}
+
+func clusterRecord(clusterName string) string {
+	return fmt.Sprintf("%s-%s", clusterPrefix, clusterName)
+}
+
 // DeleteCluster
 func DeleteCluster(clientSet kubernetes.Interface, clusterName string) error {
 	err := DeleteSecretReference(clientSet, secretName, clusterName)
@@ -36,6 +103,8 @@ func DeleteCluster(clientSet kubernetes.Interface, clusterName string) error {
 		return fmt.Errorf("error deleting cluster %s: %w", clusterName, err)
 	}
 
+	forgetClusterStatus(clusterName)
+
 	log.Info().Msgf("cluster deleted: %v", clusterName)
 
 	return nil
@@ -62,7 +131,7 @@ func (e *ClusterNotFoundError) Is(target error) bool {
 func GetCluster(clientSet kubernetes.Interface, clusterName string) (*pkgtypes.Cluster, error) {
 	cluster := pkgtypes.Cluster{}
 
-	clusterSecret, err := k8s.ReadSecretV2Old(clientSet, "kubefirst", fmt.Sprintf("%s-%s", clusterPrefix, clusterName))
+	clusterSecret, resourceVersion, err := k8s.ReadSecretV2WithResourceVersion(clientSet, "kubefirst", fmt.Sprintf("%s-%s", clusterPrefix, clusterName))
 	if err != nil {
 		if apierrors.IsNotFound(err) {
 			return nil, &ClusterNotFoundError{ClusterName: clusterName}
@@ -75,6 +144,10 @@ func GetCluster(clientSet kubernetes.Interface, clusterName string) (*pkgtypes.C
 		return nil, &ClusterNotFoundError{ClusterName: clusterName}
 	}
 
+	if err := openCluster(clusterName, clusterSecret); err != nil {
+		return nil, err
+	}
+
 	jsonString, err := MapToStructuredJSON(clusterSecret)
 	if err != nil {
 		return nil, fmt.Errorf("error mapping to structured json: %w", err)
@@ -89,6 +162,8 @@ func GetCluster(clientSet kubernetes.Interface, clusterName string) (*pkgtypes.C
 	if err != nil {
 		return nil, fmt.Errorf("unable to cast cluster: %w", err)
 	}
+	cluster.ResourceVersion = resourceVersion
+	observeClusterStatus(clusterName, cluster.Status)
 
 	return &cluster, nil
 }
@@ -146,6 +221,10 @@ func InsertCluster(clientSet kubernetes.Interface, cl pkgtypes.Cluster) error {
 		return fmt.Errorf("error parsing json to map: %w", err)
 	}
 
+	if err := sealCluster(cl.ClusterName, secretValuesMap); err != nil {
+		return err
+	}
+
 	secretToCreate := &v1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      fmt.Sprintf("%s-%s", clusterPrefix, cl.ClusterName),
@@ -158,26 +237,152 @@ func InsertCluster(clientSet kubernetes.Interface, cl pkgtypes.Cluster) error {
 	if err != nil {
 		return fmt.Errorf("error creating kubernetes secret: %w", err)
 	}
+	forgetClusterStatus(cl.ClusterName)
+	publishClusterStatus(cl.ClusterName, secretValuesMap)
 
 	return nil
 }
 
-// UpdateCluster
-func UpdateCluster(clientSet kubernetes.Interface, cluster pkgtypes.Cluster) error {
+// UpdateClusterWith applies mutate to the latest record of a cluster and
+// writes it back, starting over from the latest record when it changed in the
+// meantime. mutate may be called more than once. It returns the cluster as
+// written.
+func UpdateClusterWith(clientSet kubernetes.Interface, clusterName string, mutate func(cluster *pkgtypes.Cluster) error) (*pkgtypes.Cluster, error) {
+	var updated *pkgtypes.Cluster
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cluster, err := GetCluster(clientSet, clusterName)
+		if err != nil {
+			return err
+		}
+
+		if err := mutate(cluster); err != nil {
+			return err
+		}
+
+		data, err := clusterData(*cluster)
+		if err != nil {
+			return err
+		}
+
+		resourceVersion, err := writeCluster(clientSet, clusterName, data, cluster.ResourceVersion)
+		if err != nil {
+			return err
+		}
+		cluster.ResourceVersion = resourceVersion
+		updated = cluster
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// UpdateClusterFields applies mutate to cluster and to the latest record of
+// cluster, and writes the record back. Only the fields mutate sets are
+// changed in the record, so the fields other callers updated since cluster
+// was read are kept.
+func UpdateClusterFields(clientSet kubernetes.Interface, cluster *pkgtypes.Cluster, mutate func(cluster *pkgtypes.Cluster)) error {
+	mutate(cluster)
+
+	updated, err := UpdateClusterWith(clientSet, cluster.ClusterName, func(latest *pkgtypes.Cluster) error {
+		mutate(latest)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	cluster.ResourceVersion = updated.ResourceVersion
+
+	return nil
+}
+
+// writeCluster writes the fields of a cluster record if the record is still at
+// resourceVersion, and returns its new resourceVersion
+func writeCluster(clientSet kubernetes.Interface, clusterName string, data map[string][]byte, resourceVersion string) (string, error) {
+	secretValuesMap := make(map[string][]byte, len(data))
+	for key, value := range data {
+		secretValuesMap[key] = value
+	}
+
+	if err := sealCluster(clusterName, secretValuesMap); err != nil {
+		return "", err
+	}
+	seedClusterStatus(clientSet, clusterName)
+
+	written, err := k8s.UpdateSecretV2WithResourceVersion(clientSet, "kubefirst", clusterRecord(clusterName), resourceVersion, secretValuesMap)
+	if err != nil {
+		return "", fmt.Errorf("error updating kubernetes secret: %w", err)
+	}
+	publishClusterStatus(clusterName, data)
+
+	return written, nil
+}
+
+// clusterData returns the fields of the record of cluster
+func clusterData(cluster pkgtypes.Cluster) (map[string][]byte, error) {
 	bytes, err := json.Marshal(cluster)
 	if err != nil {
-		return fmt.Errorf("error marshalling json: %w", err)
+		return nil, fmt.Errorf("error marshalling json: %w", err)
 	}
 
 	secretValuesMap, err := ParseJSONToMap(string(bytes))
 	if err != nil {
-		return fmt.Errorf("error parsing json to map: %w", err)
+		return nil, fmt.Errorf("error parsing json to map: %w", err)
 	}
 
-	err = k8s.UpdateSecretV2(clientSet, "kubefirst", fmt.Sprintf("%s-%s", clusterPrefix, cluster.ClusterName), secretValuesMap)
+	return secretValuesMap, nil
+}
+
+// ReencryptClusters rewrites the cluster records that are not encrypted with
+// the current key, or every cluster record when force is set, and returns the
+// number of records rewritten
+func ReencryptClusters(clientSet kubernetes.Interface, force bool) (int, error) {
+	if clusterCodec == nil {
+		return 0, nil
+	}
+
+	clusterReferenceList, err := GetSecretReference(clientSet, secretName)
 	if err != nil {
-		return fmt.Errorf("error updating kubernetes secret: %w", err)
+		if apierrors.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("unable to get secret cluster reference: %w", err)
 	}
 
-	return nil
+	rewritten := 0
+	for _, clusterName := range clusterReferenceList.List {
+		clusterSecret, err := k8s.ReadSecretV2Old(clientSet, "kubefirst", clusterRecord(clusterName))
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return rewritten, fmt.Errorf("error reading cluster %s: %w", clusterName, err)
+		}
+
+		metadata, err := envelope.ReadMetadata(clusterSecret)
+		if err != nil {
+			return rewritten, fmt.Errorf("error reading encryption metadata of cluster %s: %w", clusterName, err)
+		}
+		if !force && clusterCodec.IsCurrent(metadata) {
+			continue
+		}
+
+		// writing the record back seals it with the current key
+		_, err = UpdateClusterWith(clientSet, clusterName, func(*pkgtypes.Cluster) error { return nil })
+		if err != nil {
+			if errors.Is(err, &ClusterNotFoundError{}) {
+				continue
+			}
+			return rewritten, fmt.Errorf("error re-encrypting cluster %s: %w", clusterName, err)
+		}
+		rewritten++
+
+		log.Info().Msgf("re-encrypted cluster %s", clusterName)
+	}
+
+	return rewritten, nil
 }
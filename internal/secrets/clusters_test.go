@@ -0,0 +1,62 @@
+package secrets
+
+import (
+	"testing"
+
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestUpdateClusterFields(t *testing.T) {
+	const clusterName = "fields"
+
+	clientSet := fake.NewSimpleClientset()
+	if err := InsertCluster(clientSet, pkgtypes.Cluster{ClusterName: clusterName}); err != nil {
+		t.Fatal(err)
+	}
+
+	stale, err := GetCluster(clientSet, clusterName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cluster *pkgtypes.Cluster
+		mutate  func(cluster *pkgtypes.Cluster)
+		applied func(cluster *pkgtypes.Cluster) bool
+	}{
+		{
+			name:    "read from the record",
+			cluster: stale,
+			mutate:  func(cluster *pkgtypes.Cluster) { cluster.GitInitCheck = true },
+			applied: func(cluster *pkgtypes.Cluster) bool { return cluster.GitInitCheck },
+		},
+		{
+			name:    "read before the previous update",
+			cluster: &pkgtypes.Cluster{ClusterName: clusterName, ResourceVersion: stale.ResourceVersion},
+			mutate:  func(cluster *pkgtypes.Cluster) { cluster.StateStoreCreateCheck = true },
+			applied: func(cluster *pkgtypes.Cluster) bool { return cluster.StateStoreCreateCheck },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := UpdateClusterFields(clientSet, tt.cluster, tt.mutate); err != nil {
+				t.Fatal(err)
+			}
+
+			if !tt.applied(tt.cluster) {
+				t.Errorf("expected the update to be applied to the cluster")
+			}
+		})
+	}
+
+	cluster, err := GetCluster(clientSet, clusterName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cluster.GitInitCheck || !cluster.StateStoreCreateCheck {
+		t.Errorf("expected every update to be kept, got git init check %t and state store create check %t", cluster.GitInitCheck, cluster.StateStoreCreateCheck)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
 )
 
 const (
@@ -41,7 +42,7 @@ func GetEnvironments(clientSet kubernetes.Interface) ([]pkgtypes.Environment, er
 func GetEnvironment(clientSet kubernetes.Interface, name string) (pkgtypes.Environment, error) {
 	environment := pkgtypes.Environment{}
 
-	kubefirstSecrets, _ := k8s.ReadSecretV2Old(clientSet, "kubefirst", fmt.Sprintf("%s-%s", kubefirstEnvironmentPrefix, name))
+	kubefirstSecrets, resourceVersion, _ := k8s.ReadSecretV2WithResourceVersion(clientSet, "kubefirst", fmt.Sprintf("%s-%s", kubefirstEnvironmentPrefix, name))
 	jsonString, _ := MapToStructuredJSON(kubefirstSecrets)
 
 	jsonData, err := json.Marshal(jsonString)
@@ -53,10 +54,47 @@ func GetEnvironment(clientSet kubernetes.Interface, name string) (pkgtypes.Envir
 	if err != nil {
 		return environment, fmt.Errorf("unable to cast environment %s: %w", name, err)
 	}
+	environment.ResourceVersion = resourceVersion
 
 	return environment, nil
 }
 
+type EnvironmentNotFoundError struct {
+	ID string
+}
+
+func (e *EnvironmentNotFoundError) Error() string {
+	return fmt.Sprintf("environment %q not found", e.ID)
+}
+
+func (e *EnvironmentNotFoundError) Is(target error) bool {
+	_, ok := target.(*EnvironmentNotFoundError)
+	return ok
+}
+
+// GetEnvironmentByID returns the environment with id
+func GetEnvironmentByID(clientSet kubernetes.Interface, id string) (pkgtypes.Environment, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return pkgtypes.Environment{}, fmt.Errorf("unable to cast object id: %w", err)
+	}
+
+	environmentSecretReference, err := GetSecretReference(clientSet, KubefirstEnvironmentSecretName)
+	if err != nil {
+		return pkgtypes.Environment{}, fmt.Errorf("unable to get secret environment reference: %w", err)
+	}
+
+	for _, environmentName := range environmentSecretReference.List {
+		environment, _ := GetEnvironment(clientSet, environmentName)
+
+		if environment.ID == objectID {
+			return environment, nil
+		}
+	}
+
+	return pkgtypes.Environment{}, &EnvironmentNotFoundError{ID: id}
+}
+
 // InsertEnvironment
 func InsertEnvironment(clientSet kubernetes.Interface, env pkgtypes.Environment) (pkgtypes.Environment, error) {
 	environment := pkgtypes.Environment{
@@ -146,44 +184,47 @@ func DeleteEnvironment(clientSet kubernetes.Interface, envID string) error {
 	return nil
 }
 
-func UpdateEnvironment(clientSet kubernetes.Interface, id string, env types.EnvironmentUpdateRequest) error {
-	objectID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		return fmt.Errorf("unable to cast object id: %w", err)
-	}
+// UpdateEnvironment updates the color and description of the environment with
+// id if it is at resourceVersion, or whatever its version when resourceVersion
+// is empty, and returns the environment as written
+func UpdateEnvironment(clientSet kubernetes.Interface, id string, env types.EnvironmentUpdateRequest, resourceVersion string) (pkgtypes.Environment, error) {
+	var updated pkgtypes.Environment
 
-	environmentSecretReference, err := GetSecretReference(clientSet, KubefirstEnvironmentSecretName)
-	if err != nil {
-		return fmt.Errorf("unable to get secret environment reference: %w", err)
-	}
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		environmentToUpdate, err := GetEnvironmentByID(clientSet, id)
+		if err != nil {
+			return err
+		}
 
-	environmentToUpdate := pkgtypes.Environment{}
+		if err := CheckResourceVersion(fmt.Sprintf("environment %q", environmentToUpdate.Name), environmentToUpdate.ResourceVersion, resourceVersion); err != nil {
+			return err
+		}
 
-	for _, environmentName := range environmentSecretReference.List {
-		environment, _ := GetEnvironment(clientSet, environmentName)
+		environmentToUpdate.Color = env.Color
+		environmentToUpdate.Description = env.Description
 
-		if environment.ID == objectID {
-			environmentToUpdate = environment
+		bytes, err := json.Marshal(environmentToUpdate)
+		if err != nil {
+			return fmt.Errorf("error marshalling json: %w", err)
 		}
-	}
-
-	environmentToUpdate.Color = env.Color
-	environmentToUpdate.Description = env.Description
 
-	bytes, err := json.Marshal(environmentToUpdate)
-	if err != nil {
-		return fmt.Errorf("error marshalling json: %w", err)
-	}
+		secretValuesMap, err := ParseJSONToMap(string(bytes))
+		if err != nil {
+			return fmt.Errorf("error parsing json: %w", err)
+		}
 
-	secretValuesMap, err := ParseJSONToMap(string(bytes))
-	if err != nil {
-		return fmt.Errorf("error parsing json: %w", err)
-	}
+		written, err := k8s.UpdateSecretV2WithResourceVersion(clientSet, "kubefirst", fmt.Sprintf("%s-%s", kubefirstEnvironmentPrefix, environmentToUpdate.Name), environmentToUpdate.ResourceVersion, secretValuesMap)
+		if err != nil {
+			return fmt.Errorf("error creating kubernetes secret: %w", err)
+		}
+		environmentToUpdate.ResourceVersion = written
+		updated = environmentToUpdate
 
-	err = k8s.UpdateSecretV2(clientSet, "kubefirst", fmt.Sprintf("%s-%s", kubefirstEnvironmentPrefix, environmentToUpdate.Name), secretValuesMap)
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("error creating kubernetes secret: %w", err)
+		return pkgtypes.Environment{}, err
 	}
 
-	return nil
+	return updated, nil
 }
@@ -0,0 +1,100 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package secrets
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/events"
+	"github.com/konstructio/kubefirst-api/internal/k8s"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	clusterStatusesMu sync.Mutex
+	clusterStatuses   = map[string]string{}
+)
+
+// observeClusterStatus records the status a cluster record was read with
+func observeClusterStatus(clusterName, status string) {
+	clusterStatusesMu.Lock()
+	defer clusterStatusesMu.Unlock()
+
+	clusterStatuses[clusterName] = status
+}
+
+// seedClusterStatus records the status of the stored record of a cluster
+// whose status has not been observed yet, such as after a restart, so the
+// next write is only published when it changes that status
+func seedClusterStatus(clientSet kubernetes.Interface, clusterName string) {
+	clusterStatusesMu.Lock()
+	_, known := clusterStatuses[clusterName]
+	clusterStatusesMu.Unlock()
+	if known {
+		return
+	}
+
+	record, _, err := k8s.ReadSecretV2WithResourceVersion(clientSet, "kubefirst", clusterRecord(clusterName))
+	if err != nil {
+		return
+	}
+
+	encoded, _ := record["status"].(string)
+	var status string
+	if err := json.Unmarshal([]byte(encoded), &status); err != nil {
+		return
+	}
+
+	clusterStatusesMu.Lock()
+	defer clusterStatusesMu.Unlock()
+
+	if _, known := clusterStatuses[clusterName]; !known {
+		clusterStatuses[clusterName] = status
+	}
+}
+
+// publishClusterStatus publishes a status_changed event when the fields
+// written to a cluster record change its status. The last condition of a
+// cluster in error is sent as the error of the event.
+func publishClusterStatus(clusterName string, data map[string][]byte) {
+	var status string
+	if err := json.Unmarshal(data["status"], &status); err != nil {
+		return
+	}
+
+	var condition string
+	if status == constants.ClusterStatusError {
+		_ = json.Unmarshal(data["last_condition"], &condition)
+	}
+
+	clusterStatusesMu.Lock()
+	previous, known := clusterStatuses[clusterName]
+	clusterStatuses[clusterName] = status
+	clusterStatusesMu.Unlock()
+
+	if known && previous == status {
+		return
+	}
+
+	events.Publish(events.Event{
+		Type:           events.StatusChanged,
+		Cluster:        clusterName,
+		Status:         status,
+		PreviousStatus: previous,
+		Error:          condition,
+	})
+}
+
+// forgetClusterStatus drops the status recorded for a cluster record
+func forgetClusterStatus(clusterName string) {
+	clusterStatusesMu.Lock()
+	defer clusterStatusesMu.Unlock()
+
+	delete(clusterStatuses, clusterName)
+}
@@ -0,0 +1,60 @@
+package secrets
+
+import (
+	"testing"
+
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/events"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWriteClusterAfterRestart(t *testing.T) {
+	const clusterName = "restarted"
+
+	clientSet := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterRecord(clusterName), Namespace: "kubefirst"},
+		Data:       map[string][]byte{"status": []byte(`"` + constants.ClusterStatusProvisioning + `"`)},
+	})
+	forgetClusterStatus(clusterName)
+
+	sub, _ := events.Default().Subscribe(clusterName, 0)
+	defer sub.Close()
+
+	tests := []struct {
+		name     string
+		status   string
+		expected *events.Event
+	}{
+		{name: "unchanged status", status: constants.ClusterStatusProvisioning},
+		{
+			name:     "changed status",
+			status:   constants.ClusterStatusProvisioned,
+			expected: &events.Event{Type: events.StatusChanged, Status: constants.ClusterStatusProvisioned, PreviousStatus: constants.ClusterStatusProvisioning},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := map[string][]byte{"status": []byte(`"` + tt.status + `"`)}
+			if _, err := writeCluster(clientSet, clusterName, data, ""); err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case event := <-sub.C:
+				if tt.expected == nil {
+					t.Fatalf("expected no event, got %+v", event)
+				}
+				if event.Type != tt.expected.Type || event.Status != tt.expected.Status || event.PreviousStatus != tt.expected.PreviousStatus {
+					t.Errorf("expected %+v, got %+v", *tt.expected, event)
+				}
+			default:
+				if tt.expected != nil {
+					t.Fatalf("expected %+v, got no event", *tt.expected)
+				}
+			}
+		})
+	}
+}
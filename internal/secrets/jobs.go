@@ -0,0 +1,258 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/k8s"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+	log "github.com/rs/zerolog/log"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	jobSecretName = "kubefirst-jobs"
+	jobPrefix     = "kubefirst-job"
+)
+
+type JobNotFoundError struct {
+	JobID string
+}
+
+func (e *JobNotFoundError) Error() string {
+	return fmt.Sprintf("job %q not found", e.JobID)
+}
+
+func (e *JobNotFoundError) Is(target error) bool {
+	_, ok := target.(*JobNotFoundError)
+	return ok
+}
+
+// GetJob
+func GetJob(clientSet kubernetes.Interface, jobID string) (*pkgtypes.Job, error) {
+	job, _, err := readJob(clientSet, jobID)
+	return job, err
+}
+
+// readJob returns a job along with the resourceVersion of its Secret
+func readJob(clientSet kubernetes.Interface, jobID string) (*pkgtypes.Job, string, error) {
+	job := pkgtypes.Job{}
+
+	jobSecret, resourceVersion, err := k8s.ReadSecretV2WithResourceVersion(clientSet, "kubefirst", jobRecord(jobID))
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, "", &JobNotFoundError{JobID: jobID}
+		}
+
+		return nil, "", fmt.Errorf("secret not found: %w", err)
+	}
+
+	if isMapEmpty(jobSecret) {
+		return nil, "", &JobNotFoundError{JobID: jobID}
+	}
+
+	jsonString, err := MapToStructuredJSON(jobSecret)
+	if err != nil {
+		return nil, "", fmt.Errorf("error mapping to structured json: %w", err)
+	}
+
+	jsonData, err := json.Marshal(jsonString)
+	if err != nil {
+		return nil, "", fmt.Errorf("error marshalling json: %w", err)
+	}
+
+	err = json.Unmarshal(jsonData, &job)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to cast job: %w", err)
+	}
+
+	return &job, resourceVersion, nil
+}
+
+func jobRecord(jobID string) string {
+	return fmt.Sprintf("%s-%s", jobPrefix, jobID)
+}
+
+// GetJobs returns the jobs in the job reference list. Jobs whose Secret is
+// gone, for example after being pruned by another replica, are skipped.
+func GetJobs(clientSet kubernetes.Interface) ([]pkgtypes.Job, error) {
+	jobList := []pkgtypes.Job{}
+	jobReferenceList, err := GetSecretReference(clientSet, jobSecretName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return jobList, nil
+		}
+
+		return nil, fmt.Errorf("unable to get secret job reference: %w", err)
+	}
+
+	for _, jobID := range jobReferenceList.List {
+		job, err := GetJob(clientSet, jobID)
+		if err != nil {
+			if errors.Is(err, &JobNotFoundError{}) {
+				log.Warn().Msgf("job %s is referenced but has no record, skipping", jobID)
+				continue
+			}
+			return nil, fmt.Errorf("unable to get job %s: %w", jobID, err)
+		}
+
+		jobList = append(jobList, *job)
+	}
+
+	return jobList, nil
+}
+
+// ClaimJob hands a queued or running job over to owner. The claim only
+// succeeds when the stored job still has the status and owner of job, so
+// only one of several replicas resuming the same job gets it. It returns
+// false when the job was changed or claimed in the meantime.
+func ClaimJob(clientSet kubernetes.Interface, job pkgtypes.Job, owner string) (bool, error) {
+	current, resourceVersion, err := readJob(clientSet, job.ID)
+	if err != nil {
+		if errors.Is(err, &JobNotFoundError{}) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if current.Status != job.Status || current.Owner != job.Owner {
+		return false, nil
+	}
+	current.Owner = owner
+
+	bytes, err := json.Marshal(current)
+	if err != nil {
+		return false, fmt.Errorf("error marshalling json: %w", err)
+	}
+
+	secretValuesMap, err := ParseJSONToMap(string(bytes))
+	if err != nil {
+		return false, fmt.Errorf("error parsing json to map: %w", err)
+	}
+
+	_, err = k8s.UpdateSecretV2WithResourceVersion(clientSet, "kubefirst", jobRecord(job.ID), resourceVersion, secretValuesMap)
+	if err != nil {
+		if apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error updating kubernetes secret: %w", err)
+	}
+
+	return true, nil
+}
+
+// PruneJobs deletes the oldest finished jobs beyond the latest keep finished
+// ones. Queued and running jobs are never deleted.
+func PruneJobs(clientSet kubernetes.Interface, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	allJobs, err := GetJobs(clientSet)
+	if err != nil {
+		return err
+	}
+
+	// job ids sort in the order the jobs were created
+	finished := []string{}
+	for _, job := range allJobs {
+		if job.Status != constants.JobStatusQueued && job.Status != constants.JobStatusRunning {
+			finished = append(finished, job.ID)
+		}
+	}
+	if len(finished) <= keep {
+		return nil
+	}
+	sort.Strings(finished)
+
+	for _, jobID := range finished[:len(finished)-keep] {
+		if err := DeleteSecretReference(clientSet, jobSecretName, jobID); err != nil {
+			return fmt.Errorf("error removing job %s from the job reference: %w", jobID, err)
+		}
+
+		err := clientSet.CoreV1().Secrets("kubefirst").Delete(context.Background(), jobRecord(jobID), metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("error deleting job %s: %w", jobID, err)
+		}
+	}
+
+	return nil
+}
+
+// InsertJob
+func InsertJob(clientSet kubernetes.Interface, job pkgtypes.Job) error {
+	_, err := GetSecretReference(clientSet, jobSecretName)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("unable to get secret job reference: %w", err)
+	}
+
+	if apierrors.IsNotFound(err) {
+		secretReference := pkgtypes.SecretListReference{
+			Name: "jobs",
+			List: []string{job.ID},
+		}
+		if err := UpsertSecretReference(clientSet, jobSecretName, secretReference); err != nil {
+			return fmt.Errorf("when inserting job: error creating secret reference: %w", err)
+		}
+	} else if err := AddSecretReferenceItem(clientSet, jobSecretName, job.ID); err != nil {
+		return fmt.Errorf("when inserting job: error adding secret reference item: %w", err)
+	}
+
+	bytes, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("error marshalling json: %w", err)
+	}
+
+	secretValuesMap, err := ParseJSONToMap(string(bytes))
+	if err != nil {
+		return fmt.Errorf("error parsing json to map: %w", err)
+	}
+
+	secretToCreate := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jobRecord(job.ID),
+			Namespace: "kubefirst",
+		},
+		Data: secretValuesMap,
+	}
+
+	err = k8s.CreateSecretV2(clientSet, secretToCreate)
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes secret: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateJob
+func UpdateJob(clientSet kubernetes.Interface, job pkgtypes.Job) error {
+	bytes, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("error marshalling json: %w", err)
+	}
+
+	secretValuesMap, err := ParseJSONToMap(string(bytes))
+	if err != nil {
+		return fmt.Errorf("error parsing json to map: %w", err)
+	}
+
+	err = k8s.UpdateSecretV2(clientSet, "kubefirst", jobRecord(job.ID), secretValuesMap)
+	if err != nil {
+		return fmt.Errorf("error updating kubernetes secret: %w", err)
+	}
+
+	return nil
+}
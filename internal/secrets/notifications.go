@@ -0,0 +1,268 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/konstructio/kubefirst-api/internal/k8s"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	notificationTargetSecretName = "kubefirst-notification-targets"
+	notificationTargetPrefix     = "kubefirst-notification-target"
+
+	// notificationDeliverySecretName holds the latest notification
+	// deliveries, keyed by their id
+	notificationDeliverySecretName = "kubefirst-notification-deliveries"
+)
+
+type NotificationTargetNotFoundError struct {
+	Name string
+}
+
+func (e *NotificationTargetNotFoundError) Error() string {
+	return fmt.Sprintf("notification target %q not found", e.Name)
+}
+
+func (e *NotificationTargetNotFoundError) Is(target error) bool {
+	_, ok := target.(*NotificationTargetNotFoundError)
+	return ok
+}
+
+// GetNotificationTarget
+func GetNotificationTarget(clientSet kubernetes.Interface, name string) (*pkgtypes.NotificationTarget, error) {
+	notificationTarget := pkgtypes.NotificationTarget{}
+
+	notificationTargetSecret, err := k8s.ReadSecretV2Old(clientSet, "kubefirst", fmt.Sprintf("%s-%s", notificationTargetPrefix, name))
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, &NotificationTargetNotFoundError{Name: name}
+		}
+
+		return nil, fmt.Errorf("secret not found: %w", err)
+	}
+
+	if isMapEmpty(notificationTargetSecret) {
+		return nil, &NotificationTargetNotFoundError{Name: name}
+	}
+
+	jsonString, err := MapToStructuredJSON(notificationTargetSecret)
+	if err != nil {
+		return nil, fmt.Errorf("error mapping to structured json: %w", err)
+	}
+
+	jsonData, err := json.Marshal(jsonString)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling json: %w", err)
+	}
+
+	err = json.Unmarshal(jsonData, &notificationTarget)
+	if err != nil {
+		return nil, fmt.Errorf("unable to cast notification target: %w", err)
+	}
+
+	return &notificationTarget, nil
+}
+
+// GetNotificationTargets
+func GetNotificationTargets(clientSet kubernetes.Interface) ([]pkgtypes.NotificationTarget, error) {
+	notificationTargetList := []pkgtypes.NotificationTarget{}
+	notificationTargetReferenceList, err := GetSecretReference(clientSet, notificationTargetSecretName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return notificationTargetList, nil
+		}
+
+		return nil, fmt.Errorf("unable to get secret notification target reference: %w", err)
+	}
+
+	for _, name := range notificationTargetReferenceList.List {
+		notificationTarget, err := GetNotificationTarget(clientSet, name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get notification target %s: %w", name, err)
+		}
+
+		notificationTargetList = append(notificationTargetList, *notificationTarget)
+	}
+
+	return notificationTargetList, nil
+}
+
+// InsertNotificationTarget
+func InsertNotificationTarget(clientSet kubernetes.Interface, notificationTarget pkgtypes.NotificationTarget) error {
+	_, err := GetSecretReference(clientSet, notificationTargetSecretName)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("unable to get secret notification target reference: %w", err)
+	}
+
+	if apierrors.IsNotFound(err) {
+		secretReference := pkgtypes.SecretListReference{
+			Name: "notification-targets",
+			List: []string{notificationTarget.Name},
+		}
+		if err := UpsertSecretReference(clientSet, notificationTargetSecretName, secretReference); err != nil {
+			return fmt.Errorf("when inserting notification target: error creating secret reference: %w", err)
+		}
+	} else if err := AddSecretReferenceItem(clientSet, notificationTargetSecretName, notificationTarget.Name); err != nil {
+		return fmt.Errorf("when inserting notification target: error adding secret reference item: %w", err)
+	}
+
+	secretValuesMap, err := notificationTargetSecretData(notificationTarget)
+	if err != nil {
+		return err
+	}
+
+	secretToCreate := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%s", notificationTargetPrefix, notificationTarget.Name),
+			Namespace: "kubefirst",
+		},
+		Data: secretValuesMap,
+	}
+
+	err = k8s.CreateSecretV2(clientSet, secretToCreate)
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes secret: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateNotificationTarget
+func UpdateNotificationTarget(clientSet kubernetes.Interface, notificationTarget pkgtypes.NotificationTarget) error {
+	secretValuesMap, err := notificationTargetSecretData(notificationTarget)
+	if err != nil {
+		return err
+	}
+
+	err = k8s.UpdateSecretV2(clientSet, "kubefirst", fmt.Sprintf("%s-%s", notificationTargetPrefix, notificationTarget.Name), secretValuesMap)
+	if err != nil {
+		return fmt.Errorf("error updating kubernetes secret: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteNotificationTarget
+func DeleteNotificationTarget(clientSet kubernetes.Interface, name string) error {
+	err := DeleteSecretReference(clientSet, notificationTargetSecretName, name)
+	if err != nil {
+		return fmt.Errorf("error deleting notification target %s reference: %w", name, err)
+	}
+
+	err = k8s.DeleteSecretV2(clientSet, "kubefirst", fmt.Sprintf("%s-%s", notificationTargetPrefix, name))
+	if err != nil {
+		return fmt.Errorf("error deleting notification target %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func notificationTargetSecretData(notificationTarget pkgtypes.NotificationTarget) (map[string][]byte, error) {
+	bytes, err := json.Marshal(notificationTarget)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling json: %w", err)
+	}
+
+	secretValuesMap, err := ParseJSONToMap(string(bytes))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing json to map: %w", err)
+	}
+
+	return secretValuesMap, nil
+}
+
+// GetNotificationDeliveries returns the recorded notification deliveries,
+// newest first
+func GetNotificationDeliveries(clientSet kubernetes.Interface) ([]pkgtypes.NotificationDelivery, error) {
+	deliveries := []pkgtypes.NotificationDelivery{}
+
+	deliverySecret, err := clientSet.CoreV1().Secrets("kubefirst").Get(context.Background(), notificationDeliverySecretName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return deliveries, nil
+		}
+
+		return nil, fmt.Errorf("error getting notification deliveries: %w", err)
+	}
+
+	for id, data := range deliverySecret.Data {
+		var delivery pkgtypes.NotificationDelivery
+		if err := json.Unmarshal(data, &delivery); err != nil {
+			return nil, fmt.Errorf("unable to cast notification delivery %s: %w", id, err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].ID > deliveries[j].ID
+	})
+
+	return deliveries, nil
+}
+
+// InsertNotificationDelivery records a notification delivery, dropping the
+// oldest deliveries beyond the latest keep
+func InsertNotificationDelivery(clientSet kubernetes.Interface, delivery pkgtypes.NotificationDelivery, keep int) error {
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		return fmt.Errorf("error marshalling json: %w", err)
+	}
+
+	deliverySecret, err := clientSet.CoreV1().Secrets("kubefirst").Get(context.Background(), notificationDeliverySecretName, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("error getting notification deliveries: %w", err)
+		}
+
+		secretToCreate := &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      notificationDeliverySecretName,
+				Namespace: "kubefirst",
+			},
+			Data: map[string][]byte{delivery.ID: data},
+		}
+		if err := k8s.CreateSecretV2(clientSet, secretToCreate); err != nil {
+			return fmt.Errorf("error creating notification deliveries: %w", err)
+		}
+
+		return nil
+	}
+
+	if deliverySecret.Data == nil {
+		deliverySecret.Data = map[string][]byte{}
+	}
+	deliverySecret.Data[delivery.ID] = data
+
+	// delivery ids sort in the order the deliveries were recorded
+	if keep > 0 && len(deliverySecret.Data) > keep {
+		ids := make([]string, 0, len(deliverySecret.Data))
+		for id := range deliverySecret.Data {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		for _, id := range ids[:len(ids)-keep] {
+			delete(deliverySecret.Data, id)
+		}
+	}
+
+	if _, err := clientSet.CoreV1().Secrets("kubefirst").Update(context.Background(), deliverySecret, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("error updating notification deliveries: %w", err)
+	}
+
+	return nil
+}
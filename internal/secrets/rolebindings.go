@@ -0,0 +1,180 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/konstructio/kubefirst-api/internal/k8s"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	roleBindingSecretName = "kubefirst-rolebindings"
+	roleBindingPrefix     = "kubefirst-rolebinding"
+)
+
+type RoleBindingNotFoundError struct {
+	Name string
+}
+
+func (e *RoleBindingNotFoundError) Error() string {
+	return fmt.Sprintf("role binding %q not found", e.Name)
+}
+
+func (e *RoleBindingNotFoundError) Is(target error) bool {
+	_, ok := target.(*RoleBindingNotFoundError)
+	return ok
+}
+
+// GetRoleBinding
+func GetRoleBinding(clientSet kubernetes.Interface, name string) (*pkgtypes.RoleBinding, error) {
+	roleBinding := pkgtypes.RoleBinding{}
+
+	roleBindingSecret, err := k8s.ReadSecretV2Old(clientSet, "kubefirst", fmt.Sprintf("%s-%s", roleBindingPrefix, name))
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, &RoleBindingNotFoundError{Name: name}
+		}
+
+		return nil, fmt.Errorf("secret not found: %w", err)
+	}
+
+	if isMapEmpty(roleBindingSecret) {
+		return nil, &RoleBindingNotFoundError{Name: name}
+	}
+
+	jsonString, err := MapToStructuredJSON(roleBindingSecret)
+	if err != nil {
+		return nil, fmt.Errorf("error mapping to structured json: %w", err)
+	}
+
+	jsonData, err := json.Marshal(jsonString)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling json: %w", err)
+	}
+
+	err = json.Unmarshal(jsonData, &roleBinding)
+	if err != nil {
+		return nil, fmt.Errorf("unable to cast role binding: %w", err)
+	}
+
+	return &roleBinding, nil
+}
+
+// GetRoleBindings
+func GetRoleBindings(clientSet kubernetes.Interface) ([]pkgtypes.RoleBinding, error) {
+	roleBindingList := []pkgtypes.RoleBinding{}
+	roleBindingReferenceList, err := GetSecretReference(clientSet, roleBindingSecretName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return roleBindingList, nil
+		}
+
+		return nil, fmt.Errorf("unable to get secret role binding reference: %w", err)
+	}
+
+	for _, name := range roleBindingReferenceList.List {
+		roleBinding, err := GetRoleBinding(clientSet, name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get role binding %s: %w", name, err)
+		}
+
+		roleBindingList = append(roleBindingList, *roleBinding)
+	}
+
+	return roleBindingList, nil
+}
+
+// InsertRoleBinding
+func InsertRoleBinding(clientSet kubernetes.Interface, roleBinding pkgtypes.RoleBinding) error {
+	_, err := GetSecretReference(clientSet, roleBindingSecretName)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("unable to get secret role binding reference: %w", err)
+	}
+
+	if apierrors.IsNotFound(err) {
+		secretReference := pkgtypes.SecretListReference{
+			Name: "rolebindings",
+			List: []string{roleBinding.Name},
+		}
+		if err := UpsertSecretReference(clientSet, roleBindingSecretName, secretReference); err != nil {
+			return fmt.Errorf("when inserting role binding: error creating secret reference: %w", err)
+		}
+	} else if err := AddSecretReferenceItem(clientSet, roleBindingSecretName, roleBinding.Name); err != nil {
+		return fmt.Errorf("when inserting role binding: error adding secret reference item: %w", err)
+	}
+
+	secretValuesMap, err := roleBindingSecretData(roleBinding)
+	if err != nil {
+		return err
+	}
+
+	secretToCreate := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%s", roleBindingPrefix, roleBinding.Name),
+			Namespace: "kubefirst",
+		},
+		Data: secretValuesMap,
+	}
+
+	err = k8s.CreateSecretV2(clientSet, secretToCreate)
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes secret: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateRoleBinding
+func UpdateRoleBinding(clientSet kubernetes.Interface, roleBinding pkgtypes.RoleBinding) error {
+	secretValuesMap, err := roleBindingSecretData(roleBinding)
+	if err != nil {
+		return err
+	}
+
+	err = k8s.UpdateSecretV2(clientSet, "kubefirst", fmt.Sprintf("%s-%s", roleBindingPrefix, roleBinding.Name), secretValuesMap)
+	if err != nil {
+		return fmt.Errorf("error updating kubernetes secret: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteRoleBinding
+func DeleteRoleBinding(clientSet kubernetes.Interface, name string) error {
+	err := DeleteSecretReference(clientSet, roleBindingSecretName, name)
+	if err != nil {
+		return fmt.Errorf("error deleting role binding %s reference: %w", name, err)
+	}
+
+	err = k8s.DeleteSecretV2(clientSet, "kubefirst", fmt.Sprintf("%s-%s", roleBindingPrefix, name))
+	if err != nil {
+		return fmt.Errorf("error deleting role binding %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func roleBindingSecretData(roleBinding pkgtypes.RoleBinding) (map[string][]byte, error) {
+	bytes, err := json.Marshal(roleBinding)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling json: %w", err)
+	}
+
+	secretValuesMap, err := ParseJSONToMap(string(bytes))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing json to map: %w", err)
+	}
+
+	return secretValuesMap, nil
+}
@@ -0,0 +1,35 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package secrets
+
+import "fmt"
+
+// PreconditionFailedError is returned when a record is no longer at the
+// version an update was made against
+type PreconditionFailedError struct {
+	Resource        string
+	ResourceVersion string
+}
+
+func (e *PreconditionFailedError) Error() string {
+	return fmt.Sprintf("%s has changed, its current version is %q", e.Resource, e.ResourceVersion)
+}
+
+func (e *PreconditionFailedError) Is(target error) bool {
+	_, ok := target.(*PreconditionFailedError)
+	return ok
+}
+
+// CheckResourceVersion returns a *PreconditionFailedError unless expected is
+// empty or the current version of resource
+func CheckResourceVersion(resource, current, expected string) error {
+	if expected != "" && expected != current {
+		return &PreconditionFailedError{Resource: resource, ResourceVersion: current}
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package secrets
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckResourceVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  string
+		expected string
+		wantErr  bool
+	}{
+		{name: "any version", current: "42"},
+		{name: "matching version", current: "42", expected: "42"},
+		{name: "stale version", current: "43", expected: "42", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckResourceVersion(`cluster "mgmt"`, tt.current, tt.expected)
+			if errors.Is(err, &PreconditionFailedError{}) != tt.wantErr {
+				t.Errorf("expected precondition failure: %t, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ package services
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -21,6 +22,7 @@ import (
 	githttps "github.com/go-git/go-git/v5/plumbing/transport/http"
 	vaultapi "github.com/hashicorp/vault/api"
 	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/events"
 	"github.com/konstructio/kubefirst-api/internal/gitShim"
 	"github.com/konstructio/kubefirst-api/internal/secrets"
 	internalutils "github.com/konstructio/kubefirst-api/internal/utils"
@@ -36,12 +38,13 @@ import (
 	cp "github.com/otiai10/copy"
 	log "github.com/rs/zerolog/log"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
 )
 
 // CreateService
 func CreateService(cl *pkgtypes.Cluster, serviceName string, appDef *pkgtypes.GitopsCatalogApp, req *pkgtypes.GitopsCatalogAppCreateRequest, excludeArgoSync bool) error {
 	switch cl.Status {
-	case constants.ClusterStatusDeleted, constants.ClusterStatusDeleting, constants.ClusterStatusError, constants.ClusterStatusProvisioning:
+	case constants.ClusterStatusCancelled, constants.ClusterStatusDeleted, constants.ClusterStatusDeleting, constants.ClusterStatusError, constants.ClusterStatusProvisioning:
 		return fmt.Errorf("cluster %q - unable to deploy service %q to cluster: cannot deploy services to a cluster in %q state", cl.ClusterName, serviceName, cl.Status)
 	}
 
@@ -131,7 +134,7 @@ func CreateService(cl *pkgtypes.Cluster, serviceName string, appDef *pkgtypes.Gi
 			return fmt.Errorf("cluster %q - error getting vault token: %w", clusterName, err)
 		}
 
-		vaultClient, err := vaultapi.NewClient(&vaultapi.Config{
+		vaultClient, err := vault.NewClient(&vaultapi.Config{
 			Address: vaultURL,
 		})
 		if err != nil {
@@ -238,7 +241,41 @@ func CreateService(cl *pkgtypes.Cluster, serviceName string, appDef *pkgtypes.Gi
 	}
 
 	// Wait for ArgoCD application sync
-	argocdClient, err := argocdapi.NewForConfig(kcfg.RestConfig)
+	if err := waitForServiceSync(cl, kcfg.RestConfig, fullDomainName, serviceName); err != nil {
+		// The sync error may name cloud resources, so subscribers only learn
+		// that the sync failed
+		events.Publish(events.Event{
+			Type:    events.ServiceSyncFailed,
+			Cluster: cl.ClusterName,
+			Service: serviceName,
+			Error:   fmt.Sprintf("service %s failed to sync, see the logs of the cluster", serviceName),
+		})
+
+		// The service is committed to the gitops repository by now, so a
+		// sync Argo CD has not finished in time is reported without failing
+		// the creation
+		if errors.Is(err, errSyncTimeout) {
+			log.Warn().Msg(err.Error())
+			return nil
+		}
+		return err
+	}
+
+	events.Publish(events.Event{Type: events.ServiceSynced, Cluster: cl.ClusterName, Service: serviceName})
+
+	return nil
+}
+
+// errSyncTimeout is returned when Argo CD has not synchronized the
+// application of a service in time
+var errSyncTimeout = errors.New("timed out waiting for the application to synchronize")
+
+// waitForServiceSync refreshes the registry application of a cluster and
+// waits for Argo CD to synchronize the application of a service
+func waitForServiceSync(cl *pkgtypes.Cluster, restConfig *rest.Config, fullDomainName, serviceName string) error {
+	clusterName := cl.ClusterName
+
+	argocdClient, err := argocdapi.NewForConfig(restConfig)
 	if err != nil {
 		return fmt.Errorf("cluster %q - error creating argocd client: %w", clusterName, err)
 	}
@@ -275,6 +312,7 @@ func CreateService(cl *pkgtypes.Cluster, serviceName string, appDef *pkgtypes.Gi
 	}
 
 	// Wait for app to be synchronized and healthy
+	synced := false
 	for i := 0; i < 50; i++ {
 		if i == 50 {
 			return fmt.Errorf("cluster %q - error waiting for app %q to synchronize: %w", clusterName, serviceName, err)
@@ -285,12 +323,17 @@ func CreateService(cl *pkgtypes.Cluster, serviceName string, appDef *pkgtypes.Gi
 		}
 		if app.Status.Sync.Status == v1alpha1.SyncStatusCodeSynced && app.Status.Health.Status == health.HealthStatusHealthy {
 			log.Info().Msgf("cluster %q - app %q synchronized", clusterName, serviceName)
+			synced = true
 			break
 		}
 		log.Info().Msgf("cluster %q - waiting for app %q to sync", clusterName, serviceName)
 		time.Sleep(time.Second * 10)
 	}
 
+	if !synced {
+		return fmt.Errorf("cluster %q - app %q: %w", clusterName, serviceName, errSyncTimeout)
+	}
+
 	return nil
 }
 
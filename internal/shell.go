@@ -8,13 +8,17 @@ package internal
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
+	"github.com/konstructio/kubefirst-api/internal/clusterlogs"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
@@ -59,36 +63,73 @@ func ExecShellReturnStringsV2(command string, args ...string) (string, error) {
 	return errb.String(), err
 }
 
+// outputLogger writes the output of a command to logger, which is the logger
+// of the run the command is part of
 type outputLogger struct {
+	logger  *zerolog.Logger
 	prefix  string
 	isError bool
 }
 
 func (l outputLogger) Write(p []byte) (int, error) {
 	if l.isError {
-		log.Error().Msgf("%s %s", l.prefix, string(p))
+		l.logger.Error().Msgf("%s %s", l.prefix, string(p))
 	} else {
-		log.Info().Msgf("%s %s", l.prefix, string(p))
+		l.logger.Info().Msgf("%s %s", l.prefix, string(p))
 	}
 	return len(p), nil
 }
 
+// interruptGracePeriod is how long a command is given to exit after being
+// interrupted before it is killed. Terraform uses this window to release its
+// state lock and persist partial state.
+const interruptGracePeriod = 2 * time.Minute
+
 // ExecShellWithVars Exec shell actions supporting:
 //   - On-the-fly logging of result
 //   - Map of Vars loaded
 func ExecShellWithVars(osvars map[string]string, command string, args ...string) error {
+	return ExecShellWithVarsContext(context.Background(), osvars, command, args...)
+}
+
+// ExecShellWithVarsContext behaves like ExecShellWithVars, but interrupts the
+// command when ctx is cancelled and logs its output to the run carried by ctx
+func ExecShellWithVarsContext(ctx context.Context, osvars map[string]string, command string, args ...string) error {
+	return execShellWithVarsContext(ctx, &outputLogger{logger: clusterlogs.Logger(ctx), prefix: command, isError: false}, osvars, command, args...)
+}
+
+// ExecShellWithVarsReturnStdoutContext behaves like ExecShellWithVarsContext, but
+// returns stdout to the caller instead of logging it
+func ExecShellWithVarsReturnStdoutContext(ctx context.Context, osvars map[string]string, command string, args ...string) (string, error) {
+	var outb bytes.Buffer
+	err := execShellWithVarsContext(ctx, &outb, osvars, command, args...)
+	return outb.String(), err
+}
+
+func execShellWithVarsContext(ctx context.Context, stdout io.Writer, osvars map[string]string, command string, args ...string) error {
+	logger := clusterlogs.Logger(ctx)
+
 	allvars := os.Environ()
 	for k, v := range osvars {
 		allvars = append(allvars, k+"="+v)
-		log.Info().Msgf("adding %s=%q to environment", k, strings.Repeat("*", len(v)))
+		logger.Info().Msgf("adding %s=%q to environment", k, strings.Repeat("*", len(v)))
 	}
 
-	cmd := exec.Command(command, args...)
-	cmd.Stdout = &outputLogger{prefix: command, isError: false}
-	cmd.Stderr = &outputLogger{prefix: command, isError: true}
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = &outputLogger{logger: logger, prefix: command, isError: true}
 	cmd.Env = allvars
+	cmd.Cancel = func() error {
+		logger.Warn().Msgf("interrupting %s", command)
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = interruptGracePeriod
 
 	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("command %s interrupted: %w", command, ctx.Err())
+		}
+
 		if exitError := new(exec.ExitError); errors.As(err, &exitError) {
 			return fmt.Errorf("command %s failed with exit code %d", command, exitError.ExitCode())
 		}
@@ -0,0 +1,46 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package internal
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/konstructio/kubefirst-api/internal/clusterlogs"
+)
+
+func TestExecShellWithVarsContextLogsToRun(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	ctx, closeLogs, err := clusterlogs.Start(context.Background(), "shell", "run-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ExecShellWithVarsContext(ctx, nil, "sh", "-c", "echo to stdout; echo to stderr >&2")
+	closeLogs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := clusterlogs.Path("shell", "run-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"to stdout", "to stderr"} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("expected the run logs to hold %q, got %s", expected, data)
+		}
+	}
+}
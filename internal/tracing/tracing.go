@@ -0,0 +1,236 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/konstructio/kubefirst-api/internal/env"
+	log "github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Services traced outside of the cloud and git provider SDKs
+const (
+	ServiceArgoCD = "argocd"
+	ServiceVault  = "vault"
+)
+
+// Span attributes describing kubefirst resources
+const (
+	ClusterKey  = attribute.Key("kubefirst.cluster")
+	StepKey     = attribute.Key("kubefirst.step")
+	ProviderKey = attribute.Key("kubefirst.provider")
+	JobKey      = attribute.Key("kubefirst.job")
+)
+
+var (
+	// tracer records the spans of the API. It delegates to the provider set by
+	// Configure, and records nothing until then.
+	tracer = otel.Tracer("github.com/konstructio/kubefirst-api")
+
+	// provider is the provider set by Configure, nil when traces are not
+	// exported
+	provider *sdktrace.TracerProvider
+)
+
+// Configure exports spans to the OTLP endpoint set by
+// K1_OTEL_EXPORTER_OTLP_ENDPOINT. When it is unset no span is recorded and no
+// trace context is propagated.
+func Configure(e env.Env) error {
+	if e.OTLPEndpoint == "" {
+		return nil
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpointURL(e.OTLPEndpoint),
+		otlptracehttp.WithHeaders(e.OTLPHeaders),
+	)
+	if err != nil {
+		return fmt.Errorf("error creating otlp exporter for %q: %w", e.OTLPEndpoint, err)
+	}
+
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(e.OTLPSampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName("kubefirst-api"),
+			semconv.ServiceVersion(e.KubefirstVersion),
+		)),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		log.Warn().Msgf("error exporting traces: %s", err)
+	}))
+
+	log.Info().Msgf("exporting traces to %s", e.OTLPEndpoint)
+	return nil
+}
+
+// Shutdown exports the spans still batched and stops exporting traces. It
+// does nothing when Configure did not set up an exporter.
+func Shutdown(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+
+	if err := provider.Shutdown(ctx); err != nil {
+		return fmt.Errorf("error shutting down tracer provider: %w", err)
+	}
+
+	return nil
+}
+
+// Start starts a span named name as a child of the span in ctx. The returned
+// function ends the span, marking it as failed when err is not nil.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, func(err error)) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ctx, span := tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+
+	return ctx, func(err error) {
+		end(span, err)
+	}
+}
+
+// Record records a call to service started at start as a child of the span
+// in ctx, for SDKs that neither accept a context nor let their HTTP client be
+// replaced
+func Record(ctx context.Context, service string, start time.Time, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	_, span := tracer.Start(ctx, service,
+		trace.WithTimestamp(start),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.PeerService(service)),
+	)
+	end(span, err)
+}
+
+// Inject returns the trace context of ctx, to be stored with work that
+// continues after the request
+func Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+
+	return carrier
+}
+
+// Extract returns ctx carrying the trace context stored by Inject
+func Extract(ctx context.Context, carrier map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(carrier))
+}
+
+// ServerRequest starts a span for a request received on route, continuing
+// the trace of the caller. The returned function ends the span with the
+// response status.
+func ServerRequest(req *http.Request, route string) (*http.Request, func(status int)) {
+	ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+	ctx, span := tracer.Start(ctx, req.Method+" "+route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.HTTPRoute(route),
+			semconv.URLPath(req.URL.Path),
+		),
+	)
+
+	return req.WithContext(ctx), func(status int) {
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		span.End()
+	}
+}
+
+// ClientRequest starts a span for a request sent to service and adds the
+// trace context to its headers. The returned function ends the span with the
+// response.
+func ClientRequest(service string, req *http.Request) (*http.Request, func(resp *http.Response, err error)) {
+	attrs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(req.Method),
+		semconv.ServerAddress(req.URL.Hostname()),
+	}
+	if service != "" {
+		attrs = append(attrs, semconv.PeerService(service))
+	}
+
+	ctx, span := tracer.Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	if span.SpanContext().IsValid() {
+		req = req.Clone(ctx)
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	}
+
+	return req, func(resp *http.Response, err error) {
+		if err == nil {
+			span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+			if resp.StatusCode >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+			}
+		}
+		end(span, err)
+	}
+}
+
+// Transport traces the requests sent to service through next, or through
+// http.DefaultTransport when next is nil
+func Transport(service string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if traced, ok := next.(roundTripper); ok && traced.service == service {
+		return traced
+	}
+
+	return roundTripper{service: service, next: next}
+}
+
+type roundTripper struct {
+	service string
+	next    http.RoundTripper
+}
+
+func (t roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req, done := ClientRequest(t.service, req)
+	resp, err := t.next.RoundTrip(req)
+	done(resp, err)
+
+	return resp, err //nolint:wrapcheck // the error is returned as is to the HTTP client
+}
+
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
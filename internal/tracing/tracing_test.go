@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracePropagation(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("traceparent")
+	}))
+	defer server.Close()
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/cluster/kubefirst", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+
+	// The request span continues the caller's trace
+	req, done := ServerRequest(req, "/api/v1/cluster/:cluster_name")
+	carrier := Inject(req.Context())
+	done(http.StatusAccepted)
+
+	// Work stored with the carrier joins the trace, as do its outbound calls
+	// and the calls recorded for SDKs
+	ctx, endJob := Start(Extract(context.Background(), carrier), "job")
+	outbound, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: Transport("test", nil)}).Do(outbound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	Record(ctx, "sdk", time.Now(), nil)
+	endJob(nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("expected 4 spans, got %d", len(spans))
+	}
+	for _, span := range spans {
+		if got := span.SpanContext().TraceID().String(); got != traceID {
+			t.Errorf("span %q has trace %s, expected %s", span.Name(), got, traceID)
+		}
+	}
+	if spans[0].SpanKind() != trace.SpanKindServer {
+		t.Errorf("expected the request span to be a server span, got %s", spans[0].SpanKind())
+	}
+	if received == "" {
+		t.Error("expected the outbound request to carry a traceparent header")
+	}
+}
@@ -0,0 +1,13 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package types
+
+// ClusterUpdateRequest holds the fields of a cluster that can be changed
+// once it has been created
+type ClusterUpdateRequest struct {
+	AlertsEmail string `bson:"alerts_email" json:"alerts_email" binding:"required"`
+}
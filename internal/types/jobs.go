@@ -0,0 +1,13 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package types
+
+// JobResponse describes a message returned by the API when work is enqueued
+type JobResponse struct {
+	Message string `json:"message" example:"cluster create enqueued"`
+	JobID   string `json:"job_id"`
+}
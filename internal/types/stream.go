@@ -10,3 +10,10 @@ type LogMessage struct {
 	Type    string `json:"-"`
 	Message string `json:"message"`
 }
+
+// StreamTokenResponse holds a short-lived token that opens a log or event stream
+// through the token query parameter
+type StreamTokenResponse struct {
+	Token     string `json:"token"`
+	ExpiresAt string `json:"expires_at"`
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/konstructio/kubefirst-api/internal/constants"
 	"github.com/konstructio/kubefirst-api/internal/env"
 	"github.com/konstructio/kubefirst-api/internal/k8s"
+	"github.com/konstructio/kubefirst-api/internal/metrics"
 	"github.com/konstructio/kubefirst-api/internal/secrets"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -112,12 +113,16 @@ func ScheduledGitopsCatalogUpdate() {
 	err := secrets.UpdateGitopsCatalogApps(kcfg.Clientset)
 	if err != nil {
 		log.Warn().Msg(err.Error())
+	} else {
+		metrics.GitopsCatalogUpdated(time.Now())
 	}
 	for range time.Tick(time.Minute * 30) {
 		err := secrets.UpdateGitopsCatalogApps(kcfg.Clientset)
 		if err != nil {
 			log.Warn().Msg(err.Error())
+			continue
 		}
+		metrics.GitopsCatalogUpdated(time.Now())
 	}
 }
 
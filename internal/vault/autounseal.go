@@ -13,19 +13,13 @@ import (
 )
 
 func (conf *Configuration) AutoUnseal() (*vaultapi.InitResponse, error) {
-	vaultClient, err := vaultapi.NewClient(&vaultapi.Config{
+	vaultClient, err := NewClient(&vaultapi.Config{
 		Address: VaultDefaultAddress,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("error creating vault client: %w", err)
 	}
 
-	if err := vaultClient.CloneConfig().ConfigureTLS(&vaultapi.TLSConfig{
-		Insecure: true,
-	}); err != nil {
-		return nil, fmt.Errorf("error configuring vault client TLS insecure flow: %w", err)
-	}
-
 	log.Info().Msg("created vault client, initializing vault with auto unseal")
 
 	initResponse, err := vaultClient.Sys().Init(&vaultapi.InitRequest{
@@ -7,7 +7,10 @@ See the LICENSE file for more details.
 package vault
 
 import (
+	"fmt"
+
 	"github.com/hashicorp/vault/api"
+	"github.com/konstructio/kubefirst-api/internal/tracing"
 )
 
 var Conf = Configuration{
@@ -17,3 +20,18 @@ var Conf = Configuration{
 func NewVault() *api.Config {
 	return api.DefaultConfig()
 }
+
+// NewClient returns a client for the Vault set by config whose requests are
+// traced. Fields left unset on config take the defaults of api.NewClient.
+func NewClient(config *api.Config) (*api.Client, error) {
+	client, err := api.NewClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating vault client for %s: %w", config.Address, err)
+	}
+
+	// api.NewClient fills in the HTTP client of config, which the client
+	// keeps using, so its transport is wrapped once the client is created
+	config.HttpClient.Transport = tracing.Transport(tracing.ServiceVault, config.HttpClient.Transport)
+
+	return client, nil
+}
@@ -12,7 +12,6 @@ import (
 	"os"
 	"strings"
 
-	vaultapi "github.com/hashicorp/vault/api"
 	"github.com/rs/zerolog/log"
 )
 
@@ -33,17 +32,12 @@ func (conf *Configuration) IterSecrets(endpoint, token, fileName string) error {
 
 	conf.Config.Address = endpoint
 
-	vaultClient, err := vaultapi.NewClient(conf.Config)
+	vaultClient, err := NewClient(conf.Config)
 	if err != nil {
 		return fmt.Errorf("error creating vault client: %w", err)
 	}
 
 	vaultClient.SetToken(token)
-	if strings.Contains(endpoint, "http://") {
-		vaultClient.CloneConfig().ConfigureTLS(&vaultapi.TLSConfig{
-			Insecure: true,
-		})
-	}
 
 	log.Info().Msg("created vault client")
 
@@ -9,9 +9,7 @@ package vault
 import (
 	"context"
 	"fmt"
-	"strings"
 
-	vaultapi "github.com/hashicorp/vault/api"
 	"github.com/rs/zerolog/log"
 )
 
@@ -19,17 +17,12 @@ import (
 func (conf *Configuration) GetUserPassword(endpoint string, token string, username string, key string) (string, error) {
 	conf.Config.Address = endpoint
 
-	vaultClient, err := vaultapi.NewClient(conf.Config)
+	vaultClient, err := NewClient(conf.Config)
 	if err != nil {
 		return "", fmt.Errorf("error creating vault client: %w", err)
 	}
 
 	vaultClient.SetToken(token)
-	if strings.Contains(endpoint, "http://") {
-		vaultClient.CloneConfig().ConfigureTLS(&vaultapi.TLSConfig{
-			Insecure: true,
-		})
-	}
 
 	log.Info().Msg("created vault client")
 
@@ -9,13 +9,14 @@ package vultr
 import (
 	"context"
 
+	"github.com/konstructio/kubefirst-api/internal/metrics"
 	"github.com/vultr/govultr/v3"
 	"golang.org/x/oauth2"
 )
 
 func NewVultr(vultrAPIKey string) *govultr.Client {
 	config := &oauth2.Config{}
-	ctx := context.Background()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, metrics.HTTPClient(metrics.ServiceVultr))
 	ts := config.TokenSource(ctx, &oauth2.Token{AccessToken: vultrAPIKey})
 	vultrClient := govultr.NewClient(oauth2.NewClient(ctx, ts))
 
@@ -7,20 +7,37 @@ See the LICENSE file for more details.
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/konstructio/kubefirst-api/docs"
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/encryption"
 	"github.com/konstructio/kubefirst-api/internal/env"
+	"github.com/konstructio/kubefirst-api/internal/jobs"
+	"github.com/konstructio/kubefirst-api/internal/notifications"
 	api "github.com/konstructio/kubefirst-api/internal/router"
 	"github.com/konstructio/kubefirst-api/internal/secrets"
 	"github.com/konstructio/kubefirst-api/internal/services"
 	apitelemetry "github.com/konstructio/kubefirst-api/internal/telemetry"
+	"github.com/konstructio/kubefirst-api/internal/tracing"
 	"github.com/konstructio/kubefirst-api/internal/utils"
 	"github.com/konstructio/kubefirst-api/pkg/types"
+	"github.com/konstructio/kubefirst-api/providers"
 	"github.com/kubefirst/metrics-client/pkg/telemetry"
 	log "github.com/rs/zerolog/log"
 )
 
+// shutdownTimeout is how long in-flight requests and batched spans are given
+// to complete once the API is asked to stop
+const shutdownTimeout = 10 * time.Second
+
 //	@title			Kubefirst API
 //	@version		1.0
 //	@description	Kubefirst API
@@ -35,6 +52,32 @@ func main() {
 		log.Fatal().Msg(err.Error())
 	}
 
+	// Encrypt cluster credentials at rest
+	if err := encryption.Configure(env); err != nil {
+		log.Fatal().Msgf("error configuring encryption: %s", err)
+	}
+
+	// Export traces when an OTLP endpoint is configured
+	if err := tracing.Configure(env); err != nil {
+		log.Fatal().Msgf("error configuring tracing: %s", err)
+	}
+
+	kcfg := utils.GetKubernetesClient("")
+
+	// `kubefirst-api rotate-encryption-key` re-encrypts every cluster record
+	// with a new data key and exits
+	if len(os.Args) > 1 && os.Args[1] == "rotate-encryption-key" {
+		if err := encryption.Rotate(kcfg.Clientset); err != nil {
+			log.Fatal().Msg(err.Error())
+		}
+		shutdownTracing()
+		return
+	}
+
+	if err := encryption.Migrate(kcfg.Clientset); err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+
 	if env.IsClusterZero {
 		log.Info().Msg("IS_CLUSTER_ZERO is set to true, skipping import cluster logic")
 	} else {
@@ -55,6 +98,19 @@ func main() {
 		}
 	}
 
+	// Notify the configured targets of cluster and service lifecycle events,
+	// including those of the jobs resumed below
+	notifications.Start(kcfg.Clientset)
+
+	// Resume any background jobs interrupted by a restart
+	err = jobs.ResumeJobs(kcfg.Clientset, map[string]jobs.Handler{
+		constants.JobTypeClusterCreate: providers.ResumeCreateCluster,
+		constants.JobTypeClusterDelete: providers.ResumeDeleteCluster,
+	})
+	if err != nil {
+		log.Warn().Msgf("error resuming jobs: %s", err)
+	}
+
 	// Programmatically set swagger info
 	docs.SwaggerInfo.Title = "Kubefirst API"
 	docs.SwaggerInfo.Description = "Kubefirst API"
@@ -84,15 +140,53 @@ func main() {
 	if !env.IsClusterZero {
 		// Subroutine to automatically update gitops catalog
 		go utils.ScheduledGitopsCatalogUpdate()
+		// Subroutine to check provisioned clusters for drift
+		go providers.ScheduledDriftDetection()
 	}
 	go apitelemetry.Heartbeat(telemetryEvent)
 
-	// API
+	// API, served until it fails or the pod is asked to stop
 	r := api.SetupRouter()
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%v", env.ServerPort),
+		Handler: r.Handler(),
+	}
 
-	err = r.Run(fmt.Sprintf(":%v", env.ServerPort))
-	if err != nil {
-		log.Fatal().Msgf("Error starting API: %s", err)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	var serveErr error
+	select {
+	case serveErr = <-serverErr:
+	case <-ctx.Done():
+		log.Info().Msg("stopping kubefirst API")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Warn().Msgf("error stopping API: %s", err)
+		}
+		cancel()
+	}
+
+	shutdownTracing()
+
+	if serveErr != nil && !errors.Is(serveErr, http.ErrServerClosed) {
+		log.Fatal().Msgf("Error starting API: %s", serveErr)
+	}
+}
+
+// shutdownTracing exports the spans still batched before the API exits
+func shutdownTracing() {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := tracing.Shutdown(ctx); err != nil {
+		log.Warn().Msgf("error exporting remaining traces: %s", err)
 	}
 }
 
@@ -49,3 +49,23 @@ func (c *Configuration) CreateObjectStorageBucketAndKeys(clusterName string) (*B
 
 	return &BucketAndKeysConfiguration{stateStoreData, stateStoreCredentialsData}, nil
 }
+
+// DeleteObjectStorageKeys removes the object storage access keys created for a cluster.
+// Keys are matched on their label, which is the cluster name.
+func (c *Configuration) DeleteObjectStorageKeys(clusterName string) error {
+	keys, err := c.Client.ListObjectStorageKeys(c.Context, nil)
+	if err != nil {
+		return fmt.Errorf("unable to list object storage keys: %w", err)
+	}
+
+	for _, key := range keys {
+		if key.Label != clusterName {
+			continue
+		}
+		if err := c.Client.DeleteObjectStorageKey(c.Context, key.ID); err != nil {
+			return fmt.Errorf("unable to delete object storage key %d: %w", key.ID, err)
+		}
+	}
+
+	return nil
+}
@@ -21,6 +21,7 @@ func NewEKSServiceAccountClientV1() aws.Config {
 	awsClient, err := config.LoadDefaultConfig(
 		context.Background(),
 		config.WithRegion(region),
+		awsinternal.WithRequestMetrics(),
 	)
 	if err != nil {
 		log.Error().Msg("unable to create aws client")
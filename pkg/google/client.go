@@ -0,0 +1,26 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package google
+
+import (
+	"net/http"
+
+	"github.com/konstructio/kubefirst-api/internal/metrics"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// httpClient returns an HTTP client for the REST APIs of Google Cloud,
+// authenticated with creds and recording the latency of its calls
+func httpClient(creds *google.Credentials) *http.Client {
+	return &http.Client{
+		Transport: &oauth2.Transport{
+			Source: creds.TokenSource,
+			Base:   metrics.Transport(metrics.ServiceGoogle, nil),
+		},
+	}
+}
@@ -128,7 +128,7 @@ func (conf *Configuration) GetDNSDomains() ([]string, error) {
 		return nil, fmt.Errorf("unable to create google storage client credentials: %w", err)
 	}
 
-	dnsService, err := googleDNS.NewService(conf.Context, option.WithCredentials(creds))
+	dnsService, err := googleDNS.NewService(conf.Context, option.WithHTTPClient(httpClient(creds)))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Google DNS service: %w", err)
 	}
@@ -152,7 +152,7 @@ func (conf *Configuration) ListInstances(zone string) ([]string, error) {
 		return nil, fmt.Errorf("unable to create google storage client credentials: %w", err)
 	}
 
-	machineTypeClient, err := compute.NewMachineTypesRESTClient(context.Background(), option.WithCredentials(creds))
+	machineTypeClient, err := compute.NewMachineTypesRESTClient(context.Background(), option.WithHTTPClient(httpClient(creds)))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create machine types REST client: %w", err)
 	}
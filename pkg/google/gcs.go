@@ -24,7 +24,7 @@ func (conf *Configuration) CreateBucket(bucketName string, keyFile []byte) (*sto
 	if err != nil {
 		return nil, fmt.Errorf("could not create google storage client credentials: %w", err)
 	}
-	client, err := storage.NewClient(conf.Context, option.WithCredentials(creds))
+	client, err := storage.NewClient(conf.Context, option.WithHTTPClient(httpClient(creds)))
 	if err != nil {
 		return nil, fmt.Errorf("could not create google storage client: %w", err)
 	}
@@ -58,7 +58,7 @@ func (conf *Configuration) DeleteBucket(bucketName string, keyFile []byte) error
 	if err != nil {
 		return fmt.Errorf("could not create google storage client credentials: %w", err)
 	}
-	client, err := storage.NewClient(conf.Context, option.WithCredentials(creds))
+	client, err := storage.NewClient(conf.Context, option.WithHTTPClient(httpClient(creds)))
 	if err != nil {
 		return fmt.Errorf("could not create google storage client: %w", err)
 	}
@@ -82,7 +82,7 @@ func (conf *Configuration) ListBuckets(keyFile []byte) ([]*storage.BucketAttrs,
 	if err != nil {
 		return nil, fmt.Errorf("could not create google storage client credentials: %w", err)
 	}
-	client, err := storage.NewClient(conf.Context, option.WithCredentials(creds))
+	client, err := storage.NewClient(conf.Context, option.WithHTTPClient(httpClient(creds)))
 	if err != nil {
 		return nil, fmt.Errorf("could not create google storage client: %w", err)
 	}
@@ -14,10 +14,12 @@ import (
 	container "cloud.google.com/go/container/apiv1"
 	containerpb "cloud.google.com/go/container/apiv1/containerpb"
 	"github.com/konstructio/kubefirst-api/internal/k8s"
+	"github.com/konstructio/kubefirst-api/internal/metrics"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	gocontainer "google.golang.org/api/container/v1"
 	"google.golang.org/api/option"
+	"google.golang.org/grpc"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
@@ -56,6 +58,23 @@ func (conf *Configuration) GetContainerCluster(clusterName string) (*containerpb
 	return cluster, nil
 }
 
+// ListMasterVersions returns the GKE control plane versions offered in the region
+func (conf *Configuration) ListMasterVersions() ([]string, error) {
+	client, err := container.NewClusterManagerClient(conf.Context)
+	if err != nil {
+		return nil, fmt.Errorf("could not create google container client: %w", err)
+	}
+
+	serverConfig, err := client.GetServerConfig(conf.Context, &containerpb.GetServerConfigRequest{
+		Name: fmt.Sprintf("projects/%s/locations/%s", conf.Project, conf.Region),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting container server config: %w", err)
+	}
+
+	return serverConfig.GetValidMasterVersions(), nil
+}
+
 // GetContainerClusterAuth
 func (conf *Configuration) GetContainerClusterAuth(clusterName string, keyFile []byte) (*k8s.KubernetesClient, error) {
 	creds, err := google.CredentialsFromJSON(conf.Context, keyFile, gocontainer.CloudPlatformScope)
@@ -63,7 +82,11 @@ func (conf *Configuration) GetContainerClusterAuth(clusterName string, keyFile [
 		return nil, fmt.Errorf("could not create google storage client credentials: %w", err)
 	}
 
-	client, err := container.NewClusterManagerClient(conf.Context, option.WithCredentials(creds))
+	client, err := container.NewClusterManagerClient(
+		conf.Context,
+		option.WithCredentials(creds),
+		option.WithGRPCDialOption(grpc.WithChainUnaryInterceptor(metrics.UnaryClientInterceptor(metrics.ServiceGoogle))),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("could not create google container client: %w", err)
 	}
@@ -30,7 +30,7 @@ func (conf *Configuration) GetRegions() ([]string, error) {
 		return nil, fmt.Errorf("could not create google storage client credentials: %w", err)
 	}
 
-	client, err := compute.NewRegionsRESTClient(conf.Context, option.WithCredentials(creds))
+	client, err := compute.NewRegionsRESTClient(conf.Context, option.WithHTTPClient(httpClient(creds)))
 	if err != nil {
 		return nil, fmt.Errorf("could not create google compute client: %w", err)
 	}
@@ -64,7 +64,7 @@ func (conf *Configuration) GetZones() ([]string, error) {
 		return nil, fmt.Errorf("could not create google storage client credentials: %w", err)
 	}
 
-	client, err := compute.NewZonesRESTClient(conf.Context, option.WithCredentials(creds))
+	client, err := compute.NewZonesRESTClient(conf.Context, option.WithHTTPClient(httpClient(creds)))
 	if err != nil {
 		return nil, fmt.Errorf("could not create google compute client: %w", err)
 	}
@@ -0,0 +1,33 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package types
+
+// APIKey is a named API key. Only a hash of the key is stored, the key itself
+// is returned once when the API key is created or rotated.
+type APIKey struct {
+	Name              string   `bson:"name" json:"name"`
+	HashedKey         string   `bson:"hashed_key,omitempty" json:"hashed_key,omitempty"`
+	Scopes            []string `bson:"scopes" json:"scopes"`
+	ExpiresAt         string   `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	CreationTimestamp string   `bson:"creation_timestamp" json:"creation_timestamp"`
+	LastRotatedAt     string   `bson:"last_rotated_at,omitempty" json:"last_rotated_at,omitempty"`
+}
+
+// APIKeyRequest creates a new API key
+type APIKeyRequest struct {
+	Name   string   `json:"name" binding:"required" example:"ci-pipeline"`
+	Scopes []string `json:"scopes" binding:"required" example:"clusters:read"`
+	// ExpiresAt is an RFC 3339 timestamp, the key never expires when empty
+	ExpiresAt string `json:"expires_at,omitempty" example:"2027-01-01T00:00:00Z"`
+}
+
+// APIKeyResponse is returned when an API key is created or rotated and is the
+// only time the key is shown
+type APIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
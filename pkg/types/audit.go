@@ -0,0 +1,27 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package types
+
+// AuditEntry records a mutating API call
+type AuditEntry struct {
+	ID        string `bson:"id" json:"id"`
+	Timestamp string `bson:"timestamp" json:"timestamp"`
+	Actor     string `bson:"actor" json:"actor"`
+	ActorKind string `bson:"actor_kind,omitempty" json:"actor_kind,omitempty"`
+	// Action is the method and route of the call, such as
+	// "DELETE /api/v1/cluster/:cluster_name"
+	Action string `bson:"action" json:"action"`
+	Method string `bson:"method" json:"method"`
+	Path   string `bson:"path" json:"path"`
+	// Target holds the path parameters of the call
+	Target map[string]string `bson:"target,omitempty" json:"target,omitempty"`
+	// Request is the request body with secret values redacted
+	Request  interface{} `bson:"request,omitempty" json:"request,omitempty"`
+	Status   int         `bson:"status" json:"status"`
+	Outcome  string      `bson:"outcome" json:"outcome"`
+	ClientIP string      `bson:"client_ip,omitempty" json:"client_ip,omitempty"`
+}
@@ -75,6 +75,8 @@ type K3sAuth struct {
 	K3sServersPrivateIps []string `bson:"servers_private_ips,omitempty" json:"servers_private_ips,omitempty"`
 	K3sServersPublicIps  []string `bson:"servers_public_ips,omitempty" json:"servers_public_ips,omitempty"`
 	K3sServersArgs       []string `bson:"servers_args,omitempty" json:"servers_args,omitempty"`
+	K3sAgentsPrivateIps  []string `bson:"agents_private_ips,omitempty" json:"agents_private_ips,omitempty"`
+	K3sAgentsPublicIps   []string `bson:"agents_public_ips,omitempty" json:"agents_public_ips,omitempty"`
 	K3sSSHUser           string   `bson:"ssh_user,omitempty" json:"ssh_user,omitempty"`
 	K3sSSHPrivateKey     string   `bson:"ssh_privatekey,omitempty" json:"ssh_privatekey,omitempty"`
 }
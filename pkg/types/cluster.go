@@ -61,6 +61,9 @@ type Cluster struct {
 	LastCondition string `bson:"last_condition" json:"last_condition"`
 	InProgress    bool   `bson:"in_progress" json:"in_progress"`
 
+	// Steps of the create pipeline, in run order
+	Steps []ClusterStep `bson:"steps,omitempty" json:"steps,omitempty"`
+
 	// Identifiers
 	AlertsEmail            string             `bson:"alerts_email" json:"alerts_email"`
 	CloudProvider          string             `bson:"cloud_provider" json:"cloud_provider"`
@@ -72,6 +75,8 @@ type Cluster struct {
 	SubdomainName          string             `bson:"subdomain_name" json:"subdomain_name,omitempty"`
 	DNSProvider            string             `bson:"dns_provider" json:"dns_provider"`
 	PostInstallCatalogApps []GitopsCatalogApp `bson:"post_install_catalog_apps,omitempty" json:"post_install_catalog_apps,omitempty"`
+	InstallKubefirstPro    bool               `bson:"install_kubefirst_pro,omitempty" json:"install_kubefirst_pro,omitempty"`
+	ForceDestroy           bool               `bson:"force_destroy,omitempty" json:"force_destroy,omitempty"`
 
 	// Auth
 	AkamaiAuth       AkamaiAuth       `bson:"akamai_auth,omitempty" json:"akamai_auth,omitempty"`
@@ -99,6 +104,13 @@ type Cluster struct {
 	NodeCount             int    `bson:"node_count" json:"node_count" binding:"required"`
 	LogFileName           string `bson:"log_file,omitempty" json:"log_file,omitempty"`
 
+	// Kubernetes version of the control plane and the upgrades run against it
+	KubernetesVersion string           `bson:"kubernetes_version,omitempty" json:"kubernetes_version,omitempty"`
+	UpgradeHistory    []ClusterUpgrade `bson:"upgrade_history,omitempty" json:"upgrade_history,omitempty"`
+
+	// Drift is the outcome of the last drift detection run
+	Drift *ClusterDrift `bson:"drift,omitempty" json:"drift,omitempty"`
+
 	StateStoreCredentials StateStoreCredentials `bson:"state_store_credentials,omitempty" json:"state_store_credentials,omitempty"`
 	StateStoreDetails     StateStoreDetails     `bson:"state_store_details,omitempty" json:"state_store_details,omitempty"`
 
@@ -118,26 +130,33 @@ type Cluster struct {
 	UseTelemetry bool `bson:"use_telemetry"`
 
 	// Checks
-	InstallToolsCheck              bool              `bson:"install_tools_check" json:"install_tools_check"`
-	DomainLivenessCheck            bool              `bson:"domain_liveness_check" json:"domain_liveness_check"`
-	StateStoreCredsCheck           bool              `bson:"state_store_creds_check" json:"state_store_creds_check"`
-	StateStoreCreateCheck          bool              `bson:"state_store_create_check" json:"state_store_create_check"`
-	GitInitCheck                   bool              `bson:"git_init_check" json:"git_init_check"`
-	KbotSetupCheck                 bool              `bson:"kbot_setup_check" json:"kbot_setup_check"`
-	GitopsReadyCheck               bool              `bson:"gitops_ready_check" json:"gitops_ready_check"`
-	GitTerraformApplyCheck         bool              `bson:"git_terraform_apply_check" json:"git_terraform_apply_check"`
-	GitopsPushedCheck              bool              `bson:"gitops_pushed_check" json:"gitops_pushed_check"`
-	CloudTerraformApplyCheck       bool              `bson:"cloud_terraform_apply_check" json:"cloud_terraform_apply_check"`
-	CloudTerraformApplyFailedCheck bool              `bson:"cloud_terraform_apply_failed_check" json:"cloud_terraform_apply_failed_check"`
-	ClusterSecretsCreatedCheck     bool              `bson:"cluster_secrets_created_check" json:"cluster_secrets_created_check"`
-	ArgoCDInstallCheck             bool              `bson:"argocd_install_check" json:"argocd_install_check"`
-	ArgoCDInitializeCheck          bool              `bson:"argocd_initialize_check" json:"argocd_initialize_check"`
-	ArgoCDCreateRegistryCheck      bool              `bson:"argocd_create_registry_check" json:"argocd_create_registry_check"`
-	ArgoCDDeleteRegistryCheck      bool              `bson:"argocd_delete_registry_check" json:"argocd_delete_registry_check"`
-	VaultInitializedCheck          bool              `bson:"vault_initialized_check" json:"vault_initialized_check"`
-	VaultTerraformApplyCheck       bool              `bson:"vault_terraform_apply_check" json:"vault_terraform_apply_check"`
-	UsersTerraformApplyCheck       bool              `bson:"users_terraform_apply_check" json:"users_terraform_apply_check"`
-	WorkloadClusters               []WorkloadCluster `bson:"workload_clusters,omitempty" json:"workload_clusters,omitempty"`
+	InstallToolsCheck              bool `bson:"install_tools_check" json:"install_tools_check"`
+	DomainLivenessCheck            bool `bson:"domain_liveness_check" json:"domain_liveness_check"`
+	StateStoreCredsCheck           bool `bson:"state_store_creds_check" json:"state_store_creds_check"`
+	StateStoreCreateCheck          bool `bson:"state_store_create_check" json:"state_store_create_check"`
+	GitInitCheck                   bool `bson:"git_init_check" json:"git_init_check"`
+	KbotSetupCheck                 bool `bson:"kbot_setup_check" json:"kbot_setup_check"`
+	GitopsReadyCheck               bool `bson:"gitops_ready_check" json:"gitops_ready_check"`
+	GitTerraformApplyCheck         bool `bson:"git_terraform_apply_check" json:"git_terraform_apply_check"`
+	GitopsPushedCheck              bool `bson:"gitops_pushed_check" json:"gitops_pushed_check"`
+	CloudTerraformApplyCheck       bool `bson:"cloud_terraform_apply_check" json:"cloud_terraform_apply_check"`
+	CloudTerraformApplyFailedCheck bool `bson:"cloud_terraform_apply_failed_check" json:"cloud_terraform_apply_failed_check"`
+	// CloudTerraformApplyCancelledCheck is set when the cloud terraform was
+	// interrupted by a cancelled run, leaving resources to apply again or destroy
+	CloudTerraformApplyCancelledCheck bool              `bson:"cloud_terraform_apply_cancelled_check,omitempty" json:"cloud_terraform_apply_cancelled_check,omitempty"`
+	ClusterSecretsCreatedCheck        bool              `bson:"cluster_secrets_created_check" json:"cluster_secrets_created_check"`
+	ArgoCDInstallCheck                bool              `bson:"argocd_install_check" json:"argocd_install_check"`
+	ArgoCDInitializeCheck             bool              `bson:"argocd_initialize_check" json:"argocd_initialize_check"`
+	ArgoCDCreateRegistryCheck         bool              `bson:"argocd_create_registry_check" json:"argocd_create_registry_check"`
+	ArgoCDDeleteRegistryCheck         bool              `bson:"argocd_delete_registry_check" json:"argocd_delete_registry_check"`
+	VaultInitializedCheck             bool              `bson:"vault_initialized_check" json:"vault_initialized_check"`
+	VaultTerraformApplyCheck          bool              `bson:"vault_terraform_apply_check" json:"vault_terraform_apply_check"`
+	UsersTerraformApplyCheck          bool              `bson:"users_terraform_apply_check" json:"users_terraform_apply_check"`
+	WorkloadClusters                  []WorkloadCluster `bson:"workload_clusters,omitempty" json:"workload_clusters,omitempty"`
+
+	// ResourceVersion is the version of the stored record this cluster was
+	// read from. It is not part of the record and is served as its ETag.
+	ResourceVersion string `bson:"-" json:"-"`
 }
 
 // StateStoreDetails
@@ -171,6 +190,10 @@ type Environment struct {
 	Color             string             `bson:"color" json:"color"`
 	Description       string             `bson:"description,omitempty" json:"description,omitempty"`
 	CreationTimestamp string             `bson:"creation_timestamp" json:"creation_timestamp"`
+
+	// ResourceVersion is the version of the stored record this environment
+	// was read from. It is not part of the record and is served as its ETag.
+	ResourceVersion string `bson:"-" json:"-"`
 }
 
 type WorkloadCluster struct {
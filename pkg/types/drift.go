@@ -0,0 +1,26 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package types
+
+// ClusterDrift compares a provisioned cluster to its gitops repository
+type ClusterDrift struct {
+	CheckedAt string `bson:"checked_at" json:"checked_at"`
+	Drifted   bool   `bson:"drifted" json:"drifted"`
+	// Stacks holds the terraform plan of each stack applied during the create
+	Stacks []TerraformDrift `bson:"stacks" json:"stacks"`
+	// OutOfSyncApplications lists the ArgoCD applications that are not synced
+	OutOfSyncApplications []string `bson:"out_of_sync_applications" json:"out_of_sync_applications"`
+	ArgoCDError           string   `bson:"argocd_error,omitempty" json:"argocd_error,omitempty"`
+}
+
+// TerraformDrift is the drift of a single terraform stack in the gitops repository
+type TerraformDrift struct {
+	Stack   string                `bson:"stack" json:"stack"`
+	Status  string                `bson:"status" json:"status"`
+	Message string                `bson:"message,omitempty" json:"message,omitempty"`
+	Plan    *TerraformPlanSummary `bson:"plan,omitempty" json:"plan,omitempty"`
+}
@@ -0,0 +1,47 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package types
+
+// DryRunCheck is the result of a single check run during a cluster create dry run
+type DryRunCheck struct {
+	Name    string `bson:"name" json:"name"`
+	Status  string `bson:"status" json:"status"`
+	Message string `bson:"message,omitempty" json:"message,omitempty"`
+}
+
+// TerraformPlanSummary counts the resource changes in a terraform plan
+type TerraformPlanSummary struct {
+	Add     int `bson:"add" json:"add"`
+	Change  int `bson:"change" json:"change"`
+	Destroy int `bson:"destroy" json:"destroy"`
+	// Resources counts the planned resource changes by resource type
+	Resources map[string]int `bson:"resources,omitempty" json:"resources,omitempty"`
+}
+
+// DryRunReport is returned by a cluster create dry run
+type DryRunReport struct {
+	ClusterName   string                `bson:"cluster_name" json:"cluster_name"`
+	CloudProvider string                `bson:"cloud_provider" json:"cloud_provider"`
+	Passed        bool                  `bson:"passed" json:"passed"`
+	Checks        []DryRunCheck         `bson:"checks" json:"checks"`
+	Plan          *TerraformPlanSummary `bson:"plan,omitempty" json:"plan,omitempty"`
+}
+
+// ValidationFinding is the outcome of a single pre-flight check of a
+// cluster definition
+type ValidationFinding struct {
+	Check       string `json:"check"`
+	Status      string `json:"status"`
+	Message     string `json:"message"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// ClusterValidationResponse is returned by the cluster definition validation
+type ClusterValidationResponse struct {
+	Valid    bool                `json:"valid"`
+	Findings []ValidationFinding `json:"findings"`
+}
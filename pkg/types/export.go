@@ -0,0 +1,20 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package types
+
+// ClusterExportBundle is an exported cluster record, encrypted with a key
+// derived from a passphrase chosen by the caller
+type ClusterExportBundle struct {
+	Version           int    `json:"version"`
+	ClusterName       string `json:"cluster_name"`
+	CreationTimestamp string `json:"creation_timestamp"`
+	// KDF names the key derivation function applied to the passphrase
+	KDF        string `json:"kdf"`
+	Salt       string `json:"salt"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
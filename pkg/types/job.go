@@ -0,0 +1,25 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package types
+
+// Job describes a unit of background work run by the API, such as
+// creating or deleting a cluster
+type Job struct {
+	ID                string `bson:"id" json:"id"`
+	Type              string `bson:"type" json:"type"`
+	ClusterName       string `bson:"cluster_name" json:"cluster_name"`
+	ServiceName       string `bson:"service_name,omitempty" json:"service_name,omitempty"`
+	Status            string `bson:"status" json:"status"`
+	Attempts          int    `bson:"attempts" json:"attempts"`
+	Owner             string `bson:"owner" json:"owner"`
+	LastError         string `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	CreationTimestamp string `bson:"creation_timestamp" json:"creation_timestamp"`
+	StartedAt         string `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	FinishedAt        string `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+	// TraceContext links the job to the trace of the request that created it
+	TraceContext map[string]string `bson:"trace_context,omitempty" json:"trace_context,omitempty"`
+}
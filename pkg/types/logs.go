@@ -0,0 +1,36 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package types
+
+import "time"
+
+// LogRun is the log file of a single provisioning run of a cluster. Runs of
+// background jobs are identified by the ID of the job.
+type LogRun struct {
+	ID        string    `json:"id"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Size      int64     `json:"size"`
+}
+
+// LogEntry is a line of a run's log file
+type LogEntry struct {
+	Time    string `json:"time,omitempty"`
+	Level   string `json:"level,omitempty"`
+	Message string `json:"message"`
+	// Line is the line as written to the log file
+	Line string `json:"line"`
+}
+
+// ClusterLogs holds the entries of a run matching a log search
+type ClusterLogs struct {
+	Run string `json:"run"`
+	// Runs lists the runs of the cluster, newest first
+	Runs    []LogRun   `json:"runs"`
+	Entries []LogEntry `json:"entries"`
+	// Truncated is set when older matching entries were left out
+	Truncated bool `json:"truncated"`
+}
@@ -0,0 +1,17 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package types
+
+// ClusterNodesRequest changes the node pool of a provisioned cluster. Fields
+// left empty keep their current value.
+type ClusterNodesRequest struct {
+	NodeType  string `json:"node_type,omitempty" example:"g4s.kube.large"`
+	NodeCount int    `json:"node_count,omitempty" example:"5"`
+	// ApplyMode is either terraform, where the api applies the change itself,
+	// or atlantis, where a pull request is opened for Atlantis to plan and apply
+	ApplyMode string `json:"apply_mode,omitempty" example:"terraform"`
+}
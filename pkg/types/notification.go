@@ -0,0 +1,56 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package types
+
+import "time"
+
+// NotificationTarget is a webhook notified of cluster and service lifecycle
+// events
+type NotificationTarget struct {
+	Name string `bson:"name" json:"name" binding:"required" example:"platform-slack"`
+	// Type is slack, teams, pagerduty or webhook
+	Type string `bson:"type" json:"type" binding:"required" example:"slack"`
+	// URL is the incoming webhook. It defaults to the PagerDuty Events API
+	// for pagerduty targets.
+	URL string `bson:"url" json:"url" example:"https://hooks.slack.com/services/T000/B000/XXXX"`
+	// RoutingKey is the integration key of a PagerDuty service
+	RoutingKey string `bson:"routing_key" json:"routing_key,omitempty"`
+	// SigningSecret signs the payloads sent to the target when set
+	SigningSecret string `bson:"signing_secret" json:"signing_secret,omitempty"`
+	// Events the target is notified of, every event when empty
+	Events            []string `bson:"events" json:"events" example:"cluster_failed"`
+	CreationTimestamp string   `bson:"creation_timestamp" json:"creation_timestamp"`
+}
+
+// Notification is the payload sent to webhook targets
+type Notification struct {
+	ID             string `json:"id"`
+	Event          string `json:"event"`
+	Cluster        string `json:"cluster"`
+	Service        string `json:"service,omitempty"`
+	Status         string `json:"status,omitempty"`
+	PreviousStatus string `json:"previous_status,omitempty"`
+	Message        string `json:"message"`
+	// Logs links to the logs of the cluster, which hold the details of a
+	// failure
+	Logs string    `json:"logs,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+// NotificationDelivery records the delivery of a notification to a target
+type NotificationDelivery struct {
+	ID             string `bson:"id" json:"id"`
+	Target         string `bson:"target" json:"target"`
+	NotificationID string `bson:"notification_id" json:"notification_id"`
+	Event          string `bson:"event" json:"event"`
+	Cluster        string `bson:"cluster" json:"cluster"`
+	Outcome        string `bson:"outcome" json:"outcome"`
+	Attempts       int    `bson:"attempts" json:"attempts"`
+	StatusCode     int    `bson:"status_code" json:"status_code,omitempty"`
+	Error          string `bson:"error" json:"error,omitempty"`
+	Timestamp      string `bson:"timestamp" json:"timestamp"`
+}
@@ -0,0 +1,29 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package types
+
+// RoleBinding grants a role to a set of subjects on a set of resources
+type RoleBinding struct {
+	Name              string                `bson:"name" json:"name" binding:"required" example:"developers-development"`
+	Role              string                `bson:"role" json:"role" binding:"required" example:"editor"`
+	Subjects          []RoleBindingSubject  `bson:"subjects" json:"subjects" binding:"required"`
+	Resources         []RoleBindingResource `bson:"resources" json:"resources" binding:"required"`
+	CreationTimestamp string                `bson:"creation_timestamp" json:"creation_timestamp"`
+}
+
+// RoleBindingSubject is an API key, an OIDC user or an OIDC group
+type RoleBindingSubject struct {
+	Kind string `bson:"kind" json:"kind" example:"group"`
+	Name string `bson:"name" json:"name" example:"developers"`
+}
+
+// RoleBindingResource is a cluster, workload cluster, environment or service.
+// A name of "*" matches every resource of the kind.
+type RoleBindingResource struct {
+	Kind string `bson:"kind" json:"kind" example:"environment"`
+	Name string `bson:"name" json:"name" example:"development"`
+}
@@ -0,0 +1,26 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package types
+
+// ClusterStep records the progress of a single step of the cluster
+// create pipeline
+type ClusterStep struct {
+	Name       string `bson:"name" json:"name"`
+	Status     string `bson:"status" json:"status"`
+	Attempts   int    `bson:"attempts" json:"attempts"`
+	LastError  string `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	StartedAt  string `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	FinishedAt string `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+}
+
+// ClusterRetryRequest selects which steps of the create pipeline to run again
+type ClusterRetryRequest struct {
+	// FromStep re-runs the named step and every step after it
+	FromStep string `json:"from_step,omitempty" example:"run_vault_terraform"`
+	// OnlySteps re-runs just the named steps
+	OnlySteps []string `json:"only_steps,omitempty"`
+}
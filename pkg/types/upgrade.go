@@ -0,0 +1,28 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package types
+
+// ClusterUpgradeRequest selects the Kubernetes version to upgrade a cluster to
+type ClusterUpgradeRequest struct {
+	KubernetesVersion string `json:"kubernetes_version" binding:"required" example:"1.29"`
+}
+
+// ClusterUpgradeVersions lists the Kubernetes versions a cluster can be upgraded to
+type ClusterUpgradeVersions struct {
+	CurrentVersion    string   `json:"current_version"`
+	AvailableVersions []string `json:"available_versions"`
+}
+
+// ClusterUpgrade records a single Kubernetes version upgrade of a cluster
+type ClusterUpgrade struct {
+	FromVersion string `bson:"from_version" json:"from_version"`
+	ToVersion   string `bson:"to_version" json:"to_version"`
+	Status      string `bson:"status" json:"status"`
+	Error       string `bson:"error,omitempty" json:"error,omitempty"`
+	StartedAt   string `bson:"started_at" json:"started_at"`
+	FinishedAt  string `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+}
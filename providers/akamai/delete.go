@@ -7,30 +7,36 @@ See the LICENSE file for more details.
 package akamai
 
 import (
+	"context"
 	"fmt"
-	"strconv"
+	"net/http"
 	"time"
 
-	"github.com/civo/civogo"
-	civoext "github.com/konstructio/kubefirst-api/extensions/civo"
+	akamaiext "github.com/konstructio/kubefirst-api/extensions/akamai"
 	terraformext "github.com/konstructio/kubefirst-api/extensions/terraform"
 	pkg "github.com/konstructio/kubefirst-api/internal"
 	"github.com/konstructio/kubefirst-api/internal/argocd"
+	"github.com/konstructio/kubefirst-api/internal/clusterlogs"
 	"github.com/konstructio/kubefirst-api/internal/constants"
 	"github.com/konstructio/kubefirst-api/internal/errors"
 	gitlab "github.com/konstructio/kubefirst-api/internal/gitlab"
 	"github.com/konstructio/kubefirst-api/internal/httpCommon"
 	"github.com/konstructio/kubefirst-api/internal/k8s"
+	"github.com/konstructio/kubefirst-api/internal/metrics"
 	"github.com/konstructio/kubefirst-api/internal/secrets"
 	"github.com/konstructio/kubefirst-api/internal/utils"
+	"github.com/konstructio/kubefirst-api/pkg/akamai"
 	"github.com/konstructio/kubefirst-api/pkg/providerConfigs"
 	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
 	"github.com/kubefirst/metrics-client/pkg/telemetry"
-	log "github.com/rs/zerolog/log"
+	"github.com/linode/linodego"
+	"golang.org/x/oauth2"
 )
 
 // DeleteAkamaiCluster
-func DeleteAkamaiCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryEvent) error {
+func DeleteAkamaiCluster(ctx context.Context, cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryEvent) error {
+	logger := clusterlogs.Logger(ctx)
+
 	telemetry.SendEvent(telemetryEvent, telemetry.ClusterDeleteStarted, "")
 
 	// Instantiate provider config
@@ -49,9 +55,9 @@ func DeleteAkamaiCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.Telemetr
 
 	kcfg := utils.GetKubernetesClient(cl.ClusterName)
 
-	cl.Status = constants.ClusterStatusDeleting
-
-	if err := secrets.UpdateCluster(kcfg.Clientset, *cl); err != nil {
+	if err := secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+		cluster.Status = constants.ClusterStatusDeleting
+	}); err != nil {
 		return fmt.Errorf("error updating cluster status: %w", err)
 	}
 
@@ -59,12 +65,12 @@ func DeleteAkamaiCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.Telemetr
 	var tfEntrypoint string
 
 	if cl.GitTerraformApplyCheck {
-		log.Info().Msgf("destroying %s resources with terraform", cl.GitProvider)
+		logger.Info().Msgf("destroying %s resources with terraform", cl.GitProvider)
 		switch cl.GitProvider {
 		case "github":
 			tfEntrypoint = config.GitopsDir + "/terraform/github"
-			tfEnvs = civoext.GetCivoTerraformEnvs(tfEnvs, cl)
-			tfEnvs = civoext.GetGithubTerraformEnvs(tfEnvs, cl)
+			tfEnvs = akamaiext.GetAkamaiTerraformEnvs(tfEnvs, cl)
+			tfEnvs = akamaiext.GetGithubTerraformEnvs(tfEnvs, cl)
 
 		case "gitlab":
 			gitlabClient, err := gitlab.NewGitLabClient(cl.GitAuth.Token, cl.GitAuth.Owner)
@@ -78,77 +84,60 @@ func DeleteAkamaiCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.Telemetr
 			for _, project := range projectsForDeletion {
 				projectExists, err := gitlabClient.CheckProjectExists(project)
 				if err != nil {
-					log.Error().Msgf("could not check for existence of project %s: %s", project, err)
+					logger.Error().Msgf("could not check for existence of project %s: %s", project, err)
 				}
 				if projectExists {
-					log.Info().Msgf("checking project %s for container registries...", project)
+					logger.Info().Msgf("checking project %s for container registries...", project)
 					crr, err := gitlabClient.GetProjectContainerRegistryRepositories(project)
 					if err != nil {
-						log.Error().Msgf("could not retrieve container registry repositories: %s", err)
+						logger.Error().Msgf("could not retrieve container registry repositories: %s", err)
 					}
 					if len(crr) > 0 {
 						for _, cr := range crr {
 							err := gitlabClient.DeleteContainerRegistryRepository(project, cr.ID)
 							if err != nil {
-								log.Error().Msgf("error deleting container registry repository: %s", err)
+								logger.Error().Msgf("error deleting container registry repository: %s", err)
 							}
 						}
 					} else {
-						log.Info().Msgf("project %s does not have any container registries, skipping", project)
+						logger.Info().Msgf("project %s does not have any container registries, skipping", project)
 					}
 				} else {
-					log.Info().Msgf("project %s does not exist, skipping", project)
+					logger.Info().Msgf("project %s does not exist, skipping", project)
 				}
 			}
 			tfEntrypoint = config.GitopsDir + "/terraform/gitlab"
-			tfEnvs = civoext.GetCivoTerraformEnvs(tfEnvs, cl)
-			tfEnvs = civoext.GetGitlabTerraformEnvs(tfEnvs, gitlabClient.ParentGroupID, cl)
+			tfEnvs = akamaiext.GetAkamaiTerraformEnvs(tfEnvs, cl)
+			tfEnvs = akamaiext.GetGitlabTerraformEnvs(tfEnvs, gitlabClient.ParentGroupID, cl)
 		}
 
 		err = terraformext.InitDestroyAutoApprove(config.TerraformClient, tfEntrypoint, tfEnvs)
 		if err != nil {
-			log.Info().Msgf("error executing terraform destroy %s", tfEntrypoint)
+			logger.Info().Msgf("error executing terraform destroy %s", tfEntrypoint)
 			errors.HandleClusterError(cl, err.Error())
 			return fmt.Errorf("error executing terraform destroy %s: %w", tfEntrypoint, err)
 		}
 
-		log.Info().Msgf("%s resources terraform destroyed", cl.GitProvider)
+		logger.Info().Msgf("%s resources terraform destroyed", cl.GitProvider)
 
-		cl.GitTerraformApplyCheck = false
-		err = secrets.UpdateCluster(kcfg.Clientset, *cl)
+		err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+			cluster.GitTerraformApplyCheck = false
+		})
 		if err != nil {
 			return fmt.Errorf("error updating cluster: %w", err)
 		}
 	}
 
-	if cl.CloudTerraformApplyCheck || cl.CloudTerraformApplyFailedCheck {
+	if cl.CloudTerraformApplyCheck || cl.CloudTerraformApplyFailedCheck || cl.CloudTerraformApplyCancelledCheck {
 		if !cl.ArgoCDDeleteRegistryCheck {
 			kcfg, err := k8s.CreateKubeConfig(false, config.Kubeconfig)
 			if err != nil {
 				return fmt.Errorf("error creating kubeconfig: %w", err)
 			}
 
-			log.Info().Msg("destroying civo resources with terraform")
-
-			client, err := civogo.NewClient(cl.AkamaiAuth.Token, cl.CloudRegion)
-			if err != nil {
-				return fmt.Errorf("error creating civo client: %w", err)
-			}
-
-			cluster, err := client.FindKubernetesCluster(cl.ClusterName)
-			if err != nil {
-				return fmt.Errorf("error finding civo kubernetes cluster: %w", err)
-			}
-			log.Info().Msg("cluster name: " + cluster.ID)
-
-			clusterVolumes, err := client.ListVolumesForCluster(cluster.ID)
-			if err != nil {
-				return fmt.Errorf("error listing volumes for cluster: %w", err)
-			}
-
 			// Only port-forward to ArgoCD and delete registry if ArgoCD was installed
 			if cl.ArgoCDInstallCheck {
-				log.Info().Msg("opening argocd port forward")
+				logger.Info().Msg("opening argocd port forward")
 				// * ArgoCD port-forward
 				argoCDStopChannel := make(chan struct{}, 1)
 				defer func() {
@@ -164,7 +153,7 @@ func DeleteAkamaiCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.Telemetr
 					argoCDStopChannel,
 				)
 
-				log.Info().Msg("getting new auth token for argocd")
+				logger.Info().Msg("getting new auth token for argocd")
 
 				secData, err := k8s.ReadSecretV2(kcfg.Clientset, "argocd", "argocd-initial-admin-secret")
 				if err != nil {
@@ -177,86 +166,94 @@ func DeleteAkamaiCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.Telemetr
 					return fmt.Errorf("error getting argocd token: %w", err)
 				}
 
-				log.Info().Msgf("port-forward to argocd is available at %s", providerConfigs.ArgocdPortForwardURL)
+				logger.Info().Msgf("port-forward to argocd is available at %s", providerConfigs.ArgocdPortForwardURL)
 
 				client := httpCommon.CustomHTTPClient(true)
-				log.Info().Msg("deleting the registry application")
+				logger.Info().Msg("deleting the registry application")
 				httpCode, _, err := argocd.DeleteApplication(client, config.RegistryAppName, argocdAuthToken, "true")
 				if err != nil {
 					errors.HandleClusterError(cl, err.Error())
 					return fmt.Errorf("error deleting argocd application: %w", err)
 				}
-				log.Info().Msgf("http status code %d", httpCode)
-			}
-
-			for _, vol := range clusterVolumes {
-				log.Info().Msg("removing volume with name: " + vol.Name)
-				_, err := client.DeleteVolume(vol.ID)
-				if err != nil {
-					return fmt.Errorf("error deleting volume: %w", err)
-				}
-				log.Info().Msg("volume " + vol.ID + " deleted")
+				logger.Info().Msgf("http status code %d", httpCode)
 			}
 
 			// Pause before cluster destroy to prevent a race condition
-			log.Info().Msg("waiting for Civo Kubernetes cluster resource removal to finish...")
+			logger.Info().Msg("waiting for Akamai Kubernetes cluster resource removal to finish...")
 			time.Sleep(time.Second * 10)
 
-			cl.ArgoCDDeleteRegistryCheck = true
-			err = secrets.UpdateCluster(kcfg.Clientset, *cl)
+			err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+				cluster.ArgoCDDeleteRegistryCheck = true
+			})
 			if err != nil {
 				return fmt.Errorf("error updating cluster: %w", err)
 			}
 		}
 
-		log.Info().Msg("destroying civo cloud resources")
+		logger.Info().Msg("destroying akamai cloud resources")
 		tfEntrypoint := config.GitopsDir + fmt.Sprintf("/terraform/%s", cl.CloudProvider)
 		tfEnvs := map[string]string{}
-		tfEnvs = civoext.GetCivoTerraformEnvs(tfEnvs, cl)
+		tfEnvs = akamaiext.GetAkamaiTerraformEnvs(tfEnvs, cl)
 
 		switch cl.GitProvider {
 		case "github":
-			tfEnvs = civoext.GetGithubTerraformEnvs(tfEnvs, cl)
+			tfEnvs = akamaiext.GetGithubTerraformEnvs(tfEnvs, cl)
 		case "gitlab":
-			gid, err := strconv.Atoi(strconv.Itoa(cl.GitlabOwnerGroupID))
-			if err != nil {
-				return fmt.Errorf("couldn't convert gitlab group id to int: %w", err)
-			}
-			tfEnvs = civoext.GetGitlabTerraformEnvs(tfEnvs, gid, cl)
+			tfEnvs = akamaiext.GetGitlabTerraformEnvs(tfEnvs, cl.GitlabOwnerGroupID, cl)
 		}
 		err = terraformext.InitDestroyAutoApprove(config.TerraformClient, tfEntrypoint, tfEnvs)
 		if err != nil {
-			log.Printf("error executing terraform destroy %s", tfEntrypoint)
+			logger.Printf("error executing terraform destroy %s", tfEntrypoint)
 			errors.HandleClusterError(cl, err.Error())
 			return fmt.Errorf("error executing terraform destroy %s: %w", tfEntrypoint, err)
 		}
-		log.Info().Msg("civo resources terraform destroyed")
+		logger.Info().Msg("akamai resources terraform destroyed")
 
-		cl.CloudTerraformApplyCheck = false
-		cl.CloudTerraformApplyFailedCheck = false
-		err = secrets.UpdateCluster(kcfg.Clientset, *cl)
+		err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+			cluster.CloudTerraformApplyCheck = false
+			cluster.CloudTerraformApplyFailedCheck = false
+			cluster.CloudTerraformApplyCancelledCheck = false
+		})
 		if err != nil {
 			return fmt.Errorf("error updating cluster: %w", err)
 		}
 	}
 
+	// The object storage keys are only needed by the terraform backend, so they
+	// are removed once all terraform has been destroyed
+	logger.Info().Msg("deleting akamai object storage keys")
+	akamaiConf := akamai.Configuration{
+		Client: linodego.NewClient(&http.Client{
+			Transport: &oauth2.Transport{
+				Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: cl.AkamaiAuth.Token}),
+				Base:   metrics.Transport(metrics.ServiceAkamai, nil),
+			},
+		}),
+		Context: context.Background(),
+	}
+	if err := akamaiConf.DeleteObjectStorageKeys(cl.ClusterName); err != nil {
+		errors.HandleClusterError(cl, err.Error())
+		return fmt.Errorf("error deleting object storage keys: %w", err)
+	}
+
 	// remove ssh key provided one was created
 	if cl.GitProvider == "gitlab" {
 		gitlabClient, err := gitlab.NewGitLabClient(cl.GitAuth.Token, cl.GitAuth.Owner)
 		if err != nil {
 			return fmt.Errorf("error creating gitlab client: %w", err)
 		}
-		log.Info().Msg("attempting to delete managed ssh key...")
+		logger.Info().Msg("attempting to delete managed ssh key...")
 		err = gitlabClient.DeleteUserSSHKey("kbot-ssh-key")
 		if err != nil {
-			log.Warn().Msg(err.Error())
+			logger.Warn().Msg(err.Error())
 		}
 	}
 
 	telemetry.SendEvent(telemetryEvent, telemetry.ClusterDeleteCompleted, "")
 
-	cl.Status = constants.ClusterStatusDeleted
-	err = secrets.UpdateCluster(kcfg.Clientset, *cl)
+	err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+		cluster.Status = constants.ClusterStatusDeleted
+	})
 	if err != nil {
 		return fmt.Errorf("error updating cluster: %w", err)
 	}
@@ -7,6 +7,7 @@ See the LICENSE file for more details.
 package aws
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"time"
@@ -16,6 +17,7 @@ import (
 	pkg "github.com/konstructio/kubefirst-api/internal"
 	"github.com/konstructio/kubefirst-api/internal/argocd"
 	awsinternal "github.com/konstructio/kubefirst-api/internal/aws"
+	"github.com/konstructio/kubefirst-api/internal/clusterlogs"
 	"github.com/konstructio/kubefirst-api/internal/constants"
 	"github.com/konstructio/kubefirst-api/internal/errors"
 	gitlab "github.com/konstructio/kubefirst-api/internal/gitlab"
@@ -26,11 +28,12 @@ import (
 	"github.com/konstructio/kubefirst-api/pkg/providerConfigs"
 	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
 	"github.com/kubefirst/metrics-client/pkg/telemetry"
-	log "github.com/rs/zerolog/log"
 )
 
 // DeleteAWSCluster
-func DeleteAWSCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryEvent) error {
+func DeleteAWSCluster(ctx context.Context, cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryEvent) error {
+	logger := clusterlogs.Logger(ctx)
+
 	telemetry.SendEvent(telemetryEvent, telemetry.ClusterDeleteStarted, "")
 
 	// Instantiate provider config
@@ -49,16 +52,16 @@ func DeleteAWSCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryEv
 
 	kcfg := utils.GetKubernetesClient(cl.ClusterName)
 
-	cl.Status = constants.ClusterStatusDeleting
-
-	if err := secrets.UpdateCluster(kcfg.Clientset, *cl); err != nil {
+	if err := secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+		cluster.Status = constants.ClusterStatusDeleting
+	}); err != nil {
 		return fmt.Errorf("error updating cluster status for cluster %s: %w", cl.ClusterName, err)
 	}
 
 	switch cl.GitProvider {
 	case "github":
 		if cl.GitTerraformApplyCheck {
-			log.Info().Msg("destroying github resources with terraform")
+			logger.Info().Msg("destroying github resources with terraform")
 
 			tfEntrypoint := config.GitopsDir + "/terraform/github"
 			tfEnvs := map[string]string{}
@@ -66,23 +69,24 @@ func DeleteAWSCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryEv
 			tfEnvs = awsext.GetGithubTerraformEnvs(tfEnvs, cl)
 			err := terraformext.InitDestroyAutoApprove(config.TerraformClient, tfEntrypoint, tfEnvs)
 			if err != nil {
-				log.Error().Msgf("error executing terraform destroy %s", tfEntrypoint)
+				logger.Error().Msgf("error executing terraform destroy %s", tfEntrypoint)
 				errors.HandleClusterError(cl, err.Error())
 				return fmt.Errorf("failed to execute terraform destroy for GitHub resources at %s: %w", tfEntrypoint, err)
 			}
-			log.Info().Msg("github resources terraform destroyed")
+			logger.Info().Msg("github resources terraform destroyed")
 
 			kcfg := utils.GetKubernetesClient(cl.ClusterName)
 
-			cl.GitTerraformApplyCheck = false
-			err = secrets.UpdateCluster(kcfg.Clientset, *cl)
+			err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+				cluster.GitTerraformApplyCheck = false
+			})
 			if err != nil {
 				return fmt.Errorf("error updating cluster after destroying github resources for cluster %s: %w", cl.ClusterName, err)
 			}
 		}
 	case "gitlab":
 		if cl.GitTerraformApplyCheck {
-			log.Info().Msg("destroying gitlab resources with terraform")
+			logger.Info().Msg("destroying gitlab resources with terraform")
 			gitlabClient, err := gitlab.NewGitLabClient(cl.GitAuth.Token, cl.GitAuth.Owner)
 			if err != nil {
 				return fmt.Errorf("error creating gitlab client for cluster %s: %w", cl.ClusterName, err)
@@ -94,26 +98,26 @@ func DeleteAWSCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryEv
 			for _, project := range projectsForDeletion {
 				projectExists, err := gitlabClient.CheckProjectExists(project)
 				if err != nil {
-					log.Error().Msgf("could not check for existence of project %s: %s", project, err)
+					logger.Error().Msgf("could not check for existence of project %s: %s", project, err)
 				}
 				if projectExists {
-					log.Info().Msgf("checking project %s for container registries...", project)
+					logger.Info().Msgf("checking project %s for container registries...", project)
 					crr, err := gitlabClient.GetProjectContainerRegistryRepositories(project)
 					if err != nil {
-						log.Error().Msgf("could not retrieve container registry repositories: %s", err)
+						logger.Error().Msgf("could not retrieve container registry repositories: %s", err)
 					}
 					if len(crr) > 0 {
 						for _, cr := range crr {
 							err := gitlabClient.DeleteContainerRegistryRepository(project, cr.ID)
 							if err != nil {
-								log.Error().Msgf("error deleting container registry repository: %s", err)
+								logger.Error().Msgf("error deleting container registry repository: %s", err)
 							}
 						}
 					} else {
-						log.Info().Msgf("project %s does not have any container registries, skipping", project)
+						logger.Info().Msgf("project %s does not have any container registries, skipping", project)
 					}
 				} else {
-					log.Info().Msgf("project %s does not exist, skipping", project)
+					logger.Info().Msgf("project %s does not exist, skipping", project)
 				}
 			}
 
@@ -123,22 +127,23 @@ func DeleteAWSCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryEv
 			tfEnvs = awsext.GetGitlabTerraformEnvs(tfEnvs, gitlabClient.ParentGroupID, cl)
 			err = terraformext.InitDestroyAutoApprove(config.TerraformClient, tfEntrypoint, tfEnvs)
 			if err != nil {
-				log.Error().Msgf("error executing terraform destroy %s", tfEntrypoint)
+				logger.Error().Msgf("error executing terraform destroy %s", tfEntrypoint)
 				errors.HandleClusterError(cl, err.Error())
 				return fmt.Errorf("failed to execute terraform destroy for GitLab resources at %s: %w", tfEntrypoint, err)
 			}
 
-			log.Info().Msg("gitlab resources terraform destroyed")
+			logger.Info().Msg("gitlab resources terraform destroyed")
 
-			cl.GitTerraformApplyCheck = false
-			err = secrets.UpdateCluster(kcfg.Clientset, *cl)
+			err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+				cluster.GitTerraformApplyCheck = false
+			})
 			if err != nil {
 				return fmt.Errorf("error updating cluster after destroying gitlab resources for cluster %s: %w", cl.ClusterName, err)
 			}
 		}
 	}
 
-	if cl.CloudTerraformApplyCheck || cl.CloudTerraformApplyFailedCheck {
+	if cl.CloudTerraformApplyCheck || cl.CloudTerraformApplyFailedCheck || cl.CloudTerraformApplyCancelledCheck {
 		if !cl.ArgoCDDeleteRegistryCheck {
 			conf, err := awsinternal.NewAwsV3(
 				cl.CloudRegion,
@@ -156,17 +161,17 @@ func DeleteAWSCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryEv
 			}
 			kcfg := awsext.CreateEKSKubeconfig(&awsClient.Config, cl.ClusterName)
 
-			log.Info().Msg("destroying aws resources with terraform")
+			logger.Info().Msg("destroying aws resources with terraform")
 
 			// Only port-forward to ArgoCD and delete registry if ArgoCD was installed
 			if cl.ArgoCDInstallCheck {
 				removeArgoCDApps := []string{"ingress-nginx-components", "ingress-nginx"}
 				err = argocd.ApplicationCleanup(kcfg.Clientset, removeArgoCDApps)
 				if err != nil {
-					log.Error().Msgf("encountered error during argocd application cleanup: %s", err)
+					logger.Error().Msgf("encountered error during argocd application cleanup: %s", err)
 				}
 
-				log.Info().Msg("opening argocd port forward")
+				logger.Info().Msg("opening argocd port forward")
 				// * ArgoCD port-forward
 				argoCDStopChannel := make(chan struct{}, 1)
 				defer func() {
@@ -182,7 +187,7 @@ func DeleteAWSCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryEv
 					argoCDStopChannel,
 				)
 
-				log.Info().Msg("getting new auth token for argocd")
+				logger.Info().Msg("getting new auth token for argocd")
 
 				secData, err := k8s.ReadSecretV2(kcfg.Clientset, "argocd", "argocd-initial-admin-secret")
 				if err != nil {
@@ -195,30 +200,31 @@ func DeleteAWSCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryEv
 					return fmt.Errorf("error getting argocd token for cluster %s: %w", cl.ClusterName, err)
 				}
 
-				log.Info().Msgf("port-forward to argocd is available at %s", providerConfigs.ArgocdPortForwardURL)
+				logger.Info().Msgf("port-forward to argocd is available at %s", providerConfigs.ArgocdPortForwardURL)
 
 				client := httpCommon.CustomHTTPClient(true)
-				log.Info().Msg("deleting the registry application")
+				logger.Info().Msg("deleting the registry application")
 				httpCode, _, err := argocd.DeleteApplication(client, config.RegistryAppName, argocdAuthToken, "true")
 				if err != nil {
 					errors.HandleClusterError(cl, err.Error())
 					return fmt.Errorf("failed to delete ArgoCD application %s for cluster %s: %w", config.RegistryAppName, cl.ClusterName, err)
 				}
-				log.Info().Msgf("http status code %d", httpCode)
+				logger.Info().Msgf("http status code %d", httpCode)
 			}
 
 			// Pause before cluster destroy to prevent a race condition
-			log.Info().Msg("waiting for aws Kubernetes cluster resource removal to finish...")
+			logger.Info().Msg("waiting for aws Kubernetes cluster resource removal to finish...")
 			time.Sleep(time.Second * 10)
 
-			cl.ArgoCDDeleteRegistryCheck = true
-			err = secrets.UpdateCluster(kcfg.Clientset, *cl)
+			err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+				cluster.ArgoCDDeleteRegistryCheck = true
+			})
 			if err != nil {
 				return fmt.Errorf("error updating cluster after ArgoCD cleanup for cluster %s: %w", cl.ClusterName, err)
 			}
 		}
 
-		log.Info().Msg("destroying aws cloud resources")
+		logger.Info().Msg("destroying aws cloud resources")
 		tfEntrypoint := config.GitopsDir + fmt.Sprintf("/terraform/%s", cl.CloudProvider)
 		tfEnvs := map[string]string{}
 		tfEnvs = awsext.GetAwsTerraformEnvs(tfEnvs, cl)
@@ -236,20 +242,23 @@ func DeleteAWSCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryEv
 		}
 		err = terraformext.InitDestroyAutoApprove(config.TerraformClient, tfEntrypoint, tfEnvs)
 		if err != nil {
-			log.Error().Msgf("error executing terraform destroy %s", tfEntrypoint)
+			logger.Error().Msgf("error executing terraform destroy %s", tfEntrypoint)
 			errors.HandleClusterError(cl, err.Error())
 			return fmt.Errorf("failed to execute terraform destroy for AWS resources at %s: %w", tfEntrypoint, err)
 		}
-		log.Info().Msg("aws resources terraform destroyed")
+		logger.Info().Msg("aws resources terraform destroyed")
 
-		cl.CloudTerraformApplyCheck = false
-		err = secrets.UpdateCluster(kcfg.Clientset, *cl)
+		err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+			cluster.CloudTerraformApplyCheck = false
+		})
 		if err != nil {
 			return fmt.Errorf("error updating cluster after destroying aws resources for cluster %s: %w", cl.ClusterName, err)
 		}
 
-		cl.CloudTerraformApplyFailedCheck = false
-		err = secrets.UpdateCluster(kcfg.Clientset, *cl)
+		err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+			cluster.CloudTerraformApplyFailedCheck = false
+			cluster.CloudTerraformApplyCancelledCheck = false
+		})
 		if err != nil {
 			return fmt.Errorf("error updating cluster after marking aws apply as failed for cluster %s: %w", cl.ClusterName, err)
 		}
@@ -261,17 +270,18 @@ func DeleteAWSCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryEv
 		if err != nil {
 			return fmt.Errorf("error creating gitlab client for SSH key deletion for cluster %s: %w", cl.ClusterName, err)
 		}
-		log.Info().Msgf("attempting to delete managed ssh key...")
+		logger.Info().Msgf("attempting to delete managed ssh key...")
 		err = gitlabClient.DeleteUserSSHKey("kbot-ssh-key")
 		if err != nil {
-			log.Warn().Msgf("error deleting SSH key for cluster %s: %s", cl.ClusterName, err)
+			logger.Warn().Msgf("error deleting SSH key for cluster %s: %s", cl.ClusterName, err)
 		}
 	}
 
 	telemetry.SendEvent(telemetryEvent, telemetry.ClusterDeleteCompleted, "")
 
-	cl.Status = constants.ClusterStatusDeleted
-	err = secrets.UpdateCluster(kcfg.Clientset, *cl)
+	err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+		cluster.Status = constants.ClusterStatusDeleted
+	})
 	if err != nil {
 		return fmt.Errorf("error updating cluster status to deleted for cluster %s: %w", cl.ClusterName, err)
 	}
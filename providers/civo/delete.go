@@ -7,6 +7,7 @@ See the LICENSE file for more details.
 package civo
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -15,21 +16,24 @@ import (
 	terraformext "github.com/konstructio/kubefirst-api/extensions/terraform"
 	pkg "github.com/konstructio/kubefirst-api/internal"
 	"github.com/konstructio/kubefirst-api/internal/argocd"
+	"github.com/konstructio/kubefirst-api/internal/clusterlogs"
 	"github.com/konstructio/kubefirst-api/internal/constants"
 	"github.com/konstructio/kubefirst-api/internal/errors"
 	gitlab "github.com/konstructio/kubefirst-api/internal/gitlab"
 	"github.com/konstructio/kubefirst-api/internal/httpCommon"
 	"github.com/konstructio/kubefirst-api/internal/k8s"
+	"github.com/konstructio/kubefirst-api/internal/metrics"
 	"github.com/konstructio/kubefirst-api/internal/secrets"
 	"github.com/konstructio/kubefirst-api/internal/utils"
 	"github.com/konstructio/kubefirst-api/pkg/providerConfigs"
 	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
 	"github.com/kubefirst/metrics-client/pkg/telemetry"
-	log "github.com/rs/zerolog/log"
 )
 
 // DeleteCivoCluster
-func DeleteCivoCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryEvent) error {
+func DeleteCivoCluster(ctx context.Context, cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryEvent) error {
+	logger := clusterlogs.Logger(ctx)
+
 	telemetry.SendEvent(telemetryEvent, telemetry.ClusterDeleteStarted, "")
 
 	// Instantiate provider config
@@ -48,9 +52,9 @@ func DeleteCivoCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryE
 
 	kcfg := utils.GetKubernetesClient(cl.ClusterName)
 
-	cl.Status = constants.ClusterStatusDeleting
-
-	if err := secrets.UpdateCluster(kcfg.Clientset, *cl); err != nil {
+	if err := secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+		cluster.Status = constants.ClusterStatusDeleting
+	}); err != nil {
 		return fmt.Errorf("error updating cluster status for cluster %s: %w", cl.ClusterName, err)
 	}
 
@@ -58,7 +62,7 @@ func DeleteCivoCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryE
 	var tfEntrypoint string
 
 	if cl.GitTerraformApplyCheck {
-		log.Info().Msgf("destroying %s resources with terraform", cl.GitProvider)
+		logger.Info().Msgf("destroying %s resources with terraform", cl.GitProvider)
 		switch cl.GitProvider {
 		case "github":
 			tfEntrypoint = config.GitopsDir + "/terraform/github"
@@ -77,26 +81,26 @@ func DeleteCivoCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryE
 			for _, project := range projectsForDeletion {
 				projectExists, err := gitlabClient.CheckProjectExists(project)
 				if err != nil {
-					log.Error().Msgf("could not check for existence of project %s: %s", project, err)
+					logger.Error().Msgf("could not check for existence of project %s: %s", project, err)
 				}
 				if projectExists {
-					log.Info().Msgf("checking project %s for container registries...", project)
+					logger.Info().Msgf("checking project %s for container registries...", project)
 					crr, err := gitlabClient.GetProjectContainerRegistryRepositories(project)
 					if err != nil {
-						log.Error().Msgf("could not retrieve container registry repositories: %s", err)
+						logger.Error().Msgf("could not retrieve container registry repositories: %s", err)
 					}
 					if len(crr) > 0 {
 						for _, cr := range crr {
 							err := gitlabClient.DeleteContainerRegistryRepository(project, cr.ID)
 							if err != nil {
-								log.Error().Msgf("error deleting container registry repository: %s", err)
+								logger.Error().Msgf("error deleting container registry repository: %s", err)
 							}
 						}
 					} else {
-						log.Info().Msgf("project %s does not have any container registries, skipping", project)
+						logger.Info().Msgf("project %s does not have any container registries, skipping", project)
 					}
 				} else {
-					log.Info().Msgf("project %s does not exist, skipping", project)
+					logger.Info().Msgf("project %s does not exist, skipping", project)
 				}
 			}
 			tfEntrypoint = config.GitopsDir + "/terraform/gitlab"
@@ -106,48 +110,53 @@ func DeleteCivoCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryE
 
 		err = terraformext.InitDestroyAutoApprove(config.TerraformClient, tfEntrypoint, tfEnvs)
 		if err != nil {
-			log.Info().Msgf("error executing terraform destroy %s", tfEntrypoint)
+			logger.Info().Msgf("error executing terraform destroy %s", tfEntrypoint)
 			errors.HandleClusterError(cl, err.Error())
 			return fmt.Errorf("error executing terraform destroy for %s: %w", tfEntrypoint, err)
 		}
 
-		log.Info().Msgf("%s resources terraform destroyed", cl.GitProvider)
+		logger.Info().Msgf("%s resources terraform destroyed", cl.GitProvider)
 
-		cl.GitTerraformApplyCheck = false
-		err = secrets.UpdateCluster(kcfg.Clientset, *cl)
+		err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+			cluster.GitTerraformApplyCheck = false
+		})
 		if err != nil {
 			return fmt.Errorf("error updating cluster status after terraform destroy for cluster %s: %w", cl.ClusterName, err)
 		}
 	}
 
-	if cl.CloudTerraformApplyCheck || cl.CloudTerraformApplyFailedCheck {
+	if cl.CloudTerraformApplyCheck || cl.CloudTerraformApplyFailedCheck || cl.CloudTerraformApplyCancelledCheck {
 		if !cl.ArgoCDDeleteRegistryCheck {
 			kcfg, err := k8s.CreateKubeConfig(false, config.Kubeconfig)
 			if err != nil {
 				return fmt.Errorf("error creating kubeconfig for cluster %s: %w", cl.ClusterName, err)
 			}
 
-			log.Info().Msg("destroying civo resources with terraform")
+			logger.Info().Msg("destroying civo resources with terraform")
 
 			client, err := civogo.NewClient(cl.CivoAuth.Token, cl.CloudRegion)
 			if err != nil {
 				return fmt.Errorf("error creating Civo client for cluster %s: %w", cl.ClusterName, err)
 			}
 
+			start := time.Now()
 			cluster, err := client.FindKubernetesCluster(cl.ClusterName)
+			metrics.ObserveExternalCall(ctx, metrics.ServiceCivo, start, err)
 			if err != nil {
 				return fmt.Errorf("error finding Civo Kubernetes cluster %s: %w", cl.ClusterName, err)
 			}
-			log.Info().Msg("cluster name: " + cluster.ID)
+			logger.Info().Msg("cluster name: " + cluster.ID)
 
+			start = time.Now()
 			clusterVolumes, err := client.ListVolumesForCluster(cluster.ID)
+			metrics.ObserveExternalCall(ctx, metrics.ServiceCivo, start, err)
 			if err != nil {
 				return fmt.Errorf("error listing Civo volumes for cluster %s: %w", cl.ClusterName, err)
 			}
 
 			// Only port-forward to ArgoCD and delete registry if ArgoCD was installed
 			if cl.ArgoCDInstallCheck {
-				log.Info().Msg("opening argocd port forward")
+				logger.Info().Msg("opening argocd port forward")
 				// * ArgoCD port-forward
 				argoCDStopChannel := make(chan struct{}, 1)
 				defer func() {
@@ -163,7 +172,7 @@ func DeleteCivoCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryE
 					argoCDStopChannel,
 				)
 
-				log.Info().Msg("getting new auth token for argocd")
+				logger.Info().Msg("getting new auth token for argocd")
 
 				secData, err := k8s.ReadSecretV2(kcfg.Clientset, "argocd", "argocd-initial-admin-secret")
 				if err != nil {
@@ -176,39 +185,42 @@ func DeleteCivoCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryE
 					return fmt.Errorf("error getting ArgoCD token for cluster %s: %w", cl.ClusterName, err)
 				}
 
-				log.Info().Msgf("port-forward to argocd is available at %s", providerConfigs.ArgocdPortForwardURL)
+				logger.Info().Msgf("port-forward to argocd is available at %s", providerConfigs.ArgocdPortForwardURL)
 
 				client := httpCommon.CustomHTTPClient(true)
-				log.Info().Msg("deleting the registry application")
+				logger.Info().Msg("deleting the registry application")
 				httpCode, _, err := argocd.DeleteApplication(client, config.RegistryAppName, argocdAuthToken, "true")
 				if err != nil {
 					errors.HandleClusterError(cl, err.Error())
 					return fmt.Errorf("error deleting ArgoCD application for cluster %s: %w", cl.ClusterName, err)
 				}
-				log.Info().Msgf("http status code %d", httpCode)
+				logger.Info().Msgf("http status code %d", httpCode)
 			}
 
 			for _, vol := range clusterVolumes {
-				log.Info().Msg("removing volume with name: " + vol.Name)
+				logger.Info().Msg("removing volume with name: " + vol.Name)
+				start := time.Now()
 				_, err := client.DeleteVolume(vol.ID)
+				metrics.ObserveExternalCall(ctx, metrics.ServiceCivo, start, err)
 				if err != nil {
 					return fmt.Errorf("error deleting Civo volume %s for cluster %s: %w", vol.Name, cl.ClusterName, err)
 				}
-				log.Info().Msg("volume " + vol.ID + " deleted")
+				logger.Info().Msg("volume " + vol.ID + " deleted")
 			}
 
 			// Pause before cluster destroy to prevent a race condition
-			log.Info().Msg("waiting for Civo Kubernetes cluster resource removal to finish...")
+			logger.Info().Msg("waiting for Civo Kubernetes cluster resource removal to finish...")
 			time.Sleep(time.Second * 10)
 
-			cl.ArgoCDDeleteRegistryCheck = true
-			err = secrets.UpdateCluster(kcfg.Clientset, *cl)
+			err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+				cluster.ArgoCDDeleteRegistryCheck = true
+			})
 			if err != nil {
 				return fmt.Errorf("error updating cluster status after volume deletion for cluster %s: %w", cl.ClusterName, err)
 			}
 		}
 
-		log.Info().Msg("destroying civo cloud resources")
+		logger.Info().Msg("destroying civo cloud resources")
 		tfEntrypoint := config.GitopsDir + fmt.Sprintf("/terraform/%s", cl.CloudProvider)
 		tfEnvs := map[string]string{}
 		tfEnvs = civoext.GetCivoTerraformEnvs(tfEnvs, cl)
@@ -221,15 +233,17 @@ func DeleteCivoCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryE
 		}
 		err = terraformext.InitDestroyAutoApprove(config.TerraformClient, tfEntrypoint, tfEnvs)
 		if err != nil {
-			log.Printf("error executing terraform destroy %s", tfEntrypoint)
+			logger.Printf("error executing terraform destroy %s", tfEntrypoint)
 			errors.HandleClusterError(cl, err.Error())
 			return fmt.Errorf("error executing terraform destroy for %s: %w", tfEntrypoint, err)
 		}
-		log.Info().Msg("civo resources terraform destroyed")
+		logger.Info().Msg("civo resources terraform destroyed")
 
-		cl.CloudTerraformApplyCheck = false
-		cl.CloudTerraformApplyFailedCheck = false
-		err = secrets.UpdateCluster(kcfg.Clientset, *cl)
+		err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+			cluster.CloudTerraformApplyCheck = false
+			cluster.CloudTerraformApplyFailedCheck = false
+			cluster.CloudTerraformApplyCancelledCheck = false
+		})
 		if err != nil {
 			return fmt.Errorf("error updating cluster status after cloud resource destruction for cluster %s: %w", cl.ClusterName, err)
 		}
@@ -241,17 +255,18 @@ func DeleteCivoCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryE
 		if err != nil {
 			return fmt.Errorf("error creating GitLab client for SSH key deletion for cluster %s: %w", cl.ClusterName, err)
 		}
-		log.Info().Msg("attempting to delete managed ssh key...")
+		logger.Info().Msg("attempting to delete managed ssh key...")
 		err = gitlabClient.DeleteUserSSHKey("kbot-ssh-key")
 		if err != nil {
-			log.Warn().Msg(err.Error())
+			logger.Warn().Msg(err.Error())
 		}
 	}
 
 	telemetry.SendEvent(telemetryEvent, telemetry.ClusterDeleteCompleted, "")
 
-	cl.Status = constants.ClusterStatusDeleted
-	err = secrets.UpdateCluster(kcfg.Clientset, *cl)
+	err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+		cluster.Status = constants.ClusterStatusDeleted
+	})
 	if err != nil {
 		return fmt.Errorf("error updating cluster status to deleted for cluster %s: %w", cl.ClusterName, err)
 	}
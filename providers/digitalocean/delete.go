@@ -16,6 +16,7 @@ import (
 	terraformext "github.com/konstructio/kubefirst-api/extensions/terraform"
 	pkg "github.com/konstructio/kubefirst-api/internal"
 	"github.com/konstructio/kubefirst-api/internal/argocd"
+	"github.com/konstructio/kubefirst-api/internal/clusterlogs"
 	"github.com/konstructio/kubefirst-api/internal/constants"
 	"github.com/konstructio/kubefirst-api/internal/digitalocean"
 	"github.com/konstructio/kubefirst-api/internal/errors"
@@ -27,11 +28,12 @@ import (
 	"github.com/konstructio/kubefirst-api/pkg/providerConfigs"
 	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
 	"github.com/kubefirst/metrics-client/pkg/telemetry"
-	log "github.com/rs/zerolog/log"
 )
 
 // DeleteDigitaloceanCluster
-func DeleteDigitaloceanCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryEvent) error {
+func DeleteDigitaloceanCluster(ctx context.Context, cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryEvent) error {
+	logger := clusterlogs.Logger(ctx)
+
 	telemetry.SendEvent(telemetryEvent, telemetry.ClusterDeleteStarted, "")
 
 	// Instantiate provider config
@@ -50,16 +52,16 @@ func DeleteDigitaloceanCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.Te
 
 	kcfg := utils.GetKubernetesClient(cl.ClusterName)
 
-	cl.Status = constants.ClusterStatusDeleting
-
-	if err := secrets.UpdateCluster(kcfg.Clientset, *cl); err != nil {
+	if err := secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+		cluster.Status = constants.ClusterStatusDeleting
+	}); err != nil {
 		return fmt.Errorf("error updating cluster: %w", err)
 	}
 
 	switch cl.GitProvider {
 	case "github":
 		if cl.GitTerraformApplyCheck {
-			log.Info().Msg("destroying github resources with terraform")
+			logger.Info().Msg("destroying github resources with terraform")
 
 			tfEntrypoint := config.GitopsDir + "/terraform/github"
 			tfEnvs := map[string]string{}
@@ -67,21 +69,22 @@ func DeleteDigitaloceanCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.Te
 			tfEnvs = digitaloceanext.GetGithubTerraformEnvs(tfEnvs, cl)
 			err := terraformext.InitDestroyAutoApprove(config.TerraformClient, tfEntrypoint, tfEnvs)
 			if err != nil {
-				log.Printf("error executing terraform destroy %s", tfEntrypoint)
+				logger.Printf("error executing terraform destroy %s", tfEntrypoint)
 				errors.HandleClusterError(cl, err.Error())
 				return fmt.Errorf("error executing terraform destroy %s: %w", tfEntrypoint, err)
 			}
-			log.Info().Msg("github resources terraform destroyed")
+			logger.Info().Msg("github resources terraform destroyed")
 
-			cl.GitTerraformApplyCheck = false
-			err = secrets.UpdateCluster(kcfg.Clientset, *cl)
+			err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+				cluster.GitTerraformApplyCheck = false
+			})
 			if err != nil {
 				return fmt.Errorf("error updating cluster: %w", err)
 			}
 		}
 	case "gitlab":
 		if cl.GitTerraformApplyCheck {
-			log.Info().Msg("destroying gitlab resources with terraform")
+			logger.Info().Msg("destroying gitlab resources with terraform")
 			gitlabClient, err := gitlab.NewGitLabClient(cl.GitAuth.Token, cl.GitAuth.Owner)
 			if err != nil {
 				return fmt.Errorf("error creating new GitLab client: %w", err)
@@ -93,26 +96,26 @@ func DeleteDigitaloceanCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.Te
 			for _, project := range projectsForDeletion {
 				projectExists, err := gitlabClient.CheckProjectExists(project)
 				if err != nil {
-					log.Error().Msgf("could not check for existence of project %s: %s", project, err)
+					logger.Error().Msgf("could not check for existence of project %s: %s", project, err)
 				}
 				if projectExists {
-					log.Info().Msgf("checking project %s for container registries...", project)
+					logger.Info().Msgf("checking project %s for container registries...", project)
 					crr, err := gitlabClient.GetProjectContainerRegistryRepositories(project)
 					if err != nil {
-						log.Error().Msgf("could not retrieve container registry repositories: %s", err)
+						logger.Error().Msgf("could not retrieve container registry repositories: %s", err)
 					}
 					if len(crr) > 0 {
 						for _, cr := range crr {
 							err := gitlabClient.DeleteContainerRegistryRepository(project, cr.ID)
 							if err != nil {
-								log.Error().Msgf("error deleting container registry repository: %s", err)
+								logger.Error().Msgf("error deleting container registry repository: %s", err)
 							}
 						}
 					} else {
-						log.Info().Msgf("project %s does not have any container registries, skipping", project)
+						logger.Info().Msgf("project %s does not have any container registries, skipping", project)
 					}
 				} else {
-					log.Info().Msgf("project %s does not exist, skipping", project)
+					logger.Info().Msgf("project %s does not exist, skipping", project)
 				}
 			}
 
@@ -122,15 +125,16 @@ func DeleteDigitaloceanCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.Te
 			tfEnvs = digitaloceanext.GetGitlabTerraformEnvs(tfEnvs, gitlabClient.ParentGroupID, cl)
 			err = terraformext.InitDestroyAutoApprove(config.TerraformClient, tfEntrypoint, tfEnvs)
 			if err != nil {
-				log.Info().Msgf("error executing terraform destroy %s", tfEntrypoint)
+				logger.Info().Msgf("error executing terraform destroy %s", tfEntrypoint)
 				errors.HandleClusterError(cl, err.Error())
 				return fmt.Errorf("error executing terraform destroy %s: %w", tfEntrypoint, err)
 			}
 
-			log.Info().Msg("gitlab resources terraform destroyed")
+			logger.Info().Msg("gitlab resources terraform destroyed")
 
-			cl.GitTerraformApplyCheck = false
-			err = secrets.UpdateCluster(kcfg.Clientset, *cl)
+			err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+				cluster.GitTerraformApplyCheck = false
+			})
 			if err != nil {
 				return fmt.Errorf("error updating cluster: %w", err)
 			}
@@ -157,10 +161,10 @@ func DeleteDigitaloceanCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.Te
 		}
 		err = argocd.ApplicationCleanup(kcfg.Clientset, removeArgoCDApps)
 		if err != nil {
-			log.Error().Msgf("encountered error during argocd application cleanup: %s", err)
+			logger.Error().Msgf("encountered error during argocd application cleanup: %s", err)
 		}
 		// Pause before cluster destroy to prevent a race condition
-		log.Info().Msg("waiting for argocd application deletion to complete...")
+		logger.Info().Msg("waiting for argocd application deletion to complete...")
 		time.Sleep(time.Second * 20)
 	}
 
@@ -174,18 +178,18 @@ func DeleteDigitaloceanCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.Te
 		return fmt.Errorf("error getting kubernetes associated resources: %w", err)
 	}
 
-	if cl.CloudTerraformApplyCheck || cl.CloudTerraformApplyFailedCheck {
+	if cl.CloudTerraformApplyCheck || cl.CloudTerraformApplyFailedCheck || cl.CloudTerraformApplyCancelledCheck {
 		if !cl.ArgoCDDeleteRegistryCheck {
 			kcfg, err := k8s.CreateKubeConfig(false, config.Kubeconfig)
 			if err != nil {
 				return fmt.Errorf("error creating kubeconfig: %w", err)
 			}
 
-			log.Info().Msg("destroying digitalocean resources with terraform")
+			logger.Info().Msg("destroying digitalocean resources with terraform")
 
 			// Only port-forward to ArgoCD and delete registry if ArgoCD was installed
 			if cl.ArgoCDInstallCheck {
-				log.Info().Msg("opening argocd port forward")
+				logger.Info().Msg("opening argocd port forward")
 				// * ArgoCD port-forward
 				argoCDStopChannel := make(chan struct{}, 1)
 				defer func() {
@@ -201,7 +205,7 @@ func DeleteDigitaloceanCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.Te
 					argoCDStopChannel,
 				)
 
-				log.Info().Msg("getting new auth token for argocd")
+				logger.Info().Msg("getting new auth token for argocd")
 
 				secData, err := k8s.ReadSecretV2(kcfg.Clientset, "argocd", "argocd-initial-admin-secret")
 				if err != nil {
@@ -214,30 +218,31 @@ func DeleteDigitaloceanCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.Te
 					return fmt.Errorf("error getting argocd token: %w", err)
 				}
 
-				log.Info().Msgf("port-forward to argocd is available at %s", providerConfigs.ArgocdPortForwardURL)
+				logger.Info().Msgf("port-forward to argocd is available at %s", providerConfigs.ArgocdPortForwardURL)
 
 				client := httpCommon.CustomHTTPClient(true)
-				log.Info().Msg("deleting the registry application")
+				logger.Info().Msg("deleting the registry application")
 				httpCode, _, err := argocd.DeleteApplication(client, config.RegistryAppName, argocdAuthToken, "true")
 				if err != nil {
 					errors.HandleClusterError(cl, err.Error())
 					return fmt.Errorf("error deleting the registry application: %w", err)
 				}
-				log.Info().Msgf("http status code %d", httpCode)
+				logger.Info().Msgf("http status code %d", httpCode)
 			}
 
 			// Pause before cluster destroy to prevent a race condition
-			log.Info().Msg("waiting for digitalocean kubernetes cluster resource removal to finish...")
+			logger.Info().Msg("waiting for digitalocean kubernetes cluster resource removal to finish...")
 			time.Sleep(time.Second * 10)
 
-			cl.ArgoCDDeleteRegistryCheck = true
-			err = secrets.UpdateCluster(kcfg.Clientset, *cl)
+			err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+				cluster.ArgoCDDeleteRegistryCheck = true
+			})
 			if err != nil {
 				return fmt.Errorf("error updating cluster: %w", err)
 			}
 		}
 
-		log.Info().Msg("destroying digitalocean cloud resources")
+		logger.Info().Msg("destroying digitalocean cloud resources")
 		tfEntrypoint := config.GitopsDir + fmt.Sprintf("/terraform/%s", cl.CloudProvider)
 		tfEnvs := map[string]string{}
 		tfEnvs = digitaloceanext.GetDigitaloceanTerraformEnvs(tfEnvs, cl)
@@ -254,15 +259,17 @@ func DeleteDigitaloceanCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.Te
 		}
 		err = terraformext.InitDestroyAutoApprove(config.TerraformClient, tfEntrypoint, tfEnvs)
 		if err != nil {
-			log.Printf("error executing terraform destroy %s", tfEntrypoint)
+			logger.Printf("error executing terraform destroy %s", tfEntrypoint)
 			errors.HandleClusterError(cl, err.Error())
 			return fmt.Errorf("error executing terraform destroy %s: %w", tfEntrypoint, err)
 		}
-		log.Info().Msg("digitalocean resources terraform destroyed")
+		logger.Info().Msg("digitalocean resources terraform destroyed")
 
-		cl.CloudTerraformApplyCheck = false
-		cl.CloudTerraformApplyFailedCheck = false
-		err = secrets.UpdateCluster(kcfg.Clientset, *cl)
+		err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+			cluster.CloudTerraformApplyCheck = false
+			cluster.CloudTerraformApplyFailedCheck = false
+			cluster.CloudTerraformApplyCancelledCheck = false
+		})
 		if err != nil {
 			return fmt.Errorf("error updating cluster: %w", err)
 		}
@@ -280,17 +287,18 @@ func DeleteDigitaloceanCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.Te
 		if err != nil {
 			return fmt.Errorf("error creating new GitLab client: %w", err)
 		}
-		log.Info().Msgf("attempting to delete managed ssh key...")
+		logger.Info().Msgf("attempting to delete managed ssh key...")
 		err = gitlabClient.DeleteUserSSHKey("kbot-ssh-key")
 		if err != nil {
-			log.Warn().Msg(err.Error())
+			logger.Warn().Msg(err.Error())
 		}
 	}
 
 	telemetry.SendEvent(telemetryEvent, telemetry.ClusterDeleteCompleted, "")
 
-	cl.Status = constants.ClusterStatusDeleted
-	err = secrets.UpdateCluster(kcfg.Clientset, *cl)
+	err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+		cluster.Status = constants.ClusterStatusDeleted
+	})
 	if err != nil {
 		return fmt.Errorf("error updating cluster: %w", err)
 	}
@@ -7,309 +7,116 @@ See the LICENSE file for more details.
 package google
 
 import (
+	"context"
 	"fmt"
 	"os"
 
-	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/clusterlogs"
 	"github.com/konstructio/kubefirst-api/internal/controller"
-	"github.com/konstructio/kubefirst-api/internal/k8s"
 	"github.com/konstructio/kubefirst-api/internal/secrets"
-	"github.com/konstructio/kubefirst-api/internal/services"
 	"github.com/konstructio/kubefirst-api/pkg/google"
 	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
-	log "github.com/rs/zerolog/log"
 )
 
-func CreateGoogleCluster(definition *pkgtypes.ClusterDefinition) error {
+// pipelineOverrides adjusts the default cluster create pipeline for Google Cloud
+var pipelineOverrides = []controller.StepOverride{
+	{
+		Name:   controller.StepWriteGoogleCredentials,
+		Run:    writeApplicationCredentials,
+		Before: controller.StepDownloadTools,
+	},
+	{
+		Name:      controller.StepDownloadTools,
+		DependsOn: []string{controller.StepWriteGoogleCredentials},
+	},
+	{
+		Name:      controller.StepDetokenizeKMSKeyID,
+		Run:       (*controller.ClusterController).DetokenizeKMSKeyID,
+		DependsOn: []string{controller.StepCreateCluster},
+		After:     controller.StepCreateCluster,
+	},
+	{
+		Name:      controller.StepWaitForClusterReady,
+		DependsOn: []string{controller.StepDetokenizeKMSKeyID},
+	},
+	{Name: controller.StepRestoreSSL, Remove: true},
+	// cluster secrets are bootstrapped once argocd is running
+	{
+		Name:      controller.StepClusterSecretsBootstrap,
+		DependsOn: []string{controller.StepInitializeArgoCD},
+		After:     controller.StepInitializeArgoCD,
+	},
+	{
+		Name:      controller.StepInstallArgoCD,
+		DependsOn: []string{controller.StepWaitForClusterReady},
+	},
+	{
+		Name:      controller.StepDeployRegistry,
+		DependsOn: []string{controller.StepClusterSecretsBootstrap},
+	},
+	// vault is initialized through the port-forward
+	{
+		Name:      controller.StepOpenVaultPortForward,
+		DependsOn: []string{controller.StepWaitForVault},
+		Before:    controller.StepInitializeVault,
+	},
+	{
+		Name:      controller.StepInitializeVault,
+		DependsOn: []string{controller.StepOpenVaultPortForward},
+	},
+	{
+		Name:      controller.StepRunVaultTerraform,
+		DependsOn: []string{controller.StepInitializeVault},
+	},
+}
+
+// Pipeline returns the cluster create pipeline for Google Cloud
+func Pipeline() ([]controller.Step, error) {
+	return controller.BuildPipeline(pipelineOverrides)
+}
+
+func CreateGoogleCluster(ctx context.Context, definition *pkgtypes.ClusterDefinition) error {
 	ctrl := controller.ClusterController{}
-	err := ctrl.InitController(definition)
-	if err != nil {
+	if err := ctrl.InitController(ctx, definition); err != nil {
 		return fmt.Errorf("error initializing controller: %w", err)
 	}
 
 	// Update cluster status in database
-	ctrl.Cluster.InProgress = true
-	err = secrets.UpdateCluster(ctrl.KubernetesClient, ctrl.Cluster)
-	if err != nil {
-		return fmt.Errorf("error updating cluster in progress status: %w", err)
-	}
-
-	// TODO Validate Google region
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		log.Error().Msgf("error getting home path: %s", err)
-		return fmt.Errorf("error getting home path: %w", err)
-	}
-
-	err = google.WriteGoogleApplicationCredentialsFile(definition.GoogleAuth.KeyFile, homeDir)
-	if err != nil {
-		log.Error().Msgf("error writing google application credentials file: %s", err)
-		return fmt.Errorf("error writing google application credentials file: %w", err)
-	}
-
-	os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", fmt.Sprintf("%s/.k1/application-default-credentials.json", homeDir))
-
-	err = ctrl.DownloadTools(ctrl.ProviderConfig.ToolsDir)
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error downloading tools: %w", err)
-	}
-
-	err = ctrl.DomainLivenessTest()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error during domain liveness test: %w", err)
-	}
-
-	err = ctrl.StateStoreCredentials()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error retrieving state store credentials: %w", err)
-	}
-
-	// Checks for existing repos
-	err = ctrl.GitInit()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error initializing git repository: %w", err)
-	}
-
-	err = ctrl.InitializeBot()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error initializing bot: %w", err)
-	}
-
-	// Where detokeinization happens
-	err = ctrl.RepositoryPrep()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error during repository preparation: %w", err)
-	}
-
-	err = ctrl.RunGitTerraform()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error running Git Terraform: %w", err)
-	}
-
-	err = ctrl.RepositoryPush()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error pushing repository: %w", err)
-	}
-
-	err = ctrl.CreateCluster()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error creating cluster: %w", err)
-	}
-
-	err = ctrl.DetokenizeKMSKeyID()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error detokenizing KMS Key ID: %w", err)
-	}
-
-	kcfg, err := ctrl.GoogleClient.GetContainerClusterAuth(ctrl.ClusterName, []byte(ctrl.GoogleAuth.KeyFile))
-	if err != nil {
-		return fmt.Errorf("error getting container cluster authentication: %w", err)
-	}
-	// Save config
-	ctrl.Kcfg = kcfg
-
-	err = ctrl.WaitForClusterReady()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error waiting for cluster readiness: %w", err)
-	}
-
-	err = ctrl.InstallArgoCD()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error installing ArgoCD: %w", err)
-	}
-
-	err = ctrl.InitializeArgoCD()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error initializing ArgoCD: %w", err)
-	}
-
-	// Needs wait after cluster create
-	ctrl.Cluster.InProgress = false
-	err = secrets.UpdateCluster(ctrl.KubernetesClient, ctrl.Cluster)
-	if err != nil {
-		return fmt.Errorf("error updating cluster after installation: %w", err)
-	}
-
-	err = ctrl.ClusterSecretsBootstrap()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error bootstrapping cluster secrets: %w", err)
-	}
-
-	ctrl.Cluster.ClusterSecretsCreatedCheck = true
-	err = secrets.UpdateCluster(ctrl.KubernetesClient, ctrl.Cluster)
-	if err != nil {
-		ctrl.Cluster.InProgress = false
-		err = secrets.UpdateCluster(ctrl.KubernetesClient, ctrl.Cluster)
-		if err != nil {
-			return fmt.Errorf("error updating cluster secret creation check: %w", err)
-		}
-
-		return fmt.Errorf("error updating cluster after secrets creation: %w", err)
-	}
-
-	err = ctrl.DeployRegistryApplication()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error deploying registry application: %w", err)
-	}
-
-	err = ctrl.WaitForVault()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error waiting for Vault: %w", err)
-	}
-
-	// * configure vault with terraform
-	// * vault port-forward
-	vaultStopChannel := make(chan struct{}, 1)
-	defer func() {
-		close(vaultStopChannel)
-	}()
-	k8s.OpenPortForwardPodWrapper(
-		kcfg.Clientset,
-		kcfg.RestConfig,
-		"vault-0",
-		"vault",
-		8200,
-		8200,
-		vaultStopChannel,
-	)
-
-	err = ctrl.InitializeVault()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error initializing Vault: %w", err)
-	}
-
-	err = ctrl.RunVaultTerraform()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error running Vault Terraform: %w", err)
+	if err := secrets.UpdateClusterFields(ctrl.KubernetesClient, &ctrl.Cluster, func(cluster *pkgtypes.Cluster) {
+		cluster.InProgress = true
+	}); err != nil {
+		return fmt.Errorf("error updating cluster status: %w", err)
 	}
 
-	err = ctrl.WriteVaultSecrets()
+	steps, err := Pipeline()
 	if err != nil {
 		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error writing Vault secrets: %w", err)
+		return fmt.Errorf("error building create pipeline: %w", err)
 	}
 
-	err = ctrl.RunUsersTerraform()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error running Users Terraform: %w", err)
+	if err := ctrl.RunPipeline(steps); err != nil {
+		return fmt.Errorf("error creating Google Cloud cluster: %w", err)
 	}
 
-	// Wait for last sync wave app transition to Running
-	log.Info().Msg("waiting for final sync wave Deployment to transition to Running")
-	crossplaneDeployment, err := k8s.ReturnDeploymentObject(
-		kcfg.Clientset,
-		"app.kubernetes.io/instance",
-		"crossplane",
-		"crossplane-system",
-		3600,
-	)
-	if err != nil {
-		log.Error().Msgf("Error finding crossplane Deployment: %s", err)
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error finding crossplane Deployment: %w", err)
-	}
-	log.Info().Msg("waiting on dns, tls certificates from letsencrypt and remaining sync waves.\n this may take up to 60 minutes but regularly completes in under 20 minutes")
-	_, err = k8s.WaitForDeploymentReady(kcfg.Clientset, crossplaneDeployment, 3600)
-	if err != nil {
-		log.Error().Msgf("Error waiting for all Apps to sync ready state: %s", err)
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error waiting for crossplane deployment to be ready: %w", err)
-	}
-
-	stopCh := make(chan struct{}, 1)
-	defer func() {
-		close(stopCh)
-	}()
-
-	// * export and import cluster
-	err = ctrl.ExportClusterRecord()
-	if err != nil {
-		log.Error().Msgf("Error exporting cluster record: %s", err)
-		return fmt.Errorf("error exporting cluster record: %w", err)
-	}
-
-	ctrl.Cluster.Status = constants.ClusterStatusProvisioned
-	ctrl.Cluster.InProgress = false
-	err = secrets.UpdateCluster(ctrl.KubernetesClient, ctrl.Cluster)
-	if err != nil {
-		return fmt.Errorf("error updating cluster to provisioned status: %w", err)
-	}
+	clusterlogs.Logger(ctx).Info().Msg("cluster creation complete")
 
-	log.Info().Msg("cluster creation complete")
+	return nil
+}
 
-	// Create default service entries
-	cl, _ := secrets.GetCluster(ctrl.KubernetesClient, ctrl.ClusterName)
-	err = services.AddDefaultServices(cl)
+// writeApplicationCredentials writes the service account key so that terraform
+// and the google client libraries can authenticate
+func writeApplicationCredentials(clctrl *controller.ClusterController) error {
+	// TODO Validate Google region
+	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		log.Error().Msgf("error adding default service entries for cluster %s: %s", cl.ClusterName, err)
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error adding default service entries for cluster %s: %w", cl.ClusterName, err)
-	}
-
-	if ctrl.InstallKubefirstPro {
-		log.Info().Msg("waiting for kubefirst-pro-api Deployment to transition to Running")
-		kubefirstProAPI, err := k8s.ReturnDeploymentObject(
-			kcfg.Clientset,
-			"app.kubernetes.io/name",
-			"kubefirst-pro-api",
-			"kubefirst",
-			1200,
-		)
-		if err != nil {
-			log.Error().Msgf("Error finding kubefirst-pro-api Deployment: %s", err)
-			ctrl.UpdateClusterOnError(err.Error())
-			return fmt.Errorf("error finding kubefirst-pro-api deployment: %w", err)
-		}
-
-		_, err = k8s.WaitForDeploymentReady(kcfg.Clientset, kubefirstProAPI, 300)
-		if err != nil {
-			log.Error().Msgf("Error waiting for kubefirst-pro-api to transition to Running: %s", err)
-			ctrl.UpdateClusterOnError(err.Error())
-			return fmt.Errorf("error waiting for kubefirst-pro-api deployment to be ready: %w", err)
-		}
+		return fmt.Errorf("error getting home path: %w", err)
 	}
 
-	// Wait for last sync wave app transition to Running
-	log.Info().Msg("waiting for final sync wave Deployment to transition to Running")
-	argocdDeployment, err := k8s.ReturnDeploymentObject(
-		kcfg.Clientset,
-		"app.kubernetes.io/name",
-		"argocd-server",
-		"argocd",
-		3600,
-	)
-	if err != nil {
-		log.Error().Msgf("Error finding argocd Deployment: %s", err)
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error finding argocd deployment: %w", err)
-	}
-	_, err = k8s.WaitForDeploymentReady(kcfg.Clientset, argocdDeployment, 3600)
-	if err != nil {
-		log.Error().Msgf("Error waiting for argocd deployment to enter Ready state: %s", err)
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error waiting for argocd deployment to be ready: %w", err)
+	if err := google.WriteGoogleApplicationCredentialsFile(clctrl.GoogleAuth.KeyFile, homeDir); err != nil {
+		return fmt.Errorf("error writing google application credentials file: %w", err)
 	}
 
-	log.Info().Msg("cluster creation complete")
+	os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", fmt.Sprintf("%s/.k1/application-default-credentials.json", homeDir))
 
 	return nil
 }
@@ -7,287 +7,48 @@ See the LICENSE file for more details.
 package k3s
 
 import (
+	"context"
 	"fmt"
-	"os"
 
-	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/clusterlogs"
 	"github.com/konstructio/kubefirst-api/internal/controller"
-	"github.com/konstructio/kubefirst-api/internal/k8s"
 	"github.com/konstructio/kubefirst-api/internal/secrets"
-	"github.com/konstructio/kubefirst-api/internal/services"
-	"github.com/konstructio/kubefirst-api/internal/ssl"
 	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
-	log "github.com/rs/zerolog/log"
 )
 
+// pipelineOverrides adjusts the default cluster create pipeline for K3s, which
+// runs the default steps unchanged
+var pipelineOverrides = []controller.StepOverride{}
+
+// Pipeline returns the cluster create pipeline for K3s
+func Pipeline() ([]controller.Step, error) {
+	return controller.BuildPipeline(pipelineOverrides)
+}
+
 // Createk3sCluster
-func CreateK3sCluster(definition *pkgtypes.ClusterDefinition) error {
+func CreateK3sCluster(ctx context.Context, definition *pkgtypes.ClusterDefinition) error {
 	ctrl := controller.ClusterController{}
-	err := ctrl.InitController(definition)
-	if err != nil {
+	if err := ctrl.InitController(ctx, definition); err != nil {
 		return fmt.Errorf("error initializing controller: %w", err)
 	}
 
-	ctrl.Cluster.InProgress = true
-	err = secrets.UpdateCluster(ctrl.KubernetesClient, ctrl.Cluster)
-	if err != nil {
-		return fmt.Errorf("error updating cluster secrets: %w", err)
-	}
-
-	err = ctrl.DownloadTools(ctrl.ProviderConfig.ToolsDir)
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error downloading tools: %w", err)
-	}
-
-	err = ctrl.DomainLivenessTest()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error in domain liveness test: %w", err)
-	}
-
-	err = ctrl.StateStoreCredentials()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error in state store credentials: %w", err)
-	}
-
-	err = ctrl.GitInit()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error initializing git: %w", err)
-	}
-
-	err = ctrl.InitializeBot()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error initializing bot: %w", err)
-	}
-
-	err = ctrl.RepositoryPrep()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error in repository preparation: %w", err)
-	}
-
-	err = ctrl.RunGitTerraform()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error running git terraform: %w", err)
-	}
-
-	err = ctrl.RepositoryPush()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error pushing repository: %w", err)
-	}
-
-	err = ctrl.CreateCluster()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error creating cluster: %w", err)
-	}
-
-	err = ctrl.WaitForClusterReady()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error waiting for cluster to be ready: %w", err)
-	}
-
-	err = ctrl.ClusterSecretsBootstrap()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error in cluster secrets bootstrap: %w", err)
-	}
-
-	// * check for ssl restore
-	log.Info().Msg("checking for tls secrets to restore")
-	secretsFilesToRestore, err := os.ReadDir(ctrl.ProviderConfig.SSLBackupDir + "/secrets")
-	if err != nil {
-		if os.IsNotExist(err) {
-			log.Info().Msg("no files found in secrets directory, continuing")
-		} else {
-			log.Info().Msgf("unable to check for TLS secrets to restore: %s", err.Error())
-		}
-	}
-
-	if len(secretsFilesToRestore) != 0 {
-		// todo would like these but requires CRD's and is not currently supported
-		// add crds ( use execShellReturnErrors? )
-		// https://raw.githubusercontent.com/cert-manager/cert-manager/v1.11.0/deploy/crds/crd-clusterissuers.yaml
-		// https://raw.githubusercontent.com/cert-manager/cert-manager/v1.11.0/deploy/crds/crd-certificates.yaml
-		// add certificates, and clusterissuers
-		log.Info().Msgf("found %d tls secrets to restore", len(secretsFilesToRestore))
-		ssl.Restore(ctrl.ProviderConfig.SSLBackupDir, ctrl.ProviderConfig.Kubeconfig)
-	} else {
-		log.Info().Msg("no files found in secrets directory, continuing")
-	}
-
-	err = ctrl.InstallArgoCD()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error installing ArgoCD: %w", err)
-	}
-
-	err = ctrl.InitializeArgoCD()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error initializing ArgoCD: %w", err)
+	if err := secrets.UpdateClusterFields(ctrl.KubernetesClient, &ctrl.Cluster, func(cluster *pkgtypes.Cluster) {
+		cluster.InProgress = true
+	}); err != nil {
+		return fmt.Errorf("error updating cluster status: %w", err)
 	}
 
-	err = ctrl.DeployRegistryApplication()
+	steps, err := Pipeline()
 	if err != nil {
 		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error deploying registry application: %w", err)
+		return fmt.Errorf("error building create pipeline: %w", err)
 	}
 
-	err = ctrl.WaitForVault()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error waiting for Vault: %w", err)
-	}
-
-	err = ctrl.InitializeVault()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error initializing Vault: %w", err)
-	}
-
-	// Create kubeconfig client
-	kcfg, err := k8s.CreateKubeConfig(false, ctrl.ProviderConfig.Kubeconfig)
-	if err != nil {
-		return fmt.Errorf("error creating kubeconfig: %w", err)
-	}
-
-	// SetupMinioStorage(kcfg, ctrl.ProviderConfig.K1Dir, ctrl.GitProvider)
-
-	// * configure vault with terraform
-	// * vault port-forward
-	vaultStopChannel := make(chan struct{}, 1)
-	defer func() {
-		close(vaultStopChannel)
-	}()
-	k8s.OpenPortForwardPodWrapper(
-		kcfg.Clientset,
-		kcfg.RestConfig,
-		"vault-0",
-		"vault",
-		8200,
-		8200,
-		vaultStopChannel,
-	)
-
-	err = ctrl.RunVaultTerraform()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error running Vault terraform: %w", err)
-	}
-
-	err = ctrl.WriteVaultSecrets()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error writing Vault secrets: %w", err)
-	}
-
-	err = ctrl.RunUsersTerraform()
-	if err != nil {
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error running users terraform: %w", err)
-	}
-
-	// Wait for last sync wave app transition to Running
-	log.Info().Msg("waiting for final sync wave Deployment to transition to Running")
-	crossplaneDeployment, err := k8s.ReturnDeploymentObject(
-		kcfg.Clientset,
-		"app.kubernetes.io/instance",
-		"crossplane",
-		"crossplane-system",
-		3600,
-	)
-	if err != nil {
-		log.Error().Msgf("Error finding crossplane Deployment: %s", err)
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error finding crossplane Deployment: %w", err)
-	}
-	log.Info().Msgf("waiting on dns, tls certificates from letsencrypt and remaining sync waves.\n this may take up to 60 minutes but regularly completes in under 20 minutes")
-	_, err = k8s.WaitForDeploymentReady(kcfg.Clientset, crossplaneDeployment, 3600)
-	if err != nil {
-		log.Error().Msgf("Error waiting for all Apps to sync ready state: %s", err)
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error waiting for all Apps to sync ready state: %w", err)
-	}
-
-	// * export and import cluster
-	err = ctrl.ExportClusterRecord()
-	if err != nil {
-		log.Error().Msgf("Error exporting cluster record: %s", err)
-		return fmt.Errorf("error exporting cluster record: %w", err)
-	}
-
-	ctrl.Cluster.InProgress = false
-	ctrl.Cluster.Status = constants.ClusterStatusProvisioned
-	err = secrets.UpdateCluster(ctrl.KubernetesClient, ctrl.Cluster)
-	if err != nil {
-		return fmt.Errorf("error updating cluster secrets: %w", err)
-	}
-
-	log.Info().Msg("cluster creation complete")
-
-	// Create default service entries
-	cl, _ := secrets.GetCluster(ctrl.KubernetesClient, ctrl.ClusterName)
-	err = services.AddDefaultServices(cl)
-	if err != nil {
-		log.Error().Msgf("error adding default service entries for cluster %s: %s", cl.ClusterName, err)
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error adding default service entries for cluster %s: %w", cl.ClusterName, err)
-	}
-
-	if ctrl.InstallKubefirstPro {
-		log.Info().Msg("waiting for kubefirst-pro-api Deployment to transition to Running")
-		kubefirstProAPI, err := k8s.ReturnDeploymentObject(
-			kcfg.Clientset,
-			"app.kubernetes.io/name",
-			"kubefirst-pro-api",
-			"kubefirst",
-			1200,
-		)
-		if err != nil {
-			log.Error().Msgf("Error finding kubefirst-pro-api Deployment: %s", err)
-			ctrl.UpdateClusterOnError(err.Error())
-			return fmt.Errorf("error finding kubefirst-pro-api Deployment: %w", err)
-		}
-
-		_, err = k8s.WaitForDeploymentReady(kcfg.Clientset, kubefirstProAPI, 300)
-		if err != nil {
-			log.Error().Msgf("Error waiting for kubefirst-pro-api to transition to Running: %s", err)
-			ctrl.UpdateClusterOnError(err.Error())
-			return fmt.Errorf("error waiting for kubefirst-pro-api to transition to Running: %w", err)
-		}
-	}
-
-	// Wait for last sync wave app transition to Running
-	log.Info().Msg("waiting for final sync wave Deployment to transition to Running")
-	argocdDeployment, err := k8s.ReturnDeploymentObject(
-		kcfg.Clientset,
-		"app.kubernetes.io/name",
-		"argocd-server",
-		"argocd",
-		3600,
-	)
-	if err != nil {
-		log.Error().Msgf("Error finding argocd Deployment: %s", err)
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error finding argocd Deployment: %w", err)
-	}
-	_, err = k8s.WaitForDeploymentReady(kcfg.Clientset, argocdDeployment, 3600)
-	if err != nil {
-		log.Error().Msgf("Error waiting for argocd deployment to enter Ready state: %s", err)
-
-		ctrl.UpdateClusterOnError(err.Error())
-		return fmt.Errorf("error waiting for argocd deployment to enter Ready state: %w", err)
+	if err := ctrl.RunPipeline(steps); err != nil {
+		return fmt.Errorf("error creating K3s cluster: %w", err)
 	}
 
-	log.Info().Msg("cluster creation complete")
+	clusterlogs.Logger(ctx).Info().Msg("cluster creation complete")
 
 	return nil
 }
@@ -1,2 +1,189 @@
-// TODO:
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
 package k3s
+
+import (
+	"context"
+	"fmt"
+
+	k3sext "github.com/konstructio/kubefirst-api/extensions/k3s"
+	terraformext "github.com/konstructio/kubefirst-api/extensions/terraform"
+	pkg "github.com/konstructio/kubefirst-api/internal"
+	"github.com/konstructio/kubefirst-api/internal/clusterlogs"
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/errors"
+	gitlab "github.com/konstructio/kubefirst-api/internal/gitlab"
+	"github.com/konstructio/kubefirst-api/internal/secrets"
+	"github.com/konstructio/kubefirst-api/internal/utils"
+	"github.com/konstructio/kubefirst-api/pkg/providerConfigs"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+	"github.com/kubefirst/metrics-client/pkg/telemetry"
+)
+
+// DeleteK3sCluster
+func DeleteK3sCluster(ctx context.Context, cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryEvent) error {
+	logger := clusterlogs.Logger(ctx)
+
+	telemetry.SendEvent(telemetryEvent, telemetry.ClusterDeleteStarted, "")
+
+	// Instantiate provider config
+	config, err := providerConfigs.GetConfig(
+		cl.ClusterName,
+		cl.DomainName,
+		cl.GitProvider,
+		cl.GitAuth.Owner,
+		cl.GitProtocol,
+		cl.CloudflareAuth.APIToken,
+		cl.CloudflareAuth.OriginCaIssuerKey,
+	)
+	if err != nil {
+		return fmt.Errorf("error getting provider config for cluster %s: %w", cl.ClusterName, err)
+	}
+
+	kcfg := utils.GetKubernetesClient(cl.ClusterName)
+
+	if err := secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+		cluster.Status = constants.ClusterStatusDeleting
+	}); err != nil {
+		return fmt.Errorf("error updating cluster status for cluster %s: %w", cl.ClusterName, err)
+	}
+
+	tfEnvs := map[string]string{}
+	var tfEntrypoint string
+
+	if cl.GitTerraformApplyCheck {
+		logger.Info().Msgf("destroying %s resources with terraform", cl.GitProvider)
+		switch cl.GitProvider {
+		case "github":
+			tfEntrypoint = config.GitopsDir + "/terraform/github"
+			tfEnvs = k3sext.GetK3sTerraformEnvs(tfEnvs, cl)
+			tfEnvs = k3sext.GetGithubTerraformEnvs(tfEnvs, cl)
+
+		case "gitlab":
+			gitlabClient, err := gitlab.NewGitLabClient(cl.GitAuth.Token, cl.GitAuth.Owner)
+			if err != nil {
+				return fmt.Errorf("error creating GitLab client for cluster %s: %w", cl.ClusterName, err)
+			}
+
+			// Before removing Terraform resources, remove any container registry repositories
+			// since failing to remove them beforehand will result in an apply failure
+			projectsForDeletion := []string{"gitops", "metaphor"}
+			for _, project := range projectsForDeletion {
+				projectExists, err := gitlabClient.CheckProjectExists(project)
+				if err != nil {
+					logger.Error().Msgf("could not check for existence of project %s: %s", project, err)
+				}
+				if projectExists {
+					logger.Info().Msgf("checking project %s for container registries...", project)
+					crr, err := gitlabClient.GetProjectContainerRegistryRepositories(project)
+					if err != nil {
+						logger.Error().Msgf("could not retrieve container registry repositories: %s", err)
+					}
+					if len(crr) > 0 {
+						for _, cr := range crr {
+							err := gitlabClient.DeleteContainerRegistryRepository(project, cr.ID)
+							if err != nil {
+								logger.Error().Msgf("error deleting container registry repository: %s", err)
+							}
+						}
+					} else {
+						logger.Info().Msgf("project %s does not have any container registries, skipping", project)
+					}
+				} else {
+					logger.Info().Msgf("project %s does not exist, skipping", project)
+				}
+			}
+			tfEntrypoint = config.GitopsDir + "/terraform/gitlab"
+			tfEnvs = k3sext.GetK3sTerraformEnvs(tfEnvs, cl)
+			tfEnvs = k3sext.GetGitlabTerraformEnvs(tfEnvs, gitlabClient.ParentGroupID, cl)
+		}
+
+		err = terraformext.InitDestroyAutoApprove(config.TerraformClient, tfEntrypoint, tfEnvs)
+		if err != nil {
+			logger.Info().Msgf("error executing terraform destroy %s", tfEntrypoint)
+			errors.HandleClusterError(cl, err.Error())
+			return fmt.Errorf("error executing terraform destroy for %s: %w", tfEntrypoint, err)
+		}
+
+		logger.Info().Msgf("%s resources terraform destroyed", cl.GitProvider)
+
+		err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+			cluster.GitTerraformApplyCheck = false
+		})
+		if err != nil {
+			return fmt.Errorf("error updating cluster status after terraform destroy for cluster %s: %w", cl.ClusterName, err)
+		}
+	}
+
+	if cl.CloudTerraformApplyCheck || cl.CloudTerraformApplyFailedCheck || cl.CloudTerraformApplyCancelledCheck {
+		logger.Info().Msg("destroying k3s resources with terraform")
+		tfEntrypoint := config.GitopsDir + fmt.Sprintf("/terraform/%s", cl.CloudProvider)
+		tfEnvs := map[string]string{}
+		tfEnvs = k3sext.GetK3sTerraformEnvs(tfEnvs, cl)
+
+		switch cl.GitProvider {
+		case "github":
+			tfEnvs = k3sext.GetGithubTerraformEnvs(tfEnvs, cl)
+		case "gitlab":
+			tfEnvs = k3sext.GetGitlabTerraformEnvs(tfEnvs, cl.GitlabOwnerGroupID, cl)
+		}
+		err = terraformext.InitDestroyAutoApprove(config.TerraformClient, tfEntrypoint, tfEnvs)
+		if err != nil {
+			logger.Printf("error executing terraform destroy %s", tfEntrypoint)
+			errors.HandleClusterError(cl, err.Error())
+			return fmt.Errorf("error executing terraform destroy for %s: %w", tfEntrypoint, err)
+		}
+		logger.Info().Msg("k3s resources terraform destroyed")
+
+		// Terraform only drops its own record of the k3s install, the nodes
+		// themselves are cleaned up over ssh
+		err = k3sext.UninstallK3sNodes(cl)
+		if err != nil {
+			errors.HandleClusterError(cl, err.Error())
+			return fmt.Errorf("error cleaning up k3s nodes for cluster %s: %w", cl.ClusterName, err)
+		}
+		logger.Info().Msg("k3s removed from all nodes")
+
+		err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+			cluster.CloudTerraformApplyCheck = false
+			cluster.CloudTerraformApplyFailedCheck = false
+			cluster.CloudTerraformApplyCancelledCheck = false
+		})
+		if err != nil {
+			return fmt.Errorf("error updating cluster status after cloud resource destruction for cluster %s: %w", cl.ClusterName, err)
+		}
+	}
+
+	// remove ssh key provided one was created
+	if cl.GitProvider == "gitlab" {
+		gitlabClient, err := gitlab.NewGitLabClient(cl.GitAuth.Token, cl.GitAuth.Owner)
+		if err != nil {
+			return fmt.Errorf("error creating GitLab client for SSH key deletion for cluster %s: %w", cl.ClusterName, err)
+		}
+		logger.Info().Msg("attempting to delete managed ssh key...")
+		err = gitlabClient.DeleteUserSSHKey("kbot-ssh-key")
+		if err != nil {
+			logger.Warn().Msg(err.Error())
+		}
+	}
+
+	telemetry.SendEvent(telemetryEvent, telemetry.ClusterDeleteCompleted, "")
+
+	err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+		cluster.Status = constants.ClusterStatusDeleted
+	})
+	if err != nil {
+		return fmt.Errorf("error updating cluster status to deleted for cluster %s: %w", cl.ClusterName, err)
+	}
+
+	err = pkg.ResetK1Dir(config.K1Dir)
+	if err != nil {
+		return fmt.Errorf("error resetting K1 directory for cluster %s: %w", cl.ClusterName, err)
+	}
+
+	return nil
+}
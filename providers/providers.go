@@ -0,0 +1,432 @@
+/*
+Copyright (C) 2021-2023, Kubefirst
+
+This program is licensed under MIT.
+See the LICENSE file for more details.
+*/
+package providers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/konstructio/kubefirst-api/internal/constants"
+	"github.com/konstructio/kubefirst-api/internal/controller"
+	"github.com/konstructio/kubefirst-api/internal/env"
+	"github.com/konstructio/kubefirst-api/internal/events"
+	"github.com/konstructio/kubefirst-api/internal/k8s"
+	"github.com/konstructio/kubefirst-api/internal/secrets"
+	"github.com/konstructio/kubefirst-api/internal/utils"
+	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
+	"github.com/konstructio/kubefirst-api/providers/akamai"
+	"github.com/konstructio/kubefirst-api/providers/aws"
+	"github.com/konstructio/kubefirst-api/providers/civo"
+	"github.com/konstructio/kubefirst-api/providers/digitalocean"
+	"github.com/konstructio/kubefirst-api/providers/google"
+	"github.com/konstructio/kubefirst-api/providers/k3s"
+	"github.com/konstructio/kubefirst-api/providers/vultr"
+	"github.com/kubefirst/metrics-client/pkg/telemetry"
+	log "github.com/rs/zerolog/log"
+	"k8s.io/client-go/kubernetes"
+)
+
+// CreateCluster runs the create process for the cloud provider set on the
+// definition, stopping early when ctx is cancelled
+func CreateCluster(ctx context.Context, def *pkgtypes.ClusterDefinition) error {
+	switch def.CloudProvider {
+	case "akamai":
+		return akamai.CreateAkamaiCluster(ctx, def)
+	case "aws":
+		return aws.CreateAWSCluster(ctx, def)
+	case "civo":
+		return civo.CreateCivoCluster(ctx, def)
+	case "digitalocean":
+		return digitalocean.CreateDigitaloceanCluster(ctx, def)
+	case "google":
+		return google.CreateGoogleCluster(ctx, def)
+	case "k3s":
+		return k3s.CreateK3sCluster(ctx, def)
+	case "vultr":
+		return vultr.CreateVultrCluster(ctx, def)
+	default:
+		return fmt.Errorf("cloud provider %q does not support cluster create", def.CloudProvider)
+	}
+}
+
+// Pipeline returns the cluster create pipeline for a cloud provider
+func Pipeline(cloudProvider string) ([]controller.Step, error) {
+	switch cloudProvider {
+	case "akamai":
+		return akamai.Pipeline()
+	case "aws":
+		return aws.Pipeline()
+	case "civo":
+		return civo.Pipeline()
+	case "digitalocean":
+		return digitalocean.Pipeline()
+	case "google":
+		return google.Pipeline()
+	case "k3s":
+		return k3s.Pipeline()
+	case "vultr":
+		return vultr.Pipeline()
+	default:
+		return nil, fmt.Errorf("cloud provider %q does not support cluster create", cloudProvider)
+	}
+}
+
+// DryRunCluster runs the read-only checks of a cluster create and plans its
+// cloud terraform. Nothing is created and no cluster record is written.
+func DryRunCluster(ctx context.Context, def *pkgtypes.ClusterDefinition) *pkgtypes.DryRunReport {
+	if _, err := Pipeline(def.CloudProvider); err != nil {
+		report := controller.NewDryRunReport(def.ClusterName, def.CloudProvider)
+		controller.AddDryRunCheck(report, controller.DryRunCheckInitialize, "", err)
+		return report
+	}
+
+	// dry runs answer the request that started them, so the checks and the
+	// terraform plan are bounded instead of holding the request indefinitely
+	env, _ := env.GetEnv(constants.SilenceGetEnv)
+	if env.DryRunTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, env.DryRunTimeout)
+		defer cancel()
+	}
+
+	ctrl := controller.ClusterController{DryRun: true}
+	if err := ctrl.InitController(ctx, def); err != nil {
+		report := controller.NewDryRunReport(def.ClusterName, def.CloudProvider)
+		controller.AddDryRunCheck(report, controller.DryRunCheckInitialize, "", fmt.Errorf("error initializing controller: %w", err))
+		return report
+	}
+
+	return ctrl.RunDryRun()
+}
+
+// StepDeleteCluster is the step events of a cluster delete are published for
+const StepDeleteCluster = "delete_cluster"
+
+// DeleteCluster runs the delete process for the cloud provider set on the
+// cluster, publishing its start and end as step events
+func DeleteCluster(ctx context.Context, cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryEvent) error {
+	events.Publish(events.Event{Type: events.StepStarted, Cluster: cl.ClusterName, Step: StepDeleteCluster})
+
+	err := deleteCluster(ctx, cl, telemetryEvent)
+	events.Publish(events.StepFinished(cl.ClusterName, StepDeleteCluster, err))
+
+	return err
+}
+
+func deleteCluster(ctx context.Context, cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryEvent) error {
+	switch cl.CloudProvider {
+	case "akamai":
+		return akamai.DeleteAkamaiCluster(ctx, cl, telemetryEvent)
+	case "aws":
+		return aws.DeleteAWSCluster(ctx, cl, telemetryEvent)
+	case "civo":
+		return civo.DeleteCivoCluster(ctx, cl, telemetryEvent)
+	case "digitalocean":
+		return digitalocean.DeleteDigitaloceanCluster(ctx, cl, telemetryEvent)
+	case "google":
+		return google.DeleteGoogleCluster(ctx, cl, telemetryEvent)
+	case "k3s":
+		return k3s.DeleteK3sCluster(ctx, cl, telemetryEvent)
+	case "vultr":
+		return vultr.DeleteVultrCluster(ctx, cl, telemetryEvent)
+	default:
+		return fmt.Errorf("cloud provider %q does not support cluster delete", cl.CloudProvider)
+	}
+}
+
+// DeleteTelemetryEvent returns the telemetry event sent during a cluster delete
+func DeleteTelemetryEvent(cl *pkgtypes.Cluster) telemetry.TelemetryEvent {
+	env, _ := env.GetEnv(constants.SilenceGetEnv)
+
+	return telemetry.TelemetryEvent{
+		CliVersion:        env.KubefirstVersion,
+		CloudProvider:     cl.CloudProvider,
+		ClusterID:         cl.ClusterID,
+		ClusterType:       cl.ClusterType,
+		DomainName:        cl.DomainName,
+		GitProvider:       cl.GitProvider,
+		InstallMethod:     "",
+		KubefirstClient:   "api",
+		KubefirstTeam:     env.KubefirstTeam,
+		KubefirstTeamInfo: env.KubefirstTeamInfo,
+		MachineID:         cl.DomainName,
+		ErrorMessage:      "",
+		UserId:            cl.DomainName,
+		MetricName:        telemetry.ClusterDeleteStarted,
+	}
+}
+
+// DefinitionFromCluster rebuilds the create request for an existing cluster record
+// so a create can be run again against it
+func DefinitionFromCluster(cl *pkgtypes.Cluster) pkgtypes.ClusterDefinition {
+	return pkgtypes.ClusterDefinition{
+		AdminEmail:             cl.AlertsEmail,
+		CloudProvider:          cl.CloudProvider,
+		CloudRegion:            cl.CloudRegion,
+		ClusterName:            cl.ClusterName,
+		DomainName:             cl.DomainName,
+		SubdomainName:          cl.SubdomainName,
+		DNSProvider:            cl.DNSProvider,
+		Type:                   cl.ClusterType,
+		NodeType:               cl.NodeType,
+		NodeCount:              cl.NodeCount,
+		PostInstallCatalogApps: cl.PostInstallCatalogApps,
+		InstallKubefirstPro:    cl.InstallKubefirstPro,
+		ForceDestroy:           cl.ForceDestroy,
+		GitopsTemplateURL:      cl.GitopsTemplateURL,
+		GitopsTemplateBranch:   cl.GitopsTemplateBranch,
+		GitProvider:            cl.GitProvider,
+		GitProtocol:            cl.GitProtocol,
+		ECR:                    cl.ECR,
+		AkamaiAuth:             cl.AkamaiAuth,
+		AWSAuth:                cl.AWSAuth,
+		CivoAuth:               cl.CivoAuth,
+		DigitaloceanAuth:       cl.DigitaloceanAuth,
+		VultrAuth:              cl.VultrAuth,
+		CloudflareAuth:         cl.CloudflareAuth,
+		GoogleAuth:             cl.GoogleAuth,
+		K3sAuth:                cl.K3sAuth,
+		GitAuth:                cl.GitAuth,
+		LogFileName:            cl.LogFileName,
+	}
+}
+
+// RetryCluster runs the selected steps of the create pipeline again for an
+// existing cluster. The cluster status is then derived from the step records:
+// provisioned once every step succeeded, error while a step has failed and
+// provisioning while steps are left to run.
+func RetryCluster(ctx context.Context, cl *pkgtypes.Cluster, selected []string) error {
+	steps, err := Pipeline(cl.CloudProvider)
+	if err != nil {
+		return err
+	}
+
+	def := DefinitionFromCluster(cl)
+	ctrl := controller.ClusterController{}
+	if err := ctrl.InitController(ctx, &def); err != nil {
+		return fmt.Errorf("error initializing controller: %w", err)
+	}
+
+	err = secrets.UpdateClusterFields(ctrl.KubernetesClient, &ctrl.Cluster, func(cluster *pkgtypes.Cluster) {
+		cluster.Status = constants.ClusterStatusProvisioning
+		cluster.InProgress = true
+		cluster.LastCondition = ""
+	})
+	if err != nil {
+		return fmt.Errorf("error updating cluster status: %w", err)
+	}
+
+	// the status is recomputed from the step records once the steps ran
+	if err := ctrl.RunSelectedSteps(steps, selected); err != nil {
+		return fmt.Errorf("error retrying steps for cluster %q: %w", cl.ClusterName, err)
+	}
+
+	log.Info().Msgf("retried steps %v for cluster %s", selected, cl.ClusterName)
+	return nil
+}
+
+// UpdateClusterNodes changes the node pool of a provisioned cluster. The
+// cluster is marked as updating while the change runs and returns to
+// provisioned afterwards. On failure it keeps its previous status, with the
+// error kept in last_condition.
+func UpdateClusterNodes(ctx context.Context, cl *pkgtypes.Cluster, req *pkgtypes.ClusterNodesRequest) error {
+	def := DefinitionFromCluster(cl)
+	ctrl := controller.ClusterController{}
+	if err := ctrl.InitController(ctx, &def); err != nil {
+		return fmt.Errorf("error initializing controller: %w", err)
+	}
+
+	previousStatus := ctrl.Cluster.Status
+	err := secrets.UpdateClusterFields(ctrl.KubernetesClient, &ctrl.Cluster, func(cluster *pkgtypes.Cluster) {
+		cluster.Status = constants.ClusterStatusUpdating
+		cluster.InProgress = true
+		cluster.LastCondition = ""
+	})
+	if err != nil {
+		return fmt.Errorf("error updating cluster status: %w", err)
+	}
+
+	updateErr := ctrl.UpdateNodes(req)
+
+	err = secrets.UpdateClusterFields(ctrl.KubernetesClient, &ctrl.Cluster, func(cluster *pkgtypes.Cluster) {
+		cluster.Status = constants.ClusterStatusProvisioned
+		cluster.InProgress = false
+		if updateErr != nil {
+			cluster.Status = previousStatus
+			cluster.LastCondition = updateErr.Error()
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("error updating cluster status: %w", err)
+	}
+
+	if updateErr != nil {
+		return fmt.Errorf("error updating nodes for cluster %q: %w", cl.ClusterName, updateErr)
+	}
+
+	return nil
+}
+
+// UpgradeCluster upgrades the Kubernetes version of a provisioned cluster. The
+// cluster is marked as updating while the upgrade runs and returns to
+// provisioned afterwards, with the error kept in last_condition on failure.
+func UpgradeCluster(ctx context.Context, cl *pkgtypes.Cluster, version string) error {
+	def := DefinitionFromCluster(cl)
+	ctrl := controller.ClusterController{}
+	if err := ctrl.InitController(ctx, &def); err != nil {
+		return fmt.Errorf("error initializing controller: %w", err)
+	}
+
+	err := secrets.UpdateClusterFields(ctrl.KubernetesClient, &ctrl.Cluster, func(cluster *pkgtypes.Cluster) {
+		cluster.Status = constants.ClusterStatusUpdating
+		cluster.InProgress = true
+		cluster.LastCondition = ""
+	})
+	if err != nil {
+		return fmt.Errorf("error updating cluster status: %w", err)
+	}
+
+	upgradeErr := ctrl.UpgradeKubernetes(version)
+
+	err = secrets.UpdateClusterFields(ctrl.KubernetesClient, &ctrl.Cluster, func(cluster *pkgtypes.Cluster) {
+		cluster.Status = constants.ClusterStatusProvisioned
+		cluster.InProgress = false
+		if upgradeErr != nil {
+			cluster.LastCondition = upgradeErr.Error()
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("error updating cluster status: %w", err)
+	}
+
+	if upgradeErr != nil {
+		return fmt.Errorf("error upgrading cluster %q to kubernetes %s: %w", cl.ClusterName, version, upgradeErr)
+	}
+
+	return nil
+}
+
+// DetectClusterDrift compares a provisioned cluster to its gitops repository
+// and stores the result in the drift section of the cluster record
+func DetectClusterDrift(ctx context.Context, cl *pkgtypes.Cluster) error {
+	def := DefinitionFromCluster(cl)
+	ctrl := controller.ClusterController{}
+	if err := ctrl.InitController(ctx, &def); err != nil {
+		return fmt.Errorf("error initializing controller: %w", err)
+	}
+
+	drift := ctrl.DetectDrift()
+
+	// the plans can take minutes, so the drift is written to the latest record
+	// rather than the one the controller was initialized with
+	_, err := secrets.UpdateClusterWith(ctrl.KubernetesClient, cl.ClusterName, func(latest *pkgtypes.Cluster) error {
+		latest.Drift = drift
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error recording drift for cluster %q: %w", cl.ClusterName, err)
+	}
+
+	if drift.Drifted {
+		log.Warn().Msgf("cluster %s has drifted from its gitops repository", cl.ClusterName)
+	}
+	return nil
+}
+
+const (
+	// driftLease is the Lease that makes a single api replica run drift detection
+	driftLease = "kubefirst-drift-detection"
+	// driftLeaseDuration is shorter than the detection interval so the holder
+	// renews it on each run, and another replica takes over an hour after the
+	// holder stops
+	driftLeaseDuration = 50 * time.Minute
+)
+
+// ScheduledDriftDetection checks every provisioned cluster for drift when the
+// api starts and once an hour after that. Only the replica holding the drift
+// detection Lease runs the checks. Clusters with a process in progress are left
+// for the next run.
+func ScheduledDriftDetection() {
+	kcfg := utils.GetKubernetesClient("")
+
+	holder, err := os.Hostname()
+	if err != nil {
+		log.Warn().Msgf("unable to determine hostname for drift detection lease: %s", err)
+	}
+
+	detectDrift(kcfg.Clientset, holder)
+	for range time.Tick(time.Hour) {
+		detectDrift(kcfg.Clientset, holder)
+	}
+}
+
+func detectDrift(clientset kubernetes.Interface, holder string) {
+	acquired, err := k8s.AcquireLease(context.Background(), clientset, "kubefirst", driftLease, holder, driftLeaseDuration)
+	if err != nil {
+		log.Warn().Msgf("error acquiring drift detection lease: %s", err)
+		return
+	}
+	if !acquired {
+		log.Debug().Msg("drift detection is run by another replica")
+		return
+	}
+
+	clusters, err := secrets.GetClusters(clientset)
+	if err != nil {
+		log.Warn().Msgf("error listing clusters for drift detection: %s", err)
+		return
+	}
+
+	for i := range clusters {
+		cl := &clusters[i]
+		if cl.Status != constants.ClusterStatusProvisioned || cl.InProgress {
+			continue
+		}
+		if err := DetectClusterDrift(context.Background(), cl); err != nil {
+			log.Warn().Msgf("error detecting drift for cluster %s: %s", cl.ClusterName, err)
+		}
+	}
+}
+
+// ResumeCreateCluster restarts an interrupted cluster create job. Steps that
+// already completed are skipped based on the checks stored on the cluster record.
+func ResumeCreateCluster(ctx context.Context, job *pkgtypes.Job) error {
+	kcfg := utils.GetKubernetesClient(job.ClusterName)
+
+	cl, err := secrets.GetCluster(kcfg.Clientset, job.ClusterName)
+	if err != nil {
+		return fmt.Errorf("error retrieving cluster %q to resume create: %w", job.ClusterName, err)
+	}
+
+	if cl.Status == constants.ClusterStatusProvisioned {
+		log.Info().Msgf("cluster %s is already provisioned, nothing to resume", cl.ClusterName)
+		return nil
+	}
+
+	def := DefinitionFromCluster(cl)
+	return CreateCluster(ctx, &def)
+}
+
+// ResumeDeleteCluster restarts an interrupted cluster delete job. Resources that
+// were already destroyed are skipped based on the checks stored on the cluster record.
+func ResumeDeleteCluster(ctx context.Context, job *pkgtypes.Job) error {
+	kcfg := utils.GetKubernetesClient(job.ClusterName)
+
+	cl, err := secrets.GetCluster(kcfg.Clientset, job.ClusterName)
+	if err != nil {
+		return fmt.Errorf("error retrieving cluster %q to resume delete: %w", job.ClusterName, err)
+	}
+
+	if cl.Status == constants.ClusterStatusDeleted {
+		log.Info().Msgf("cluster %s is already deleted, nothing to resume", cl.ClusterName)
+		return nil
+	}
+
+	return DeleteCluster(ctx, cl, DeleteTelemetryEvent(cl))
+}
@@ -0,0 +1,59 @@
+package providers
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/konstructio/kubefirst-api/internal/controller"
+)
+
+func TestPipeline(t *testing.T) {
+	tests := []struct {
+		cloudProvider string
+		first         string
+		includes      []string
+		excludes      []string
+	}{
+		{cloudProvider: "akamai", first: controller.StepDownloadTools, includes: []string{controller.StepStateStoreCreate}, excludes: []string{controller.StepWaitForClusterReady}},
+		{cloudProvider: "aws", first: controller.StepCheckAvailabilityZones, includes: []string{controller.StepDetokenizeKMSKeyID}, excludes: []string{controller.StepRestoreSSL, controller.StepStateStoreCreate}},
+		{cloudProvider: "civo", first: controller.StepDownloadTools, includes: []string{controller.StepStateStoreCreate}, excludes: []string{controller.StepWaitForClusterReady}},
+		{cloudProvider: "digitalocean", first: controller.StepDownloadTools, includes: []string{controller.StepWaitForClusterReady}},
+		{cloudProvider: "google", first: controller.StepWriteGoogleCredentials, includes: []string{controller.StepDetokenizeKMSKeyID}, excludes: []string{controller.StepRestoreSSL}},
+		{cloudProvider: "k3s", first: controller.StepDownloadTools, includes: []string{controller.StepWaitForClusterReady}},
+		{cloudProvider: "vultr", first: controller.StepDownloadTools, includes: []string{controller.StepWaitForClusterReady}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cloudProvider, func(t *testing.T) {
+			steps, err := Pipeline(tt.cloudProvider)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			names := make([]string, 0, len(steps))
+			for _, step := range steps {
+				names = append(names, step.Name)
+			}
+
+			if names[0] != tt.first {
+				t.Errorf("expected first step %q, got %q", tt.first, names[0])
+			}
+
+			for _, name := range tt.includes {
+				if !slices.Contains(names, name) {
+					t.Errorf("expected pipeline to include %q", name)
+				}
+			}
+
+			for _, name := range tt.excludes {
+				if slices.Contains(names, name) {
+					t.Errorf("expected pipeline to exclude %q", name)
+				}
+			}
+		})
+	}
+
+	if _, err := Pipeline("k3d"); err == nil {
+		t.Error("expected an error for an unsupported cloud provider")
+	}
+}
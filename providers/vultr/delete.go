@@ -15,6 +15,7 @@ import (
 	vultrext "github.com/konstructio/kubefirst-api/extensions/vultr"
 	runtime "github.com/konstructio/kubefirst-api/internal"
 	"github.com/konstructio/kubefirst-api/internal/argocd"
+	"github.com/konstructio/kubefirst-api/internal/clusterlogs"
 	"github.com/konstructio/kubefirst-api/internal/constants"
 	"github.com/konstructio/kubefirst-api/internal/errors"
 	gitlab "github.com/konstructio/kubefirst-api/internal/gitlab"
@@ -26,11 +27,12 @@ import (
 	"github.com/konstructio/kubefirst-api/pkg/providerConfigs"
 	pkgtypes "github.com/konstructio/kubefirst-api/pkg/types"
 	"github.com/kubefirst/metrics-client/pkg/telemetry"
-	log "github.com/rs/zerolog/log"
 )
 
 // DeleteVultrCluster
-func DeleteVultrCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryEvent) error {
+func DeleteVultrCluster(ctx context.Context, cl *pkgtypes.Cluster, telemetryEvent telemetry.TelemetryEvent) error {
+	logger := clusterlogs.Logger(ctx)
+
 	telemetry.SendEvent(telemetryEvent, telemetry.ClusterDeleteStarted, "")
 
 	// Instantiate provider config
@@ -49,16 +51,16 @@ func DeleteVultrCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.Telemetry
 
 	kcfg := utils.GetKubernetesClient(cl.ClusterName)
 
-	cl.Status = constants.ClusterStatusDeleting
-
-	if err := secrets.UpdateCluster(kcfg.Clientset, *cl); err != nil {
+	if err := secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+		cluster.Status = constants.ClusterStatusDeleting
+	}); err != nil {
 		return fmt.Errorf("error updating cluster secrets for cluster %q: %w", cl.ClusterName, err)
 	}
 
 	switch cl.GitProvider {
 	case "github":
 		if cl.GitTerraformApplyCheck {
-			log.Info().Msg("destroying github resources with terraform")
+			logger.Info().Msg("destroying github resources with terraform")
 
 			tfEntrypoint := config.GitopsDir + "/terraform/github"
 			tfEnvs := map[string]string{}
@@ -66,21 +68,22 @@ func DeleteVultrCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.Telemetry
 			tfEnvs = vultrext.GetGithubTerraformEnvs(tfEnvs, cl)
 			err := terraformext.InitDestroyAutoApprove(config.TerraformClient, tfEntrypoint, tfEnvs)
 			if err != nil {
-				log.Printf("error executing terraform destroy %s", tfEntrypoint)
+				logger.Printf("error executing terraform destroy %s", tfEntrypoint)
 				errors.HandleClusterError(cl, err.Error())
 				return fmt.Errorf("error executing terraform destroy %q: %w", tfEntrypoint, err)
 			}
-			log.Info().Msg("github resources terraform destroyed")
+			logger.Info().Msg("github resources terraform destroyed")
 
-			cl.GitTerraformApplyCheck = false
-			err = secrets.UpdateCluster(kcfg.Clientset, *cl)
+			err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+				cluster.GitTerraformApplyCheck = false
+			})
 			if err != nil {
 				return fmt.Errorf("error updating cluster secrets after destroying github resources for cluster %q: %w", cl.ClusterName, err)
 			}
 		}
 	case "gitlab":
 		if cl.GitTerraformApplyCheck {
-			log.Info().Msg("destroying gitlab resources with terraform")
+			logger.Info().Msg("destroying gitlab resources with terraform")
 			gitlabClient, err := gitlab.NewGitLabClient(cl.GitAuth.Token, cl.GitAuth.Owner)
 			if err != nil {
 				return fmt.Errorf("error creating gitlab client for cluster %q: %w", cl.ClusterName, err)
@@ -92,29 +95,29 @@ func DeleteVultrCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.Telemetry
 			for _, project := range projectsForDeletion {
 				projectExists, err := gitlabClient.CheckProjectExists(project)
 				if err != nil {
-					log.Error().Msgf("could not check for existence of project %s: %s", project, err)
+					logger.Error().Msgf("could not check for existence of project %s: %s", project, err)
 					return fmt.Errorf("could not check for existence of project %q: %w", project, err)
 				}
 				if projectExists {
-					log.Info().Msgf("checking project %s for container registries...", project)
+					logger.Info().Msgf("checking project %s for container registries...", project)
 					crr, err := gitlabClient.GetProjectContainerRegistryRepositories(project)
 					if err != nil {
-						log.Error().Msgf("could not retrieve container registry repositories: %s", err)
+						logger.Error().Msgf("could not retrieve container registry repositories: %s", err)
 						return fmt.Errorf("could not retrieve container registry repositories for project %q: %w", project, err)
 					}
 					if len(crr) > 0 {
 						for _, cr := range crr {
 							err := gitlabClient.DeleteContainerRegistryRepository(project, cr.ID)
 							if err != nil {
-								log.Error().Msgf("error deleting container registry repository: %s", err)
+								logger.Error().Msgf("error deleting container registry repository: %s", err)
 								return fmt.Errorf("error deleting container registry repository for project %q: %w", project, err)
 							}
 						}
 					} else {
-						log.Info().Msgf("project %s does not have any container registries, skipping", project)
+						logger.Info().Msgf("project %s does not have any container registries, skipping", project)
 					}
 				} else {
-					log.Info().Msgf("project %s does not exist, skipping", project)
+					logger.Info().Msgf("project %s does not exist, skipping", project)
 				}
 			}
 
@@ -124,15 +127,16 @@ func DeleteVultrCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.Telemetry
 			tfEnvs = vultrext.GetGitlabTerraformEnvs(tfEnvs, gitlabClient.ParentGroupID, cl)
 			err = terraformext.InitDestroyAutoApprove(config.TerraformClient, tfEntrypoint, tfEnvs)
 			if err != nil {
-				log.Info().Msgf("error executing terraform destroy %s", tfEntrypoint)
+				logger.Info().Msgf("error executing terraform destroy %s", tfEntrypoint)
 				errors.HandleClusterError(cl, err.Error())
 				return fmt.Errorf("error executing terraform destroy %q: %w", tfEntrypoint, err)
 			}
 
-			log.Info().Msg("gitlab resources terraform destroyed")
+			logger.Info().Msg("gitlab resources terraform destroyed")
 
-			cl.GitTerraformApplyCheck = false
-			err = secrets.UpdateCluster(kcfg.Clientset, *cl)
+			err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+				cluster.GitTerraformApplyCheck = false
+			})
 			if err != nil {
 				return fmt.Errorf("error updating cluster secrets after destroying gitlab resources for cluster %q: %w", cl.ClusterName, err)
 			}
@@ -158,11 +162,11 @@ func DeleteVultrCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.Telemetry
 		}
 		err = argocd.ApplicationCleanup(kcfg.Clientset, removeArgoCDApps)
 		if err != nil {
-			log.Error().Msgf("encountered error during argocd application cleanup: %s", err)
+			logger.Error().Msgf("encountered error during argocd application cleanup: %s", err)
 			return fmt.Errorf("encountered error during argocd application cleanup for cluster %q: %w", cl.ClusterName, err)
 		}
 		// Pause before cluster destroy to prevent a race condition
-		log.Info().Msg("waiting for argocd application deletion to complete...")
+		logger.Info().Msg("waiting for argocd application deletion to complete...")
 		time.Sleep(time.Second * 20)
 	}
 
@@ -176,18 +180,18 @@ func DeleteVultrCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.Telemetry
 		return fmt.Errorf("error getting associated block storage for cluster %q: %w", cl.ClusterName, err)
 	}
 
-	if cl.CloudTerraformApplyCheck || cl.CloudTerraformApplyFailedCheck {
-		if !cl.CloudTerraformApplyFailedCheck {
+	if cl.CloudTerraformApplyCheck || cl.CloudTerraformApplyFailedCheck || cl.CloudTerraformApplyCancelledCheck {
+		if !cl.CloudTerraformApplyFailedCheck && !cl.CloudTerraformApplyCancelledCheck {
 			kcfg, err := k8s.CreateKubeConfig(false, config.Kubeconfig)
 			if err != nil {
 				return fmt.Errorf("error creating kubeconfig for cluster %q: %w", cl.ClusterName, err)
 			}
 
-			log.Info().Msg("destroying vultr resources with terraform")
+			logger.Info().Msg("destroying vultr resources with terraform")
 
 			// Only port-forward to ArgoCD and delete registry if ArgoCD was installed
 			if !cl.ArgoCDDeleteRegistryCheck {
-				log.Info().Msg("opening argocd port forward")
+				logger.Info().Msg("opening argocd port forward")
 				// * ArgoCD port-forward
 				argoCDStopChannel := make(chan struct{}, 1)
 				defer func() {
@@ -203,7 +207,7 @@ func DeleteVultrCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.Telemetry
 					argoCDStopChannel,
 				)
 
-				log.Info().Msg("getting new auth token for argocd")
+				logger.Info().Msg("getting new auth token for argocd")
 
 				secData, err := k8s.ReadSecretV2(kcfg.Clientset, "argocd", "argocd-initial-admin-secret")
 				if err != nil {
@@ -216,29 +220,30 @@ func DeleteVultrCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.Telemetry
 					return fmt.Errorf("error getting argocd token for cluster %q: %w", cl.ClusterName, err)
 				}
 
-				log.Info().Msgf("port-forward to argocd is available at %s", providerConfigs.ArgocdPortForwardURL)
+				logger.Info().Msgf("port-forward to argocd is available at %s", providerConfigs.ArgocdPortForwardURL)
 
 				client := httpCommon.CustomHTTPClient(true)
-				log.Info().Msg("deleting the registry application")
+				logger.Info().Msg("deleting the registry application")
 				httpCode, _, err := argocd.DeleteApplication(client, config.RegistryAppName, argocdAuthToken, "true")
 				if err != nil {
 					return fmt.Errorf("error deleting registry application for cluster %q: %w", cl.ClusterName, err)
 				}
-				log.Info().Msgf("http status code %d", httpCode)
+				logger.Info().Msgf("http status code %d", httpCode)
 			}
 
 			// Pause before cluster destroy to prevent a race condition
-			log.Info().Msg("waiting for vultr kubernetes cluster resource removal to finish...")
+			logger.Info().Msg("waiting for vultr kubernetes cluster resource removal to finish...")
 			time.Sleep(time.Second * 10)
 
-			cl.ArgoCDDeleteRegistryCheck = true
-			err = secrets.UpdateCluster(kcfg.Clientset, *cl)
+			err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+				cluster.ArgoCDDeleteRegistryCheck = true
+			})
 			if err != nil {
 				return fmt.Errorf("error updating cluster secrets after waiting for resource removal for cluster %q: %w", cl.ClusterName, err)
 			}
 		}
 
-		log.Info().Msg("destroying vultr cloud resources")
+		logger.Info().Msg("destroying vultr cloud resources")
 		tfEntrypoint := config.GitopsDir + fmt.Sprintf("/terraform/%s", cl.CloudProvider)
 		tfEnvs := map[string]string{}
 		tfEnvs = vultrext.GetVultrTerraformEnvs(tfEnvs, cl)
@@ -251,15 +256,17 @@ func DeleteVultrCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.Telemetry
 		}
 		err = terraformext.InitDestroyAutoApprove(config.TerraformClient, tfEntrypoint, tfEnvs)
 		if err != nil {
-			log.Printf("error executing terraform destroy %s", tfEntrypoint)
+			logger.Printf("error executing terraform destroy %s", tfEntrypoint)
 			errors.HandleClusterError(cl, err.Error())
 			return fmt.Errorf("error executing terraform destroy %q: %w", tfEntrypoint, err)
 		}
-		log.Info().Msg("vultr resources terraform destroyed")
+		logger.Info().Msg("vultr resources terraform destroyed")
 
-		cl.CloudTerraformApplyCheck = false
-		cl.CloudTerraformApplyFailedCheck = false
-		err = secrets.UpdateCluster(kcfg.Clientset, *cl)
+		err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+			cluster.CloudTerraformApplyCheck = false
+			cluster.CloudTerraformApplyFailedCheck = false
+			cluster.CloudTerraformApplyCancelledCheck = false
+		})
 		if err != nil {
 			return fmt.Errorf("error updating cluster secrets after destroying vultr resources for cluster %q: %w", cl.ClusterName, err)
 		}
@@ -280,18 +287,19 @@ func DeleteVultrCluster(cl *pkgtypes.Cluster, telemetryEvent telemetry.Telemetry
 			return fmt.Errorf("error creating gitlab client for deleting ssh key for cluster %q: %w", cl.ClusterName, err)
 		}
 
-		log.Info().Msg("attempting to delete managed ssh key...")
+		logger.Info().Msg("attempting to delete managed ssh key...")
 		err = gitlabClient.DeleteUserSSHKey("kbot-ssh-key")
 		if err != nil {
-			log.Error().Msg(err.Error())
+			logger.Error().Msg(err.Error())
 			return fmt.Errorf("error deleting managed ssh key for cluster %q: %w", cl.ClusterName, err)
 		}
 	}
 
 	telemetry.SendEvent(telemetryEvent, telemetry.ClusterDeleteCompleted, "")
 
-	cl.Status = constants.ClusterStatusDeleted
-	err = secrets.UpdateCluster(kcfg.Clientset, *cl)
+	err = secrets.UpdateClusterFields(kcfg.Clientset, cl, func(cluster *pkgtypes.Cluster) {
+		cluster.Status = constants.ClusterStatusDeleted
+	})
 	if err != nil {
 		return fmt.Errorf("error updating cluster status for cluster %q: %w", cl.ClusterName, err)
 	}